@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
@@ -133,22 +134,34 @@ func (g DeletionCounts) MarshalZerologObject(e *zerolog.Event) {
 var MaxGCInterval = 60 * time.Minute
 
 // StartGarbageCollector loops forever until the context is canceled and
-// performs garbage collection on the provided interval.
-func StartGarbageCollector(ctx context.Context, gc GarbageCollector, interval, window, timeout time.Duration) error {
-	return startGarbageCollectorWithMaxElapsedTime(ctx, gc, interval, window, 0, timeout, gcFailureCounter)
+// performs garbage collection on the provided interval. Each interval is
+// randomly offset by up to the jitter fraction of the interval in either
+// direction, so that the passes of multiple instances do not synchronize.
+func StartGarbageCollector(ctx context.Context, gc GarbageCollector, interval time.Duration, jitter float64, window, timeout time.Duration) error {
+	return startGarbageCollectorWithMaxElapsedTime(ctx, gc, interval, jitter, window, 0, timeout, gcFailureCounter)
 }
 
-func startGarbageCollectorWithMaxElapsedTime(ctx context.Context, gc GarbageCollector, interval, window, maxElapsedTime, timeout time.Duration, failureCounter prometheus.Counter) error {
+// jitteredInterval returns the interval randomly offset by up to the given
+// fraction of the interval in either direction.
+func jitteredInterval(interval time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+func startGarbageCollectorWithMaxElapsedTime(ctx context.Context, gc GarbageCollector, interval time.Duration, jitter float64, window, maxElapsedTime, timeout time.Duration, failureCounter prometheus.Counter) error {
 	backoffInterval := backoff.NewExponentialBackOff()
 	backoffInterval.InitialInterval = interval
 	backoffInterval.MaxInterval = max(MaxGCInterval, interval)
 	backoffInterval.MaxElapsedTime = maxElapsedTime
 	backoffInterval.Reset()
 
-	nextInterval := interval
+	nextInterval := jitteredInterval(interval, jitter)
 
 	log.Ctx(ctx).Info().
-		Dur("interval", nextInterval).
+		Dur("interval", interval).
+		Float64("jitter", jitter).
 		Msg("datastore garbage collection worker started")
 
 	for {
@@ -176,10 +189,10 @@ func startGarbageCollectorWithMaxElapsedTime(ctx context.Context, gc GarbageColl
 			}
 
 			backoffInterval.Reset()
-			nextInterval = interval
+			nextInterval = jitteredInterval(interval, jitter)
 
 			log.Ctx(ctx).Debug().
-				Dur("next-run-in", interval).
+				Dur("next-run-in", nextInterval).
 				Msg("datastore garbage collection scheduled for next run")
 		}
 	}
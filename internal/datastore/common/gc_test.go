@@ -170,7 +170,7 @@ func TestGCFailureBackoff(t *testing.T) {
 	defer cancel()
 	go func() {
 		gc := newFakeGC(alwaysErrorDeleter{})
-		require.Error(t, startGarbageCollectorWithMaxElapsedTime(ctx, &gc, 100*time.Millisecond, 1*time.Second, 1*time.Nanosecond, 1*time.Minute, localCounter))
+		require.Error(t, startGarbageCollectorWithMaxElapsedTime(ctx, &gc, 100*time.Millisecond, 0, 1*time.Second, 1*time.Nanosecond, 1*time.Minute, localCounter))
 	}()
 	time.Sleep(200 * time.Millisecond)
 	cancel()
@@ -192,7 +192,7 @@ func TestGCFailureBackoff(t *testing.T) {
 	defer cancel()
 	go func() {
 		gc := newFakeGC(alwaysErrorDeleter{})
-		require.Error(t, startGarbageCollectorWithMaxElapsedTime(ctx, &gc, 100*time.Millisecond, 0, 1*time.Second, 1*time.Minute, localCounter))
+		require.Error(t, startGarbageCollectorWithMaxElapsedTime(ctx, &gc, 100*time.Millisecond, 0, 0, 1*time.Second, 1*time.Minute, localCounter))
 	}()
 	time.Sleep(200 * time.Millisecond)
 	cancel()
@@ -226,7 +226,7 @@ func TestGCFailureBackoffReset(t *testing.T) {
 		window := 10 * time.Second
 		timeout := 1 * time.Minute
 
-		require.Error(t, StartGarbageCollector(ctx, &gc, interval, window, timeout))
+		require.Error(t, StartGarbageCollector(ctx, &gc, interval, 0, window, timeout))
 	}()
 
 	time.Sleep(500 * time.Millisecond)
@@ -249,7 +249,7 @@ func TestGCUnlockOnTimeout(t *testing.T) {
 		window := 10 * time.Second
 		timeout := 1 * time.Millisecond
 
-		require.Error(t, StartGarbageCollector(ctx, &gc, interval, window, timeout))
+		require.Error(t, StartGarbageCollector(ctx, &gc, interval, 0, window, timeout))
 	}()
 
 	time.Sleep(30 * time.Millisecond)
@@ -261,3 +261,13 @@ func TestGCUnlockOnTimeout(t *testing.T) {
 	require.True(t, gc.wasLocked, "GC should have been locked")
 	require.True(t, gc.wasUnlocked, "GC should have been unlocked")
 }
+
+func TestJitteredInterval(t *testing.T) {
+	require.Equal(t, time.Minute, jitteredInterval(time.Minute, 0))
+
+	for i := 0; i < 100; i++ {
+		jittered := jitteredInterval(time.Minute, 0.1)
+		require.GreaterOrEqual(t, jittered, 54*time.Second)
+		require.LessOrEqual(t, jittered, 66*time.Second)
+	}
+}
@@ -25,11 +25,24 @@ func SeparateContextWithTracing(ctx context.Context) context.Context {
 		ctxWithObservability = loggerFromContext.WithContext(ctxWithObservability)
 	}
 
+	if datastore.FollowerReadsDisabled(ctx) {
+		ctxWithObservability = datastore.WithFollowerReadsDisabled(ctxWithObservability)
+	}
+
+	if label := datastore.StatementLabel(ctx); label != "" {
+		ctxWithObservability = datastore.WithStatementLabel(ctxWithObservability, label)
+	}
+
+	if metadata := datastore.RelationshipMetadata(ctx); metadata != nil {
+		ctxWithObservability = datastore.WithRelationshipMetadata(ctxWithObservability, metadata)
+	}
+
 	return ctxWithObservability
 }
 
 // NewSeparatingContextDatastoreProxy severs any timeouts in the context being
-// passed to the datastore and only retains tracing metadata.
+// passed to the datastore and only retains tracing metadata, whether follower
+// reads have been disabled, the statement label and the relationship metadata.
 //
 // This is useful for datastores that do not want to close connections when a
 // cancel or deadline occurs.
@@ -0,0 +1,37 @@
+package crdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const asOfSystemTime = "AS OF SYSTEM TIME"
+
+// AsOfSystemTimeClause returns the `AS OF SYSTEM TIME` clause that reads the
+// data at the given HLC revision.
+//
+// Revisions that are older than now minus the gcWindow may refer to data that
+// has already been garbage collected, and are rejected with a
+// datastore.InvalidRevisionError.
+func AsOfSystemTimeClause(revision datastore.Revision, now time.Time, gcWindow time.Duration) (string, error) {
+	hlc, ok := revision.(revisions.HLCRevision)
+	if !ok {
+		return "", fmt.Errorf("expected HLC revision, got %T", revision)
+	}
+
+	if hlc.TimestampNanoSec() < now.Add(-gcWindow).UnixNano() {
+		return "", datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+	}
+
+	return asOfSystemTimeClause(hlc.String()), nil
+}
+
+// asOfSystemTimeClause formats the clause for an already validated revision
+// string. HLC revisions are always formatted as a decimal literal, and thus
+// need no quoting.
+func asOfSystemTimeClause(revision string) string {
+	return asOfSystemTime + " " + revision
+}
@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestAsOfSystemTimeClause(t *testing.T) {
+	now := time.Now()
+	gcWindow := 24 * time.Hour
+
+	withLogical, err := revisions.HLCRevisionFromString("1703283409994227985.0000000004")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name          string
+		revision      datastore.Revision
+		now           time.Time
+		expected      string
+		expectedStale bool
+		expectedError bool
+	}{
+		{
+			name:     "now",
+			revision: revisions.NewHLCForTime(now),
+			now:      now,
+			expected: "AS OF SYSTEM TIME " + revisions.NewHLCForTime(now).String(),
+		},
+		{
+			name:     "within gc window",
+			revision: revisions.NewHLCForTime(now.Add(-gcWindow + time.Minute)),
+			now:      now,
+			expected: "AS OF SYSTEM TIME " + revisions.NewHLCForTime(now.Add(-gcWindow+time.Minute)).String(),
+		},
+		{
+			name:     "with logical clock",
+			revision: withLogical,
+			now:      time.Unix(0, 1703283409994227985),
+			expected: "AS OF SYSTEM TIME 1703283409994227985.0000000004",
+		},
+		{
+			name:          "gc expired",
+			revision:      revisions.NewHLCForTime(now.Add(-gcWindow - time.Minute)),
+			now:           now,
+			expectedStale: true,
+		},
+		{
+			name:          "not an HLC revision",
+			revision:      revisions.NewForTransactionID(42),
+			now:           now,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clause, err := AsOfSystemTimeClause(tc.revision, tc.now, gcWindow)
+			switch {
+			case tc.expectedStale:
+				var invalidErr datastore.InvalidRevisionError
+				require.ErrorAs(t, err, &invalidErr)
+				require.Equal(t, datastore.RevisionStale, invalidErr.Reason())
+			case tc.expectedError:
+				require.Error(t, err)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, clause)
+			}
+		})
+	}
+}
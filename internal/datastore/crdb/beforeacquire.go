@@ -0,0 +1,19 @@
+package crdb
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// pingBeforeAcquire reports whether the connection is still usable, causing
+// the pool to destroy it and acquire another if it is not.
+func pingBeforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	if err := conn.Ping(ctx); err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("discarding connection that failed validation before acquire")
+		return false
+	}
+	return true
+}
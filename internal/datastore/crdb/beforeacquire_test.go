@@ -0,0 +1,100 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/require"
+)
+
+// serveFakeConn completes the startup of a single connection accepted on the
+// listener and then, unless dropAfterStartup is set, answers each query with
+// an empty result, much as a server answers the query of a ping. Otherwise,
+// the connection is closed, as by a server being restarted.
+func serveFakeConn(t *testing.T, listener net.Listener, dropAfterStartup bool) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		t.Errorf("unable to receive startup message: %v", err)
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		t.Errorf("unable to complete startup: %v", err)
+		return
+	}
+
+	if dropAfterStartup {
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg.(type) {
+		case *pgproto3.Query:
+			backend.Send(&pgproto3.EmptyQueryResponse{})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+func TestPingBeforeAcquire(t *testing.T) {
+	testCases := []struct {
+		name             string
+		dropAfterStartup bool
+		expectedUsable   bool
+	}{
+		{"server answers", false, true},
+		{"server dropped connection", true, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = listener.Close() })
+
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				serveFakeConn(t, listener, tc.dropAfterStartup)
+			}()
+			t.Cleanup(func() { <-served })
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			config, err := pgx.ParseConfig(fmt.Sprintf("postgres://root@%s/defaultdb?sslmode=disable", listener.Addr()))
+			require.NoError(t, err)
+			conn, err := pgx.ConnectConfig(ctx, config)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+			if tc.dropAfterStartup {
+				<-served
+			}
+
+			require.Equal(t, tc.expectedUsable, pingBeforeAcquire(ctx, conn))
+		})
+	}
+}
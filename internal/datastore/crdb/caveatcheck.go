@@ -0,0 +1,56 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/genutil/mapz"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// checkCaveatReferences checks that the caveat of each relationship created
+// or touched by the mutations is defined, and that its context only has keys
+// that are parameters of the caveat. The definitions are read within the
+// transaction, so that a caveat deleted concurrently is either seen as deleted
+// or conflicts with the write.
+func (rwt *crdbReadWriteTXN) checkCaveatReferences(ctx context.Context, mutations []tuple.RelationshipUpdate) error {
+	names := mapz.NewSet[string]()
+	for _, mutation := range mutations {
+		if mutation.Operation != tuple.UpdateOperationDelete && mutation.Relationship.OptionalCaveat != nil {
+			names.Add(mutation.Relationship.OptionalCaveat.CaveatName)
+		}
+	}
+	if names.IsEmpty() {
+		return nil
+	}
+
+	caveats, err := rwt.LookupCaveatsWithNames(ctx, names.AsSlice())
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteRelationships, err)
+	}
+
+	definitions := make(map[string]*core.CaveatDefinition, len(caveats))
+	for _, caveat := range caveats {
+		definitions[caveat.Definition.Name] = caveat.Definition
+	}
+
+	for _, mutation := range mutations {
+		rel := mutation.Relationship
+		if mutation.Operation == tuple.UpdateOperationDelete || rel.OptionalCaveat == nil {
+			continue
+		}
+
+		definition, ok := definitions[rel.OptionalCaveat.CaveatName]
+		if !ok {
+			return datastore.NewCaveatNameNotFoundErr(rel.OptionalCaveat.CaveatName)
+		}
+		for key := range rel.OptionalCaveat.Context.GetFields() {
+			if _, ok := definition.ParameterTypes[key]; !ok {
+				return datastore.NewCaveatContextMismatchErr(rel, key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// ChangeSink receives the relationship changes committed to the datastore,
+// such as to forward them to a message broker; see WithChangeSink.
+type ChangeSink interface {
+	// Publish is called with the relationship changes committed at a
+	// revision, one revision at a time and in revision order. If Publish
+	// returns an error, it is called again with the same changes, after a
+	// backoff, until it succeeds or the datastore is closed.
+	//
+	// Changes are delivered at least once: those of a revision may be
+	// published again after a disconnection of the changefeed, so the sink
+	// should deduplicate them by revision. The context is canceled when the
+	// datastore is closed.
+	Publish(ctx context.Context, changes *datastore.RevisionChanges) error
+}
+
+type watchFunc func(ctx context.Context, afterRevision datastore.Revision, options datastore.WatchOptions) (<-chan *datastore.RevisionChanges, <-chan error)
+
+// changeSinkPublisher feeds a change sink from a watch of the datastore,
+// restarting the watch after the last revision published whenever it is
+// disconnected.
+type changeSinkPublisher struct {
+	sink         ChangeSink
+	watch        watchFunc
+	bufferLength uint16
+
+	// headRevision returns the revision to resume from if the last revision
+	// published can no longer be watched.
+	headRevision func(ctx context.Context) (datastore.Revision, error)
+
+	// newBackOff returns the backoff between retries of a failed publish or
+	// of a disconnected watch.
+	newBackOff func() backoff.BackOff
+}
+
+const (
+	changeSinkInitialBackOff = 100 * time.Millisecond
+	changeSinkMaxBackOff     = 5 * time.Second
+)
+
+func newChangeSinkBackOff() backoff.BackOff {
+	retryBackoff := backoff.NewExponentialBackOff()
+	retryBackoff.InitialInterval = changeSinkInitialBackOff
+	retryBackoff.MaxInterval = changeSinkMaxBackOff
+	retryBackoff.MaxElapsedTime = 0
+	return retryBackoff
+}
+
+// run publishes the changes committed after the revision until the context
+// is canceled.
+func (csp *changeSinkPublisher) run(ctx context.Context, afterRevision datastore.Revision) {
+	reconnectBackOff := backoff.WithContext(csp.newBackOff(), ctx)
+	for {
+		published, err := csp.publishWatch(ctx, afterRevision)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if published.GreaterThan(afterRevision) {
+			afterRevision = published
+			reconnectBackOff.Reset()
+		}
+
+		var invalidRevision datastore.InvalidRevisionError
+		var catchupExceeded datastore.WatchCatchupWindowExceededError
+		switch {
+		case errors.As(err, &invalidRevision), errors.As(err, &catchupExceeded):
+			// The changes after the last revision published can no longer be
+			// read, so they are skipped rather than retried forever.
+			head, headErr := csp.headRevision(ctx)
+			if headErr != nil {
+				log.Ctx(ctx).Warn().Err(headErr).Msg("unable to read the head revision to resume the change sink")
+				break
+			}
+			log.Ctx(ctx).Error().Err(err).Stringer("afterRevision", afterRevision).Stringer("resumeRevision", head).
+				Msg("change sink fell behind the readable revisions; changes were skipped")
+			afterRevision = head
+
+		case err != nil:
+			log.Ctx(ctx).Warn().Err(err).Stringer("afterRevision", afterRevision).Msg("change sink watch disconnected; resuming after the last revision published")
+		}
+
+		wait := reconnectBackOff.NextBackOff()
+		if wait == backoff.Stop {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// publishWatch publishes the changes of a watch starting after the revision
+// until the watch ends, returning the last revision published and the error
+// that ended the watch. The changes buffered when the watch ends are published
+// before it returns.
+func (csp *changeSinkPublisher) publishWatch(ctx context.Context, afterRevision datastore.Revision) (datastore.Revision, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, errs := csp.watch(watchCtx, afterRevision, datastore.WatchOptions{
+		Content:           datastore.WatchRelationships,
+		WatchBufferLength: csp.bufferLength,
+	})
+
+	published := afterRevision
+	for {
+		select {
+		case <-ctx.Done():
+			return published, ctx.Err()
+
+		case change, ok := <-changes:
+			if !ok {
+				return published, <-errs
+			}
+			if len(change.RelationshipChanges) == 0 {
+				continue
+			}
+			if err := csp.publish(ctx, change); err != nil {
+				return published, err
+			}
+			published = change.Revision
+		}
+	}
+}
+
+// publish publishes the changes, retrying with backoff until they are
+// published or the context is canceled.
+func (csp *changeSinkPublisher) publish(ctx context.Context, change *datastore.RevisionChanges) error {
+	return backoff.RetryNotify(func() error {
+		return csp.sink.Publish(ctx, change)
+	}, backoff.WithContext(csp.newBackOff(), ctx), func(err error, wait time.Duration) {
+		log.Ctx(ctx).Warn().Err(err).Stringer("revision", change.Revision).Dur("retryIn", wait).Msg("change sink failed to publish changes")
+	})
+}
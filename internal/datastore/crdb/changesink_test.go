@@ -0,0 +1,145 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// fakeChangefeed serves watches of a fixed series of changes, disconnecting
+// them, as the datastore does, when their buffer stays full for longer than
+// the write timeout.
+type fakeChangefeed struct {
+	changes      []*datastore.RevisionChanges
+	writeTimeout time.Duration
+	watches      atomic.Int32
+}
+
+func (fc *fakeChangefeed) watch(ctx context.Context, afterRevision datastore.Revision, options datastore.WatchOptions) (<-chan *datastore.RevisionChanges, <-chan error) {
+	fc.watches.Add(1)
+	updates := make(chan *datastore.RevisionChanges, options.WatchBufferLength)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(updates)
+		for _, change := range fc.changes {
+			if !change.Revision.GreaterThan(afterRevision) {
+				continue
+			}
+			select {
+			case updates <- change:
+			case <-time.After(fc.writeTimeout):
+				errs <- datastore.NewWatchDisconnectedErr()
+				return
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+		<-ctx.Done()
+		errs <- ctx.Err()
+	}()
+	return updates, errs
+}
+
+// slowSink records the revisions published to it, taking a while to publish
+// each and failing the first attempt to publish the revision in failAt.
+type slowSink struct {
+	delay  time.Duration
+	failAt datastore.Revision
+
+	lock      sync.Mutex
+	published []datastore.Revision
+	failed    bool
+}
+
+func (ss *slowSink) Publish(_ context.Context, changes *datastore.RevisionChanges) error {
+	time.Sleep(ss.delay)
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	if changes.Revision.Equal(ss.failAt) && !ss.failed {
+		ss.failed = true
+		return errors.New("broker unavailable")
+	}
+	ss.published = append(ss.published, changes.Revision)
+	return nil
+}
+
+func (ss *slowSink) publishedRevisions() []datastore.Revision {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	return append([]datastore.Revision(nil), ss.published...)
+}
+
+func TestChangeSinkPublisherSlowSink(t *testing.T) {
+	rev := func(seconds int64) datastore.Revision {
+		return revisions.NewHLCForTime(time.Unix(seconds, 0))
+	}
+
+	feed := &fakeChangefeed{writeTimeout: time.Millisecond}
+	var expected []datastore.Revision
+	for i := int64(1); i <= 20; i++ {
+		feed.changes = append(feed.changes, &datastore.RevisionChanges{
+			Revision: rev(i),
+			RelationshipChanges: []tuple.RelationshipUpdate{
+				tuple.Touch(tuple.MustParse("document:doc1#viewer@user:tom")),
+			},
+		})
+		expected = append(expected, rev(i))
+	}
+
+	// Checkpoints are not published.
+	feed.changes = append(feed.changes, &datastore.RevisionChanges{Revision: rev(21), IsCheckpoint: true})
+
+	sink := &slowSink{delay: 5 * time.Millisecond, failAt: rev(5)}
+	publisher := &changeSinkPublisher{
+		sink:         sink,
+		watch:        feed.watch,
+		bufferLength: 2,
+		headRevision: func(context.Context) (datastore.Revision, error) { return rev(21), nil },
+		newBackOff:   func() backoff.BackOff { return backoff.NewConstantBackOff(time.Millisecond) },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publisher.run(ctx, rev(0))
+	}()
+
+	deduplicated := func() []datastore.Revision {
+		var revisions []datastore.Revision
+		for _, revision := range sink.publishedRevisions() {
+			if len(revisions) == 0 || !revision.Equal(revisions[len(revisions)-1]) {
+				revisions = append(revisions, revision)
+			}
+		}
+		return revisions
+	}
+	require.Eventually(t, func() bool { return len(deduplicated()) == len(expected) }, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	// The sink fell behind, so the feed was disconnected and resumed.
+	require.Greater(t, feed.watches.Load(), int32(1))
+	require.True(t, sink.failed)
+
+	// Every change was published, in revision order, with at most repeats of
+	// the same revision.
+	published := sink.publishedRevisions()
+	for i := 1; i < len(published); i++ {
+		require.False(t, published[i].LessThan(published[i-1]), "revision %s published after %s", published[i], published[i-1])
+	}
+	require.Equal(t, expected, deduplicated())
+}
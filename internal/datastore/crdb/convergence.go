@@ -0,0 +1,49 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+)
+
+// HasConvergedTo reports whether the head revision of the datastore is at
+// least the revision, which may have been read from another cluster, such as
+// the active cluster of an active-passive replication, allowing tooling to
+// check that this datastore has caught up before failing over to it.
+//
+// The logical component of an HLC revision is only ordered within the cluster
+// that assigned it, so revisions are compared by their wall-clock component
+// alone: the datastore has converged once its head revision's wall-clock time
+// is no earlier than that of the revision.
+func (cds *crdbDatastore) HasConvergedTo(ctx context.Context, revision datastore.Revision) (bool, error) {
+	if err := cds.checkOpen(); err != nil {
+		return false, err
+	}
+
+	head, err := cds.HeadRevision(ctx)
+	if err != nil {
+		return false, err
+	}
+	return convergedTo(head, revision)
+}
+
+// convergedTo reports whether the wall-clock component of the head revision is
+// at least that of the target revision.
+func convergedTo(head, target datastore.Revision) (bool, error) {
+	if target == datastore.NoRevision {
+		return true, nil
+	}
+
+	headHLC, ok := head.(revisions.HLCRevision)
+	if !ok {
+		return false, spiceerrors.MustBugf("expected HLC head revision, got %T", head)
+	}
+	targetHLC, ok := target.(revisions.HLCRevision)
+	if !ok {
+		return false, fmt.Errorf("revision %s is not a CockroachDB revision", target)
+	}
+	return headHLC.TimestampNanoSec() >= targetHLC.TimestampNanoSec(), nil
+}
@@ -0,0 +1,48 @@
+package crdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestConvergedTo(t *testing.T) {
+	mustParse := func(s string) datastore.Revision {
+		rev, err := revisions.HLCRevisionFromString(s)
+		require.NoError(t, err)
+		return rev
+	}
+
+	tcs := []struct {
+		name     string
+		head     string
+		target   string
+		expected bool
+	}{
+		{"later wall clock", "1235.0000000000", "1234.0000000005", true},
+		{"same revision", "1234.0000000005", "1234.0000000005", true},
+		{"same wall clock with a lower logical clock", "1234.0000000001", "1234.0000000005", true},
+		{"earlier wall clock", "1233.0000000009", "1234.0000000000", false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			converged, err := convergedTo(mustParse(tc.head), mustParse(tc.target))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, converged)
+		})
+	}
+
+	t.Run("no revision", func(t *testing.T) {
+		converged, err := convergedTo(mustParse("1234.0000000000"), datastore.NoRevision)
+		require.NoError(t, err)
+		require.True(t, converged)
+	})
+
+	t.Run("not an HLC revision", func(t *testing.T) {
+		_, err := convergedTo(mustParse("1234.0000000000"), revisions.NewForTransactionID(1))
+		require.Error(t, err)
+	})
+}
@@ -2,10 +2,13 @@ package crdb
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/pgxpoolprometheus"
@@ -15,9 +18,12 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
 	"github.com/shopspring/decimal"
 	"go.opentelemetry.io/otel"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
 	"resenje.org/singleflight"
 
 	datastoreinternal "github.com/authzed/spicedb/internal/datastore"
@@ -32,6 +38,15 @@ import (
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 )
 
+// newAdvertisedRevisionGauge creates the gauge of the advertised revision.
+func newAdvertisedRevisionGauge(metrics pool.Metrics) pool.Gauge {
+	return metrics.Gauge("spicedb_datastore_crdb_advertised_revision_timestamp_seconds",
+		"the timestamp of the optimized revision most recently computed by the datastore")
+}
+
+// advertisedRevisionGauge is registered with Prometheus on startup.
+var advertisedRevisionGauge = newAdvertisedRevisionGauge(pool.PrometheusMetrics())
+
 func init() {
 	datastore.Engines = append(datastore.Engines, Engine)
 }
@@ -91,6 +106,7 @@ const (
 	queryTransactionNowPreV23 = querySelectNow
 	queryTransactionNow       = "SHOW COMMIT TIMESTAMP"
 	queryShowZoneConfig       = "SHOW ZONE CONFIGURATION FOR RANGE default;"
+	queryShowIsolation        = "SHOW transaction_isolation"
 
 	spicedbTransactionKey = "$spicedb_transaction_key"
 )
@@ -103,21 +119,7 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
 	}
 
-	includeQueryParametersInTraces := config.includeQueryParametersInTraces
-	readPoolConfig, err := pgxpool.ParseConfig(url)
-	if err != nil {
-		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
-	}
-	err = config.readPoolOpts.ConfigurePgx(readPoolConfig, includeQueryParametersInTraces)
-	if err != nil {
-		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
-	}
-
-	writePoolConfig, err := pgxpool.ParseConfig(url)
-	if err != nil {
-		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
-	}
-	err = config.writePoolOpts.ConfigurePgx(writePoolConfig, includeQueryParametersInTraces)
+	readPoolConfig, writePoolConfig, err := config.poolConfigs(url)
 	if err != nil {
 		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
 	}
@@ -129,13 +131,16 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 	if err != nil {
 		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
 	}
+	var initPoolOpts []pool.RetryPoolOption
+	if config.metricsDisabled {
+		healthChecker.DisableMetrics()
+		initPoolOpts = append(initPoolOpts, pool.WithoutMetrics())
+	}
 
 	// The initPool is a 1-connection pool that is only used for setup tasks.
 	// The actual pools are not given the initCtx, since cancellation can
 	// interfere with pool setup.
-	initPoolConfig := readPoolConfig.Copy()
-	initPoolConfig.MinConns = 1
-	initPool, err := pool.NewRetryPool(initCtx, "init", initPoolConfig, healthChecker, config.maxRetries, config.connectRate)
+	initPool, err := pool.NewRetryPool(initCtx, "init", initPoolConfig(readPoolConfig), healthChecker, config.maxRetries, config.connectRate, initPoolOpts...)
 	if err != nil {
 		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
 	}
@@ -146,15 +151,20 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
 	}
 
+	logger := log.Ctx(initCtx)
+	if config.logger != nil {
+		logger = config.logger
+	}
+
 	changefeedQuery := queryChangefeed
 	if version.Major < 22 {
-		log.Info().Object("version", version).Msg("using changefeed query for CRDB version < 22")
+		logger.Info().Object("version", version).Msg("using changefeed query for CRDB version < 22")
 		changefeedQuery = queryChangefeedPreV22
 	}
 
 	transactionNowQuery := queryTransactionNow
 	if version.Major < 23 {
-		log.Info().Object("version", version).Msg("using transaction now query for CRDB version < 23")
+		logger.Info().Object("version", version).Msg("using transaction now query for CRDB version < 23")
 		transactionNowQuery = queryTransactionNowPreV23
 	}
 
@@ -165,7 +175,7 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 
 	gcWindowNanos := config.gcWindow.Nanoseconds()
 	if clusterTTLNanos < gcWindowNanos {
-		log.Warn().
+		logger.Warn().
 			Int64("cockroach_cluster_gc_window_nanos", clusterTTLNanos).
 			Int64("spicedb_gc_window_nanos", gcWindowNanos).
 			Msg("configured CockroachDB cluster gc window is less than configured SpiceDB gc window, falling back to CRDB value - see https://spicedb.dev/d/crdb-gc-window-warning")
@@ -187,7 +197,7 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 		keyer = noOverlapKeyer
 		keySetInit = overlapKeysFromContext
 	case overlapStrategyInsecure:
-		log.Warn().Str("strategy", overlapStrategyInsecure).
+		logger.Warn().Str("strategy", overlapStrategyInsecure).
 			Msg("running in this mode is only safe when replicas == nodes")
 		keyer = noOverlapKeyer
 	}
@@ -200,32 +210,109 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 		return nil, fmt.Errorf("invalid head migration found for cockroach: %w", err)
 	}
 
-	relTableName := tableTuple
-	if config.withIntegrity {
-		relTableName = tableTupleWithIntegrity
+	schema := newCRDBSchema(config)
+
+	migrationValidator := common.NewMigrationValidator(headMigration, config.allowedMigrations)
+	if config.validateSchemaOnOpen {
+		if err := validateSchema(initCtx, initPool, migrationValidator, *schema, versionTableName(config.migrationTablePrefix)); err != nil {
+			return nil, fmt.Errorf("unable to validate datastore schema: %w", err)
+		}
 	}
 
-	schema := common.NewSchemaInformationWithOptions(
-		common.WithRelationshipTableName(relTableName),
-		common.WithColNamespace(colNamespace),
-		common.WithColObjectID(colObjectID),
-		common.WithColRelation(colRelation),
-		common.WithColUsersetNamespace(colUsersetNamespace),
-		common.WithColUsersetObjectID(colUsersetObjectID),
-		common.WithColUsersetRelation(colUsersetRelation),
-		common.WithColCaveatName(colCaveatContextName),
-		common.WithColCaveatContext(colCaveatContext),
-		common.WithColExpiration(colExpiration),
-		common.WithColIntegrityKeyID(colIntegrityKeyID),
-		common.WithColIntegrityHash(colIntegrityHash),
-		common.WithColIntegrityTimestamp(colTimestamp),
-		common.WithPaginationFilterType(common.ExpandedLogicComparison),
-		common.WithPlaceholderFormat(sq.Dollar),
-		common.WithNowFunction("NOW"),
-		common.WithColumnOptimization(config.columnOptimizationOption),
-		common.WithIntegrityEnabled(config.withIntegrity),
-		common.WithExpirationDisabled(config.expirationDisabled),
-	)
+	var maxConcurrentWatches, maxTotalWatchBufferBytes int
+	if config.maxConcurrentWatches != nil {
+		maxConcurrentWatches = *config.maxConcurrentWatches
+	}
+	if config.maxTotalWatchBufferBytes != nil {
+		maxTotalWatchBufferBytes = *config.maxTotalWatchBufferBytes
+	}
+	var maxTuplesPerWrite int
+	if config.maxTuplesPerWrite != nil {
+		maxTuplesPerWrite = *config.maxTuplesPerWrite
+	}
+	advertisedRevision, gcCaveatsCollected := advertisedRevisionGauge, gcCaveatsCollectedCounter
+	if config.metrics != nil {
+		advertisedRevision = newAdvertisedRevisionGauge(config.metrics)
+		gcCaveatsCollected = newGCCaveatsCollectedCounter(config.metrics)
+	}
+
+	// this ctx and cancel is tied to the lifetime of the datastore
+	dsCtx, dsCancel := context.WithCancel(context.Background())
+	if config.logger != nil {
+		dsCtx = config.logger.WithContext(dsCtx)
+	}
+	retryPoolOpts := []pool.RetryPoolOption{
+		pool.WithQueryTimeout(config.queryTimeout),
+		pool.WithSlowQueryThreshold(config.slowQueryThreshold),
+		pool.WithRetryLogSampling(uint64(config.retryLogSampleRate)),
+	}
+	if config.statementLabels {
+		retryPoolOpts = append(retryPoolOpts, pool.WithStatementLabels())
+	}
+	if config.deadlineStatementTimeouts {
+		retryPoolOpts = append(retryPoolOpts, pool.WithDeadlineStatementTimeouts())
+	}
+	if config.simpleProtocolFallback {
+		retryPoolOpts = append(retryPoolOpts, pool.WithSimpleProtocolFallback())
+	}
+	if callbacks := config.connectionCallbacks; callbacks.OnConnect != nil || callbacks.OnDisconnect != nil || callbacks.OnConnectError != nil {
+		retryPoolOpts = append(retryPoolOpts, pool.WithConnectionCallbacks(callbacks))
+	}
+	if config.fairPoolAcquisition {
+		retryPoolOpts = append(retryPoolOpts, pool.WithFairAcquisition())
+	}
+	if config.metricsDisabled {
+		retryPoolOpts = append(retryPoolOpts, pool.WithoutMetrics())
+	}
+	if config.metrics != nil {
+		retryPoolOpts = append(retryPoolOpts, pool.WithMetrics(config.metrics))
+	}
+	if config.poolRefillMode != PoolRefillEager {
+		retryPoolOpts = append(retryPoolOpts, pool.WithRefillMode(config.poolRefillMode))
+	}
+	if config.retryBudgetRate > 0 {
+		retryBudget := rate.NewLimiter(rate.Limit(config.retryBudgetRate), config.retryBudgetBurst)
+		retryPoolOpts = append(retryPoolOpts, pool.WithRetryBudget(retryBudget))
+	}
+	readRetryPoolOpts, err := withMinIdleConns(retryPoolOpts, config.readPoolOpts)
+	if err != nil {
+		dsCancel()
+		return nil, err
+	}
+	readPool, err := pool.NewRetryPool(dsCtx, "read", readPoolConfig, healthChecker, config.maxRetries, config.connectRate, readRetryPoolOpts...)
+	if err != nil {
+		dsCancel()
+		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
+	}
+
+	// A read replica has no write pool: the reads that would otherwise use it,
+	// such as those with follower reads disabled, use the read pool (see
+	// strongReadPool), and every write fails with ErrReadOnlyMode.
+	var writePool *pool.RetryPool
+	if !config.readReplica {
+		writeRetryPoolOpts, err := withMinIdleConns(append([]pool.RetryPoolOption{pool.WithMaxAcquireQueueDepth(config.writeConnsMaxQueueDepth)}, retryPoolOpts...), config.writePoolOpts)
+		if err != nil {
+			readPool.Close()
+			dsCancel()
+			return nil, err
+		}
+		writePool, err = pool.NewRetryPool(dsCtx, "write", writePoolConfig, healthChecker, config.maxRetries, config.connectRate, writeRetryPoolOpts...)
+		if err != nil {
+			readPool.Close()
+			dsCancel()
+			return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
+		}
+	}
+
+	// closeOnError closes the pools, and cancels the context they are tied
+	// to, when the datastore fails to be created after they are.
+	closeOnError := func() {
+		readPool.Close()
+		if writePool != nil {
+			writePool.Close()
+		}
+		dsCancel()
+	}
 
 	ds := &crdbDatastore{
 		RemoteClockRevisions: revisions.NewRemoteClockRevisions(
@@ -234,51 +321,113 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 			config.followerReadDelay,
 			config.revisionQuantization,
 		),
-		CommonDecoder:           revisions.CommonDecoder{Kind: revisions.HybridLogicalClock},
-		MigrationValidator:      common.NewMigrationValidator(headMigration, config.allowedMigrations),
-		dburl:                   url,
-		watchBufferLength:       config.watchBufferLength,
-		watchBufferWriteTimeout: config.watchBufferWriteTimeout,
-		watchConnectTimeout:     config.watchConnectTimeout,
-		writeOverlapKeyer:       keyer,
-		overlapKeyInit:          keySetInit,
-		beginChangefeedQuery:    changefeedQuery,
-		transactionNowQuery:     transactionNowQuery,
-		analyzeBeforeStatistics: config.analyzeBeforeStatistics,
-		filterMaximumIDCount:    config.filterMaximumIDCount,
-		supportsIntegrity:       config.withIntegrity,
-		gcWindow:                config.gcWindow,
-		schema:                  *schema,
+		CommonDecoder:              revisions.CommonDecoder{Kind: revisions.HybridLogicalClock},
+		MigrationValidator:         migrationValidator,
+		dburl:                      url,
+		ctx:                        dsCtx,
+		cancel:                     dsCancel,
+		readPool:                   readPool,
+		writePool:                  writePool,
+		healthChecker:              healthChecker,
+		effectiveConfig:            config.effectiveConfig(readPoolConfig, writePoolConfig),
+		readReplica:                config.readReplica,
+		allowDestructiveOperations: config.allowDestructiveOperations,
+		migrationTablePrefix:       config.migrationTablePrefix,
+		watches:                    newWatchRegistry(maxConcurrentWatches, maxTotalWatchBufferBytes),
+		watchCompressionThreshold:  config.watchCompressionThreshold,
+		watchBufferOverflowPolicy:  config.watchBufferOverflowPolicy,
+		watchBufferLength:          config.watchBufferLength,
+		watchBufferLengthByType:    config.watchBufferLengthByType,
+		watchBufferWriteTimeout:    config.watchBufferWriteTimeout,
+		watchConnectTimeout:        config.watchConnectTimeout,
+		maxWatchCatchupWindow:      config.maxWatchCatchupWindow,
+		closeTimeout:               config.closeTimeout,
+		watchCoalesceWindow:        config.watchCoalesceWindow,
+		watchBatchSize:             config.watchBatchSize,
+		watchBatchMaxLatency:       config.watchBatchMaxLatency,
+		writeOverlapKeyer:          keyer,
+		overlapKeyInit:             keySetInit,
+		beginChangefeedQuery:       changefeedQuery,
+		transactionNowQuery:        transactionNowQuery,
+		analyzeBeforeStatistics:    config.analyzeBeforeStatistics,
+		filterMaximumIDCount:       config.filterMaximumIDCount,
+		supportsIntegrity:          config.withIntegrity,
+		writeBatchSize:             config.writeBatchSize,
+		readPageSize:               uint64(config.readPageSize),
+		maxListResults:             config.maxListResults(),
+		checkIndexHint:             config.checkIndexHint,
+		gcDeletes:                  semaphore.NewWeighted(int64(config.gcMaxConcurrentDeletes)),
+		gcQualityOfService:         config.gcQualityOfService,
+		gcCaveats:                  config.gcCaveats,
+		gcCaveatsCollected:         gcCaveatsCollected,
+		gcDanglingRelationships:    config.gcDanglingRelationships,
+		metricsDisabled:            config.metricsDisabled,
+		writePriority:              config.writeTransactionPriority,
+		logger:                     config.logger,
+		statementLabels:            config.statementLabels,
+		metadataColumns:            config.metadataColumns,
+		maxRowsPerTransaction:      config.maxRowsPerTransaction,
+		maxTuplesPerWrite:          maxTuplesPerWrite,
+		duplicateWritePolicy:       config.duplicateWritePolicy,
+		validateCaveatsOnWrite:     config.validateCaveatsOnWrite,
+		gcWindow:                   config.gcWindow,
+		schema:                     *schema,
 	}
+	// readOnly is atomic, since SetReadOnly toggles it, so it cannot be set in
+	// the literal.
+	ds.readOnly.Store(config.readOnlyMode || config.readReplica)
 	ds.RemoteClockRevisions.SetNowFunc(ds.headRevisionInternal)
-
-	// this ctx and cancel is tied to the lifetime of the datastore
-	ds.ctx, ds.cancel = context.WithCancel(context.Background())
-	ds.writePool, err = pool.NewRetryPool(ds.ctx, "write", writePoolConfig, healthChecker, config.maxRetries, config.connectRate)
-	if err != nil {
-		ds.cancel()
-		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
+	if config.clock != nil {
+		ds.RemoteClockRevisions.SetClock(config.clock)
+		ds.RemoteClockRevisions.SetNowFunc(revisions.HLCClockNowFunction(config.clock))
 	}
-	ds.readPool, err = pool.NewRetryPool(ds.ctx, "read", readPoolConfig, healthChecker, config.maxRetries, config.connectRate)
-	if err != nil {
-		ds.cancel()
-		return nil, common.RedactAndLogSensitiveConnString(ctx, errUnableToInstantiate, err, url)
+	ds.RemoteClockRevisions.SetFutureRevisionPolicy(config.futureRevisionPolicy, config.futureRevisionMaxWait)
+	ds.RemoteClockRevisions.SetQuantizationAlignment(config.quantizationAlignment)
+	ds.RemoteClockRevisions.SetMaxCachedRevisionAge(config.maxCachedRevisionAge)
+	ds.RemoteClockRevisions.SetBackwardsRevisionPolicy(config.backwardsRevisionPolicy)
+	var notifier *revisionNotifier
+	if config.revisionAdvancedCallback != nil {
+		notifier = newRevisionNotifier(config.revisionAdvancedCallback)
+	}
+	switch {
+	case config.advertisedRevisionMetric && notifier != nil:
+		record := recordAdvertisedRevision(advertisedRevision)
+		ds.RemoteClockRevisions.SetRevisionObserver(func(rev datastore.Revision) {
+			record(rev)
+			notifier.observe(rev)
+		})
+	case config.advertisedRevisionMetric:
+		ds.RemoteClockRevisions.SetRevisionObserver(recordAdvertisedRevision(advertisedRevision))
+	case notifier != nil:
+		ds.RemoteClockRevisions.SetRevisionObserver(notifier.observe)
+	}
+
+	if err := ds.checkMetadataColumns(initCtx); err != nil {
+		closeOnError()
+		return nil, err
 	}
 
 	if config.enablePrometheusStats {
-		if err := prometheus.Register(pgxpoolprometheus.NewCollector(ds.writePool, map[string]string{
-			"db_name":    "spicedb",
-			"pool_usage": "write",
-		})); err != nil {
-			ds.cancel()
-			return nil, err
+		var writeCollector prometheus.Collector
+		if !config.readReplica {
+			writeCollector = pgxpoolprometheus.NewCollector(writePool, map[string]string{
+				"db_name":    "spicedb",
+				"pool_usage": "write",
+			})
+			if err := prometheus.Register(writeCollector); err != nil {
+				closeOnError()
+				return nil, err
+			}
 		}
 
-		if err := prometheus.Register(pgxpoolprometheus.NewCollector(ds.readPool, map[string]string{
+		if err := prometheus.Register(pgxpoolprometheus.NewCollector(readPool, map[string]string{
 			"db_name":    "spicedb",
 			"pool_usage": "read",
 		})); err != nil {
-			ds.cancel()
+			if writeCollector != nil {
+				prometheus.Unregister(writeCollector)
+			}
+			closeOnError()
 			return nil, err
 		}
 	}
@@ -288,27 +437,164 @@ func newCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 
 	// Start goroutines for pruning
 	if config.enableConnectionBalancing {
-		log.Ctx(initCtx).Info().Msg("starting cockroach connection balancer")
+		logger.Info().Msg("starting cockroach connection balancer")
 		ds.pruneGroup, ds.ctx = errgroup.WithContext(ds.ctx)
-		writePoolBalancer := pool.NewNodeConnectionBalancer(ds.writePool, healthChecker, 5*time.Second)
+		if !config.readReplica {
+			writePoolBalancer := pool.NewNodeConnectionBalancer(ds.writePool, healthChecker, 5*time.Second)
+			ds.pruneGroup.Go(func() error {
+				writePoolBalancer.Prune(ds.ctx)
+				return nil
+			})
+		}
 		readPoolBalancer := pool.NewNodeConnectionBalancer(ds.readPool, healthChecker, 5*time.Second)
-		ds.pruneGroup.Go(func() error {
-			writePoolBalancer.Prune(ds.ctx)
-			return nil
-		})
 		ds.pruneGroup.Go(func() error {
 			readPoolBalancer.Prune(ds.ctx)
 			return nil
 		})
 		ds.pruneGroup.Go(func() error {
-			healthChecker.Poll(ds.ctx, 5*time.Second)
+			healthChecker.Poll(ds.ctx, config.nodeHealthCheckInterval)
 			return nil
 		})
 	}
 
+	if notifier != nil {
+		ds.goBackground(func() { notifier.run(ds.ctx) })
+	}
+
+	// Keep the advertised revision advancing while the datastore is idle. A
+	// read replica computes revisions only as they are requested.
+	if !config.readReplica {
+		ds.goBackground(func() {
+			runRevisionHeartbeat(ds.ctx, config.revisionHeartbeatInterval, func(ctx context.Context) error {
+				_, err := ds.RemoteClockRevisions.OptimizedRevision(ctx)
+				return err
+			})
+		})
+	}
+
+	if config.changeSink != nil {
+		afterRevision, err := ds.headRevisionInternal(initCtx)
+		if err != nil {
+			_ = ds.Close()
+			return nil, fmt.Errorf("unable to read the revision to publish changes from: %w", err)
+		}
+
+		bufferLength := config.changeSinkBufferLength
+		if bufferLength == 0 {
+			bufferLength = config.watchBufferLength
+		}
+		publisher := &changeSinkPublisher{
+			sink: config.changeSink,
+			// The sink must receive every change, so its watch blocks when
+			// its buffer is full, whatever the policy of other watches.
+			watch: func(ctx context.Context, afterRevision datastore.Revision, options datastore.WatchOptions) (<-chan *datastore.RevisionChanges, <-chan error) {
+				return ds.watchWithOverflowPolicy(ctx, afterRevision, options, WatchBufferOverflowBlock)
+			},
+			bufferLength: bufferLength,
+			headRevision: ds.headRevisionInternal,
+			newBackOff:   newChangeSinkBackOff,
+		}
+		ds.goBackground(func() { publisher.run(ds.ctx, afterRevision) })
+	}
+
+	if config.healthLogInterval > 0 {
+		ds.goBackground(func() { runHealthLog(ds.ctx, config.healthLogInterval, ds.healthSummary) })
+	}
+
+	if config.prewarmRevisionCache {
+		ds.prewarmRevisionCache(initCtx, logger)
+	}
+
 	return ds, nil
 }
 
+// initPoolConfig returns the configuration of the 1-connection pool used for
+// setup tasks, derived from that of the read pool. The setup tasks are not
+// limited to reads, so the init pool does not inherit the read pool's
+// read-only default.
+func initPoolConfig(readPoolConfig *pgxpool.Config) *pgxpool.Config {
+	config := readPoolConfig.Copy()
+	config.MinConns = 1
+	delete(config.ConnConfig.RuntimeParams, "default_transaction_read_only")
+	return config
+}
+
+// poolConfigs returns the configurations of the read and write pools of a
+// datastore connecting to the URL with the options.
+func (co crdbOptions) poolConfigs(url string) (readPoolConfig, writePoolConfig *pgxpool.Config, err error) {
+	readPoolConfig, err = pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = co.readPoolOpts.ConfigurePgx(readPoolConfig, co.includeQueryParametersInTraces)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writePoolConfig, err = pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = co.writePoolOpts.ConfigurePgx(writePoolConfig, co.includeQueryParametersInTraces)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if co.connectTimeout > 0 {
+		readPoolConfig.ConnConfig.ConnectTimeout = co.connectTimeout
+		writePoolConfig.ConnConfig.ConnectTimeout = co.connectTimeout
+	}
+
+	if co.tcpKeepaliveInterval > 0 {
+		readPoolConfig.ConnConfig.DialFunc = keepaliveDialFunc(readPoolConfig.ConnConfig.ConnectTimeout, co.tcpKeepaliveInterval, co.tcpKeepaliveCount)
+		writePoolConfig.ConnConfig.DialFunc = keepaliveDialFunc(writePoolConfig.ConnConfig.ConnectTimeout, co.tcpKeepaliveInterval, co.tcpKeepaliveCount)
+	}
+
+	if co.vectorize != "" {
+		readPoolConfig.ConnConfig.RuntimeParams["vectorize"] = co.vectorize
+		writePoolConfig.ConnConfig.RuntimeParams["vectorize"] = co.vectorize
+	}
+
+	if co.connectionLabel != "" {
+		readPoolConfig.ConnConfig.RuntimeParams["application_name"] = co.connectionLabel
+		writePoolConfig.ConnConfig.RuntimeParams["application_name"] = co.connectionLabel
+	}
+
+	if co.queryExecMode != nil {
+		readPoolConfig.ConnConfig.DefaultQueryExecMode = *co.queryExecMode
+		writePoolConfig.ConnConfig.DefaultQueryExecMode = *co.queryExecMode
+	}
+
+	if co.statementCacheCapacity != nil {
+		readPoolConfig.ConnConfig.StatementCacheCapacity = *co.statementCacheCapacity
+		writePoolConfig.ConnConfig.StatementCacheCapacity = *co.statementCacheCapacity
+	}
+
+	if co.descriptionCacheCapacity != nil {
+		readPoolConfig.ConnConfig.DescriptionCacheCapacity = *co.descriptionCacheCapacity
+		writePoolConfig.ConnConfig.DescriptionCacheCapacity = *co.descriptionCacheCapacity
+	}
+
+	// Only a read pool distinct from the write pool is made read-only: a read
+	// replica has no write pool, so its read pool also serves the reads that
+	// would otherwise use the write pool (see strongReadPool).
+	if co.readOnlyReadPool && !co.readReplica {
+		readPoolConfig.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+
+	if co.validateConnBeforeAcquire {
+		readPoolConfig.BeforeAcquire = pingBeforeAcquire
+		writePoolConfig.BeforeAcquire = pingBeforeAcquire
+	}
+
+	if co.resetQueryOnRelease != "" {
+		readPoolConfig.AfterRelease = resetAfterRelease(co.resetQueryOnRelease)
+		writePoolConfig.AfterRelease = resetAfterRelease(co.resetQueryOnRelease)
+	}
+
+	return readPoolConfig, writePoolConfig, nil
+}
+
 // NewCRDBDatastore initializes a SpiceDB datastore that uses a CockroachDB
 // database while leveraging its AOST functionality.
 func NewCRDBDatastore(ctx context.Context, url string, options ...Option) (datastore.Datastore, error) {
@@ -319,6 +605,16 @@ func NewCRDBDatastore(ctx context.Context, url string, options ...Option) (datas
 	return datastoreinternal.NewSeparatingContextDatastoreProxy(ds), nil
 }
 
+// NewReadOnlyCRDBDatastore initializes a SpiceDB datastore that only serves
+// reads from a CockroachDB database, such as for a tier of processes scaled
+// out to serve reads from follower reads or replicas. It opens only the read
+// pool and runs no revision heartbeat, and its writes, including garbage
+// collection, fail with ErrReadOnlyMode regardless of SetReadOnly.
+func NewReadOnlyCRDBDatastore(ctx context.Context, url string, options ...Option) (datastore.Datastore, error) {
+	options = append(slices.Clone(options), func(po *crdbOptions) { po.readReplica = true })
+	return NewCRDBDatastore(ctx, url, options...)
+}
+
 type crdbDatastore struct {
 	*revisions.RemoteClockRevisions
 	revisions.CommonDecoder
@@ -327,8 +623,13 @@ type crdbDatastore struct {
 	dburl                   string
 	readPool, writePool     *pool.RetryPool
 	watchBufferLength       uint16
+	watchBufferLengthByType map[string]uint16
 	watchBufferWriteTimeout time.Duration
 	watchConnectTimeout     time.Duration
+	maxWatchCatchupWindow   time.Duration
+	watchCoalesceWindow     time.Duration
+	watchBatchSize          uint16
+	watchBatchMaxLatency    time.Duration
 	writeOverlapKeyer       overlapKeyer
 	overlapKeyInit          func(ctx context.Context) keySet
 	analyzeBeforeStatistics bool
@@ -345,19 +646,128 @@ type crdbDatastore struct {
 	cancel               context.CancelFunc
 	filterMaximumIDCount uint16
 	supportsIntegrity    bool
+	writeBatchSize       int
+	readPageSize         uint64
+	maxListResults       uint64
+	checkIndexHint       string
+	metadataColumns      []string
+	gcDeletes            *semaphore.Weighted
+	gcQualityOfService   string
+	gcCaveats            bool
+	gcCaveatsCollected   pool.Counter
+	unreferencedCaveats  unreferencedCaveats
+	metricsDisabled      bool
+	writePriority        string
+	statementLabels      bool
+
+	// logger receives the datastore's operational logs, and is nil if they
+	// use the logger of the context in which they are emitted.
+	logger *zerolog.Logger
+
+	allowDestructiveOperations bool
+
+	// migrationTablePrefix is the prefix of the version tables from which the
+	// version of the schema is read, as given to MigrationTablePrefix.
+	migrationTablePrefix string
+
+	// watches tracks the watches being served, limiting their number to
+	// MaxConcurrentWatches and the size of their buffers to
+	// MaxTotalWatchBufferBytes.
+	watches *watchRegistry
+
+	// effectiveConfig is the configuration in effect, as resolved from the
+	// options when the datastore was created.
+	effectiveConfig EffectiveConfig
+
+	// healthChecker tracks the health of the cluster's nodes, which it polls
+	// when connection balancing is enabled.
+	healthChecker *pool.NodeHealthTracker
+
+	// watchBufferOverflowPolicy is what happens when a change is produced for
+	// a watch whose buffer is full; see WatchBufferOverflowPolicy.
+	watchBufferOverflowPolicy string
+
+	// watchCompressionThreshold is the size from which the caveat contexts of
+	// buffered watch changes are compressed, or zero if they are not.
+	watchCompressionThreshold int
+
+	// maxRowsPerTransaction is the maximum number of mutations written by each
+	// transaction of WriteRelationshipsInBatches, or zero if they are not
+	// split; see AllowTransactionSplitting.
+	maxRowsPerTransaction int
+
+	// maxTuplesPerWrite is the maximum number of mutations of a relationship
+	// write, or zero if writes are not limited; see MaxTuplesPerWrite.
+	maxTuplesPerWrite int
+
+	// gcDanglingRelationships is set if garbage collection deletes the
+	// relationships whose object types are not defined; see
+	// GCDanglingRelationships.
+	gcDanglingRelationships bool
+
+	// duplicateWritePolicy is what happens when a write creates a relationship
+	// that already exists; see DuplicateWritePolicy.
+	duplicateWritePolicy string
+
+	// validateCaveatsOnWrite is set if the caveats of written relationships are
+	// checked against their definitions; see ValidateCaveatsOnWrite.
+	validateCaveatsOnWrite bool
+
+	// closed is set once Close has been called, after which the datastore
+	// returns datastore.ErrDatastoreClosed rather than using its pools.
+	closed atomic.Bool
+
+	// background tracks the goroutines, such as the revision heartbeat and
+	// watches, that Close waits for, for at most closeTimeout. backgroundLock
+	// orders the start of goroutines with Close.
+	background     sync.WaitGroup
+	backgroundLock sync.Mutex
+	closeTimeout   time.Duration
+
+	// readOnly is set while the datastore is in read-only mode, during which
+	// read-write transactions fail with ErrReadOnlyMode.
+	readOnly atomic.Bool
+
+	// readReplica is set for datastores created by NewReadOnlyCRDBDatastore,
+	// which remain in read-only mode and have no write pool; their strong
+	// reads use the read pool instead, through strongReadPool.
+	readReplica bool
+
+	// instanceID caches the instance ID once it has been read; see InstanceID.
+	instanceID atomic.Pointer[string]
+}
+
+// ErrReadOnlyMode is returned by ReadWriteTx while the datastore has been put
+// in read-only mode, such as for a maintenance window of the cluster.
+var ErrReadOnlyMode = datastore.NewReadonlyErr()
+
+// SetReadOnly enables or disables read-only mode. While enabled, read-write
+// transactions fail with ErrReadOnlyMode without being started, while reads
+// continue to be served. Transactions already running are not affected. A
+// datastore created by NewReadOnlyCRDBDatastore remains read-only.
+func (cds *crdbDatastore) SetReadOnly(readOnly bool) {
+	cds.readOnly.Store(readOnly || cds.readReplica)
 }
 
 func (cds *crdbDatastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	return cds.snapshotReader(rev)
+}
+
+func (cds *crdbDatastore) snapshotReader(rev datastore.Revision) *crdbReader {
+	querier := cds.readQuerier()
 	executor := common.QueryRelationshipsExecutor{
-		Executor: pgxcommon.NewPGXQueryRelationshipsExecutor(cds.readPool),
+		Executor: pgxcommon.NewPGXQueryRelationshipsExecutor(querier),
 	}
 	return &crdbReader{
 		schema:               cds.schema,
-		query:                cds.readPool,
+		query:                querier,
 		executor:             executor,
 		keyer:                noOverlapKeyer,
 		overlapKeySet:        nil,
 		filterMaximumIDCount: cds.filterMaximumIDCount,
+		readPageSize:         cds.readPageSize,
+		maxListResults:       cds.maxListResults,
+		checkIndexHint:       cds.checkIndexHint,
 		withIntegrity:        cds.supportsIntegrity,
 		atSpecificRevision:   rev.String(),
 	}
@@ -370,12 +780,27 @@ func (cds *crdbDatastore) ReadWriteTx(
 ) (datastore.Revision, error) {
 	var commitTimestamp datastore.Revision
 
+	if err := cds.checkOpen(); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	if cds.readOnly.Load() {
+		return datastore.NoRevision, ErrReadOnlyMode
+	}
+
+	ctx = datastore.WithStatementLabel(ctx, datastore.StatementLabelWrite)
+
 	config := options.NewRWTOptionsWithOptions(opts...)
 	if config.DisableRetries {
 		ctx = context.WithValue(ctx, pool.CtxDisableRetries, true)
 	}
 
-	err := cds.writePool.BeginFunc(ctx, func(tx pgx.Tx) error {
+	priority, err := cds.writeTransactionPriority(ctx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	err = cds.writePool.BeginTxFunc(ctx, writeTxOptions(priority), func(tx pgx.Tx) error {
 		querier := pgxcommon.QuerierFuncsFor(tx)
 		executor := common.QueryRelationshipsExecutor{
 			Executor: pgxcommon.NewPGXQueryRelationshipsExecutor(querier),
@@ -409,14 +834,18 @@ func (cds *crdbDatastore) ReadWriteTx(
 			keyer:                cds.writeOverlapKeyer,
 			overlapKeySet:        cds.overlapKeyInit(ctx),
 			filterMaximumIDCount: cds.filterMaximumIDCount,
+			readPageSize:         cds.readPageSize,
 			withIntegrity:        cds.supportsIntegrity,
 			atSpecificRevision:   "", // No AS OF SYSTEM TIME for writes
 		}
 
 		rwt := &crdbReadWriteTXN{
-			reader,
-			tx,
-			0,
+			crdbReader:           reader,
+			tx:                   tx,
+			writeBatchSize:       cds.writeBatchSize,
+			duplicateWritePolicy: cds.duplicateWritePolicy,
+			validateCaveats:      cds.validateCaveatsOnWrite,
+			metadataColumns:      cds.metadataColumns,
 		}
 
 		if err := f(ctx, rwt); err != nil {
@@ -455,7 +884,13 @@ func wrapError(err error) error {
 }
 
 func (cds *crdbDatastore) ReadyState(ctx context.Context) (datastore.ReadyState, error) {
-	currentRevision, err := migrations.NewCRDBDriver(cds.dburl)
+	if err := cds.checkOpen(); err != nil {
+		return datastore.ReadyState{}, err
+	}
+
+	// ReadyState is polled, so an unavailable database is reported at once
+	// rather than after retrying the connection.
+	currentRevision, err := migrations.NewCRDBDriverContext(ctx, cds.dburl, migrations.WithConnectRetryTimeout(0), migrations.WithVersionTablePrefix(cds.migrationTablePrefix))
 	if err != nil {
 		return datastore.ReadyState{}, err
 	}
@@ -474,13 +909,16 @@ func (cds *crdbDatastore) ReadyState(ctx context.Context) (datastore.ReadyState,
 	if readMin > 0 {
 		readMin--
 	}
-	writeMin := cds.writePool.MinConns()
-	if writeMin > 0 {
-		writeMin--
-	}
-	writeTotal, err := safecast.ToUint32(cds.writePool.Stat().TotalConns())
-	if err != nil {
-		return datastore.ReadyState{}, spiceerrors.MustBugf("could not cast writeTotal to uint32: %v", err)
+	var writeMin, writeTotal uint32
+	if cds.writePool != nil {
+		writeMin = cds.writePool.MinConns()
+		if writeMin > 0 {
+			writeMin--
+		}
+		writeTotal, err = safecast.ToUint32(cds.writePool.Stat().TotalConns())
+		if err != nil {
+			return datastore.ReadyState{}, spiceerrors.MustBugf("could not cast writeTotal to uint32: %v", err)
+		}
 	}
 	readTotal, err := safecast.ToUint32(cds.readPool.Stat().TotalConns())
 	if err != nil {
@@ -501,22 +939,193 @@ func (cds *crdbDatastore) ReadyState(ctx context.Context) (datastore.ReadyState,
 	return datastore.ReadyState{IsReady: true}, nil
 }
 
+// Close cancels the datastore's background goroutines and waits, for at most
+// the configured close timeout, for them to exit before closing the pools.
 func (cds *crdbDatastore) Close() error {
+	cds.backgroundLock.Lock()
+	cds.closed.Store(true)
+	cds.backgroundLock.Unlock()
+
 	cds.cancel()
+	err := cds.waitForBackground()
 	cds.readPool.Close()
-	cds.writePool.Close()
-	return nil
+	if cds.writePool != nil {
+		cds.writePool.Close()
+	}
+	return err
+}
+
+// strongReadPool returns the pool for the reads that must observe the latest
+// writes, such as those with follower reads disabled: the write pool or, for
+// a datastore created by NewReadOnlyCRDBDatastore, which has none, the read
+// pool.
+func (cds *crdbDatastore) strongReadPool() *pool.RetryPool {
+	if cds.writePool == nil {
+		return cds.readPool
+	}
+	return cds.writePool
+}
+
+// goBackground runs f in a goroutine that Close waits for, returning false
+// without running it if the datastore has been closed. f must return once the
+// datastore's context is canceled.
+func (cds *crdbDatastore) goBackground(f func()) bool {
+	cds.backgroundLock.Lock()
+	defer cds.backgroundLock.Unlock()
+	if cds.closed.Load() {
+		return false
+	}
+
+	cds.background.Add(1)
+	go func() {
+		defer cds.background.Done()
+		f()
+	}()
+	return true
+}
+
+// waitForBackground waits for the background goroutines to exit, for at most
+// the close timeout.
+func (cds *crdbDatastore) waitForBackground() error {
+	done := make(chan struct{})
+	go func() {
+		cds.background.Wait()
+		if cds.pruneGroup != nil {
+			_ = cds.pruneGroup.Wait()
+		}
+		close(done)
+	}()
+
+	timer := time.NewTimer(cds.closeTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("timed out after %s waiting for background goroutines to exit", cds.closeTimeout)
+	}
 }
 
 func (cds *crdbDatastore) HeadRevision(ctx context.Context) (datastore.Revision, error) {
 	return cds.headRevisionInternal(ctx)
 }
 
+// RefreshLatestRevision replaces the cached optimized revision with the
+// current head revision, which is returned, rather than waiting for the end of
+// the quantization window. Reads made at the optimized revision afterwards
+// observe every write made before the call.
+func (cds *crdbDatastore) RefreshLatestRevision(ctx context.Context) (datastore.Revision, error) {
+	if err := cds.checkOpen(); err != nil {
+		return datastore.NoRevision, err
+	}
+	return cds.RemoteClockRevisions.RefreshOptimizedRevision(ctx)
+}
+
+// RevisionRange returns the oldest revision that can still be read, per
+// MinimumValidRevision, and the newest revision handed out by the datastore,
+// per OptimizedRevision. Revisions are derived from the cluster's clock, so the
+// range is available even before anything has been written.
+func (cds *crdbDatastore) RevisionRange(ctx context.Context) (oldest, newest datastore.Revision, err error) {
+	if err := cds.checkOpen(); err != nil {
+		return datastore.NoRevision, datastore.NoRevision, err
+	}
+
+	oldest, err = cds.MinimumValidRevision(ctx)
+	if err != nil {
+		return datastore.NoRevision, datastore.NoRevision, fmt.Errorf("unable to compute the oldest revision: %w", err)
+	}
+
+	newest, err = cds.OptimizedRevision(ctx)
+	if err != nil {
+		return datastore.NoRevision, datastore.NoRevision, fmt.Errorf("unable to compute the newest revision: %w", err)
+	}
+	return oldest, newest, nil
+}
+
+// AwaitRevision blocks until the revision, such as one returned by a write,
+// can be read from the datastore, or until the context is done. It allows
+// clients to read their writes without configuring FutureRevisionPolicy to
+// wait for every future revision. See RemoteClockRevisions.AwaitRevision.
+func (cds *crdbDatastore) AwaitRevision(ctx context.Context, rev datastore.Revision) error {
+	if err := cds.checkOpen(); err != nil {
+		return err
+	}
+	return cds.RemoteClockRevisions.AwaitRevision(ctx, rev)
+}
+
+// newCRDBSchema returns the information about the relationships table of the
+// configuration used to build the datastore's queries.
+func newCRDBSchema(config crdbOptions) *common.SchemaInformation {
+	relTableName := tableTuple
+	if config.withIntegrity {
+		relTableName = tableTupleWithIntegrity
+	}
+
+	return common.NewSchemaInformationWithOptions(
+		common.WithRelationshipTableName(relTableName),
+		common.WithColNamespace(colNamespace),
+		common.WithColObjectID(colObjectID),
+		common.WithColRelation(colRelation),
+		common.WithColUsersetNamespace(colUsersetNamespace),
+		common.WithColUsersetObjectID(colUsersetObjectID),
+		common.WithColUsersetRelation(colUsersetRelation),
+		common.WithColCaveatName(colCaveatContextName),
+		common.WithColCaveatContext(colCaveatContext),
+		common.WithColExpiration(colExpiration),
+		common.WithColIntegrityKeyID(colIntegrityKeyID),
+		common.WithColIntegrityHash(colIntegrityHash),
+		common.WithColIntegrityTimestamp(colTimestamp),
+		common.WithPaginationFilterType(common.ExpandedLogicComparison),
+		common.WithPlaceholderFormat(sq.Dollar),
+		common.WithNowFunction("NOW"),
+		common.WithColumnOptimization(config.columnOptimizationOption),
+		common.WithIntegrityEnabled(config.withIntegrity),
+		common.WithExpirationDisabled(config.expirationDisabled),
+	)
+}
+
+// withMinIdleConns returns the RetryPool options with the addition of the
+// minimum number of idle connections of the pool options, if one is set.
+func withMinIdleConns(retryPoolOpts []pool.RetryPoolOption, poolOpts pgxcommon.PoolOptions) ([]pool.RetryPoolOption, error) {
+	if poolOpts.MinIdleConns == nil {
+		return retryPoolOpts, nil
+	}
+
+	minIdleConns, err := safecast.ToInt32(*poolOpts.MinIdleConns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection min idle: %w", err)
+	}
+	return append(slices.Clip(retryPoolOpts), pool.WithMinIdleConns(minIdleConns)), nil
+}
+
+// operationalLogger returns the logger configured with WithLogger or, if none
+// was, the given default.
+func (cds *crdbDatastore) operationalLogger(defaultLogger *zerolog.Logger) *zerolog.Logger {
+	if cds.logger != nil {
+		return cds.logger
+	}
+	return defaultLogger
+}
+
+// checkOpen returns datastore.ErrDatastoreClosed if the datastore has been
+// closed.
+func (cds *crdbDatastore) checkOpen() error {
+	if cds.closed.Load() {
+		return datastore.ErrDatastoreClosed
+	}
+	return nil
+}
+
 func (cds *crdbDatastore) headRevisionInternal(ctx context.Context) (datastore.Revision, error) {
+	if err := cds.checkOpen(); err != nil {
+		return datastore.NoRevision, err
+	}
+
 	var hlcNow datastore.Revision
 
 	var fnErr error
-	hlcNow, fnErr = readCRDBNow(ctx, cds.readPool)
+	hlcNow, fnErr = readCRDBNow(ctx, cds.readQuerier())
 	if fnErr != nil {
 		return datastore.NoRevision, fmt.Errorf(errRevision, fnErr)
 	}
@@ -524,6 +1133,26 @@ func (cds *crdbDatastore) headRevisionInternal(ctx context.Context) (datastore.R
 	return hlcNow, fnErr
 }
 
+// EffectiveIsolation returns the isolation level, e.g. `serializable`, that
+// CockroachDB uses for the datastore's read-write transactions, as reported
+// within a fresh transaction on the write pool, or on the read pool of a
+// datastore created by NewReadOnlyCRDBDatastore. The datastore relies on
+// serializable isolation, so any other level, such as a `read committed`
+// default enabled cluster-wide, indicates the cause of consistency anomalies.
+func (cds *crdbDatastore) EffectiveIsolation(ctx context.Context) (string, error) {
+	if err := cds.checkOpen(); err != nil {
+		return "", err
+	}
+
+	var isolation string
+	if err := cds.strongReadPool().BeginFunc(ctx, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, queryShowIsolation).Scan(&isolation)
+	}); err != nil {
+		return "", fmt.Errorf("unable to read transaction isolation: %w", err)
+	}
+	return isolation, nil
+}
+
 func (cds *crdbDatastore) OfflineFeatures() (*datastore.Features, error) {
 	if cds.supportsIntegrity {
 		return &datastore.Features{
@@ -553,6 +1182,10 @@ func (cds *crdbDatastore) OfflineFeatures() (*datastore.Features, error) {
 }
 
 func (cds *crdbDatastore) Features(ctx context.Context) (*datastore.Features, error) {
+	if err := cds.checkOpen(); err != nil {
+		return nil, err
+	}
+
 	features, _, err := cds.featureGroup.Do(ctx, "", func(ictx context.Context) (*datastore.Features, error) {
 		return cds.features(ictx)
 	})
@@ -580,17 +1213,14 @@ func (cds *crdbDatastore) features(ctx context.Context) (*datastore.Features, er
 	// Start a changefeed with an invalid value. If we get back an invalid value error (SQLSTATE 22023)
 	// then we know that the datastore supports watch. If we get back any other error, then we know that
 	// the datastore does not support watch emits or there is a permissions issue.
-	_ = cds.writePool.ExecFunc(ctx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
+	_ = cds.strongReadPool().ExecFunc(ctx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
 		if err == nil {
 			return spiceerrors.MustBugf("expected an error, but got none")
 		}
 
-		var pgerr *pgconn.PgError
-		if errors.As(err, &pgerr) {
-			if pgerr.Code == "22023" {
-				features.Watch.Status = datastore.FeatureSupported
-				return nil
-			}
+		if pool.IsInvalidParameterValue(err) {
+			features.Watch.Status = datastore.FeatureSupported
+			return nil
 		}
 
 		features.Watch.Status = datastore.FeatureUnsupported
@@ -615,6 +1245,52 @@ func (cds *crdbDatastore) readTransactionCommitRev(ctx context.Context, reader p
 	return revisions.NewForHLC(hlcNow)
 }
 
+// resetAfterReleaseTimeout bounds the time spent resetting a connection that
+// is being returned to its pool.
+const resetAfterReleaseTimeout = 5 * time.Second
+
+// resetAfterRelease returns a function that runs the reset statement on a
+// connection being returned to its pool, causing the pool to destroy the
+// connection if the statement fails.
+func resetAfterRelease(sql string) func(*pgx.Conn) bool {
+	return func(conn *pgx.Conn) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), resetAfterReleaseTimeout)
+		defer cancel()
+
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			log.Debug().Err(err).Msg("discarding connection that failed to reset after release")
+			return false
+		}
+		return true
+	}
+}
+
+// keepaliveDialFunc returns a dial function whose connections send TCP
+// keepalive probes once idle for the interval, and then every interval, until
+// count probes have gone unanswered.
+func keepaliveDialFunc(connectTimeout, interval time.Duration, count int) pgconn.DialFunc {
+	dialer := &net.Dialer{
+		Timeout: connectTimeout,
+		KeepAliveConfig: net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     interval,
+			Interval: interval,
+			Count:    count,
+		},
+	}
+	return dialer.DialContext
+}
+
+// recordAdvertisedRevision returns a revision observer that sets the gauge to
+// the timestamp of each advertised revision.
+func recordAdvertisedRevision(gauge pool.Gauge) func(datastore.Revision) {
+	return func(rev datastore.Revision) {
+		if withTimestamp, ok := rev.(revisions.WithTimestampRevision); ok {
+			gauge.Set(float64(withTimestamp.TimestampNanoSec()) / float64(time.Second))
+		}
+	}
+}
+
 func readCRDBNow(ctx context.Context, reader pgxcommon.DBFuncQuerier) (datastore.Revision, error) {
 	ctx, span := tracer.Start(ctx, "readCRDBNow")
 	defer span.End()
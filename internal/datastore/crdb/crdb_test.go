@@ -4,6 +4,7 @@
 package crdb
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -12,12 +13,14 @@ import (
 	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,9 +28,14 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ory/dockertest/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	crdbmigrations "github.com/authzed/spicedb/internal/datastore/crdb/migrations"
 	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
 	"github.com/authzed/spicedb/internal/datastore/proxy"
@@ -35,10 +43,13 @@ import (
 	"github.com/authzed/spicedb/internal/testfixtures"
 	testdatastore "github.com/authzed/spicedb/internal/testserver/datastore"
 	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
 	"github.com/authzed/spicedb/pkg/datastore/test"
 	"github.com/authzed/spicedb/pkg/genutil/mapz"
 	"github.com/authzed/spicedb/pkg/migrate"
+	ns "github.com/authzed/spicedb/pkg/namespace"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/testutil"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
@@ -82,73 +93,2091 @@ func TestCRDBDatastoreWithoutIntegrity(t *testing.T) {
 		RevisionQuantization(0),
 		GCWindow(veryLargeGCWindow),
 	))
+
+	t.Run("TestWatchByResourceType", createDatastoreTest(
+		b,
+		ResourceTypeWatchTest,
+		RevisionQuantization(0),
+		GCWindow(veryLargeGCWindow),
+		WatchBufferLength(1),
+		WatchBufferLengthForResourceType("resource", 2),
+	))
+
+	t.Run("TestReadOnlyReadPool", createDatastoreTest(
+		b,
+		ReadOnlyReadPoolTest,
+	))
+
+	t.Run("TestWriteBatchSize", createDatastoreTest(
+		b,
+		LargeRelationshipWriteTest,
+		WriteBatchSize(2),
+	))
+
+	t.Run("TestTransactionSplitting", createDatastoreTest(
+		b,
+		LargeRelationshipWriteTest,
+		AllowTransactionSplitting(2),
+	))
+
+	t.Run("TestConnectionLabel", createDatastoreTest(
+		b,
+		ConnectionLabelTest,
+		WithConnectionLabel("spicedb-test"),
+	))
+
+	t.Run("TestResetQueryOnRelease", createDatastoreTest(
+		b,
+		ResetQueryOnReleaseTest,
+		ResetQueryOnRelease("RESET application_name"),
+		ReadConnsMaxOpen(1),
+		WriteConnsMaxOpen(1),
+	))
+
+	t.Run("TestDeadlineStatementTimeouts", createDatastoreTest(
+		b,
+		DeadlineStatementTimeoutsTest,
+		WithDeadlineStatementTimeouts(true),
+		ReadConnsMaxOpen(1),
+		WriteConnsMaxOpen(1),
+	))
+}
+
+type datastoreTestFunc func(t *testing.T, ds datastore.Datastore)
+
+func createDatastoreTest(b testdatastore.RunningEngineForTest, tf datastoreTestFunc, options ...Option) func(*testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+		ds := b.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+			ds, err := NewCRDBDatastore(ctx, uri, options...)
+			require.NoError(t, err)
+			return ds
+		})
+		defer ds.Close()
+
+		tf(t, ds)
+	}
+}
+
+func TestCRDBDatastoreWithFollowerReads(t *testing.T) {
+	t.Parallel()
+	followerReadDelay := time.Duration(4.8 * float64(time.Second))
+	gcWindow := 100 * time.Second
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+
+	quantizationDurations := []time.Duration{
+		0 * time.Second,
+		100 * time.Millisecond,
+	}
+	for _, quantization := range quantizationDurations {
+		t.Run(fmt.Sprintf("Quantization%s", quantization), func(t *testing.T) {
+			require := require.New(t)
+			ctx := context.Background()
+
+			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				ds, err := NewCRDBDatastore(
+					ctx,
+					uri,
+					GCWindow(gcWindow),
+					RevisionQuantization(quantization),
+					FollowerReadDelay(followerReadDelay),
+					DebugAnalyzeBeforeStatistics(),
+				)
+				require.NoError(err)
+				return ds
+			})
+			defer ds.Close()
+
+			r, err := ds.ReadyState(ctx)
+			require.NoError(err)
+			require.True(r.IsReady)
+
+			// Revisions should be at least the follower read delay amount in the past
+			for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+				testRevision, err := ds.OptimizedRevision(ctx)
+				require.NoError(err)
+
+				nowRevision, err := ds.HeadRevision(ctx)
+				require.NoError(err)
+
+				diff := nowRevision.(revisions.HLCRevision).TimestampNanoSec() - testRevision.(revisions.HLCRevision).TimestampNanoSec()
+				require.True(diff > followerReadDelay.Nanoseconds())
+			}
+		})
+	}
+}
+
+func TestCRDBDatastoreClosed(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+
+	rev, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ds.Close())
+
+	_, _, err = ds.SnapshotReader(rev).ReadNamespaceByName(ctx, "resource")
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+
+	iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		OptionalResourceType: "resource",
+	})
+	require.NoError(t, err)
+	_, err = datastore.IteratorToSlice(iter)
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+
+	_, err = ds.HeadRevision(ctx)
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return nil
+	})
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+}
+
+// TestCRDBDatastoreCloseStopsBackground is not parallel, so that the
+// goroutines of other tests are not mistaken for leaks.
+func TestCRDBDatastoreConnectionCallbacks(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var lock sync.Mutex
+	var connected, disconnected []pool.ConnectionEvent
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri,
+			OnConnect(func(event pool.ConnectionEvent) {
+				lock.Lock()
+				defer lock.Unlock()
+				connected = append(connected, event)
+			}),
+			OnDisconnect(func(event pool.ConnectionEvent) {
+				lock.Lock()
+				defer lock.Unlock()
+				disconnected = append(disconnected, event)
+			}),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+
+	_, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	lock.Lock()
+	require.NotEmpty(t, connected)
+	for _, event := range connected {
+		require.Contains(t, []string{"read", "write"}, event.Pool)
+		require.NotEmpty(t, event.Addr)
+		require.NoError(t, event.Err)
+	}
+	connectedCount := len(connected)
+	lock.Unlock()
+
+	require.NoError(t, ds.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, disconnected, connectedCount)
+}
+
+func TestCRDBDatastoreCloseStopsBackground(t *testing.T) {
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var existing goleak.Option
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		existing = goleak.IgnoreCurrent()
+		ds, err := NewCRDBDatastore(ctx, uri,
+			RevisionQuantization(100*time.Millisecond),
+			RevisionHeartbeatInterval(100*time.Millisecond),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+
+	rev, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	changes, errs := ds.Watch(ctx, rev, datastore.WatchJustRelationships())
+	require.NoError(t, ds.Close())
+
+	// Closing the datastore ends the watch.
+	for range changes {
+	}
+	require.ErrorIs(t, <-errs, datastore.ErrDatastoreClosed)
+
+	goleak.VerifyNone(t, append(testutil.GoLeakIgnores(), existing)...)
+}
+
+func TestCRDBDatastoreMaxConcurrentWatches(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, MaxConcurrentWatches(1))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	rev, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	changes, errs := ds.Watch(watchCtx, rev, datastore.WatchJustRelationships())
+
+	// A second watch is rejected while the first is running.
+	rejected, rejectedErrs := ds.Watch(ctx, rev, datastore.WatchJustRelationships())
+	_, ok := <-rejected
+	require.False(t, ok)
+	var tooMany datastore.TooManyWatchesError
+	require.ErrorAs(t, <-rejectedErrs, &tooMany)
+	require.Equal(t, 1, tooMany.Limit())
+
+	// Once the first watch ends, a new one can be started.
+	cancel()
+	for range changes {
+	}
+	<-errs
+
+	require.Eventually(t, func() bool {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		changes, errs := ds.Watch(watchCtx, rev, datastore.WatchJustRelationships())
+		select {
+		case err := <-errs:
+			for range changes {
+			}
+			return !errors.As(err, &datastore.TooManyWatchesError{})
+		case <-time.After(100 * time.Millisecond):
+			return true
+		}
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestCRDBDatastoreReadOnlyMode(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	rel := tuple.MustParse("resource:foo#viewer@user:tom")
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	crdbDS.SetReadOnly(true)
+
+	// Reads are served while in read-only mode.
+	rev, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+	iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		OptionalResourceType: "resource",
+	})
+	require.NoError(t, err)
+	found, err := datastore.IteratorToSlice(iter)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	// Writes are rejected.
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:sarah"))
+	require.ErrorIs(t, err, ErrReadOnlyMode)
+	require.ErrorAs(t, err, &datastore.ReadOnlyError{})
+
+	crdbDS.SetReadOnly(false)
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:sarah"))
+	require.NoError(t, err)
+}
+
+func TestReadOnlyCRDBDatastore(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var dsURI string
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		dsURI = uri
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	rel := tuple.MustParse("resource:foo#viewer@user:tom")
+	rev, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+	require.NoError(t, err)
+
+	replica, err := NewReadOnlyCRDBDatastore(ctx, dsURI, GCWindow(100*time.Second))
+	require.NoError(t, err)
+	defer replica.Close()
+
+	// Reads of the replica observe the writes of the read-write datastore.
+	iter, err := replica.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		OptionalResourceType: "resource",
+	})
+	require.NoError(t, err)
+	found, err := datastore.IteratorToSlice(iter)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	crdbReplica := datastore.UnwrapAs[*crdbDatastore](replica)
+	require.Nil(t, crdbReplica.writePool)
+
+	// The instance ID, like every other write, cannot be created.
+	_, err = crdbReplica.createInstanceID(ctx)
+	require.ErrorIs(t, err, ErrReadOnlyMode)
+
+	// Writes are rejected, even once read-only mode is disabled.
+	_, err = common.WriteRelationships(ctx, replica, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:sarah"))
+	require.ErrorIs(t, err, ErrReadOnlyMode)
+	crdbReplica.SetReadOnly(false)
+	_, err = common.WriteRelationships(ctx, replica, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:sarah"))
+	require.ErrorIs(t, err, ErrReadOnlyMode)
+
+	_, err = crdbReplica.RunGC(ctx)
+	require.ErrorIs(t, err, ErrReadOnlyMode)
+}
+
+func TestCRDBDatastoreCheckIndexHint(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, CheckIndexHint("ix_relation_tuple_by_subject"))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	rel := tuple.MustParse("resource:foo#viewer@user:tom")
+	rev, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+	require.NoError(t, err)
+
+	query := func(ctx context.Context) (string, []tuple.Relationship) {
+		var sql string
+		iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+			OptionalResourceType: "resource",
+		}, options.WithSQLAssertion(func(s string) { sql = s }))
+		require.NoError(t, err)
+		found, err := datastore.IteratorToSlice(iter)
+		require.NoError(t, err)
+		return sql, found
+	}
+
+	// Only the queries of checks are given the hint.
+	sql, found := query(ctx)
+	require.NotContains(t, sql, "@ix_relation_tuple_by_subject")
+	require.Len(t, found, 1)
+
+	sql, found = query(datastore.WithStatementLabel(ctx, datastore.StatementLabelCheck))
+	require.Contains(t, sql, "@ix_relation_tuple_by_subject")
+	require.Len(t, found, 1)
+}
+
+func TestCRDBDatastoreTableSizes(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+
+	rels := make([]tuple.Relationship, 0, 10)
+	for i := range 10 {
+		rels = append(rels, tuple.MustParse(fmt.Sprintf("resource:foo%d#viewer@user:tom", i)))
+	}
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rels...)
+	require.NoError(t, err)
+
+	require.NoError(t, crdbDS.writePool.ExecFunc(ctx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
+		return err
+	}, "ANALYZE "+crdbDS.schema.RelationshipTableName))
+
+	sizes, err := crdbDS.TableSizes(ctx)
+	require.NoError(t, err)
+	require.Contains(t, sizes, tableNamespace)
+	require.Contains(t, sizes, tableTransactions)
+	require.Equal(t, int64(10), sizes[crdbDS.schema.RelationshipTableName])
+}
+
+func TestCRDBDatastoreWithLogger(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(zerolog.SyncWriter(&buf))
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, WithLogger(logger))
+		require.NoError(t, err)
+		return ds
+	})
+	require.NoError(t, ds.Close())
+
+	require.Contains(t, buf.String(), "starting cockroach connection balancer")
+}
+
+func TestCRDBDatastoreInstanceID(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var uri string
+	ds := engine.NewDatastore(t, func(engine, dbURI string) datastore.Datastore {
+		uri = dbURI
+		ds, err := NewCRDBDatastore(ctx, dbURI)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	instanceID, err := crdbDS.InstanceID(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, instanceID)
+
+	stats, err := ds.Statistics(ctx)
+	require.NoError(t, err)
+	require.Equal(t, instanceID, stats.UniqueID)
+
+	// Once the ID is missing, processes starting concurrently all create and
+	// return the same one.
+	require.NoError(t, crdbDS.writePool.ExecFunc(ctx, func(ctx context.Context, _ pgconn.CommandTag, err error) error {
+		return err
+	}, "DELETE FROM "+tableMetadata))
+
+	ids := make([]string, 4)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other, err := NewCRDBDatastore(ctx, uri)
+			require.NoError(t, err)
+			defer other.Close()
+
+			ids[i], err = datastore.UnwrapAs[*crdbDatastore](other).InstanceID(ctx)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.NotEqual(t, instanceID, ids[0])
+	for _, id := range ids {
+		require.Equal(t, ids[0], id)
+	}
+
+	var count int
+	require.NoError(t, crdbDS.readPool.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+		return row.Scan(&count)
+	}, "SELECT count(*) FROM "+tableMetadata))
+	require.Equal(t, 1, count)
+
+	// The ID is cached once read.
+	cached, err := crdbDS.InstanceID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, instanceID, cached)
+}
+
+func TestCRDBDatastoreReadinessStatus(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+
+	status, err := crdbDS.ReadinessStatus(ctx)
+	require.NoError(t, err)
+	require.Equal(t, StatusServing, status)
+
+	require.NoError(t, ds.Close())
+	status, err = crdbDS.ReadinessStatus(ctx)
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+	require.Equal(t, StatusNotServing, status)
+}
+
+func TestCRDBDatastoreMigrationTablePrefix(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	uri := engine.NewDatabase(t)
+	driver, err := crdbmigrations.NewCRDBDriver(uri, crdbmigrations.WithVersionTablePrefix("tenant1_"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = driver.Close(context.Background())
+	})
+	require.NoError(t, crdbmigrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+
+	// A datastore without the prefix reads the unprefixed version table, which
+	// the initial migration left at the empty version.
+	ds, err := NewCRDBDatastore(ctx, uri, ValidateSchemaOnOpen())
+	require.ErrorContains(t, err, "unable to validate datastore schema")
+	require.Nil(t, ds)
+
+	ds, err = NewCRDBDatastore(ctx, uri, MigrationTablePrefix("tenant1_"), ValidateSchemaOnOpen(), AllowDestructiveOperations())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ds.Close()
+	})
+
+	require.Eventually(t, func() bool {
+		state, err := ds.ReadyState(ctx)
+		require.NoError(t, err)
+		return state.IsReady
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// Truncating the datastore keeps the prefixed version table.
+	require.NoError(t, datastore.UnwrapAs[*crdbDatastore](ds).TruncateAllData(ctx))
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	head, err := crdbmigrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+}
+
+func TestCRDBDatastoreHealthLog(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(zerolog.SyncWriter(&buf))
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, WithLogger(logger), HealthLogInterval(20*time.Millisecond))
+		require.NoError(t, err)
+		return ds
+	})
+
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("resource:foo#viewer@user:tom[expiration:2020-01-01T00:00:00Z]"))
+	require.NoError(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Close stops the health log, after which the buffer is no longer written.
+	require.NoError(t, ds.Close())
+	require.Contains(t, buf.String(), `"message":"datastore health"`)
+	require.Contains(t, buf.String(), `"gcBacklog":1`)
+}
+
+func TestCRDBDatastorePoolStats(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri,
+			ReadConnsMinOpen(1),
+			WriteConnsMinOpen(1),
+			WithEnableConnectionBalancing(true),
+			NodeHealthCheckInterval(50*time.Millisecond),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	require.Eventually(t, func() bool {
+		stats := crdbDS.PoolStats()
+		return stats.Read.Open >= 1 && stats.Write.Open >= 1 && !stats.LastNodeHealthCheck.IsZero()
+	}, 5*time.Second, 25*time.Millisecond)
+
+	stats := crdbDS.PoolStats()
+	require.LessOrEqual(t, stats.Read.Idle+stats.Read.Acquired, stats.Read.Open)
+	require.LessOrEqual(t, stats.Write.Idle+stats.Write.Acquired, stats.Write.Open)
+	require.WithinDuration(t, time.Now(), stats.LastNodeHealthCheck, 5*time.Second)
+}
+
+func TestCRDBDatastoreActiveWatches(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	watches, err := crdbDS.ActiveWatches(ctx)
+	require.NoError(t, err)
+	require.Empty(t, watches)
+
+	rev, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	changes, errs := ds.Watch(watchCtx, rev, datastore.WatchJustRelationships())
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:tom"))
+	require.NoError(t, err)
+
+	// The change is left unread in the buffer of the watch.
+	require.Eventually(t, func() bool {
+		watches, err := crdbDS.ActiveWatches(ctx)
+		require.NoError(t, err)
+		return len(watches) == 1 && watches[0].EventsDelivered == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	watches, err = crdbDS.ActiveWatches(ctx)
+	require.NoError(t, err)
+	require.Equal(t, rev, watches[0].StartRevision)
+	require.Equal(t, 1, watches[0].BufferedEvents)
+	require.Equal(t, int(defaultWatchBufferLength), watches[0].BufferCapacity)
+	require.Positive(t, watches[0].Age)
+
+	cancel()
+	for range changes {
+	}
+	<-errs
+
+	require.Eventually(t, func() bool {
+		watches, err := crdbDS.ActiveWatches(ctx)
+		require.NoError(t, err)
+		return len(watches) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestCRDBDatastoreWatchCompression(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, WatchCompressionThreshold(1024))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	rev, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes, errs := ds.Watch(watchCtx, rev, datastore.WatchJustRelationships())
+
+	// The change is delivered with the context that was compressed while
+	// buffered.
+	change := largeContextChange(t, 1, 1000)
+	rel := change.RelationshipChanges[0].Relationship
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+	require.NoError(t, err)
+
+	select {
+	case received := <-changes:
+		require.Len(t, received.RelationshipChanges, 1)
+		require.True(t, proto.Equal(rel.OptionalCaveat.Context, received.RelationshipChanges[0].Relationship.OptionalCaveat.Context))
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for the change")
+	}
+}
+
+func TestCRDBDatastoreSchemaHash(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	writeNamespaces := func(names ...string) string {
+		_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			for _, name := range names {
+				if err := rwt.WriteNamespaces(ctx, &core.NamespaceDefinition{Name: name}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		hash, err := crdbDS.SchemaHash(ctx)
+		require.NoError(t, err)
+		return hash
+	}
+
+	empty, err := crdbDS.SchemaHash(ctx)
+	require.NoError(t, err)
+
+	hash := writeNamespaces("document", "user")
+	require.NotEqual(t, empty, hash)
+
+	// Rewriting the same definitions, in another order, keeps the hash.
+	require.Equal(t, hash, writeNamespaces("user", "document"))
+	require.NotEqual(t, hash, writeNamespaces("folder"))
+}
+
+func TestCRDBDatastoreTruncateAllData(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	for _, allowed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("allowed=%t", allowed), func(t *testing.T) {
+			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				var opts []Option
+				if allowed {
+					opts = append(opts, AllowDestructiveOperations())
+				}
+				ds, err := NewCRDBDatastore(ctx, uri, opts...)
+				require.NoError(t, err)
+				return ds
+			})
+			defer ds.Close()
+
+			crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+			rel := tuple.MustParse("resource:foo#viewer@user:tom")
+			_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+			require.NoError(t, err)
+
+			stats, err := ds.Statistics(ctx)
+			require.NoError(t, err)
+
+			err = crdbDS.TruncateAllData(ctx)
+			if !allowed {
+				require.ErrorIs(t, err, ErrDestructiveOperationsDisabled)
+				return
+			}
+			require.NoError(t, err)
+
+			// The datastore remains migrated, with the same unique ID, but empty.
+			headRev, err := ds.HeadRevision(ctx)
+			require.NoError(t, err)
+			it, err := ds.SnapshotReader(headRev).QueryRelationships(ctx, datastore.RelationshipsFilter{OptionalResourceType: "resource"})
+			require.NoError(t, err)
+			for _, err := range it {
+				require.NoError(t, err)
+				require.Fail(t, "expected no relationships")
+			}
+
+			afterStats, err := ds.Statistics(ctx)
+			require.NoError(t, err)
+			require.Equal(t, stats.UniqueID, afterStats.UniqueID)
+
+			_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCRDBDatastoreWriteTransactionPriority(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		// The statement timeout set at the start of each transaction must not
+		// prevent its priority from being set.
+		ds, err := NewCRDBDatastore(ctx, uri, WriteTransactionPriority(TransactionPriorityLow), WithDeadlineStatementTimeouts(true))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	for _, tc := range []struct {
+		ctx      context.Context
+		expected string
+	}{
+		{ctx, "low"},
+		{WithWriteTransactionPriority(ctx, TransactionPriorityHigh), "high"},
+		{WithWriteTransactionPriority(ctx, TransactionPriorityNormal), "normal"},
+	} {
+		_, err := ds.ReadWriteTx(tc.ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			var priority string
+			if err := rwt.(*crdbReadWriteTXN).tx.QueryRow(ctx, "SHOW TRANSACTION PRIORITY").Scan(&priority); err != nil {
+				return err
+			}
+			require.Equal(t, tc.expected, priority)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestCRDBDatastoreRunGC(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("resource:foo#viewer@user:tom[expiration:2020-01-01T00:00:00Z]"),
+		tuple.MustParse("resource:foo#viewer@user:sarah[expiration:2020-01-01T00:00:00Z]"),
+		tuple.MustParse("resource:foo#viewer@user:fred"),
+	)
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	deleted, err := crdbDS.RunGC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+
+	// A second pass finds nothing left to delete.
+	deleted, err = crdbDS.RunGC(ctx)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+}
+
+func TestCRDBDatastoreValidateCaveatsOnWrite(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, ValidateCaveatsOnWrite(true))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteCaveats(ctx, []*core.CaveatDefinition{{
+			Name:           "somecaveat",
+			ParameterTypes: map[string]*core.CaveatTypeReference{"ip": {TypeName: "ipaddress"}},
+		}})
+	})
+	require.NoError(t, err)
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc1#viewer@user:tom[somecaveat:{\"ip\":\"10.0.0.1\"}]"),
+		tuple.MustParse("document:doc1#viewer@user:sarah[somecaveat]"),
+	)
+	require.NoError(t, err)
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationTouch,
+		tuple.MustParse("document:doc2#viewer@user:tom[undefined]"))
+	require.ErrorAs(t, err, &datastore.CaveatNameNotFoundError{})
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc2#viewer@user:tom[somecaveat:{\"user\":\"tom\"}]"))
+	var mismatch datastore.CaveatContextMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	require.Equal(t, "user", mismatch.Parameter())
+
+	// Deleting a relationship of an undefined caveat is not checked.
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationDelete,
+		tuple.MustParse("document:doc2#viewer@user:tom[undefined]"))
+	require.NoError(t, err)
+}
+
+func TestCRDBDatastoreDanglingRelationships(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	for _, gcEnabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("gc=%t", gcEnabled), func(t *testing.T) {
+			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				ds, err := NewCRDBDatastore(ctx, uri, GCDanglingRelationships(gcEnabled))
+				require.NoError(t, err)
+				return ds
+			})
+			defer ds.Close()
+
+			_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+				return rwt.WriteNamespaces(ctx, &core.NamespaceDefinition{Name: "document"}, &core.NamespaceDefinition{Name: "user"})
+			})
+			require.NoError(t, err)
+
+			_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+				tuple.MustParse("document:doc1#viewer@user:tom"),
+				tuple.MustParse("folder:f1#viewer@user:tom"),
+				tuple.MustParse("document:doc1#viewer@team:eng#member"),
+			)
+			require.NoError(t, err)
+
+			crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+			dangling, err := crdbDS.FindDanglingRelationships(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []tuple.Relationship{
+				tuple.MustParse("document:doc1#viewer@team:eng#member"),
+				tuple.MustParse("folder:f1#viewer@user:tom"),
+			}, dangling)
+
+			deleted, err := crdbDS.RunGC(ctx)
+			require.NoError(t, err)
+
+			dangling, err = crdbDS.FindDanglingRelationships(ctx)
+			require.NoError(t, err)
+			if gcEnabled {
+				require.Equal(t, int64(2), deleted)
+				require.Empty(t, dangling)
+			} else {
+				require.Zero(t, deleted)
+				require.Len(t, dangling, 2)
+			}
+		})
+	}
+}
+
+func TestCRDBDatastoreCheckReferentialIntegrity(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri,
+			ReadPageSize(2),
+			RevisionQuantization(time.Millisecond),
+			FollowerReadDelay(time.Millisecond),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(ctx,
+			ns.Namespace("user"),
+			ns.Namespace("team", ns.MustRelation("member", nil)),
+			ns.Namespace("document",
+				ns.MustRelation("viewer", nil),
+				ns.MustRelation("view", ns.Union(ns.ComputedUserset("viewer"))),
+			),
+		)
+	})
+	require.NoError(t, err)
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc1#viewer@user:tom"),
+		tuple.MustParse("document:doc1#viewer@team:eng#member"),
+		tuple.MustParse("document:doc1#view@user:tom"),
+		tuple.MustParse("document:doc1#editor@user:tom"),
+		tuple.MustParse("document:doc1#viewer@team:eng#admin"),
+		tuple.MustParse("document:doc2#viewer@group:g1"),
+		tuple.MustParse("folder:f1#viewer@user:tom"),
+	)
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+
+	var report IntegrityReport
+	require.Eventually(t, func() bool {
+		report, err = crdbDS.CheckReferentialIntegrity(ctx)
+		require.NoError(t, err)
+		return report.Checked == 7
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, uint64(5), report.ViolationCount)
+	require.False(t, report.Truncated())
+
+	reasons := make(map[string]string, len(report.Violations))
+	for _, violation := range report.Violations {
+		reasons[tuple.MustString(violation.Relationship)] = violation.Reason
+	}
+	require.Equal(t, map[string]string{
+		"document:doc1#view@user:tom":         "view of resource type document is a permission, not a relation",
+		"document:doc1#editor@user:tom":       "relation editor is not defined on resource type document",
+		"document:doc1#viewer@team:eng#admin": "relation admin is not defined on subject type team",
+		"document:doc2#viewer@group:g1":       "subject type group is not defined",
+		"folder:f1#viewer@user:tom":           "resource type folder is not defined",
+	}, reasons)
+}
+
+func TestCRDBDatastoreCheckReferentialIntegrityMaxListResults(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri,
+			ReadPageSize(10),
+			MaxListResults(2),
+			RevisionQuantization(time.Millisecond),
+			FollowerReadDelay(time.Millisecond),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(ctx,
+			ns.Namespace("user"),
+			ns.Namespace("document", ns.MustRelation("viewer", nil)),
+		)
+	})
+	require.NoError(t, err)
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc1#viewer@user:tom"),
+		tuple.MustParse("document:doc2#viewer@user:tom"),
+		tuple.MustParse("document:doc3#editor@user:tom"),
+		tuple.MustParse("document:doc4#viewer@user:tom"),
+		tuple.MustParse("document:doc5#viewer@user:tom"),
+	)
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+
+	// The scan reads pages capped by MaxListResults rather than aborting on
+	// a truncated one.
+	var report IntegrityReport
+	require.Eventually(t, func() bool {
+		report, err = crdbDS.CheckReferentialIntegrity(ctx)
+		require.NoError(t, err)
+		return report.Checked == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, uint64(1), report.ViolationCount)
+	require.Equal(t, "document:doc3#editor@user:tom", tuple.MustString(report.Violations[0].Relationship))
+}
+
+func TestCRDBDatastoreChangeSink(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	// The sink receives every change, even when other watches drop the oldest
+	// changes of their full buffers.
+	for _, policy := range []string{WatchBufferOverflowBlock, WatchBufferOverflowDropOldest} {
+		t.Run(policy, func(t *testing.T) {
+			sink := &slowSink{delay: 50 * time.Millisecond}
+			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				ds, err := NewCRDBDatastore(ctx, uri, WithChangeSink(sink), ChangeSinkBufferLength(1), WatchBufferOverflowPolicy(policy))
+				require.NoError(t, err)
+				return ds
+			})
+			defer ds.Close()
+
+			var written []datastore.Revision
+			for i := range 5 {
+				revision, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse(fmt.Sprintf("document:doc%d#viewer@user:tom", i)))
+				require.NoError(t, err)
+				written = append(written, revision)
+			}
+
+			require.Eventually(t, func() bool {
+				return len(sink.publishedRevisions()) >= len(written)
+			}, 10*time.Second, 10*time.Millisecond)
+
+			published := sink.publishedRevisions()
+			for i, revision := range written {
+				require.True(t, revision.Equal(published[i]), "expected revision %s, published %s", revision, published[i])
+			}
+		})
+	}
+}
+
+func TestCRDBDatastoreRunGCCaveats(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				ds, err := NewCRDBDatastore(ctx, uri, GCWindow(2*time.Second), RevisionQuantization(100*time.Millisecond), GCCaveats(enabled))
+				require.NoError(t, err)
+				return ds
+			})
+			defer ds.Close()
+
+			_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+				if err := rwt.WriteCaveats(ctx, []*core.CaveatDefinition{{Name: "used"}, {Name: "unused"}, {Name: "recent"}, {Name: "declared"}}); err != nil {
+					return err
+				}
+
+				// The schema names a caveat that no relationship uses yet.
+				return rwt.WriteNamespaces(ctx, ns.Namespace("document",
+					ns.MustRelation("viewer", nil, ns.AllowedRelationWithCaveat("user", "...", ns.AllowedCaveat("declared"))),
+				))
+			})
+			require.NoError(t, err)
+
+			recent := tuple.MustParse("resource:foo#viewer@user:fred[recent]")
+			_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+				tuple.MustParse("resource:foo#viewer@user:tom[used]"),
+				recent,
+			)
+			require.NoError(t, err)
+
+			crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+			runGC := func() []string {
+				_, err := crdbDS.RunGC(ctx)
+				require.NoError(t, err)
+
+				headRev, err := ds.HeadRevision(ctx)
+				require.NoError(t, err)
+				caveats, err := ds.SnapshotReader(headRev).ListAllCaveats(ctx)
+				require.NoError(t, err)
+
+				names := make([]string, 0, len(caveats))
+				for _, caveat := range caveats {
+					names = append(names, caveat.Definition.Name)
+				}
+				return names
+			}
+
+			// The first pass finds the unused caveat unreferenced, but it has
+			// not been so for longer than the GC window yet.
+			require.ElementsMatch(t, []string{"used", "unused", "recent", "declared"}, runGC())
+
+			// Once the window has passed, the unused caveat is collected, but
+			// not the one that was just unreferenced, nor the one the schema
+			// requires.
+			time.Sleep(3 * time.Second)
+			_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationDelete, recent)
+			require.NoError(t, err)
+			if !enabled {
+				require.ElementsMatch(t, []string{"used", "unused", "recent", "declared"}, runGC())
+				return
+			}
+			require.ElementsMatch(t, []string{"used", "recent", "declared"}, runGC())
+
+			// The recently unreferenced caveat is collected once the window
+			// has passed for it too.
+			time.Sleep(3 * time.Second)
+			require.ElementsMatch(t, []string{"used", "declared"}, runGC())
+		})
+	}
+}
+
+func TestCRDBDatastoreListRelationshipsPage(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	expected := make([]string, 0, 20)
+	initial := make([]tuple.Relationship, 0, 20)
+	for i := range 20 {
+		rel := tuple.MustParse(fmt.Sprintf("resource:doc%03d#viewer@user:tom", i*2))
+		initial = append(initial, rel)
+		expected = append(expected, tuple.MustString(rel))
+	}
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, initial...)
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[datastore.PagedRelationshipsDatastore](ds)
+	require.NotNil(t, crdbDS)
+	filter := datastore.RelationshipsFilter{OptionalResourceType: "resource"}
+
+	page, cursor, err := crdbDS.ListRelationshipsPage(ctx, filter, "", 3)
+	require.NoError(t, err)
+	listed := make([]string, 0, len(expected))
+	for _, rel := range page {
+		listed = append(listed, tuple.MustString(rel))
+	}
+
+	// Write relationships, interleaved with the existing ones, throughout the
+	// rest of the listing.
+	writeCtx, stopWrites := context.WithCancel(ctx)
+	writesDone := make(chan struct{})
+	go func() {
+		defer close(writesDone)
+		for i := 0; writeCtx.Err() == nil; i++ {
+			rel := tuple.MustParse(fmt.Sprintf("resource:doc%03d#viewer@user:tom", i*2+1))
+			_, _ = common.WriteRelationships(writeCtx, ds, tuple.UpdateOperationTouch, rel)
+		}
+	}()
+
+	for cursor != "" {
+		time.Sleep(10 * time.Millisecond)
+
+		page, cursor, err = crdbDS.ListRelationshipsPage(ctx, filter, cursor, 3)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 3)
+		for _, rel := range page {
+			listed = append(listed, tuple.MustString(rel))
+		}
+	}
+
+	stopWrites()
+	<-writesDone
+
+	require.Equal(t, expected, listed)
+
+	_, _, err = crdbDS.ListRelationshipsPage(ctx, filter, "invalid", 3)
+	require.ErrorIs(t, err, ErrInvalidListCursor)
+}
+
+func TestCRDBDatastoreListRelationshipsWithCaveatPage(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc1#viewer@user:tom[audited]"),
+		tuple.MustParse("document:doc2#viewer@user:tom"),
+		tuple.MustParse("folder:folder1#viewer@user:tom[audited]"),
+		tuple.MustParse("folder:folder2#viewer@user:tom[other]"),
+		tuple.MustParse("organization:org1#member@user:tom[audited]"),
+	)
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[datastore.PagedRelationshipsDatastore](ds)
+	require.NotNil(t, crdbDS)
+
+	var listed []string
+	cursor := ""
+	for {
+		var page []tuple.Relationship
+		page, cursor, err = crdbDS.ListRelationshipsWithCaveatPage(ctx, "audited", cursor, 2)
+		require.NoError(t, err)
+		for _, rel := range page {
+			listed = append(listed, tuple.StringWithoutCaveatOrExpiration(rel))
+		}
+		if cursor == "" {
+			break
+		}
+	}
+	require.Equal(t, []string{
+		"document:doc1#viewer@user:tom",
+		"folder:folder1#viewer@user:tom",
+		"organization:org1#member@user:tom",
+	}, listed)
+
+	_, _, err = crdbDS.ListRelationshipsWithCaveatPage(ctx, "", "", 2)
+	require.Error(t, err)
+}
+
+func TestCRDBDatastoreExportRelationships(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(100*time.Second), ReadPageSize(10))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	expected := make([]string, 0, 25)
+	initial := make([]tuple.Relationship, 0, 25)
+	for i := range 25 {
+		rel := tuple.MustParse(fmt.Sprintf("resource:doc%03d#viewer@user:tom", i))
+		initial = append(initial, rel)
+		expected = append(expected, tuple.MustString(rel))
+	}
+	revision, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, initial...)
+	require.NoError(t, err)
+
+	// Relationships written after the revision are not exported.
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:doc100#viewer@user:tom"))
+	require.NoError(t, err)
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	batches, err := crdbDS.ExportRelationships(ctx, revision)
+	require.NoError(t, err)
+
+	exported := make([]string, 0, len(expected))
+	for batch := range batches {
+		require.NoError(t, batch.Err)
+		require.LessOrEqual(t, len(batch.Relationships), 10)
+		for _, rel := range batch.Relationships {
+			exported = append(exported, tuple.MustString(rel))
+		}
+	}
+	require.Equal(t, expected, exported)
+
+	// Canceling the context closes the stream.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	batches, err = crdbDS.ExportRelationships(cancelCtx, revision)
+	require.NoError(t, err)
+	<-batches
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-batches:
+			return !ok
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCRDBDatastoreExportRelationshipsMaxListResults(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, ReadPageSize(10), MaxListResults(4))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	expected := make([]string, 0, 25)
+	initial := make([]tuple.Relationship, 0, 25)
+	for i := range 25 {
+		rel := tuple.MustParse(fmt.Sprintf("resource:doc%03d#viewer@user:tom", i))
+		initial = append(initial, rel)
+		expected = append(expected, tuple.MustString(rel))
+	}
+	revision, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, initial...)
+	require.NoError(t, err)
+
+	// The batches are capped by MaxListResults rather than truncated.
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	batches, err := crdbDS.ExportRelationships(ctx, revision)
+	require.NoError(t, err)
+
+	exported := make([]string, 0, len(expected))
+	for batch := range batches {
+		require.NoError(t, batch.Err)
+		require.LessOrEqual(t, len(batch.Relationships), 4)
+		for _, rel := range batch.Relationships {
+			exported = append(exported, tuple.MustString(rel))
+		}
+	}
+	require.Equal(t, expected, exported)
+}
+
+func TestCRDBDatastoreDiffRevisions(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, ReadPageSize(2))
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+	defer ds.Close()
+
+	from, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc1#viewer@user:tom"),
+		tuple.MustParse("document:doc2#viewer@user:tom"),
+		tuple.MustParse("document:doc3#viewer@user:tom[somecaveat]"),
+		tuple.MustParse("document:doc4#viewer@user:tom"),
+	)
+	require.NoError(t, err)
+
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationDelete,
+		tuple.MustParse("document:doc2#viewer@user:tom"))
+	require.NoError(t, err)
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationTouch,
+		tuple.MustParse("document:doc3#viewer@user:tom[othercaveat]"),
+		tuple.MustParse("document:doc5#viewer@user:tom"))
+	require.NoError(t, err)
+
+	// Relationships created and deleted between the revisions are not
+	// reported.
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc6#viewer@user:tom"))
+	require.NoError(t, err)
+	to, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationDelete,
+		tuple.MustParse("document:doc6#viewer@user:tom"))
+	require.NoError(t, err)
+
+	changes, err := crdbDS.DiffRevisions(ctx, from, to)
+	require.NoError(t, err)
+
+	var diffed []string
+	for change := range changes {
+		require.NoError(t, change.Err)
+		update := tuple.RelationshipUpdate{Operation: change.Operation, Relationship: change.Relationship}
+		diffed = append(diffed, update.OperationString()+" "+tuple.MustString(change.Relationship))
+	}
+	require.Equal(t, []string{
+		"DELETE document:doc2#viewer@user:tom",
+		"TOUCH document:doc3#viewer@user:tom[othercaveat]",
+		"CREATE document:doc5#viewer@user:tom",
+	}, diffed)
+
+	_, err = crdbDS.DiffRevisions(ctx, to, from)
+	require.Error(t, err)
+}
+
+func TestCRDBDatastoreDiffRevisionsMaxListResults(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, ReadPageSize(10), MaxListResults(2))
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+	defer ds.Close()
+
+	initial := make([]tuple.Relationship, 0, 10)
+	for i := range 10 {
+		initial = append(initial, tuple.MustParse(fmt.Sprintf("document:doc%02d#viewer@user:tom", i)))
+	}
+	from, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, initial...)
+	require.NoError(t, err)
+
+	to, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationDelete,
+		tuple.MustParse("document:doc07#viewer@user:tom"))
+	require.NoError(t, err)
+
+	// The pages are capped by MaxListResults rather than truncated.
+	changes, err := crdbDS.DiffRevisions(ctx, from, to)
+	require.NoError(t, err)
+
+	var diffed []string
+	for change := range changes {
+		require.NoError(t, change.Err)
+		update := tuple.RelationshipUpdate{Operation: change.Operation, Relationship: change.Relationship}
+		diffed = append(diffed, update.OperationString()+" "+tuple.MustString(change.Relationship))
+	}
+	require.Equal(t, []string{"DELETE document:doc07#viewer@user:tom"}, diffed)
+}
+
+func TestCRDBDatastoreMaxTuplesPerWrite(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, MaxTuplesPerWrite(2))
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+	defer ds.Close()
+
+	_, _, err := crdbDS.WriteRelationshipsWithCounts(ctx, []tuple.RelationshipUpdate{
+		tuple.Create(tuple.MustParse("document:doc1#viewer@user:tom")),
+		tuple.Create(tuple.MustParse("document:doc2#viewer@user:tom")),
+	})
+	require.NoError(t, err)
+
+	tooLarge := []tuple.RelationshipUpdate{
+		tuple.Create(tuple.MustParse("document:doc3#viewer@user:tom")),
+		tuple.Create(tuple.MustParse("document:doc4#viewer@user:tom")),
+		tuple.Create(tuple.MustParse("document:doc5#viewer@user:tom")),
+	}
+	_, _, err = crdbDS.WriteRelationshipsWithCounts(ctx, tooLarge)
+	var tooMany datastore.TooManyUpdatesError
+	require.ErrorAs(t, err, &tooMany)
+	require.Equal(t, 3, tooMany.Count())
+	require.Equal(t, 2, tooMany.Limit())
+
+	// Batched writes are checked as a whole, before they are split.
+	_, counts, err := crdbDS.WriteRelationshipsInBatches(ctx, tooLarge)
+	require.ErrorAs(t, err, &tooMany)
+	require.Equal(t, WriteCounts{}, counts)
+
+	// Relationships written within a transaction are not limited.
+	_, err = common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc3#viewer@user:tom"),
+		tuple.MustParse("document:doc4#viewer@user:tom"),
+		tuple.MustParse("document:doc5#viewer@user:tom"),
+	)
+	require.NoError(t, err)
+}
+
+func TestCRDBDatastoreDuplicateWritePolicy(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		policy         string
+		expectedError  bool
+		expectedCaveat string
+	}{
+		{DuplicateWriteError, true, "original"},
+		{DuplicateWriteIgnore, false, "original"},
+		{DuplicateWriteUpsert, false, "updated"},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			var crdbDS *crdbDatastore
+			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				ds, err := NewCRDBDatastore(ctx, uri, DuplicateWritePolicy(tc.policy))
+				require.NoError(t, err)
+				crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+				return ds
+			})
+			defer ds.Close()
+
+			_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+				tuple.MustParse("document:doc1#viewer@user:tom[original]"))
+			require.NoError(t, err)
+
+			revision, counts, err := crdbDS.WriteRelationshipsWithCounts(ctx, []tuple.RelationshipUpdate{
+				tuple.Create(tuple.MustParse("document:doc1#viewer@user:tom[updated]")),
+				tuple.Create(tuple.MustParse("document:doc2#viewer@user:tom")),
+			})
+			if tc.expectedError {
+				require.ErrorAs(t, err, &common.CreateRelationshipExistsError{})
+				revision, err = ds.HeadRevision(ctx)
+				require.NoError(t, err)
+			} else {
+				require.NoError(t, err)
+
+				// The existing relationship, even when overwritten, was not
+				// created.
+				require.Equal(t, WriteCounts{Created: 1, Skipped: 1}, counts)
+
+				// Nor does it change the count of relationships.
+				_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+					if err := rwt.WriteRelationships(ctx, []tuple.RelationshipUpdate{
+						tuple.Create(tuple.MustParse("document:doc1#viewer@user:tom[updated]")),
+						tuple.Create(tuple.MustParse("document:doc3#viewer@user:tom")),
+					}); err != nil {
+						return err
+					}
+					require.Equal(t, int64(1), rwt.(*crdbReadWriteTXN).relCountChange)
+					return nil
+				})
+				require.NoError(t, err)
+			}
+
+			iter, err := ds.SnapshotReader(revision).QueryRelationships(ctx, datastore.RelationshipsFilter{OptionalResourceType: "document"})
+			require.NoError(t, err)
+			rels, err := datastore.IteratorToSlice(iter)
+			require.NoError(t, err)
+
+			if tc.expectedError {
+				require.Len(t, rels, 1)
+			} else {
+				require.Len(t, rels, 2)
+			}
+			require.Equal(t, tc.expectedCaveat, rels[0].OptionalCaveat.CaveatName)
+		})
+	}
+}
+
+func TestCRDBDatastoreWriteRelationshipsWithCounts(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, DuplicateWritePolicy(DuplicateWriteIgnore))
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+	defer ds.Close()
+
+	_, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("document:doc0#viewer@user:tom"),
+		tuple.MustParse("document:doc1#viewer@user:tom"))
+	require.NoError(t, err)
+
+	revision, counts, err := crdbDS.WriteRelationshipsWithCounts(ctx, []tuple.RelationshipUpdate{
+		tuple.Create(tuple.MustParse("document:doc1#viewer@user:tom")),
+		tuple.Create(tuple.MustParse("document:doc2#viewer@user:tom")),
+		tuple.Touch(tuple.MustParse("document:doc3#viewer@user:tom")),
+		tuple.Delete(tuple.MustParse("document:doc0#viewer@user:tom")),
+		tuple.Delete(tuple.MustParse("document:missing#viewer@user:tom")),
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, datastore.NoRevision, revision)
+	require.Equal(t, WriteCounts{Created: 1, Touched: 1, Deleted: 1, Skipped: 2}, counts)
+
+	// Only the relationships that were inserted or deleted change the count of
+	// relationships, not the skipped duplicates, the touches of existing
+	// relationships or the missing relationships.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		if err := rwt.WriteRelationships(ctx, []tuple.RelationshipUpdate{
+			tuple.Create(tuple.MustParse("document:doc1#viewer@user:tom")),
+			tuple.Create(tuple.MustParse("document:doc4#viewer@user:tom")),
+			tuple.Create(tuple.MustParse("document:doc5#viewer@user:tom")),
+			tuple.Touch(tuple.MustParse("document:doc3#viewer@user:tom[expiration:2300-01-01T00:00:00Z]")),
+			tuple.Touch(tuple.MustParse("document:doc6#viewer@user:tom")),
+			tuple.Delete(tuple.MustParse("document:doc2#viewer@user:tom")),
+			tuple.Delete(tuple.MustParse("document:missing#viewer@user:tom")),
+		}); err != nil {
+			return err
+		}
+		require.Equal(t, int64(2), rwt.(*crdbReadWriteTXN).relCountChange)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCRDBDatastoreWriteRelationshipsInBatches(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, AllowTransactionSplitting(2))
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+	defer ds.Close()
+
+	creates := func(ids ...string) []tuple.RelationshipUpdate {
+		mutations := make([]tuple.RelationshipUpdate, 0, len(ids))
+		for _, id := range ids {
+			mutations = append(mutations, tuple.Create(tuple.MustParse("document:"+id+"#viewer@user:tom")))
+		}
+		return mutations
+	}
+	countAt := func(revision datastore.Revision) int {
+		iter, err := ds.SnapshotReader(revision).QueryRelationships(ctx, datastore.RelationshipsFilter{OptionalResourceType: "document"})
+		require.NoError(t, err)
+		count := 0
+		for _, err := range iter {
+			require.NoError(t, err)
+			count++
+		}
+		return count
+	}
+
+	revision, counts, err := crdbDS.WriteRelationshipsInBatches(ctx, creates("a", "b", "c", "d", "e"))
+	require.NoError(t, err)
+	require.Equal(t, WriteCounts{Created: 5}, counts)
+	require.Equal(t, 5, countAt(revision))
+
+	// The batches before a failing one remain committed.
+	revision, counts, err = crdbDS.WriteRelationshipsInBatches(ctx, creates("f", "g", "a", "h"))
+	require.Error(t, err)
+	require.Equal(t, WriteCounts{Created: 2}, counts)
+	require.Equal(t, 7, countAt(revision))
+
+	// Writes through a read-write transaction are never split, so a failed one
+	// commits nothing.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		if err := rwt.WriteRelationships(ctx, creates("i", "j", "k", "l", "m")); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	require.ErrorContains(t, err, "abort")
+	head, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 7, countAt(head))
 }
 
-type datastoreTestFunc func(t *testing.T, ds datastore.Datastore)
+func TestCRDBDatastoreRefreshLatestRevision(t *testing.T) {
+	t.Parallel()
 
-func createDatastoreTest(b testdatastore.RunningEngineForTest, tf datastoreTestFunc, options ...Option) func(*testing.T) {
-	return func(t *testing.T) {
-		ctx := context.Background()
-		ds := b.NewDatastore(t, func(engine, uri string) datastore.Datastore {
-			ds, err := NewCRDBDatastore(ctx, uri, options...)
-			require.NoError(t, err)
-			return ds
-		})
-		defer ds.Close()
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
 
-		tf(t, ds)
-	}
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, RevisionQuantization(time.Hour))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	cached, err := ds.OptimizedRevision(ctx)
+	require.NoError(t, err)
+
+	written, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate,
+		tuple.MustParse("resource:foo#viewer@user:tom"),
+	)
+	require.NoError(t, err)
+
+	// The cached revision predates the write until refreshed.
+	optimized, err := ds.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.True(t, cached.Equal(optimized))
+	require.True(t, optimized.LessThan(written))
+
+	refreshed, err := datastore.UnwrapAs[*crdbDatastore](ds).RefreshLatestRevision(ctx)
+	require.NoError(t, err)
+	require.False(t, refreshed.LessThan(written))
+
+	optimized, err = ds.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.True(t, refreshed.Equal(optimized))
+
+	iter, err := ds.SnapshotReader(optimized).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		OptionalResourceType: "resource",
+	})
+	require.NoError(t, err)
+	found, err := datastore.IteratorToSlice(iter)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
 }
 
-func TestCRDBDatastoreWithFollowerReads(t *testing.T) {
+func TestCRDBDatastoreMetadataColumns(t *testing.T) {
 	t.Parallel()
-	followerReadDelay := time.Duration(4.8 * float64(time.Second))
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		// The columns are not added by the datastore.
+		_, err := NewCRDBDatastore(ctx, uri, WithMetadataColumns("source", "tag"))
+		require.ErrorContains(t, err, "metadata columns [source tag] do not exist")
+
+		conn, err := pgx.Connect(ctx, uri)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+		_, err = conn.Exec(ctx, "ALTER TABLE relation_tuple ADD COLUMN source STRING")
+		require.NoError(t, err)
+
+		_, err = NewCRDBDatastore(ctx, uri, WithMetadataColumns("source", "tag"))
+		require.ErrorContains(t, err, "metadata columns [tag] do not exist")
+
+		_, err = conn.Exec(ctx, "ALTER TABLE relation_tuple ADD COLUMN tag STRING")
+		require.NoError(t, err)
+
+		ds, err := NewCRDBDatastore(ctx, uri, WithMetadataColumns("source", "tag"))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	rel := tuple.MustParse("resource:foo#viewer@user:tom")
+
+	writeCtx := datastore.WithRelationshipMetadata(ctx, map[string]string{"source": "import"})
+	rev, err := common.WriteRelationships(writeCtx, ds, tuple.UpdateOperationCreate, rel)
+	require.NoError(t, err)
+
+	metadata, err := crdbDS.RelationshipMetadata(ctx, rev, rel)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"source": "import"}, metadata)
+
+	// Touching the relationship replaces its metadata.
+	writeCtx = datastore.WithRelationshipMetadata(ctx, map[string]string{"source": "sync", "tag": "t1"})
+	rev, err = common.WriteRelationships(writeCtx, ds, tuple.UpdateOperationTouch, rel)
+	require.NoError(t, err)
+
+	metadata, err = crdbDS.RelationshipMetadata(ctx, rev, rel)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"source": "sync", "tag": "t1"}, metadata)
+
+	_, err = crdbDS.RelationshipMetadata(ctx, rev, tuple.MustParse("resource:bar#viewer@user:tom"))
+	require.ErrorIs(t, err, ErrRelationshipNotFound)
+
+	writeCtx = datastore.WithRelationshipMetadata(ctx, map[string]string{"unknown": "value"})
+	_, err = common.WriteRelationships(writeCtx, ds, tuple.UpdateOperationTouch, rel)
+	require.Error(t, err)
+}
+
+func TestCRDBDatastoreRevisionRange(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
 	gcWindow := 100 * time.Second
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri, GCWindow(gcWindow), RevisionQuantization(time.Second))
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+
+	// The range is available before anything has been written.
+	oldest, newest, err := crdbDS.RevisionRange(ctx)
+	require.NoError(t, err)
+	require.True(t, oldest.LessThan(newest))
+
+	span := newest.(revisions.HLCRevision).TimestampNanoSec() - oldest.(revisions.HLCRevision).TimestampNanoSec()
+	require.LessOrEqual(t, span, gcWindow.Nanoseconds())
+	require.Greater(t, span, (gcWindow - 5*time.Second).Nanoseconds())
+	require.NoError(t, ds.CheckRevision(ctx, newest))
+
+	// The GC watermark is the oldest revision of the range, and revisions
+	// before it can no longer be read.
+	watermark, err := crdbDS.GCWatermark(ctx)
+	require.NoError(t, err)
+	require.False(t, watermark.LessThan(oldest))
+	beforeWatermark := revisions.NewHLCForTime(time.Unix(0, watermark.(revisions.HLCRevision).TimestampNanoSec()).Add(-2 * time.Second))
+	require.Error(t, ds.CheckRevision(ctx, beforeWatermark))
+}
+
+func TestCRDBDatastoreAwaitRevision(t *testing.T) {
+	t.Parallel()
 
 	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
 
-	quantizationDurations := []time.Duration{
-		0 * time.Second,
-		100 * time.Millisecond,
-	}
-	for _, quantization := range quantizationDurations {
-		t.Run(fmt.Sprintf("Quantization%s", quantization), func(t *testing.T) {
-			require := require.New(t)
-			ctx := context.Background()
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
 
-			ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
-				ds, err := NewCRDBDatastore(
-					ctx,
-					uri,
-					GCWindow(gcWindow),
-					RevisionQuantization(quantization),
-					FollowerReadDelay(followerReadDelay),
-					DebugAnalyzeBeforeStatistics(),
-				)
-				require.NoError(err)
-				return ds
-			})
-			defer ds.Close()
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
 
-			r, err := ds.ReadyState(ctx)
-			require.NoError(err)
-			require.True(r.IsReady)
+	rev, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:tom"))
+	require.NoError(t, err)
+	require.NoError(t, crdbDS.AwaitRevision(ctx, rev))
 
-			// Revisions should be at least the follower read delay amount in the past
-			for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
-				testRevision, err := ds.OptimizedRevision(ctx)
-				require.NoError(err)
+	// A revision in the future is awaited until the context expires.
+	future := revisions.NewHLCForTime(time.Now().Add(1 * time.Hour))
+	awaitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, crdbDS.AwaitRevision(awaitCtx, future), context.DeadlineExceeded)
+}
 
-				nowRevision, err := ds.HeadRevision(ctx)
-				require.NoError(err)
+func TestCRDBDatastoreSnapshotReaderAtLeast(t *testing.T) {
+	t.Parallel()
 
-				diff := nowRevision.(revisions.HLCRevision).TimestampNanoSec() - testRevision.(revisions.HLCRevision).TimestampNanoSec()
-				require.True(diff > followerReadDelay.Nanoseconds())
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	var crdbDS *crdbDatastore
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		crdbDS = datastore.UnwrapAs[*crdbDatastore](ds)
+		return ds
+	})
+	defer ds.Close()
+
+	before, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:tom"))
+	require.NoError(t, err)
+	latest, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, tuple.MustParse("resource:foo#viewer@user:sarah"))
+	require.NoError(t, err)
+	future := revisions.NewHLCForTime(time.Now().Add(1 * time.Hour))
+
+	for _, tc := range []struct {
+		name     string
+		policy   MinimumRevisionPolicy
+		minimum  datastore.Revision
+		exact    bool
+		expected bool
+	}{
+		{"exact/before", MinimumRevisionExact, before, true, true},
+		{"exact/at", MinimumRevisionExact, latest, true, true},
+		{"exact/after", MinimumRevisionExact, future, true, false},
+		{"latest/before", MinimumRevisionLatestIfPast, before, false, true},
+		{"latest/at", MinimumRevisionLatestIfPast, latest, false, true},
+		{"latest/after", MinimumRevisionLatestIfPast, future, false, false},
+		{"wait/before", MinimumRevisionWait, before, true, true},
+		{"wait/at", MinimumRevisionWait, latest, true, true},
+		{"wait/after", MinimumRevisionWait, future, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			readCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+			defer cancel()
+
+			reader, revision, err := crdbDS.SnapshotReaderAtLeast(readCtx, tc.minimum, tc.policy)
+			if !tc.expected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.False(t, revision.LessThan(tc.minimum))
+			if tc.exact {
+				require.True(t, revision.Equal(tc.minimum))
+			} else {
+				require.False(t, revision.LessThan(latest))
+			}
+
+			iter, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{OptionalResourceType: "resource"})
+			require.NoError(t, err)
+			rels, err := datastore.IteratorToSlice(iter)
+			require.NoError(t, err)
+			if revision.Equal(before) {
+				require.Len(t, rels, 1)
+			} else {
+				require.Len(t, rels, 2)
 			}
 		})
 	}
 }
 
+func TestCRDBDatastoreEffectiveIsolation(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	isolation, err := datastore.UnwrapAs[*crdbDatastore](ds).EffectiveIsolation(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "serializable", isolation)
+}
+
+func TestCRDBDatastoreRevisionHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(ctx, uri,
+			RevisionQuantization(100*time.Millisecond),
+			RevisionHeartbeatInterval(100*time.Millisecond),
+			WithAdvertisedRevisionMetric(true),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	advertised := func() float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() == "spicedb_datastore_crdb_advertised_revision_timestamp_seconds" {
+				return family.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		return 0
+	}
+
+	// Without any requests being made, the heartbeat advances the advertised
+	// revision.
+	require.Eventually(t, func() bool { return advertised() > 0 }, 5*time.Second, 10*time.Millisecond)
+	first := advertised()
+	require.Eventually(t, func() bool { return advertised() > first }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCRDBDatastoreWithFollowerReadsDisabled(t *testing.T) {
+	t.Parallel()
+	followerReadDelay := 5 * time.Second
+
+	engine := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	ds := engine.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		ds, err := NewCRDBDatastore(
+			ctx,
+			uri,
+			GCWindow(100*time.Second),
+			FollowerReadDelay(followerReadDelay),
+		)
+		require.NoError(t, err)
+		return ds
+	})
+	defer ds.Close()
+
+	// Write a relationship and ensure it is visible immediately when follower
+	// reads are disabled, despite the configured delay.
+	rel := tuple.MustParse("resource:foo#viewer@user:tom")
+	writtenRev, err := common.WriteRelationships(ctx, ds, tuple.UpdateOperationCreate, rel)
+	require.NoError(t, err)
+
+	strongCtx := datastore.WithFollowerReadsDisabled(ctx)
+	optimized, err := ds.OptimizedRevision(strongCtx)
+	require.NoError(t, err)
+	require.True(t, optimized.GreaterThan(writtenRev) || optimized.Equal(writtenRev))
+
+	iter, err := ds.SnapshotReader(optimized).QueryRelationships(strongCtx, datastore.RelationshipsFilter{
+		OptionalResourceType: "resource",
+	})
+	require.NoError(t, err)
+
+	found := 0
+	for _, err := range iter {
+		require.NoError(t, err)
+		found++
+	}
+	require.Equal(t, 1, found)
+
+	// Without disabling follower reads, the optimized revision trails the write.
+	delayed, err := ds.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.True(t, delayed.LessThan(writtenRev))
+}
+
 var defaultKeyForTesting = proxy.KeyConfig{
 	ID: "defaultfortest",
 	Bytes: (func() []byte {
@@ -618,6 +2647,58 @@ func RelationshipIntegrityWatchTest(t *testing.T, tester test.DatastoreTester) {
 	}
 }
 
+func ResourceTypeWatchTest(t *testing.T, rawDS datastore.Datastore) {
+	require := require.New(t)
+
+	ds, rev := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, `
+		definition user {}
+
+		definition resource {
+			relation viewer: user
+		}
+
+		definition hotresource {
+			relation viewer: user
+		}
+	`, nil, require)
+	ctx := context.Background()
+
+	opts := datastore.WatchOptions{
+		Content:                 datastore.WatchRelationships,
+		WatchBufferWriteTimeout: 1 * time.Second,
+		OptionalResourceTypes:   []string{"resource"},
+	}
+	changes, errchan := ds.Watch(ctx, rev, opts)
+
+	// Flood the hot type, then write a single change to the watched type.
+	for i := 0; i < 50; i++ {
+		_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships(ctx, []tuple.RelationshipUpdate{
+				tuple.Touch(tuple.MustParse(fmt.Sprintf("hotresource:doc%d#viewer@user:tom", i))),
+			})
+		})
+		require.NoError(err)
+	}
+
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships(ctx, []tuple.RelationshipUpdate{
+			tuple.Touch(tuple.MustParse("resource:foo#viewer@user:tom")),
+		})
+	})
+	require.NoError(err)
+
+	select {
+	case change, ok := <-changes:
+		require.True(ok, "watch closed before receiving changes")
+		require.Len(change.RelationshipChanges, 1)
+		require.Equal("resource", change.RelationshipChanges[0].Relationship.Resource.ObjectType)
+	case err := <-errchan:
+		require.Failf("Failed waiting for changes with error", "error: %v", err)
+	case <-time.NewTimer(10 * time.Second).C:
+		require.Fail("Timed out")
+	}
+}
+
 func StreamingWatchTest(t *testing.T, rawDS datastore.Datastore) {
 	require := require.New(t)
 
@@ -711,3 +2792,125 @@ func StreamingWatchTest(t *testing.T, rawDS datastore.Datastore) {
 		}
 	}
 }
+
+func ReadOnlyReadPoolTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	err := crdbDS.readPool.ExecFunc(ctx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
+		return err
+	}, "INSERT INTO metadata (unique_id) VALUES ('shouldfail')")
+	require.ErrorContains(err, "read-only")
+}
+
+func LargeRelationshipWriteTest(t *testing.T, rawDS datastore.Datastore) {
+	require := require.New(t)
+
+	ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, `
+		definition user {}
+
+		definition resource {
+			relation viewer: user
+		}
+	`, nil, require)
+	ctx := context.Background()
+
+	updates := make([]tuple.RelationshipUpdate, 0, 5)
+	for i := 0; i < 5; i++ {
+		updates = append(updates, tuple.Create(tuple.MustParse(fmt.Sprintf("resource:foo#viewer@user:user%d", i))))
+	}
+	updates = append(updates, tuple.Touch(tuple.MustParse("resource:foo#viewer@user:user0")))
+
+	rev, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships(ctx, updates)
+	})
+	require.NoError(err)
+
+	iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		OptionalResourceType: "resource",
+	})
+	require.NoError(err)
+
+	rels, err := datastore.IteratorToSlice(iter)
+	require.NoError(err)
+	require.Len(rels, 5)
+}
+
+func ResetQueryOnReleaseTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	for _, p := range []*pool.RetryPool{crdbDS.readPool, crdbDS.writePool} {
+		require.NoError(p.ExecFunc(ctx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
+			return err
+		}, "SET application_name = 'leaked'"))
+
+		// The pool holds a single connection, so this reads from the one whose
+		// session was changed above.
+		var label string
+		require.NoError(p.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+			return row.Scan(&label)
+		}, "SHOW application_name"))
+		require.NotEqual("leaked", label)
+	}
+}
+
+func ConnectionLabelTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	for _, p := range []*pool.RetryPool{crdbDS.readPool, crdbDS.writePool} {
+		var label string
+		require.NoError(p.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+			return row.Scan(&label)
+		}, "SHOW application_name"))
+		require.Equal("spicedb-test", label)
+	}
+}
+
+func DeadlineStatementTimeoutsTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+
+	deadlineCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	crdbDS := datastore.UnwrapAs[*crdbDatastore](ds)
+	for _, p := range []*pool.RetryPool{crdbDS.readPool, crdbDS.writePool} {
+		showTimeout := func(ctx context.Context) string {
+			var timeout string
+			require.NoError(p.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+				return row.Scan(&timeout)
+			}, "SHOW statement_timeout"))
+			return timeout
+		}
+
+		// Statements outside of a transaction do not change the session's
+		// timeout, but are canceled once their deadline passes.
+		require.Equal("0", showTimeout(deadlineCtx))
+
+		shortCtx, cancelShort := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		started := time.Now()
+		err := p.ExecFunc(shortCtx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
+			return err
+		}, "SELECT pg_sleep(30)")
+		cancelShort()
+		require.Error(err)
+		require.Less(time.Since(started), 10*time.Second)
+
+		// The pool holds a single connection, whose statement was canceled
+		// above, and which remains usable.
+		require.Equal("0", showTimeout(context.Background()))
+
+		var timeout string
+		require.NoError(p.BeginFunc(deadlineCtx, func(tx pgx.Tx) error {
+			return tx.QueryRow(deadlineCtx, "SHOW statement_timeout").Scan(&timeout)
+		}))
+		require.NotEqual("0", timeout)
+
+		// The timeout of the transaction does not outlive it.
+		require.Equal("0", showTimeout(context.Background()))
+	}
+}
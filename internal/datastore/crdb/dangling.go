@@ -0,0 +1,67 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// danglingRelationships returns the condition matching the relationships
+// whose resource or subject type has no namespace definition. The namespace
+// definitions are few and keyed by name, so each relationship is checked with
+// a point lookup rather than a scan.
+func (cds *crdbDatastore) danglingRelationships() sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf(
+		"(NOT EXISTS (SELECT 1 FROM %[2]s WHERE %[2]s.%[3]s = %[1]s.%[3]s) OR NOT EXISTS (SELECT 1 FROM %[2]s WHERE %[2]s.%[3]s = %[1]s.%[4]s))",
+		cds.schema.RelationshipTableName, tableNamespace, colNamespace, colUsersetNamespace,
+	))
+}
+
+// FindDanglingRelationships returns the relationships whose resource or
+// subject type is not defined by the current schema, such as those left
+// behind when an object type is removed or renamed. The relationships are
+// returned without their caveats or expiration, in resource order.
+//
+// Every dangling relationship is returned, so sources of many of them should
+// be reconciled in parts, or removed by garbage collection with
+// GCDanglingRelationships.
+func (cds *crdbDatastore) FindDanglingRelationships(ctx context.Context) ([]tuple.Relationship, error) {
+	if err := cds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	sql, args, err := psql.Select(colNamespace, colObjectID, colRelation, colUsersetNamespace, colUsersetObjectID, colUsersetRelation).
+		From(cds.schema.RelationshipTableName).
+		Where(cds.danglingRelationships()).
+		OrderBy(colNamespace, colObjectID, colRelation, colUsersetNamespace, colUsersetObjectID, colUsersetRelation).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var dangling []tuple.Relationship
+	if err := cds.readPool.QueryFunc(ctx, func(ctx context.Context, rows pgx.Rows) error {
+		for rows.Next() {
+			var rel tuple.Relationship
+			if err := rows.Scan(
+				&rel.Resource.ObjectType,
+				&rel.Resource.ObjectID,
+				&rel.Resource.Relation,
+				&rel.Subject.ObjectType,
+				&rel.Subject.ObjectID,
+				&rel.Subject.Relation,
+			); err != nil {
+				return err
+			}
+			dangling = append(dangling, rel)
+		}
+		return rows.Err()
+	}, sql, args...); err != nil {
+		return nil, fmt.Errorf("unable to find dangling relationships: %w", err)
+	}
+	return dangling, nil
+}
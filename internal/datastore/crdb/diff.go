@@ -0,0 +1,161 @@
+package crdb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// RelationshipChange is a change to a relationship streamed by DiffRevisions.
+// A change with an error is the last of the stream.
+type RelationshipChange struct {
+	// Operation is tuple.UpdateOperationCreate for a relationship that was
+	// created, tuple.UpdateOperationDelete for one that was deleted and
+	// tuple.UpdateOperationTouch for one whose caveat or expiration changed.
+	Operation tuple.UpdateOperation
+
+	// Relationship is the relationship as of the later revision or, if it was
+	// deleted, the earlier one.
+	Relationship tuple.Relationship
+
+	Err error
+}
+
+// DiffRevisions streams the changes that turn the relationships at revision
+// from into those at revision to, in resource order. Both revisions must be
+// within the GC window, and from must not follow to.
+//
+// The relationships at each revision are read a page at a time and compared,
+// so the diff holds at most a page of each in memory, however many
+// relationships there are; only the net change between the revisions is
+// reported, so a relationship created and deleted between them is not. The
+// channel is closed once the last change has been sent, after a change with
+// an error, or once the context has been canceled. The diff fails, with a
+// stale revision error, if the revisions fall outside the GC window before it
+// ends.
+func (cds *crdbDatastore) DiffRevisions(ctx context.Context, from, to datastore.Revision) (<-chan RelationshipChange, error) {
+	if err := cds.CheckRevision(ctx, from); err != nil {
+		return nil, err
+	}
+	if err := cds.CheckRevision(ctx, to); err != nil {
+		return nil, err
+	}
+	if to.LessThan(from) {
+		return nil, fmt.Errorf("revision %s precedes revision %s", to, from)
+	}
+
+	changes := make(chan RelationshipChange)
+	go func() {
+		defer close(changes)
+
+		send := func(change RelationshipChange) bool {
+			select {
+			case changes <- change:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		before := &pagedRelationships{reader: cds.SnapshotReader(from), pageSize: cds.exportBatchSize()}
+		after := &pagedRelationships{reader: cds.SnapshotReader(to), pageSize: cds.exportBatchSize()}
+		for {
+			beforeRel, beforeOK, err := before.peek(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					send(RelationshipChange{Err: err})
+				}
+				return
+			}
+			afterRel, afterOK, err := after.peek(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					send(RelationshipChange{Err: err})
+				}
+				return
+			}
+
+			var change RelationshipChange
+			switch {
+			case !beforeOK && !afterOK:
+				return
+
+			case !afterOK || (beforeOK && compareRelationshipKeys(beforeRel, afterRel) < 0):
+				before.pop()
+				change = RelationshipChange{Operation: tuple.UpdateOperationDelete, Relationship: beforeRel}
+
+			case !beforeOK || compareRelationshipKeys(beforeRel, afterRel) > 0:
+				after.pop()
+				change = RelationshipChange{Operation: tuple.UpdateOperationCreate, Relationship: afterRel}
+
+			default:
+				before.pop()
+				after.pop()
+				if tuple.Equal(beforeRel, afterRel) {
+					continue
+				}
+				change = RelationshipChange{Operation: tuple.UpdateOperationTouch, Relationship: afterRel}
+			}
+
+			if !send(change) {
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// pagedRelationships reads the relationships of a snapshot, in resource order,
+// a page at a time.
+type pagedRelationships struct {
+	reader   datastore.Reader
+	pageSize uint64
+
+	page  []tuple.Relationship
+	after options.Cursor
+	done  bool
+}
+
+// peek returns the next relationship, reading the next page if the current
+// one has been consumed, or false if there are none left.
+func (pr *pagedRelationships) peek(ctx context.Context) (tuple.Relationship, bool, error) {
+	if len(pr.page) == 0 && !pr.done {
+		page, err := readExportBatch(ctx, pr.reader, pr.after, pr.pageSize)
+		if err != nil {
+			return tuple.Relationship{}, false, err
+		}
+		pr.page = page
+		pr.done = uint64(len(page)) < pr.pageSize
+		if len(page) > 0 {
+			pr.after = options.ToCursor(page[len(page)-1])
+		}
+	}
+
+	if len(pr.page) == 0 {
+		return tuple.Relationship{}, false, nil
+	}
+	return pr.page[0], true, nil
+}
+
+// pop consumes the relationship returned by peek.
+func (pr *pagedRelationships) pop() {
+	pr.page = pr.page[1:]
+}
+
+// compareRelationshipKeys orders relationships by their resource and subject,
+// as the relationships table is sorted by options.ByResource.
+func compareRelationshipKeys(lhs, rhs tuple.Relationship) int {
+	return cmp.Or(
+		cmp.Compare(lhs.Resource.ObjectType, rhs.Resource.ObjectType),
+		cmp.Compare(lhs.Resource.ObjectID, rhs.Resource.ObjectID),
+		cmp.Compare(lhs.Resource.Relation, rhs.Resource.Relation),
+		cmp.Compare(lhs.Subject.ObjectType, rhs.Subject.ObjectType),
+		cmp.Compare(lhs.Subject.ObjectID, rhs.Subject.ObjectID),
+		cmp.Compare(lhs.Subject.Relation, rhs.Subject.Relation),
+	)
+}
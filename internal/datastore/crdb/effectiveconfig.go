@@ -0,0 +1,202 @@
+package crdb
+
+import (
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EffectiveConfig is the configuration in effect for the datastore, once its
+// options and their defaults have been resolved and the GC window has been
+// capped by the cluster's. It holds no credentials: the connection is
+// described by its host, port, database and user only. Durations are
+// formatted as by time.Duration.String.
+type EffectiveConfig struct {
+	Host     string `json:"host"`
+	Port     uint16 `json:"port"`
+	Database string `json:"database"`
+	User     string `json:"user"`
+
+	ReadPool EffectivePoolConfig `json:"read_pool"`
+
+	// WritePool is nil for a read-only datastore, which opens no write pool.
+	WritePool *EffectivePoolConfig `json:"write_pool,omitempty"`
+
+	GCWindow                    string  `json:"gc_window"`
+	RevisionQuantization        string  `json:"revision_quantization"`
+	FollowerReadDelay           string  `json:"follower_read_delay"`
+	MaxRevisionStalenessPercent float64 `json:"max_revision_staleness_percent"`
+	RevisionHeartbeatInterval   string  `json:"revision_heartbeat_interval"`
+
+	ConnectTimeout     string `json:"connect_timeout"`
+	QueryTimeout       string `json:"query_timeout"`
+	SlowQueryThreshold string `json:"slow_query_threshold"`
+	CloseTimeout       string `json:"close_timeout"`
+	MaxRetries         uint8  `json:"max_retries"`
+
+	WatchBufferLength         uint16 `json:"watch_buffer_length"`
+	WatchBufferWriteTimeout   string `json:"watch_buffer_write_timeout"`
+	WatchBufferOverflowPolicy string `json:"watch_buffer_overflow_policy"`
+	WatchConnectTimeout       string `json:"watch_connect_timeout"`
+
+	OverlapStrategy          string `json:"overlap_strategy"`
+	WriteTransactionPriority string `json:"write_transaction_priority"`
+	DuplicateWritePolicy     string `json:"duplicate_write_policy"`
+	WriteBatchSize           int    `json:"write_batch_size"`
+	ReadPageSize             int    `json:"read_page_size"`
+	FilterMaximumIDCount     uint16 `json:"filter_maximum_id_count"`
+
+	// The limits below are nil when unset, such that no limit is
+	// distinguished from an explicit zero.
+	MaxListResults           *int `json:"max_list_results,omitempty"`
+	MaxConcurrentWatches     *int `json:"max_concurrent_watches,omitempty"`
+	MaxTotalWatchBufferBytes *int `json:"max_total_watch_buffer_bytes,omitempty"`
+	MaxTuplesPerWrite        *int `json:"max_tuples_per_write,omitempty"`
+	StatementCacheCapacity   *int `json:"statement_cache_capacity,omitempty"`
+	DescriptionCacheCapacity *int `json:"description_cache_capacity,omitempty"`
+
+	Features EffectiveFeatures `json:"features"`
+}
+
+// EffectivePoolConfig is the configuration in effect for a connection pool of
+// the datastore.
+type EffectivePoolConfig struct {
+	MaxConns              int32  `json:"max_conns"`
+	MinConns              int32  `json:"min_conns"`
+	MaxConnLifetime       string `json:"max_conn_lifetime"`
+	MaxConnLifetimeJitter string `json:"max_conn_lifetime_jitter"`
+	MaxConnIdleTime       string `json:"max_conn_idle_time"`
+	HealthCheckPeriod     string `json:"health_check_period"`
+}
+
+// EffectiveFeatures are the optional behaviors of the datastore that are
+// enabled.
+type EffectiveFeatures struct {
+	ConnectionBalancing     bool `json:"connection_balancing"`
+	PrometheusStats         bool `json:"prometheus_stats"`
+	Metrics                 bool `json:"metrics"`
+	RelationshipIntegrity   bool `json:"relationship_integrity"`
+	Expiration              bool `json:"expiration"`
+	GCCaveats               bool `json:"gc_caveats"`
+	GCDanglingRelationships bool `json:"gc_dangling_relationships"`
+	WatchCompression        bool `json:"watch_compression"`
+	StatementLabels         bool `json:"statement_labels"`
+	ValidateCaveatsOnWrite  bool `json:"validate_caveats_on_write"`
+	ReadOnly                bool `json:"read_only"`
+	ReadReplica             bool `json:"read_replica"`
+	ChangeSink              bool `json:"change_sink"`
+}
+
+// effectiveConfig returns the configuration in effect for a datastore with
+// the resolved options and the configurations of its pools.
+func (co crdbOptions) effectiveConfig(readPoolConfig, writePoolConfig *pgxpool.Config) EffectiveConfig {
+	connConfig := readPoolConfig.ConnConfig
+	config := EffectiveConfig{
+		Host:     connConfig.Host,
+		Port:     connConfig.Port,
+		Database: connConfig.Database,
+		User:     connConfig.User,
+
+		ReadPool: effectivePoolConfig(readPoolConfig),
+
+		GCWindow:                    co.gcWindow.String(),
+		RevisionQuantization:        co.revisionQuantization.String(),
+		FollowerReadDelay:           co.followerReadDelay.String(),
+		MaxRevisionStalenessPercent: co.maxRevisionStalenessPercent,
+		RevisionHeartbeatInterval:   co.revisionHeartbeatInterval.String(),
+
+		ConnectTimeout:     connConfig.ConnectTimeout.String(),
+		QueryTimeout:       co.queryTimeout.String(),
+		SlowQueryThreshold: co.slowQueryThreshold.String(),
+		CloseTimeout:       co.closeTimeout.String(),
+		MaxRetries:         co.maxRetries,
+
+		WatchBufferLength:         co.watchBufferLength,
+		WatchBufferWriteTimeout:   co.watchBufferWriteTimeout.String(),
+		WatchBufferOverflowPolicy: co.watchBufferOverflowPolicy,
+		WatchConnectTimeout:       co.watchConnectTimeout.String(),
+
+		OverlapStrategy:          co.overlapStrategy,
+		WriteTransactionPriority: co.writeTransactionPriority,
+		DuplicateWritePolicy:     co.duplicateWritePolicy,
+		WriteBatchSize:           co.writeBatchSize,
+		ReadPageSize:             co.readPageSize,
+		FilterMaximumIDCount:     co.filterMaximumIDCount,
+
+		MaxListResults:           copyOf(co.maxListResultsLimit),
+		MaxConcurrentWatches:     copyOf(co.maxConcurrentWatches),
+		MaxTotalWatchBufferBytes: copyOf(co.maxTotalWatchBufferBytes),
+		MaxTuplesPerWrite:        copyOf(co.maxTuplesPerWrite),
+		StatementCacheCapacity:   copyOf(co.statementCacheCapacity),
+		DescriptionCacheCapacity: copyOf(co.descriptionCacheCapacity),
+
+		Features: EffectiveFeatures{
+			ConnectionBalancing:     co.enableConnectionBalancing,
+			PrometheusStats:         co.enablePrometheusStats,
+			Metrics:                 !co.metricsDisabled,
+			RelationshipIntegrity:   co.withIntegrity,
+			Expiration:              !co.expirationDisabled,
+			GCCaveats:               co.gcCaveats,
+			GCDanglingRelationships: co.gcDanglingRelationships,
+			WatchCompression:        co.watchCompressionThreshold > 0,
+			StatementLabels:         co.statementLabels,
+			ValidateCaveatsOnWrite:  co.validateCaveatsOnWrite,
+			ReadOnly:                co.readOnlyMode || co.readReplica,
+			ReadReplica:             co.readReplica,
+			ChangeSink:              co.changeSink != nil,
+		},
+	}
+	if !co.readReplica {
+		writePool := effectivePoolConfig(writePoolConfig)
+		config.WritePool = &writePool
+	}
+	return config
+}
+
+func effectivePoolConfig(poolConfig *pgxpool.Config) EffectivePoolConfig {
+	return EffectivePoolConfig{
+		MaxConns:              poolConfig.MaxConns,
+		MinConns:              poolConfig.MinConns,
+		MaxConnLifetime:       poolConfig.MaxConnLifetime.String(),
+		MaxConnLifetimeJitter: poolConfig.MaxConnLifetimeJitter.String(),
+		MaxConnIdleTime:       poolConfig.MaxConnIdleTime.String(),
+		HealthCheckPeriod:     poolConfig.HealthCheckPeriod.String(),
+	}
+}
+
+// copyOf returns a pointer to a copy of the value, or nil if it is unset.
+func copyOf[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+// ResolveConfig returns the configuration that would be in effect for a
+// datastore created with NewCRDBDatastore for the URL and options, or the
+// error with which it would reject them, without connecting to the database.
+// Unlike that of the datastore, the GC window is not capped by the cluster's.
+func ResolveConfig(url string, options []Option) (EffectiveConfig, error) {
+	config, err := generateConfig(options)
+	if err != nil {
+		return EffectiveConfig{}, err
+	}
+
+	readPoolConfig, writePoolConfig, err := config.poolConfigs(url)
+	if err != nil {
+		return EffectiveConfig{}, err
+	}
+	return config.effectiveConfig(readPoolConfig, writePoolConfig), nil
+}
+
+// EffectiveConfig returns the configuration in effect for the datastore.
+func (cds *crdbDatastore) EffectiveConfig() EffectiveConfig {
+	return cds.effectiveConfig
+}
+
+// EffectiveConfigJSON returns the configuration in effect for the datastore,
+// as returned by EffectiveConfig, marshaled to JSON.
+func (cds *crdbDatastore) EffectiveConfigJSON() ([]byte, error) {
+	return json.Marshal(cds.effectiveConfig)
+}
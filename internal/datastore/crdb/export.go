@@ -0,0 +1,93 @@
+package crdb
+
+import (
+	"context"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// RelationshipBatch is a batch of the relationships streamed by
+// ExportRelationships. A batch with an error is the last of the stream.
+type RelationshipBatch struct {
+	Relationships []tuple.Relationship
+	Err           error
+}
+
+// ExportRelationships streams every relationship at the given revision, in
+// resource order, as batches of at most ReadPageSize relationships, or of
+// MaxListResults if it is lower. The batches are read one at a time, each
+// resuming after the last relationship of the previous one, as they are
+// received, so that at most two batches are held in memory at once however
+// many relationships are exported.
+//
+// The channel is closed once the last batch has been sent, after a batch with
+// an error, or once the context has been canceled, in which case the
+// relationships not yet sent are not read. The export fails, with a stale
+// revision error, if the revision falls outside the GC window before it ends.
+func (cds *crdbDatastore) ExportRelationships(ctx context.Context, revision datastore.Revision) (<-chan RelationshipBatch, error) {
+	if err := cds.CheckRevision(ctx, revision); err != nil {
+		return nil, err
+	}
+
+	batches := make(chan RelationshipBatch)
+	go func() {
+		defer close(batches)
+
+		reader := cds.SnapshotReader(revision)
+		var after options.Cursor
+		for {
+			limit := cds.exportBatchSize()
+			batch, err := readExportBatch(ctx, reader, after, limit)
+			if err != nil {
+				if ctx.Err() == nil {
+					select {
+					case batches <- RelationshipBatch{Err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+
+			if len(batch) > 0 {
+				select {
+				case batches <- RelationshipBatch{Relationships: batch}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if uint64(len(batch)) < limit {
+				return
+			}
+			after = options.ToCursor(batch[len(batch)-1])
+		}
+	}()
+
+	return batches, nil
+}
+
+// exportBatchSize returns the number of relationships read by each query of
+// readExportBatch: ReadPageSize, capped by MaxListResults so that no batch is
+// truncated.
+func (cds *crdbDatastore) exportBatchSize() uint64 {
+	if cds.maxListResults > 0 && cds.readPageSize > cds.maxListResults {
+		return cds.maxListResults
+	}
+	return cds.readPageSize
+}
+
+// readExportBatch reads at most limit of the relationships that follow the
+// cursor. The limit must not exceed MaxListResults; see exportBatchSize.
+func readExportBatch(ctx context.Context, reader datastore.Reader, after options.Cursor, limit uint64) ([]tuple.Relationship, error) {
+	iter, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{},
+		options.WithSort(options.ByResource),
+		options.WithLimit(&limit),
+		options.WithAfter(after),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return datastore.IteratorToSlice(iter)
+}
@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// followerAwareQuerier sends queries to the read pool, unless follower reads
+// have been disabled for the operation, in which case they are sent to the
+// write pool. Once the datastore has been closed, queries fail with
+// datastore.ErrDatastoreClosed.
+type followerAwareQuerier struct {
+	read, write pgxcommon.DBFuncQuerier
+	closed      *atomic.Bool
+}
+
+func (q followerAwareQuerier) querierFor(ctx context.Context) (pgxcommon.DBFuncQuerier, error) {
+	if q.closed != nil && q.closed.Load() {
+		return nil, datastore.ErrDatastoreClosed
+	}
+	if datastore.FollowerReadsDisabled(ctx) {
+		return q.write, nil
+	}
+	return q.read, nil
+}
+
+func (q followerAwareQuerier) ExecFunc(ctx context.Context, tagFunc func(ctx context.Context, tag pgconn.CommandTag, err error) error, sql string, arguments ...any) error {
+	querier, err := q.querierFor(ctx)
+	if err != nil {
+		return err
+	}
+	return querier.ExecFunc(ctx, tagFunc, sql, arguments...)
+}
+
+func (q followerAwareQuerier) QueryFunc(ctx context.Context, rowsFunc func(ctx context.Context, rows pgx.Rows) error, sql string, optionsAndArgs ...any) error {
+	querier, err := q.querierFor(ctx)
+	if err != nil {
+		return err
+	}
+	return querier.QueryFunc(ctx, rowsFunc, sql, optionsAndArgs...)
+}
+
+func (q followerAwareQuerier) QueryRowFunc(ctx context.Context, rowFunc func(ctx context.Context, row pgx.Row) error, sql string, optionsAndArgs ...any) error {
+	querier, err := q.querierFor(ctx)
+	if err != nil {
+		return err
+	}
+	return querier.QueryRowFunc(ctx, rowFunc, sql, optionsAndArgs...)
+}
+
+// readQuerier returns the querier used for reads, which honors whether
+// follower reads have been disabled for the operation.
+func (cds *crdbDatastore) readQuerier() followerAwareQuerier {
+	return followerAwareQuerier{read: cds.readPool, write: cds.strongReadPool(), closed: &cds.closed}
+}
+
+// OptimizedRevision returns the optimized revision for reads, which trails the
+// current time by the follower read delay. If follower reads have been
+// disabled for the operation, the current revision is returned instead.
+func (cds *crdbDatastore) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	if err := cds.checkOpen(); err != nil {
+		return datastore.NoRevision, err
+	}
+	if datastore.FollowerReadsDisabled(ctx) {
+		return cds.headRevisionInternal(ctx)
+	}
+	return cds.RemoteClockRevisions.OptimizedRevision(ctx)
+}
+
+var _ pgxcommon.DBFuncQuerier = followerAwareQuerier{}
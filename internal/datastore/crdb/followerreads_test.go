@@ -0,0 +1,68 @@
+package crdb
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	internaldatastore "github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+type recordingQuerier struct {
+	calls int
+}
+
+func (r *recordingQuerier) ExecFunc(context.Context, func(context.Context, pgconn.CommandTag, error) error, string, ...any) error {
+	r.calls++
+	return nil
+}
+
+func (r *recordingQuerier) QueryFunc(context.Context, func(context.Context, pgx.Rows) error, string, ...any) error {
+	r.calls++
+	return nil
+}
+
+func (r *recordingQuerier) QueryRowFunc(context.Context, func(context.Context, pgx.Row) error, string, ...any) error {
+	r.calls++
+	return nil
+}
+
+func TestFollowerAwareQuerier(t *testing.T) {
+	read, write := &recordingQuerier{}, &recordingQuerier{}
+	querier := followerAwareQuerier{read: read, write: write}
+
+	ctx := context.Background()
+	require.NoError(t, querier.QueryFunc(ctx, nil, "SELECT 1"))
+	require.Equal(t, 1, read.calls)
+	require.Zero(t, write.calls)
+
+	// The flag must survive the context separation performed by the datastore
+	// proxy.
+	strongCtx := internaldatastore.SeparateContextWithTracing(datastore.WithFollowerReadsDisabled(ctx))
+	require.NoError(t, querier.QueryFunc(strongCtx, nil, "SELECT 1"))
+	require.NoError(t, querier.QueryRowFunc(strongCtx, nil, "SELECT 1"))
+	require.NoError(t, querier.ExecFunc(strongCtx, nil, "SELECT 1"))
+	require.Equal(t, 1, read.calls)
+	require.Equal(t, 3, write.calls)
+}
+
+func TestFollowerAwareQuerierClosed(t *testing.T) {
+	read, write := &recordingQuerier{}, &recordingQuerier{}
+	var closed atomic.Bool
+	querier := followerAwareQuerier{read: read, write: write, closed: &closed}
+
+	ctx := context.Background()
+	require.NoError(t, querier.QueryFunc(ctx, nil, "SELECT 1"))
+
+	closed.Store(true)
+	require.ErrorIs(t, querier.QueryFunc(ctx, nil, "SELECT 1"), datastore.ErrDatastoreClosed)
+	require.ErrorIs(t, querier.QueryRowFunc(ctx, nil, "SELECT 1"), datastore.ErrDatastoreClosed)
+	require.ErrorIs(t, querier.ExecFunc(ctx, nil, "SELECT 1"), datastore.ErrDatastoreClosed)
+	require.Equal(t, 1, read.calls)
+	require.Zero(t, write.calls)
+}
@@ -0,0 +1,352 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// gcDeleteBatchSize is the maximum number of rows removed by each DELETE
+// statement of a GC pass, keeping the transactions small.
+const gcDeleteBatchSize = 1000
+
+// newGCCaveatsCollectedCounter creates the counter of the caveats deleted by
+// garbage collection.
+func newGCCaveatsCollectedCounter(metrics pool.Metrics) pool.Counter {
+	return metrics.Counter("spicedb_datastore_crdb_gc_caveats_collected_total",
+		"the number of unreferenced caveat definitions deleted by garbage collection")
+}
+
+// gcCaveatsCollectedCounter is registered with Prometheus on startup.
+var gcCaveatsCollectedCounter = newGCCaveatsCollectedCounter(pool.PrometheusMetrics())
+
+// RunGC synchronously performs a single garbage collection pass, deleting the
+// relationships and transaction metadata whose expiration has passed, and
+// returns the number of relationships deleted. The relationships and the
+// transaction metadata are deleted concurrently, subject to the limit set by
+// GCMaxConcurrentDeletes.
+//
+// CockroachDB removes these rows with row-level TTL jobs that run on a daily
+// schedule; RunGC performs the same deletions immediately, which allows tests
+// and operators to observe the effect of expiration without waiting for the
+// jobs. Like the TTL jobs, the deletions are performed without transaction
+// metadata, so they are not reported by Watch, and, by default, with the
+// background quality of service set by GCQualityOfService, so that they yield
+// to serving traffic.
+//
+// If enabled with GCCaveats, the pass also deletes the caveat definitions that
+// no relation of the stored schema requires and that passes have found
+// referenced by no relationship for longer than the GC window, and, if enabled
+// with GCDanglingRelationships, the relationships whose object types are no
+// longer defined, which are included in the number returned.
+//
+// A datastore created by NewReadOnlyCRDBDatastore does not collect garbage
+// and fails with ErrReadOnlyMode.
+func (cds *crdbDatastore) RunGC(ctx context.Context) (int64, error) {
+	if err := cds.checkOpen(); err != nil {
+		return 0, err
+	}
+	if cds.readReplica {
+		return 0, ErrReadOnlyMode
+	}
+
+	var deleted int64
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		deleted, err = cds.deleteExpired(gctx, cds.schema.RelationshipTableName, colExpiration)
+		if err != nil {
+			return fmt.Errorf("unable to delete expired relationships: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		if _, err := cds.deleteExpired(gctx, tableTransactionMetadata, colExpiresAt); err != nil {
+			return fmt.Errorf("unable to delete expired transaction metadata: %w", err)
+		}
+		return nil
+	})
+	var danglingDeleted int64
+	if cds.gcDanglingRelationships {
+		g.Go(func() error {
+			var err error
+			danglingDeleted, err = cds.deleteInBatches(gctx, cds.schema.RelationshipTableName, cds.danglingRelationships())
+			if err != nil {
+				return fmt.Errorf("unable to delete dangling relationships: %w", err)
+			}
+			return nil
+		})
+	}
+	if cds.gcCaveats {
+		g.Go(func() error {
+			collected, err := cds.deleteUnreferencedCaveats(gctx)
+			if err != nil {
+				return fmt.Errorf("unable to delete unreferenced caveats: %w", err)
+			}
+			if !cds.metricsDisabled {
+				cds.gcCaveatsCollected.Add(float64(collected))
+			}
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	return deleted + danglingDeleted, err
+}
+
+// GCWatermark returns the revision below which relationships, and their
+// history, may have been garbage collected: the current revision less the GC
+// window, rounded up to the revision quantization. This is the oldest
+// revision that can be read, as reported by MinimumValidRevision, published
+// for the coordination of external tooling, such as backups that must read at
+// a revision that remains available for the duration of the backup.
+//
+// A revision at or above the watermark remains readable until it is older
+// than the GC window, so a backup started at the watermark has no time left to
+// complete; backups should read at a more recent revision.
+func (cds *crdbDatastore) GCWatermark(ctx context.Context) (datastore.Revision, error) {
+	if err := cds.checkOpen(); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	watermark, err := cds.MinimumValidRevision(ctx)
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf("unable to compute the GC watermark: %w", err)
+	}
+	return watermark, nil
+}
+
+// GCBacklog returns the number of expired relationships that garbage
+// collection has yet to delete, counting up to limit, such that its cost is
+// bounded however large the backlog. It is zero when expiration is disabled.
+func (cds *crdbDatastore) GCBacklog(ctx context.Context, limit uint64) (int64, error) {
+	if err := cds.checkOpen(); err != nil {
+		return 0, err
+	}
+	if cds.schema.ExpirationDisabled {
+		return 0, nil
+	}
+
+	expiredRels := psql.Select("1").From(cds.schema.RelationshipTableName).Where(expired(colExpiration)).Limit(limit)
+	sql, args, err := psql.Select("count(*)").FromSelect(expiredRels, "expired").ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var backlog int64
+	if err := cds.readPool.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+		return row.Scan(&backlog)
+	}, sql, args...); err != nil {
+		return 0, fmt.Errorf("unable to count expired relationships: %w", err)
+	}
+	return backlog, nil
+}
+
+// expired matches the rows whose expiration column is before the current
+// time, which are deleted by garbage collection.
+func expired(expirationColumn string) sq.Sqlizer {
+	return sq.Expr(expirationColumn + " < now()")
+}
+
+// deleteExpired deletes, in batches, the rows of the table whose expiration
+// column is before the current time, returning the number of rows deleted.
+// Each batch is deleted in its own transaction, run with the GC quality of
+// service.
+func (cds *crdbDatastore) deleteExpired(ctx context.Context, table, expirationColumn string) (int64, error) {
+	return cds.deleteInBatches(ctx, table, expired(expirationColumn))
+}
+
+// deleteInBatches deletes, in batches, the rows of the table matching the
+// condition, returning the number of rows deleted. Each batch is deleted in
+// its own transaction, run with the GC quality of service.
+func (cds *crdbDatastore) deleteInBatches(ctx context.Context, table string, where sq.Sqlizer) (int64, error) {
+	if cds.readReplica {
+		return 0, ErrReadOnlyMode
+	}
+
+	sql, args, err := psql.Delete(table).
+		Where(where).
+		Suffix(fmt.Sprintf("LIMIT %d", gcDeleteBatchSize)).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		if err := cds.gcDeletes.Acquire(ctx, 1); err != nil {
+			return total, err
+		}
+
+		var deleted int64
+		err := cds.writePool.BeginFunc(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "SET LOCAL default_transaction_quality_of_service = '"+cds.gcQualityOfService+"'"); err != nil {
+				return fmt.Errorf("unable to set the GC quality of service: %w", err)
+			}
+
+			tag, err := tx.Exec(ctx, sql, args...)
+			deleted = tag.RowsAffected()
+			return err
+		})
+		cds.gcDeletes.Release(1)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		if deleted < gcDeleteBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// unreferencedCaveats tracks, for each caveat that GC passes have found
+// referenced by no relationship, when a pass first found it so. A caveat is
+// forgotten as soon as a pass finds it referenced again, or deletes it.
+//
+// The tracking is kept in memory, so a datastore that is restarted starts
+// tracking anew, which only ever delays the deletion of a caveat.
+type unreferencedCaveats struct {
+	sync.Mutex
+	since map[string]time.Time
+}
+
+// observe records that the named caveats, and only those, were found
+// unreferenced at now, and returns those of them first found unreferenced no
+// later than cutoff.
+func (uc *unreferencedCaveats) observe(names []string, now, cutoff time.Time) []string {
+	uc.Lock()
+	defer uc.Unlock()
+
+	since := make(map[string]time.Time, len(names))
+	var expired []string
+	for _, name := range names {
+		first, ok := uc.since[name]
+		if !ok {
+			first = now
+		}
+		since[name] = first
+		if !first.After(cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	uc.since = since
+	return expired
+}
+
+// forget stops tracking the named caveats, once they have been deleted.
+func (uc *unreferencedCaveats) forget(names []string) {
+	uc.Lock()
+	defer uc.Unlock()
+
+	for _, name := range names {
+		delete(uc.since, name)
+	}
+}
+
+// deleteUnreferencedCaveats deletes the caveats that no relation of the schema
+// stored at the head revision requires and that GC passes have found
+// referenced by no relationship for longer than the GC window, returning the
+// number of caveats deleted. The unreferenced caveats are found with a single
+// anti-join against the relationships, which the deletion repeats, so that a
+// caveat referenced since it was found unreferenced is kept. Reads at
+// revisions within the window still see the deleted caveats, as of their
+// revision.
+func (cds *crdbDatastore) deleteUnreferencedCaveats(ctx context.Context) (int64, error) {
+	if cds.readReplica {
+		return 0, ErrReadOnlyMode
+	}
+
+	required, err := cds.caveatsRequiredBySchema(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to load the caveats required by the schema: %w", err)
+	}
+
+	unreferenced := fmt.Sprintf(
+		"SELECT c.%[1]s FROM %[2]s AS c LEFT JOIN %[3]s AS r ON r.%[4]s = c.%[1]s "+
+			"WHERE r.%[4]s IS NULL AND c.%[1]s <> ALL($1)",
+		colCaveatName, tableCaveat, cds.schema.RelationshipTableName, colCaveatContextName,
+	)
+
+	if err := cds.gcDeletes.Acquire(ctx, 1); err != nil {
+		return 0, err
+	}
+	defer cds.gcDeletes.Release(1)
+
+	var found []string
+	if err := cds.writePool.QueryFunc(ctx, func(ctx context.Context, rows pgx.Rows) error {
+		var err error
+		found, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	}, unreferenced, required); err != nil {
+		return 0, fmt.Errorf("unable to find the unreferenced caveats: %w", err)
+	}
+
+	now := time.Now()
+	expired := cds.unreferencedCaveats.observe(found, now, now.Add(-cds.gcWindow))
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	sql := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s) AND %s = ANY($2) RETURNING %s",
+		tableCaveat, colCaveatName, unreferenced, colCaveatName, colCaveatName)
+
+	var deleted []string
+	err = cds.writePool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SET LOCAL default_transaction_quality_of_service = '"+cds.gcQualityOfService+"'"); err != nil {
+			return fmt.Errorf("unable to set the GC quality of service: %w", err)
+		}
+
+		rows, err := tx.Query(ctx, sql, required, expired)
+		if err != nil {
+			return err
+		}
+		deleted, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	cds.unreferencedCaveats.forget(deleted)
+	return int64(len(deleted)), nil
+}
+
+// caveatsRequiredBySchema returns the names of the caveats that the relations
+// of the namespace definitions stored at the head revision require of their
+// subjects, which are kept even if no relationship references them.
+func (cds *crdbDatastore) caveatsRequiredBySchema(ctx context.Context) ([]string, error) {
+	headRev, err := cds.HeadRevision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := cds.SnapshotReader(headRev).ListAllNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	seen := make(map[string]struct{})
+	for _, namespace := range namespaces {
+		for _, relation := range namespace.Definition.GetRelation() {
+			for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+				name := allowed.GetRequiredCaveat().GetCaveatName()
+				if _, ok := seen[name]; name == "" || ok {
+					continue
+				}
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,36 @@
+package crdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnreferencedCaveatsObserve(t *testing.T) {
+	var tracked unreferencedCaveats
+	start := time.Unix(1000, 0)
+	window := 10 * time.Second
+	observe := func(now time.Time, names ...string) []string {
+		return tracked.observe(names, now, now.Add(-window))
+	}
+
+	// Caveats first found unreferenced are kept for the window.
+	require.Empty(t, observe(start, "a", "b"))
+	require.Empty(t, observe(start.Add(5*time.Second), "a", "b", "c"))
+
+	// Once the window has passed since a caveat was first found unreferenced,
+	// it is returned, but not one found unreferenced later.
+	require.ElementsMatch(t, []string{"a", "b"}, observe(start.Add(window), "a", "b", "c"))
+
+	// A caveat found referenced again is forgotten, so its window starts anew
+	// once it is unreferenced again.
+	require.ElementsMatch(t, []string{"a"}, observe(start.Add(11*time.Second), "a"))
+	require.ElementsMatch(t, []string{"a"}, observe(start.Add(16*time.Second), "a", "b", "c"))
+	require.ElementsMatch(t, []string{"a", "b", "c"}, observe(start.Add(26*time.Second), "a", "b", "c"))
+
+	// A deleted caveat is forgotten, and a caveat of the same name defined
+	// anew is kept for the window.
+	tracked.forget([]string{"a"})
+	require.ElementsMatch(t, []string{"b", "c"}, observe(start.Add(27*time.Second), "a", "b", "c"))
+}
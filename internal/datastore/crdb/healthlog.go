@@ -0,0 +1,84 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// maxGCBacklog is the number of expired relationships at which the GC backlog
+// of the health summary stops counting, bounding the cost of each summary.
+const maxGCBacklog = 10 * gcDeleteBatchSize
+
+// healthSummary is the summary of the datastore's health logged every health
+// log interval.
+type healthSummary struct {
+	pools PoolStats
+
+	// gcBacklog is the number of expired relationships awaiting garbage
+	// collection, up to maxGCBacklog.
+	gcBacklog int64
+
+	// revisionAge is how long ago the latest advertised revision was current.
+	revisionAge time.Duration
+}
+
+// runHealthLog logs a summary of the datastore's health every interval until
+// the context is canceled.
+func runHealthLog(ctx context.Context, interval time.Duration, summarize func(context.Context) (healthSummary, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			summary, err := summarize(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Ctx(ctx).Warn().Err(err).Msg("unable to summarize the datastore health")
+				}
+				continue
+			}
+
+			log.Ctx(ctx).Info().
+				Int32("readConnsTotal", summary.pools.Read.Open).
+				Int32("readConnsIdle", summary.pools.Read.Idle).
+				Int32("readConnsAcquired", summary.pools.Read.Acquired).
+				Int32("writeConnsTotal", summary.pools.Write.Open).
+				Int32("writeConnsIdle", summary.pools.Write.Idle).
+				Int32("writeConnsAcquired", summary.pools.Write.Acquired).
+				Int64("gcBacklog", summary.gcBacklog).
+				Dur("revisionAge", summary.revisionAge).
+				Msg("datastore health")
+		}
+	}
+}
+
+// healthSummary summarizes the state of the pools, as reported by PoolStats,
+// the garbage awaiting collection, as reported by GCBacklog, and the age of
+// the latest advertised revision.
+func (cds *crdbDatastore) healthSummary(ctx context.Context) (healthSummary, error) {
+	summary := healthSummary{pools: cds.PoolStats()}
+
+	backlog, err := cds.GCBacklog(ctx, maxGCBacklog)
+	if err != nil {
+		return healthSummary{}, fmt.Errorf("unable to count the GC backlog: %w", err)
+	}
+	summary.gcBacklog = backlog
+
+	optimized, err := cds.OptimizedRevision(ctx)
+	if err != nil {
+		return healthSummary{}, fmt.Errorf("unable to compute the latest revision: %w", err)
+	}
+	if withTimestamp, ok := optimized.(revisions.WithTimestampRevision); ok {
+		summary.revisionAge = time.Since(time.Unix(0, withTimestamp.TimestampNanoSec()))
+	}
+
+	return summary, nil
+}
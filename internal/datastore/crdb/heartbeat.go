@@ -0,0 +1,27 @@
+package crdb
+
+import (
+	"context"
+	"time"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// runRevisionHeartbeat calls refresh every interval until the context is
+// canceled, logging any errors other than those caused by the cancellation.
+func runRevisionHeartbeat(ctx context.Context, interval time.Duration, refresh func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("revision heartbeat failed to refresh the optimized revision")
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRevisionHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var refreshes atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runRevisionHeartbeat(ctx, 5*time.Millisecond, func(context.Context) error {
+			refreshes.Add(1)
+			return errors.New("refresh failed")
+		})
+	}()
+
+	// Heartbeats continue after a failed refresh.
+	require.Eventually(t, func() bool { return refreshes.Load() >= 3 }, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "heartbeat did not stop after the context was canceled")
+	}
+}
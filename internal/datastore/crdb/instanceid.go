@@ -0,0 +1,84 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+var (
+	// queryReadInstanceID reads the instance ID, choosing the same one should
+	// several have been recorded.
+	queryReadInstanceID = fmt.Sprintf("SELECT %[1]s FROM %[2]s ORDER BY %[1]s LIMIT 1", colUniqueID, tableMetadata)
+
+	// queryCreateInstanceID records the instance ID $1 unless one has already
+	// been recorded. Concurrent creations conflict on the read of the table,
+	// so that, under serializable isolation, only one of them records its ID.
+	queryCreateInstanceID = fmt.Sprintf(
+		"INSERT INTO %[2]s (%[1]s) SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM %[2]s) ON CONFLICT DO NOTHING",
+		colUniqueID, tableMetadata,
+	)
+)
+
+// InstanceID returns the unique ID of the datastore's database, which is
+// recorded in the metadata table when the database is migrated, and is the
+// same for every process using the database. Should the ID be missing, such as
+// after the table was emptied, a new UUID is recorded in its place, unless the
+// datastore is read-only; concurrent processes all return the same new ID.
+func (cds *crdbDatastore) InstanceID(ctx context.Context) (string, error) {
+	if err := cds.checkOpen(); err != nil {
+		return "", err
+	}
+	if cached := cds.instanceID.Load(); cached != nil {
+		return *cached, nil
+	}
+
+	instanceID, err := cds.readInstanceID(ctx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if cds.readOnly.Load() {
+			return "", fmt.Errorf("unable to create the instance ID: %w", ErrReadOnlyMode)
+		}
+		instanceID, err = cds.createInstanceID(ctx)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to query unique ID: %w", err)
+	}
+
+	if cds.instanceID.CompareAndSwap(nil, &instanceID) {
+		cds.operationalLogger(log.Ctx(ctx)).Info().Str("instanceID", instanceID).Msg("loaded datastore instance ID")
+	}
+	return instanceID, nil
+}
+
+func (cds *crdbDatastore) readInstanceID(ctx context.Context) (string, error) {
+	var instanceID string
+	err := cds.readPool.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+		return row.Scan(&instanceID)
+	}, queryReadInstanceID)
+	return instanceID, err
+}
+
+// createInstanceID records a new instance ID unless one already exists, and
+// returns the one recorded.
+func (cds *crdbDatastore) createInstanceID(ctx context.Context) (string, error) {
+	if cds.readReplica {
+		return "", ErrReadOnlyMode
+	}
+
+	var instanceID string
+	err := cds.writePool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, queryCreateInstanceID, uuid.NewString()); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, queryReadInstanceID).Scan(&instanceID)
+	})
+	return instanceID, err
+}
+
+var _ datastore.InstanceIdentifiedDatastore = &crdbDatastore{}
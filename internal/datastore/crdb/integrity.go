@@ -0,0 +1,149 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// maxIntegrityViolations is the maximum number of violations listed by an
+// IntegrityReport, so that the memory used to check a graph with many of them
+// stays bounded. Further violations are only counted.
+const maxIntegrityViolations = 1000
+
+// IntegrityViolation is a relationship that does not conform to the schema,
+// as found by CheckReferentialIntegrity.
+type IntegrityViolation struct {
+	Relationship tuple.Relationship
+
+	// Reason describes the part of the relationship missing from the schema.
+	Reason string
+}
+
+// IntegrityReport is the result of CheckReferentialIntegrity.
+type IntegrityReport struct {
+	// Revision is the revision at which the relationships were checked.
+	Revision datastore.Revision
+
+	// Checked is the number of relationships checked.
+	Checked uint64
+
+	// ViolationCount is the number of relationships that do not conform to
+	// the schema.
+	ViolationCount uint64
+
+	// Violations lists the first of the relationships that do not conform to
+	// the schema, in resource order, up to a thousand of them.
+	Violations []IntegrityViolation
+}
+
+// Truncated returns whether more violations were found than are listed.
+func (ir IntegrityReport) Truncated() bool {
+	return ir.ViolationCount > uint64(len(ir.Violations))
+}
+
+// CheckReferentialIntegrity checks every relationship against the schema,
+// reporting those whose resource or subject type is not defined, whose
+// resource relation is not a relation of its resource type, or whose subject
+// relation is neither the ellipsis nor a relation or permission of its
+// subject type. Unlike FindDanglingRelationships, relations are checked too.
+//
+// The relationships and the schema are read at the optimized revision, so
+// that the check can be served by follower reads. The relationships are read
+// a page at a time, as ExportRelationships does, and at most a thousand
+// violations are listed, so the memory used stays bounded however large the
+// graph is. Every relationship is read, so the check is intended to be run
+// offline or against a read replica.
+func (cds *crdbDatastore) CheckReferentialIntegrity(ctx context.Context) (IntegrityReport, error) {
+	if err := cds.checkOpen(); err != nil {
+		return IntegrityReport{}, err
+	}
+
+	revision, err := cds.OptimizedRevision(ctx)
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+
+	reader := cds.SnapshotReader(revision)
+	namespaces, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return IntegrityReport{}, fmt.Errorf("unable to read the schema: %w", err)
+	}
+	schema := newSchemaRelations(namespaces)
+
+	report := IntegrityReport{Revision: revision}
+	var after options.Cursor
+	for {
+		limit := cds.exportBatchSize()
+		batch, err := readExportBatch(ctx, reader, after, limit)
+		if err != nil {
+			return IntegrityReport{}, fmt.Errorf("unable to read relationships: %w", err)
+		}
+
+		for _, rel := range batch {
+			report.Checked++
+			reason := schema.violation(rel)
+			if reason == "" {
+				continue
+			}
+
+			report.ViolationCount++
+			if len(report.Violations) < maxIntegrityViolations {
+				report.Violations = append(report.Violations, IntegrityViolation{Relationship: rel, Reason: reason})
+			}
+		}
+
+		if uint64(len(batch)) < limit {
+			return report, nil
+		}
+		after = options.ToCursor(batch[len(batch)-1])
+	}
+}
+
+// schemaRelations holds, for each object type defined by the schema, whether
+// each of its relations is a permission.
+type schemaRelations map[string]map[string]bool
+
+func newSchemaRelations(namespaces []datastore.RevisionedNamespace) schemaRelations {
+	schema := make(schemaRelations, len(namespaces))
+	for _, ns := range namespaces {
+		relations := make(map[string]bool, len(ns.Definition.Relation))
+		for _, relation := range ns.Definition.Relation {
+			relations[relation.Name] = relation.UsersetRewrite != nil
+		}
+		schema[ns.Definition.Name] = relations
+	}
+	return schema
+}
+
+// violation returns the reason the relationship does not conform to the
+// schema, or the empty string if it does.
+func (sr schemaRelations) violation(rel tuple.Relationship) string {
+	resourceRelations, ok := sr[rel.Resource.ObjectType]
+	if !ok {
+		return fmt.Sprintf("resource type %s is not defined", rel.Resource.ObjectType)
+	}
+
+	isPermission, ok := resourceRelations[rel.Resource.Relation]
+	if !ok {
+		return fmt.Sprintf("relation %s is not defined on resource type %s", rel.Resource.Relation, rel.Resource.ObjectType)
+	}
+	if isPermission {
+		return fmt.Sprintf("%s of resource type %s is a permission, not a relation", rel.Resource.Relation, rel.Resource.ObjectType)
+	}
+
+	subjectRelations, ok := sr[rel.Subject.ObjectType]
+	if !ok {
+		return fmt.Sprintf("subject type %s is not defined", rel.Subject.ObjectType)
+	}
+
+	if rel.Subject.Relation != tuple.Ellipsis {
+		if _, ok := subjectRelations[rel.Subject.Relation]; !ok {
+			return fmt.Sprintf("relation %s is not defined on subject type %s", rel.Subject.Relation, rel.Subject.ObjectType)
+		}
+	}
+	return ""
+}
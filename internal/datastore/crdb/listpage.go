@@ -0,0 +1,148 @@
+package crdb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ErrInvalidListCursor is returned by ListRelationshipsPage when the cursor
+// cannot be decoded.
+var ErrInvalidListCursor = errors.New("invalid list relationships cursor")
+
+// listCursor is the decoded form of the cursor returned by
+// ListRelationshipsPage.
+type listCursor struct {
+	// Revision is the revision at which every page is read.
+	Revision string `json:"r"`
+
+	// After is the last relationship of the previous page, without its caveat
+	// or expiration.
+	After string `json:"a"`
+}
+
+func encodeListCursor(revision datastore.Revision, after tuple.Relationship) (string, error) {
+	encoded, err := json.Marshal(listCursor{
+		Revision: revision.String(),
+		After:    tuple.StringWithoutCaveatOrExpiration(after),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func (cds *crdbDatastore) decodeListCursor(cursor string) (datastore.Revision, options.Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return datastore.NoRevision, nil, fmt.Errorf("%w: %w", ErrInvalidListCursor, err)
+	}
+
+	var lc listCursor
+	if err := json.Unmarshal(decoded, &lc); err != nil {
+		return datastore.NoRevision, nil, fmt.Errorf("%w: %w", ErrInvalidListCursor, err)
+	}
+
+	revision, err := cds.RevisionFromString(lc.Revision)
+	if err != nil {
+		return datastore.NoRevision, nil, fmt.Errorf("%w: %w", ErrInvalidListCursor, err)
+	}
+
+	after, err := tuple.Parse(lc.After)
+	if err != nil {
+		return datastore.NoRevision, nil, fmt.Errorf("%w: %w", ErrInvalidListCursor, err)
+	}
+	return revision, options.ToCursor(after), nil
+}
+
+// ListRelationshipsPage returns a page of at most limit of the relationships
+// matching the filter, in resource order, along with the cursor from which to
+// read the next page. The cursor is empty once the last page has been read.
+//
+// The first page is read at the current head revision, which is recorded in
+// the cursor along with the last relationship of the page, so that every page
+// is read at the same revision and relationships written while paging neither
+// appear nor shift the pages. Paging therefore fails, with a stale revision
+// error, once the first page falls outside the GC window. The same filter must
+// be given for every page. The limit is capped by MaxListResults.
+func (cds *crdbDatastore) ListRelationshipsPage(
+	ctx context.Context,
+	filter datastore.RelationshipsFilter,
+	cursor string,
+	limit uint64,
+) ([]tuple.Relationship, string, error) {
+	if limit == 0 {
+		return nil, "", errors.New("list relationships page limit must be greater than zero")
+	}
+	if cds.maxListResults > 0 && limit > cds.maxListResults {
+		limit = cds.maxListResults
+	}
+
+	var revision datastore.Revision
+	var after options.Cursor
+	if cursor == "" {
+		var err error
+		revision, err = cds.HeadRevision(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		var err error
+		revision, after, err = cds.decodeListCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err := cds.CheckRevision(ctx, revision); err != nil {
+			return nil, "", err
+		}
+	}
+
+	iter, err := cds.SnapshotReader(revision).QueryRelationships(ctx, filter,
+		options.WithSort(options.ByResource),
+		options.WithLimit(&limit),
+		options.WithAfter(after),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, err := datastore.IteratorToSlice(iter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if uint64(len(page)) < limit {
+		return page, "", nil
+	}
+
+	next, err := encodeListCursor(revision, page[len(page)-1])
+	if err != nil {
+		return nil, "", err
+	}
+	return page, next, nil
+}
+
+// ListRelationshipsWithCaveatPage returns a page of the relationships, of any
+// resource type, whose caveat is the named caveat, such as to audit what
+// references a caveat before it is deleted. It pages as ListRelationshipsPage
+// does, with the same caveat name given for every page.
+func (cds *crdbDatastore) ListRelationshipsWithCaveatPage(
+	ctx context.Context,
+	caveatName string,
+	cursor string,
+	limit uint64,
+) ([]tuple.Relationship, string, error) {
+	if caveatName == "" {
+		return nil, "", errors.New("caveat name must not be empty")
+	}
+	return cds.ListRelationshipsPage(ctx, datastore.RelationshipsFilter{OptionalCaveatName: caveatName}, cursor, limit)
+}
+
+var _ datastore.PagedRelationshipsDatastore = &crdbDatastore{}
@@ -0,0 +1,32 @@
+package crdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestListCursorRoundTrip(t *testing.T) {
+	cds := &crdbDatastore{CommonDecoder: revisions.CommonDecoder{Kind: revisions.HybridLogicalClock}}
+
+	revision, err := revisions.HLCRevisionFromString("1703283409994227985.0000000004")
+	require.NoError(t, err)
+
+	after := tuple.MustParse("document:doc1#viewer@user:tom[somecaveat]")
+	cursor, err := encodeListCursor(revision, after)
+	require.NoError(t, err)
+
+	decodedRevision, decodedAfter, err := cds.decodeListCursor(cursor)
+	require.NoError(t, err)
+	require.True(t, revision.Equal(decodedRevision))
+	require.Equal(t, tuple.StringWithoutCaveatOrExpiration(after), tuple.StringWithoutCaveatOrExpiration(*options.ToRelationship(decodedAfter)))
+
+	for _, invalid := range []string{"not base64!", "bm90IGpzb24", cursor[:len(cursor)-4]} {
+		_, _, err := cds.decodeListCursor(invalid)
+		require.ErrorIs(t, err, ErrInvalidListCursor)
+	}
+}
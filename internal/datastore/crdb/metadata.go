@@ -0,0 +1,189 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// metadataColumnRegex restricts the names of metadata columns to unquoted
+// identifiers, so that they can be safely included in statements.
+var metadataColumnRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// ErrRelationshipNotFound is returned by RelationshipMetadata when the
+// relationship does not exist at the revision.
+var ErrRelationshipNotFound = errors.New("relationship not found")
+
+// relationshipColumns are the columns of the relationships tables, which
+// cannot be declared as metadata columns.
+var relationshipColumns = []string{
+	colNamespace,
+	colObjectID,
+	colRelation,
+	colUsersetNamespace,
+	colUsersetObjectID,
+	colUsersetRelation,
+	colCaveatContextName,
+	colCaveatContext,
+	colExpiration,
+	colTimestamp,
+	colIntegrityKeyID,
+	colIntegrityHash,
+}
+
+func validateMetadataColumns(columns []string) error {
+	for i, column := range columns {
+		if !metadataColumnRegex.MatchString(column) {
+			return fmt.Errorf("metadata column %q must be a lowercase identifier of at most 63 characters", column)
+		}
+		if slices.Contains(relationshipColumns, column) {
+			return fmt.Errorf("metadata column %q conflicts with a column of the relationships table", column)
+		}
+		if slices.Contains(columns[:i], column) {
+			return fmt.Errorf("metadata column %q is declared more than once", column)
+		}
+	}
+	return nil
+}
+
+// queryTableColumnNames lists the columns of a table of the database.
+const queryTableColumnNames = `SELECT column_name FROM information_schema.columns WHERE table_schema = 'public' AND table_name = $1`
+
+// checkMetadataColumns ensures that the metadata columns have been added to
+// the relationships table. The datastore does not add them itself, as it does
+// not change the schema outside of migrations.
+func (cds *crdbDatastore) checkMetadataColumns(ctx context.Context) error {
+	if len(cds.metadataColumns) == 0 {
+		return nil
+	}
+
+	var existing []string
+	if err := cds.readPool.QueryFunc(ctx, func(ctx context.Context, rows pgx.Rows) error {
+		var err error
+		existing, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	}, queryTableColumnNames, cds.schema.RelationshipTableName); err != nil {
+		return fmt.Errorf("unable to load the columns of the %s table: %w", cds.schema.RelationshipTableName, err)
+	}
+
+	var missing []string
+	for _, column := range cds.metadataColumns {
+		if !slices.Contains(existing, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("metadata columns %v do not exist in the %s table: add each with `ALTER TABLE %s ADD COLUMN <column> STRING` before declaring it", missing, cds.schema.RelationshipTableName, cds.schema.RelationshipTableName)
+	}
+	return nil
+}
+
+// metadataValues returns the values of the metadata columns set for the write
+// via datastore.WithRelationshipMetadata, with nil for the columns without a
+// value.
+func metadataValues(ctx context.Context, columns []string) ([]any, error) {
+	metadata := datastore.RelationshipMetadata(ctx)
+	for key := range metadata {
+		if !slices.Contains(columns, key) {
+			return nil, fmt.Errorf("relationship metadata %q is not a configured metadata column", key)
+		}
+	}
+
+	values := make([]any, 0, len(columns))
+	for _, column := range columns {
+		if value, ok := metadata[column]; ok {
+			values = append(values, value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+	return values, nil
+}
+
+// upsertTupleSuffix returns the ON CONFLICT clause by which touching an
+// existing relationship in the table updates its caveat, expiration, integrity
+// and metadata columns, if any of them have changed.
+func upsertTupleSuffix(table string, withIntegrity bool, metadataColumns []string) string {
+	updated := []string{colCaveatContextName, colCaveatContext}
+	if withIntegrity {
+		updated = append(updated, colIntegrityKeyID, colIntegrityHash)
+	}
+	updated = append(updated, colExpiration)
+	updated = append(updated, metadataColumns...)
+
+	set := []string{colTimestamp + " = now()"}
+	for _, column := range updated {
+		set = append(set, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+
+	changed := make([]string, 0, 3+len(metadataColumns))
+	for _, column := range []string{colCaveatContextName, colCaveatContext, colExpiration} {
+		changed = append(changed, fmt.Sprintf("%s.%s <> excluded.%s", table, column, column))
+	}
+	for _, column := range metadataColumns {
+		changed = append(changed, fmt.Sprintf("%s.%s IS DISTINCT FROM excluded.%s", table, column, column))
+	}
+
+	return fmt.Sprintf(
+		"ON CONFLICT (%s,%s,%s,%s,%s,%s) DO UPDATE SET %s WHERE (%s)",
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+		strings.Join(set, ", "),
+		strings.Join(changed, " OR "),
+	)
+}
+
+// RelationshipMetadata returns the values of the metadata columns, declared
+// with WithMetadataColumns, of the relationship at the revision. Columns
+// without a value are omitted.
+func (cds *crdbDatastore) RelationshipMetadata(ctx context.Context, revision datastore.Revision, rel tuple.Relationship) (map[string]string, error) {
+	if err := cds.checkOpen(); err != nil {
+		return nil, err
+	}
+	if len(cds.metadataColumns) == 0 {
+		return nil, errors.New("no metadata columns have been configured")
+	}
+
+	reader := cds.snapshotReader(revision)
+	sql, args, err := reader.addFromToQuery(psql.Select(cds.metadataColumns...), cds.schema.RelationshipTableName).
+		Where(exactRelationshipClause(rel)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*string, len(cds.metadataColumns))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	if err := reader.query.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+		return row.Scan(dest...)
+	}, sql, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrRelationshipNotFound, tuple.StringWithoutCaveatOrExpiration(rel))
+		}
+		return nil, fmt.Errorf("unable to read relationship metadata: %w", err)
+	}
+
+	metadata := make(map[string]string, len(values))
+	for i, value := range values {
+		if value != nil {
+			metadata[cds.metadataColumns[i]] = *value
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,55 @@
+package crdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestValidateMetadataColumns(t *testing.T) {
+	require.NoError(t, validateMetadataColumns(nil))
+	require.NoError(t, validateMetadataColumns([]string{"source", "source_tag2"}))
+
+	for _, columns := range [][]string{
+		{"Source"},
+		{"source; DROP TABLE relation_tuple"},
+		{"2source"},
+		{""},
+		{colCaveatContext},
+		{"source", "source"},
+	} {
+		require.Error(t, validateMetadataColumns(columns), columns)
+	}
+}
+
+func TestUpsertTupleSuffix(t *testing.T) {
+	require.Equal(t,
+		"ON CONFLICT (namespace,object_id,relation,userset_namespace,userset_object_id,userset_relation) DO UPDATE SET timestamp = now(), caveat_name = excluded.caveat_name, caveat_context = excluded.caveat_context, expires_at = excluded.expires_at WHERE (relation_tuple.caveat_name <> excluded.caveat_name OR relation_tuple.caveat_context <> excluded.caveat_context OR relation_tuple.expires_at <> excluded.expires_at)",
+		upsertTupleSuffixWithoutIntegrity,
+	)
+
+	require.Equal(t,
+		"ON CONFLICT (namespace,object_id,relation,userset_namespace,userset_object_id,userset_relation) DO UPDATE SET timestamp = now(), caveat_name = excluded.caveat_name, caveat_context = excluded.caveat_context, expires_at = excluded.expires_at, source = excluded.source WHERE (relation_tuple.caveat_name <> excluded.caveat_name OR relation_tuple.caveat_context <> excluded.caveat_context OR relation_tuple.expires_at <> excluded.expires_at OR relation_tuple.source IS DISTINCT FROM excluded.source)",
+		upsertTupleSuffix(tableTuple, false, []string{"source"}),
+	)
+}
+
+func TestMetadataValues(t *testing.T) {
+	columns := []string{"source", "tag"}
+
+	values, err := metadataValues(context.Background(), columns)
+	require.NoError(t, err)
+	require.Equal(t, []any{nil, nil}, values)
+
+	ctx := datastore.WithRelationshipMetadata(context.Background(), map[string]string{"tag": "imported"})
+	values, err = metadataValues(ctx, columns)
+	require.NoError(t, err)
+	require.Equal(t, []any{nil, "imported"}, values)
+
+	ctx = datastore.WithRelationshipMetadata(context.Background(), map[string]string{"unknown": "value"})
+	_, err = metadataValues(ctx, columns)
+	require.Error(t, err)
+}
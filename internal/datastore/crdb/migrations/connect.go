@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// defaultConnectRetryTimeout is the default total time for which the
+	// driver retries its initial connection.
+	defaultConnectRetryTimeout = 30 * time.Second
+
+	connectRetryInitialInterval = 500 * time.Millisecond
+	connectRetryMaxInterval     = 5 * time.Second
+)
+
+// WithConnectRetryTimeout sets the total time for which the driver retries
+// its initial connection to the database, with exponential backoff, before
+// giving up with an error aggregating the failures of each attempt. This
+// allows the driver to be created while the database is still starting, as
+// during an orchestrated startup. Connections rejected by the database, such
+// as for invalid credentials, are not retried. A timeout of zero makes a
+// single attempt.
+//
+// By default, the connection is retried for 30 seconds.
+func WithConnectRetryTimeout(timeout time.Duration) DriverOption {
+	return func(do *driverOptions) { do.connectRetryTimeout = timeout }
+}
+
+// WithDriverConnectTimeout bounds each attempt to connect to the database, so
+// that connecting to an unreachable address, whose packets are dropped rather
+// than refused, fails once the timeout elapses with a ConnectTimeoutError
+// rather than hanging. Attempts that time out are retried per
+// WithConnectRetryTimeout.
+//
+// By default, an attempt is only bounded by the connect_timeout parameter of
+// the connection string, if any.
+func WithDriverConnectTimeout(timeout time.Duration) DriverOption {
+	return func(do *driverOptions) { do.connectTimeout = timeout }
+}
+
+// ConnectTimeoutError is returned when an attempt to connect to the database
+// does not complete within the timeout set by WithDriverConnectTimeout.
+type ConnectTimeoutError struct {
+	// Timeout is the timeout of the attempt.
+	Timeout time.Duration
+
+	err error
+}
+
+func (err ConnectTimeoutError) Error() string {
+	return fmt.Sprintf("timed out connecting to the database after %s: %v", err.Timeout, err.err)
+}
+
+func (err ConnectTimeoutError) Unwrap() error {
+	return err.err
+}
+
+// connect connects with the configuration, retrying with backoff until the
+// context is done if a connection retry timeout is set.
+func (do driverOptions) connect(ctx context.Context, connConfig *pgx.ConnConfig) (*pgx.Conn, error) {
+	if do.connectRetryTimeout <= 0 {
+		return do.connectOnce(ctx, connConfig)
+	}
+
+	retryBackoff := backoff.NewExponentialBackOff()
+	retryBackoff.InitialInterval = connectRetryInitialInterval
+	retryBackoff.MaxInterval = connectRetryMaxInterval
+	retryBackoff.MaxElapsedTime = 0
+	retryBackoff.Reset()
+
+	var attemptErrs []error
+	for attempt := 1; ; attempt++ {
+		conn, err := do.connectOnce(ctx, connConfig)
+		if err == nil {
+			return conn, nil
+		}
+		attemptErrs = append(attemptErrs, fmt.Errorf("attempt %d: %w", attempt, err))
+
+		// The database is reachable but rejected the connection.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			return nil, errors.Join(attemptErrs...)
+		}
+
+		timer := time.NewTimer(retryBackoff.NextBackOff())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("unable to connect after %d attempts: %w", attempt, errors.Join(attemptErrs...))
+		case <-timer.C:
+		}
+	}
+}
+
+// connectOnce makes a single attempt to connect, bounded by the connect
+// timeout if one is set.
+func (do driverOptions) connectOnce(ctx context.Context, connConfig *pgx.ConnConfig) (*pgx.Conn, error) {
+	if do.connectTimeout <= 0 {
+		return pgx.ConnectConfig(ctx, connConfig)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, do.connectTimeout)
+	defer cancel()
+
+	conn, err := pgx.ConnectConfig(attemptCtx, connConfig)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return nil, ConnectTimeoutError{Timeout: do.connectTimeout, err: err}
+	}
+	return conn, err
+}
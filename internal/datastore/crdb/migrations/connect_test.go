@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableURL refuses connections, as a database that is still starting.
+const unreachableURL = "postgres://root@127.0.0.1:1/spicedb?sslmode=disable&connect_timeout=1"
+
+func TestConnectRetryTimeout(t *testing.T) {
+	started := time.Now()
+	_, err := NewCRDBDriver(unreachableURL, WithConnectRetryTimeout(1500*time.Millisecond))
+	require.Error(t, err)
+	require.GreaterOrEqual(t, time.Since(started), 1500*time.Millisecond)
+	require.Less(t, time.Since(started), 10*time.Second)
+
+	// The error aggregates the attempts.
+	require.ErrorContains(t, err, "unable to connect after")
+	require.ErrorContains(t, err, "attempt 1:")
+	require.ErrorContains(t, err, "attempt 2:")
+}
+
+func TestConnectWithoutRetries(t *testing.T) {
+	_, err := NewCRDBDriver(unreachableURL, WithConnectRetryTimeout(0))
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "attempt")
+}
+
+func TestConnectRetryRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := NewCRDBDriverContext(ctx, unreachableURL)
+	require.ErrorContains(t, err, "unable to connect after")
+	require.Less(t, time.Since(started), 5*time.Second)
+}
+
+func TestConnectTimeout(t *testing.T) {
+	// The listener accepts connections but never responds, as a black-holed
+	// address does.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	url := "postgres://root@" + listener.Addr().String() + "/spicedb?sslmode=disable"
+
+	started := time.Now()
+	driver, err := NewCRDBDriver(url, WithDriverConnectTimeout(200*time.Millisecond), WithConnectRetryTimeout(0))
+	require.Less(t, time.Since(started), 5*time.Second)
+	require.ErrorContains(t, err, "unable to instantiate CRDBDriver")
+
+	var timeoutErr ConnectTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, 200*time.Millisecond, timeoutErr.Timeout)
+
+	require.Nil(t, driver)
+	require.NoError(t, driver.Close(context.Background()))
+}
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultDatabase is the database that exists in every CockroachDB cluster,
+// to which the driver connects to create the target database.
+const defaultDatabase = "defaultdb"
+
+// CreateDatabaseIfNotExists makes the driver create the database of the
+// connection string, if it does not exist, before connecting to it. The
+// database is created through a connection, with the same credentials, to the
+// cluster's default database, so the user must be allowed to create
+// databases.
+//
+// This is intended to streamline development and test environments, and is
+// discouraged in production, where the database should be provisioned, with
+// its zone configuration and privileges, ahead of time. The connection string
+// must name the database, which is created with exactly that name, quoted as
+// an identifier. By default, the database must already exist.
+func CreateDatabaseIfNotExists() DriverOption {
+	return func(do *driverOptions) { do.createDatabase = true }
+}
+
+// createDatabaseIfNotExists creates the database of the connection
+// configuration, if it does not exist, through a connection made with connect.
+func createDatabaseIfNotExists(ctx context.Context, connConfig *pgx.ConnConfig, connect func(context.Context, *pgx.ConnConfig) (*pgx.Conn, error)) error {
+	name := connConfig.Database
+	if name == "" {
+		return errors.New("the connection string must name the database to create")
+	}
+	if name == defaultDatabase {
+		return nil
+	}
+
+	defaultConfig := connConfig.Copy()
+	defaultConfig.Database = defaultDatabase
+	conn, err := connect(ctx, defaultConfig)
+	if err != nil {
+		return fmt.Errorf("unable to connect to the %s database: %w", defaultDatabase, err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	if _, err := conn.Exec(ctx, createDatabaseStatement(name)); err != nil {
+		return fmt.Errorf("unable to create database %s: %w", name, err)
+	}
+	return nil
+}
+
+// createDatabaseStatement returns the statement creating the named database if
+// it does not exist.
+func createDatabaseStatement(name string) string {
+	return "CREATE DATABASE IF NOT EXISTS " + pgx.Identifier{name}.Sanitize()
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDatabaseIfNotExistsWithoutName(t *testing.T) {
+	// The connection string is rejected before any connection is made.
+	_, err := NewCRDBDriver("postgres://root@localhost:1/?sslmode=disable", CreateDatabaseIfNotExists())
+	require.ErrorContains(t, err, "must name the database")
+}
+
+func TestCreateDatabaseStatement(t *testing.T) {
+	testCases := []struct {
+		name              string
+		expectedStatement string
+	}{
+		{"spicedb", `CREATE DATABASE IF NOT EXISTS "spicedb"`},
+		{"Spicedb", `CREATE DATABASE IF NOT EXISTS "Spicedb"`},
+		{"spice-db", `CREATE DATABASE IF NOT EXISTS "spice-db"`},
+		{`spicedb"; DROP DATABASE defaultdb; --`, `CREATE DATABASE IF NOT EXISTS "spicedb""; DROP DATABASE defaultdb; --"`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedStatement, createDatabaseStatement(tc.name))
+		})
+	}
+}
@@ -4,55 +4,405 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
 	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/migrate"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 )
 
 const (
 	errUnableToInstantiate = "unable to instantiate CRDBDriver: %w"
 
-	postgresMissingTableErrorCode = "42P01"
+	// The queries of the version tables are formatted with the (possibly
+	// prefixed) name of the table, as returned by PrefixedTable.
+	queryLoadVersion   = "SELECT version_num from %s"
+	queryWriteVersion  = "UPDATE %s SET version_num=$1 WHERE version_num=$2"
+	queryForceVersion  = "UPDATE %s SET version_num=$1"
+	queryInsertVersion = "INSERT INTO %s (version_num) VALUES ($1)"
 
-	queryLoadVersion  = "SELECT version_num from schema_version"
-	queryWriteVersion = "UPDATE schema_version SET version_num=$1 WHERE version_num=$2"
+	// queryCreateVersionTable creates the version table of a prefixed driver,
+	// as the initial migration only creates the unprefixed one.
+	queryCreateVersionTable = `CREATE TABLE IF NOT EXISTS %s (
+		version_num VARCHAR NOT NULL
+	);`
+
+	// queryBootstrapVersion records the first version in a version table that
+	// was created without a row.
+	queryBootstrapVersion = "INSERT INTO %[1]s (version_num) SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM %[1]s)"
+
+	// queryCreateCheckpointTable creates the table of the completed phases of
+	// phased migrations. It is run by the add-migration-checkpoint-table
+	// migration and, for a prefixed driver, with its version table.
+	queryCreateCheckpointTable = `CREATE TABLE IF NOT EXISTS %[1]s (
+		version VARCHAR NOT NULL,
+		phase VARCHAR NOT NULL,
+		completed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		CONSTRAINT pk_%[1]s PRIMARY KEY (version, phase)
+	);`
+	// queryCreateHistoryTable creates the table of the migrations applied to
+	// the database. It is run by the first migration, when it writes its
+	// version, and by the add-version-history-table migration for databases
+	// whose first migration ran before the table existed.
+	queryCreateHistoryTable = `CREATE TABLE IF NOT EXISTS %[1]s (
+		version VARCHAR NOT NULL,
+		replaced VARCHAR NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		duration_ms INT8 NOT NULL DEFAULT 0,
+		CONSTRAINT pk_%[1]s PRIMARY KEY (applied_at, version)
+	);`
+	queryTableExists   = "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1)"
+	queryInsertHistory = "INSERT INTO %s (version, replaced, duration_ms) VALUES ($1, $2, $3)"
+	queryLoadHistory   = "SELECT version, replaced, applied_at, duration_ms FROM %s ORDER BY applied_at, version"
+
+	// queryPruneHistory deletes the entries other than the latest $2 and those
+	// of the current version, $1.
+	queryPruneHistory = `DELETE FROM %[1]s WHERE version <> $1 AND (applied_at, version) NOT IN (
+		SELECT applied_at, version FROM %[1]s ORDER BY applied_at DESC, version DESC LIMIT $2
+	)`
+
+	queryInsertSeedNamespace = "INSERT INTO namespace_config (namespace, serialized_config) VALUES ($1, $2) ON CONFLICT (namespace) DO NOTHING"
+
+	queryLoadCompletedPhases = "SELECT phase FROM %s WHERE version = $1"
+	queryMarkPhaseCompleted  = "UPSERT INTO %s (version, phase) VALUES ($1, $2)"
+	queryClearPhases         = "DELETE FROM %s WHERE version = $1"
 )
 
 // CRDBDriver implements a schema migration facility for use in SpiceDB's CRDB
 // datastore.
 type CRDBDriver struct {
-	db *pgx.Conn
+	db                 *pgx.Conn
+	ownsConn           bool
+	seedNamespaces     []*core.NamespaceDefinition
+	versionTablePrefix string
+	strictVersion      bool
+	closed             atomic.Bool
+
+	regressionFactor float64
+	historyRetention int
+	txMaxRetries     int
+
+	timeMigrations bool
+	reportTiming   func(migrate.MigrationTiming)
+}
+
+type driverOptions struct {
+	seedNamespaces     []*core.NamespaceDefinition
+	queryExecMode      pgx.QueryExecMode
+	versionTablePrefix string
+	createDatabase     bool
+	strictVersion      bool
+
+	connectTimeout      time.Duration
+	connectRetryTimeout time.Duration
+	regressionFactor    float64
+	historyRetention    int
+	txMaxRetries        int
+
+	timeMigrations bool
+	reportTiming   func(migrate.MigrationTiming)
+
+	clientCertFile, clientKeyFile string
+	rootCAFile                    string
+
+	legacyVersionTable   string
+	legacyVersionMapping map[string]string
+}
+
+// DriverOption configures optional behavior of a CRDBDriver.
+type DriverOption func(*driverOptions)
+
+// WithSeedNamespaces loads the given namespace definitions into the datastore
+// once it has been migrated to the head revision, skipping any namespace that
+// already exists. This is intended for development and test environments;
+// by default, nothing is seeded.
+func WithSeedNamespaces(namespaces ...*core.NamespaceDefinition) DriverOption {
+	return func(do *driverOptions) { do.seedNamespaces = namespaces }
+}
+
+// WithDriverQueryExecMode sets the protocol pgx uses to execute the
+// migration queries, overriding any `default_query_exec_mode` given in the
+// connection string. See crdb.WithQueryExecMode for the mode required by each
+// connection pooler topology.
+//
+// By default, the mode is taken from the connection string or pgx's default.
+func WithDriverQueryExecMode(mode pgx.QueryExecMode) DriverOption {
+	return func(do *driverOptions) { do.queryExecMode = mode }
+}
+
+// WithVersionTablePrefix prefixes the names of the tables in which the driver
+// records the migration of the database, such as `tenant1_schema_version` for
+// the prefix `tenant1_`, so that they do not collide with version tables of
+// the same name kept in the database by other tools. The names are those
+// returned by PrefixedTable. The driver creates the prefixed version table when
+// the first migration is recorded, leaving the unprefixed table created by the
+// initial migration at the empty version. A datastore reading a prefixed
+// database must be created with crdb.MigrationTablePrefix and the same prefix.
+//
+// Only the version tables are prefixed. Prefixing the tables of the datastore
+// is not supported: the migrations create them under their usual names, and
+// the cockroachdb migration engine rejects a RunnerOptions.TablePrefix, so the
+// prefix does not allow several SpiceDB schemas to share a database, each of
+// which needs a database of its own.
+//
+// The prefix must be at most 32 lowercase letters, digits or underscores, and
+// must not begin with a digit. By default, the tables are not prefixed.
+func WithVersionTablePrefix(prefix string) DriverOption {
+	return func(do *driverOptions) { do.versionTablePrefix = prefix }
+}
+
+// WithStrictVersionTable makes Version fail with a MissingVersionTableError
+// when the version table does not exist, rather than reporting the database
+// as fresh. This protects restore workflows, in which a missing version table
+// indicates an incomplete restore or corruption, from re-running the
+// migrations over existing data. A strict driver cannot migrate a fresh
+// database.
+//
+// By default, a missing version table is reported as the empty version.
+func WithStrictVersionTable() DriverOption {
+	return func(do *driverOptions) { do.strictVersion = true }
+}
+
+// WithHistoryRetention caps the version history, as returned by
+// MigrationHistory, to the latest entries, pruning older ones as each version
+// is written. The entries of the current version are always kept, even beyond
+// the cap. Pruned entries no longer serve as the baselines of
+// WithDurationRegressionFactor.
+//
+// By default, the whole history is kept.
+func WithHistoryRetention(entries int) DriverOption {
+	return func(do *driverOptions) { do.historyRetention = entries }
+}
+
+// WithMigrationTimings logs the completion of each migration run through the
+// driver, with the versions and the time it took, or its failure, and reports
+// its timing to report, if not nil. A migration is timed as a whole, from the
+// start of its phases through the commit of its transaction, including any
+// retries.
+//
+// By default, the migrations are not timed.
+func WithMigrationTimings(report func(migrate.MigrationTiming)) DriverOption {
+	return func(do *driverOptions) {
+		do.timeMigrations = true
+		do.reportTiming = report
+	}
+}
+
+// MigrationTimingReporter implements migrate.TimingDriver.
+func (apd *CRDBDriver) MigrationTimingReporter() (func(migrate.MigrationTiming), bool) {
+	return apd.reportTiming, apd.timeMigrations
+}
+
+// MissingVersionTableError is returned by the Version of a driver created with
+// WithStrictVersionTable when the version table does not exist.
+type MissingVersionTableError struct {
+	// Table is the name of the missing version table.
+	Table string
+}
+
+func (err MissingVersionTableError) Error() string {
+	return fmt.Sprintf("version table %s does not exist; the database is either fresh or has been incompletely restored", err.Table)
 }
 
 // NewCRDBDriver creates a new driver with active connections to the database
 // specified.
-func NewCRDBDriver(url string) (*CRDBDriver, error) {
-	connConfig, err := pgx.ParseConfig(url)
+func NewCRDBDriver(url string, opts ...DriverOption) (*CRDBDriver, error) {
+	return NewCRDBDriverContext(context.Background(), url, opts...)
+}
+
+// NewCRDBDriverContext creates a new driver with active connections to the
+// database specified. The context bounds the time spent connecting, including
+// the retries of the initial connection.
+func NewCRDBDriverContext(ctx context.Context, url string, opts ...DriverOption) (*CRDBDriver, error) {
+	options, err := newDriverOptions(opts)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
-	pgxcommon.ConfigurePGXLogger(connConfig)
-	pgxcommon.ConfigureOTELTracer(connConfig, false)
 
-	db, err := pgx.ConnectConfig(context.Background(), connConfig)
+	connConfig, err := options.connConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
+	if options.connectRetryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.connectRetryTimeout)
+		defer cancel()
+	}
+
+	if options.createDatabase {
+		if err := createDatabaseIfNotExists(ctx, connConfig, options.connect); err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	db, err := options.connect(ctx, connConfig)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
-	return &CRDBDriver{db}, nil
+	driver, err := newCRDBDriver(ctx, db, options, true)
+	if err != nil {
+		_ = db.Close(ctx)
+		return nil, err
+	}
+	return driver, nil
+}
+
+// NewCRDBDriverFromConn creates a new driver that runs its statements on the
+// given connection, such as one configured by the caller with its own TLS,
+// tracing or credentials. The caller retains ownership of the connection:
+// closing the driver does not close it, and the connection must remain open
+// for as long as the driver is used.
+//
+// The options that configure how the driver connects, such as
+// WithDriverConnectTimeout, WithDriverQueryExecMode, CreateDatabaseIfNotExists
+// and WithClientCert, have no effect on a driver created this way.
+func NewCRDBDriverFromConn(ctx context.Context, conn *pgx.Conn, opts ...DriverOption) (*CRDBDriver, error) {
+	if conn == nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, errors.New("connection must not be nil"))
+	}
+
+	options, err := newDriverOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+	return newCRDBDriver(ctx, conn, options, false)
+}
+
+// newDriverOptions applies the options to their defaults and validates them.
+func newDriverOptions(opts []DriverOption) (driverOptions, error) {
+	options := driverOptions{
+		connectRetryTimeout: defaultConnectRetryTimeout,
+		txMaxRetries:        defaultTxMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := ValidateVersionTablePrefix(options.versionTablePrefix); err != nil {
+		return options, err
+	}
+
+	if options.regressionFactor != 0 && options.regressionFactor <= 1 {
+		return options, fmt.Errorf("invalid duration regression factor %v: must be greater than 1", options.regressionFactor)
+	}
+
+	if options.historyRetention < 0 {
+		return options, fmt.Errorf("invalid history retention %d: must not be negative", options.historyRetention)
+	}
+
+	if options.txMaxRetries < 0 {
+		return options, fmt.Errorf("invalid transaction max retries %d: must not be negative", options.txMaxRetries)
+	}
+
+	if err := options.validateLegacyVersionMapping(); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// newCRDBDriver creates a driver running its statements on the connection,
+// which it closes on Close if it owns it.
+func newCRDBDriver(ctx context.Context, db *pgx.Conn, options driverOptions, ownsConn bool) (*CRDBDriver, error) {
+	driver := &CRDBDriver{
+		db:                 db,
+		ownsConn:           ownsConn,
+		seedNamespaces:     options.seedNamespaces,
+		versionTablePrefix: options.versionTablePrefix,
+		strictVersion:      options.strictVersion,
+
+		regressionFactor: options.regressionFactor,
+		historyRetention: options.historyRetention,
+		txMaxRetries:     options.txMaxRetries,
+
+		timeMigrations: options.timeMigrations,
+		reportTiming:   options.reportTiming,
+	}
+
+	if options.legacyVersionTable != "" {
+		if err := driver.backfillLegacyVersion(ctx, options.legacyVersionTable, options.legacyVersionMapping); err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	return driver, nil
+}
+
+// versionTable returns the name of the table in which the version of the
+// schema is recorded.
+func (apd *CRDBDriver) versionTable() string {
+	return PrefixedTable(apd.versionTablePrefix, TableSchemaVersion)
+}
+
+// historyTable returns the name of the table in which the history of applied
+// migrations is recorded.
+func (apd *CRDBDriver) historyTable() string {
+	return PrefixedTable(apd.versionTablePrefix, TableSchemaVersionHistory)
+}
+
+// checkpointTable returns the name of the table in which the completed phases
+// of phased migrations are recorded.
+func (apd *CRDBDriver) checkpointTable() string {
+	return PrefixedTable(apd.versionTablePrefix, TableMigrationCheckpoint)
+}
+
+// connConfig builds the configuration of the driver's connection from the
+// connection string and the options. The host of the connection string may be
+// the absolute path of the directory containing a unix domain socket, such as
+// `postgresql://root@/defaultdb?host=/var/run/cockroach&port=26257`.
+func (do driverOptions) connConfig(url string) (*pgx.ConnConfig, error) {
+	connConfig, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	pgxcommon.ConfigurePGXLogger(connConfig)
+	pgxcommon.ConfigureOTELTracer(connConfig, false)
+
+	if do.queryExecMode != 0 {
+		connConfig.DefaultQueryExecMode = do.queryExecMode
+	}
+
+	if err := do.configureTLS(connConfig); err != nil {
+		return nil, err
+	}
+	return connConfig, nil
+}
+
+// Ping checks that the database is reachable over the driver's connection,
+// regardless of the state of its schema, returning the error with which the
+// connection failed if it is not. A database that is reachable but has not
+// been migrated is reported by Version as the empty version instead.
+func (apd *CRDBDriver) Ping(ctx context.Context) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	if err := apd.db.Ping(ctx); err != nil {
+		return fmt.Errorf("unable to reach database: %w", err)
+	}
+	return nil
 }
 
 // Version returns the version of the schema to which the connected database
 // has been migrated.
 func (apd *CRDBDriver) Version(ctx context.Context) (string, error) {
+	if err := apd.checkOpen(); err != nil {
+		return "", err
+	}
+
 	var loaded string
 
-	if err := apd.db.QueryRow(ctx, queryLoadVersion).Scan(&loaded); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == postgresMissingTableErrorCode {
+	if err := apd.db.QueryRow(ctx, fmt.Sprintf(queryLoadVersion, apd.versionTable())).Scan(&loaded); err != nil {
+		if pool.IsMissingTable(err) {
+			if apd.strictVersion {
+				return "", MissingVersionTableError{Table: apd.versionTable()}
+			}
 			return "", nil
 		}
 		return "", fmt.Errorf("unable to load alembic revision: %w", err)
@@ -66,29 +416,290 @@ func (apd *CRDBDriver) Conn() *pgx.Conn {
 	return apd.db
 }
 
+// RunTx runs the function in a transaction, which is re-run after
+// serialization failures per WithTxMaxRetries.
 func (apd *CRDBDriver) RunTx(ctx context.Context, f migrate.TxMigrationFunc[pgx.Tx]) error {
-	return pgx.BeginFunc(ctx, apd.db, func(tx pgx.Tx) error {
-		return f(ctx, tx)
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	return retrySerializationFailures(ctx, apd.txMaxRetries, func() error {
+		return pgx.BeginFunc(ctx, apd.db, func(tx pgx.Tx) error {
+			return f(ctx, tx)
+		})
 	})
 }
 
-// Close disposes the driver.
+// Close disposes the driver, closing its connection unless it was provided
+// to NewCRDBDriverFromConn. Using the driver after it has been closed fails
+// with datastore.ErrDatastoreClosed. Closing a nil driver, as returned when
+// it could not be created, does nothing.
 func (apd *CRDBDriver) Close(ctx context.Context) error {
+	if apd == nil {
+		return nil
+	}
+	apd.closed.Store(true)
+	if !apd.ownsConn {
+		return nil
+	}
 	return apd.db.Close(ctx)
 }
 
+// checkOpen returns datastore.ErrDatastoreClosed if the driver has been
+// closed.
+func (apd *CRDBDriver) checkOpen() error {
+	if apd.closed.Load() {
+		return datastore.ErrDatastoreClosed
+	}
+	return nil
+}
+
 func (apd *CRDBDriver) WriteVersion(ctx context.Context, tx pgx.Tx, version, replaced string) error {
-	result, err := tx.Exec(ctx, queryWriteVersion, version, replaced)
+	if replaced == "" {
+		if apd.versionTablePrefix != "" {
+			if _, err := tx.Exec(ctx, fmt.Sprintf(queryCreateVersionTable, apd.versionTable())); err != nil {
+				return fmt.Errorf("unable to create version table: %w", err)
+			}
+			if _, err := tx.Exec(ctx, fmt.Sprintf(queryCreateCheckpointTable, apd.checkpointTable())); err != nil {
+				return fmt.Errorf("unable to create checkpoint table: %w", err)
+			}
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(queryCreateHistoryTable, apd.historyTable())); err != nil {
+			return fmt.Errorf("unable to create version history table: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(ctx, fmt.Sprintf(queryWriteVersion, apd.versionTable()), version, replaced)
 	if err != nil {
 		return fmt.Errorf("unable to update version row: %w", err)
 	}
 
 	updatedCount := result.RowsAffected()
+	if updatedCount == 0 && replaced == "" {
+		// The first migration creates the version table, which it need not
+		// seed with the empty version, so the version is inserted instead.
+		result, err = tx.Exec(ctx, fmt.Sprintf(queryBootstrapVersion, apd.versionTable()), version)
+		if err != nil {
+			return fmt.Errorf("unable to insert version row: %w", err)
+		}
+		updatedCount = result.RowsAffected()
+	}
 	if updatedCount != 1 {
 		return fmt.Errorf("writing version update affected %d rows, should be 1", updatedCount)
 	}
 
+	// The migrations of a database whose first migration ran before the
+	// history table existed are recorded from the migration that creates it.
+	var hasHistory bool
+	if err := tx.QueryRow(ctx, queryTableExists, apd.historyTable()).Scan(&hasHistory); err != nil {
+		return fmt.Errorf("unable to check for the version history table: %w", err)
+	}
+	if !hasHistory {
+		return nil
+	}
+
+	duration, _ := migrate.MigrationDuration(ctx)
+	if err := apd.warnOnDurationRegression(ctx, tx, version, duration); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(queryInsertHistory, apd.historyTable()), version, replaced, duration.Milliseconds()); err != nil {
+		return fmt.Errorf("unable to record version history: %w", err)
+	}
+	if apd.historyRetention > 0 {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(queryPruneHistory, apd.historyTable()), version, apd.historyRetention); err != nil {
+			return fmt.Errorf("unable to prune version history: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RevertVersion implements migrate.RollbackDriver, restoring the version that
+// the given version replaced. The version history is left as it is, so that
+// it still lists the migration that was rolled back.
+func (apd *CRDBDriver) RevertVersion(ctx context.Context, tx pgx.Tx, version, replaced string) error {
+	result, err := tx.Exec(ctx, fmt.Sprintf(queryWriteVersion, apd.versionTable()), replaced, version)
+	if err != nil {
+		return fmt.Errorf("unable to revert version row: %w", err)
+	}
+
+	if revertedCount := result.RowsAffected(); revertedCount != 1 {
+		return fmt.Errorf("reverting version affected %d rows, should be 1", revertedCount)
+	}
 	return nil
 }
 
-var _ migrate.Driver[*pgx.Conn, pgx.Tx] = &CRDBDriver{}
+// Rollback reverts the database by one version, running the down migration
+// of its current version in a transaction. It fails with a
+// migrate.IrreversibleMigrationError if the migration has none. See
+// migrate.Manager.Rollback.
+func (apd *CRDBDriver) Rollback(ctx context.Context) (migrate.MigrationStep, error) {
+	if err := apd.checkOpen(); err != nil {
+		return migrate.MigrationStep{}, err
+	}
+	return CRDBMigrations.Rollback(ctx, apd)
+}
+
+// MigrationRecord describes a migration that was applied to the database.
+type MigrationRecord struct {
+	Version   string
+	Replaced  string
+	AppliedAt time.Time
+	Duration  time.Duration
+}
+
+// MigrationHistory returns the migrations that have been applied to the
+// database, in the order in which they were applied. Migrations applied before
+// history was recorded are not included.
+func (apd *CRDBDriver) MigrationHistory(ctx context.Context) ([]MigrationRecord, error) {
+	if err := apd.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	history := make([]MigrationRecord, 0)
+	rows, err := apd.db.Query(ctx, fmt.Sprintf(queryLoadHistory, apd.historyTable()))
+	if err != nil {
+		if pool.IsMissingTable(err) {
+			return history, nil
+		}
+		return nil, fmt.Errorf("unable to load version history: %w", err)
+	}
+
+	var record MigrationRecord
+	var durationMs int64
+	if _, err := pgx.ForEachRow(rows, []any{&record.Version, &record.Replaced, &record.AppliedAt, &durationMs}, func() error {
+		record.Duration = time.Duration(durationMs) * time.Millisecond
+		history = append(history, record)
+		return nil
+	}); err != nil {
+		if pool.IsMissingTable(err) {
+			return history, nil
+		}
+		return nil, fmt.Errorf("unable to load version history: %w", err)
+	}
+
+	return history, nil
+}
+
+// ForceVersion overwrites the version of the schema recorded in the database
+// without executing any migration, inserting the version row if one does not
+// yet exist.
+//
+// DANGER: this is a disaster recovery tool. Recording a version does not make
+// the schema match it; forcing a version that does not reflect the actual state
+// of the schema will cause subsequent migrations and the datastore to fail in
+// unpredictable ways.
+func (apd *CRDBDriver) ForceVersion(ctx context.Context, version string) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, apd.db, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, fmt.Sprintf(queryForceVersion, apd.versionTable()), version)
+		if err != nil {
+			return fmt.Errorf("unable to force version row: %w", err)
+		}
+
+		if result.RowsAffected() > 0 {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx, fmt.Sprintf(queryInsertVersion, apd.versionTable()), version); err != nil {
+			return fmt.Errorf("unable to insert version row: %w", err)
+		}
+		return nil
+	})
+}
+
+// Plan returns the version transitions that migrating the database through
+// the given revision, or migrate.Head, would make, without running any
+// migration. See migrate.Manager.Plan.
+func (apd *CRDBDriver) Plan(ctx context.Context, throughRevision string) ([]migrate.MigrationStep, error) {
+	if err := apd.checkOpen(); err != nil {
+		return nil, err
+	}
+	return CRDBMigrations.Plan(ctx, apd, throughRevision)
+}
+
+// ResumeMigration re-runs the phased migration to the given version starting
+// at the phase with index fromStep, as listed by CRDBMigrations.Phases, and
+// then completes the migration. See migrate.Manager.ResumeMigration.
+//
+// DANGER: this is a recovery tool for migrations that failed partway and left
+// the schema partially changed. The phases before fromStep must have been
+// applied in full, and those after must tolerate the state left by the
+// failure.
+func (apd *CRDBDriver) ResumeMigration(ctx context.Context, version string, fromStep int) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+	return CRDBMigrations.ResumeMigration(ctx, apd, version, fromStep)
+}
+
+// CompletedPhases returns the phases of the migration to the given version
+// that have been recorded as completed.
+func (apd *CRDBDriver) CompletedPhases(ctx context.Context, version string) ([]string, error) {
+	if err := apd.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	rows, err := apd.db.Query(ctx, fmt.Sprintf(queryLoadCompletedPhases, apd.checkpointTable()), version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load completed phases: %w", err)
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// MarkPhaseCompleted records the phase of the migration to the given version
+// as completed.
+func (apd *CRDBDriver) MarkPhaseCompleted(ctx context.Context, version, phase string) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	if _, err := apd.db.Exec(ctx, fmt.Sprintf(queryMarkPhaseCompleted, apd.checkpointTable()), version, phase); err != nil {
+		return fmt.Errorf("unable to record completed phase: %w", err)
+	}
+	return nil
+}
+
+// ClearPhases deletes, in the transaction, the phases of the migration to the
+// given version that have been recorded as completed.
+func (apd *CRDBDriver) ClearPhases(ctx context.Context, tx pgx.Tx, version string) error {
+	if _, err := tx.Exec(ctx, fmt.Sprintf(queryClearPhases, apd.checkpointTable()), version); err != nil {
+		return fmt.Errorf("unable to clear completed phases: %w", err)
+	}
+	return nil
+}
+
+// Seed loads the namespaces configured with WithSeedNamespaces, if any, that
+// do not already exist.
+func (apd *CRDBDriver) Seed(ctx context.Context) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	if len(apd.seedNamespaces) == 0 {
+		return nil
+	}
+
+	return pgx.BeginFunc(ctx, apd.db, func(tx pgx.Tx) error {
+		for _, namespace := range apd.seedNamespaces {
+			serialized, err := namespace.MarshalVT()
+			if err != nil {
+				return fmt.Errorf("unable to serialize namespace %s: %w", namespace.Name, err)
+			}
+
+			if _, err := tx.Exec(ctx, queryInsertSeedNamespace, namespace.Name, serialized); err != nil {
+				return fmt.Errorf("unable to seed namespace %s: %w", namespace.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+var (
+	_ migrate.Driver[*pgx.Conn, pgx.Tx] = &CRDBDriver{}
+	_ migrate.SeedingDriver             = &CRDBDriver{}
+	_ migrate.CheckpointDriver[pgx.Tx]  = &CRDBDriver{}
+)
@@ -0,0 +1,639 @@
+//go:build ci && docker
+// +build ci,docker
+
+package migrations_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+	testdatastore "github.com/authzed/spicedb/internal/testserver/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/migrate"
+	"github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func newMigratedDriver(t *testing.T, b testdatastore.RunningEngineForTest) *migrations.CRDBDriver {
+	driver := newDriver(t, b)
+	require.NoError(t, migrations.CRDBMigrations.Run(context.Background(), driver, migrate.Head, migrate.LiveRun))
+	return driver
+}
+
+func newDriver(t *testing.T, b testdatastore.RunningEngineForTest) *migrations.CRDBDriver {
+	driver, err := migrations.NewCRDBDriver(b.NewDatabase(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = driver.Close(context.Background())
+	})
+	return driver
+}
+
+func TestForceVersion(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver := newMigratedDriver(t, b)
+	require.NoError(t, driver.ForceVersion(ctx, "add-caveats"))
+
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "add-caveats", version)
+
+	// Forcing a version when the row is missing inserts it.
+	_, err = driver.Conn().Exec(ctx, "DELETE FROM schema_version")
+	require.NoError(t, err)
+	require.NoError(t, driver.ForceVersion(ctx, "initial"))
+
+	version, err = driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "initial", version)
+}
+
+func TestPhaseCheckpoints(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	// The checkpoint table is created by the migrations.
+	driver := newMigratedDriver(t, b)
+	completed, err := driver.CompletedPhases(ctx, "some-version")
+	require.NoError(t, err)
+	require.Empty(t, completed)
+
+	require.NoError(t, driver.MarkPhaseCompleted(ctx, "some-version", "backfill"))
+	require.NoError(t, driver.MarkPhaseCompleted(ctx, "some-version", "backfill"))
+
+	completed, err = driver.CompletedPhases(ctx, "some-version")
+	require.NoError(t, err)
+	require.Equal(t, []string{"backfill"}, completed)
+
+	completed, err = driver.CompletedPhases(ctx, "other-version")
+	require.NoError(t, err)
+	require.Empty(t, completed)
+}
+
+func TestResumeMigration(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	// None of the migrations of the CRDB datastore are phased, so none can be
+	// resumed from a step.
+	driver := newDriver(t, b)
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, "initial", migrate.LiveRun))
+	require.ErrorContains(t, driver.ResumeMigration(ctx, "add-transactions-table", 0), "not phased")
+	require.ErrorContains(t, driver.ResumeMigration(ctx, "unknown", 0), "unknown migration")
+
+	require.NoError(t, driver.Close(ctx))
+	require.ErrorIs(t, driver.ResumeMigration(ctx, "add-transactions-table", 0), datastore.ErrDatastoreClosed)
+}
+
+func TestVerifySchema(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver := newMigratedDriver(t, b)
+	require.NoError(t, driver.VerifySchema(ctx))
+
+	_, err := driver.Conn().Exec(ctx, "DROP INDEX relation_tuple@ix_relation_tuple_by_subject")
+	require.NoError(t, err)
+	_, err = driver.Conn().Exec(ctx, "ALTER TABLE caveat DROP COLUMN timestamp")
+	require.NoError(t, err)
+	_, err = driver.Conn().Exec(ctx, "DROP TABLE relationship_counter")
+	require.NoError(t, err)
+
+	var driftErr migrations.SchemaDriftError
+	require.ErrorAs(t, driver.VerifySchema(ctx), &driftErr)
+	require.Equal(t, []string{
+		"missing column caveat.timestamp",
+		"missing index ix_relation_tuple_by_subject on relation_tuple",
+		"missing table relationship_counter",
+	}, driftErr.Discrepancies)
+}
+
+func TestSeedNamespaces(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+	url := b.NewDatabase(t)
+
+	seed := func(namespaces ...string) {
+		defs := make([]*core.NamespaceDefinition, 0, len(namespaces))
+		for _, name := range namespaces {
+			defs = append(defs, namespace.Namespace(name))
+		}
+
+		driver, err := migrations.NewCRDBDriver(url, migrations.WithSeedNamespaces(defs...))
+		require.NoError(t, err)
+		defer driver.Close(ctx)
+
+		require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+	}
+
+	seed("user", "document")
+	seed("user", "folder")
+
+	driver, err := migrations.NewCRDBDriver(url)
+	require.NoError(t, err)
+	defer driver.Close(ctx)
+
+	rows, err := driver.Conn().Query(ctx, "SELECT namespace FROM namespace_config ORDER BY namespace")
+	require.NoError(t, err)
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	require.NoError(t, err)
+	require.Equal(t, []string{"document", "folder", "user"}, names)
+}
+
+func TestMigrationHistory(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver := newDriver(t, b)
+	history, err := driver.MigrationHistory(ctx)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+
+	history, err = driver.MigrationHistory(ctx)
+	require.NoError(t, err)
+
+	// The initial migration creates the history table when it writes its
+	// version, and is thus recorded along with every subsequent migration.
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, "initial", history[0].Version)
+	require.Equal(t, "", history[0].Replaced)
+	require.Equal(t, head, history[len(history)-1].Version)
+	for i := 1; i < len(history); i++ {
+		require.Equal(t, history[i-1].Version, history[i].Replaced)
+		require.False(t, history[i].AppliedAt.Before(history[i-1].AppliedAt))
+	}
+}
+
+func TestMigrationHistoryOfPreviouslyMigratedDatabase(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	// A database whose first migration ran before the history table existed
+	// has no history table.
+	driver := newDriver(t, b)
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, "add-caveats", migrate.LiveRun))
+	_, err := driver.Conn().Exec(ctx, "DROP TABLE schema_version_history")
+	require.NoError(t, err)
+
+	// The migrations before the one creating the table are not recorded.
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, "add-migration-checkpoint-table", migrate.LiveRun))
+	history, err := driver.MigrationHistory(ctx)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+	history, err = driver.MigrationHistory(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	require.Equal(t, "add-version-history-table", history[0].Version)
+	require.NoError(t, driver.VerifySchema(ctx))
+}
+
+func TestHistoryRetention(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	_, err := migrations.NewCRDBDriver(b.NewDatabase(t), migrations.WithHistoryRetention(-1))
+	require.ErrorContains(t, err, "must not be negative")
+
+	driver, err := migrations.NewCRDBDriver(b.NewDatabase(t), migrations.WithHistoryRetention(3))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = driver.Close(context.Background())
+	})
+
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+
+	// Only the latest entries, ending with the current version, are kept.
+	history, err := driver.MigrationHistory(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, head, history[2].Version)
+	require.Equal(t, history[0].Version, history[1].Replaced)
+	require.Equal(t, history[1].Version, history[2].Replaced)
+}
+
+func TestVersionTablePrefix(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver, err := migrations.NewCRDBDriver(b.NewDatabase(t), migrations.WithVersionTablePrefix("tenant1_"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = driver.Close(context.Background())
+	})
+
+	// The missing prefixed version table is detected as a fresh database.
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Empty(t, version)
+
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	version, err = driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+
+	history, err := driver.MigrationHistory(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, history[len(history)-1].Version)
+
+	require.NoError(t, driver.VerifySchema(ctx))
+
+	// The prefixed checkpoint table is created with the prefixed version table.
+	require.NoError(t, driver.MarkPhaseCompleted(ctx, "some-version", "backfill"))
+	completed, err := driver.CompletedPhases(ctx, "some-version")
+	require.NoError(t, err)
+	require.Equal(t, []string{"backfill"}, completed)
+
+	// The unprefixed version table created by the initial migration is left
+	// at the empty version.
+	var unprefixed string
+	require.NoError(t, driver.Conn().QueryRow(ctx, "SELECT version_num FROM schema_version").Scan(&unprefixed))
+	require.Empty(t, unprefixed)
+}
+
+func TestStrictVersionTable(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	url := b.NewDatabase(t)
+	driver, err := migrations.NewCRDBDriver(url, migrations.WithStrictVersionTable())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = driver.Close(context.Background())
+	})
+
+	// A missing version table is an error rather than a fresh database, which
+	// prevents the migrations from running.
+	_, err = driver.Version(ctx)
+	var missing migrations.MissingVersionTableError
+	require.ErrorAs(t, err, &missing)
+	require.Equal(t, "schema_version", missing.Table)
+	require.ErrorAs(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun), &missing)
+
+	// Once migrated by a lenient driver, the version is loaded as usual.
+	lenient, err := migrations.NewCRDBDriver(url)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = lenient.Close(context.Background())
+	})
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, lenient, migrate.Head, migrate.LiveRun))
+
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+}
+
+func TestDiffMigration(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver := newMigratedDriver(t, b)
+	diff, err := driver.DiffMigration(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		for _, stmt := range []string{
+			"CREATE TABLE dry_run_table (id INT8 PRIMARY KEY)",
+			"ALTER TABLE caveat ADD COLUMN dry_run_column STRING",
+			"CREATE INDEX ix_caveat_dry_run ON caveat (dry_run_column)",
+		} {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"dry_run_table"}, diff.AddedTables)
+	require.Equal(t, []string{"caveat.dry_run_column"}, diff.AddedColumns)
+	require.Equal(t, []string{"ix_caveat_dry_run on caveat"}, diff.AddedIndexes)
+	require.Empty(t, diff.DroppedTables)
+
+	// The candidate's changes must have been rolled back.
+	require.NoError(t, driver.VerifySchema(ctx))
+	var count int
+	require.NoError(t, driver.Conn().QueryRow(ctx,
+		"SELECT count(*) FROM information_schema.tables WHERE table_name = 'dry_run_table'").Scan(&count))
+	require.Zero(t, count)
+}
+
+func TestDriverClosed(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver := newDriver(t, b)
+	require.NoError(t, driver.Close(ctx))
+
+	_, err := driver.Version(ctx)
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+	require.ErrorIs(t, driver.Ping(ctx), datastore.ErrDatastoreClosed)
+	require.ErrorIs(t, driver.RunTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		return nil
+	}), datastore.ErrDatastoreClosed)
+}
+
+func TestPing(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	// An unmigrated database is reachable, with the empty version.
+	driver := newDriver(t, b)
+	require.NoError(t, driver.Ping(ctx))
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "", version)
+
+	// A lost connection fails the ping, rather than reporting a schema state.
+	conn, err := pgx.Connect(ctx, b.NewDatabase(t))
+	require.NoError(t, err)
+	lost, err := migrations.NewCRDBDriverFromConn(ctx, conn)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close(ctx))
+
+	err = lost.Ping(ctx)
+	require.ErrorContains(t, err, "unable to reach database")
+	require.NotErrorIs(t, err, datastore.ErrDatastoreClosed)
+}
+
+func TestDriverQueryExecMode(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver, err := migrations.NewCRDBDriver(b.NewDatabase(t), migrations.WithDriverQueryExecMode(pgx.QueryExecModeSimpleProtocol))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = driver.Close(ctx)
+	})
+	require.Equal(t, pgx.QueryExecModeSimpleProtocol, driver.Conn().Config().DefaultQueryExecMode)
+
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+}
+
+func TestCreateDatabaseIfNotExists(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	uri, err := url.Parse(b.NewDatabase(t))
+	require.NoError(t, err)
+	name := fmt.Sprintf("created_%d", time.Now().UnixNano())
+	uri.Path = "/" + name
+
+	for range 2 {
+		// Creating the database is idempotent.
+		driver, err := migrations.NewCRDBDriver(uri.String(), migrations.CreateDatabaseIfNotExists())
+		require.NoError(t, err)
+
+		var exists bool
+		require.NoError(t, driver.Conn().QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM [SHOW DATABASES] WHERE database_name = $1)", name).Scan(&exists))
+		require.True(t, exists)
+		require.NoError(t, driver.Close(ctx))
+	}
+
+	driver, err := migrations.NewCRDBDriver(uri.String(), migrations.CreateDatabaseIfNotExists())
+	require.NoError(t, err)
+	defer driver.Close(ctx)
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+}
+
+func TestWithSavepoint(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	driver := newMigratedDriver(t, b)
+	require.NoError(t, driver.RunTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "CREATE TABLE backfill (id INT PRIMARY KEY)"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO backfill (id) VALUES (1)"); err != nil {
+			return err
+		}
+
+		// Writes made within a released savepoint are kept and readable.
+		require.NoError(t, migrations.WithSavepoint(ctx, tx, func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, "INSERT INTO backfill (id) SELECT id + 1 FROM backfill")
+			return err
+		}))
+
+		// Writes made within a savepoint whose function fails are undone.
+		errFailed := errors.New("failed")
+		require.ErrorIs(t, migrations.WithSavepoint(ctx, tx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "INSERT INTO backfill (id) VALUES (10)"); err != nil {
+				return err
+			}
+			return errFailed
+		}), errFailed)
+
+		rows, err := tx.Query(ctx, "SELECT id FROM backfill ORDER BY id")
+		if err != nil {
+			return err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+		require.Equal(t, []int{1, 2}, ids)
+		return nil
+	}))
+}
+
+func TestLegacyVersionTable(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	_, err := migrations.NewCRDBDriver(b.NewDatabase(t), migrations.WithLegacyVersionTable("alembic_version", nil))
+	require.ErrorContains(t, err, "no version mapping")
+	_, err = migrations.NewCRDBDriver(b.NewDatabase(t), migrations.WithLegacyVersionTable("alembic_version", map[string]string{"abc123": "unknown"}))
+	require.ErrorContains(t, err, "unregistered migrations")
+
+	// Migrate a database part way and move its version into a legacy table.
+	url := b.NewDatabase(t)
+	driver, err := migrations.NewCRDBDriver(url)
+	require.NoError(t, err)
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, "add-caveats", migrate.LiveRun))
+	for _, stmt := range []string{
+		"CREATE TABLE alembic_version (version_num VARCHAR NOT NULL)",
+		"INSERT INTO alembic_version (version_num) VALUES ('abc123')",
+		"DROP TABLE schema_version",
+	} {
+		_, err := driver.Conn().Exec(ctx, stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, driver.Close(ctx))
+
+	// A legacy revision missing from the mapping fails rather than being
+	// treated as a fresh database.
+	_, err = migrations.NewCRDBDriver(url, migrations.WithLegacyVersionTable("alembic_version", map[string]string{"def456": "initial"}))
+	require.ErrorContains(t, err, `legacy revision "abc123"`)
+
+	backfilled, err := migrations.NewCRDBDriver(url, migrations.WithLegacyVersionTable("alembic_version", map[string]string{
+		"def456": "initial",
+		"abc123": "add-caveats",
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = backfilled.Close(context.Background())
+	})
+
+	version, err := backfilled.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "add-caveats", version)
+
+	// The migrations resume from the backfilled version.
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, backfilled, migrate.Head, migrate.LiveRun))
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	version, err = backfilled.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+
+	// Once backfilled, the version table is left untouched.
+	reconnected, err := migrations.NewCRDBDriver(url, migrations.WithLegacyVersionTable("alembic_version", map[string]string{"abc123": "initial"}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = reconnected.Close(context.Background())
+	})
+	version, err = reconnected.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+}
+
+func TestDriverFromConn(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, b.NewDatabase(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+
+	driver, err := migrations.NewCRDBDriverFromConn(ctx, conn)
+	require.NoError(t, err)
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+
+	// Closing the driver leaves the caller's connection open.
+	require.NoError(t, driver.Close(ctx))
+	_, err = driver.Version(ctx)
+	require.ErrorIs(t, err, datastore.ErrDatastoreClosed)
+	require.NoError(t, conn.Ping(ctx))
+
+	_, err = migrations.NewCRDBDriverFromConn(ctx, nil)
+	require.ErrorContains(t, err, "connection must not be nil")
+}
+
+func TestWriteVersionBootstrap(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	// The first migration creates the version table without seeding it with
+	// the empty version; the version is inserted when it is written.
+	m := migrate.NewManager[*migrations.CRDBDriver, *pgx.Conn, pgx.Tx]()
+	noNonAtomic := func(ctx context.Context, conn *pgx.Conn) error { return nil }
+	require.NoError(t, m.Register("first", "", noNonAtomic, func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "CREATE TABLE schema_version (version_num VARCHAR NOT NULL)")
+		return err
+	}))
+	require.NoError(t, m.Register("second", "first", noNonAtomic, func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "CREATE TABLE bootstrapped (id INT PRIMARY KEY)")
+		return err
+	}))
+
+	driver := newDriver(t, b)
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "", version)
+
+	require.NoError(t, m.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+	version, err = driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", version)
+
+	var rows int
+	require.NoError(t, driver.Conn().QueryRow(ctx, "SELECT count(*) FROM schema_version").Scan(&rows))
+	require.Equal(t, 1, rows)
+
+	// The registered migrations, whose first seeds the version table, also
+	// run in full against a database without a version table.
+	fresh := newDriver(t, b)
+	require.NoError(t, migrations.CRDBMigrations.Run(ctx, fresh, migrate.Head, migrate.LiveRun))
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	version, err = fresh.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, version)
+}
+
+func TestRollback(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	m := migrate.NewManager[*migrations.CRDBDriver, *pgx.Conn, pgx.Tx]()
+	noNonAtomic := func(ctx context.Context, conn *pgx.Conn) error { return nil }
+	exec := func(stmt string) migrate.TxMigrationFunc[pgx.Tx] {
+		return func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, stmt)
+			return err
+		}
+	}
+	require.NoError(t, m.Register("first", "", noNonAtomic, exec("CREATE TABLE schema_version (version_num VARCHAR NOT NULL)")))
+	require.NoError(t, m.Register("second", "first", noNonAtomic, exec("CREATE TABLE rolled_back (id INT PRIMARY KEY)"),
+		migrate.WithDown(exec("DROP TABLE rolled_back"))))
+
+	driver := newDriver(t, b)
+	require.NoError(t, m.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+
+	step, err := m.Rollback(ctx, driver)
+	require.NoError(t, err)
+	require.Equal(t, migrate.MigrationStep{From: "second", To: "first"}, step)
+
+	version, err := driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "first", version)
+
+	var exists bool
+	require.NoError(t, driver.Conn().QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'rolled_back')").Scan(&exists))
+	require.False(t, exists)
+
+	// The migration back to the predecessor can be re-run.
+	require.NoError(t, m.Run(ctx, driver, migrate.Head, migrate.LiveRun))
+	version, err = driver.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", version)
+
+	// The registered migrations are irreversible.
+	migrated := newMigratedDriver(t, b)
+	_, err = migrated.Rollback(ctx)
+	var irreversible migrate.IrreversibleMigrationError
+	require.ErrorAs(t, err, &irreversible)
+	head, err := migrations.CRDBMigrations.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, head, irreversible.Version)
+}
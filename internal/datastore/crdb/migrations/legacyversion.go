@@ -0,0 +1,122 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+const (
+	// The legacy version table is expected to hold its revision in a
+	// version_num column, as alembic's does.
+	queryLoadLegacyVersion = "SELECT version_num FROM %s"
+
+	queryCreateVersionIfNotExists = `CREATE TABLE IF NOT EXISTS %s (
+	version_num VARCHAR NOT NULL
+);`
+	queryInsertVersionIfEmpty = "INSERT INTO %[1]s (version_num) SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM %[1]s)"
+)
+
+// legacyTableRegex restricts the names of legacy version tables to unquoted
+// identifiers, optionally qualified by a schema.
+var legacyTableRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+// WithLegacyVersionTable backfills the version table of a database migrated by
+// older tooling that recorded its revision in another table, such as alembic's
+// `alembic_version`. When the driver connects to a database that has no
+// version table, it reads the revision from the version_num column of the
+// legacy table and records the SpiceDB version to which the mapping maps it,
+// so that the migrations resume from that version. A database that has a
+// version table, or no legacy table, is left untouched.
+//
+// Every version in the mapping must be a registered migration. Connecting
+// fails if the legacy revision found in the database is not in the mapping,
+// rather than treating the database as fresh. By default, no legacy table is
+// read.
+func WithLegacyVersionTable(table string, mapping map[string]string) DriverOption {
+	return func(do *driverOptions) {
+		do.legacyVersionTable = table
+		do.legacyVersionMapping = mapping
+	}
+}
+
+// validateLegacyVersionMapping checks that the legacy version table is a valid
+// table name and that its mapping only maps to registered migrations.
+func (do driverOptions) validateLegacyVersionMapping() error {
+	if do.legacyVersionTable == "" {
+		if len(do.legacyVersionMapping) > 0 {
+			return errors.New("a legacy version mapping requires a legacy version table")
+		}
+		return nil
+	}
+
+	if !legacyTableRegex.MatchString(do.legacyVersionTable) {
+		return fmt.Errorf("invalid legacy version table %q: must be a lowercase identifier, optionally qualified by a schema", do.legacyVersionTable)
+	}
+	if do.legacyVersionTable == PrefixedTable(do.versionTablePrefix, TableSchemaVersion) {
+		return fmt.Errorf("invalid legacy version table %q: must differ from the version table", do.legacyVersionTable)
+	}
+	if len(do.legacyVersionMapping) == 0 {
+		return fmt.Errorf("legacy version table %s has no version mapping", do.legacyVersionTable)
+	}
+
+	var unregistered []string
+	for legacy, version := range do.legacyVersionMapping {
+		if !CRDBMigrations.IsRegistered(version) {
+			unregistered = append(unregistered, fmt.Sprintf("%q => %q", legacy, version))
+		}
+	}
+	if len(unregistered) > 0 {
+		sort.Strings(unregistered)
+		return fmt.Errorf("legacy version mapping refers to unregistered migrations: %v", unregistered)
+	}
+	return nil
+}
+
+// backfillLegacyVersion records the version to which the revision of the
+// legacy version table maps, if the database has no version table.
+func (apd *CRDBDriver) backfillLegacyVersion(ctx context.Context, table string, mapping map[string]string) error {
+	var current string
+	err := apd.db.QueryRow(ctx, fmt.Sprintf(queryLoadVersion, apd.versionTable())).Scan(&current)
+	switch {
+	case err == nil:
+		return nil
+	case !pool.IsMissingTable(err) && !errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("unable to load version: %w", err)
+	}
+
+	var legacy string
+	if err := apd.db.QueryRow(ctx, fmt.Sprintf(queryLoadLegacyVersion, table)).Scan(&legacy); err != nil {
+		if pool.IsMissingTable(err) || errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("unable to load legacy revision from %s: %w", table, err)
+	}
+
+	version, ok := mapping[legacy]
+	if !ok {
+		return fmt.Errorf("legacy revision %q of %s has no mapping to a version", legacy, table)
+	}
+
+	if err := pgx.BeginFunc(ctx, apd.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(queryCreateVersionIfNotExists, apd.versionTable())); err != nil {
+			return fmt.Errorf("unable to create version table: %w", err)
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(queryInsertVersionIfEmpty, apd.versionTable()), version); err != nil {
+			return fmt.Errorf("unable to insert version row: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("legacyRevision", legacy).Str("version", version).Str("legacyTable", table).Msg("backfilled version from legacy version table")
+	return nil
+}
@@ -1,11 +1,19 @@
 package migrations
 
 import (
+	"context"
+	"errors"
+
 	"github.com/jackc/pgx/v5"
 
 	"github.com/authzed/spicedb/pkg/migrate"
 )
 
+// errTablePrefixUnsupported is returned for a RunnerOptions.TablePrefix, which
+// names every table of the datastore, whereas the CRDB migrations only create
+// unprefixed tables; see WithVersionTablePrefix.
+var errTablePrefixUnsupported = errors.New("cockroachdb does not support prefixing the names of the datastore tables; use a separate database for each SpiceDB schema")
+
 var (
 	noNonAtomicMigration migrate.MigrationFunc[*pgx.Conn]
 	noAtomicMigration    migrate.TxMigrationFunc[pgx.Tx]
@@ -13,3 +21,27 @@ var (
 
 // CRDBMigrations implements a migration manager for the CRDBDriver.
 var CRDBMigrations = migrate.NewManager[*CRDBDriver, *pgx.Conn, pgx.Tx]()
+
+func init() {
+	if err := migrate.RegisterEngine("cockroachdb", migrate.Engine{
+		NewRunner: func(ctx context.Context, uri string, options migrate.RunnerOptions) (migrate.Runner, error) {
+			if options.TablePrefix != "" {
+				return nil, errTablePrefixUnsupported
+			}
+
+			driver, err := NewCRDBDriverContext(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			return migrate.NewRunner(CRDBMigrations, driver), nil
+		},
+		HeadRevision: CRDBMigrations.HeadRevision,
+		ValidateURI: func(uri string) error {
+			_, err := pgx.ParseConfig(uri)
+			return err
+		},
+		SQLScript: CRDBMigrations.SQLScript,
+	}); err != nil {
+		panic("failed to register migrations: " + err.Error())
+	}
+}
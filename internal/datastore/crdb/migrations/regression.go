@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// minRegressionDuration is the shortest migration duration that is reported as
+// a regression, so that the noise in the durations of quick migrations does
+// not produce warnings.
+const minRegressionDuration = 1 * time.Second
+
+// queryLoadBaseline loads the duration of the first recorded application of a
+// version, which is its baseline.
+const queryLoadBaseline = "SELECT duration_ms FROM %s WHERE version = $1 AND duration_ms > 0 ORDER BY applied_at LIMIT 1"
+
+// WithDurationRegressionFactor logs a warning when a migration takes more than
+// factor times as long as its baseline, which is the duration recorded in the
+// version history the first time the same version was applied to the
+// database, such as by an earlier release's run in a staging environment
+// whose database is migrated again. This helps catch migrations whose cost has
+// grown, e.g. quadratically with the data, before they run in production.
+// Migrations shorter than a second are never reported.
+//
+// The factor must be greater than one. By default, durations are recorded but
+// not compared.
+func WithDurationRegressionFactor(factor float64) DriverOption {
+	return func(do *driverOptions) { do.regressionFactor = factor }
+}
+
+// durationBaseline returns the baseline duration recorded in the history
+// table, which must exist, for the version and whether the duration of the
+// migration regressed from it. It must be called before the duration itself is
+// recorded.
+func (apd *CRDBDriver) durationBaseline(ctx context.Context, tx pgx.Tx, version string, duration time.Duration) (time.Duration, bool, error) {
+	if apd.regressionFactor <= 0 || duration < minRegressionDuration {
+		return 0, false, nil
+	}
+
+	var baselineMs int64
+	if err := tx.QueryRow(ctx, fmt.Sprintf(queryLoadBaseline, apd.historyTable()), version).Scan(&baselineMs); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("unable to load the baseline duration: %w", err)
+	}
+
+	baseline := time.Duration(baselineMs) * time.Millisecond
+	return baseline, float64(duration) > apd.regressionFactor*float64(baseline), nil
+}
+
+// warnOnDurationRegression logs a warning if the migration to the version
+// regressed from its baseline.
+func (apd *CRDBDriver) warnOnDurationRegression(ctx context.Context, tx pgx.Tx, version string, duration time.Duration) error {
+	baseline, regressed, err := apd.durationBaseline(ctx, tx, version, duration)
+	if err != nil || !regressed {
+		return err
+	}
+
+	log.Ctx(ctx).Warn().
+		Str("version", version).
+		Dur("duration", duration).
+		Dur("baseline", baseline).
+		Float64("factor", apd.regressionFactor).
+		Msg("migration took significantly longer than its recorded baseline")
+	return nil
+}
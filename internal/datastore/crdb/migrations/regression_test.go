@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// baselineTx is a pgx.Tx whose queries return the baseline, or no rows if it
+// is nil.
+type baselineTx struct {
+	pgx.Tx
+	baselineMs *int64
+	queries    int
+}
+
+func (tx *baselineTx) QueryRow(context.Context, string, ...any) pgx.Row {
+	tx.queries++
+	return baselineRow{tx.baselineMs}
+}
+
+type baselineRow struct {
+	baselineMs *int64
+}
+
+func (row baselineRow) Scan(dest ...any) error {
+	if row.baselineMs == nil {
+		return pgx.ErrNoRows
+	}
+	*dest[0].(*int64) = *row.baselineMs
+	return nil
+}
+
+func TestDurationBaseline(t *testing.T) {
+	ctx := context.Background()
+	baselineMs := int64(2000)
+	driver := &CRDBDriver{regressionFactor: 3}
+
+	baseline, regressed, err := driver.durationBaseline(ctx, &baselineTx{baselineMs: &baselineMs}, "v1", 5*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, baseline)
+	require.False(t, regressed)
+
+	baseline, regressed, err = driver.durationBaseline(ctx, &baselineTx{baselineMs: &baselineMs}, "v1", 7*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, baseline)
+	require.True(t, regressed)
+
+	// Without a baseline, the first application of the version becomes it.
+	_, regressed, err = driver.durationBaseline(ctx, &baselineTx{}, "v1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, regressed)
+
+	// Quick migrations, and drivers without a factor, do not load the
+	// baseline.
+	tx := &baselineTx{baselineMs: &baselineMs}
+	_, regressed, err = driver.durationBaseline(ctx, tx, "v1", 500*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, regressed)
+	_, regressed, err = (&CRDBDriver{}).durationBaseline(ctx, tx, "v1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, regressed)
+	require.Zero(t, tx.queries)
+}
+
+func TestDurationRegressionFactorValidation(t *testing.T) {
+	_, err := NewCRDBDriver("postgres://localhost:26257/db", WithDurationRegressionFactor(0.5))
+	require.ErrorContains(t, err, "must be greater than 1")
+}
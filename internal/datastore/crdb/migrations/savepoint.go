@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WithSavepoint runs fn within a savepoint of the migration transaction,
+// created by beginning a pgx pseudo nested transaction. The savepoint is
+// released if fn succeeds and rolled back to if it fails, undoing the writes fn
+// made while leaving those made earlier in the transaction in place, and fn's
+// error is returned.
+//
+// Statements run by fn, and by the rest of the migration after it, read the
+// writes made earlier in the same transaction, so a data migration can
+// backfill from its own partial writes and use savepoints to retry or skip
+// the steps that fail.
+func WithSavepoint(ctx context.Context, tx pgx.Tx, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, tx, fn)
+}
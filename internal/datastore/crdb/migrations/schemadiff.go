@@ -0,0 +1,164 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+// errRollbackDryRun is used to force the rollback of a dry-run transaction.
+var errRollbackDryRun = errors.New("dry run: rolling back")
+
+// SchemaDiff describes the tables, columns and indexes that a migration adds
+// to or drops from the schema.
+type SchemaDiff struct {
+	AddedTables    []string
+	DroppedTables  []string
+	AddedColumns   []string
+	DroppedColumns []string
+	AddedIndexes   []string
+	DroppedIndexes []string
+}
+
+// IsEmpty returns true if the migration made no changes to the schema.
+func (d SchemaDiff) IsEmpty() bool {
+	return len(d.AddedTables) == 0 && len(d.DroppedTables) == 0 &&
+		len(d.AddedColumns) == 0 && len(d.DroppedColumns) == 0 &&
+		len(d.AddedIndexes) == 0 && len(d.DroppedIndexes) == 0
+}
+
+// String returns a human-readable summary of the diff, one change per line.
+func (d SchemaDiff) String() string {
+	if d.IsEmpty() {
+		return "no schema changes"
+	}
+
+	var sb strings.Builder
+	writeLines := func(prefix, kind string, names []string) {
+		for _, name := range names {
+			fmt.Fprintf(&sb, "%s %s %s\n", prefix, kind, name)
+		}
+	}
+	writeLines("+", "table", d.AddedTables)
+	writeLines("-", "table", d.DroppedTables)
+	writeLines("+", "column", d.AddedColumns)
+	writeLines("-", "column", d.DroppedColumns)
+	writeLines("+", "index", d.AddedIndexes)
+	writeLines("-", "index", d.DroppedIndexes)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// RunTxNoCommit runs f within a transaction that is always rolled back, even
+// if f succeeds.
+func (apd *CRDBDriver) RunTxNoCommit(ctx context.Context, f migrate.TxMigrationFunc[pgx.Tx]) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	err := pgx.BeginFunc(ctx, apd.db, func(tx pgx.Tx) error {
+		if err := f(ctx, tx); err != nil {
+			return err
+		}
+		return errRollbackDryRun
+	})
+	if errors.Is(err, errRollbackDryRun) {
+		return nil
+	}
+	return err
+}
+
+// DiffMigration runs the candidate migration in a transaction that is rolled
+// back and returns the schema changes it would have made. The database is
+// left unmodified.
+func (apd *CRDBDriver) DiffMigration(ctx context.Context, candidate migrate.TxMigrationFunc[pgx.Tx]) (SchemaDiff, error) {
+	var diff SchemaDiff
+	err := apd.RunTxNoCommit(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		before, err := loadSchemaSnapshot(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := candidate(ctx, tx); err != nil {
+			return fmt.Errorf("candidate migration failed: %w", err)
+		}
+
+		after, err := loadSchemaSnapshot(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		diff = diffSnapshots(before, after)
+		return nil
+	})
+	return diff, err
+}
+
+func diffSnapshots(before, after schemaSnapshot) SchemaDiff {
+	var diff SchemaDiff
+	for tableName, afterColumns := range after.columnTypes {
+		beforeColumns, ok := before.columnTypes[tableName]
+		if !ok {
+			diff.AddedTables = append(diff.AddedTables, tableName)
+			continue
+		}
+		for columnName := range afterColumns {
+			if _, ok := beforeColumns[columnName]; !ok {
+				diff.AddedColumns = append(diff.AddedColumns, tableName+"."+columnName)
+			}
+		}
+	}
+	for tableName, beforeColumns := range before.columnTypes {
+		afterColumns, ok := after.columnTypes[tableName]
+		if !ok {
+			diff.DroppedTables = append(diff.DroppedTables, tableName)
+			continue
+		}
+		for columnName := range beforeColumns {
+			if _, ok := afterColumns[columnName]; !ok {
+				diff.DroppedColumns = append(diff.DroppedColumns, tableName+"."+columnName)
+			}
+		}
+	}
+
+	// Indexes are only reported for tables that exist on both sides; the
+	// indexes of added or dropped tables are implied by the table change.
+	for tableName, afterIndexes := range after.indexes {
+		beforeIndexes, ok := before.indexes[tableName]
+		if !ok {
+			if _, existed := before.columnTypes[tableName]; !existed {
+				continue
+			}
+		}
+		for _, indexName := range afterIndexes {
+			if !slices.Contains(beforeIndexes, indexName) {
+				diff.AddedIndexes = append(diff.AddedIndexes, indexName+" on "+tableName)
+			}
+		}
+	}
+	for tableName, beforeIndexes := range before.indexes {
+		if _, ok := after.columnTypes[tableName]; !ok {
+			continue
+		}
+		afterIndexes := after.indexes[tableName]
+		for _, indexName := range beforeIndexes {
+			if !slices.Contains(afterIndexes, indexName) {
+				diff.DroppedIndexes = append(diff.DroppedIndexes, indexName+" on "+tableName)
+			}
+		}
+	}
+
+	for _, names := range []*[]string{
+		&diff.AddedTables, &diff.DroppedTables,
+		&diff.AddedColumns, &diff.DroppedColumns,
+		&diff.AddedIndexes, &diff.DroppedIndexes,
+	} {
+		slices.Sort(*names)
+	}
+	return diff
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := schemaSnapshot{
+		columnTypes: map[string]map[string]string{
+			"namespace_config": {"namespace": "STRING", "serialized_config": "BYTES"},
+			"caveat":           {"name": "STRING", "definition": "BYTES"},
+			"old_stats":        {"id": "INT8"},
+		},
+		indexes: map[string][]string{
+			"namespace_config": {"namespace_config_pkey"},
+			"caveat":           {"caveat_pkey", "ix_caveat_old"},
+			"old_stats":        {"old_stats_pkey"},
+		},
+	}
+	after := schemaSnapshot{
+		columnTypes: map[string]map[string]string{
+			"namespace_config": {"namespace": "STRING", "serialized_config": "BYTES", "timestamp": "TIMESTAMP"},
+			"caveat":           {"name": "STRING"},
+			"new_table":        {"id": "INT8"},
+		},
+		indexes: map[string][]string{
+			"namespace_config": {"namespace_config_pkey", "ix_namespace_timestamp"},
+			"caveat":           {"caveat_pkey"},
+			"new_table":        {"new_table_pkey"},
+		},
+	}
+
+	diff := diffSnapshots(before, after)
+	require.Equal(t, SchemaDiff{
+		AddedTables:    []string{"new_table"},
+		DroppedTables:  []string{"old_stats"},
+		AddedColumns:   []string{"namespace_config.timestamp"},
+		DroppedColumns: []string{"caveat.definition"},
+		AddedIndexes:   []string{"ix_namespace_timestamp on namespace_config"},
+		DroppedIndexes: []string{"ix_caveat_old on caveat"},
+	}, diff)
+	require.Equal(t, `+ table new_table
+- table old_stats
++ column namespace_config.timestamp
+- column caveat.definition
++ index ix_namespace_timestamp on namespace_config
+- index ix_caveat_old on caveat`, diff.String())
+
+	require.True(t, diffSnapshots(before, before).IsEmpty())
+	require.Equal(t, "no schema changes", diffSnapshots(before, before).String())
+}
@@ -0,0 +1,248 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	queryColumnTypes = `SELECT table_name, column_name, data_type FROM information_schema.columns WHERE table_schema = 'public'`
+	queryIndexNames  = `SELECT tablename, indexname FROM pg_indexes WHERE schemaname = 'public'`
+)
+
+// tableSpec describes the columns (by name and information_schema data type)
+// and the explicitly named indexes that migrations produce for a table.
+type tableSpec struct {
+	columns map[string]string
+	indexes []string
+}
+
+// expectedSchema is the schema that results from running all migrations to
+// head. It must be updated whenever a migration changes the schema.
+var expectedSchema = map[string]tableSpec{
+	"namespace_config": {
+		columns: map[string]string{
+			"namespace":         "character varying",
+			"serialized_config": "bytea",
+			"timestamp":         "timestamp without time zone",
+		},
+	},
+	"relation_tuple": {
+		columns: map[string]string{
+			"namespace":         "character varying",
+			"object_id":         "character varying",
+			"relation":          "character varying",
+			"userset_namespace": "character varying",
+			"userset_object_id": "character varying",
+			"userset_relation":  "character varying",
+			"timestamp":         "timestamp without time zone",
+			"caveat_name":       "character varying",
+			"caveat_context":    "jsonb",
+			"expires_at":        "timestamp with time zone",
+		},
+		indexes: []string{
+			"pk_relation_tuple",
+			"ix_relation_tuple_by_subject",
+			"ix_relation_tuple_by_subject_relation",
+			"ix_relation_tuple_by_caveat",
+		},
+	},
+	"relation_tuple_with_integrity": {
+		columns: map[string]string{
+			"namespace":         "character varying",
+			"object_id":         "character varying",
+			"relation":          "character varying",
+			"userset_namespace": "character varying",
+			"userset_object_id": "character varying",
+			"userset_relation":  "character varying",
+			"caveat_name":       "character varying",
+			"caveat_context":    "jsonb",
+			"timestamp":         "timestamp without time zone",
+			"integrity_hash":    "bytea",
+			"integrity_key_id":  "character varying",
+			"expires_at":        "timestamp with time zone",
+		},
+		indexes: []string{
+			"pk_relation_tuple",
+			"ix_relation_tuple_with_integrity",
+			"ix_relation_tuple_with_integrity_by_caveat",
+		},
+	},
+	TableSchemaVersion: {
+		columns: map[string]string{
+			"version_num": "character varying",
+		},
+	},
+	TableSchemaVersionHistory: {
+		columns: map[string]string{
+			"version":     "character varying",
+			"replaced":    "character varying",
+			"applied_at":  "timestamp with time zone",
+			"duration_ms": "bigint",
+		},
+	},
+	TableMigrationCheckpoint: {
+		columns: map[string]string{
+			"version":      "character varying",
+			"phase":        "character varying",
+			"completed_at": "timestamp with time zone",
+		},
+	},
+	"transactions": {
+		columns: map[string]string{
+			"key":       "character varying",
+			"timestamp": "timestamp without time zone",
+		},
+	},
+	"metadata": {
+		columns: map[string]string{
+			"unique_id": "character varying",
+		},
+	},
+	"caveat": {
+		columns: map[string]string{
+			"name":       "character varying",
+			"definition": "bytea",
+			"timestamp":  "timestamp without time zone",
+		},
+		indexes: []string{"pk_caveat_v1"},
+	},
+	"relationship_counter": {
+		columns: map[string]string{
+			"name":                 "text",
+			"serialized_filter":    "bytea",
+			"current_count":        "bigint",
+			"updated_at_timestamp": "numeric",
+		},
+	},
+	"transaction_metadata": {
+		columns: map[string]string{
+			"key":        "uuid",
+			"expires_at": "timestamp with time zone",
+			"metadata":   "jsonb",
+		},
+	},
+}
+
+// queryer is implemented by both connections and transactions.
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// schemaSnapshot is the set of tables, columns and indexes found in the
+// database at a point in time.
+type schemaSnapshot struct {
+	// columnTypes maps each table to the data type of each of its columns.
+	columnTypes map[string]map[string]string
+
+	// indexes maps each table to the names of its indexes.
+	indexes map[string][]string
+}
+
+func loadSchemaSnapshot(ctx context.Context, conn queryer) (schemaSnapshot, error) {
+	snapshot := schemaSnapshot{
+		columnTypes: make(map[string]map[string]string),
+		indexes:     make(map[string][]string),
+	}
+
+	rows, err := conn.Query(ctx, queryColumnTypes)
+	if err != nil {
+		return snapshot, fmt.Errorf("unable to read columns: %w", err)
+	}
+	var tableName, columnName, dataType string
+	if _, err := pgx.ForEachRow(rows, []any{&tableName, &columnName, &dataType}, func() error {
+		if snapshot.columnTypes[tableName] == nil {
+			snapshot.columnTypes[tableName] = make(map[string]string)
+		}
+		snapshot.columnTypes[tableName][columnName] = dataType
+		return nil
+	}); err != nil {
+		return snapshot, fmt.Errorf("unable to read columns: %w", err)
+	}
+
+	rows, err = conn.Query(ctx, queryIndexNames)
+	if err != nil {
+		return snapshot, fmt.Errorf("unable to read indexes: %w", err)
+	}
+	var indexName string
+	if _, err := pgx.ForEachRow(rows, []any{&tableName, &indexName}, func() error {
+		snapshot.indexes[tableName] = append(snapshot.indexes[tableName], indexName)
+		return nil
+	}); err != nil {
+		return snapshot, fmt.Errorf("unable to read indexes: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// SchemaDriftError is returned by VerifySchema when the schema of the database
+// differs from the one produced by the migrations.
+type SchemaDriftError struct {
+	// Discrepancies describes each difference that was found.
+	Discrepancies []string
+}
+
+func (err SchemaDriftError) Error() string {
+	return "database schema does not match the schema produced by migrations: " + strings.Join(err.Discrepancies, "; ")
+}
+
+// VerifySchema compares the tables, columns and indexes of the connected
+// database against those produced by running all migrations, returning a
+// SchemaDriftError describing each discrepancy if they differ. Additional
+// tables, columns and indexes are not considered discrepancies.
+func (apd *CRDBDriver) VerifySchema(ctx context.Context) error {
+	if err := apd.checkOpen(); err != nil {
+		return err
+	}
+
+	snapshot, err := loadSchemaSnapshot(ctx, apd.db)
+	if err != nil {
+		return err
+	}
+	columnTypes, indexes := snapshot.columnTypes, snapshot.indexes
+
+	var discrepancies []string
+	for tableName, spec := range expectedSchema {
+		switch tableName {
+		case TableSchemaVersion:
+			tableName = apd.versionTable()
+		case TableSchemaVersionHistory:
+			tableName = apd.historyTable()
+		case TableMigrationCheckpoint:
+			tableName = apd.checkpointTable()
+		}
+
+		foundColumns, ok := columnTypes[tableName]
+		if !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("missing table %s", tableName))
+			continue
+		}
+
+		for columnName, expectedType := range spec.columns {
+			foundType, ok := foundColumns[columnName]
+			if !ok {
+				discrepancies = append(discrepancies, fmt.Sprintf("missing column %s.%s", tableName, columnName))
+				continue
+			}
+			if foundType != expectedType {
+				discrepancies = append(discrepancies, fmt.Sprintf("column %s.%s has type %s, expected %s", tableName, columnName, foundType, expectedType))
+			}
+		}
+
+		for _, indexName := range spec.indexes {
+			if !slices.Contains(indexes[tableName], indexName) {
+				discrepancies = append(discrepancies, fmt.Sprintf("missing index %s on %s", indexName, tableName))
+			}
+		}
+	}
+
+	if len(discrepancies) > 0 {
+		slices.Sort(discrepancies)
+		return SchemaDriftError{Discrepancies: discrepancies}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// The names of the tables in which the driver records the migration of the
+// database, before any prefix configured with WithVersionTablePrefix is
+// applied.
+const (
+	TableSchemaVersion        = "schema_version"
+	TableSchemaVersionHistory = "schema_version_history"
+	TableMigrationCheckpoint  = "schema_migration_checkpoint"
+)
+
+// versionTablePrefixRegex restricts version table prefixes to characters that
+// are valid in an unquoted identifier, leaving room for the names of the
+// tables.
+var versionTablePrefixRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,31}$`)
+
+// PrefixedTable returns the name of the given version table under the version
+// table prefix, such as `tenant1_schema_version` for TableSchemaVersion and the
+// prefix `tenant1_`. Both the driver and the datastore name the version tables
+// through it, so that they agree on the tables of a prefixed database.
+func PrefixedTable(prefix, table string) string {
+	return prefix + table
+}
+
+// ValidateVersionTablePrefix returns an error if the prefix cannot be given to
+// WithVersionTablePrefix. The empty prefix is valid.
+func ValidateVersionTablePrefix(prefix string) error {
+	if prefix != "" && !versionTablePrefixRegex.MatchString(prefix) {
+		return fmt.Errorf("invalid version table prefix %q: must be at most 32 lowercase letters, digits or underscores, not beginning with a digit", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package migrations
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithClientCert authenticates the driver's connection to the database with
+// the PEM-encoded client certificate and private key found in the given files.
+func WithClientCert(certFile, keyFile string) DriverOption {
+	return func(do *driverOptions) {
+		do.clientCertFile = certFile
+		do.clientKeyFile = keyFile
+	}
+}
+
+// WithRootCA verifies the database server's certificate against the
+// PEM-encoded CA certificates found in the given file, instead of the system
+// certificate pool. As with libpq's sslrootcert, the chain of the certificate
+// is verified under every sslmode that uses TLS, and its hostname only under
+// verify-full.
+func WithRootCA(caFile string) DriverOption {
+	return func(do *driverOptions) { do.rootCAFile = caFile }
+}
+
+func (do driverOptions) hasTLSFiles() bool {
+	return do.clientCertFile != "" || do.clientKeyFile != "" || do.rootCAFile != ""
+}
+
+// configureTLS loads the certificate files given in the options and applies
+// them to the TLS configuration of the connection, building one if the
+// connection string did not enable TLS. Like libpq, TLS is never used over a
+// unix domain socket, so the files are ignored for socket connections.
+//
+// Also like libpq, a root CA given with WithRootCA is used to verify the chain
+// of the server's certificate under every sslmode that uses TLS, while its
+// hostname is only checked under verify-full: sslmode=verify-ca, require,
+// prefer and allow verify the chain alone. A connection string that did not
+// enable TLS is upgraded to verify-full.
+func (do driverOptions) configureTLS(connConfig *pgx.ConnConfig) error {
+	if !do.hasTLSFiles() || isUnixSocket(connConfig.Host, connConfig.Port) {
+		return nil
+	}
+
+	var certificates []tls.Certificate
+	if do.clientCertFile != "" || do.clientKeyFile != "" {
+		if do.clientCertFile == "" || do.clientKeyFile == "" {
+			return errors.New("both a client certificate and key file must be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(do.clientCertFile, do.clientKeyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		certificates = []tls.Certificate{cert}
+	}
+
+	var rootCAs *x509.CertPool
+	if do.rootCAFile != "" {
+		caPEM, err := os.ReadFile(do.rootCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read root CA file: %w", err)
+		}
+
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in root CA file %s", do.rootCAFile)
+		}
+	}
+
+	if connConfig.TLSConfig == nil {
+		connConfig.TLSConfig = &tls.Config{
+			ServerName: connConfig.Host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	connConfig.TLSConfig = withTLSFiles(connConfig.TLSConfig, certificates, rootCAs)
+
+	// Only the fallbacks that already use TLS are updated, so that an
+	// sslmode permitting plaintext keeps doing so.
+	for _, fallback := range connConfig.Fallbacks {
+		if fallback.TLSConfig != nil {
+			fallback.TLSConfig = withTLSFiles(fallback.TLSConfig, certificates, rootCAs)
+		}
+	}
+	return nil
+}
+
+// withTLSFiles returns a copy of the TLS configuration presenting the client
+// certificates, if any, and verifying the server's certificate against the
+// root CAs, if not nil. A configuration that skips the standard verification,
+// as pgx builds for the sslmodes other than verify-full, verifies the chain
+// alone.
+func withTLSFiles(tlsConfig *tls.Config, certificates []tls.Certificate, rootCAs *x509.CertPool) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+	if certificates != nil {
+		tlsConfig.Certificates = certificates
+	}
+	if rootCAs != nil {
+		tlsConfig.RootCAs = rootCAs
+		if tlsConfig.InsecureSkipVerify {
+			tlsConfig.VerifyPeerCertificate = verifyCertificateChain(rootCAs)
+		}
+	}
+	return tlsConfig
+}
+
+// verifyCertificateChain returns a tls.Config.VerifyPeerCertificate function
+// verifying that the server's certificate chains to one of the root CAs,
+// without checking its hostname, as under the verify-ca sslmode.
+func verifyCertificateChain(rootCAs *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server presented no certificate")
+		}
+
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("unable to parse server certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		opts := x509.VerifyOptions{
+			Roots:         rootCAs,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(opts); err != nil {
+			return fmt.Errorf("unable to verify server certificate: %w", err)
+		}
+		return nil
+	}
+}
+
+func isUnixSocket(host string, port uint16) bool {
+	network, _ := pgconn.NetworkAddress(host, port)
+	return network == "unix"
+}
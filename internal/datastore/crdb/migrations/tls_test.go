@@ -0,0 +1,247 @@
+package migrations
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(1 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0o600))
+	return certFile, keyFile
+}
+
+func TestConfigureTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+
+	parse := func(t *testing.T, url string) *pgx.ConnConfig {
+		connConfig, err := pgx.ParseConfig(url)
+		require.NoError(t, err)
+		return connConfig
+	}
+
+	t.Run("no files", func(t *testing.T) {
+		connConfig := parse(t, "postgres://root@localhost:26257/defaultdb?sslmode=disable")
+		require.NoError(t, driverOptions{}.configureTLS(connConfig))
+		require.Nil(t, connConfig.TLSConfig)
+	})
+
+	t.Run("client cert and root CA", func(t *testing.T) {
+		connConfig := parse(t, "postgres://root@localhost:26257/defaultdb?sslmode=disable")
+
+		var options driverOptions
+		WithClientCert(certFile, keyFile)(&options)
+		WithRootCA(certFile)(&options)
+		require.NoError(t, options.configureTLS(connConfig))
+
+		require.NotNil(t, connConfig.TLSConfig)
+		require.Len(t, connConfig.TLSConfig.Certificates, 1)
+		require.NotNil(t, connConfig.TLSConfig.RootCAs)
+		require.Equal(t, "localhost", connConfig.TLSConfig.ServerName)
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		var options driverOptions
+		WithClientCert(filepath.Join(dir, "missing.crt"), keyFile)(&options)
+		require.Error(t, options.configureTLS(parse(t, "postgres://root@localhost:26257/defaultdb")))
+
+		options = driverOptions{}
+		WithRootCA(filepath.Join(dir, "missing.crt"))(&options)
+		require.Error(t, options.configureTLS(parse(t, "postgres://root@localhost:26257/defaultdb")))
+	})
+
+	t.Run("invalid root CA", func(t *testing.T) {
+		var options driverOptions
+		WithRootCA(keyFile)(&options)
+		require.ErrorContains(t, options.configureTLS(parse(t, "postgres://root@localhost:26257/defaultdb")), "no certificates found")
+	})
+
+	t.Run("constructor returns errors", func(t *testing.T) {
+		_, err := NewCRDBDriver("postgres://root@localhost:26257/defaultdb", WithRootCA(filepath.Join(dir, "missing.crt")))
+		require.ErrorContains(t, err, "unable to read root CA file")
+	})
+}
+
+func TestConnConfigUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+
+	for _, url := range []string{
+		"postgresql://root@/defaultdb?host=/var/run/cockroach&port=26257",
+		"host=/var/run/cockroach port=26257 user=root dbname=defaultdb",
+	} {
+		t.Run(url, func(t *testing.T) {
+			var options driverOptions
+			WithClientCert(certFile, keyFile)(&options)
+			WithDriverQueryExecMode(pgx.QueryExecModeExec)(&options)
+
+			connConfig, err := options.connConfig(url)
+			require.NoError(t, err)
+			require.Equal(t, "/var/run/cockroach", connConfig.Host)
+			require.Equal(t, uint16(26257), connConfig.Port)
+			require.Equal(t, "defaultdb", connConfig.Database)
+			require.Equal(t, pgx.QueryExecModeExec, connConfig.DefaultQueryExecMode)
+			require.True(t, isUnixSocket(connConfig.Host, connConfig.Port))
+
+			// TLS is not used over the socket, even when certificates are given.
+			require.Nil(t, connConfig.TLSConfig)
+		})
+	}
+}
+
+func TestVersionTablePrefixValidation(t *testing.T) {
+	for _, prefix := range []string{"Tenant_", "1tenant_", "tenant-1_", "tenant;drop_", "a_very_long_prefix_exceeding_the_limit_"} {
+		_, err := NewCRDBDriver("postgresql://root@localhost:26257/defaultdb", WithVersionTablePrefix(prefix))
+		require.ErrorContains(t, err, "invalid version table prefix", prefix)
+	}
+
+	driver := &CRDBDriver{versionTablePrefix: "tenant1_"}
+	require.Equal(t, "tenant1_schema_version", driver.versionTable())
+	require.Equal(t, "tenant1_schema_version_history", driver.historyTable())
+	require.Equal(t, "tenant1_schema_migration_checkpoint", driver.checkpointTable())
+	require.Equal(t, "schema_version", (&CRDBDriver{}).versionTable())
+}
+
+// newTestCA returns a self-signed CA certificate, as PEM written to a file in
+// the directory, and its key.
+func newTestCA(t *testing.T, dir, name string) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(1 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certBytes)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(dir, name+".crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0o600))
+	return cert, key, caFile
+}
+
+// newTestServerCert returns a server certificate for the host, signed by the
+// CA.
+func newTestServerCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, host string) tls.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{certBytes}, PrivateKey: key}
+}
+
+// handshake runs a TLS handshake between a client with the configuration and
+// a server presenting the certificate, returning the client's error.
+func handshake(t *testing.T, clientConfig *tls.Config, serverCert tls.Certificate) error {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}, MinVersion: tls.VersionTLS12})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_ = conn.Close()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestConfigureTLSVerification(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caFile := newTestCA(t, dir, "ca")
+	_, _, wrongCAFile := newTestCA(t, dir, "wrong-ca")
+
+	// The server's certificate is issued for a name other than the host to
+	// which the driver connects.
+	serverCert := newTestServerCert(t, ca, caKey, "db.example.com")
+	matchingServerCert := newTestServerCert(t, ca, caKey, "localhost")
+
+	for _, tc := range []struct {
+		sslmode       string
+		checkHostname bool
+	}{
+		{"verify-full", true},
+		{"verify-ca", false},
+		{"require", false},
+		{"prefer", false},
+	} {
+		t.Run(tc.sslmode, func(t *testing.T) {
+			config := func(t *testing.T, rootCAFile string) *tls.Config {
+				connConfig, err := pgx.ParseConfig("postgres://root@localhost:26257/defaultdb?sslmode=" + tc.sslmode)
+				require.NoError(t, err)
+
+				var options driverOptions
+				WithRootCA(rootCAFile)(&options)
+				require.NoError(t, options.configureTLS(connConfig))
+				require.NotNil(t, connConfig.TLSConfig)
+				return connConfig.TLSConfig
+			}
+
+			require.NoError(t, handshake(t, config(t, caFile), matchingServerCert))
+			require.Error(t, handshake(t, config(t, wrongCAFile), matchingServerCert), "a certificate from another CA must be rejected")
+
+			err := handshake(t, config(t, caFile), serverCert)
+			if tc.checkHostname {
+				require.Error(t, err, "the hostname must be checked")
+			} else {
+				require.NoError(t, err, "only the chain must be verified")
+			}
+			require.Error(t, handshake(t, config(t, wrongCAFile), serverCert))
+		})
+	}
+}
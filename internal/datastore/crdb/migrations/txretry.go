@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+const (
+	// defaultTxMaxRetries is the default number of times a migration
+	// transaction is retried after a serialization failure.
+	defaultTxMaxRetries = 5
+
+	txRetryInitialInterval = 50 * time.Millisecond
+	txRetryMaxInterval     = time.Second
+)
+
+// WithTxMaxRetries sets the number of times a transaction run by the driver,
+// such as that of a migration step, is re-run, with backoff, after it fails
+// with a serialization failure (SQLSTATE 40001), as CockroachDB returns under
+// contention. Such a transaction has been rolled back, so it is re-run in
+// full, including the recording of the new version. Other errors are returned
+// without retrying. Zero disables the retries.
+//
+// By default, a transaction is retried 5 times.
+func WithTxMaxRetries(retries int) DriverOption {
+	return func(do *driverOptions) { do.txMaxRetries = retries }
+}
+
+// retrySerializationFailures runs fn, re-running it with backoff for as long
+// as it fails with a serialization failure, for at most maxRetries retries.
+func retrySerializationFailures(ctx context.Context, maxRetries int, fn func() error) error {
+	retryBackoff := backoff.NewExponentialBackOff()
+	retryBackoff.InitialInterval = txRetryInitialInterval
+	retryBackoff.MaxInterval = txRetryMaxInterval
+	retryBackoff.MaxElapsedTime = 0
+	retryBackoff.Reset()
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !pool.IsSerializationFailure(err) {
+			return err
+		}
+		if attempt >= maxRetries {
+			if maxRetries == 0 {
+				return err
+			}
+			return fmt.Errorf("transaction failed after %d attempts: %w", attempt+1, err)
+		}
+
+		wait := retryBackoff.NextBackOff()
+		log.Ctx(ctx).Warn().Err(err).Int("attempt", attempt+1).Dur("retryIn", wait).Msg("retrying migration transaction after serialization failure")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
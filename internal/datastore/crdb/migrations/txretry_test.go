@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrySerializationFailures(t *testing.T) {
+	serializationFailure := &pgconn.PgError{Code: "40001", Message: "restart transaction"}
+	ctx := context.Background()
+
+	t.Run("retried until it succeeds", func(t *testing.T) {
+		attempts := 0
+		err := retrySerializationFailures(ctx, 5, func() error {
+			attempts++
+			if attempts < 3 {
+				return serializationFailure
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		attempts := 0
+		failure := errors.New("syntax error")
+		err := retrySerializationFailures(ctx, 5, func() error {
+			attempts++
+			return failure
+		})
+		require.ErrorIs(t, err, failure)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		attempts := 0
+		err := retrySerializationFailures(ctx, 2, func() error {
+			attempts++
+			return serializationFailure
+		})
+		require.ErrorContains(t, err, "transaction failed after 3 attempts")
+		require.ErrorIs(t, err, serializationFailure)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("retries disabled", func(t *testing.T) {
+		attempts := 0
+		err := retrySerializationFailures(ctx, 0, func() error {
+			attempts++
+			return serializationFailure
+		})
+		require.Equal(t, serializationFailure, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		attempts := 0
+		err := retrySerializationFailures(canceled, 5, func() error {
+			attempts++
+			return serializationFailure
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, attempts)
+	})
+}
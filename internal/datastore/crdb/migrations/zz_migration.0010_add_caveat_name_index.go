@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+const (
+	// The indexes are partial, as most relationships are not caveated. They
+	// back the listing of the relationships with a caveat and the check for
+	// unreferenced caveats made by GC.
+	createCaveatNameIndex = `CREATE INDEX IF NOT EXISTS ix_relation_tuple_by_caveat ON relation_tuple (caveat_name) WHERE caveat_name IS NOT NULL;`
+
+	createIntegrityCaveatNameIndex = `CREATE INDEX IF NOT EXISTS ix_relation_tuple_with_integrity_by_caveat ON relation_tuple_with_integrity (caveat_name) WHERE caveat_name IS NOT NULL;`
+)
+
+func init() {
+	err := CRDBMigrations.Register("add-caveat-name-index", "add-expiration-support", addCaveatNameIndex, noAtomicMigration,
+		migrate.WithSQL(createCaveatNameIndex, createIntegrityCaveatNameIndex))
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addCaveatNameIndex(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, createCaveatNameIndex); err != nil {
+		return fmt.Errorf("failed to create caveat name index on relation_tuple table: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, createIntegrityCaveatNameIndex); err != nil {
+		return fmt.Errorf("failed to create caveat name index on relation_tuple_with_integrity table: %w", err)
+	}
+
+	return nil
+}
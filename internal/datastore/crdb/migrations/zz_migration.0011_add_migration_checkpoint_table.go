@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+// createMigrationCheckpointTable creates the table in which the completed
+// phases of phased migrations are recorded. The table of a database migrated
+// with WithVersionTablePrefix is instead created with its version table, by the first
+// migration.
+var createMigrationCheckpointTable = fmt.Sprintf(queryCreateCheckpointTable, TableMigrationCheckpoint)
+
+func init() {
+	err := CRDBMigrations.Register("add-migration-checkpoint-table", "add-caveat-name-index", noNonAtomicMigration, addMigrationCheckpointTable,
+		migrate.WithSQL(createMigrationCheckpointTable))
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addMigrationCheckpointTable(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, createMigrationCheckpointTable); err != nil {
+		return fmt.Errorf("failed to create migration checkpoint table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+// createVersionHistoryTable creates the table in which the migrations applied
+// to the database are recorded, for the databases whose first migration ran
+// before the table existed. The first migration of any other database creates
+// the table, possibly prefixed, when it writes its version, so that the
+// history begins with it.
+var createVersionHistoryTable = fmt.Sprintf(queryCreateHistoryTable, TableSchemaVersionHistory)
+
+func init() {
+	err := CRDBMigrations.Register("add-version-history-table", "add-migration-checkpoint-table", noNonAtomicMigration, addVersionHistoryTable,
+		migrate.WithSQL(createVersionHistoryTable))
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addVersionHistoryTable(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, createVersionHistoryTable); err != nil {
+		return fmt.Errorf("failed to create version history table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package migrations
+
+// The statements of the migrations that shipped before SQLScript existed are
+// declared here, after their registration, so that the migrations themselves
+// remain unchanged.
+func init() {
+	declarations := []struct {
+		version    string
+		statements []string
+	}{
+		{"initial", []string{
+			createNamespaceConfig,
+			createRelationTuple,
+			createSchemaVersion,
+			insertEmptyVersion,
+			createReverseQueryIndex,
+			createReverseCheckIndex,
+		}},
+		{"add-transactions-table", []string{createTransactions}},
+		{"add-caveats", []string{createCaveatTable, addRelationshipCaveatContext}},
+		{"remove-stats-table", []string{dropStatsTable}},
+		{"add-relationship-counters-table", []string{addRelationshipCountersTableQuery}},
+		{"add-integrity-relationtuple-table", []string{createRelationTupleWithIntegrityTable, createIntegrityTupleIndex}},
+	}
+
+	for _, declaration := range declarations {
+		if err := CRDBMigrations.DeclareSQL(declaration.version, declaration.statements...); err != nil {
+			panic("failed to declare migration SQL: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// MinimumRevisionPolicy determines the revision read by SnapshotReaderAtLeast
+// for a minimum revision.
+type MinimumRevisionPolicy int
+
+const (
+	// MinimumRevisionExact reads at exactly the minimum revision, which must
+	// be valid per CheckRevision.
+	MinimumRevisionExact MinimumRevisionPolicy = iota
+
+	// MinimumRevisionLatestIfPast reads at the latest revision, the optimized
+	// revision or, if that precedes the minimum, the head revision, provided
+	// it is at or after the minimum revision. A minimum the datastore has yet
+	// to reach fails with a datastore.InvalidRevisionError. A minimum older
+	// than the GC window is satisfied by any readable revision, so it does not
+	// fail.
+	MinimumRevisionLatestIfPast
+
+	// MinimumRevisionWait waits, as AwaitRevision does, until the datastore
+	// has reached the minimum revision and then reads at it.
+	MinimumRevisionWait
+)
+
+// SnapshotReaderAtLeast returns a reader at a revision at least as fresh as
+// the minimum revision, chosen per the policy, along with that revision. It
+// underpins the consistency modes that read at, or after, a revision known to
+// the client, such as one returned by a write.
+func (cds *crdbDatastore) SnapshotReaderAtLeast(ctx context.Context, minimum datastore.Revision, policy MinimumRevisionPolicy) (datastore.Reader, datastore.Revision, error) {
+	if err := cds.checkOpen(); err != nil {
+		return nil, datastore.NoRevision, err
+	}
+
+	var revision datastore.Revision
+	switch policy {
+	case MinimumRevisionExact:
+		if err := cds.CheckRevision(ctx, minimum); err != nil {
+			return nil, datastore.NoRevision, err
+		}
+		revision = minimum
+
+	case MinimumRevisionLatestIfPast:
+		latest, err := cds.OptimizedRevision(ctx)
+		if err != nil {
+			return nil, datastore.NoRevision, err
+		}
+		if latest.LessThan(minimum) {
+			latest, err = cds.HeadRevision(ctx)
+			if err != nil {
+				return nil, datastore.NoRevision, err
+			}
+			if latest.LessThan(minimum) {
+				return nil, datastore.NoRevision, datastore.NewInvalidRevisionErr(minimum, datastore.RevisionInFuture)
+			}
+		}
+		revision = latest
+
+	case MinimumRevisionWait:
+		if err := cds.AwaitRevision(ctx, minimum); err != nil {
+			return nil, datastore.NoRevision, err
+		}
+		revision = minimum
+
+	default:
+		return nil, datastore.NoRevision, fmt.Errorf("unknown minimum revision policy: %d", policy)
+	}
+
+	return cds.SnapshotReader(revision), revision, nil
+}
@@ -2,92 +2,421 @@ package crdb
 
 import (
 	"fmt"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/benbjohnson/clock"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/metric"
+
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
 	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+	"github.com/authzed/spicedb/internal/datastore/revisions"
 	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
 )
 
 type crdbOptions struct {
 	readPoolOpts, writePoolOpts pgxcommon.PoolOptions
 	connectRate                 time.Duration
+	maxOpenConnsPerProc         int
 
 	watchBufferLength              uint16
+	watchBufferLengthByType        map[string]uint16
 	watchBufferWriteTimeout        time.Duration
+	watchBufferOverflowPolicy      string
 	watchConnectTimeout            time.Duration
+	maxWatchCatchupWindow          time.Duration
+	revisionHeartbeatInterval      time.Duration
+	healthLogInterval              time.Duration
+	prewarmRevisionCache           bool
+	closeTimeout                   time.Duration
+	watchCoalesceWindow            time.Duration
+	watchBatchSize                 uint16
+	watchBatchMaxLatency           time.Duration
+	watchCompressionThreshold      int
 	revisionQuantization           time.Duration
+	quantizationAlignment          revisions.QuantizationAlignment
+	maxCachedRevisionAge           time.Duration
+	allowUnsafeConfig              bool
+	allowDestructiveOperations     bool
 	followerReadDelay              time.Duration
 	maxRevisionStalenessPercent    float64
 	gcWindow                       time.Duration
 	maxRetries                     uint8
+	retryBudgetRate                float64
+	retryBudgetBurst               int
 	overlapStrategy                string
 	overlapKey                     string
 	enableConnectionBalancing      bool
+	nodeHealthCheckInterval        time.Duration
 	analyzeBeforeStatistics        bool
 	filterMaximumIDCount           uint16
+	writeBatchSize                 int
+	readPageSize                   int
+	maxListResultsLimit            *int
+	maxConcurrentWatches           *int
+	maxTotalWatchBufferBytes       *int
+	checkIndexHint                 string
+	logger                         *zerolog.Logger
+	gcMaxConcurrentDeletes         int
+	gcCaveats                      bool
+	gcDanglingRelationships        bool
+	gcQualityOfService             string
+	writeTransactionPriority       string
+	duplicateWritePolicy           string
+	maxRowsPerTransaction          int
+	maxTuplesPerWrite              *int
+	writeConnsMaxQueueDepth        int32
 	enablePrometheusStats          bool
 	withIntegrity                  bool
 	allowedMigrations              []string
+	migrationTablePrefix           string
 	columnOptimizationOption       common.ColumnOptimizationOption
 	includeQueryParametersInTraces bool
 	expirationDisabled             bool
+	validateSchemaOnOpen           bool
+	validateCaveatsOnWrite         bool
+	advertisedRevisionMetric       bool
+	metricsDisabled                bool
+	metrics                        pool.Metrics
+	validateConnBeforeAcquire      bool
+	resetQueryOnRelease            string
+	connectTimeout                 time.Duration
+	tcpKeepaliveInterval           time.Duration
+	tcpKeepaliveCount              int
+	queryTimeout                   time.Duration
+	slowQueryThreshold             time.Duration
+	retryLogSampleRate             int
+	vectorize                      string
+	connectionLabel                string
+	clock                          clock.Clock
+	metadataColumns                []string
+	revisionAdvancedCallback       func(datastore.Revision)
+	changeSink                     ChangeSink
+	changeSinkBufferLength         uint16
+	readOnlyReadPool               bool
+	statementLabels                bool
+	deadlineStatementTimeouts      bool
+	fairPoolAcquisition            bool
+	readOnlyMode                   bool
+	readReplica                    bool
+	queryExecMode                  *pgx.QueryExecMode
+	statementCacheCapacity         *int
+	descriptionCacheCapacity       *int
+	simpleProtocolFallback         bool
+	connectionCallbacks            pool.ConnectionCallbacks
+	futureRevisionPolicy           revisions.FutureRevisionPolicy
+	futureRevisionMaxWait          time.Duration
+	backwardsRevisionPolicy        revisions.BackwardsRevisionPolicy
+	poolRefillMode                 pool.RefillMode
 }
 
 const (
 	errQuantizationTooLarge = "revision quantization (%s) must be less than GC window (%s)"
+	errNegativeConns        = "%s connection %s (%d) must not be negative"
+	errMinOpenConnsTooLarge = "%s connection min open (%d) must not exceed the %s connection max open (%d)"
 
 	overlapStrategyRequest  = "request"
 	overlapStrategyPrefix   = "prefix"
 	overlapStrategyStatic   = "static"
 	overlapStrategyInsecure = "insecure"
 
+	// FutureRevisionError fails requests made at a revision newer than the
+	// datastore's current revision.
+	FutureRevisionError = revisions.FutureRevisionError
+
+	// FutureRevisionWait waits, for a bounded amount of time, for the
+	// datastore to catch up to a revision newer than its current revision.
+	FutureRevisionWait = revisions.FutureRevisionWait
+
+	// PoolRefillEager dials all of the connections missing from a pool at
+	// once.
+	PoolRefillEager = pool.RefillEager
+
+	// PoolRefillGradual dials the connections missing from a pool one at a
+	// time, at the ConnectRate.
+	PoolRefillGradual = pool.RefillGradual
+
+	// ClampBackwardsRevision advertises the latest revision previously read
+	// when the cluster's current revision goes backwards, and logs a warning.
+	ClampBackwardsRevision = revisions.ClampBackwardsRevision
+
+	// FailBackwardsRevision fails the computation of the advertised revision
+	// with a revisions.RevisionWentBackwardsError when the cluster's current
+	// revision goes backwards.
+	FailBackwardsRevision = revisions.FailBackwardsRevision
+
+	// RelativeEpoch begins a quantization window at each multiple of the
+	// revision quantization since the Unix epoch.
+	RelativeEpoch = revisions.RelativeEpochAlignment
+
+	// WallClock begins a quantization window at each UTC wall-clock second,
+	// minute, hour or day boundary, whichever is the shortest that is at least
+	// as long as the revision quantization.
+	WallClock = revisions.WallClockAlignment
+
+	vectorizeOn                 = "on"
+	vectorizeOff                = "off"
+	vectorizeAuto               = "auto"
+	vectorizeExperimentalAlways = "experimental_always"
+
+	qualityOfServiceBackground = "background"
+	qualityOfServiceRegular    = "regular"
+	qualityOfServiceCritical   = "critical"
+
+	// TransactionPriorityLow makes write transactions lose conflicts against
+	// those of a higher priority.
+	TransactionPriorityLow = "low"
+
+	// TransactionPriorityNormal is CockroachDB's default priority.
+	TransactionPriorityNormal = "normal"
+
+	// TransactionPriorityHigh makes write transactions win conflicts against
+	// those of a lower priority.
+	TransactionPriorityHigh = "high"
+
+	// DuplicateWriteError fails writes that create a relationship that already
+	// exists.
+	DuplicateWriteError = "error"
+
+	// DuplicateWriteIgnore leaves a relationship that already exists unchanged
+	// when a write creates it.
+	DuplicateWriteIgnore = "ignore"
+
+	// DuplicateWriteUpsert updates a relationship that already exists, as a
+	// touch does, when a write creates it.
+	DuplicateWriteUpsert = "upsert"
+
+	// WatchBufferOverflowBlock waits, for at most the WatchBufferWriteTimeout,
+	// for the consumer of a watch whose buffer is full to make room in it, and
+	// then disconnects the watch with a datastore.WatchDisconnectedError.
+	WatchBufferOverflowBlock = "block"
+
+	// WatchBufferOverflowDropOldest discards the oldest change in the buffer
+	// of a watch whose buffer is full to make room for the next one.
+	WatchBufferOverflowDropOldest = "drop-oldest"
+
+	// WatchBufferOverflowError disconnects a watch whose buffer is full with a
+	// datastore.WatchDisconnectedError, without waiting.
+	WatchBufferOverflowError = "error"
+
+	defaultGCWindow                    = 24 * time.Hour
 	defaultRevisionQuantization        = 5 * time.Second
 	defaultFollowerReadDelay           = 0 * time.Second
 	defaultMaxRevisionStalenessPercent = 0.1
 	defaultWatchBufferLength           = 128
 	defaultWatchBufferWriteTimeout     = 1 * time.Second
 	defaultWatchConnectTimeout         = 1 * time.Second
+	defaultRevisionHeartbeatInterval   = 5 * time.Second
+	defaultWatchBatchMaxLatency        = 100 * time.Millisecond
+	defaultCloseTimeout                = 5 * time.Second
+	defaultRetryLogSampleRate          = 1
 	defaultSplitSize                   = 1024
 
-	defaultMaxRetries      = 5
-	defaultOverlapKey      = "defaultsynckey"
-	defaultOverlapStrategy = overlapStrategyStatic
+	defaultMaxRetries       = 5
+	defaultRetryBudgetRate  = 0
+	defaultRetryBudgetBurst = 100
+	defaultOverlapKey       = "defaultsynckey"
+	defaultOverlapStrategy  = overlapStrategyStatic
 
 	defaultEnablePrometheusStats          = false
 	defaultEnableConnectionBalancing      = true
 	defaultConnectRate                    = 100 * time.Millisecond
+	defaultNodeHealthCheckInterval        = 5 * time.Second
 	defaultFilterMaximumIDCount           = 100
+	defaultWriteBatchSize                 = 1000
+	defaultReadPageSize                   = 1000
+	defaultGCMaxConcurrentDeletes         = 1
+	defaultGCQualityOfService             = qualityOfServiceBackground
+	defaultGCCaveats                      = true
+	defaultWriteTransactionPriority       = TransactionPriorityNormal
+	defaultDuplicateWritePolicy           = DuplicateWriteError
+	defaultWatchBufferOverflowPolicy      = WatchBufferOverflowBlock
 	defaultWithIntegrity                  = false
 	defaultColumnOptimizationOption       = common.ColumnOptimizationOptionNone
 	defaultIncludeQueryParametersInTraces = false
 	defaultExpirationDisabled             = false
+	defaultValidateSchemaOnOpen           = false
+	defaultAdvertisedRevisionMetric       = false
+	defaultValidateConnBeforeAcquire      = false
+	defaultReadOnlyReadPool               = true
 )
 
+// connectionLabelRegex restricts connection labels to characters that are safe
+// to use in a session setting.
+var connectionLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-.:/]{1,63}$`)
+
+// OptionDefaults contains the values used by the datastore for options that
+// are not explicitly configured.
+type OptionDefaults struct {
+	GCWindow                       time.Duration
+	RevisionQuantization           time.Duration
+	FollowerReadDelay              time.Duration
+	MaxRevisionStalenessPercent    float64
+	WatchBufferLength              uint16
+	WatchBufferWriteTimeout        time.Duration
+	WatchBufferOverflowPolicy      string
+	WatchConnectTimeout            time.Duration
+	RevisionHeartbeatInterval      time.Duration
+	CloseTimeout                   time.Duration
+	RetryLogSampleRate             int
+	MaxRetries                     uint8
+	RetryBudgetRate                float64
+	RetryBudgetBurst               int
+	OverlapStrategy                string
+	OverlapKey                     string
+	EnablePrometheusStats          bool
+	EnableConnectionBalancing      bool
+	NodeHealthCheckInterval        time.Duration
+	ConnectRate                    time.Duration
+	FilterMaximumIDCount           uint16
+	WriteBatchSize                 int
+	ReadPageSize                   int
+	GCMaxConcurrentDeletes         int
+	GCQualityOfService             string
+	GCCaveats                      bool
+	WriteTransactionPriority       string
+	DuplicateWritePolicy           string
+	WithIntegrity                  bool
+	IncludeQueryParametersInTraces bool
+	ExpirationDisabled             bool
+	ReadOnlyReadPool               bool
+}
+
+// Defaults returns the default values of the datastore's options.
+func Defaults() OptionDefaults {
+	return OptionDefaults{
+		GCWindow:                       defaultGCWindow,
+		RevisionQuantization:           defaultRevisionQuantization,
+		FollowerReadDelay:              defaultFollowerReadDelay,
+		MaxRevisionStalenessPercent:    defaultMaxRevisionStalenessPercent,
+		WatchBufferLength:              defaultWatchBufferLength,
+		WatchBufferWriteTimeout:        defaultWatchBufferWriteTimeout,
+		WatchBufferOverflowPolicy:      defaultWatchBufferOverflowPolicy,
+		WatchConnectTimeout:            defaultWatchConnectTimeout,
+		RevisionHeartbeatInterval:      defaultRevisionHeartbeatInterval,
+		CloseTimeout:                   defaultCloseTimeout,
+		RetryLogSampleRate:             defaultRetryLogSampleRate,
+		MaxRetries:                     defaultMaxRetries,
+		RetryBudgetRate:                defaultRetryBudgetRate,
+		RetryBudgetBurst:               defaultRetryBudgetBurst,
+		OverlapStrategy:                defaultOverlapStrategy,
+		OverlapKey:                     defaultOverlapKey,
+		EnablePrometheusStats:          defaultEnablePrometheusStats,
+		EnableConnectionBalancing:      defaultEnableConnectionBalancing,
+		NodeHealthCheckInterval:        defaultNodeHealthCheckInterval,
+		ConnectRate:                    defaultConnectRate,
+		FilterMaximumIDCount:           defaultFilterMaximumIDCount,
+		WriteBatchSize:                 defaultWriteBatchSize,
+		ReadPageSize:                   defaultReadPageSize,
+		GCMaxConcurrentDeletes:         defaultGCMaxConcurrentDeletes,
+		GCQualityOfService:             defaultGCQualityOfService,
+		GCCaveats:                      defaultGCCaveats,
+		WriteTransactionPriority:       defaultWriteTransactionPriority,
+		DuplicateWritePolicy:           defaultDuplicateWritePolicy,
+		WithIntegrity:                  defaultWithIntegrity,
+		IncludeQueryParametersInTraces: defaultIncludeQueryParametersInTraces,
+		ExpirationDisabled:             defaultExpirationDisabled,
+		ReadOnlyReadPool:               defaultReadOnlyReadPool,
+	}
+}
+
+// validateConnLifetimes checks that connections of the pool are not allowed to
+// idle for longer than they may live, since such an idle timeout never takes
+// effect. An idle timeout equal to the lifetime, as in the defaults of the
+// command line, is accepted.
+func validateConnLifetimes(pool string, opts pgxcommon.PoolOptions) error {
+	if opts.ConnMaxIdleTime == nil || opts.ConnMaxLifetime == nil {
+		return nil
+	}
+
+	if *opts.ConnMaxIdleTime > *opts.ConnMaxLifetime {
+		return fmt.Errorf("%s connection max idle time (%s) must not exceed the %s connection max lifetime (%s)", pool, *opts.ConnMaxIdleTime, pool, *opts.ConnMaxLifetime)
+	}
+	return nil
+}
+
+// validateOpenConns ensures that the pool's minimum and maximum number of
+// connections are not negative and that the minimum does not exceed the
+// maximum, which the pool would otherwise keep trying to grow past.
+func validateOpenConns(pool string, opts pgxcommon.PoolOptions) error {
+	if opts.MinOpenConns != nil && *opts.MinOpenConns < 0 {
+		return fmt.Errorf(errNegativeConns, pool, "min open", *opts.MinOpenConns)
+	}
+	if opts.MaxOpenConns != nil && *opts.MaxOpenConns < 0 {
+		return fmt.Errorf(errNegativeConns, pool, "max open", *opts.MaxOpenConns)
+	}
+	if opts.MinOpenConns != nil && opts.MaxOpenConns != nil && *opts.MinOpenConns > *opts.MaxOpenConns {
+		return fmt.Errorf(errMinOpenConnsTooLarge, pool, *opts.MinOpenConns, pool, *opts.MaxOpenConns)
+	}
+	return nil
+}
+
+// validateMinIdleConns ensures that the minimum number of idle connections of
+// the pool can be kept within its maximum size.
+func validateMinIdleConns(pool string, opts pgxcommon.PoolOptions) error {
+	if opts.MinIdleConns == nil {
+		return nil
+	}
+
+	if *opts.MinIdleConns < 0 {
+		return fmt.Errorf("%s connection min idle (%d) must not be negative", pool, *opts.MinIdleConns)
+	}
+	if opts.MaxOpenConns != nil && *opts.MinIdleConns > *opts.MaxOpenConns {
+		return fmt.Errorf("%s connection min idle (%d) must not exceed the %s connection max open (%d)", pool, *opts.MinIdleConns, pool, *opts.MaxOpenConns)
+	}
+	return nil
+}
+
 // Option provides the facility to configure how clients within the CRDB
 // datastore interact with the running CockroachDB database.
 type Option func(*crdbOptions)
 
 func generateConfig(options []Option) (crdbOptions, error) {
 	computed := crdbOptions{
-		gcWindow:                       24 * time.Hour,
+		gcWindow:                       defaultGCWindow,
 		watchBufferLength:              defaultWatchBufferLength,
 		watchBufferWriteTimeout:        defaultWatchBufferWriteTimeout,
+		watchBufferOverflowPolicy:      defaultWatchBufferOverflowPolicy,
 		watchConnectTimeout:            defaultWatchConnectTimeout,
+		revisionHeartbeatInterval:      defaultRevisionHeartbeatInterval,
+		closeTimeout:                   defaultCloseTimeout,
+		retryLogSampleRate:             defaultRetryLogSampleRate,
 		revisionQuantization:           defaultRevisionQuantization,
 		followerReadDelay:              defaultFollowerReadDelay,
 		maxRevisionStalenessPercent:    defaultMaxRevisionStalenessPercent,
 		maxRetries:                     defaultMaxRetries,
+		retryBudgetRate:                defaultRetryBudgetRate,
+		retryBudgetBurst:               defaultRetryBudgetBurst,
 		overlapKey:                     defaultOverlapKey,
 		overlapStrategy:                defaultOverlapStrategy,
 		enablePrometheusStats:          defaultEnablePrometheusStats,
 		enableConnectionBalancing:      defaultEnableConnectionBalancing,
+		nodeHealthCheckInterval:        defaultNodeHealthCheckInterval,
 		connectRate:                    defaultConnectRate,
 		filterMaximumIDCount:           defaultFilterMaximumIDCount,
+		writeBatchSize:                 defaultWriteBatchSize,
+		readPageSize:                   defaultReadPageSize,
+		gcMaxConcurrentDeletes:         defaultGCMaxConcurrentDeletes,
+		gcQualityOfService:             defaultGCQualityOfService,
+		gcCaveats:                      defaultGCCaveats,
+		writeTransactionPriority:       defaultWriteTransactionPriority,
+		duplicateWritePolicy:           defaultDuplicateWritePolicy,
 		withIntegrity:                  defaultWithIntegrity,
 		columnOptimizationOption:       defaultColumnOptimizationOption,
 		includeQueryParametersInTraces: defaultIncludeQueryParametersInTraces,
 		expirationDisabled:             defaultExpirationDisabled,
+		validateSchemaOnOpen:           defaultValidateSchemaOnOpen,
+		advertisedRevisionMetric:       defaultAdvertisedRevisionMetric,
+		validateConnBeforeAcquire:      defaultValidateConnBeforeAcquire,
+		readOnlyReadPool:               defaultReadOnlyReadPool,
 	}
 
 	for _, option := range options {
@@ -95,12 +424,277 @@ func generateConfig(options []Option) (crdbOptions, error) {
 	}
 
 	// Run any checks on the config that need to be done
-	if computed.revisionQuantization >= computed.gcWindow {
-		return computed, fmt.Errorf(
-			errQuantizationTooLarge,
-			computed.revisionQuantization,
-			computed.gcWindow,
-		)
+	if computed.revisionQuantization < 0 {
+		return computed, fmt.Errorf("revision quantization (%s) must not be negative", computed.revisionQuantization)
+	}
+	if computed.revisionQuantization > 0 && computed.revisionQuantization >= computed.gcWindow {
+		if !computed.allowUnsafeConfig {
+			return computed, fmt.Errorf(
+				errQuantizationTooLarge,
+				computed.revisionQuantization,
+				computed.gcWindow,
+			)
+		}
+		log.Warn().
+			Dur("revisionQuantization", computed.revisionQuantization).
+			Dur("gcWindow", computed.gcWindow).
+			Msg("unsafe configuration allowed: the revision quantization is not less than the GC window, so advertised revisions may already be garbage collected")
+	}
+
+	if computed.maxOpenConnsPerProc < 0 {
+		return computed, fmt.Errorf("max open connections per proc (%d) must not be negative", computed.maxOpenConnsPerProc)
+	}
+	if computed.maxOpenConnsPerProc > 0 {
+		procs := runtime.GOMAXPROCS(0)
+		maxOpenConns := computed.maxOpenConnsPerProc * procs
+		for name, poolOpts := range map[string]*pgxcommon.PoolOptions{"read": &computed.readPoolOpts, "write": &computed.writePoolOpts} {
+			if poolOpts.MaxOpenConns != nil {
+				continue
+			}
+			conns := maxOpenConns
+			poolOpts.MaxOpenConns = &conns
+			log.Info().
+				Str("pool", name).
+				Int("maxOpenConns", maxOpenConns).
+				Int("gomaxprocs", procs).
+				Msg("sized the maximum open connections from GOMAXPROCS")
+		}
+	}
+
+	if computed.metricsDisabled {
+		computed.enablePrometheusStats = false
+		computed.advertisedRevisionMetric = false
+	}
+
+	if computed.poolRefillMode != PoolRefillEager && computed.poolRefillMode != PoolRefillGradual {
+		return computed, fmt.Errorf("unknown pool refill mode: %d", computed.poolRefillMode)
+	}
+
+	if computed.backwardsRevisionPolicy != ClampBackwardsRevision && computed.backwardsRevisionPolicy != FailBackwardsRevision {
+		return computed, fmt.Errorf("unknown backwards revision policy: %d", computed.backwardsRevisionPolicy)
+	}
+
+	if computed.quantizationAlignment != RelativeEpoch && computed.quantizationAlignment != WallClock {
+		return computed, fmt.Errorf("unknown quantization alignment: %d", computed.quantizationAlignment)
+	}
+
+	if computed.maxCachedRevisionAge < 0 {
+		return computed, fmt.Errorf("max cached revision age (%s) must not be negative", computed.maxCachedRevisionAge)
+	}
+
+	if computed.revisionHeartbeatInterval <= 0 {
+		return computed, fmt.Errorf("revision heartbeat interval (%s) must be greater than zero", computed.revisionHeartbeatInterval)
+	}
+	if computed.revisionQuantization > 0 && computed.revisionHeartbeatInterval > computed.revisionQuantization {
+		log.Warn().
+			Dur("revisionHeartbeatInterval", computed.revisionHeartbeatInterval).
+			Dur("revisionQuantization", computed.revisionQuantization).
+			Msg("the revision heartbeat interval exceeds the revision quantization, so the advertised revision may lag behind while the datastore is idle")
+	}
+
+	if computed.nodeHealthCheckInterval <= 0 {
+		return computed, fmt.Errorf("node health check interval (%s) must be greater than zero", computed.nodeHealthCheckInterval)
+	}
+
+	if computed.healthLogInterval < 0 {
+		return computed, fmt.Errorf("health log interval (%s) must not be negative", computed.healthLogInterval)
+	}
+
+	if computed.closeTimeout < 0 {
+		return computed, fmt.Errorf("close timeout (%s) must not be negative", computed.closeTimeout)
+	}
+
+	if computed.maxWatchCatchupWindow < 0 {
+		return computed, fmt.Errorf("max watch catch-up window (%s) must not be negative", computed.maxWatchCatchupWindow)
+	}
+	if computed.maxWatchCatchupWindow == 0 {
+		computed.maxWatchCatchupWindow = computed.gcWindow
+	}
+
+	if computed.connectTimeout < 0 {
+		return computed, fmt.Errorf("connect timeout (%s) must not be negative", computed.connectTimeout)
+	}
+
+	if computed.writeConnsMaxQueueDepth < 0 {
+		return computed, fmt.Errorf("write connection queue depth (%d) must not be negative", computed.writeConnsMaxQueueDepth)
+	}
+
+	if computed.retryBudgetRate < 0 {
+		return computed, fmt.Errorf("retry budget rate (%v) must not be negative", computed.retryBudgetRate)
+	}
+
+	if computed.retryBudgetRate > 0 && computed.retryBudgetBurst <= 0 {
+		return computed, fmt.Errorf("retry budget burst (%d) must be greater than zero", computed.retryBudgetBurst)
+	}
+
+	if computed.maxRowsPerTransaction < 0 {
+		return computed, fmt.Errorf("maximum rows per transaction (%d) must not be negative", computed.maxRowsPerTransaction)
+	}
+
+	if computed.maxTuplesPerWrite != nil && *computed.maxTuplesPerWrite <= 0 {
+		return computed, fmt.Errorf("maximum tuples per write (%d) must be positive", *computed.maxTuplesPerWrite)
+	}
+
+	if computed.writeBatchSize <= 0 {
+		return computed, fmt.Errorf("write batch size (%d) must be greater than zero", computed.writeBatchSize)
+	}
+
+	if computed.readPageSize <= 0 {
+		return computed, fmt.Errorf("read page size (%d) must be greater than zero", computed.readPageSize)
+	}
+
+	if computed.checkIndexHint != "" {
+		known := relationshipIndexes
+		if computed.withIntegrity {
+			known = relationshipWithIntegrityIndexes
+		}
+		if !slices.Contains(known, computed.checkIndexHint) {
+			return computed, fmt.Errorf("check index hint %q is not an index of the relationships table; must be one of %s", computed.checkIndexHint, strings.Join(known, ", "))
+		}
+	}
+
+	if computed.maxConcurrentWatches != nil && *computed.maxConcurrentWatches <= 0 {
+		return computed, fmt.Errorf("max concurrent watches (%d) must be greater than zero", *computed.maxConcurrentWatches)
+	}
+
+	if computed.maxTotalWatchBufferBytes != nil && *computed.maxTotalWatchBufferBytes <= 0 {
+		return computed, fmt.Errorf("max total watch buffer bytes (%d) must be greater than zero", *computed.maxTotalWatchBufferBytes)
+	}
+
+	if computed.maxListResultsLimit != nil && *computed.maxListResultsLimit <= 0 {
+		return computed, fmt.Errorf("max list results (%d) must be greater than zero", *computed.maxListResultsLimit)
+	}
+
+	if computed.tcpKeepaliveInterval < 0 || computed.tcpKeepaliveCount < 0 {
+		return computed, fmt.Errorf("TCP keepalive interval (%s) and count (%d) must not be negative", computed.tcpKeepaliveInterval, computed.tcpKeepaliveCount)
+	}
+	if (computed.tcpKeepaliveInterval == 0) != (computed.tcpKeepaliveCount == 0) {
+		return computed, fmt.Errorf("TCP keepalive interval (%s) and count (%d) must be set together", computed.tcpKeepaliveInterval, computed.tcpKeepaliveCount)
+	}
+
+	if err := validateMetadataColumns(computed.metadataColumns); err != nil {
+		return computed, err
+	}
+
+	if computed.gcMaxConcurrentDeletes <= 0 {
+		return computed, fmt.Errorf("GC max concurrent deletes (%d) must be greater than zero", computed.gcMaxConcurrentDeletes)
+	}
+
+	if maxWriteConns := computed.writePoolOpts.MaxOpenConns; maxWriteConns != nil && *maxWriteConns > 0 && computed.gcMaxConcurrentDeletes >= *maxWriteConns {
+		// GC is clamped to leave a write connection for everything else, but
+		// never below a single delete, so that small pools can still be
+		// collected.
+		clamped := max(*maxWriteConns-1, 1)
+		log.Warn().
+			Int("gcMaxConcurrentDeletes", computed.gcMaxConcurrentDeletes).
+			Int("writeConnsMaxOpen", *maxWriteConns).
+			Int("clampedTo", clamped).
+			Msg("the GC max concurrent deletes is not less than the maximum number of write connections, so it has been lowered")
+		computed.gcMaxConcurrentDeletes = clamped
+	}
+
+	for name, poolOpts := range map[string]pgxcommon.PoolOptions{"read": computed.readPoolOpts, "write": computed.writePoolOpts} {
+		if err := validateConnLifetimes(name, poolOpts); err != nil {
+			return computed, err
+		}
+		if err := validateOpenConns(name, poolOpts); err != nil {
+			return computed, err
+		}
+		if err := validateMinIdleConns(name, poolOpts); err != nil {
+			return computed, err
+		}
+	}
+
+	if computed.watchCoalesceWindow < 0 {
+		return computed, fmt.Errorf("watch coalesce window (%s) must not be negative", computed.watchCoalesceWindow)
+	}
+
+	if computed.watchBatchMaxLatency < 0 {
+		return computed, fmt.Errorf("watch batch max latency (%s) must not be negative", computed.watchBatchMaxLatency)
+	}
+
+	// A batch must never wait for writes that may not come to be delivered, so
+	// batching always flushes on a timer.
+	if computed.watchBatchSize > 1 && computed.watchBatchMaxLatency == 0 {
+		computed.watchBatchMaxLatency = defaultWatchBatchMaxLatency
+	}
+
+	if computed.watchCompressionThreshold < 0 {
+		return computed, fmt.Errorf("watch compression threshold (%d) must not be negative", computed.watchCompressionThreshold)
+	}
+
+	if computed.queryTimeout < 0 {
+		return computed, fmt.Errorf("query timeout (%s) must not be negative", computed.queryTimeout)
+	}
+
+	if computed.retryLogSampleRate <= 0 {
+		return computed, fmt.Errorf("retry log sample rate (%d) must be greater than zero", computed.retryLogSampleRate)
+	}
+
+	if computed.slowQueryThreshold < 0 {
+		return computed, fmt.Errorf("slow query threshold (%s) must not be negative", computed.slowQueryThreshold)
+	}
+
+	if computed.futureRevisionMaxWait < 0 {
+		return computed, fmt.Errorf("future revision max wait (%s) must not be negative", computed.futureRevisionMaxWait)
+	}
+
+	if computed.futureRevisionPolicy == FutureRevisionWait && computed.futureRevisionMaxWait == 0 {
+		return computed, fmt.Errorf("future revision wait policy requires a max wait")
+	}
+
+	if computed.queryExecMode != nil && (*computed.queryExecMode < 0 || *computed.queryExecMode > pgx.QueryExecModeSimpleProtocol) {
+		return computed, fmt.Errorf("unknown query exec mode: %d", *computed.queryExecMode)
+	}
+
+	if computed.statementCacheCapacity != nil && *computed.statementCacheCapacity < 0 {
+		return computed, fmt.Errorf("statement cache capacity (%d) must not be negative", *computed.statementCacheCapacity)
+	}
+
+	if computed.descriptionCacheCapacity != nil && *computed.descriptionCacheCapacity < 0 {
+		return computed, fmt.Errorf("description cache capacity (%d) must not be negative", *computed.descriptionCacheCapacity)
+	}
+
+	if computed.connectionLabel != "" && !connectionLabelRegex.MatchString(computed.connectionLabel) {
+		return computed, fmt.Errorf("invalid connection label %q: must be at most 63 letters, digits, or the characters `_-.:/`", computed.connectionLabel)
+	}
+
+	switch computed.gcQualityOfService {
+	case qualityOfServiceBackground, qualityOfServiceRegular, qualityOfServiceCritical:
+	default:
+		return computed, fmt.Errorf("unknown GC quality of service %q", computed.gcQualityOfService)
+	}
+
+	if !isTransactionPriority(computed.writeTransactionPriority) {
+		return computed, fmt.Errorf("unknown write transaction priority %q", computed.writeTransactionPriority)
+	}
+
+	switch computed.duplicateWritePolicy {
+	case DuplicateWriteError, DuplicateWriteIgnore, DuplicateWriteUpsert:
+	default:
+		return computed, fmt.Errorf("unknown duplicate write policy %q", computed.duplicateWritePolicy)
+	}
+
+	switch computed.watchBufferOverflowPolicy {
+	case WatchBufferOverflowBlock:
+	case WatchBufferOverflowError, WatchBufferOverflowDropOldest:
+		// With compression, the changes are sent to the compressor rather
+		// than the buffer, so whether the buffer is full cannot be told.
+		if computed.watchCompressionThreshold > 0 {
+			return computed, fmt.Errorf("watch buffer overflow policy %q cannot be combined with watch compression", computed.watchBufferOverflowPolicy)
+		}
+	default:
+		return computed, fmt.Errorf("unknown watch buffer overflow policy %q", computed.watchBufferOverflowPolicy)
+	}
+
+	switch computed.vectorize {
+	case "", vectorizeOn, vectorizeOff, vectorizeAuto, vectorizeExperimentalAlways:
+	default:
+		return computed, fmt.Errorf("unknown vectorize mode %q", computed.vectorize)
+	}
+
+	if err := migrations.ValidateVersionTablePrefix(computed.migrationTablePrefix); err != nil {
+		return computed, err
 	}
 
 	if computed.filterMaximumIDCount == 0 {
@@ -113,7 +707,8 @@ func generateConfig(options []Option) (crdbOptions, error) {
 
 // ReadConnHealthCheckInterval is the frequency at which both idle and max
 // lifetime connections are checked, and also the frequency at which the
-// minimum number of connections is checked.
+// minimum number of connections is checked. How quickly the missing
+// connections are then opened is set by PoolRefillMode.
 //
 // This happens asynchronously.
 //
@@ -134,7 +729,8 @@ func ReadConnHealthCheckInterval(interval time.Duration) Option {
 
 // WriteConnHealthCheckInterval is the frequency at which both idle and max
 // lifetime connections are checked, and also the frequency at which the
-// minimum number of connections is checked.
+// minimum number of connections is checked. How quickly the missing
+// connections are then opened is set by PoolRefillMode.
 //
 // This happens asynchronously.
 //
@@ -221,6 +817,32 @@ func WriteConnsMinOpen(conns int) Option {
 	return func(po *crdbOptions) { po.writePoolOpts.MinOpenConns = &conns }
 }
 
+// ReadConnsMinIdle is the number of idle connections the connection pool used
+// for reads keeps ready, so that a burst of requests does not wait for new
+// connections to be opened. It must not exceed the maximum open connections.
+//
+// Whenever fewer connections are idle, new ones are opened, up to the maximum
+// open connections, when the pool is created and then at each health check of
+// the pool.
+//
+// This value defaults to 0.
+func ReadConnsMinIdle(conns int) Option {
+	return func(po *crdbOptions) { po.readPoolOpts.MinIdleConns = &conns }
+}
+
+// WriteConnsMinIdle is the number of idle connections the connection pool used
+// for writes keeps ready, so that a burst of requests does not wait for new
+// connections to be opened. It must not exceed the maximum open connections.
+//
+// Whenever fewer connections are idle, new ones are opened, up to the maximum
+// open connections, when the pool is created and then at each health check of
+// the pool.
+//
+// This value defaults to 0.
+func WriteConnsMinIdle(conns int) Option {
+	return func(po *crdbOptions) { po.writePoolOpts.MinIdleConns = &conns }
+}
+
 // ReadConnsMaxOpen is the maximum size of the connection pool used for reads.
 //
 // This value defaults to having no maximum.
@@ -235,6 +857,28 @@ func WriteConnsMaxOpen(conns int) Option {
 	return func(po *crdbOptions) { po.writePoolOpts.MaxOpenConns = &conns }
 }
 
+// MaxOpenConnsPerProc sizes the connection pools used for reads and for writes
+// to at most conns connections per GOMAXPROCS, computed once when the
+// datastore is created, so that differently sized deployments get a fitting
+// pool size without configuring each of them. The maximum set explicitly for a
+// pool with ReadConnsMaxOpen or WriteConnsMaxOpen takes precedence.
+//
+// This value defaults to 0, which leaves the pools without a maximum unless
+// one is set explicitly.
+func MaxOpenConnsPerProc(conns int) Option {
+	return func(po *crdbOptions) { po.maxOpenConnsPerProc = conns }
+}
+
+// WriteConnsMaxQueueDepth is the maximum number of callers that may wait for
+// a connection from the write pool once all of its connections are in use.
+// Further callers fail immediately with a retryable datastore.OverloadedError
+// instead of waiting.
+//
+// This value defaults to 0, which means that callers always wait.
+func WriteConnsMaxQueueDepth(depth int32) Option {
+	return func(po *crdbOptions) { po.writeConnsMaxQueueDepth = depth }
+}
+
 // WatchBufferLength is the number of entries that can be stored in the watch
 // buffer while awaiting read by the client.
 //
@@ -243,12 +887,46 @@ func WatchBufferLength(watchBufferLength uint16) Option {
 	return func(po *crdbOptions) { po.watchBufferLength = watchBufferLength }
 }
 
+// WatchBufferLengthForResourceType is the number of entries reserved in the
+// watch buffer for changes to the given resource type when a watch is
+// restricted to specific resource types via WatchOptions.OptionalResourceTypes.
+// The buffer for such a watch is the sum of the lengths of the requested types,
+// so a flood of changes to one type does not back-pressure watchers of another.
+//
+// Resource types without a configured length use WatchBufferLength. Watches not
+// restricted to specific resource types always use WatchBufferLength.
+func WatchBufferLengthForResourceType(resourceType string, length uint16) Option {
+	return func(po *crdbOptions) {
+		if po.watchBufferLengthByType == nil {
+			po.watchBufferLengthByType = make(map[string]uint16)
+		}
+		po.watchBufferLengthByType[resourceType] = length
+	}
+}
+
 // WatchBufferWriteTimeout is the maximum timeout for writing to the watch buffer,
 // after which the caller to the watch will be disconnected.
 func WatchBufferWriteTimeout(watchBufferWriteTimeout time.Duration) Option {
 	return func(po *crdbOptions) { po.watchBufferWriteTimeout = watchBufferWriteTimeout }
 }
 
+// WatchBufferOverflowPolicy sets what happens when a change is produced for a
+// watch whose buffer is full, because its consumer lags behind: with
+// WatchBufferOverflowBlock the change waits for room, for at most the
+// WatchBufferWriteTimeout, before the watch is disconnected; with
+// WatchBufferOverflowError the watch is disconnected at once, so that a
+// lagging consumer holds no more than its buffer; and with
+// WatchBufferOverflowDropOldest the oldest buffered change is discarded to
+// make room, such that the consumer misses changes but is never disconnected.
+// The changes discarded are counted in WatchInfo.EventsDropped.
+// WatchBufferOverflowError and WatchBufferOverflowDropOldest cannot be combined
+// with WatchCompressionThreshold.
+//
+// This value defaults to "block".
+func WatchBufferOverflowPolicy(policy string) Option {
+	return func(po *crdbOptions) { po.watchBufferOverflowPolicy = policy }
+}
+
 // WatchConnectTimeout is the maximum timeout for connecting the watch stream
 // to the datastore.
 //
@@ -257,14 +935,173 @@ func WatchConnectTimeout(watchConnectTimeout time.Duration) Option {
 	return func(po *crdbOptions) { po.watchConnectTimeout = watchConnectTimeout }
 }
 
+// RevisionHeartbeatInterval is how often the datastore recomputes the
+// optimized revision it advertises, even when no requests are being served,
+// so that the advertised revision (and the gauge enabled by
+// WithAdvertisedRevisionMetric) keeps advancing on an idle datastore and the
+// first request after an idle period finds a fresh revision. CockroachDB's
+// clock advances without writes, so each heartbeat is a single read of the
+// cluster's current timestamp.
+//
+// An interval longer than the revision quantization lets the advertised
+// revision fall behind while idle; a much shorter one issues reads that do
+// not advance it.
+//
+// This value defaults to 5 seconds.
+func RevisionHeartbeatInterval(interval time.Duration) Option {
+	return func(po *crdbOptions) { po.revisionHeartbeatInterval = interval }
+}
+
+// CloseTimeout is the maximum amount of time Close waits for the datastore's
+// background goroutines, such as the revision heartbeat, running watches and
+// the connection balancers, to exit after they have been canceled. Close
+// returns an error, after closing the connection pools, if they do not exit
+// in time.
+//
+// This value defaults to 5 seconds.
+func CloseTimeout(timeout time.Duration) Option {
+	return func(po *crdbOptions) { po.closeTimeout = timeout }
+}
+
+// MaxWatchCatchupWindow is the maximum age of the revision a watch may start
+// from. A watch starting from an older revision fails with a
+// datastore.WatchCatchupWindowExceededError, telling the client to re-read the
+// current state and watch from its revision, rather than replaying every
+// change made since the revision.
+//
+// This value defaults to the GC window.
+func MaxWatchCatchupWindow(window time.Duration) Option {
+	return func(po *crdbOptions) { po.maxWatchCatchupWindow = window }
+}
+
+// WatchCoalesceWindow merges relationship changes emitted by the watch whose
+// revisions fall within the given window of one another into a single change,
+// keeping only the last update made to each relationship. Changes to schema
+// and changes with transaction metadata are never merged.
+//
+// This value defaults to 0, which disables coalescing.
+func WatchCoalesceWindow(window time.Duration) Option {
+	return func(po *crdbOptions) { po.watchCoalesceWindow = window }
+}
+
+// WatchBatchSize delays the delivery of watch changes until the changes of the
+// given number of consecutive revisions have accumulated, at which point they
+// are delivered as a single change at the last of the revisions, keeping only
+// the last update made to each relationship. As with WatchCoalesceWindow,
+// checkpoints, changes to schema and changes with transaction metadata are
+// never merged: each ends the batch before it and is delivered on its own.
+// Changes are never held for longer than WatchBatchMaxLatency, so a partial
+// batch is delivered even when no further writes arrive, and any partial batch
+// is delivered when the watch ends.
+//
+// This value defaults to 0, which delivers each change immediately.
+func WatchBatchSize(size uint16) Option {
+	return func(po *crdbOptions) { po.watchBatchSize = size }
+}
+
+// WatchBatchMaxLatency is the maximum amount of time a watch change is held
+// while waiting for its batch to fill before the batch is delivered. Batches
+// are only delivered once they hold at least one change.
+//
+// This value defaults to 100ms when WatchBatchSize is set, and to 0, which
+// delivers each change immediately, otherwise.
+func WatchBatchMaxLatency(latency time.Duration) Option {
+	return func(po *crdbOptions) { po.watchBatchMaxLatency = latency }
+}
+
+// WatchCompressionThreshold compresses the caveat contexts of the changes
+// waiting in the buffer of a watch whose serialized size is at least the given
+// number of bytes, reducing the memory held by watches whose consumers fall
+// behind on changes with large contexts. The contexts are decompressed as the
+// changes are delivered, so consumers receive the same changes as without
+// compression, at the cost of the CPU time to compress and decompress them.
+// Compression requires the WatchBufferOverflowBlock overflow policy.
+//
+// This value defaults to 0, which disables compression.
+func WatchCompressionThreshold(bytes int) Option {
+	return func(po *crdbOptions) { po.watchCompressionThreshold = bytes }
+}
+
 // RevisionQuantization is the time bucket size to which advertised revisions
-// will be rounded.
+// will be rounded. Zero disables the rounding, so that every advertised
+// revision is exact, which is strongly consistent but can no longer be shared
+// by the requests made within a bucket. Unlike any other
+// quantization, zero is valid whatever the GC window. It must not be negative.
 //
 // This value defaults to 5 seconds.
 func RevisionQuantization(bucketSize time.Duration) Option {
 	return func(po *crdbOptions) { po.revisionQuantization = bucketSize }
 }
 
+// QuantizationAlignment sets where the windows to which advertised revisions
+// are rounded by RevisionQuantization begin. RelativeEpoch aligns them to
+// multiples of the quantization since the Unix epoch, while WallClock begins
+// a window at every round UTC second, minute, hour or day, whichever is the
+// shortest that is at least as long as the quantization, even when the
+// quantization does not evenly divide it, making advertised revisions easier
+// to read. Either way, replicas configured alike advertise the same revisions
+// regardless of when they started.
+//
+// This value defaults to RelativeEpoch.
+func QuantizationAlignment(alignment revisions.QuantizationAlignment) Option {
+	return func(po *crdbOptions) { po.quantizationAlignment = alignment }
+}
+
+// AllowUnsafeConfig downgrades the check that the revision quantization is less
+// than the GC window from a configuration error to a logged warning.
+//
+// This is dangerous: with a quantization at least as long as the GC window,
+// the datastore may advertise revisions whose data has already been garbage
+// collected, causing requests at those revisions to fail. It is only intended
+// for deployments that handle garbage collection externally and understand
+// the consequences.
+func AllowUnsafeConfig() Option {
+	return func(po *crdbOptions) { po.allowUnsafeConfig = true }
+}
+
+// AllowDestructiveOperations enables the operations of the datastore that
+// delete data wholesale, such as TruncateAllData, which otherwise fail.
+//
+// This is intended for test environments and must never be set in
+// production, where it exposes all data to accidental deletion.
+func AllowDestructiveOperations() Option {
+	return func(po *crdbOptions) { po.allowDestructiveOperations = true }
+}
+
+// HealthLogInterval logs, at info level every interval, a summary of the
+// datastore's health: the total, idle and acquired connections of each pool,
+// the number of expired relationships awaiting garbage collection, counted up
+// to 10,000, and the age of the latest advertised revision. This is meant for
+// environments without a metrics pipeline.
+//
+// This value defaults to 0, which disables the summary.
+func HealthLogInterval(interval time.Duration) Option {
+	return func(po *crdbOptions) { po.healthLogInterval = interval }
+}
+
+// PrewarmRevisionCache populates the cached optimized revision, as
+// RefreshLatestRevision does, while the datastore is created, so that the
+// first reads served after startup do not wait on a query for it. A failure to
+// populate the cache is logged as a warning and does not fail the creation of
+// the datastore.
+//
+// Disabled by default.
+func PrewarmRevisionCache(enabled bool) Option {
+	return func(po *crdbOptions) { po.prewarmRevisionCache = enabled }
+}
+
+// MaxCachedRevisionAge bounds how old the advertised revision can be: it is
+// refreshed once it is older than maxAge even within a quantization window, and
+// the start of a window older than maxAge is not advertised, so that reads and
+// watches on an idle cluster with a long quantization do not lag behind by up
+// to the whole window. Revisions can still be older by up to the maximum
+// revision staleness (see MaxRevisionStalenessPercent).
+//
+// This value defaults to 0, which bounds the age by the quantization alone.
+func MaxCachedRevisionAge(maxAge time.Duration) Option {
+	return func(po *crdbOptions) { po.maxCachedRevisionAge = maxAge }
+}
+
 // FollowerReadDelay is the time delay to apply to enable historial reads.
 //
 // This value defaults to 0 seconds.
@@ -296,6 +1133,21 @@ func ConnectRate(rate time.Duration) Option {
 	return func(po *crdbOptions) { po.connectRate = rate }
 }
 
+// PoolRefillMode sets how quickly the connection pools open the connections
+// they are missing, such as when the health check, run at the interval set by
+// ReadConnHealthCheckInterval and WriteConnHealthCheckInterval, finds a pool
+// below its minimum size after a restart of the cluster dropped all of its
+// connections. PoolRefillEager dials all of the missing connections in the
+// same health check, throttling only their initialization to the
+// ConnectRate, while PoolRefillGradual throttles the dialing itself to the
+// ConnectRate, avoiding a thundering herd of connection attempts at the cost
+// of a slower recovery.
+//
+// This value defaults to PoolRefillEager.
+func PoolRefillMode(mode pool.RefillMode) Option {
+	return func(po *crdbOptions) { po.poolRefillMode = mode }
+}
+
 // MaxRetries is the maximum number of times a retriable transaction will be
 // client-side retried.
 // Default: 5
@@ -303,6 +1155,27 @@ func MaxRetries(maxRetries uint8) Option {
 	return func(po *crdbOptions) { po.maxRetries = maxRetries }
 }
 
+// RetryBudgetRate is the number of client-side retries per second, shared
+// across all operations, that the datastore may perform. When the budget is
+// exhausted, e.g. because the cluster is struggling, operations fail rather
+// than retry, preventing retries from amplifying the load on the cluster.
+// A rate of 0 disables the budget, allowing every operation to retry up to
+// MaxRetries times.
+//
+// This value defaults to 0, so the budget is opt-in and retries are only
+// limited by MaxRetries.
+func RetryBudgetRate(retriesPerSecond float64) Option {
+	return func(po *crdbOptions) { po.retryBudgetRate = retriesPerSecond }
+}
+
+// RetryBudgetBurst is the number of retries that may be performed in a burst
+// beyond the RetryBudgetRate. It has no effect unless RetryBudgetRate is set.
+//
+// This value defaults to 100.
+func RetryBudgetBurst(burst int) Option {
+	return func(po *crdbOptions) { po.retryBudgetBurst = burst }
+}
+
 // OverlapStrategy is the strategy used to generate overlap keys on write.
 // Default: 'static'
 func OverlapStrategy(strategy string) Option {
@@ -331,6 +1204,23 @@ func WithEnableConnectionBalancing(connectionBalancing bool) Option {
 	return func(po *crdbOptions) { po.enableConnectionBalancing = connectionBalancing }
 }
 
+// NodeHealthCheckInterval is the frequency at which the node health poller
+// checks the health of the cluster's nodes, by connecting to one of them, so
+// that the connection balancer can spread the connections of the pools over
+// the healthy nodes. The time of the last poll is reported by PoolStats as
+// LastNodeHealthCheck. The poller only runs with connection balancing enabled.
+//
+// This is unrelated to the health checks that the pools run on their own
+// connections, to enforce the minimum number of connections and their maximum
+// idle time and lifetime, whose frequency is set by ReadConnHealthCheckInterval
+// and WriteConnHealthCheckInterval, and reported by EffectiveConfig as the
+// HealthCheckPeriod of each pool.
+//
+// This value defaults to 5 seconds.
+func NodeHealthCheckInterval(interval time.Duration) Option {
+	return func(po *crdbOptions) { po.nodeHealthCheckInterval = interval }
+}
+
 // DebugAnalyzeBeforeStatistics signals to the Statistics method that it should
 // run Analyze on the database before returning statistics. This should only be
 // used for debug and testing.
@@ -345,6 +1235,207 @@ func FilterMaximumIDCount(filterMaximumIDCount uint16) Option {
 	return func(po *crdbOptions) { po.filterMaximumIDCount = filterMaximumIDCount }
 }
 
+// AllowTransactionSplitting sets the maximum number of mutations written by
+// each of the transactions of WriteRelationshipsInBatches, to stay within
+// CockroachDB's transaction size limits. Writes through ReadWriteTx are never
+// split, and remain atomic.
+//
+// Disabled by default, such that WriteRelationshipsInBatches writes all of
+// the mutations in a single transaction.
+func AllowTransactionSplitting(maxRows int) Option {
+	return func(po *crdbOptions) { po.maxRowsPerTransaction = maxRows }
+}
+
+// MaxTuplesPerWrite rejects each relationship write made with
+// WriteRelationshipsWithCounts or WriteRelationshipsInBatches of more than n
+// mutations with a datastore.TooManyUpdatesError, before a transaction is
+// opened. Relationships written within a transaction given to ReadWriteTx are
+// not limited.
+//
+// Unlike AllowTransactionSplitting, which writes large writes in parts, this
+// protects the datastore from them; when both are set, writes are checked
+// against the limit before they are split.
+//
+// By default, writes are not limited.
+func MaxTuplesPerWrite(n int) Option {
+	return func(po *crdbOptions) { po.maxTuplesPerWrite = &n }
+}
+
+// WriteBatchSize is the maximum number of relationships inserted or touched by
+// a single INSERT statement when writing relationships. Larger batches require
+// fewer round trips, at the cost of larger statements.
+//
+// This value defaults to 1000.
+func WriteBatchSize(size int) Option {
+	return func(po *crdbOptions) { po.writeBatchSize = size }
+}
+
+// ReadPageSize is the maximum number of rows fetched by a single query when
+// reading relationships. Reads of larger result sets are performed as a series
+// of queries, each resuming after the last relationship of the previous one,
+// which bounds the memory held for any one of them. Only sorted reads are
+// paged, since each page resumes in the order of the sort; unsorted reads are
+// performed as a single query, in the order chosen by CockroachDB.
+//
+// This value defaults to 1000.
+func ReadPageSize(rows int) Option {
+	return func(po *crdbOptions) { po.readPageSize = rows }
+}
+
+// MaxListResults is the maximum number of relationships returned by a single
+// query of the relationships of a snapshot. Once the maximum has been
+// returned, the iterator of a query with further results fails with a
+// datastore.ResultsTruncatedError, whose cursor can be used to query for the
+// rest. Queries whose results may be truncated are returned in resource order
+// when unsorted.
+//
+// By default, the results are not limited.
+func MaxListResults(n int) Option {
+	return func(po *crdbOptions) { po.maxListResultsLimit = &n }
+}
+
+// MaxConcurrentWatches is the maximum number of watches served by the
+// datastore at once. Each watch holds a dedicated connection, a buffer and a
+// goroutine until it ends; further watches fail with a
+// datastore.TooManyWatchesError.
+//
+// By default, the number of watches is not limited.
+func MaxConcurrentWatches(n int) Option {
+	return func(po *crdbOptions) { po.maxConcurrentWatches = &n }
+}
+
+// MaxTotalWatchBufferBytes is the maximum total size, in bytes, of the changes
+// buffered by all of the watches served by the datastore, which bounds their
+// memory where MaxConcurrentWatches bounds their number. The size of each
+// change is estimated, before any compression by WatchCompressionThreshold,
+// so the bound is approximate.
+//
+// Once the buffers hold more than the maximum, further watches fail with a
+// datastore.WatchBufferMemoryExceededError and the watches whose buffers are
+// not empty wait for them to drain before buffering more changes; a watch that
+// waits longer than the watch buffer write timeout is disconnected.
+//
+// By default, the size of the buffers is not limited.
+func MaxTotalWatchBufferBytes(n int) Option {
+	return func(po *crdbOptions) { po.maxTotalWatchBufferBytes = &n }
+}
+
+// CheckIndexHint forces the queries of relationships made by permission checks
+// to use the named index of the relationships table, via CockroachDB's
+// `table@index` syntax, rather than the index chosen by the optimizer. The
+// index must be one of those created by the migrations for the table:
+// pk_relation_tuple, ix_relation_tuple_by_subject and
+// ix_relation_tuple_by_subject_relation, or, with WithIntegrity,
+// pk_relation_tuple and ix_relation_tuple_with_integrity.
+//
+// This is an escape hatch for when the optimizer picks a poor plan on a
+// particular cluster, and should be used sparingly: the best index depends on
+// the shape of each query and the version of CockroachDB, and a forced index
+// is used even when it is the wrong one for the query.
+//
+// By default, no hint is given.
+func CheckIndexHint(index string) Option {
+	return func(po *crdbOptions) { po.checkIndexHint = index }
+}
+
+// WithLogger sends the datastore's operational logs, such as those emitted at
+// startup and by the revision heartbeat, the connection balancer and
+// Statistics, to the given logger, allowing the logs of several datastores in
+// one process to be told apart. Logs emitted while serving a request continue
+// to use the logger of the request's context.
+//
+// By default, operational logs use the logger of the context in which they
+// are emitted.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(po *crdbOptions) { po.logger = &logger }
+}
+
+// maxListResults returns the configured maximum number of results of a
+// relationships query, or zero if the results are not limited.
+func (po crdbOptions) maxListResults() uint64 {
+	if po.maxListResultsLimit == nil {
+		return 0
+	}
+	return uint64(*po.maxListResultsLimit)
+}
+
+// GCMaxConcurrentDeletes is the maximum number of DELETE statements that
+// garbage collection (see RunGC) may have in flight at once, across all
+// concurrent passes. Each statement holds a write pool connection and removes
+// at most 1000 rows, so this bounds both the share of the write pool used by
+// GC and the rate at which rows are removed. A value that is not less than
+// WriteConnsMaxOpen is lowered, with a warning, to one less than it, or to 1,
+// so that GC does not take every write connection of a larger pool.
+//
+// This value defaults to 1.
+func GCMaxConcurrentDeletes(deletes int) Option {
+	return func(po *crdbOptions) { po.gcMaxConcurrentDeletes = deletes }
+}
+
+// WriteTransactionPriority sets the priority, via CockroachDB's
+// `BEGIN PRIORITY`, of the datastore's write transactions, which
+// influences which transaction wins when they conflict: TransactionPriorityLow
+// ones yield to, and TransactionPriorityHigh ones push aside, those of a
+// different priority. The priority of individual writes can be overridden
+// with WithWriteTransactionPriority, for example to protect interactive writes
+// from bulk jobs.
+//
+// This value defaults to "normal".
+func WriteTransactionPriority(priority string) Option {
+	return func(po *crdbOptions) { po.writeTransactionPriority = priority }
+}
+
+// DuplicateWritePolicy sets what happens when a write creates a relationship
+// that already exists: with DuplicateWriteError the write fails, with
+// DuplicateWriteIgnore the existing relationship is left as it is, which suits
+// idempotent sync jobs, and with DuplicateWriteUpsert its caveat, expiration
+// and metadata are replaced by those written, as a touch does. Touches and
+// deletes are unaffected. With either of the latter two, a create of an
+// existing relationship is reported as skipped, not created, by
+// WriteRelationshipsWithCounts.
+//
+// This value defaults to "error".
+func DuplicateWritePolicy(policy string) Option {
+	return func(po *crdbOptions) { po.duplicateWritePolicy = policy }
+}
+
+// GCQualityOfService sets the quality of service, via CockroachDB's
+// `default_transaction_quality_of_service`, of the transactions in which
+// garbage collection (see RunGC) deletes rows. With "background", admission
+// control defers the deletes in favor of serving traffic when the cluster is
+// overloaded, so that GC does not degrade the latency of checks; "regular" and
+// "critical" give them the same or a higher priority than serving traffic.
+//
+// This value defaults to "background".
+func GCQualityOfService(level string) Option {
+	return func(po *crdbOptions) { po.gcQualityOfService = level }
+}
+
+// GCCaveats sets whether garbage collection (see RunGC) deletes the caveat
+// definitions that no relation of the schema stored at the head revision
+// requires and that no relationship references. A caveat is only deleted once
+// GC passes have found it unreferenced for longer than the GC window, so one
+// whose last relationship was just deleted is kept until the window has
+// passed; reads at revisions within the window still see it, as of their
+// revision.
+//
+// This value defaults to true.
+func GCCaveats(enabled bool) Option {
+	return func(po *crdbOptions) { po.gcCaveats = enabled }
+}
+
+// GCDanglingRelationships sets whether garbage collection (see RunGC) deletes
+// the relationships whose resource or subject type is not defined by the
+// current schema, as listed by FindDanglingRelationships. The relationships
+// are deleted as soon as their type is removed, so a schema change that
+// removes a type by mistake loses its relationships at the next pass; they
+// remain readable at revisions within the GC window.
+//
+// Disabled by default.
+func GCDanglingRelationships(enabled bool) Option {
+	return func(po *crdbOptions) { po.gcDanglingRelationships = enabled }
+}
+
 // WithIntegrity marks whether the datastore should store and return integrity information.
 func WithIntegrity(withIntegrity bool) Option {
 	return func(po *crdbOptions) { po.withIntegrity = withIntegrity }
@@ -356,6 +1447,17 @@ func AllowedMigrations(allowedMigrations []string) Option {
 	return func(po *crdbOptions) { po.allowedMigrations = allowedMigrations }
 }
 
+// MigrationTablePrefix configures the datastore to read the version of the
+// schema from the version tables of a database migrated by a driver created
+// with migrations.WithVersionTablePrefix and the same prefix. The other tables
+// of the datastore cannot be prefixed, so several SpiceDB schemas cannot share
+// a database.
+//
+// By default, the version tables are not prefixed.
+func MigrationTablePrefix(prefix string) Option {
+	return func(po *crdbOptions) { po.migrationTablePrefix = prefix }
+}
+
 // IncludeQueryParametersInTraces marks whether query parameters should be included in traces.
 func IncludeQueryParametersInTraces(includeQueryParametersInTraces bool) Option {
 	return func(po *crdbOptions) { po.includeQueryParametersInTraces = includeQueryParametersInTraces }
@@ -376,3 +1478,399 @@ func WithColumnOptimization(isEnabled bool) Option {
 func WithExpirationDisabled(isDisabled bool) Option {
 	return func(po *crdbOptions) { po.expirationDisabled = isDisabled }
 }
+
+// ValidateSchemaOnOpen configures the datastore to verify, during construction,
+// that the database has been migrated to a version accepted by this binary and
+// that all of the tables and columns the datastore queries exist.
+//
+// Disabled by default.
+func ValidateSchemaOnOpen() Option {
+	return func(po *crdbOptions) { po.validateSchemaOnOpen = true }
+}
+
+// ValidateCaveatsOnWrite checks, within the write transaction, that the caveat
+// of each relationship created or touched is defined and that the keys of its
+// context are parameters of the caveat. A write referencing an undefined caveat
+// fails with a datastore.CaveatNameNotFoundError, and one whose context has a
+// key that is not a parameter of the caveat with a datastore.CaveatContextMismatchError.
+// Since the definitions are read in the write transaction, a write racing the
+// deletion of its caveat either commits first or fails like any other write
+// referencing an undefined caveat.
+//
+// Disabled by default.
+func ValidateCaveatsOnWrite(enabled bool) Option {
+	return func(po *crdbOptions) { po.validateCaveatsOnWrite = enabled }
+}
+
+// DisableMetrics turns off all of the datastore's Prometheus metrics,
+// including the connection pool statistics and the advertised revision gauge
+// regardless of WithEnablePrometheusStats and WithAdvertisedRevisionMetric,
+// as well as the retry, overload, statement duration, node health and
+// connection balancing metrics of the connection pools. This gives a clean baseline when
+// benchmarking the datastore's query paths.
+//
+// Metrics are enabled by default.
+func DisableMetrics() Option {
+	return func(po *crdbOptions) { po.metricsDisabled = true }
+}
+
+// WithOpenTelemetryMetrics records the retry, overload and retry budget
+// metrics of the connection pools, the garbage collection metrics and the
+// advertised revision gauge as instruments of the OpenTelemetry meter, rather
+// than as Prometheus metrics. The connection pool statistics of
+// WithEnablePrometheusStats, and the node health and connection balancing
+// metrics, remain Prometheus metrics.
+//
+// By default, metrics are registered with Prometheus.
+func WithOpenTelemetryMetrics(meter metric.Meter) Option {
+	return func(po *crdbOptions) { po.metrics = pool.OpenTelemetryMetrics(meter) }
+}
+
+// WithAdvertisedRevisionMetric marks whether the timestamp of the optimized
+// revision advertised by the datastore should be recorded in a gauge each time
+// it is recomputed. Comparing the gauge against the current time shows the
+// combined delay introduced by quantization and follower reads.
+//
+// Disabled by default.
+func WithAdvertisedRevisionMetric(enabled bool) Option {
+	return func(po *crdbOptions) { po.advertisedRevisionMetric = enabled }
+}
+
+// ValidateConnBeforeAcquire configures the read and write pools to ping each
+// connection before handing it out, discarding connections that the server
+// has silently dropped (e.g. during a rolling restart) at the cost of an
+// additional round trip per acquisition.
+//
+// Disabled by default.
+func ValidateConnBeforeAcquire() Option {
+	return func(po *crdbOptions) { po.validateConnBeforeAcquire = true }
+}
+
+// ResetQueryOnRelease configures the read and write pools to run the given
+// statement, such as `RESET ALL`, on each connection returned to them, so that
+// session state left behind by one user of the connection is not seen by the
+// next. Connections for which the statement fails are discarded.
+//
+// The statement must not deallocate prepared statements (as `DISCARD ALL`
+// does) unless the query exec mode does not cache them, since the cached
+// statements would otherwise fail on their next use.
+//
+// Disabled by default, in which case connections are returned as is.
+func ResetQueryOnRelease(sql string) Option {
+	return func(po *crdbOptions) { po.resetQueryOnRelease = sql }
+}
+
+// ConnectTimeout is the maximum amount of time to wait when establishing a new
+// connection for the read or write pools.
+//
+// This value defaults to no timeout (or the connect_timeout in the URL, if any).
+func ConnectTimeout(timeout time.Duration) Option {
+	return func(po *crdbOptions) { po.connectTimeout = timeout }
+}
+
+// QueryTimeout is the maximum amount of time, including client-side retries,
+// that an individual query issued through the read or write pools may take.
+// The timeout of a transaction, such as that of ReadWriteTx, bounds the entire
+// transaction, including the caller's function and its retries, so it must
+// allow for the longest transactions.
+//
+// This value defaults to no timeout.
+func QueryTimeout(timeout time.Duration) Option {
+	return func(po *crdbOptions) { po.queryTimeout = timeout }
+}
+
+// SlowQueryThreshold logs, at warn level, the SQL and duration of any query
+// issued through the read or write pools that takes longer than the threshold,
+// independently of pgx's query logging.
+//
+// This value defaults to 0, which disables slow query logging.
+func SlowQueryThreshold(threshold time.Duration) Option {
+	return func(po *crdbOptions) { po.slowQueryThreshold = threshold }
+}
+
+// TCPKeepalive enables TCP keepalive probes on the datastore's connections,
+// sent once a connection has been idle for the interval and then every
+// interval, with the connection closed after count probes go unanswered. This
+// detects connections silently dropped by NATs and firewalls before they are
+// next used, more cheaply than ValidateConnBeforeAcquire.
+//
+// By default, the operating system's keepalive settings are used.
+func TCPKeepalive(interval time.Duration, count int) Option {
+	return func(po *crdbOptions) {
+		po.tcpKeepaliveInterval = interval
+		po.tcpKeepaliveCount = count
+	}
+}
+
+// RetryLogSampleRate logs only one in every n of the retries of serialization
+// failures and other retryable errors, rather than each of them, so that
+// heavy contention does not flood the logs. The retries themselves are
+// unaffected.
+//
+// This value defaults to 1, which logs every retry.
+func RetryLogSampleRate(n int) Option {
+	return func(po *crdbOptions) { po.retryLogSampleRate = n }
+}
+
+// WithVectorize sets the `vectorize` session setting on all of the datastore's
+// connections, controlling whether CockroachDB uses its vectorized execution
+// engine for SpiceDB's queries. Valid modes are "on", "off", "auto" and
+// "experimental_always", subject to support by the CockroachDB version in use.
+//
+// This is an advanced tuning knob; by default the setting is left at the
+// cluster's default.
+func WithVectorize(mode string) Option {
+	return func(po *crdbOptions) { po.vectorize = mode }
+}
+
+// ReadOnlyMode starts the datastore in read-only mode, in which writes fail
+// with ErrReadOnlyMode while reads are served normally. The mode can be
+// toggled at runtime with the datastore's SetReadOnly method, such as for the
+// duration of a maintenance window of the cluster.
+//
+// Disabled by default.
+func ReadOnlyMode(enabled bool) Option {
+	return func(po *crdbOptions) { po.readOnlyMode = enabled }
+}
+
+// ReadOnlyReadPool sets `default_transaction_read_only` on the connections of
+// the read pool, so that a write mistakenly issued through the read pool fails
+// instead of being applied. It applies only to a read pool distinct from the
+// write pool, and so not to a datastore created by NewReadOnlyCRDBDatastore,
+// and never to the pool used for setup tasks when the datastore is created.
+//
+// This value defaults to true.
+func ReadOnlyReadPool(enabled bool) Option {
+	return func(po *crdbOptions) { po.readOnlyReadPool = enabled }
+}
+
+// FairPoolAcquisition makes the read and write pools serve operations in the
+// order in which they began waiting for a connection, so that no operation is
+// overtaken by ones that arrived after it while the pool is saturated. An
+// operation keeps its place across retries instead of waiting again behind
+// newer operations, which bounds the tail latency of the operations that
+// would otherwise wait the longest, at the cost of some throughput.
+//
+// Disabled by default.
+func FairPoolAcquisition() Option {
+	return func(po *crdbOptions) { po.fairPoolAcquisition = true }
+}
+
+// WithStatementLabels appends a comment naming the class of operation (check,
+// expand, watch or write) that issued each statement, e.g.
+// `/*spicedb_operation='check'*/`, allowing the statements to be told apart in
+// CockroachDB's statement diagnostics and SQL activity pages.
+//
+// Since each class is a separate statement text, enabling the labels increases
+// the number of statement fingerprints and cached prepared statements.
+//
+// Disabled by default.
+func WithStatementLabels(enabled bool) Option {
+	return func(po *crdbOptions) { po.statementLabels = enabled }
+}
+
+// WithDeadlineStatementTimeouts bounds each statement by the deadline of the
+// request's context, so that CockroachDB aborts a query once the caller would
+// have given up on it, rather than continuing to run it after the client has
+// gone away. Requests without a deadline run without a statement timeout.
+//
+// Transactions set their `statement_timeout` via `SET LOCAL` when they begin.
+// Statements run outside of a transaction are aborted by a cancel request
+// sent once their deadline passes, which adds no round trips.
+//
+// Disabled by default.
+func WithDeadlineStatementTimeouts(enabled bool) Option {
+	return func(po *crdbOptions) { po.deadlineStatementTimeouts = enabled }
+}
+
+// WithConnectionLabel sets the `application_name` session setting of the
+// connections of the read and write pools to the given label, allowing the
+// usage of a CockroachDB cluster to be attributed to a SpiceDB deployment or
+// tenant via crdb_internal.cluster_sessions and related tables. Labels may
+// contain at most 63 letters, digits, or the characters `_-.:/`.
+//
+// By default, no label is set.
+func WithConnectionLabel(label string) Option {
+	return func(po *crdbOptions) { po.connectionLabel = label }
+}
+
+// WithQueryExecMode sets the protocol pgx uses to execute queries on the
+// connections of the read and write pools, overriding any
+// `default_query_exec_mode` given in the connection string.
+//
+// The mode required depends on what sits between SpiceDB and CockroachDB:
+//   - a direct connection, or a pooler in session mode, supports every mode,
+//     including pgx's default of pgx.QueryExecModeCacheStatement;
+//   - PgBouncer in transaction or statement mode before 1.21, or without
+//     max_prepared_statements configured, does not support prepared statements
+//     and requires pgx.QueryExecModeSimpleProtocol (or pgx.QueryExecModeExec);
+//   - PgBouncer 1.21+ in transaction mode with max_prepared_statements set
+//     supports pgx.QueryExecModeCacheDescribe and pgx.QueryExecModeDescribeExec.
+//
+// By default, the mode is taken from the connection string or pgx's default.
+func WithQueryExecMode(mode pgx.QueryExecMode) Option {
+	return func(po *crdbOptions) { po.queryExecMode = &mode }
+}
+
+// StatementCacheCapacity bounds the number of prepared statements pgx caches
+// on each connection of the read and write pools when queries are executed
+// with pgx.QueryExecModeCacheStatement. Every cached statement is also
+// prepared on the server, so on pools of many connections executing varied
+// queries a smaller cache bounds the memory held per connection, at the cost
+// of preparing statements evicted from the cache again when they are next
+// executed. A capacity of 0 disables the cache.
+//
+// By default, the capacity is taken from the connection string
+// (`statement_cache_capacity`) or pgx's default of 512.
+func StatementCacheCapacity(capacity int) Option {
+	return func(po *crdbOptions) { po.statementCacheCapacity = &capacity }
+}
+
+// DescriptionCacheCapacity bounds the number of statement descriptions pgx
+// caches on each connection of the read and write pools when queries are
+// executed with pgx.QueryExecModeCacheDescribe. A smaller cache bounds the
+// memory held per connection, at the cost of describing statements evicted
+// from the cache again, in an extra round trip, when they are next executed.
+// A capacity of 0 disables the cache.
+//
+// By default, the capacity is taken from the connection string
+// (`description_cache_capacity`) or pgx's default of 512.
+func DescriptionCacheCapacity(capacity int) Option {
+	return func(po *crdbOptions) { po.descriptionCacheCapacity = &capacity }
+}
+
+// WithSimpleProtocolFallback runs queries again with
+// pgx.QueryExecModeSimpleProtocol when they fail because a prepared statement
+// does not exist, logging a warning the first time a pool falls back. Unlike
+// WithQueryExecMode, which sets the protocol of every query, this heals the
+// intermittent failures seen when connecting through a transaction-mode pooler
+// such as PgBouncer without configuring a compatible exec mode. Statements run
+// within transactions, such as those of writes, are not retried.
+//
+// Disabled by default.
+func WithSimpleProtocolFallback(enabled bool) Option {
+	return func(po *crdbOptions) { po.simpleProtocolFallback = enabled }
+}
+
+// OnConnect invokes the callback each time a connection of the read or write
+// pool is established, with the pool, the server address and the CockroachDB
+// node of the connection. The callback runs on the path establishing the
+// connection, so it must return quickly.
+//
+// By default, no callback is invoked.
+func OnConnect(callback func(pool.ConnectionEvent)) Option {
+	return func(po *crdbOptions) { po.connectionCallbacks.OnConnect = callback }
+}
+
+// OnDisconnect invokes the callback each time a connection of the read or
+// write pool is about to be closed, such as when it reaches its maximum
+// lifetime or the datastore is closed. The callback must return quickly.
+//
+// By default, no callback is invoked.
+func OnDisconnect(callback func(pool.ConnectionEvent)) Option {
+	return func(po *crdbOptions) { po.connectionCallbacks.OnDisconnect = callback }
+}
+
+// OnConnectError invokes the callback each time a query fails to acquire a
+// connection of the read or write pool because the connection could not be
+// established, with the server address and the error. The error never
+// includes the password of the connection. The callback must return quickly.
+//
+// By default, no callback is invoked.
+func OnConnectError(callback func(pool.ConnectionEvent)) Option {
+	return func(po *crdbOptions) { po.connectionCallbacks.OnConnectError = callback }
+}
+
+// FutureRevisionPolicy sets how a request made at a revision newer than the
+// datastore's current revision, such as one returned by a write before a
+// failover to a lagging replica, is handled. FutureRevisionError fails the
+// request with a "revision in the future" error, while FutureRevisionWait
+// waits for at most maxWait for the datastore to catch up to the revision.
+//
+// This value defaults to FutureRevisionError.
+func FutureRevisionPolicy(policy revisions.FutureRevisionPolicy, maxWait time.Duration) Option {
+	return func(po *crdbOptions) {
+		po.futureRevisionPolicy = policy
+		po.futureRevisionMaxWait = maxWait
+	}
+}
+
+// BackwardsRevisionPolicy sets how the datastore handles the current revision
+// of the cluster going backwards, such as after clock issues or a failover,
+// so that clients never see the advertised revision travel back in time.
+// ClampBackwardsRevision keeps advertising revisions based on the latest
+// revision previously read, while FailBackwardsRevision fails the request
+// with a revisions.RevisionWentBackwardsError.
+//
+// This value defaults to ClampBackwardsRevision.
+func BackwardsRevisionPolicy(policy revisions.BackwardsRevisionPolicy) Option {
+	return func(po *crdbOptions) { po.backwardsRevisionPolicy = policy }
+}
+
+// WithClock sets the clock from which the datastore computes its optimized
+// revisions and checks revisions against the GC window, in place of
+// CockroachDB's cluster clock, so that tests can advance time
+// deterministically, e.g. with clock.NewMock.
+//
+// Revisions of writes are still assigned by CockroachDB, so this option is
+// only suitable for tests that do not mix the two.
+//
+// By default, the cluster clock is used.
+func WithClock(clock clock.Clock) Option {
+	return func(po *crdbOptions) { po.clock = clock }
+}
+
+// WithMetadataColumns declares additional STRING columns of the relationships
+// table in which per-relationship metadata, such as a source tag, is stored.
+// The columns must have been added to the table, e.g. with
+// `ALTER TABLE relation_tuple ADD COLUMN source STRING`, before the datastore
+// is created, which otherwise fails. Relationships created or touched by a write are given
+// the values set for the write via datastore.WithRelationshipMetadata, and
+// the values can be read with the datastore's RelationshipMetadata method.
+//
+// Column names must be lowercase identifiers that do not conflict with the
+// table's own columns.
+//
+// By default, no metadata columns are declared.
+func WithMetadataColumns(columns ...string) Option {
+	return func(po *crdbOptions) { po.metadataColumns = columns }
+}
+
+// OnRevisionAdvanced registers a callback invoked with each new optimized
+// revision, such as when the revision advances to a new quantization window,
+// allowing caches keyed by revision to be invalidated without polling. The
+// callback is invoked on a separate goroutine, so that it never delays the
+// computation of revisions; revisions that advance while it is running are
+// coalesced, and it is next invoked with only the latest of them.
+//
+// By default, no callback is registered.
+func OnRevisionAdvanced(callback func(datastore.Revision)) Option {
+	return func(po *crdbOptions) { po.revisionAdvancedCallback = callback }
+}
+
+// WithChangeSink registers a sink that is published every relationship change
+// committed to the datastore after it is created, one revision at a time, in
+// revision order and at least once; see ChangeSink. The changes are read from
+// a changefeed on a goroutine of their own, so a slow or failing sink never
+// delays writes, and the feed counts toward MaxConcurrentWatches.
+//
+// The changes awaiting the sink are buffered, per ChangeSinkBufferLength.
+// When the buffer stays full for longer than the WatchBufferWriteTimeout, the
+// changefeed is disconnected and, once the buffered changes have been
+// published, restarted after the last revision published, so that no change
+// is dropped. The WatchBufferOverflowPolicy of other watches does not apply to
+// the sink's changefeed. A sink that falls behind the GC window, however, misses the
+// changes it can no longer read, which is logged as an error.
+//
+// By default, no sink is registered.
+func WithChangeSink(sink ChangeSink) Option {
+	return func(po *crdbOptions) { po.changeSink = sink }
+}
+
+// ChangeSinkBufferLength is the number of revisions of changes buffered while
+// awaiting publication to the sink registered by WithChangeSink.
+//
+// This value defaults to the WatchBufferLength.
+func ChangeSinkBufferLength(length uint16) Option {
+	return func(po *crdbOptions) { po.changeSinkBufferLength = length }
+}
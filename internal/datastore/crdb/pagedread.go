@@ -0,0 +1,143 @@
+package crdb
+
+import (
+	"context"
+	"slices"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// executePaged runs the relationships query in pages of at most pageSize
+// rows, resuming each page after the last relationship of the previous one, so
+// that reading a large result set does not buffer it all at once. Resuming
+// requires the order of the query's sort, so unsorted queries, which are not
+// given one, and queries whose limit already fits within a single page are
+// executed as is.
+func executePaged(
+	ctx context.Context,
+	executor common.QueryRelationshipsExecutor,
+	qBuilder common.SchemaQueryFilterer,
+	pageSize uint64,
+	opts ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	queryOpts := options.NewQueryOptionsWithOptions(opts...)
+	if pageSize == 0 || queryOpts.Sort == options.Unsorted || (queryOpts.Limit != nil && *queryOpts.Limit <= pageSize) {
+		return executor.ExecuteQuery(ctx, qBuilder, opts...)
+	}
+
+	var remaining *uint64
+	if queryOpts.Limit != nil {
+		limit := *queryOpts.Limit
+		remaining = &limit
+	}
+
+	queryPage := func(after options.Cursor) (datastore.RelationshipIterator, uint64, error) {
+		limit := pageSize
+		if remaining != nil && *remaining < limit {
+			limit = *remaining
+		}
+
+		pageOpts := append(slices.Clone(opts),
+			options.WithLimit(&limit),
+			options.WithAfter(after),
+		)
+		iter, err := executor.ExecuteQuery(ctx, qBuilder, pageOpts...)
+		return iter, limit, err
+	}
+
+	iter, limit, err := queryPage(queryOpts.After)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(tuple.Relationship, error) bool) {
+		for {
+			var cursor options.Cursor
+			var count uint64
+			for rel, err := range iter {
+				if !yield(rel, err) || err != nil {
+					return
+				}
+
+				cursor = options.ToCursor(rel)
+				count++
+			}
+
+			if remaining != nil {
+				*remaining -= count
+				if *remaining == 0 {
+					return
+				}
+			}
+
+			if count < limit {
+				return
+			}
+
+			iter, limit, err = queryPage(cursor)
+			if err != nil {
+				yield(tuple.Relationship{}, err)
+				return
+			}
+		}
+	}, nil
+}
+
+// executeLimited runs the relationships query with executePaged, yielding at
+// most maxResults relationships, followed by a datastore.ResultsTruncatedError
+// if the query has further results. Unsorted queries that may be truncated are
+// read in the order of the primary key, so that the cursor of the error can be
+// used to resume them. A maxResults of zero does not limit the results.
+func executeLimited(
+	ctx context.Context,
+	executor common.QueryRelationshipsExecutor,
+	qBuilder common.SchemaQueryFilterer,
+	pageSize uint64,
+	maxResults uint64,
+	opts ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	queryOpts := options.NewQueryOptionsWithOptions(opts...)
+	if maxResults == 0 || (queryOpts.Limit != nil && *queryOpts.Limit <= maxResults) {
+		return executePaged(ctx, executor, qBuilder, pageSize, opts...)
+	}
+
+	sort := queryOpts.Sort
+	if sort == options.Unsorted {
+		sort = options.ByResource
+	}
+
+	// Read one more relationship than the maximum to find whether there are
+	// further results.
+	limit := maxResults + 1
+	limitedOpts := append(slices.Clone(opts), options.WithSort(sort), options.WithLimit(&limit))
+	iter, err := executePaged(ctx, executor, qBuilder, pageSize, limitedOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(tuple.Relationship, error) bool) {
+		var cursor options.Cursor
+		var count uint64
+		for rel, err := range iter {
+			if err != nil {
+				yield(rel, err)
+				return
+			}
+
+			if count == maxResults {
+				yield(tuple.Relationship{}, datastore.NewResultsTruncatedErr(maxResults, cursor))
+				return
+			}
+
+			if !yield(rel, nil) {
+				return
+			}
+
+			cursor = options.ToCursor(rel)
+			count++
+		}
+	}, nil
+}
@@ -0,0 +1,219 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var limitRegex = regexp.MustCompile(`LIMIT (\d+)`)
+
+// pagingExecutor serves the relationships in order, one page per query, and
+// records the limit of each query and whether it was sorted.
+type pagingExecutor struct {
+	rels    []tuple.Relationship
+	offset  int
+	limits  []int
+	orderBy []bool
+}
+
+func (pe *pagingExecutor) execute(_ context.Context, builder common.RelationshipsQueryBuilder) (datastore.RelationshipIterator, error) {
+	sql, _, err := builder.SelectSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := len(pe.rels)
+	if match := limitRegex.FindStringSubmatch(sql); match != nil {
+		limit, err = strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	pe.limits = append(pe.limits, limit)
+	pe.orderBy = append(pe.orderBy, strings.Contains(sql, "ORDER BY"))
+
+	page := pe.rels[pe.offset:min(pe.offset+limit, len(pe.rels))]
+	pe.offset += len(page)
+	return func(yield func(tuple.Relationship, error) bool) {
+		for _, rel := range page {
+			if !yield(rel, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// defaultSchema returns the schema of the relationships table of a datastore
+// with the default configuration.
+func defaultSchema(t *testing.T) *common.SchemaInformation {
+	config, err := generateConfig(nil)
+	require.NoError(t, err)
+	return newCRDBSchema(config)
+}
+
+func TestExecutePaged(t *testing.T) {
+	schema := defaultSchema(t)
+
+	rels := make([]tuple.Relationship, 0, 25)
+	for i := range 25 {
+		rels = append(rels, tuple.MustParse(fmt.Sprintf("document:doc%02d#viewer@user:tom", i)))
+	}
+
+	uint64Ptr := func(v uint64) *uint64 { return &v }
+
+	tcs := []struct {
+		name            string
+		pageSize        uint64
+		sort            options.SortOrder
+		limit           *uint64
+		expectedLimits  []int
+		expectedOrderBy []bool
+		expectedCount   int
+	}{
+		{"single page", 50, options.ByResource, nil, []int{50}, []bool{true}, 25},
+		{"multiple pages", 10, options.ByResource, nil, []int{10, 10, 10}, []bool{true, true, true}, 25},
+		{"exact multiple of the page size", 5, options.ByResource, nil, []int{5, 5, 5, 5, 5, 5}, []bool{true, true, true, true, true, true}, 25},
+		{"limit within a page", 10, options.ByResource, uint64Ptr(7), []int{7}, []bool{true}, 7},
+		{"limit across pages", 10, options.ByResource, uint64Ptr(15), []int{10, 5}, []bool{true, true}, 15},
+		{"limit beyond the results", 10, options.ByResource, uint64Ptr(100), []int{10, 10, 10}, []bool{true, true, true}, 25},
+		{"unsorted is not paged", 10, options.Unsorted, nil, []int{25}, []bool{false}, 25},
+		{"unsorted with limit is not paged", 10, options.Unsorted, uint64Ptr(15), []int{15}, []bool{false}, 15},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			qBuilder, err := common.NewSchemaQueryFiltererForRelationshipsSelect(*schema, 100).
+				FilterWithRelationshipsFilter(datastore.RelationshipsFilter{OptionalResourceType: "document"})
+			require.NoError(t, err)
+
+			pe := &pagingExecutor{rels: rels}
+			executor := common.QueryRelationshipsExecutor{Executor: pe.execute}
+
+			opts := []options.QueryOptionsOption{options.WithSort(tc.sort)}
+			if tc.limit != nil {
+				opts = append(opts, options.WithLimit(tc.limit))
+			}
+
+			iter, err := executePaged(context.Background(), executor, qBuilder, tc.pageSize, opts...)
+			require.NoError(t, err)
+
+			found, err := datastore.IteratorToSlice(iter)
+			require.NoError(t, err)
+			require.Equal(t, rels[:tc.expectedCount], found)
+			require.Equal(t, tc.expectedLimits, pe.limits)
+
+			// Only the queries the caller asked to sort are sorted.
+			require.Equal(t, tc.expectedOrderBy, pe.orderBy)
+		})
+	}
+}
+
+func TestExecuteLimited(t *testing.T) {
+	schema := defaultSchema(t)
+
+	rels := make([]tuple.Relationship, 0, 25)
+	for i := range 25 {
+		rels = append(rels, tuple.MustParse(fmt.Sprintf("document:doc%02d#viewer@user:tom", i)))
+	}
+
+	uint64Ptr := func(v uint64) *uint64 { return &v }
+
+	tcs := []struct {
+		name          string
+		maxResults    uint64
+		limit         *uint64
+		expectedCount int
+		truncated     bool
+	}{
+		{"no maximum", 0, nil, 25, false},
+		{"maximum beyond the results", 50, nil, 25, false},
+		{"maximum equal to the results", 25, nil, 25, false},
+		{"maximum within the results", 10, nil, 10, true},
+		{"limit within the maximum", 10, uint64Ptr(5), 5, false},
+		{"limit beyond the maximum", 10, uint64Ptr(20), 10, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			qBuilder, err := common.NewSchemaQueryFiltererForRelationshipsSelect(*schema, 100).
+				FilterWithRelationshipsFilter(datastore.RelationshipsFilter{OptionalResourceType: "document"})
+			require.NoError(t, err)
+
+			pe := &pagingExecutor{rels: rels}
+			executor := common.QueryRelationshipsExecutor{Executor: pe.execute}
+
+			var opts []options.QueryOptionsOption
+			if tc.limit != nil {
+				opts = append(opts, options.WithLimit(tc.limit))
+			}
+
+			iter, err := executeLimited(context.Background(), executor, qBuilder, 100, tc.maxResults, opts...)
+			require.NoError(t, err)
+
+			var found []tuple.Relationship
+			var iterErr error
+			for rel, err := range iter {
+				if err != nil {
+					iterErr = err
+					break
+				}
+				found = append(found, rel)
+			}
+			require.Equal(t, rels[:tc.expectedCount], found)
+
+			if !tc.truncated {
+				require.NoError(t, iterErr)
+				return
+			}
+
+			var truncatedErr datastore.ResultsTruncatedError
+			require.ErrorAs(t, iterErr, &truncatedErr)
+			require.Equal(t, tc.maxResults, truncatedErr.Limit())
+			require.Equal(t, rels[tc.expectedCount-1], *options.ToRelationship(truncatedErr.Cursor()))
+			require.Equal(t, []bool{true}, pe.orderBy, "truncated queries must be sorted")
+		})
+	}
+}
+
+func TestReadExportBatchWithinMaxListResults(t *testing.T) {
+	rels := make([]tuple.Relationship, 0, 25)
+	for i := range 25 {
+		rels = append(rels, tuple.MustParse(fmt.Sprintf("document:doc%02d#viewer@user:tom", i)))
+	}
+
+	cds := &crdbDatastore{readPageSize: 10, maxListResults: 4}
+	require.Equal(t, uint64(4), cds.exportBatchSize())
+
+	pe := &pagingExecutor{rels: rels}
+	reader := &crdbReader{
+		schema:               *defaultSchema(t),
+		executor:             common.QueryRelationshipsExecutor{Executor: pe.execute},
+		filterMaximumIDCount: 100,
+		readPageSize:         cds.readPageSize,
+		maxListResults:       cds.maxListResults,
+	}
+
+	var found []tuple.Relationship
+	var after options.Cursor
+	for {
+		batch, err := readExportBatch(context.Background(), reader, after, cds.exportBatchSize())
+		require.NoError(t, err)
+		found = append(found, batch...)
+		if uint64(len(batch)) < cds.exportBatchSize() {
+			break
+		}
+		after = options.ToCursor(batch[len(batch)-1])
+	}
+	require.Equal(t, rels, found)
+}
@@ -69,7 +69,9 @@ type NodeConnectionBalancer struct {
 
 // NewNodeConnectionBalancer builds a new nodeConnectionBalancer for a given connection pool and health tracker.
 func NewNodeConnectionBalancer(pool *RetryPool, healthTracker *NodeHealthTracker, interval time.Duration) *NodeConnectionBalancer {
-	return &NodeConnectionBalancer{*newNodeConnectionBalancer[*pgxpool.Conn, *pgx.Conn](pool, healthTracker, interval)}
+	balancer := newNodeConnectionBalancer[*pgxpool.Conn, *pgx.Conn](pool, healthTracker, interval)
+	balancer.metricsDisabled = pool.metricsDisabled
+	return &NodeConnectionBalancer{*balancer}
 }
 
 // nodeConnectionBalancer is generic over underlying connection types for
@@ -81,6 +83,8 @@ type nodeConnectionBalancer[P balancePoolConn[C], C balanceConn] struct {
 	healthTracker *NodeHealthTracker
 	rnd           *rand.Rand
 	seed          int64
+
+	metricsDisabled bool
 }
 
 // newNodeConnectionBalancer is generic over underlying connection types for
@@ -133,7 +137,9 @@ func (p *nodeConnectionBalancer[P, C]) Prune(ctx context.Context) {
 func (p *nodeConnectionBalancer[P, C]) mustPruneConnections(ctx context.Context) {
 	start := time.Now()
 	defer func() {
-		pruningTimeHistogram.WithLabelValues(p.pool.ID()).Observe(float64(time.Since(start).Milliseconds()))
+		if !p.metricsDisabled {
+			pruningTimeHistogram.WithLabelValues(p.pool.ID()).Observe(float64(time.Since(start).Milliseconds()))
+		}
 	}()
 	conns := p.pool.AcquireAllIdle(ctx)
 	defer func() {
@@ -175,16 +181,18 @@ func (p *nodeConnectionBalancer[P, C]) mustPruneConnections(ctx context.Context)
 		Msg("connections per node")
 
 	// Delete metrics for nodes we no longer have connections for
-	p.healthTracker.RLock()
-	for node := range p.healthTracker.nodesEverSeen {
-		if _, ok := connectionCounts[node]; !ok {
-			connectionsPerCRDBNodeCountGauge.DeletePartialMatch(map[string]string{
-				"pool":    p.pool.ID(),
-				"node_id": strconv.FormatUint(uint64(node), 10),
-			})
+	if !p.metricsDisabled {
+		p.healthTracker.RLock()
+		for node := range p.healthTracker.nodesEverSeen {
+			if _, ok := connectionCounts[node]; !ok {
+				connectionsPerCRDBNodeCountGauge.DeletePartialMatch(map[string]string{
+					"pool":    p.pool.ID(),
+					"node_id": strconv.FormatUint(uint64(node), 10),
+				})
+			}
 		}
+		p.healthTracker.RUnlock()
 	}
-	p.healthTracker.RUnlock()
 
 	nodes := maps.Keys(connectionCounts)
 	slices.Sort(nodes)
@@ -201,10 +209,12 @@ func (p *nodeConnectionBalancer[P, C]) mustPruneConnections(ctx context.Context)
 	initialPerNodeMax := p.pool.MaxConns() / nodeCount
 	for i, node := range nodes {
 		count := connectionCounts[node]
-		connectionsPerCRDBNodeCountGauge.WithLabelValues(
-			p.pool.ID(),
-			strconv.FormatUint(uint64(node), 10),
-		).Set(float64(count))
+		if !p.metricsDisabled {
+			connectionsPerCRDBNodeCountGauge.WithLabelValues(
+				p.pool.ID(),
+				strconv.FormatUint(uint64(node), 10),
+			).Set(float64(count))
+		}
 
 		perNodeMax := initialPerNodeMax
 
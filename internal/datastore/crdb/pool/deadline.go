@@ -0,0 +1,78 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+)
+
+// cancelRequestDeadlineDelay bounds the time given to CockroachDB to abort a
+// statement whose context is done, via a cancel request, before its connection
+// is closed.
+const cancelRequestDeadlineDelay = 1 * time.Second
+
+// WithDeadlineStatementTimeouts bounds each statement run by the pool by the
+// deadline of its context, so that CockroachDB aborts the statement when the
+// caller would give up on it, rather than leaving it running after the caller
+// has gone. Statements whose context has no deadline run without a timeout.
+//
+// Transactions set their statement_timeout to the time remaining before the
+// deadline once, via SET LOCAL, when they begin. Statements run outside of a
+// transaction, via ExecFunc, QueryFunc or QueryRowFunc, are instead canceled
+// by a cancel request sent to CockroachDB once their context is done, rather
+// than by the connection merely being closed, so that they cost no additional
+// round trips.
+func WithDeadlineStatementTimeouts() RetryPoolOption {
+	return func(p *RetryPool) { p.deadlineStatementTimeouts = true }
+}
+
+// cancelRequestContextWatcher makes the connections of the pool send a cancel
+// request for the running statement when its context is done.
+func cancelRequestContextWatcher(pgConn *pgconn.PgConn) ctxwatch.Handler {
+	return &pgconn.CancelRequestContextWatcherHandler{
+		Conn:          pgConn,
+		DeadlineDelay: cancelRequestDeadlineDelay,
+	}
+}
+
+// deadlineStatementTimeout returns the statement_timeout value for the time
+// remaining before the deadline of the context, rounded up to the millisecond,
+// and false if the context has no deadline. It fails with
+// context.DeadlineExceeded if the deadline has already passed.
+func deadlineStatementTimeout(ctx context.Context) (string, bool, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false, nil
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return "", false, context.DeadlineExceeded
+	}
+
+	// A timeout of zero disables the timeout, so it is at least 1ms.
+	millis := (remaining + time.Millisecond - 1) / time.Millisecond
+	return fmt.Sprintf("'%dms'", millis), true, nil
+}
+
+// setLocalStatementTimeout sets the statement_timeout of the transaction from
+// the deadline of the context, if enabled.
+func (p *RetryPool) setLocalStatementTimeout(ctx context.Context, tx pgx.Tx) error {
+	if !p.deadlineStatementTimeouts {
+		return nil
+	}
+
+	timeout, ok, err := deadlineStatementTimeout(ctx)
+	if err != nil || !ok {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = "+timeout); err != nil {
+		return fmt.Errorf("unable to set the statement timeout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineStatementTimeout(t *testing.T) {
+	_, ok, err := deadlineStatementTimeout(context.Background())
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	timeout, ok, err := deadlineStatementTimeout(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "'3600000ms'", timeout)
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, _, err = deadlineStatementTimeout(expired)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSetLocalStatementTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	// Without the option, no timeout is set.
+	recorder := &recordingTx{}
+	require.NoError(t, (&RetryPool{}).setLocalStatementTimeout(ctx, recorder))
+	require.Empty(t, recorder.statements)
+
+	p := &RetryPool{}
+	WithDeadlineStatementTimeouts()(p)
+
+	// Without a deadline, no timeout is set.
+	require.NoError(t, p.setLocalStatementTimeout(context.Background(), recorder))
+	require.Empty(t, recorder.statements)
+
+	require.NoError(t, p.setLocalStatementTimeout(ctx, recorder))
+	require.Equal(t, []string{"SET LOCAL statement_timeout = '3600000ms'"}, recorder.statements)
+}
+
+func TestCancelRequestContextWatcher(t *testing.T) {
+	handler := cancelRequestContextWatcher(nil)
+	cancelRequest, ok := handler.(*pgconn.CancelRequestContextWatcherHandler)
+	require.True(t, ok, "statements must be canceled by a cancel request, not by closing the connection")
+	require.Equal(t, cancelRequestDeadlineDelay, cancelRequest.DeadlineDelay)
+}
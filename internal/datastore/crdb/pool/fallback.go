@@ -0,0 +1,63 @@
+package pool
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// WithSimpleProtocolFallback makes the pool run ExecFunc, QueryFunc and
+// QueryRowFunc calls again with pgx.QueryExecModeSimpleProtocol when they fail
+// because a prepared statement does not exist, which happens intermittently
+// when connecting through a transaction-mode pooler, such as PgBouncer, with a
+// query exec mode that prepares statements. A warning is logged the first time
+// the pool falls back. Statements run within transactions are not retried, as
+// the failure aborts the transaction.
+func WithSimpleProtocolFallback() RetryPoolOption {
+	return func(p *RetryPool) { p.simpleProtocolFallback = &fallbackState{} }
+}
+
+// fallbackState records whether a pool has warned about falling back to the
+// simple protocol.
+type fallbackState struct {
+	warnOnce sync.Once
+}
+
+// withSimpleProtocolFallback calls fn with the arguments and, if the pool falls
+// back to the simple protocol and fn fails because a prepared statement does
+// not exist, calls it again with the arguments set to use the simple protocol.
+func (p *RetryPool) withSimpleProtocolFallback(ctx context.Context, sql string, arguments []any, fn func(arguments []any) error) error {
+	err := fn(arguments)
+	if err == nil || p.simpleProtocolFallback == nil || !IsPreparedStatementMissing(err) {
+		return err
+	}
+
+	p.simpleProtocolFallback.warnOnce.Do(func() {
+		log.Ctx(ctx).Warn().
+			Str("pool", p.id).
+			Str("sql", sql).
+			Msg("prepared statement does not exist, retrying with the simple protocol; if connecting through a transaction-mode pooler, configure a query exec mode that does not prepare statements")
+	})
+	return fn(simpleProtocolArguments(arguments))
+}
+
+// simpleProtocolArguments returns the arguments with any leading
+// pgx.QueryExecMode replaced by pgx.QueryExecModeSimpleProtocol.
+func simpleProtocolArguments(arguments []any) []any {
+	withMode := make([]any, 0, len(arguments)+1)
+	withMode = append(withMode, pgx.QueryExecModeSimpleProtocol)
+	for i, arg := range arguments {
+		switch arg.(type) {
+		case pgx.QueryExecMode:
+			continue
+		case pgx.QueryResultFormats, pgx.QueryResultFormatsByOID, pgx.QueryRewriter:
+			withMode = append(withMode, arg)
+			continue
+		}
+		return append(withMode, arguments[i:]...)
+	}
+	return withMode
+}
@@ -0,0 +1,83 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleProtocolFallback(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	ctx := logger.WithContext(context.Background())
+
+	missing := fmt.Errorf("unable to run query: %w", &pgconn.PgError{Code: "26000", Message: `prepared statement "stmtcache_1" does not exist`})
+
+	// failPrepared simulates a connection on which prepared statements are
+	// lost, failing unless the simple protocol is requested.
+	var calls [][]any
+	failPrepared := func(arguments []any) error {
+		calls = append(calls, arguments)
+		if len(arguments) > 0 && arguments[0] == pgx.QueryExecModeSimpleProtocol {
+			return nil
+		}
+		return missing
+	}
+
+	disabled := &RetryPool{id: "read"}
+	err := disabled.withSimpleProtocolFallback(ctx, "SELECT $1", []any{1}, failPrepared)
+	require.ErrorIs(t, err, missing)
+	require.Len(t, calls, 1)
+	require.Empty(t, buf.String())
+
+	p := &RetryPool{id: "read"}
+	WithSimpleProtocolFallback()(p)
+
+	calls = nil
+	require.NoError(t, p.withSimpleProtocolFallback(ctx, "SELECT $1", []any{1}, failPrepared))
+	require.Equal(t, [][]any{{1}, {pgx.QueryExecModeSimpleProtocol, 1}}, calls)
+	require.Contains(t, buf.String(), `"level":"warn"`)
+	require.Contains(t, buf.String(), `"sql":"SELECT $1"`)
+
+	// The warning is only logged once per pool.
+	buf.Reset()
+	calls = nil
+	require.NoError(t, p.withSimpleProtocolFallback(ctx, "SELECT $1", []any{2}, failPrepared))
+	require.Len(t, calls, 2)
+	require.Empty(t, buf.String())
+
+	// Other errors are not retried.
+	other := errors.New("connection reset")
+	calls = nil
+	err = p.withSimpleProtocolFallback(ctx, "SELECT $1", []any{3}, func(arguments []any) error {
+		calls = append(calls, arguments)
+		return other
+	})
+	require.ErrorIs(t, err, other)
+	require.Len(t, calls, 1)
+}
+
+func TestSimpleProtocolArguments(t *testing.T) {
+	formats := pgx.QueryResultFormats{1}
+	for _, tc := range []struct {
+		name      string
+		arguments []any
+		expected  []any
+	}{
+		{"no arguments", nil, []any{pgx.QueryExecModeSimpleProtocol}},
+		{"arguments", []any{1, "a"}, []any{pgx.QueryExecModeSimpleProtocol, 1, "a"}},
+		{"exec mode replaced", []any{pgx.QueryExecModeCacheStatement, 1}, []any{pgx.QueryExecModeSimpleProtocol, 1}},
+		{"options kept", []any{formats, pgx.QueryExecModeExec, 1}, []any{pgx.QueryExecModeSimpleProtocol, formats, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, simpleProtocolArguments(tc.arguments))
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -37,6 +38,12 @@ type NodeHealthTracker struct {
 	healthyNodes  map[uint32]struct{}
 	nodesEverSeen map[uint32]*rate.Limiter
 	newLimiter    func() *rate.Limiter
+
+	// lastPoll is the Unix time, in nanoseconds, at which the cluster was last
+	// polled, or zero if it has not been.
+	lastPoll atomic.Int64
+
+	metricsDisabled bool
 }
 
 // NewNodeHealthChecker builds a health checker that polls the cluster at the given url.
@@ -56,6 +63,14 @@ func NewNodeHealthChecker(url string) (*NodeHealthTracker, error) {
 	}, nil
 }
 
+// DisableMetrics stops the tracker from updating its Prometheus metrics. It
+// must be called before the tracker is used.
+func (t *NodeHealthTracker) DisableMetrics() {
+	t.Lock()
+	defer t.Unlock()
+	t.metricsDisabled = true
+}
+
 // Poll starts polling the cluster and recording the node IDs that it sees.
 func (t *NodeHealthTracker) Poll(ctx context.Context, interval time.Duration) {
 	ticker := jitterbug.New(interval, jitterbug.Uniform{
@@ -76,8 +91,19 @@ func (t *NodeHealthTracker) Poll(ctx context.Context, interval time.Duration) {
 	}
 }
 
+// LastPoll returns when the cluster was last polled, whether or not a node
+// could be reached, or the zero time if it has not been polled.
+func (t *NodeHealthTracker) LastPoll() time.Time {
+	nanos := t.lastPoll.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
 // tryConnect attempts to connect to a node and ping it. If successful, that node is marked healthy.
 func (t *NodeHealthTracker) tryConnect(interval time.Duration) {
+	defer func() { t.lastPoll.Store(time.Now().UnixNano()) }()
 	ctx, cancel := context.WithTimeout(context.Background(), interval)
 	defer cancel()
 	conn, err := pgx.ConnectConfig(ctx, t.connConfig)
@@ -104,7 +130,9 @@ func (t *NodeHealthTracker) SetNodeHealth(nodeID uint32, healthy bool) {
 	t.Lock()
 	defer t.Unlock()
 	defer func() {
-		healthyCRDBNodeCountGauge.Set(float64(len(t.healthyNodes)))
+		if !t.metricsDisabled {
+			healthyCRDBNodeCountGauge.Set(float64(len(t.healthyNodes)))
+		}
 	}()
 
 	if _, ok := t.nodesEverSeen[nodeID]; !ok {
@@ -39,3 +39,15 @@ func TestNodeHealthTracker(t *testing.T) {
 	require.True(t, tracker.IsHealthy(2))
 	require.Equal(t, tracker.HealthyNodeCount(), 1)
 }
+
+func TestNodeHealthTrackerLastPoll(t *testing.T) {
+	tracker, err := NewNodeHealthChecker("postgres://root@127.0.0.1:1/spicedb?sslmode=disable")
+	require.NoError(t, err)
+	require.True(t, tracker.LastPoll().IsZero())
+
+	// A poll that reaches no node is still recorded.
+	before := time.Now()
+	tracker.tryConnect(100 * time.Millisecond)
+	require.False(t, tracker.LastPoll().Before(before))
+	require.Equal(t, 0, tracker.HealthyNodeCount())
+}
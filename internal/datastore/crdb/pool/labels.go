@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// WithStatementLabels appends the statement label of the context, set via
+// datastore.WithStatementLabel, to each statement run by the pool as a
+// sqlcommenter-style comment, e.g. `/*spicedb_operation='check'*/`. The
+// comment is kept in the statement text shown by CockroachDB's statement
+// diagnostics and SQL activity pages, allowing statements to be attributed to
+// the class of operation that issued them.
+//
+// Since the label is part of the statement text, each label results in a
+// separate entry in the statement cache of a connection.
+func WithStatementLabels() RetryPoolOption {
+	return func(p *RetryPool) { p.statementLabels = true }
+}
+
+// statementLabelRegex restricts statement labels to characters that can
+// neither terminate the comment nor the quoted value in which they are placed.
+var statementLabelRegex = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// LabelStatement appends the statement label of the context to the SQL. SQL is
+// returned unchanged when the context has no label, or when the label has
+// characters other than ASCII letters, digits, `_`, `.`, `:` and `-`, which
+// could otherwise inject text into the statement.
+func LabelStatement(ctx context.Context, sql string) string {
+	label := datastore.StatementLabel(ctx)
+	if !statementLabelRegex.MatchString(label) {
+		return sql
+	}
+	return sql + " /*spicedb_operation='" + label + "'*/"
+}
+
+func (p *RetryPool) label(ctx context.Context, sql string) string {
+	if !p.statementLabels {
+		return sql
+	}
+	return LabelStatement(ctx, sql)
+}
+
+// labelingTx is a pgx.Tx that labels the statements run within the
+// transaction.
+type labelingTx struct {
+	pgx.Tx
+}
+
+func (tx labelingTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return tx.Tx.Exec(ctx, LabelStatement(ctx, sql), arguments...)
+}
+
+func (tx labelingTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.Tx.Query(ctx, LabelStatement(ctx, sql), args...)
+}
+
+func (tx labelingTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.Tx.QueryRow(ctx, LabelStatement(ctx, sql), args...)
+}
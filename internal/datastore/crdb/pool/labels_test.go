@@ -0,0 +1,77 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// recordingTx records the statements executed within it.
+type recordingTx struct {
+	pgx.Tx
+	statements []string
+}
+
+func (tx *recordingTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	tx.statements = append(tx.statements, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func TestLabelStatement(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, "SELECT 1", LabelStatement(ctx, "SELECT 1"))
+
+	ctx = datastore.WithStatementLabel(ctx, datastore.StatementLabelCheck)
+	require.Equal(t, "SELECT 1 /*spicedb_operation='check'*/", LabelStatement(ctx, "SELECT 1"))
+}
+
+func TestLabelStatementRejectsUnsafeLabels(t *testing.T) {
+	for _, label := range []string{
+		"check*/; DROP TABLE relation_tuple; /*",
+		"check' OR '1'='1",
+		"check\nDELETE FROM relation_tuple",
+		"check\x00",
+		"check\t",
+		"check /* nested",
+		"chéck",
+	} {
+		ctx := datastore.WithStatementLabel(context.Background(), label)
+		require.Equal(t, "SELECT 1", LabelStatement(ctx, "SELECT 1"), "label %q", label)
+	}
+
+	ctx := datastore.WithStatementLabel(context.Background(), "tenant-1.check:v2_x")
+	require.Equal(t, "SELECT 1 /*spicedb_operation='tenant-1.check:v2_x'*/", LabelStatement(ctx, "SELECT 1"))
+}
+
+func TestStatementLabelsOption(t *testing.T) {
+	ctx := datastore.WithStatementLabel(context.Background(), datastore.StatementLabelWrite)
+
+	unlabeled := &RetryPool{}
+	require.Equal(t, "SELECT 1", unlabeled.label(ctx, "SELECT 1"))
+
+	labeled := &RetryPool{}
+	WithStatementLabels()(labeled)
+	require.Equal(t, "SELECT 1 /*spicedb_operation='write'*/", labeled.label(ctx, "SELECT 1"))
+}
+
+func TestLabelingTx(t *testing.T) {
+	recorder := &recordingTx{}
+	tx := labelingTx{recorder}
+
+	_, err := tx.Exec(context.Background(), "DELETE FROM t")
+	require.NoError(t, err)
+
+	ctx := datastore.WithStatementLabel(context.Background(), datastore.StatementLabelWrite)
+	_, err = tx.Exec(ctx, "DELETE FROM t")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"DELETE FROM t",
+		"DELETE FROM t /*spicedb_operation='write'*/",
+	}, recorder.statements)
+}
@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ConnectionEvent describes a connection of a RetryPool being established or
+// closed, or failing to be established. It never includes the credentials of
+// the connection.
+type ConnectionEvent struct {
+	// Pool is the name of the pool, e.g. "read" or "write".
+	Pool string
+
+	// Addr is the host and port of the server.
+	Addr string
+
+	// NodeID is the CockroachDB node of the connection, or zero if it failed
+	// to be established.
+	NodeID uint32
+
+	// Err is the reason the connection failed to be established, and is nil
+	// for other events. It is the cause wrapped by the pgconn.ConnectError,
+	// without the configuration of the connection, and wraps the
+	// *pgconn.PgError carrying the SQLSTATE if the server refused the
+	// connection.
+	Err error
+}
+
+// ConnectionCallbacks are invoked on the lifecycle events of the connections
+// of a RetryPool. Callbacks run synchronously on the path establishing or
+// closing the connection, though not under the pool's lock, so they must
+// return quickly and must not block; any of them may be nil.
+type ConnectionCallbacks struct {
+	// OnConnect is invoked once a connection has been established and
+	// registered with the pool.
+	OnConnect func(ConnectionEvent)
+
+	// OnDisconnect is invoked before a connection is closed.
+	OnDisconnect func(ConnectionEvent)
+
+	// OnConnectError is invoked when an operation fails to acquire a
+	// connection because one could not be established. Failures of the
+	// connections the pool establishes in the background to maintain its
+	// minimum size are not reported.
+	OnConnectError func(ConnectionEvent)
+}
+
+// WithConnectionCallbacks invokes the callbacks on the lifecycle events of the
+// pool's connections.
+func WithConnectionCallbacks(callbacks ConnectionCallbacks) RetryPoolOption {
+	return func(p *RetryPool) { p.connectionCallbacks = callbacks }
+}
+
+func (p *RetryPool) notifyConnect(conn *pgx.Conn, nodeID uint32) {
+	if p.connectionCallbacks.OnConnect != nil {
+		p.connectionCallbacks.OnConnect(ConnectionEvent{Pool: p.id, Addr: connAddr(conn), NodeID: nodeID})
+	}
+}
+
+func (p *RetryPool) notifyDisconnect(conn *pgx.Conn, nodeID uint32) {
+	if p.connectionCallbacks.OnDisconnect != nil {
+		p.connectionCallbacks.OnDisconnect(ConnectionEvent{Pool: p.id, Addr: connAddr(conn), NodeID: nodeID})
+	}
+}
+
+// notifyConnectError invokes OnConnectError if the error, returned when
+// acquiring a connection with the context, reports that the connection could
+// not be established. Errors caused by the cancellation or deadline of the
+// context are not failures of the node, and are not reported.
+func (p *RetryPool) notifyConnectError(ctx context.Context, err error) {
+	if p.connectionCallbacks.OnConnectError == nil {
+		return
+	}
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	var connectErr *pgconn.ConnectError
+	if !errors.As(err, &connectErr) {
+		return
+	}
+
+	// The error is reported without the ConnectError, whose configuration
+	// holds the credentials of the connection.
+	event := ConnectionEvent{Pool: p.id, Err: connectErr.Unwrap()}
+	if connectErr.Config != nil {
+		event.Addr = net.JoinHostPort(connectErr.Config.Host, strconv.Itoa(int(connectErr.Config.Port)))
+	}
+	p.connectionCallbacks.OnConnectError(event)
+}
+
+// connAddr returns the address of the server of the connection.
+func connAddr(conn *pgx.Conn) string {
+	if netConn := conn.PgConn().Conn(); netConn != nil {
+		return netConn.RemoteAddr().String()
+	}
+	config := conn.Config()
+	return net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port)))
+}
@@ -0,0 +1,204 @@
+package pool
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// Counter is a metric whose value only increases. The values of its labels are
+// given in the order in which the labels were declared.
+type Counter interface {
+	Add(value float64, labelValues ...string)
+}
+
+// Gauge is a metric whose value is set to the latest measurement.
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+}
+
+// Histogram is a metric that records the distribution of its observations.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// Metrics creates the metrics recorded by the pools and the datastore, so that
+// they can be exported with the metrics library of the deployment. Metrics are
+// created with the name, help text, buckets and label names of the metric.
+type Metrics interface {
+	Counter(name, help string, labels ...string) Counter
+	Gauge(name, help string, labels ...string) Gauge
+	Histogram(name, help string, buckets []float64, labels ...string) Histogram
+}
+
+// WithMetrics records the pool's metrics with the given Metrics rather than
+// with Prometheus.
+func WithMetrics(metrics Metrics) RetryPoolOption {
+	return func(p *RetryPool) { p.metrics = metrics }
+}
+
+// PrometheusMetrics returns Metrics that registers the metrics with the default
+// Prometheus registerer. A metric already registered under the same name is
+// reused, so that every pool and datastore records the same metrics.
+func PrometheusMetrics() Metrics {
+	return prometheusMetrics{registerer: prometheus.DefaultRegisterer}
+}
+
+type prometheusMetrics struct {
+	registerer prometheus.Registerer
+}
+
+func (pm prometheusMetrics) Counter(name, help string, labels ...string) Counter {
+	counter := registerOrReuse(pm.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, labels))
+	return prometheusCounter{counter}
+}
+
+func (pm prometheusMetrics) Gauge(name, help string, labels ...string) Gauge {
+	gauge := registerOrReuse(pm.registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: name,
+		Help: help,
+	}, labels))
+	return prometheusGauge{gauge}
+}
+
+func (pm prometheusMetrics) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	histogram := registerOrReuse(pm.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: buckets,
+	}, labels))
+	return prometheusHistogram{histogram}
+}
+
+// registerOrReuse registers the collector, returning the collector already
+// registered in its place, if any.
+func registerOrReuse[C prometheus.Collector](registerer prometheus.Registerer, collector C) C {
+	err := registerer.Register(collector)
+	if err == nil {
+		return collector
+	}
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(C); ok {
+			return existing
+		}
+	}
+	log.Warn().Err(err).Msg("unable to register datastore metric with prometheus")
+	return collector
+}
+
+type prometheusCounter struct {
+	vec *prometheus.CounterVec
+}
+
+func (c prometheusCounter) Add(value float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(value)
+}
+
+type prometheusGauge struct {
+	vec *prometheus.GaugeVec
+}
+
+func (g prometheusGauge) Set(value float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Set(value)
+}
+
+type prometheusHistogram struct {
+	vec *prometheus.HistogramVec
+}
+
+func (h prometheusHistogram) Observe(value float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(value)
+}
+
+// OpenTelemetryMetrics returns Metrics that records the metrics as the
+// instruments of the OpenTelemetry meter. The labels of a metric are recorded
+// as string attributes.
+func OpenTelemetryMetrics(meter metric.Meter) Metrics {
+	return otelMetrics{meter: meter}
+}
+
+type otelMetrics struct {
+	meter metric.Meter
+}
+
+func (om otelMetrics) Counter(name, help string, labels ...string) Counter {
+	counter, err := om.meter.Float64Counter(name, metric.WithDescription(help))
+	if err != nil || counter == nil {
+		logOTelError(name, err)
+		counter, _ = noop.Meter{}.Float64Counter(name)
+	}
+	return otelCounter{counter: counter, labels: labels}
+}
+
+func (om otelMetrics) Gauge(name, help string, labels ...string) Gauge {
+	gauge, err := om.meter.Float64Gauge(name, metric.WithDescription(help))
+	if err != nil || gauge == nil {
+		logOTelError(name, err)
+		gauge, _ = noop.Meter{}.Float64Gauge(name)
+	}
+	return otelGauge{gauge: gauge, labels: labels}
+}
+
+func (om otelMetrics) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	histogram, err := om.meter.Float64Histogram(name, metric.WithDescription(help), metric.WithExplicitBucketBoundaries(buckets...))
+	if err != nil || histogram == nil {
+		logOTelError(name, err)
+		histogram, _ = noop.Meter{}.Float64Histogram(name)
+	}
+	return otelHistogram{histogram: histogram, labels: labels}
+}
+
+// logOTelError logs the failure to create the instrument of a metric, which
+// is then not recorded.
+func logOTelError(name string, err error) {
+	log.Warn().Err(err).Str("metric", name).Msg("unable to create datastore metric with opentelemetry")
+}
+
+// otelAttributes pairs the names of the labels with their values.
+func otelAttributes(labels, labelValues []string) metric.MeasurementOption {
+	attributes := make([]attribute.KeyValue, 0, len(labels))
+	for i, label := range labels {
+		if i < len(labelValues) {
+			attributes = append(attributes, attribute.String(label, labelValues[i]))
+		}
+	}
+	return metric.WithAttributes(attributes...)
+}
+
+type otelCounter struct {
+	counter metric.Float64Counter
+	labels  []string
+}
+
+func (c otelCounter) Add(value float64, labelValues ...string) {
+	c.counter.Add(context.Background(), value, otelAttributes(c.labels, labelValues))
+}
+
+type otelGauge struct {
+	gauge  metric.Float64Gauge
+	labels []string
+}
+
+func (g otelGauge) Set(value float64, labelValues ...string) {
+	g.gauge.Record(context.Background(), value, otelAttributes(g.labels, labelValues))
+}
+
+type otelHistogram struct {
+	histogram metric.Float64Histogram
+	labels    []string
+}
+
+func (h otelHistogram) Observe(value float64, labelValues ...string) {
+	h.histogram.Record(context.Background(), value, otelAttributes(h.labels, labelValues))
+}
@@ -0,0 +1,119 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := prometheusMetrics{registerer: registry}
+
+	metrics.Counter("test_counter", "a counter", "pool").Add(2, "read")
+
+	// A metric registered again is reused rather than failing to register.
+	metrics.Counter("test_counter", "a counter", "pool").Add(1, "read")
+	metrics.Histogram("test_histogram", "a histogram", []float64{1, 10}).Observe(5)
+	metrics.Gauge("test_gauge", "a gauge").Set(3)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 3)
+	for _, family := range families {
+		if family.GetName() == "test_counter" {
+			require.Equal(t, 3.0, family.GetMetric()[0].GetCounter().GetValue())
+		}
+	}
+}
+
+func TestOpenTelemetryMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	metrics := OpenTelemetryMetrics(provider.Meter("test"))
+
+	metrics.Counter("test_counter", "a counter", "pool").Add(2, "read")
+	metrics.Histogram("test_histogram", "a histogram", []float64{1, 10}).Observe(5)
+	metrics.Gauge("test_gauge", "a gauge").Set(3)
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &collected))
+	require.Len(t, collected.ScopeMetrics, 1)
+
+	recorded := map[string]metricdata.Aggregation{}
+	for _, m := range collected.ScopeMetrics[0].Metrics {
+		recorded[m.Name] = m.Data
+	}
+	require.Len(t, recorded, 3)
+
+	counter := recorded["test_counter"].(metricdata.Sum[float64])
+	require.Equal(t, 2.0, counter.DataPoints[0].Value)
+	pool, ok := counter.DataPoints[0].Attributes.Value(attribute.Key("pool"))
+	require.True(t, ok)
+	require.Equal(t, "read", pool.AsString())
+
+	histogram := recorded["test_histogram"].(metricdata.Histogram[float64])
+	require.Equal(t, []float64{1, 10}, histogram.DataPoints[0].Bounds)
+	require.Equal(t, uint64(1), histogram.DataPoints[0].Count)
+
+	gauge := recorded["test_gauge"].(metricdata.Gauge[float64])
+	require.Equal(t, 3.0, gauge.DataPoints[0].Value)
+}
+
+func TestPoolWithMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	p := &RetryPool{id: "otel"}
+	WithMetrics(OpenTelemetryMetrics(provider.Meter("test")))(p)
+	recorded := newPoolMetrics(p.metrics)
+	p.poolMetrics = &recorded
+	p.recordedMetrics().overloaded.Add(1, p.id)
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &collected))
+	require.Equal(t, metricOverloaded, collected.ScopeMetrics[0].Metrics[0].Name)
+
+	// Pools created without WithMetrics record Prometheus metrics.
+	require.Same(t, &prometheusPoolMetrics, (&RetryPool{}).recordedMetrics())
+}
+
+func TestPoolRecordsStatementDuration(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	p := &RetryPool{id: "read"}
+	WithMetrics(OpenTelemetryMetrics(provider.Meter("test")))(p)
+	recorded := newPoolMetrics(p.metrics)
+	p.poolMetrics = &recorded
+
+	ctx := context.Background()
+	p.recordDuration(datastore.WithStatementLabel(ctx, datastore.StatementLabelCheck), "SELECT 1", time.Now())
+	p.recordDuration(ctx, "SELECT 1", time.Now())
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &collected))
+	require.Equal(t, metricStatementDuration, collected.ScopeMetrics[0].Metrics[0].Name)
+
+	operations := map[string]uint64{}
+	for _, point := range collected.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints {
+		operation, ok := point.Attributes.Value(attribute.Key("operation"))
+		require.True(t, ok)
+		operations[operation.AsString()] = point.Count
+	}
+	require.Equal(t, map[string]uint64{datastore.StatementLabelCheck: 1, unlabeledOperation: 1}, operations)
+
+	// Pools without metrics record no durations.
+	disabled := &RetryPool{id: "read", metricsDisabled: true, poolMetrics: &recorded}
+	disabled.recordDuration(ctx, "SELECT 1", time.Now())
+	require.NoError(t, reader.Collect(ctx, &collected))
+	require.Equal(t, uint64(1), collected.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints[0].Count)
+}
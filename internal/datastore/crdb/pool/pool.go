@@ -6,27 +6,65 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ccoveille/go-safecast"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/semaphore"
 	"golang.org/x/time/rate"
 
 	"github.com/authzed/spicedb/internal/datastore/postgres/common"
 	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
 )
 
-var resetHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
-	Name:    "crdb_client_resets",
-	Help:    "cockroachdb client-side tx reset distribution",
-	Buckets: []float64{0, 1, 2, 5, 10, 20, 50},
-})
+const (
+	metricResets               = "crdb_client_resets"
+	metricOverloaded           = "crdb_client_overloaded_rejections"
+	metricRetryBudgetExhausted = "crdb_client_retry_budget_exhausted"
+	metricStatementDuration    = "crdb_client_statement_duration_seconds"
+)
+
+// unlabeledOperation is the operation recorded for the statements and
+// transactions without a statement label.
+const unlabeledOperation = "other"
 
-func init() {
-	prometheus.MustRegister(resetHistogram)
+// poolMetrics are the metrics recorded by a pool.
+type poolMetrics struct {
+	resets               Histogram
+	overloaded           Counter
+	retryBudgetExhausted Counter
+	statementDuration    Histogram
+}
+
+func newPoolMetrics(metrics Metrics) poolMetrics {
+	return poolMetrics{
+		resets: metrics.Histogram(metricResets, "cockroachdb client-side tx reset distribution",
+			[]float64{0, 1, 2, 5, 10, 20, 50}),
+		overloaded: metrics.Counter(metricOverloaded,
+			"number of cockroachdb requests rejected because the connection pool acquire queue was full", "pool"),
+		retryBudgetExhausted: metrics.Counter(metricRetryBudgetExhausted,
+			"number of cockroachdb retries abandoned because the retry budget was exhausted", "pool"),
+		statementDuration: metrics.Histogram(metricStatementDuration,
+			"duration of cockroachdb statements and transactions, by the class of operation of their statement label",
+			[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}, "pool", "operation"),
+	}
+}
+
+// prometheusPoolMetrics are registered with Prometheus on startup, and are
+// recorded by the pools created without WithMetrics.
+var prometheusPoolMetrics = newPoolMetrics(PrometheusMetrics())
+
+// recordedMetrics returns the metrics recorded by the pool.
+func (p *RetryPool) recordedMetrics() *poolMetrics {
+	if p.poolMetrics == nil {
+		return &prometheusPoolMetrics
+	}
+	return p.poolMetrics
 }
 
 type ctxDisableRetries struct{}
@@ -39,12 +77,148 @@ type RetryPool struct {
 	healthTracker *NodeHealthTracker
 
 	sync.RWMutex
-	maxRetries  uint8
-	nodeForConn map[*pgx.Conn]uint32
-	gc          map[*pgx.Conn]struct{}
+	maxRetries   uint8
+	queryTimeout time.Duration
+	nodeForConn  map[*pgx.Conn]uint32
+	gc           map[*pgx.Conn]struct{}
+
+	maxAcquireQueueDepth int32
+	acquiring            atomic.Int32
+
+	retryBudget *rate.Limiter
+
+	slowQueryThreshold time.Duration
+
+	retryLogSampleRate uint64
+	retryLogEvents     atomic.Uint64
+
+	statementLabels bool
+
+	deadlineStatementTimeouts bool
+
+	simpleProtocolFallback *fallbackState
+
+	connectionCallbacks ConnectionCallbacks
+
+	fairAcquisition bool
+	fairQueue       *semaphore.Weighted
+
+	metricsDisabled bool
+	metrics         Metrics
+	poolMetrics     *poolMetrics
+
+	refillMode RefillMode
+
+	// minIdleConns is the number of connections kept idle by maintainIdle,
+	// which is stopped by stopIdle and closes idleDone once it has returned.
+	minIdleConns int32
+	stopIdle     context.CancelFunc
+	idleDone     chan struct{}
+}
+
+// RefillMode determines how quickly a pool opens the connections it is
+// missing, such as after a restart of the cluster closes all of them.
+type RefillMode int
+
+const (
+	// RefillEager dials all of the missing connections at once, and throttles
+	// only their initialization to the pool's connect rate, so that the pool
+	// recovers within a single health check.
+	RefillEager RefillMode = iota
+
+	// RefillGradual throttles the dialing of each new connection to the
+	// pool's connect rate, spreading the recovery of the pool over time to
+	// avoid a thundering herd of connection attempts against the cluster.
+	RefillGradual
+)
+
+// RetryPoolOption configures optional behavior of a RetryPool.
+type RetryPoolOption func(*RetryPool)
+
+// WithQueryTimeout bounds the total time, including retries, that a single
+// ExecFunc, QueryFunc or QueryRowFunc call, or a BeginFunc or BeginTxFunc
+// call, may take. The timeout of a transaction covers all of it: its begin,
+// the caller's function, its commit and every retry of them, so a transaction
+// whose function is still running when the timeout elapses fails, at the
+// latest when committing, with context.DeadlineExceeded. A zero timeout means
+// no timeout.
+func WithQueryTimeout(timeout time.Duration) RetryPoolOption {
+	return func(p *RetryPool) { p.queryTimeout = timeout }
 }
 
-func NewRetryPool(ctx context.Context, name string, config *pgxpool.Config, healthTracker *NodeHealthTracker, maxRetries uint8, connectRate time.Duration) (*RetryPool, error) {
+// WithMaxAcquireQueueDepth makes the pool fail fast with a
+// datastore.OverloadedError, rather than block, when all of its connections
+// are in use and more than depth callers are already waiting to acquire one.
+// A zero depth means callers always wait.
+func WithMaxAcquireQueueDepth(depth int32) RetryPoolOption {
+	return func(p *RetryPool) { p.maxAcquireQueueDepth = depth }
+}
+
+// WithRetryBudget limits the retries performed by the pool to those allowed by
+// the given limiter, which may be shared between pools. When the budget is
+// exhausted, operations fail with their last error rather than being retried.
+func WithRetryBudget(budget *rate.Limiter) RetryPoolOption {
+	return func(p *RetryPool) { p.retryBudget = budget }
+}
+
+// WithSlowQueryThreshold logs, at warn level, the SQL and duration of each
+// query run through ExecFunc, QueryFunc or QueryRowFunc, and the duration of
+// each transaction, that takes longer than the threshold. A zero threshold
+// disables logging.
+func WithSlowQueryThreshold(threshold time.Duration) RetryPoolOption {
+	return func(p *RetryPool) { p.slowQueryThreshold = threshold }
+}
+
+// WithRetryLogSampling logs only one in every n of the pool's retry events,
+// such as retryable errors and operations that succeeded after being retried,
+// rather than every event, so that contention does not flood the logs. An n of
+// zero or one logs every event.
+func WithRetryLogSampling(n uint64) RetryPoolOption {
+	return func(p *RetryPool) { p.retryLogSampleRate = n }
+}
+
+// retryLog returns an info level event for logging a retry event, or nil,
+// which discards the event, if it is not sampled.
+func (p *RetryPool) retryLog(ctx context.Context) *zerolog.Event {
+	if p.retryLogSampleRate > 1 && (p.retryLogEvents.Add(1)-1)%p.retryLogSampleRate != 0 {
+		return nil
+	}
+	return log.Ctx(ctx).Info()
+}
+
+// WithoutMetrics stops the pool, and the NodeConnectionBalancer of the pool,
+// from updating their Prometheus metrics, removing their overhead when
+// benchmarking.
+func WithoutMetrics() RetryPoolOption {
+	return func(p *RetryPool) { p.metricsDisabled = true }
+}
+
+// WithRefillMode sets how quickly the pool opens the connections it is
+// missing. Defaults to RefillEager.
+func WithRefillMode(mode RefillMode) RetryPoolOption {
+	return func(p *RetryPool) { p.refillMode = mode }
+}
+
+// WithMinIdleConns makes the pool keep at least conns of its connections
+// idle, opening new connections, up to the pool's maximum size, whenever fewer
+// are, so that a burst of operations does not wait for connections to be
+// opened. The idle connections are replenished when the pool is created and
+// then at each of its health check periods, as pgx does for its minimum size.
+func WithMinIdleConns(conns int32) RetryPoolOption {
+	return func(p *RetryPool) { p.minIdleConns = conns }
+}
+
+// WithFairAcquisition makes the pool serve operations in the order in which
+// they began waiting for a connection. Each ExecFunc, QueryFunc, QueryRowFunc
+// or transaction waits its turn in a FIFO queue with one slot per connection,
+// and keeps its slot across retries, so that an operation is never overtaken
+// by ones that arrived after it, including by operations that are retried or
+// that have their connection rejected by the pool.
+func WithFairAcquisition() RetryPoolOption {
+	return func(p *RetryPool) { p.fairAcquisition = true }
+}
+
+func NewRetryPool(ctx context.Context, name string, config *pgxpool.Config, healthTracker *NodeHealthTracker, maxRetries uint8, connectRate time.Duration, opts ...RetryPoolOption) (*RetryPool, error) {
 	config = config.Copy()
 	p := &RetryPool{
 		id:            name,
@@ -53,8 +227,34 @@ func NewRetryPool(ctx context.Context, name string, config *pgxpool.Config, heal
 		nodeForConn:   make(map[*pgx.Conn]uint32, 0),
 		gc:            make(map[*pgx.Conn]struct{}, 0),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.metrics != nil {
+		recorded := newPoolMetrics(p.metrics)
+		p.poolMetrics = &recorded
+	}
+	if p.fairAcquisition {
+		p.fairQueue = semaphore.NewWeighted(int64(config.MaxConns))
+	}
+	if p.deadlineStatementTimeouts {
+		config.ConnConfig.BuildContextWatcherHandler = cancelRequestContextWatcher
+	}
 
 	limiter := rate.NewLimiter(rate.Every(connectRate), 1)
+	if p.refillMode == RefillGradual {
+		beforeConnect := config.BeforeConnect
+		config.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			if beforeConnect != nil {
+				return beforeConnect(ctx, connConfig)
+			}
+			return nil
+		}
+	}
+
 	afterConnect := config.AfterConnect
 	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
 		if afterConnect != nil {
@@ -64,19 +264,23 @@ func NewRetryPool(ctx context.Context, name string, config *pgxpool.Config, heal
 		}
 
 		p.Lock()
-		defer p.Unlock()
-
 		delete(p.nodeForConn, conn)
 		delete(p.gc, conn)
 
 		healthTracker.SetNodeHealth(nodeID(conn), true)
 
-		if err := limiter.Wait(ctx); err != nil {
-			return err
+		if p.refillMode == RefillEager {
+			if err := limiter.Wait(ctx); err != nil {
+				p.Unlock()
+				return err
+			}
 		}
 
-		p.nodeForConn[conn] = nodeID(conn)
+		id := nodeID(conn)
+		p.nodeForConn[conn] = id
+		p.Unlock()
 
+		p.notifyConnect(conn, id)
 		return nil
 	}
 
@@ -122,9 +326,15 @@ func NewRetryPool(ctx context.Context, name string, config *pgxpool.Config, heal
 			beforeClose(conn)
 		}
 		p.Lock()
-		defer p.Unlock()
+		id, ok := p.nodeForConn[conn]
+		if !ok {
+			id = nodeID(conn)
+		}
 		delete(p.nodeForConn, conn)
 		delete(p.gc, conn)
+		p.Unlock()
+
+		p.notifyDisconnect(conn, id)
 	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
@@ -132,9 +342,70 @@ func NewRetryPool(ctx context.Context, name string, config *pgxpool.Config, heal
 		return nil, err
 	}
 	p.pool = pool
+
+	if p.minIdleConns > 0 {
+		var idleCtx context.Context
+		idleCtx, p.stopIdle = context.WithCancel(ctx)
+		p.idleDone = make(chan struct{})
+		go p.maintainIdle(idleCtx, config.HealthCheckPeriod)
+	}
 	return p, nil
 }
 
+// maintainIdle opens connections whenever fewer than minIdleConns of the
+// pool's connections are idle, checking immediately and then every interval,
+// until the context is canceled.
+func (p *RetryPool) maintainIdle(ctx context.Context, interval time.Duration) {
+	defer close(p.idleDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.fillIdle(ctx, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fillIdle opens as many connections as are needed for minIdleConns of the
+// pool's connections to be idle, without exceeding the pool's maximum size,
+// giving up after timeout. The idle connections are held while the new ones
+// are acquired, so that acquiring opens new connections rather than returning
+// the idle ones, and all of them are then released back to the pool.
+func (p *RetryPool) fillIdle(ctx context.Context, timeout time.Duration) {
+	stat := p.pool.Stat()
+	missing := min(p.minIdleConns-stat.IdleConns(), stat.MaxConns()-stat.TotalConns())
+	if missing <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	held := p.pool.AcquireAllIdle(ctx)
+	defer func() {
+		for _, conn := range held {
+			conn.Release()
+		}
+	}()
+
+	for range missing {
+		conn, err := p.pool.Acquire(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Ctx(ctx).Warn().Err(err).Str("pool", p.id).Msg("unable to open idle connection")
+			}
+			return
+		}
+		held = append(held, conn)
+	}
+}
+
 // ID returns a string identifier for this pool for use in metrics and logs.
 func (p *RetryPool) ID() string {
 	return p.id
@@ -157,30 +428,39 @@ func (p *RetryPool) MinConns() uint32 {
 // ExecFunc is a replacement for pgxpool.Pool.Exec that allows resetting the
 // connection on error, or retrying on a retryable error.
 func (p *RetryPool) ExecFunc(ctx context.Context, tagFunc func(ctx context.Context, tag pgconn.CommandTag, err error) error, sql string, arguments ...any) error {
-	return p.withRetries(ctx, func(conn *pgxpool.Conn) error {
-		tag, err := conn.Conn().Exec(ctx, sql, arguments...)
-		return tagFunc(ctx, tag, err)
+	return p.withRetries(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		defer p.recordDuration(ctx, sql, time.Now())
+		return p.withSimpleProtocolFallback(ctx, sql, arguments, func(arguments []any) error {
+			tag, err := conn.Conn().Exec(ctx, p.label(ctx, sql), arguments...)
+			return tagFunc(ctx, tag, err)
+		})
 	})
 }
 
 // QueryFunc is a replacement for pgxpool.Pool.Query that allows resetting the
 // connection on error, or retrying on a retryable error.
 func (p *RetryPool) QueryFunc(ctx context.Context, rowsFunc func(ctx context.Context, rows pgx.Rows) error, sql string, optionsAndArgs ...any) error {
-	return p.withRetries(ctx, func(conn *pgxpool.Conn) error {
-		rows, err := conn.Conn().Query(ctx, sql, optionsAndArgs...)
-		if err != nil {
-			return err
-		}
-		defer rows.Close()
-		return rowsFunc(ctx, rows)
+	return p.withRetries(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		defer p.recordDuration(ctx, sql, time.Now())
+		return p.withSimpleProtocolFallback(ctx, sql, optionsAndArgs, func(optionsAndArgs []any) error {
+			rows, err := conn.Conn().Query(ctx, p.label(ctx, sql), optionsAndArgs...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			return rowsFunc(ctx, rows)
+		})
 	})
 }
 
 // QueryRowFunc is a replacement for pgxpool.Pool.QueryRow that allows resetting
 // the connection on error, or retrying on a retryable error.
 func (p *RetryPool) QueryRowFunc(ctx context.Context, rowFunc func(ctx context.Context, row pgx.Row) error, sql string, optionsAndArgs ...any) error {
-	return p.withRetries(ctx, func(conn *pgxpool.Conn) error {
-		return rowFunc(ctx, conn.Conn().QueryRow(ctx, sql, optionsAndArgs...))
+	return p.withRetries(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		defer p.recordDuration(ctx, sql, time.Now())
+		return p.withSimpleProtocolFallback(ctx, sql, optionsAndArgs, func(optionsAndArgs []any) error {
+			return rowFunc(ctx, conn.Conn().QueryRow(ctx, p.label(ctx, sql), optionsAndArgs...))
+		})
 	})
 }
 
@@ -193,16 +473,61 @@ func (p *RetryPool) BeginFunc(ctx context.Context, txFunc func(pgx.Tx) error) er
 // BeginTxFunc is a replacement for  pgxpool.BeginTxFunc that allows resetting
 // the connection on error, or retrying on a retryable error.
 func (p *RetryPool) BeginTxFunc(ctx context.Context, txOptions pgx.TxOptions, txFunc func(pgx.Tx) error) error {
-	return p.withRetries(ctx, func(conn *pgxpool.Conn) error {
+	return p.withRetries(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		defer p.recordDuration(ctx, "", time.Now())
 		tx, err := conn.BeginTx(ctx, txOptions)
 		if err != nil {
 			return err
 		}
+		if err := p.setLocalStatementTimeout(ctx, tx); err != nil {
+			_ = tx.Rollback(ctx)
+			return err
+		}
+		if p.statementLabels {
+			tx = labelingTx{tx}
+		}
 
 		return beginFuncExec(ctx, tx, txFunc)
 	})
 }
 
+// recordDuration records the duration of the SQL, or of the transaction if sql
+// is empty, labeled with the statement label of the context, and logs it if it
+// has been slow.
+func (p *RetryPool) recordDuration(ctx context.Context, sql string, started time.Time) {
+	if !p.metricsDisabled {
+		operation := datastore.StatementLabel(ctx)
+		if operation == "" {
+			operation = unlabeledOperation
+		}
+		p.recordedMetrics().statementDuration.Observe(time.Since(started).Seconds(), p.id, operation)
+	}
+	p.logIfSlow(ctx, sql, started)
+}
+
+// logIfSlow logs the SQL, or the transaction if sql is empty, if it has been
+// running since started for longer than the slow query threshold.
+func (p *RetryPool) logIfSlow(ctx context.Context, sql string, started time.Time) {
+	if p.slowQueryThreshold <= 0 {
+		return
+	}
+
+	duration := time.Since(started)
+	if duration <= p.slowQueryThreshold {
+		return
+	}
+
+	event := log.Ctx(ctx).Warn().
+		Str("pool", p.id).
+		Dur("duration", duration).
+		Dur("threshold", p.slowQueryThreshold)
+	if sql == "" {
+		event.Msg("slow datastore transaction")
+		return
+	}
+	event.Str("sql", sql).Msg("slow datastore query")
+}
+
 // AcquireAllIdle returns all idle connections from the underlying pgxpool.Pool
 func (p *RetryPool) AcquireAllIdle(ctx context.Context) []*pgxpool.Conn {
 	return p.pool.AcquireAllIdle(ctx)
@@ -213,8 +538,13 @@ func (p *RetryPool) Config() *pgxpool.Config {
 	return p.pool.Config()
 }
 
-// Close closes the underlying pgxpool.Pool
+// Close stops keeping connections idle, if WithMinIdleConns was given, and
+// closes the underlying pgxpool.Pool
 func (p *RetryPool) Close() {
+	if p.stopIdle != nil {
+		p.stopIdle()
+		<-p.idleDone
+	}
 	p.pool.Close()
 }
 
@@ -244,14 +574,23 @@ func (p *RetryPool) Range(f func(conn *pgx.Conn, nodeID uint32)) {
 }
 
 // withRetries acquires a connection and attempts the request multiple times
-func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
-	conn, err := p.pool.Acquire(ctx)
+func (p *RetryPool) withRetries(ctx context.Context, fn func(ctx context.Context, conn *pgxpool.Conn) error) error {
+	if p.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.queryTimeout)
+		defer cancel()
+	}
+
+	conn, err := p.acquire(ctx)
 	if err != nil {
 		if conn != nil {
 			conn.Release()
 		}
 		return fmt.Errorf("error acquiring connection from pool: %w", err)
 	}
+	if p.fairQueue != nil {
+		defer p.fairQueue.Release(1)
+	}
 	defer func() {
 		if conn != nil {
 			conn.Release()
@@ -260,7 +599,9 @@ func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn)
 
 	var retries uint8
 	defer func() {
-		resetHistogram.Observe(float64(retries))
+		if !p.metricsDisabled {
+			p.recordedMetrics().resets.Observe(float64(retries))
+		}
 	}()
 
 	maxRetries := p.maxRetries
@@ -269,11 +610,11 @@ func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn)
 	}
 
 	for retries = uint8(0); retries <= maxRetries; retries++ {
-		err = wrapRetryableError(ctx, fn(conn))
+		err = wrapRetryableError(ctx, fn(ctx, conn))
 		if err == nil {
 			conn.Release()
 			if retries > 0 {
-				log.Ctx(ctx).Info().Uint8("retries", retries).Msg("resettable database error succeeded after retry")
+				p.retryLog(ctx).Uint8("retries", retries).Msg("resettable database error succeeded after retry")
 			}
 			return nil
 		}
@@ -283,7 +624,7 @@ func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn)
 			retryable  *RetryableError
 		)
 		if errors.As(err, &resettable) || conn.Conn().IsClosed() {
-			log.Ctx(ctx).Info().Err(err).Uint8("retries", retries).Msg("resettable error")
+			p.retryLog(ctx).Err(err).Uint8("retries", retries).Msg("resettable error")
 
 			nodeID := p.Node(conn.Conn())
 			p.GC(conn.Conn())
@@ -296,6 +637,10 @@ func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn)
 				p.healthTracker.SetNodeHealth(nodeID, false)
 			}
 
+			if retries < maxRetries && p.retryBudgetExhausted(ctx) {
+				return fmt.Errorf("retry budget exhausted after %d retries: %w", retries, err)
+			}
+
 			common.SleepOnErr(ctx, err, retries)
 
 			conn, err = p.acquireFromDifferentNode(ctx, nodeID)
@@ -305,7 +650,11 @@ func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn)
 			continue
 		}
 		if errors.As(err, &retryable) {
-			log.Ctx(ctx).Info().Err(err).Uint8("retries", retries).Msg("retryable error")
+			p.retryLog(ctx).Err(err).Uint8("retries", retries).Msg("retryable error")
+			if retries < maxRetries && p.retryBudgetExhausted(ctx) {
+				conn.Release()
+				return fmt.Errorf("retry budget exhausted after %d retries: %w", retries, err)
+			}
 			common.SleepOnErr(ctx, err, retries)
 			continue
 		}
@@ -317,6 +666,58 @@ func (p *RetryPool) withRetries(ctx context.Context, fn func(conn *pgxpool.Conn)
 	return &MaxRetryError{MaxRetries: maxRetries, LastErr: err}
 }
 
+// retryBudgetExhausted consumes a retry from the pool's retry budget, if any,
+// returning true if none remain.
+func (p *RetryPool) retryBudgetExhausted(ctx context.Context) bool {
+	if p.retryBudget == nil || p.retryBudget.Allow() {
+		return false
+	}
+
+	if !p.metricsDisabled {
+		p.recordedMetrics().retryBudgetExhausted.Add(1, p.id)
+	}
+	log.Ctx(ctx).Warn().Str("pool", p.id).Msg("retry budget exhausted, not retrying")
+	return true
+}
+
+// acquire acquires a connection from the pool, failing immediately if the
+// pool is saturated and the maximum acquire queue depth has been exceeded.
+// With fair acquisition, it first waits its turn in the fair queue; the
+// caller must release the turn once it has finished with the connection.
+func (p *RetryPool) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	waiting := p.acquiring.Add(1)
+	defer p.acquiring.Add(-1)
+
+	if p.maxAcquireQueueDepth > 0 && waiting > p.maxAcquireQueueDepth {
+		stat := p.pool.Stat()
+		if stat.AcquiredConns() >= stat.MaxConns() {
+			if !p.metricsDisabled {
+				p.recordedMetrics().overloaded.Add(1, p.id)
+			}
+			return nil, datastore.NewOverloadedErr()
+		}
+	}
+
+	if p.fairQueue == nil {
+		conn, err := p.pool.Acquire(ctx)
+		if err != nil {
+			p.notifyConnectError(ctx, err)
+		}
+		return conn, err
+	}
+
+	if err := p.fairQueue.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		p.fairQueue.Release(1)
+		p.notifyConnectError(ctx, err)
+		return nil, err
+	}
+	return conn, nil
+}
+
 // GC marks a connection for destruction on the next Acquire.
 // BeforeAcquire can signal to the pool to close the connection and clean up
 // the reference in the pool at the same time, so we lazily GC connections
@@ -361,7 +762,7 @@ func IsRetryableError(ctx context.Context, err error) bool {
 
 	// Retryable errors: the transaction should be retried but no new connection
 	// is needed.
-	if sqlState == CrdbRetryErrCode ||
+	if IsSerializationFailure(err) ||
 		// Error encountered when crdb nodes have large clock skew
 		(sqlState == CrdbUnknownSQLState && strings.Contains(err.Error(), CrdbClockSkewMessage)) {
 		return true
@@ -388,9 +789,9 @@ func IsResettableError(ctx context.Context, err error) bool {
 
 	// Ambiguous result error includes connection closed errors
 	// https://www.cockroachlabs.com/docs/stable/common-errors.html#result-is-ambiguous
-	if sqlState == CrdbAmbiguousErrorCode ||
+	if IsAmbiguousResult(err) ||
 		// Reset on node draining
-		sqlState == CrdbServerNotAcceptingClients {
+		IsServerNotAcceptingClients(err) {
 		return true
 	}
 
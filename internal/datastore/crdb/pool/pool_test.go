@@ -0,0 +1,287 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	promclient "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	ctx := context.Background()
+
+	unlimited := &RetryPool{id: "unlimited"}
+	for i := 0; i < 10; i++ {
+		require.False(t, unlimited.retryBudgetExhausted(ctx))
+	}
+
+	// Pools sharing a budget draw from the same bucket.
+	budget := rate.NewLimiter(0, 2)
+	read := &RetryPool{id: "read", retryBudget: budget}
+	write := &RetryPool{id: "write", retryBudget: budget}
+	require.False(t, read.retryBudgetExhausted(ctx))
+	require.False(t, write.retryBudgetExhausted(ctx))
+	require.True(t, read.retryBudgetExhausted(ctx))
+	require.True(t, write.retryBudgetExhausted(ctx))
+}
+
+func TestLogIfSlow(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	ctx := logger.WithContext(context.Background())
+
+	disabled := &RetryPool{id: "read"}
+	disabled.logIfSlow(ctx, "SELECT 1", time.Now().Add(-1*time.Hour))
+	require.Empty(t, buf.String())
+
+	p := &RetryPool{id: "read", slowQueryThreshold: 100 * time.Millisecond}
+	p.logIfSlow(ctx, "SELECT 1", time.Now())
+	require.Empty(t, buf.String())
+
+	p.logIfSlow(ctx, "SELECT 2", time.Now().Add(-1*time.Second))
+	require.Contains(t, buf.String(), `"level":"warn"`)
+	require.Contains(t, buf.String(), `"sql":"SELECT 2"`)
+	require.Contains(t, buf.String(), "slow datastore query")
+
+	buf.Reset()
+	p.logIfSlow(ctx, "", time.Now().Add(-1*time.Second))
+	require.Contains(t, buf.String(), "slow datastore transaction")
+}
+
+func TestRetryLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	ctx := logger.WithContext(context.Background())
+
+	countLogged := func(p *RetryPool, events int) int {
+		buf.Reset()
+		for range events {
+			p.retryLog(ctx).Msg("retryable error")
+		}
+		return bytes.Count(buf.Bytes(), []byte("retryable error"))
+	}
+
+	require.Equal(t, 10, countLogged(&RetryPool{id: "read"}, 10))
+	require.Equal(t, 10, countLogged(&RetryPool{id: "read", retryLogSampleRate: 1}, 10))
+	require.Equal(t, 4, countLogged(&RetryPool{id: "read", retryLogSampleRate: 3}, 10))
+}
+
+func TestFairAcquisition(t *testing.T) {
+	ctx := context.Background()
+
+	config, err := pgxpool.ParseConfig("postgres://user@localhost:26257/db?pool_max_conns=2")
+	require.NoError(t, err)
+
+	healthTracker, err := NewNodeHealthChecker("")
+	require.NoError(t, err)
+
+	unfair, err := NewRetryPool(ctx, "unfair", config, healthTracker, 0, time.Millisecond)
+	require.NoError(t, err)
+	defer unfair.Close()
+	require.Nil(t, unfair.fairQueue)
+
+	fair, err := NewRetryPool(ctx, "fair", config, healthTracker, 0, time.Millisecond, WithFairAcquisition())
+	require.NoError(t, err)
+	defer fair.Close()
+
+	// The queue has one slot per connection.
+	require.True(t, fair.fairQueue.TryAcquire(2))
+	require.False(t, fair.fairQueue.TryAcquire(1))
+
+	// An acquisition waiting its turn gives up when its context is done,
+	// without consuming a slot.
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = fair.acquire(waitCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	fair.fairQueue.Release(2)
+	require.True(t, fair.fairQueue.TryAcquire(2))
+}
+
+func TestWithoutMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	for _, disabled := range []bool{false, true} {
+		id := "metrics-enabled"
+		var opts []RetryPoolOption
+		if disabled {
+			id = "metrics-disabled"
+			opts = append(opts, WithoutMetrics())
+		}
+
+		p := &RetryPool{id: id, retryBudget: rate.NewLimiter(0, 0)}
+		for _, opt := range opts {
+			opt(p)
+		}
+		require.True(t, p.retryBudgetExhausted(ctx))
+
+		expected := 1.0
+		if disabled {
+			expected = 0
+		}
+		var metric promclient.Metric
+		require.NoError(t, prometheusPoolMetrics.retryBudgetExhausted.(prometheusCounter).vec.WithLabelValues(id).Write(&metric))
+		require.Equal(t, expected, metric.GetCounter().GetValue())
+	}
+}
+
+func TestRefillMode(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		mode          RefillMode
+		expectedError string
+	}{
+		// Only the initialization of a connection is throttled, so the
+		// second dial is attempted, and refused, immediately.
+		{"eager", RefillEager, "connect"},
+
+		// The second dial waits for the connect rate.
+		{"gradual", RefillGradual, "context deadline exceeded"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := pgxpool.ParseConfig("postgres://root@127.0.0.1:1/defaultdb?connect_timeout=1")
+			require.NoError(t, err)
+			config.MinConns = 0
+
+			p, err := NewRetryPool(context.Background(), "refill", config, nil, 0, time.Hour, WithRefillMode(tc.mode))
+			require.NoError(t, err)
+			defer p.Close()
+
+			_, err = p.pool.Acquire(context.Background())
+			require.Error(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_, err = p.pool.Acquire(ctx)
+			require.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+// serveFakeConns completes the startup of each connection accepted on the
+// listener, and then answers each of its queries as completed until the
+// client terminates it, until the listener is closed.
+func serveFakeConns(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			backend := pgproto3.NewBackend(conn, conn)
+			if _, err := backend.ReceiveStartupMessage(); err != nil {
+				return
+			}
+			backend.Send(&pgproto3.AuthenticationOk{})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if err := backend.Flush(); err != nil {
+				return
+			}
+
+			for {
+				msg, err := backend.Receive()
+				if err != nil {
+					return
+				}
+				switch msg.(type) {
+				case *pgproto3.Query:
+					backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("OK")})
+					backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+					if err := backend.Flush(); err != nil {
+						return
+					}
+				case *pgproto3.Terminate:
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestMinIdleConns(t *testing.T) {
+	ctx := context.Background()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go serveFakeConns(listener)
+
+	config, err := pgxpool.ParseConfig(fmt.Sprintf("postgres://root@%s/defaultdb?sslmode=disable&pool_max_conns=4&pool_min_conns=0&pool_health_check_period=10ms", listener.Addr()))
+	require.NoError(t, err)
+
+	healthTracker, err := NewNodeHealthChecker("")
+	require.NoError(t, err)
+
+	p, err := NewRetryPool(ctx, "idle", config, healthTracker, 0, time.Millisecond, WithMinIdleConns(2))
+	require.NoError(t, err)
+	defer p.Close()
+
+	idleAndTotal := func(idle, total int32) func() bool {
+		return func() bool {
+			stat := p.Stat()
+			return stat.IdleConns() == idle && stat.TotalConns() == total
+		}
+	}
+
+	// The idle connections are opened when the pool is created.
+	require.Eventually(t, idleAndTotal(2, 2), 5*time.Second, 10*time.Millisecond)
+
+	// Acquiring an idle connection opens another in its place.
+	first, err := p.pool.Acquire(ctx)
+	require.NoError(t, err)
+	defer first.Release()
+	require.Eventually(t, idleAndTotal(2, 3), 5*time.Second, 10*time.Millisecond)
+
+	// Connections beyond the maximum size of the pool are not opened.
+	second, err := p.pool.Acquire(ctx)
+	require.NoError(t, err)
+	defer second.Release()
+	third, err := p.pool.Acquire(ctx)
+	require.NoError(t, err)
+	defer third.Release()
+	require.Eventually(t, idleAndTotal(1, 4), 5*time.Second, 10*time.Millisecond)
+}
+
+func TestQueryTimeoutBoundsTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go serveFakeConns(listener)
+
+	config, err := pgxpool.ParseConfig(fmt.Sprintf("postgres://root@%s/defaultdb?sslmode=disable&pool_max_conns=1&pool_min_conns=0", listener.Addr()))
+	require.NoError(t, err)
+
+	healthTracker, err := NewNodeHealthChecker("")
+	require.NoError(t, err)
+
+	p, err := NewRetryPool(ctx, "timeout", config, healthTracker, 0, time.Millisecond, WithQueryTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+	defer p.Close()
+
+	// A transaction whose function finishes within the timeout commits.
+	require.NoError(t, p.BeginTxFunc(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error { return nil }))
+
+	// A transaction whose function outlasts the timeout is cancelled, even
+	// though its begin and commit are quick.
+	err = p.BeginTxFunc(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
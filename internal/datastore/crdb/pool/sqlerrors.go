@@ -22,6 +22,13 @@ const (
 	CrdbUnknownSQLState = "XXUUU"
 	// Error message encountered when crdb nodes have large clock skew
 	CrdbClockSkewMessage = "cannot specify timestamp in the future"
+
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	sqlStateUndefinedTable        = "42P01"
+	sqlStateUndefinedFunction     = "42883"
+	sqlStateUniqueViolation       = "23505"
+	sqlStateInvalidParameterValue = "22023"
+	sqlStateInvalidStatementName  = "26000"
 )
 
 // MaxRetryError is returned when the retry budget is exhausted.
@@ -87,3 +94,54 @@ func sqlErrorCode(err error) string {
 
 	return pgerr.SQLState()
 }
+
+// IsMissingTable returns whether the error, or an error it wraps, reports that
+// a table referenced by the statement does not exist.
+func IsMissingTable(err error) bool {
+	return sqlErrorCode(err) == sqlStateUndefinedTable
+}
+
+// IsMissingFunction returns whether the error, or an error it wraps, reports
+// that a function called by the statement does not exist.
+func IsMissingFunction(err error) bool {
+	return sqlErrorCode(err) == sqlStateUndefinedFunction
+}
+
+// IsSerializationFailure returns whether the error, or an error it wraps, is a
+// serialization failure, after which the transaction must be restarted.
+func IsSerializationFailure(err error) bool {
+	return sqlErrorCode(err) == CrdbRetryErrCode
+}
+
+// IsAmbiguousResult returns whether the error, or an error it wraps, reports
+// that it is unknown whether the statement was committed, such as when the
+// connection was lost during the commit.
+func IsAmbiguousResult(err error) bool {
+	return sqlErrorCode(err) == CrdbAmbiguousErrorCode
+}
+
+// IsServerNotAcceptingClients returns whether the error, or an error it wraps,
+// reports that the node is draining and not accepting clients.
+func IsServerNotAcceptingClients(err error) bool {
+	return sqlErrorCode(err) == CrdbServerNotAcceptingClients
+}
+
+// IsUniqueViolation returns whether the error, or an error it wraps, reports
+// a violation of a unique constraint.
+func IsUniqueViolation(err error) bool {
+	return sqlErrorCode(err) == sqlStateUniqueViolation
+}
+
+// IsInvalidParameterValue returns whether the error, or an error it wraps,
+// reports that a parameter of the statement was given an invalid value.
+func IsInvalidParameterValue(err error) bool {
+	return sqlErrorCode(err) == sqlStateInvalidParameterValue
+}
+
+// IsPreparedStatementMissing returns whether the error, or an error it wraps,
+// reports that a prepared statement used by the statement does not exist, as
+// happens when a pooler in transaction mode runs it on a different server
+// connection than the one it was prepared on.
+func IsPreparedStatementMissing(err error) bool {
+	return sqlErrorCode(err) == sqlStateInvalidStatementName
+}
@@ -0,0 +1,52 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStatePredicates(t *testing.T) {
+	predicates := map[string]func(error) bool{
+		"IsMissingTable":              IsMissingTable,
+		"IsMissingFunction":           IsMissingFunction,
+		"IsSerializationFailure":      IsSerializationFailure,
+		"IsAmbiguousResult":           IsAmbiguousResult,
+		"IsServerNotAcceptingClients": IsServerNotAcceptingClients,
+		"IsUniqueViolation":           IsUniqueViolation,
+		"IsInvalidParameterValue":     IsInvalidParameterValue,
+		"IsPreparedStatementMissing":  IsPreparedStatementMissing,
+	}
+
+	codes := map[string]string{
+		"IsMissingTable":              "42P01",
+		"IsMissingFunction":           "42883",
+		"IsSerializationFailure":      "40001",
+		"IsAmbiguousResult":           "40003",
+		"IsServerNotAcceptingClients": "57P01",
+		"IsUniqueViolation":           "23505",
+		"IsInvalidParameterValue":     "22023",
+		"IsPreparedStatementMissing":  "26000",
+	}
+
+	for name, predicate := range predicates {
+		t.Run(name, func(t *testing.T) {
+			for otherName, code := range codes {
+				pgErr := &pgconn.PgError{Code: code}
+				wrapped := fmt.Errorf("unable to run query: %w", pgErr)
+				doubleWrapped := &RetryableError{Err: wrapped}
+
+				expected := otherName == name
+				require.Equal(t, expected, predicate(pgErr), code)
+				require.Equal(t, expected, predicate(wrapped), code)
+				require.Equal(t, expected, predicate(doubleWrapped), code)
+			}
+
+			require.False(t, predicate(nil))
+			require.False(t, predicate(errors.New("42P01")))
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/require"
 
 	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
@@ -138,3 +140,48 @@ func TestTxReset(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteWithRetry(t *testing.T) {
+	b := testdatastore.RunCRDBForTesting(t, "")
+	ctx := context.Background()
+
+	conns, err := pgxpool.New(ctx, b.NewDatabase(t))
+	require.NoError(t, err)
+	t.Cleanup(conns.Close)
+
+	t.Run("retries serialization failures", func(t *testing.T) {
+		attempts := 0
+		err := ExecuteWithRetry(ctx, conns, 3, func(ctx context.Context, tx pgx.Tx) error {
+			attempts++
+			if attempts <= 2 {
+				return &pgconn.PgError{Code: pool.CrdbRetryErrCode}
+			}
+			_, err := tx.Exec(ctx, "SELECT 1")
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("exhausts retries", func(t *testing.T) {
+		attempts := 0
+		err := ExecuteWithRetry(ctx, conns, 2, func(ctx context.Context, tx pgx.Tx) error {
+			attempts++
+			return &pgconn.PgError{Code: pool.CrdbRetryErrCode}
+		})
+		var maxRetryErr *pool.MaxRetryError
+		require.ErrorAs(t, err, &maxRetryErr)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		attempts := 0
+		expected := errors.New("not retryable")
+		err := ExecuteWithRetry(ctx, conns, 3, func(ctx context.Context, tx pgx.Tx) error {
+			attempts++
+			return expected
+		})
+		require.ErrorIs(t, err, expected)
+		require.Equal(t, 1, attempts)
+	})
+}
@@ -0,0 +1,57 @@
+package crdb
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolStats is a snapshot of the state of the datastore's connection pools,
+// as returned by PoolStats.
+type PoolStats struct {
+	// Read and Write are the statistics of the read and write pools. A read
+	// replica, which has no write pool, reports a zero Write.
+	Read  PoolStat
+	Write PoolStat
+
+	// LastNodeHealthCheck is when the node health poller last checked the
+	// health of the cluster's nodes, every NodeHealthCheckInterval, or the
+	// zero time if it has not polled yet, or does not run because connection
+	// balancing is disabled. It does not reflect the health checks the pools
+	// run on their own connections.
+	LastNodeHealthCheck time.Time
+}
+
+// PoolStat is a snapshot of the connections of a connection pool.
+type PoolStat struct {
+	// Open is the number of open connections, whether idle, acquired or being
+	// established.
+	Open int32
+
+	// Idle is the number of open connections that are not in use.
+	Idle int32
+
+	// Acquired is the number of connections in use.
+	Acquired int32
+}
+
+// PoolStats returns the current statistics of the datastore's connection
+// pools, such as for export by a metrics collector.
+func (cds *crdbDatastore) PoolStats() PoolStats {
+	stats := PoolStats{
+		Read:                poolStatOf(cds.readPool.Stat()),
+		LastNodeHealthCheck: cds.healthChecker.LastPoll(),
+	}
+	if cds.writePool != nil {
+		stats.Write = poolStatOf(cds.writePool.Stat())
+	}
+	return stats
+}
+
+func poolStatOf(stat *pgxpool.Stat) PoolStat {
+	return PoolStat{
+		Open:     stat.TotalConns(),
+		Idle:     stat.IdleConns(),
+		Acquired: stat.AcquiredConns(),
+	}
+}
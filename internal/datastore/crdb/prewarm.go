@@ -0,0 +1,16 @@
+package crdb
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// prewarmRevisionCache populates the cached optimized revision, as
+// RefreshLatestRevision does, logging rather than returning a failure to do so;
+// see PrewarmRevisionCache.
+func (cds *crdbDatastore) prewarmRevisionCache(ctx context.Context, logger *zerolog.Logger) {
+	if _, err := cds.RefreshLatestRevision(ctx); err != nil {
+		logger.Warn().Err(err).Msg("unable to prewarm the revision cache")
+	}
+}
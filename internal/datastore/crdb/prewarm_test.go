@@ -0,0 +1,75 @@
+package crdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestPrewarmRevisionCache(t *testing.T) {
+	errUnavailable := errors.New("cluster unavailable")
+
+	testCases := []struct {
+		name            string
+		nowErr          error
+		closed          bool
+		expectedWarning string
+		expectedCached  bool
+	}{
+		{"populates the cache", nil, false, "", true},
+		{"revision query failed", errUnavailable, false, errUnavailable.Error(), false},
+		{"datastore closed", nil, true, datastore.ErrDatastoreClosed.Error(), false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			now := revisions.NewHLCForTime(time.Now())
+			nowCalls := 0
+
+			ds := &crdbDatastore{
+				RemoteClockRevisions: revisions.NewRemoteClockRevisions(24*time.Hour, time.Hour, 0, time.Hour),
+			}
+			ds.RemoteClockRevisions.SetNowFunc(func(context.Context) (datastore.Revision, error) {
+				nowCalls++
+				if tc.nowErr != nil {
+					return datastore.NoRevision, tc.nowErr
+				}
+				return now, nil
+			})
+			ds.closed.Store(tc.closed)
+
+			var buf bytes.Buffer
+			logger := zerolog.New(&buf)
+
+			// A failure to prewarm the cache is only logged.
+			ds.prewarmRevisionCache(context.Background(), &logger)
+			if tc.expectedWarning == "" {
+				require.Empty(t, buf.String())
+			} else {
+				require.Contains(t, buf.String(), "unable to prewarm the revision cache")
+				require.Contains(t, buf.String(), tc.expectedWarning)
+			}
+
+			if !tc.expectedCached {
+				return
+			}
+
+			// The first read after the cache is prewarmed does not query the
+			// revision again.
+			require.Equal(t, 1, nowCalls)
+			revision, err := ds.OptimizedRevision(context.Background())
+			require.NoError(t, err)
+			require.True(t, revision.Equal(now))
+			require.Equal(t, 1, nowCalls)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ctxWriteTransactionPriority struct{}
+
+// WithWriteTransactionPriority returns a context in which the write
+// transactions of the datastore run with the given priority, one of
+// TransactionPriorityLow, TransactionPriorityNormal or
+// TransactionPriorityHigh, in place of the one set by
+// WriteTransactionPriority.
+func WithWriteTransactionPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, ctxWriteTransactionPriority{}, priority)
+}
+
+func isTransactionPriority(priority string) bool {
+	switch priority {
+	case TransactionPriorityLow, TransactionPriorityNormal, TransactionPriorityHigh:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeTransactionPriority returns the priority with which a write
+// transaction runs in the context.
+func (cds *crdbDatastore) writeTransactionPriority(ctx context.Context) (string, error) {
+	priority, ok := ctx.Value(ctxWriteTransactionPriority{}).(string)
+	if !ok {
+		return cds.writePriority, nil
+	}
+	if !isTransactionPriority(priority) {
+		return "", fmt.Errorf("unknown write transaction priority %q", priority)
+	}
+	return priority, nil
+}
+
+// writeTxOptions returns the options with which a write transaction of the
+// priority begins. A priority other than the default is set by the BEGIN
+// statement itself, such that it is in place before any other statement of the
+// transaction, such as one setting its statement timeout, runs.
+func writeTxOptions(priority string) pgx.TxOptions {
+	if priority == TransactionPriorityNormal {
+		return pgx.TxOptions{}
+	}
+	return pgx.TxOptions{BeginQuery: "BEGIN PRIORITY " + priority}
+}
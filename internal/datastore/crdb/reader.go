@@ -46,18 +46,19 @@ type crdbReader struct {
 	keyer                overlapKeyer
 	overlapKeySet        keySet
 	filterMaximumIDCount uint16
+	readPageSize         uint64
+	maxListResults       uint64
+	checkIndexHint       string
 	withIntegrity        bool
 	atSpecificRevision   string
 }
 
-const asOfSystemTime = "AS OF SYSTEM TIME"
-
 func (cr *crdbReader) addFromToQuery(query sq.SelectBuilder, tableName string) sq.SelectBuilder {
 	if cr.atSpecificRevision == "" {
 		return query.From(tableName)
 	}
 
-	return query.From(tableName + " " + asOfSystemTime + " " + cr.atSpecificRevision)
+	return query.From(tableName + " " + asOfSystemTimeClause(cr.atSpecificRevision))
 }
 
 func (cr *crdbReader) fromSuffix() string {
@@ -65,7 +66,7 @@ func (cr *crdbReader) fromSuffix() string {
 		return ""
 	}
 
-	return " " + asOfSystemTime + " " + cr.atSpecificRevision
+	return " " + asOfSystemTimeClause(cr.atSpecificRevision)
 }
 
 func (cr *crdbReader) assertHasExpectedAsOfSystemTime(sql string) {
@@ -220,7 +221,12 @@ func (cr *crdbReader) QueryRelationships(
 	filter datastore.RelationshipsFilter,
 	opts ...options.QueryOptionsOption,
 ) (iter datastore.RelationshipIterator, err error) {
-	qBuilder, err := common.NewSchemaQueryFiltererForRelationshipsSelect(cr.schema, cr.filterMaximumIDCount).WithFromSuffix(cr.fromSuffix()).FilterWithRelationshipsFilter(filter)
+	fromSuffix := cr.fromSuffix()
+	if cr.checkIndexHint != "" && datastore.StatementLabel(ctx) == datastore.StatementLabelCheck {
+		fromSuffix = "@" + cr.checkIndexHint + fromSuffix
+	}
+
+	qBuilder, err := common.NewSchemaQueryFiltererForRelationshipsSelect(cr.schema, cr.filterMaximumIDCount).WithFromSuffix(fromSuffix).FilterWithRelationshipsFilter(filter)
 	if err != nil {
 		return nil, err
 	}
@@ -229,7 +235,7 @@ func (cr *crdbReader) QueryRelationships(
 		opts = append(opts, options.WithSQLAssertion(cr.assertHasExpectedAsOfSystemTime))
 	}
 
-	return cr.executor.ExecuteQuery(ctx, qBuilder, opts...)
+	return executeLimited(ctx, cr.executor, qBuilder, cr.readPageSize, cr.maxListResults, opts...)
 }
 
 func (cr *crdbReader) ReverseQueryRelationships(
@@ -0,0 +1,72 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Status is the readiness of the datastore to serve requests, as reported by
+// ReadinessStatus. Its values match those of the grpc.health.v1 serving
+// status, so that a health server can report it unchanged.
+type Status int32
+
+const (
+	// StatusUnknown is never returned by ReadinessStatus; it is the zero value.
+	StatusUnknown Status = 0
+
+	// StatusServing is returned when the datastore is ready to serve requests.
+	StatusServing Status = 1
+
+	// StatusNotServing is returned when the datastore cannot serve requests.
+	StatusNotServing Status = 2
+)
+
+// ReadinessStatus consolidates the checks of the datastore's readiness into a
+// single verdict for health probes. The datastore is reported as not serving,
+// along with an error giving the reason, when:
+//   - it has been closed;
+//   - a connection to CockroachDB cannot be established;
+//   - the database is not migrated to the head revision expected by the
+//     datastore;
+//   - a pool holds fewer connections than its minimum, as reported by
+//     ReadyState; or
+//   - every connection of a pool is acquired up to its maximum, so that
+//     requests must wait for a connection to be released.
+//
+// Otherwise it is reported as serving, with no error.
+func (cds *crdbDatastore) ReadinessStatus(ctx context.Context) (Status, error) {
+	if err := cds.checkOpen(); err != nil {
+		return StatusNotServing, err
+	}
+
+	state, err := cds.ReadyState(ctx)
+	if err != nil {
+		return StatusNotServing, fmt.Errorf("unable to check the datastore readiness: %w", err)
+	}
+	if !state.IsReady {
+		return StatusNotServing, errors.New(state.Message)
+	}
+
+	if err := checkPoolNotExhausted("read", cds.readPool.Stat()); err != nil {
+		return StatusNotServing, err
+	}
+	if !cds.readReplica {
+		if err := checkPoolNotExhausted("write", cds.writePool.Stat()); err != nil {
+			return StatusNotServing, err
+		}
+	}
+
+	return StatusServing, nil
+}
+
+// checkPoolNotExhausted returns an error if every connection the pool may open
+// is acquired.
+func checkPoolNotExhausted(name string, stat *pgxpool.Stat) error {
+	if stat.MaxConns() > 0 && stat.AcquiredConns() >= stat.MaxConns() {
+		return fmt.Errorf("every connection of the %s pool is in use (%d/%d)", name, stat.AcquiredConns(), stat.MaxConns())
+	}
+	return nil
+}
@@ -49,57 +49,38 @@ type crdbReadWriteTXN struct {
 	*crdbReader
 	tx             pgx.Tx
 	relCountChange int64
+	writeBatchSize int
+
+	// duplicateWritePolicy is what happens when a mutation creates a
+	// relationship that already exists; see DuplicateWritePolicy.
+	duplicateWritePolicy string
+
+	// validateCaveats is set if the caveats of the relationships created or
+	// touched are checked against their definitions; see
+	// ValidateCaveatsOnWrite.
+	validateCaveats bool
+
+	// metadataColumns are the metadata columns set, from the context, for the
+	// relationships written.
+	metadataColumns []string
+
+	// writeCounts are the numbers of relationships changed by the mutations
+	// written in this transaction.
+	writeCounts WriteCounts
 }
 
 var (
-	upsertTupleSuffixWithoutIntegrity = fmt.Sprintf(
-		"ON CONFLICT (%s,%s,%s,%s,%s,%s) DO UPDATE SET %s = now(), %s = excluded.%s, %s = excluded.%s, %s = excluded.%s WHERE (relation_tuple.%s <> excluded.%s OR relation_tuple.%s <> excluded.%s OR relation_tuple.%s <> excluded.%s)",
-		colNamespace,
-		colObjectID,
-		colRelation,
-		colUsersetNamespace,
-		colUsersetObjectID,
-		colUsersetRelation,
-		colTimestamp,
-		colCaveatContextName,
-		colCaveatContextName,
-		colCaveatContext,
-		colCaveatContext,
-		colExpiration,
-		colExpiration,
-		colCaveatContextName,
-		colCaveatContextName,
-		colCaveatContext,
-		colCaveatContext,
-		colExpiration,
-		colExpiration,
-	)
+	upsertTupleSuffixWithoutIntegrity = upsertTupleSuffix(tableTuple, false, nil)
+	upsertTupleSuffixWithIntegrity    = upsertTupleSuffix(tableTupleWithIntegrity, true, nil)
 
-	upsertTupleSuffixWithIntegrity = fmt.Sprintf(
-		"ON CONFLICT (%s,%s,%s,%s,%s,%s) DO UPDATE SET %s = now(), %s = excluded.%s, %s = excluded.%s, %s = excluded.%s, %s = excluded.%s, %s = excluded.%s WHERE (relation_tuple_with_integrity.%s <> excluded.%s OR relation_tuple_with_integrity.%s <> excluded.%s OR relation_tuple_with_integrity.%s <> excluded.%s)",
+	ignoreTupleSuffix = fmt.Sprintf(
+		"ON CONFLICT (%s,%s,%s,%s,%s,%s) DO NOTHING",
 		colNamespace,
 		colObjectID,
 		colRelation,
 		colUsersetNamespace,
 		colUsersetObjectID,
 		colUsersetRelation,
-		colTimestamp,
-		colCaveatContextName,
-		colCaveatContextName,
-		colCaveatContext,
-		colCaveatContext,
-		colIntegrityKeyID,
-		colIntegrityKeyID,
-		colIntegrityHash,
-		colIntegrityHash,
-		colExpiration,
-		colExpiration,
-		colCaveatContextName,
-		colCaveatContextName,
-		colCaveatContext,
-		colCaveatContext,
-		colExpiration,
-		colExpiration,
 	)
 
 	queryTouchTransaction = fmt.Sprintf(
@@ -144,7 +125,7 @@ func (rwt *crdbReadWriteTXN) queryWriteTuple() sq.InsertBuilder {
 			colExpiration,
 			colIntegrityKeyID,
 			colIntegrityHash,
-		)
+		).Columns(rwt.metadataColumns...)
 	}
 
 	return rwt.insertQuery().Columns(
@@ -157,10 +138,14 @@ func (rwt *crdbReadWriteTXN) queryWriteTuple() sq.InsertBuilder {
 		colCaveatContextName,
 		colCaveatContext,
 		colExpiration,
-	)
+	).Columns(rwt.metadataColumns...)
 }
 
 func (rwt *crdbReadWriteTXN) queryTouchTuple() sq.InsertBuilder {
+	if len(rwt.metadataColumns) > 0 {
+		return rwt.queryWriteTuple().Suffix(upsertTupleSuffix(rwt.schema.RelationshipTableName, rwt.withIntegrity, rwt.metadataColumns))
+	}
+
 	if rwt.withIntegrity {
 		return rwt.queryWriteTuple().Suffix(upsertTupleSuffixWithIntegrity)
 	}
@@ -168,6 +153,20 @@ func (rwt *crdbReadWriteTXN) queryTouchTuple() sq.InsertBuilder {
 	return rwt.queryWriteTuple().Suffix(upsertTupleSuffixWithoutIntegrity)
 }
 
+// queryCreateTuple returns the query that inserts the relationships created by
+// mutations, whose conflicts with existing relationships are handled as the
+// duplicate write policy requires.
+func (rwt *crdbReadWriteTXN) queryCreateTuple() sq.InsertBuilder {
+	switch rwt.duplicateWritePolicy {
+	case DuplicateWriteIgnore:
+		return rwt.queryWriteTuple().Suffix(ignoreTupleSuffix)
+	case DuplicateWriteUpsert:
+		return rwt.queryTouchTuple()
+	default:
+		return rwt.queryWriteTuple()
+	}
+}
+
 func (rwt *crdbReadWriteTXN) RegisterCounter(ctx context.Context, name string, filter *core.RelationshipFilter) error {
 	counters, err := rwt.lookupCounters(ctx, name)
 	if err != nil {
@@ -265,16 +264,24 @@ func (rwt *crdbReadWriteTXN) StoreCounterValue(ctx context.Context, name string,
 }
 
 func (rwt *crdbReadWriteTXN) WriteRelationships(ctx context.Context, mutations []tuple.RelationshipUpdate) error {
-	bulkWrite := rwt.queryWriteTuple()
-	var bulkWriteCount int64
+	if rwt.validateCaveats {
+		if err := rwt.checkCaveatReferences(ctx, mutations); err != nil {
+			return err
+		}
+	}
 
-	bulkTouch := rwt.queryTouchTuple()
-	var bulkTouchCount int64
+	var bulkWriteValues, bulkTouchValues [][]any
+	var counts WriteCounts
 
 	bulkDelete := rwt.queryDeleteTuples()
 	bulkDeleteOr := sq.Or{}
 	var bulkDeleteCount int64
 
+	metadata, err := metadataValues(ctx, rwt.metadataColumns)
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteRelationships, err)
+	}
+
 	// Process the actual updates
 	for _, mutation := range mutations {
 		rel := mutation.Relationship
@@ -315,23 +322,19 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(ctx context.Context, mutations [
 		if rwt.withIntegrity {
 			values = append(values, integrityKeyID, integrityHash)
 		}
+		values = append(values, metadata...)
 
 		rwt.addOverlapKey(rel.Resource.ObjectType)
 		rwt.addOverlapKey(rel.Subject.ObjectType)
 
 		switch mutation.Operation {
 		case tuple.UpdateOperationTouch:
-			rwt.relCountChange++
-			bulkTouch = bulkTouch.Values(values...)
-			bulkTouchCount++
+			bulkTouchValues = append(bulkTouchValues, values)
 
 		case tuple.UpdateOperationCreate:
-			rwt.relCountChange++
-			bulkWrite = bulkWrite.Values(values...)
-			bulkWriteCount++
+			bulkWriteValues = append(bulkWriteValues, values)
 
 		case tuple.UpdateOperationDelete:
-			rwt.relCountChange--
 			bulkDeleteOr = append(bulkDeleteOr, exactRelationshipClause(rel))
 			bulkDeleteCount++
 
@@ -348,31 +351,137 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(ctx context.Context, mutations [
 			return fmt.Errorf(errUnableToWriteRelationships, err)
 		}
 
-		if _, err := rwt.tx.Exec(ctx, sql, args...); err != nil {
+		deleted, err := rwt.tx.Exec(ctx, sql, args...)
+		if err != nil {
 			return fmt.Errorf(errUnableToWriteRelationships, err)
 		}
+		rwt.relCountChange -= deleted.RowsAffected()
+		counts.Deleted, err = safecast.ToUint64(deleted.RowsAffected())
+		if err != nil {
+			return spiceerrors.MustBugf("could not cast RowsAffected to uint64: %v", err)
+		}
+	}
+
+	created, err := rwt.createInBatches(ctx, bulkWriteValues)
+	if err != nil {
+		return err
+	}
+	counts.Created = created
+
+	// Creates of existing relationships, whether skipped by
+	// DuplicateWriteIgnore or overwritten by DuplicateWriteUpsert, add no
+	// relationship, so only the newly inserted rows are counted.
+	createdCount, err := safecast.ToInt64(created)
+	if err != nil {
+		return spiceerrors.MustBugf("could not cast created count to int64: %v", err)
+	}
+	rwt.relCountChange += createdCount
+
+	touchInserted, touched, err := rwt.touchInBatches(ctx, bulkTouchValues)
+	if err != nil {
+		return err
+	}
+	counts.Touched = touched
+
+	// Touches of existing relationships update them in place, so, as for
+	// creates, only the newly inserted rows add to the relationship count.
+	touchInsertedCount, err := safecast.ToInt64(touchInserted)
+	if err != nil {
+		return spiceerrors.MustBugf("could not cast touched count to int64: %v", err)
+	}
+	rwt.relCountChange += touchInsertedCount
+
+	counts.Skipped = skippedMutations(len(mutations), counts)
+	rwt.writeCounts.add(counts)
+	return nil
+}
+
+// createInBatches inserts the rows of the relationships created by mutations,
+// in batches, and returns the number of relationships that did not already
+// exist. With DuplicateWriteUpsert, whose upserts count the overwritten rows as
+// affected too, the rows are first inserted with their duplicates ignored, to
+// count the new relationships, and only if some were duplicates are the rows
+// then upserted, to overwrite the existing relationships.
+func (rwt *crdbReadWriteTXN) createInBatches(ctx context.Context, rows [][]any) (uint64, error) {
+	if rwt.duplicateWritePolicy != DuplicateWriteUpsert {
+		return rwt.insertInBatches(ctx, rwt.queryCreateTuple, rows)
+	}
+
+	inserted, err := rwt.insertInBatches(ctx, func() sq.InsertBuilder {
+		return rwt.queryWriteTuple().Suffix(ignoreTupleSuffix)
+	}, rows)
+	if err != nil {
+		return 0, err
+	}
+	if inserted == uint64(len(rows)) {
+		return inserted, nil
+	}
+
+	if _, err := rwt.insertInBatches(ctx, rwt.queryCreateTuple, rows); err != nil {
+		return 0, err
 	}
+	return inserted, nil
+}
 
-	bulkUpdateQueries := make([]sq.InsertBuilder, 0, 2)
-	if bulkWriteCount > 0 {
-		bulkUpdateQueries = append(bulkUpdateQueries, bulkWrite)
+// touchInBatches writes the rows of the relationships touched by mutations, in
+// batches, and returns both the number of relationships that did not already
+// exist and the number of relationships inserted or updated. As upserts count
+// the updated rows as affected too, the rows are first inserted with their
+// duplicates ignored, to count the new relationships, and only if some were
+// duplicates are the rows then upserted, to update the changed relationships.
+func (rwt *crdbReadWriteTXN) touchInBatches(ctx context.Context, rows [][]any) (uint64, uint64, error) {
+	inserted, err := rwt.insertInBatches(ctx, func() sq.InsertBuilder {
+		return rwt.queryWriteTuple().Suffix(ignoreTupleSuffix)
+	}, rows)
+	if err != nil {
+		return 0, 0, err
 	}
-	if bulkTouchCount > 0 {
-		bulkUpdateQueries = append(bulkUpdateQueries, bulkTouch)
+	if inserted == uint64(len(rows)) {
+		return inserted, inserted, nil
 	}
 
-	for _, updateQuery := range bulkUpdateQueries {
-		sql, args, err := updateQuery.ToSql()
+	// The rows inserted above are unchanged by their upsert, so only the
+	// updated relationships are affected.
+	updated, err := rwt.insertInBatches(ctx, rwt.queryTouchTuple, rows)
+	if err != nil {
+		return 0, 0, err
+	}
+	return inserted, inserted + updated, nil
+}
+
+// insertInBatches inserts the given rows using queries built by newQuery, with
+// at most writeBatchSize rows per statement, and returns the number of rows
+// inserted or updated.
+func (rwt *crdbReadWriteTXN) insertInBatches(ctx context.Context, newQuery func() sq.InsertBuilder, rows [][]any) (uint64, error) {
+	var affected uint64
+	batchSize := rwt.writeBatchSize
+	if batchSize <= 0 {
+		batchSize = len(rows)
+	}
+
+	for start := 0; start < len(rows); start += batchSize {
+		query := newQuery()
+		for _, values := range rows[start:min(start+batchSize, len(rows))] {
+			query = query.Values(values...)
+		}
+
+		sql, args, err := query.ToSql()
 		if err != nil {
-			return fmt.Errorf(errUnableToWriteRelationships, err)
+			return 0, fmt.Errorf(errUnableToWriteRelationships, err)
 		}
 
-		if _, err := rwt.tx.Exec(ctx, sql, args...); err != nil {
-			return fmt.Errorf(errUnableToWriteRelationships, err)
+		result, err := rwt.tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return 0, fmt.Errorf(errUnableToWriteRelationships, err)
 		}
+		rowsAffected, err := safecast.ToUint64(result.RowsAffected())
+		if err != nil {
+			return 0, spiceerrors.MustBugf("could not cast RowsAffected to uint64: %v", err)
+		}
+		affected += rowsAffected
 	}
 
-	return nil
+	return affected, nil
 }
 
 func exactRelationshipClause(r tuple.Relationship) sq.Eq {
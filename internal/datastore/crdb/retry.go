@@ -0,0 +1,48 @@
+package crdb
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// ExecuteWithRetry runs fn within a transaction on the given pool, retrying
+// the entire transaction with exponential backoff whenever CockroachDB reports
+// a retryable error (e.g. a serialization failure, SQLSTATE 40001), in the
+// same manner as the datastore does internally.
+//
+// If the error persists after maxRetries retries, a *pool.MaxRetryError
+// wrapping the last error is returned. Errors that are not retryable are
+// returned immediately.
+func ExecuteWithRetry(ctx context.Context, conns *pgxpool.Pool, maxRetries uint8, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	var err error
+	for retries := uint8(0); retries <= maxRetries; retries++ {
+		err = pgx.BeginFunc(ctx, conns, func(tx pgx.Tx) error {
+			return fn(ctx, tx)
+		})
+		if err == nil {
+			if retries > 0 {
+				log.Ctx(ctx).Info().Uint8("retries", retries).Msg("retryable database error succeeded after retry")
+			}
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !pool.IsRetryableError(ctx, err) {
+			return err
+		}
+
+		log.Ctx(ctx).Info().Err(err).Uint8("retries", retries).Msg("retryable error")
+		pgxcommon.SleepOnErr(ctx, err, retries)
+	}
+
+	return &pool.MaxRetryError{MaxRetries: maxRetries, LastErr: err}
+}
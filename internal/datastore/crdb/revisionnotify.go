@@ -0,0 +1,60 @@
+package crdb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// revisionNotifier invokes a callback, on its own goroutine, with each
+// optimized revision that advances past the last one notified. Revisions
+// observed while the callback is running are coalesced, such that the
+// callback is next invoked with only the latest of them.
+type revisionNotifier struct {
+	callback func(datastore.Revision)
+	pending  chan datastore.Revision
+
+	lock sync.Mutex
+	last datastore.Revision
+}
+
+func newRevisionNotifier(callback func(datastore.Revision)) *revisionNotifier {
+	return &revisionNotifier{
+		callback: callback,
+		pending:  make(chan datastore.Revision, 1),
+	}
+}
+
+// observe queues the revision for the callback if it advances past the last
+// revision observed, without blocking.
+func (rn *revisionNotifier) observe(revision datastore.Revision) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+
+	if rn.last != nil && !revision.GreaterThan(rn.last) {
+		return
+	}
+	rn.last = revision
+
+	// Replace any revision that has not yet been delivered.
+	select {
+	case <-rn.pending:
+	default:
+	}
+	rn.pending <- revision
+}
+
+// run delivers the queued revisions to the callback until the context is
+// canceled.
+func (rn *revisionNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case revision := <-rn.pending:
+			rn.callback(revision)
+		}
+	}
+}
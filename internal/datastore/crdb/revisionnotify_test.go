@@ -0,0 +1,57 @@
+package crdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestRevisionNotifier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	delivered := make(chan datastore.Revision)
+	notifier := newRevisionNotifier(func(revision datastore.Revision) { delivered <- revision })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notifier.run(ctx)
+	}()
+
+	rev := func(seconds int64) datastore.Revision {
+		return revisions.NewHLCForTime(time.Unix(seconds, 0))
+	}
+
+	notifier.observe(rev(10))
+	require.True(t, rev(10).Equal(<-delivered))
+
+	// Revisions that do not advance are not delivered.
+	notifier.observe(rev(10))
+	notifier.observe(rev(5))
+
+	// Observing does not block on a slow callback, and only the latest of the
+	// revisions observed while it runs is delivered afterwards. The callback
+	// may or may not have started for the first of them.
+	notifier.observe(rev(20))
+	notifier.observe(rev(30))
+	notifier.observe(rev(40))
+	first := <-delivered
+	if first.Equal(rev(20)) {
+		first = <-delivered
+	}
+	require.True(t, rev(40).Equal(first))
+
+	select {
+	case revision := <-delivered:
+		require.Failf(t, "unexpected revision delivered", "%s", revision)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel()
+	<-done
+}
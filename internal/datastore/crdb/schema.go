@@ -0,0 +1,140 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+)
+
+const (
+	colVersionNum = "version_num"
+
+	queryTableColumns = `SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = 'public' AND table_name = ANY($1)`
+)
+
+// versionTableName returns the name of the table from which the version of the
+// schema is read, under the prefix given to MigrationTablePrefix.
+func versionTableName(prefix string) string {
+	return migrations.PrefixedTable(prefix, migrations.TableSchemaVersion)
+}
+
+// relationshipIndexes and relationshipWithIntegrityIndexes are the indexes
+// created by the migrations on the relationships tables without and with
+// integrity, respectively.
+var (
+	relationshipIndexes = []string{
+		"pk_relation_tuple",
+		"ix_relation_tuple_by_subject",
+		"ix_relation_tuple_by_subject_relation",
+	}
+	relationshipWithIntegrityIndexes = []string{
+		"pk_relation_tuple",
+		"ix_relation_tuple_with_integrity",
+	}
+)
+
+// expectedColumns returns the set of tables, and the columns within each
+// table, that the datastore reads from or writes to when configured with the
+// given schema and version table.
+func expectedColumns(schema common.SchemaInformation, versionTable string) map[string][]string {
+	relColumns := []string{
+		schema.ColNamespace,
+		schema.ColObjectID,
+		schema.ColRelation,
+		schema.ColUsersetNamespace,
+		schema.ColUsersetObjectID,
+		schema.ColUsersetRelation,
+		schema.ColCaveatName,
+		schema.ColCaveatContext,
+		schema.ColExpiration,
+		colTimestamp,
+	}
+	if schema.IntegrityEnabled {
+		relColumns = append(relColumns, schema.ColIntegrityKeyID, schema.ColIntegrityHash)
+	}
+
+	return map[string][]string{
+		schema.RelationshipTableName: relColumns,
+		tableNamespace:               {colNamespace, colConfig, colTimestamp},
+		tableCaveat:                  {colCaveatName, colCaveatDefinition, colTimestamp},
+		tableTransactions:            {colTransactionKey, colTimestamp},
+		tableRelationshipCounter:     {colCounterName, colCounterSerializedFilter, colCounterCurrentCount, colCounterUpdatedAt},
+		tableTransactionMetadata:     {colTransactionKey, colExpiresAt, colMetadata},
+		tableMetadata:                {colUniqueID},
+		versionTable:                 {colVersionNum},
+	}
+}
+
+// validateSchema ensures that the database has been migrated to a version that
+// is considered ready by the validator and that all expected tables and
+// columns exist.
+func validateSchema(ctx context.Context, conn pgxcommon.DBFuncQuerier, validator *common.MigrationValidator, schema common.SchemaInformation, versionTable string) error {
+	sql, args, err := psql.Select(colVersionNum).From(versionTable).ToSql()
+	if err != nil {
+		return err
+	}
+
+	var version string
+	if err := conn.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
+		return row.Scan(&version)
+	}, sql, args...); err != nil {
+		if pool.IsMissingTable(err) {
+			return fmt.Errorf("datastore is not migrated: %s table not found. Please run \"spicedb datastore migrate\"", versionTable)
+		}
+		return fmt.Errorf("unable to load schema version: %w", err)
+	}
+
+	if state := validator.MigrationReadyState(version); !state.IsReady {
+		return errors.New(state.Message)
+	}
+
+	expected := expectedColumns(schema, versionTable)
+	tableNames := make([]string, 0, len(expected))
+	for tableName := range expected {
+		tableNames = append(tableNames, tableName)
+	}
+
+	found := make(map[string][]string, len(expected))
+	if err := conn.QueryFunc(ctx, func(ctx context.Context, rows pgx.Rows) error {
+		for rows.Next() {
+			var tableName, columnName string
+			if err := rows.Scan(&tableName, &columnName); err != nil {
+				return err
+			}
+			found[tableName] = append(found[tableName], columnName)
+		}
+		return rows.Err()
+	}, queryTableColumns, tableNames); err != nil {
+		return fmt.Errorf("unable to read table columns: %w", err)
+	}
+
+	var missing []string
+	for tableName, columns := range expected {
+		foundColumns, ok := found[tableName]
+		if !ok {
+			missing = append(missing, "table "+tableName)
+			continue
+		}
+		for _, column := range columns {
+			if !slices.Contains(foundColumns, column) {
+				missing = append(missing, "column "+tableName+"."+column)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("datastore schema at version %q is missing expected objects: %s", version, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+// fakeSchemaQuerier answers the queries made by validateSchema with the given
+// version and table columns.
+type fakeSchemaQuerier struct {
+	version    string
+	versionErr error
+	columns    map[string][]string
+}
+
+func (fq fakeSchemaQuerier) ExecFunc(context.Context, func(context.Context, pgconn.CommandTag, error) error, string, ...any) error {
+	return errors.New("unexpected exec")
+}
+
+func (fq fakeSchemaQuerier) QueryFunc(ctx context.Context, rowsFunc func(context.Context, pgx.Rows) error, _ string, _ ...any) error {
+	rows := &fakeColumnRows{}
+	for tableName, columns := range fq.columns {
+		for _, column := range columns {
+			rows.columns = append(rows.columns, [2]string{tableName, column})
+		}
+	}
+	return rowsFunc(ctx, rows)
+}
+
+func (fq fakeSchemaQuerier) QueryRowFunc(ctx context.Context, rowFunc func(context.Context, pgx.Row) error, _ string, _ ...any) error {
+	if fq.versionErr != nil {
+		return fq.versionErr
+	}
+	return rowFunc(ctx, fakeVersionRow(fq.version))
+}
+
+type fakeVersionRow string
+
+func (fr fakeVersionRow) Scan(dest ...any) error {
+	*dest[0].(*string) = string(fr)
+	return nil
+}
+
+// fakeColumnRows are the rows of the table columns query; the methods of
+// pgx.Rows not used by validateSchema are left unimplemented.
+type fakeColumnRows struct {
+	pgx.Rows
+	columns [][2]string
+	next    int
+}
+
+func (fr *fakeColumnRows) Next() bool {
+	fr.next++
+	return fr.next <= len(fr.columns)
+}
+
+func (fr *fakeColumnRows) Scan(dest ...any) error {
+	*dest[0].(*string) = fr.columns[fr.next-1][0]
+	*dest[1].(*string) = fr.columns[fr.next-1][1]
+	return nil
+}
+
+func (fr *fakeColumnRows) Err() error { return nil }
+
+func testSchema(integrityEnabled bool) common.SchemaInformation {
+	relTableName := tableTuple
+	if integrityEnabled {
+		relTableName = tableTupleWithIntegrity
+	}
+
+	return *common.NewSchemaInformationWithOptions(
+		common.WithRelationshipTableName(relTableName),
+		common.WithColNamespace(colNamespace),
+		common.WithColObjectID(colObjectID),
+		common.WithColRelation(colRelation),
+		common.WithColUsersetNamespace(colUsersetNamespace),
+		common.WithColUsersetObjectID(colUsersetObjectID),
+		common.WithColUsersetRelation(colUsersetRelation),
+		common.WithColCaveatName(colCaveatContextName),
+		common.WithColCaveatContext(colCaveatContext),
+		common.WithColExpiration(colExpiration),
+		common.WithColIntegrityKeyID(colIntegrityKeyID),
+		common.WithColIntegrityHash(colIntegrityHash),
+		common.WithColIntegrityTimestamp(colTimestamp),
+		common.WithPaginationFilterType(common.ExpandedLogicComparison),
+		common.WithPlaceholderFormat(sq.Dollar),
+		common.WithNowFunction("NOW"),
+		common.WithIntegrityEnabled(integrityEnabled),
+	)
+}
+
+func TestValidateSchema(t *testing.T) {
+	const versionTable = "schema_version"
+	expected := func(integrityEnabled bool) map[string][]string {
+		return expectedColumns(testSchema(integrityEnabled), versionTable)
+	}
+	without := func(columns map[string][]string, tableName string, column string) map[string][]string {
+		columns = maps.Clone(columns)
+		if column == "" {
+			delete(columns, tableName)
+			return columns
+		}
+		var kept []string
+		for _, existing := range columns[tableName] {
+			if existing != column {
+				kept = append(kept, existing)
+			}
+		}
+		columns[tableName] = kept
+		return columns
+	}
+
+	testCases := []struct {
+		name             string
+		integrityEnabled bool
+		querier          fakeSchemaQuerier
+		expectedError    string
+	}{
+		{
+			name:    "migrated",
+			querier: fakeSchemaQuerier{version: "head", columns: expected(false)},
+		},
+		{
+			name:             "migrated with integrity",
+			integrityEnabled: true,
+			querier:          fakeSchemaQuerier{version: "head", columns: expected(true)},
+		},
+		{
+			name:    "allowed previous version",
+			querier: fakeSchemaQuerier{version: "previous", columns: expected(false)},
+		},
+		{
+			name:          "missing version table",
+			querier:       fakeSchemaQuerier{versionErr: &pgconn.PgError{Code: "42P01"}},
+			expectedError: "datastore is not migrated: schema_version table not found",
+		},
+		{
+			name:          "version query failed",
+			querier:       fakeSchemaQuerier{versionErr: errors.New("connection reset")},
+			expectedError: "unable to load schema version: connection reset",
+		},
+		{
+			name:          "not migrated to head",
+			querier:       fakeSchemaQuerier{version: "older", columns: expected(false)},
+			expectedError: `currently at revision "older", but requires "head"`,
+		},
+		{
+			name:          "missing table",
+			querier:       fakeSchemaQuerier{version: "head", columns: without(expected(false), tableMetadata, "")},
+			expectedError: "missing expected objects: table " + tableMetadata,
+		},
+		{
+			name:          "missing column",
+			querier:       fakeSchemaQuerier{version: "head", columns: without(expected(false), tableTuple, colExpiration)},
+			expectedError: "missing expected objects: column " + tableTuple + "." + colExpiration,
+		},
+		{
+			name:             "missing integrity columns",
+			integrityEnabled: true,
+			querier: fakeSchemaQuerier{
+				version: "head",
+				columns: without(without(expected(true), tableTupleWithIntegrity, colIntegrityHash), tableTupleWithIntegrity, colIntegrityKeyID),
+			},
+			expectedError: "missing expected objects: column " + tableTupleWithIntegrity + "." + colIntegrityHash + ", column " + tableTupleWithIntegrity + "." + colIntegrityKeyID,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			validator := common.NewMigrationValidator("head", []string{"previous"})
+			err := validateSchema(context.Background(), tc.querier, validator, testSchema(tc.integrityEnabled), versionTable)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
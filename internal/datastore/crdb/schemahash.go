@@ -0,0 +1,83 @@
+package crdb
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// SchemaHash returns a hex-encoded SHA-256 hash of the namespace and caveat
+// definitions stored at the head revision, allowing tooling to detect when
+// the schema has changed without comparing the definitions themselves.
+//
+// The hash depends only on the definitions, not on the order in which they
+// are read nor on the revisions at which they were written, so rewriting an
+// unchanged schema does not change it.
+func (cds *crdbDatastore) SchemaHash(ctx context.Context) (string, error) {
+	if err := cds.checkOpen(); err != nil {
+		return "", err
+	}
+
+	headRevision, err := cds.HeadRevision(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	reader := cds.SnapshotReader(headRevision)
+	namespaces, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to read namespaces: %w", err)
+	}
+	caveats, err := reader.ListAllCaveats(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to read caveats: %w", err)
+	}
+
+	return schemaHash(namespaces, caveats)
+}
+
+// schemaHash hashes the namespaces followed by the caveats, each sorted by
+// name, serialized deterministically and prefixed by their kind and length.
+func schemaHash(namespaces []datastore.RevisionedNamespace, caveats []datastore.RevisionedCaveat) (string, error) {
+	hash := sha256.New()
+	if err := hashDefinitions(hash, "namespace", namespaces); err != nil {
+		return "", err
+	}
+	if err := hashDefinitions(hash, "caveat", caveats); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashDefinitions[T interface {
+	datastore.SchemaDefinition
+	proto.Message
+}](h hash.Hash, kind string, revisioned []datastore.RevisionedDefinition[T]) error {
+	definitions := make([]T, 0, len(revisioned))
+	for _, definition := range revisioned {
+		definitions = append(definitions, definition.Definition)
+	}
+	slices.SortFunc(definitions, func(a, b T) int { return cmp.Compare(a.GetName(), b.GetName()) })
+
+	marshal := proto.MarshalOptions{Deterministic: true}
+	for _, definition := range definitions {
+		serialized, err := marshal.Marshal(definition)
+		if err != nil {
+			return fmt.Errorf("unable to serialize %s %s: %w", kind, definition.GetName(), err)
+		}
+
+		h.Write([]byte(kind))
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(serialized))))
+		h.Write(serialized)
+	}
+	return nil
+}
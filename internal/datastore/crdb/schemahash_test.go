@@ -0,0 +1,55 @@
+package crdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func TestSchemaHash(t *testing.T) {
+	revision := revisions.NewHLCForTime(time.Unix(1, 0))
+	later := revisions.NewHLCForTime(time.Unix(2, 0))
+
+	namespaces := []datastore.RevisionedNamespace{
+		{Definition: &core.NamespaceDefinition{Name: "document"}, LastWrittenRevision: revision},
+		{Definition: &core.NamespaceDefinition{Name: "user"}, LastWrittenRevision: revision},
+	}
+	caveats := []datastore.RevisionedCaveat{
+		{Definition: &core.CaveatDefinition{Name: "ip_allowlist", SerializedExpression: []byte("expr")}, LastWrittenRevision: revision},
+	}
+
+	hash, err := schemaHash(namespaces, caveats)
+	require.NoError(t, err)
+	require.Len(t, hash, 64)
+
+	// The hash does not depend on the order nor the revisions of the
+	// definitions.
+	reordered, err := schemaHash([]datastore.RevisionedNamespace{
+		{Definition: &core.NamespaceDefinition{Name: "user"}, LastWrittenRevision: later},
+		{Definition: &core.NamespaceDefinition{Name: "document"}, LastWrittenRevision: later},
+	}, caveats)
+	require.NoError(t, err)
+	require.Equal(t, hash, reordered)
+
+	// A changed definition changes the hash.
+	changed, err := schemaHash(namespaces, []datastore.RevisionedCaveat{
+		{Definition: &core.CaveatDefinition{Name: "ip_allowlist", SerializedExpression: []byte("other")}, LastWrittenRevision: revision},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changed)
+
+	// A definition moved between kinds changes the hash.
+	empty, err := schemaHash(nil, nil)
+	require.NoError(t, err)
+	asNamespace, err := schemaHash([]datastore.RevisionedNamespace{{Definition: &core.NamespaceDefinition{Name: "a"}}}, nil)
+	require.NoError(t, err)
+	asCaveat, err := schemaHash(nil, []datastore.RevisionedCaveat{{Definition: &core.CaveatDefinition{Name: "a"}}})
+	require.NoError(t, err)
+	require.NotEqual(t, empty, asNamespace)
+	require.NotEqual(t, asNamespace, asCaveat)
+}
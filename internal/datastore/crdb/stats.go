@@ -3,6 +3,7 @@ package crdb
 import (
 	"context"
 	"fmt"
+	"maps"
 	"slices"
 
 	"github.com/Masterminds/squirrel"
@@ -20,22 +21,51 @@ const (
 	colUniqueID   = "unique_id"
 )
 
-var (
-	queryReadUniqueID = psql.Select(colUniqueID).From(tableMetadata)
-	uniqueID          string
-)
+const queryTableRowStatistics = `SELECT table_name, estimated_row_count FROM crdb_internal.table_row_statistics WHERE table_name = ANY($1)`
+
+// TableSizes returns the approximate number of rows in each of the tables used
+// by the datastore, keyed by table name. The counts are estimates taken from
+// the table statistics that CockroachDB collects automatically, rather than
+// exact counts, so reading them does not scan the tables; they may lag behind
+// recent writes and deletes until the statistics are next refreshed. Tables
+// without statistics are reported with a count of zero.
+func (cds *crdbDatastore) TableSizes(ctx context.Context) (map[string]int64, error) {
+	if err := cds.checkOpen(); err != nil {
+		return nil, err
+	}
 
-func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, error) {
-	if len(uniqueID) == 0 {
-		sql, args, err := queryReadUniqueID.ToSql()
-		if err != nil {
-			return datastore.Stats{}, fmt.Errorf("unable to prepare unique ID sql: %w", err)
-		}
-		if err := cds.readPool.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
-			return row.Scan(&uniqueID)
-		}, sql, args...); err != nil {
-			return datastore.Stats{}, fmt.Errorf("unable to query unique ID: %w", err)
+	tables := slices.Sorted(maps.Keys(expectedColumns(cds.schema, versionTableName(cds.migrationTablePrefix))))
+	sizes := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		sizes[table] = 0
+	}
+
+	if err := cds.readPool.QueryFunc(ctx, func(ctx context.Context, rows pgx.Rows) error {
+		for rows.Next() {
+			var table string
+			var estimatedRows int64
+			if err := rows.Scan(&table, &estimatedRows); err != nil {
+				return err
+			}
+			sizes[table] = estimatedRows
 		}
+		return rows.Err()
+	}, queryTableRowStatistics, tables); err != nil {
+		return nil, fmt.Errorf("unable to read table statistics: %w", err)
+	}
+	return sizes, nil
+}
+
+func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, error) {
+	if err := cds.checkOpen(); err != nil {
+		return datastore.Stats{}, err
+	}
+
+	logger := cds.operationalLogger(log.Ctx(ctx))
+
+	uniqueID, err := cds.InstanceID(ctx)
+	if err != nil {
+		return datastore.Stats{}, err
 	}
 
 	var nsDefs []datastore.RevisionedNamespace
@@ -75,7 +105,7 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 			hasRows = true
 			values, err := rows.Values()
 			if err != nil {
-				log.Warn().Err(err).Msg("unable to read statistics")
+				logger.Warn().Err(err).Msg("unable to read statistics")
 				return nil
 			}
 
@@ -89,7 +119,7 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 
 				columnNames, ok := values[index].([]any)
 				if !ok {
-					log.Warn().Msg("unable to read column names")
+					logger.Warn().Msg("unable to read column names")
 					return nil
 				}
 
@@ -116,7 +146,7 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 
 				rowCount, ok := values[index].(int64)
 				if !ok {
-					log.Warn().Msg("unable to read row count")
+					logger.Warn().Msg("unable to read row count")
 					return nil
 				}
 
@@ -129,7 +159,7 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 			}
 		}
 
-		log.Warn().Bool("has-rows", hasRows).Msg("unable to find row count in statistics query result")
+		logger.Warn().Bool("has-rows", hasRows).Msg("unable to find row count in statistics query result")
 		return nil
 	}, "SHOW STATISTICS FOR TABLE "+cds.schema.RelationshipTableName); err != nil {
 		return datastore.Stats{}, fmt.Errorf("unable to query unique estimated row count: %w", err)
@@ -0,0 +1,56 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrDestructiveOperationsDisabled is returned by TruncateAllData unless the
+// datastore was created with AllowDestructiveOperations.
+var ErrDestructiveOperationsDisabled = errors.New("destructive operations are disabled: create the datastore with AllowDestructiveOperations to enable them")
+
+// retainedTables returns the tables kept by TruncateAllData, which record the
+// migration of the database and its unique ID rather than SpiceDB data.
+func (cds *crdbDatastore) retainedTables() []string {
+	return []string{versionTableName(cds.migrationTablePrefix), tableMetadata}
+}
+
+// TruncateAllData deletes all relationships, schema definitions, counters and
+// transaction metadata, leaving the datastore migrated but empty, which is
+// much faster than dropping and re-migrating the database between tests.
+// The schema version and the unique ID of the datastore are kept. Caches
+// layered above the datastore are not invalidated, so they must be discarded
+// along with it.
+//
+// This is intended for test teardown and fails with
+// ErrDestructiveOperationsDisabled unless the datastore was created with
+// AllowDestructiveOperations.
+func (cds *crdbDatastore) TruncateAllData(ctx context.Context) error {
+	if err := cds.checkOpen(); err != nil {
+		return err
+	}
+	if !cds.allowDestructiveOperations {
+		return ErrDestructiveOperationsDisabled
+	}
+	if cds.readOnly.Load() {
+		return ErrReadOnlyMode
+	}
+
+	retained := cds.retainedTables()
+	tables := slices.DeleteFunc(slices.Sorted(maps.Keys(expectedColumns(cds.schema, versionTableName(cds.migrationTablePrefix)))), func(table string) bool {
+		return slices.Contains(retained, table)
+	})
+	sql := "TRUNCATE " + strings.Join(tables, ", ")
+	if err := cds.writePool.ExecFunc(ctx, func(ctx context.Context, tag pgconn.CommandTag, err error) error {
+		return err
+	}, sql); err != nil {
+		return fmt.Errorf("unable to truncate datastore tables: %w", err)
+	}
+	return nil
+}
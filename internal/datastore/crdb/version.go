@@ -2,23 +2,20 @@ package crdb
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rs/zerolog"
 
+	"github.com/authzed/spicedb/internal/datastore/crdb/pool"
 	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
 )
 
 const (
 	queryVersionJSON = "SELECT crdb_internal.active_version()::jsonb;"
 	queryVersion     = "SELECT version();"
-
-	errFunctionDoesNotExist = "42883"
 )
 
 var versionRegex = regexp.MustCompile(`v([0-9]+)\.([0-9]+)\.([0-9]+)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+)?`)
@@ -27,8 +24,7 @@ func queryServerVersion(ctx context.Context, db pgxcommon.DBFuncQuerier, version
 	if err := db.QueryRowFunc(ctx, func(ctx context.Context, row pgx.Row) error {
 		return row.Scan(version)
 	}, queryVersionJSON); err != nil {
-		var pgerr *pgconn.PgError
-		if !errors.As(err, &pgerr) || pgerr.Code != errFunctionDoesNotExist {
+		if !pool.IsMissingFunction(err) {
 			return err
 		}
 
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -20,6 +22,7 @@ import (
 	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
 	"github.com/authzed/spicedb/internal/datastore/revisions"
 	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/genutil/mapz"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 	"github.com/authzed/spicedb/pkg/tuple"
@@ -66,12 +69,26 @@ type changeDetails struct {
 }
 
 func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, options datastore.WatchOptions) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return cds.watchWithOverflowPolicy(ctx, afterRevision, options, cds.watchBufferOverflowPolicy)
+}
+
+// watchWithOverflowPolicy watches like Watch, applying the given overflow
+// policy, rather than that of WatchBufferOverflowPolicy, when the buffer of
+// the watch is full.
+func (cds *crdbDatastore) watchWithOverflowPolicy(ctx context.Context, afterRevision datastore.Revision, options datastore.WatchOptions, overflowPolicy string) (<-chan *datastore.RevisionChanges, <-chan error) {
 	watchBufferLength := options.WatchBufferLength
 	if watchBufferLength <= 0 {
-		watchBufferLength = cds.watchBufferLength
+		watchBufferLength = cds.watchBufferLengthFor(options.OptionalResourceTypes)
+	}
+
+	// With compression, the changes are buffered in compressed form before
+	// the updates channel, which is then unbuffered.
+	updatesLength := watchBufferLength
+	if cds.watchCompressionThreshold > 0 {
+		updatesLength = 0
 	}
 
-	updates := make(chan *datastore.RevisionChanges, watchBufferLength)
+	updates := make(chan *datastore.RevisionChanges, updatesLength)
 	errs := make(chan error, 1)
 
 	features, err := cds.Features(ctx)
@@ -93,17 +110,96 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 		return updates, errs
 	}
 
-	go cds.watch(ctx, afterRevision, options, updates, errs)
+	headRevision, err := cds.headRevisionInternal(ctx)
+	if err != nil {
+		close(updates)
+		errs <- err
+		return updates, errs
+	}
+
+	if err := checkWatchCatchupWindow(afterRevision, headRevision, cds.maxWatchCatchupWindow); err != nil {
+		close(updates)
+		errs <- err
+		return updates, errs
+	}
+
+	// The changes produced by the watch are compressed into the buffer and
+	// decompressed as they are delivered to the updates channel.
+	produced := updates
+	var buffer chan compressedChange
+	bufferLength := func() int { return len(updates) }
+	if cds.watchCompressionThreshold > 0 {
+		produced = make(chan *datastore.RevisionChanges)
+		buffer = make(chan compressedChange, watchBufferLength)
+		bufferLength = func() int { return len(buffer) }
+	}
+
+	registered, err := cds.watches.register(afterRevision, bufferLength, int(watchBufferLength))
+	if err != nil {
+		close(updates)
+		errs <- err
+		return updates, errs
+	}
+	releaseSlot := func() { cds.watches.unregister(registered) }
+
+	// Stop the watch when the datastore is closed, so that Close can wait for it.
+	watchCtx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(cds.ctx, func() { cancel(datastore.ErrDatastoreClosed) })
+	if !cds.goBackground(func() {
+		defer releaseSlot()
+		defer cancel(nil)
+		defer stop()
+		if buffer != nil {
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				compressChanges(watchCtx, produced, buffer, cds.watchCompressionThreshold)
+			}()
+			go func() {
+				defer wg.Done()
+				decompressChanges(watchCtx, buffer, updates)
+			}()
+			defer wg.Wait()
+		}
+		cds.watch(watchCtx, afterRevision, options, overflowPolicy, produced, errs, registered)
+	}) {
+		stop()
+		cancel(nil)
+		releaseSlot()
+		close(updates)
+		errs <- datastore.ErrDatastoreClosed
+	}
 
 	return updates, errs
 }
 
+// watchBufferLengthFor returns the default buffer length for a watch restricted
+// to the given resource types.
+func (cds *crdbDatastore) watchBufferLengthFor(resourceTypes []string) uint16 {
+	if len(resourceTypes) == 0 || len(cds.watchBufferLengthByType) == 0 {
+		return cds.watchBufferLength
+	}
+
+	var total uint64
+	for _, resourceType := range mapz.NewSet(resourceTypes...).AsSlice() {
+		length, ok := cds.watchBufferLengthByType[resourceType]
+		if !ok {
+			length = cds.watchBufferLength
+		}
+		total += uint64(length)
+	}
+	return uint16(min(total, math.MaxUint16))
+}
+
 func (cds *crdbDatastore) watch(
 	ctx context.Context,
 	afterRevision datastore.Revision,
 	opts datastore.WatchOptions,
+	overflowPolicy string,
 	updates chan *datastore.RevisionChanges,
 	errs chan error,
+	registered *registeredWatch,
 ) {
 	defer close(updates)
 	defer close(errs)
@@ -155,6 +251,11 @@ func (cds *crdbDatastore) watch(
 	interpolated := fmt.Sprintf(cds.beginChangefeedQuery, strings.Join(tableNames, ","), afterRevision, resolvedDurationString)
 
 	sendError := func(err error) {
+		if errors.Is(context.Cause(ctx), datastore.ErrDatastoreClosed) {
+			errs <- datastore.ErrDatastoreClosed
+			return
+		}
+
 		if errors.Is(ctx.Err(), context.Canceled) {
 			errs <- datastore.NewWatchCanceledErr()
 			return
@@ -174,24 +275,30 @@ func (cds *crdbDatastore) watch(
 	}
 
 	sendChange := func(change *datastore.RevisionChanges) error {
-		select {
-		case updates <- change:
-			return nil
+		return sendWatchChange(ctx, updates, change, registered, overflowPolicy, watchBufferWriteTimeout)
+	}
 
-		default:
-			// If we cannot immediately write, setup the timer and try again.
+	var batcher *watchBatcher
+	var errorSent bool
+	if cds.watchBatchSize > 1 || cds.watchBatchMaxLatency > 0 {
+		batcher = newWatchBatcher(sendChange, int(cds.watchBatchSize), cds.watchBatchMaxLatency)
+		sendChange = batcher.add
+
+		sendErrorImmediately := sendError
+		sendError = func(err error) {
+			// Deliver the changes that preceded the error before reporting it.
+			// The error is reported in place of any error delivering them,
+			// which it follows from.
+			_ = batcher.close()
+			errorSent = true
+			sendErrorImmediately(err)
 		}
+	}
 
-		timer := time.NewTimer(watchBufferWriteTimeout)
-		defer timer.Stop()
-
-		select {
-		case updates <- change:
-			return nil
-
-		case <-timer.C:
-			return datastore.NewWatchDisconnectedErr()
-		}
+	// The changefeed connection is not pooled, so it is labeled here rather
+	// than by the pool.
+	if cds.statementLabels {
+		interpolated = pool.LabelStatement(datastore.WithStatementLabel(ctx, datastore.StatementLabelWatch), interpolated)
 	}
 
 	changes, err := conn.Query(ctx, interpolated)
@@ -205,6 +312,12 @@ func (cds *crdbDatastore) watch(
 	defer func() { go changes.Close() }()
 
 	cds.processChanges(ctx, changes, sendError, sendChange, opts, opts.EmissionStrategy == datastore.EmitImmediatelyStrategy)
+
+	if batcher != nil {
+		if err := batcher.close(); err != nil && !errorSent {
+			sendError(err)
+		}
+	}
 }
 
 // changeTracker takes care of accumulating received from CockroachDB until a checkpoint is emitted
@@ -329,6 +442,8 @@ func (cds *crdbDatastore) processChanges(ctx context.Context, changes pgx.Rows,
 		tracked = common.NewChanges(revisions.HLCKeyFunc, opts.Content, opts.MaximumBufferedChangesByteSize)
 	}
 
+	resourceTypes := mapz.NewSet(opts.OptionalResourceTypes...)
+
 	for changes.Next() {
 		var tableNameBytes []byte
 		var changeJSON []byte
@@ -361,6 +476,7 @@ func (cds *crdbDatastore) processChanges(ctx context.Context, changes pgx.Rows,
 				return
 			}
 
+			toSend := make([]datastore.RevisionChanges, 0, len(filtered))
 			for _, revChange := range filtered {
 				revChange := revChange
 
@@ -404,6 +520,11 @@ func (cds *crdbDatastore) processChanges(ctx context.Context, changes pgx.Rows,
 					}
 				}
 
+				toSend = append(toSend, revChange)
+			}
+
+			for _, revChange := range coalesceRevisionChanges(toSend, cds.watchCoalesceWindow) {
+				revChange := revChange
 				if err := sendChange(&revChange); err != nil {
 					sendError(err)
 					return
@@ -434,6 +555,10 @@ func (cds *crdbDatastore) processChanges(ctx context.Context, changes pgx.Rows,
 
 		switch tableName {
 		case cds.schema.RelationshipTableName:
+			if !resourceTypes.IsEmpty() && !resourceTypes.Has(pkValues[0]) {
+				continue
+			}
+
 			var caveatName string
 			var caveatContext map[string]any
 			if details.After != nil && details.After.RelationshipCaveatName != "" {
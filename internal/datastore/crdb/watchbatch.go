@@ -0,0 +1,112 @@
+package crdb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// watchBatcher buffers consecutive relationship-only changes of a watch and
+// delivers them as a single change, at the last of their revisions, once
+// either the batch size has been reached or the oldest buffered change has
+// waited for the maximum latency, whichever comes first. Changes that cannot
+// be merged, such as checkpoints and changes to schema, end the batch and are
+// delivered on their own, as with WatchCoalesceWindow.
+type watchBatcher struct {
+	send       sendChangeFunc
+	size       int
+	maxLatency time.Duration
+
+	mu      sync.Mutex
+	pending []datastore.RevisionChanges
+	timer   *time.Timer
+	closed  bool
+
+	// err is the first error encountered while delivering a change, which is
+	// returned by every later call to add and by close.
+	err error
+}
+
+func newWatchBatcher(send sendChangeFunc, size int, maxLatency time.Duration) *watchBatcher {
+	return &watchBatcher{send: send, size: size, maxLatency: maxLatency}
+}
+
+// add buffers the change, delivering the batch if it is now full. A change
+// that cannot be merged is delivered immediately, after the batch preceding it.
+func (b *watchBatcher) add(change *datastore.RevisionChanges) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+
+	if !isCoalescable(*change) {
+		if b.err = b.flushLocked(); b.err != nil {
+			return b.err
+		}
+		b.err = b.send(change)
+		return b.err
+	}
+
+	b.pending = append(b.pending, *change)
+	if b.size > 0 && len(b.pending) >= b.size {
+		b.err = b.flushLocked()
+		return b.err
+	}
+
+	if b.timer == nil && b.maxLatency > 0 {
+		b.timer = time.AfterFunc(b.maxLatency, b.flushOnExpiry)
+	}
+	return nil
+}
+
+// close delivers any buffered changes and stops the batcher, returning the
+// error that ended the delivery of changes, if any. It must be called before
+// the updates channel is closed, so that a partial batch is not lost when the
+// watch ends.
+func (b *watchBatcher) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return b.err
+	}
+
+	if b.err == nil {
+		b.err = b.flushLocked()
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.pending = nil
+	b.closed = true
+	return b.err
+}
+
+func (b *watchBatcher) flushOnExpiry() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || b.err != nil {
+		return
+	}
+	b.err = b.flushLocked()
+}
+
+func (b *watchBatcher) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	merged := mergeRelationshipChanges(b.pending)
+	b.pending = nil
+	return b.send(&merged)
+}
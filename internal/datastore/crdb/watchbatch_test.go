@@ -0,0 +1,117 @@
+package crdb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+type recordingSender struct {
+	sync.Mutex
+	sent []*datastore.RevisionChanges
+	err  error
+}
+
+func (r *recordingSender) send(change *datastore.RevisionChanges) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, change)
+	return nil
+}
+
+func (r *recordingSender) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.sent)
+}
+
+func changeAt(seconds int64, rels ...string) *datastore.RevisionChanges {
+	change := &datastore.RevisionChanges{Revision: revisions.NewHLCForTime(time.Unix(seconds, 0))}
+	for _, rel := range rels {
+		change.RelationshipChanges = append(change.RelationshipChanges, tuple.Touch(tuple.MustParse(rel)))
+	}
+	return change
+}
+
+func TestWatchBatcherDeliversFullBatches(t *testing.T) {
+	sender := &recordingSender{}
+	batcher := newWatchBatcher(sender.send, 3, time.Hour)
+
+	require.NoError(t, batcher.add(changeAt(1, "document:1#viewer@user:1")))
+	require.NoError(t, batcher.add(changeAt(2, "document:2#viewer@user:1")))
+	require.Zero(t, sender.count())
+
+	// A full batch is delivered as a single change at its last revision.
+	require.NoError(t, batcher.add(changeAt(3, "document:3#viewer@user:1", "document:1#viewer@user:1")))
+	require.Equal(t, 1, sender.count())
+	require.True(t, sender.sent[0].Revision.Equal(changeAt(3).Revision))
+	require.Len(t, sender.sent[0].RelationshipChanges, 3)
+
+	// Partial batches are delivered on close.
+	require.NoError(t, batcher.add(changeAt(4, "document:4#viewer@user:1")))
+	require.Equal(t, 1, sender.count())
+	require.NoError(t, batcher.close())
+	require.Equal(t, 2, sender.count())
+	require.True(t, sender.sent[1].Revision.Equal(changeAt(4).Revision))
+
+	// Closing again is a no-op.
+	require.NoError(t, batcher.close())
+	require.Equal(t, 2, sender.count())
+}
+
+func TestWatchBatcherDeliversUnmergeableChangesAlone(t *testing.T) {
+	sender := &recordingSender{}
+	batcher := newWatchBatcher(sender.send, 100, time.Hour)
+
+	require.NoError(t, batcher.add(changeAt(1, "document:1#viewer@user:1")))
+	require.NoError(t, batcher.add(changeAt(2, "document:2#viewer@user:1")))
+
+	// A checkpoint ends the batch before it and is delivered immediately.
+	checkpoint := changeAt(2)
+	checkpoint.IsCheckpoint = true
+	require.NoError(t, batcher.add(checkpoint))
+	require.Equal(t, 2, sender.count())
+	require.Len(t, sender.sent[0].RelationshipChanges, 2)
+	require.Same(t, checkpoint, sender.sent[1])
+
+	require.NoError(t, batcher.add(changeAt(3, "document:3#viewer@user:1")))
+	require.NoError(t, batcher.close())
+	require.Equal(t, 3, sender.count())
+}
+
+func TestWatchBatcherDeliversAfterMaxLatency(t *testing.T) {
+	sender := &recordingSender{}
+	batcher := newWatchBatcher(sender.send, 100, 10*time.Millisecond)
+	defer func() { _ = batcher.close() }()
+
+	require.NoError(t, batcher.add(changeAt(1, "document:1#viewer@user:1")))
+	require.NoError(t, batcher.add(changeAt(2, "document:2#viewer@user:1")))
+	require.Eventually(t, func() bool { return sender.count() == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(t, batcher.add(changeAt(3, "document:3#viewer@user:1")))
+	require.Eventually(t, func() bool { return sender.count() == 2 }, time.Second, time.Millisecond)
+}
+
+func TestWatchBatcherReportsDeliveryErrors(t *testing.T) {
+	sendErr := errors.New("disconnected")
+	sender := &recordingSender{err: sendErr}
+	batcher := newWatchBatcher(sender.send, 100, 5*time.Millisecond)
+
+	require.NoError(t, batcher.add(changeAt(1)))
+	require.Eventually(t, func() bool { return batcher.add(changeAt(2)) != nil }, time.Second, time.Millisecond)
+	require.ErrorIs(t, batcher.add(changeAt(3)), sendErr)
+
+	// The error that ended delivery is returned by close.
+	require.ErrorIs(t, batcher.close(), sendErr)
+	require.ErrorIs(t, batcher.close(), sendErr)
+}
@@ -0,0 +1,29 @@
+package crdb
+
+import (
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+)
+
+// checkWatchCatchupWindow returns a WatchCatchupWindowExceededError if the
+// revision from which a watch is starting is older than the head revision by
+// more than the window.
+func checkWatchCatchupWindow(afterRevision, headRevision datastore.Revision, window time.Duration) error {
+	after, ok := afterRevision.(revisions.WithTimestampRevision)
+	if !ok {
+		return nil
+	}
+
+	head, ok := headRevision.(revisions.WithTimestampRevision)
+	if !ok {
+		return spiceerrors.MustBugf("expected with-timestamp revision, got %T", headRevision)
+	}
+
+	if head.TimestampNanoSec()-after.TimestampNanoSec() > window.Nanoseconds() {
+		return datastore.NewWatchCatchupWindowExceededErr(afterRevision, window)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package crdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestCheckWatchCatchupWindow(t *testing.T) {
+	head := time.Unix(1_700_000_000, 0)
+	window := 10 * time.Minute
+
+	testCases := []struct {
+		name     string
+		age      time.Duration
+		exceeded bool
+	}{
+		{"head revision", 0, false},
+		{"within the window", 9 * time.Minute, false},
+		{"at the window", window, false},
+		{"outside the window", window + time.Second, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			after := revisions.NewHLCForTime(head.Add(-tc.age))
+			err := checkWatchCatchupWindow(after, revisions.NewHLCForTime(head), window)
+			if !tc.exceeded {
+				require.NoError(t, err)
+				return
+			}
+
+			var exceeded datastore.WatchCatchupWindowExceededError
+			require.True(t, errors.As(err, &exceeded))
+			require.Equal(t, after, exceeded.StartRevision())
+			require.Equal(t, window, exceeded.Window())
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package crdb
+
+import (
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// coalesceRevisionChanges merges runs of consecutive, relationship-only
+// revision changes whose revisions fall within the given window of the first
+// revision in the run into a single change at the last revision of the run.
+//
+// Within a run, multiple updates to the same relationship are merged into
+// the last update applied to it. Revisions that change schema or carry
+// metadata are never merged, as doing so would misattribute them.
+func coalesceRevisionChanges(changes []datastore.RevisionChanges, window time.Duration) []datastore.RevisionChanges {
+	if window <= 0 || len(changes) < 2 {
+		return changes
+	}
+
+	coalesced := make([]datastore.RevisionChanges, 0, len(changes))
+	var run []datastore.RevisionChanges
+	var runStart int64
+
+	flush := func() {
+		if len(run) > 0 {
+			coalesced = append(coalesced, mergeRelationshipChanges(run))
+			run = nil
+		}
+	}
+
+	for _, change := range changes {
+		if !isCoalescable(change) {
+			flush()
+			coalesced = append(coalesced, change)
+			continue
+		}
+
+		timestamp := change.Revision.(revisions.HLCRevision).TimestampNanoSec()
+		if len(run) > 0 && timestamp-runStart > window.Nanoseconds() {
+			flush()
+		}
+		if len(run) == 0 {
+			runStart = timestamp
+		}
+		run = append(run, change)
+	}
+	flush()
+
+	return coalesced
+}
+
+func isCoalescable(change datastore.RevisionChanges) bool {
+	if _, ok := change.Revision.(revisions.HLCRevision); !ok {
+		return false
+	}
+
+	return !change.IsCheckpoint &&
+		len(change.ChangedDefinitions) == 0 &&
+		len(change.DeletedNamespaces) == 0 &&
+		len(change.DeletedCaveats) == 0 &&
+		len(change.Metadata.GetFields()) == 0
+}
+
+// mergeRelationshipChanges merges the relationship changes of the given
+// revisions, in order, such that each relationship appears once with its last
+// update. A relationship that was deleted and then created again is reported
+// as touched, since it may have existed before the run.
+func mergeRelationshipChanges(run []datastore.RevisionChanges) datastore.RevisionChanges {
+	if len(run) == 1 {
+		return run[0]
+	}
+
+	var order []tuple.RelationshipReference
+	latest := make(map[tuple.RelationshipReference]tuple.RelationshipUpdate)
+	previouslyRemoved := make(map[tuple.RelationshipReference]bool)
+
+	for _, change := range run {
+		for _, update := range change.RelationshipChanges {
+			key := update.Relationship.RelationshipReference
+			existing, ok := latest[key]
+			if !ok {
+				order = append(order, key)
+			} else if existing.Operation == tuple.UpdateOperationDelete {
+				previouslyRemoved[key] = true
+			}
+			latest[key] = update
+		}
+	}
+
+	merged := datastore.RevisionChanges{
+		Revision:            run[len(run)-1].Revision,
+		RelationshipChanges: make([]tuple.RelationshipUpdate, 0, len(order)),
+	}
+	for _, key := range order {
+		update := latest[key]
+		if update.Operation == tuple.UpdateOperationCreate && previouslyRemoved[key] {
+			update = tuple.Touch(update.Relationship)
+		}
+		merged.RelationshipChanges = append(merged.RelationshipChanges, update)
+	}
+
+	return merged
+}
@@ -0,0 +1,110 @@
+package crdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestCoalesceRevisionChanges(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	revAt := func(offset time.Duration) datastore.Revision {
+		return revisions.NewHLCForTime(base.Add(offset))
+	}
+	relChange := func(offset time.Duration, updates ...tuple.RelationshipUpdate) datastore.RevisionChanges {
+		return datastore.RevisionChanges{Revision: revAt(offset), RelationshipChanges: updates}
+	}
+
+	fred := tuple.MustParse("document:foo#viewer@user:fred")
+	tom := tuple.MustParse("document:foo#viewer@user:tom")
+
+	metadata, err := structpb.NewStruct(map[string]any{"some": "metadata"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		window   time.Duration
+		changes  []datastore.RevisionChanges
+		expected []datastore.RevisionChanges
+	}{
+		{
+			name:   "disabled",
+			window: 0,
+			changes: []datastore.RevisionChanges{
+				relChange(0, tuple.Create(fred)),
+				relChange(time.Millisecond, tuple.Delete(fred)),
+			},
+			expected: []datastore.RevisionChanges{
+				relChange(0, tuple.Create(fred)),
+				relChange(time.Millisecond, tuple.Delete(fred)),
+			},
+		},
+		{
+			name:   "last write wins",
+			window: 10 * time.Millisecond,
+			changes: []datastore.RevisionChanges{
+				relChange(0, tuple.Create(fred), tuple.Create(tom)),
+				relChange(time.Millisecond, tuple.Delete(fred)),
+				relChange(2*time.Millisecond, tuple.Touch(tom)),
+			},
+			expected: []datastore.RevisionChanges{
+				relChange(2*time.Millisecond, tuple.Delete(fred), tuple.Touch(tom)),
+			},
+		},
+		{
+			name:   "delete then create is not a no-op",
+			window: 10 * time.Millisecond,
+			changes: []datastore.RevisionChanges{
+				relChange(0, tuple.Delete(fred)),
+				relChange(time.Millisecond, tuple.Create(fred)),
+			},
+			expected: []datastore.RevisionChanges{
+				relChange(time.Millisecond, tuple.Touch(fred)),
+			},
+		},
+		{
+			name:   "outside of window",
+			window: 10 * time.Millisecond,
+			changes: []datastore.RevisionChanges{
+				relChange(0, tuple.Create(fred)),
+				relChange(5*time.Millisecond, tuple.Touch(fred)),
+				relChange(20*time.Millisecond, tuple.Delete(fred)),
+			},
+			expected: []datastore.RevisionChanges{
+				relChange(5*time.Millisecond, tuple.Touch(fred)),
+				relChange(20*time.Millisecond, tuple.Delete(fred)),
+			},
+		},
+		{
+			name:   "schema and metadata changes are not merged",
+			window: 10 * time.Millisecond,
+			changes: []datastore.RevisionChanges{
+				relChange(0, tuple.Create(fred)),
+				{Revision: revAt(time.Millisecond), DeletedNamespaces: []string{"other"}},
+				relChange(2*time.Millisecond, tuple.Delete(fred)),
+				{Revision: revAt(3 * time.Millisecond), RelationshipChanges: []tuple.RelationshipUpdate{tuple.Create(tom)}, Metadata: metadata},
+				relChange(4*time.Millisecond, tuple.Delete(tom)),
+			},
+			expected: []datastore.RevisionChanges{
+				relChange(0, tuple.Create(fred)),
+				{Revision: revAt(time.Millisecond), DeletedNamespaces: []string{"other"}},
+				relChange(2*time.Millisecond, tuple.Delete(fred)),
+				{Revision: revAt(3 * time.Millisecond), RelationshipChanges: []tuple.RelationshipUpdate{tuple.Create(tom)}, Metadata: metadata},
+				relChange(4*time.Millisecond, tuple.Delete(tom)),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, coalesceRevisionChanges(tc.changes, tc.window))
+		})
+	}
+}
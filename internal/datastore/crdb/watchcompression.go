@@ -0,0 +1,139 @@
+package crdb
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+)
+
+// compressedChange is a watch change waiting in the buffer of a watch, with
+// its large caveat contexts compressed.
+type compressedChange struct {
+	change *datastore.RevisionChanges
+
+	// contexts holds the compressed caveat contexts, by the index of their
+	// relationship change, of which the caveats have been stripped from the
+	// change.
+	contexts map[int][]byte
+}
+
+// compressChange compresses the caveat contexts of the change whose size, in
+// their serialized form, is at least threshold bytes. The change itself is
+// not modified. Contexts that cannot be compressed are left in place.
+func compressChange(change *datastore.RevisionChanges, threshold int) compressedChange {
+	compressed := compressedChange{change: change}
+	for i, update := range change.RelationshipChanges {
+		caveat := update.Relationship.OptionalCaveat
+		if caveat == nil || caveat.Context == nil || proto.Size(caveat.Context) < threshold {
+			continue
+		}
+
+		data, err := compressContext(caveat.Context)
+		if err != nil {
+			continue
+		}
+
+		if compressed.contexts == nil {
+			// Copy the change and its relationship changes before stripping
+			// the contexts, as the caller may hold onto the change.
+			stripped := *change
+			stripped.RelationshipChanges = append(stripped.RelationshipChanges[:0:0], change.RelationshipChanges...)
+			compressed.change = &stripped
+			compressed.contexts = map[int][]byte{}
+		}
+		compressed.change.RelationshipChanges[i].Relationship.OptionalCaveat = &core.ContextualizedCaveat{CaveatName: caveat.CaveatName}
+		compressed.contexts[i] = data
+	}
+	return compressed
+}
+
+func compressContext(caveatContext *structpb.Struct) ([]byte, error) {
+	serialized, err := proto.Marshal(caveatContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(serialized); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress returns the change with its compressed caveat contexts restored.
+func (c compressedChange) decompress() (*datastore.RevisionChanges, error) {
+	for i, data := range c.contexts {
+		serialized, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return nil, spiceerrors.MustBugf("unable to decompress caveat context: %v", err)
+		}
+
+		caveatContext := &structpb.Struct{}
+		if err := proto.Unmarshal(serialized, caveatContext); err != nil {
+			return nil, spiceerrors.MustBugf("unable to deserialize caveat context: %v", err)
+		}
+		c.change.RelationshipChanges[i].Relationship.OptionalCaveat.Context = caveatContext
+	}
+	return c.change, nil
+}
+
+// compressChanges reads the changes produced by a watch from in, compresses
+// them and writes them to the buffer, closing it once in is closed. Once
+// the context is done, the remaining changes are discarded.
+func compressChanges(ctx context.Context, in <-chan *datastore.RevisionChanges, buffer chan<- compressedChange, threshold int) {
+	defer close(buffer)
+	defer func() {
+		for range in {
+		}
+	}()
+
+	for change := range in {
+		select {
+		case buffer <- compressChange(change, threshold):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// decompressChanges reads the changes in the buffer of a watch, decompresses
+// them and delivers them to out, closing it once the buffer is closed. Once
+// the context is done, or if a change cannot be decompressed, the remaining
+// changes are discarded.
+func decompressChanges(ctx context.Context, buffer <-chan compressedChange, out chan<- *datastore.RevisionChanges) {
+	defer close(out)
+	defer func() {
+		for range buffer {
+		}
+	}()
+
+	for compressed := range buffer {
+		change, err := compressed.decompress()
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to decompress watch change")
+			return
+		}
+
+		select {
+		case out <- change:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
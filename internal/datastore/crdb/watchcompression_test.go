@@ -0,0 +1,156 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// largeContextChange returns a change touching a relationship with a caveat
+// context of the given number of IP addresses, and one without a context.
+func largeContextChange(t testing.TB, id, addresses int) *datastore.RevisionChanges {
+	allowed := make([]any, 0, addresses)
+	for i := range addresses {
+		allowed = append(allowed, fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+	caveatContext, err := structpb.NewStruct(map[string]any{"allowed_cidrs": allowed})
+	require.NoError(t, err)
+
+	caveated := tuple.MustParse(fmt.Sprintf("resource:doc%d#viewer@user:tom", id))
+	caveated.OptionalCaveat = &core.ContextualizedCaveat{CaveatName: "ip_allowlist", Context: caveatContext}
+	return &datastore.RevisionChanges{
+		Revision: revisions.NewHLCForTime(time.Unix(int64(id), 0)),
+		RelationshipChanges: []tuple.RelationshipUpdate{
+			tuple.Touch(caveated),
+			tuple.Delete(tuple.MustParse(fmt.Sprintf("resource:doc%d#viewer@user:fred", id))),
+		},
+	}
+}
+
+func TestCompressChange(t *testing.T) {
+	change := largeContextChange(t, 1, 1000)
+	original := change.RelationshipChanges[0].Relationship.OptionalCaveat.Context
+
+	// Contexts below the threshold are left in place.
+	compressed := compressChange(change, proto.Size(original)+1)
+	require.Empty(t, compressed.contexts)
+	require.Same(t, change, compressed.change)
+
+	compressed = compressChange(change, proto.Size(original))
+	require.Len(t, compressed.contexts, 1)
+	require.Less(t, len(compressed.contexts[0]), proto.Size(original))
+	require.Nil(t, compressed.change.RelationshipChanges[0].Relationship.OptionalCaveat.Context)
+	require.Equal(t, "ip_allowlist", compressed.change.RelationshipChanges[0].Relationship.OptionalCaveat.CaveatName)
+
+	// The original change is not modified.
+	require.Same(t, original, change.RelationshipChanges[0].Relationship.OptionalCaveat.Context)
+
+	decompressed, err := compressed.decompress()
+	require.NoError(t, err)
+	require.Equal(t, change.Revision, decompressed.Revision)
+	require.Len(t, decompressed.RelationshipChanges, 2)
+	require.True(t, proto.Equal(original, decompressed.RelationshipChanges[0].Relationship.OptionalCaveat.Context))
+	require.Equal(t, change.RelationshipChanges[1], decompressed.RelationshipChanges[1])
+}
+
+func TestCompressionPipeline(t *testing.T) {
+	ctx := context.Background()
+	produced := make(chan *datastore.RevisionChanges)
+	buffer := make(chan compressedChange, 4)
+	updates := make(chan *datastore.RevisionChanges)
+
+	go compressChanges(ctx, produced, buffer, 1024)
+	go decompressChanges(ctx, buffer, updates)
+
+	expected := make([]*datastore.RevisionChanges, 0, 10)
+	go func() {
+		defer close(produced)
+		for i := range 10 {
+			change := largeContextChange(t, i, 100)
+			expected = append(expected, change)
+			produced <- change
+		}
+	}()
+
+	received := make([]*datastore.RevisionChanges, 0, 10)
+	for change := range updates {
+		received = append(received, change)
+	}
+	require.Len(t, received, len(expected))
+	for i, change := range received {
+		require.Equal(t, expected[i].Revision, change.Revision)
+		require.True(t, proto.Equal(
+			expected[i].RelationshipChanges[0].Relationship.OptionalCaveat.Context,
+			change.RelationshipChanges[0].Relationship.OptionalCaveat.Context,
+		))
+	}
+}
+
+func TestCompressionPipelineCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	produced := make(chan *datastore.RevisionChanges)
+	buffer := make(chan compressedChange, 1)
+	updates := make(chan *datastore.RevisionChanges)
+
+	go compressChanges(ctx, produced, buffer, 1024)
+	go decompressChanges(ctx, buffer, updates)
+
+	produced <- largeContextChange(t, 1, 100)
+	produced <- largeContextChange(t, 2, 100)
+	cancel()
+
+	// Once canceled, the pipeline keeps consuming until the watch ends, and
+	// closes the updates channel without the consumer reading the changes.
+	produced <- largeContextChange(t, 3, 100)
+	close(produced)
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-updates:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCompressionMemorySavings(t *testing.T) {
+	const bufferLength = 128
+
+	heapAlloc := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	before := heapAlloc()
+	uncompressed := make([]*datastore.RevisionChanges, 0, bufferLength)
+	for i := range bufferLength {
+		uncompressed = append(uncompressed, largeContextChange(t, i, 1000))
+	}
+	uncompressedBytes := heapAlloc() - before
+	runtime.KeepAlive(uncompressed)
+	uncompressed = nil
+
+	before = heapAlloc()
+	compressed := make([]compressedChange, 0, bufferLength)
+	for i := range bufferLength {
+		compressed = append(compressed, compressChange(largeContextChange(t, i, 1000), 1024))
+	}
+	compressedBytes := heapAlloc() - before
+	runtime.KeepAlive(compressed)
+
+	t.Logf("buffer of %d changes: %d bytes uncompressed, %d bytes compressed", bufferLength, uncompressedBytes, compressedBytes)
+	require.Less(t, compressedBytes*4, uncompressedBytes)
+}
@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"context"
+	"time"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// sendWatchChange writes the change to the buffer of the watch, applying the
+// overflow policy if the buffer is full. The buffer must not be closed while
+// the change is being sent.
+func sendWatchChange(
+	ctx context.Context,
+	updates chan *datastore.RevisionChanges,
+	change *datastore.RevisionChanges,
+	registered *registeredWatch,
+	overflowPolicy string,
+	writeTimeout time.Duration,
+) error {
+	if err := registered.awaitBufferMemory(ctx, writeTimeout); err != nil {
+		return err
+	}
+
+	sent := func() error {
+		registered.delivered.Add(1)
+		registered.buffered(change)
+		return nil
+	}
+
+	select {
+	case updates <- change:
+		return sent()
+
+	default:
+		// If we cannot immediately write, apply the overflow policy.
+	}
+
+	switch overflowPolicy {
+	case WatchBufferOverflowError:
+		return datastore.NewWatchDisconnectedErr()
+
+	case WatchBufferOverflowDropOldest:
+		for {
+			// Discard a change only while the buffer is still full, since the
+			// consumer may have made room in the meantime.
+			select {
+			case <-updates:
+				// The size of the discarded change is released once the
+				// registry next settles the watch's buffer.
+				if registered.dropped.Add(1) == 1 {
+					log.Ctx(ctx).Warn().Uint64("watchID", registered.id).Msg("watch buffer is full; discarding the oldest buffered changes")
+				}
+			default:
+			}
+
+			select {
+			case updates <- change:
+				return sent()
+			default:
+			}
+		}
+	}
+
+	timer := time.NewTimer(writeTimeout)
+	defer timer.Stop()
+
+	select {
+	case updates <- change:
+		return sent()
+
+	case <-timer.C:
+		return datastore.NewWatchDisconnectedErr()
+	}
+}
@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestSendWatchChangeOverflowPolicies(t *testing.T) {
+	ctx := context.Background()
+	change := func(seconds int64) *datastore.RevisionChanges {
+		return &datastore.RevisionChanges{Revision: revisions.NewHLCForTime(time.Unix(seconds, 0))}
+	}
+
+	newWatch := func(t *testing.T, bufferLength int) (chan *datastore.RevisionChanges, *registeredWatch) {
+		updates := make(chan *datastore.RevisionChanges, bufferLength)
+		registered, err := newWatchRegistry(0, 1<<20).register(change(0).Revision, func() int { return len(updates) }, cap(updates))
+		require.NoError(t, err)
+		return updates, registered
+	}
+
+	t.Run("block", func(t *testing.T) {
+		updates, registered := newWatch(t, 1)
+		require.NoError(t, sendWatchChange(ctx, updates, change(1), registered, WatchBufferOverflowBlock, 50*time.Millisecond))
+
+		// The change waits for room in the buffer, for at most the timeout.
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			<-updates
+		}()
+		require.NoError(t, sendWatchChange(ctx, updates, change(2), registered, WatchBufferOverflowBlock, 5*time.Second))
+
+		started := time.Now()
+		err := sendWatchChange(ctx, updates, change(3), registered, WatchBufferOverflowBlock, 50*time.Millisecond)
+		require.ErrorAs(t, err, &datastore.WatchDisconnectedError{})
+		require.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		updates, registered := newWatch(t, 1)
+		require.NoError(t, sendWatchChange(ctx, updates, change(1), registered, WatchBufferOverflowError, 5*time.Second))
+
+		started := time.Now()
+		err := sendWatchChange(ctx, updates, change(2), registered, WatchBufferOverflowError, 5*time.Second)
+		require.ErrorAs(t, err, &datastore.WatchDisconnectedError{})
+		require.Less(t, time.Since(started), time.Second)
+		require.Equal(t, uint64(1), registered.delivered.Load())
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		updates, registered := newWatch(t, 2)
+		for i := int64(1); i <= 5; i++ {
+			require.NoError(t, sendWatchChange(ctx, updates, change(i), registered, WatchBufferOverflowDropOldest, 5*time.Second))
+		}
+
+		// The latest changes are kept.
+		require.Equal(t, uint64(5), registered.delivered.Load())
+		require.Equal(t, uint64(3), registered.dropped.Load())
+		require.True(t, change(4).Revision.Equal((<-updates).Revision))
+		require.True(t, change(5).Revision.Equal((<-updates).Revision))
+
+		// The sizes of the discarded changes are released with those received.
+		registered.registry.lock.Lock()
+		registered.registry.settleLocked()
+		registered.registry.lock.Unlock()
+		require.Zero(t, registered.registry.bufferedBytes.Load())
+
+		watches := registered.registry.snapshot(time.Now())
+		require.Equal(t, uint64(3), watches[0].EventsDropped)
+	})
+}
@@ -0,0 +1,277 @@
+package crdb
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// WatchInfo describes a watch being served by the datastore, as reported by
+// ActiveWatches.
+type WatchInfo struct {
+	// ID identifies the watch among those served by the datastore.
+	ID uint64
+
+	// StartRevision is the revision after which the watch reports changes.
+	StartRevision datastore.Revision
+
+	// StartedAt is the time at which the watch was started.
+	StartedAt time.Time
+
+	// Age is the time for which the watch has been running.
+	Age time.Duration
+
+	// EventsDelivered is the number of changes written to the watch's buffer.
+	EventsDelivered uint64
+
+	// EventsDropped is the number of changes discarded from the watch's
+	// buffer, per WatchBufferOverflowDropOldest, before its consumer received
+	// them.
+	EventsDropped uint64
+
+	// BufferedEvents is the number of changes in the watch's buffer that have
+	// not yet been received by its consumer.
+	BufferedEvents int
+
+	// BufferCapacity is the number of changes the watch's buffer can hold.
+	BufferCapacity int
+}
+
+// ActiveWatches returns a snapshot of the watches being served by the
+// datastore, in the order in which they were started. Collecting the snapshot
+// does not block the delivery of changes to the watches.
+func (cds *crdbDatastore) ActiveWatches(_ context.Context) ([]WatchInfo, error) {
+	if err := cds.checkOpen(); err != nil {
+		return nil, err
+	}
+	return cds.watches.snapshot(time.Now()), nil
+}
+
+// watchRegistry tracks the watches being served by the datastore, limiting
+// their number to MaxConcurrentWatches and the total size of the changes they
+// buffer to MaxTotalWatchBufferBytes, if set.
+type watchRegistry struct {
+	// limit is the maximum number of watches, or zero if they are unlimited.
+	limit int
+
+	// byteLimit is the maximum total size of the changes buffered by the
+	// watches, or zero if it is unlimited.
+	byteLimit int
+
+	// bufferedBytes is the estimated total size of the changes buffered by
+	// the watches.
+	bufferedBytes atomic.Int64
+
+	lock    sync.Mutex
+	nextID  uint64
+	watches map[uint64]*registeredWatch
+}
+
+// registeredWatch is the entry of a watch in the registry. Its delivered
+// count is updated atomically by the watch, so that the producer never waits
+// on the registry's lock.
+type registeredWatch struct {
+	registry      *watchRegistry
+	id            uint64
+	startRevision datastore.Revision
+	startedAt     time.Time
+	bufferLength  func() int
+	bufferCap     int
+	delivered     atomic.Uint64
+	dropped       atomic.Uint64
+
+	// sizes are the estimated sizes of the changes written to the buffer that
+	// may not yet have been received by the consumer, oldest first. They are
+	// only tracked if the registry limits the total size of the buffers.
+	sizesLock sync.Mutex
+	sizes     []int64
+}
+
+func newWatchRegistry(limit, byteLimit int) *watchRegistry {
+	return &watchRegistry{limit: limit, byteLimit: byteLimit, watches: map[uint64]*registeredWatch{}}
+}
+
+// register adds a watch to the registry, failing with a
+// datastore.TooManyWatchesError if the registry is already at its limit, or
+// with a datastore.WatchBufferMemoryExceededError if the watches already
+// buffer more than the registry's byte limit. The fill of the watch's buffer
+// is reported with bufferLength, which must not block.
+func (r *watchRegistry) register(startRevision datastore.Revision, bufferLength func() int, bufferCap int) (*registeredWatch, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.limit > 0 && len(r.watches) >= r.limit {
+		return nil, datastore.NewTooManyWatchesErr(r.limit)
+	}
+	if r.byteLimit > 0 {
+		r.settleLocked()
+		if r.bufferedBytes.Load() >= int64(r.byteLimit) {
+			return nil, datastore.NewWatchBufferMemoryExceededErr(r.byteLimit)
+		}
+	}
+
+	r.nextID++
+	watch := &registeredWatch{
+		registry:      r,
+		id:            r.nextID,
+		startRevision: startRevision,
+		startedAt:     time.Now(),
+		bufferLength:  bufferLength,
+		bufferCap:     bufferCap,
+	}
+	r.watches[watch.id] = watch
+	return watch, nil
+}
+
+func (r *watchRegistry) unregister(watch *registeredWatch) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.watches, watch.id)
+
+	// The changes left in the buffer of an ended watch are released with it.
+	watch.sizesLock.Lock()
+	defer watch.sizesLock.Unlock()
+	watch.release(len(watch.sizes))
+}
+
+// settleLocked releases the sizes of the changes received from the buffers of
+// all watches. It must be called with the registry's lock held.
+func (r *watchRegistry) settleLocked() {
+	for _, watch := range r.watches {
+		watch.settle()
+	}
+}
+
+// watchBufferPollInterval is the interval at which a watch waiting for the
+// total size of the buffers to fall below the limit checks it again.
+const watchBufferPollInterval = 10 * time.Millisecond
+
+// awaitBufferMemory applies back-pressure to the producer of the watch,
+// waiting until the watches buffer less than the registry's byte limit. A
+// watch whose own buffer is empty never waits, so that the watches whose
+// consumers keep up, and changes larger than the limit, are still delivered.
+// It fails with a datastore.WatchDisconnectedError if the wait exceeds the
+// timeout.
+func (w *registeredWatch) awaitBufferMemory(ctx context.Context, timeout time.Duration) error {
+	r := w.registry
+	if r.byteLimit <= 0 {
+		return nil
+	}
+
+	underLimit := func() bool {
+		r.lock.Lock()
+		r.settleLocked()
+		r.lock.Unlock()
+		return r.bufferedBytes.Load() < int64(r.byteLimit) || w.bufferLength() == 0
+	}
+	if underLimit() {
+		return nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(watchBufferPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if underLimit() {
+				return nil
+			}
+		case <-timer.C:
+			return datastore.NewWatchDisconnectedErr()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// buffered records the estimated size of a change written to the watch's
+// buffer, once it has been written.
+func (w *registeredWatch) buffered(change *datastore.RevisionChanges) {
+	if w.registry.byteLimit <= 0 {
+		return
+	}
+
+	size := estimateChangeSize(change)
+	w.sizesLock.Lock()
+	defer w.sizesLock.Unlock()
+	w.sizes = append(w.sizes, size)
+	w.registry.bufferedBytes.Add(size)
+}
+
+// settle releases the sizes of the changes that have been received from the
+// watch's buffer. The changes are received in the order in which they were
+// written, so those received are the oldest beyond the buffer's fill.
+func (w *registeredWatch) settle() {
+	w.sizesLock.Lock()
+	defer w.sizesLock.Unlock()
+	w.release(len(w.sizes) - w.bufferLength())
+}
+
+// release releases the sizes of the oldest count changes. It must be called
+// with the watch's sizes lock held.
+func (w *registeredWatch) release(count int) {
+	if count <= 0 {
+		return
+	}
+
+	var released int64
+	for _, size := range w.sizes[:count] {
+		released += size
+	}
+	w.sizes = w.sizes[count:]
+	w.registry.bufferedBytes.Add(-released)
+}
+
+// changeStructSize approximates the size of a change beyond that of its
+// contents.
+const changeStructSize = 128
+
+// estimateChangeSize returns the approximate size in memory of the change,
+// before any compression of its caveat contexts.
+func estimateChangeSize(change *datastore.RevisionChanges) int64 {
+	size := changeStructSize + proto.Size(change.Metadata)
+	for _, update := range change.RelationshipChanges {
+		size += update.Relationship.SizeVT()
+	}
+	for _, def := range change.ChangedDefinitions {
+		size += def.SizeVT()
+	}
+	for _, name := range change.DeletedNamespaces {
+		size += len(name)
+	}
+	for _, name := range change.DeletedCaveats {
+		size += len(name)
+	}
+	return int64(size)
+}
+
+func (r *watchRegistry) snapshot(now time.Time) []WatchInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	infos := make([]WatchInfo, 0, len(r.watches))
+	for _, watch := range r.watches {
+		infos = append(infos, WatchInfo{
+			ID:              watch.id,
+			StartRevision:   watch.startRevision,
+			StartedAt:       watch.startedAt,
+			Age:             now.Sub(watch.startedAt),
+			EventsDelivered: watch.delivered.Load(),
+			EventsDropped:   watch.dropped.Load(),
+			BufferedEvents:  watch.bufferLength(),
+			BufferCapacity:  watch.bufferCap,
+		})
+	}
+	slices.SortFunc(infos, func(a, b WatchInfo) int { return cmp.Compare(a.ID, b.ID) })
+	return infos
+}
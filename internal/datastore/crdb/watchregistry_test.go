@@ -0,0 +1,103 @@
+package crdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/revisions"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestWatchRegistry(t *testing.T) {
+	registry := newWatchRegistry(2, 0)
+
+	first := make(chan *datastore.RevisionChanges, 4)
+	firstWatch, err := registry.register(revisions.NewHLCForTime(time.Unix(1, 0)), func() int { return len(first) }, cap(first))
+	require.NoError(t, err)
+
+	second := make(chan *datastore.RevisionChanges, 8)
+	secondWatch, err := registry.register(revisions.NewHLCForTime(time.Unix(2, 0)), func() int { return len(second) }, cap(second))
+	require.NoError(t, err)
+
+	// The registry is at its limit.
+	_, err = registry.register(revisions.NewHLCForTime(time.Unix(3, 0)), func() int { return 0 }, 0)
+	require.ErrorAs(t, err, &datastore.TooManyWatchesError{})
+
+	first <- &datastore.RevisionChanges{}
+	first <- &datastore.RevisionChanges{}
+	firstWatch.delivered.Add(3)
+
+	now := firstWatch.startedAt.Add(time.Minute)
+	watches := registry.snapshot(now)
+	require.Len(t, watches, 2)
+	require.Equal(t, firstWatch.id, watches[0].ID)
+	require.Equal(t, uint64(3), watches[0].EventsDelivered)
+	require.Equal(t, 2, watches[0].BufferedEvents)
+	require.Equal(t, 4, watches[0].BufferCapacity)
+	require.Equal(t, time.Minute, watches[0].Age)
+	require.Equal(t, secondWatch.id, watches[1].ID)
+	require.Equal(t, 8, watches[1].BufferCapacity)
+
+	registry.unregister(firstWatch)
+	watches = registry.snapshot(now)
+	require.Len(t, watches, 1)
+	require.Equal(t, secondWatch.id, watches[0].ID)
+
+	_, err = registry.register(revisions.NewHLCForTime(time.Unix(3, 0)), func() int { return 0 }, 0)
+	require.NoError(t, err)
+}
+
+func TestWatchRegistryUnlimited(t *testing.T) {
+	registry := newWatchRegistry(0, 0)
+	for range 100 {
+		_, err := registry.register(revisions.NewHLCForTime(time.Unix(1, 0)), func() int { return 0 }, 0)
+		require.NoError(t, err)
+	}
+	require.Len(t, registry.snapshot(time.Now()), 100)
+}
+
+func TestWatchRegistryByteLimit(t *testing.T) {
+	change := &datastore.RevisionChanges{
+		RelationshipChanges: []tuple.RelationshipUpdate{tuple.Touch(tuple.MustParse("document:firstdoc#viewer@user:tom"))},
+	}
+	size := estimateChangeSize(change)
+	registry := newWatchRegistry(0, int(2*size))
+
+	first := make(chan *datastore.RevisionChanges, 4)
+	firstWatch, err := registry.register(revisions.NewHLCForTime(time.Unix(1, 0)), func() int { return len(first) }, cap(first))
+	require.NoError(t, err)
+
+	// A watch whose own buffer is empty never waits.
+	ctx := context.Background()
+	for range 2 {
+		require.NoError(t, firstWatch.awaitBufferMemory(ctx, time.Millisecond))
+		first <- change
+		firstWatch.buffered(change)
+	}
+	require.Equal(t, 2*size, registry.bufferedBytes.Load())
+
+	// Once the buffers are full, further watches are rejected, and watches
+	// with buffered changes wait for them to be received.
+	_, err = registry.register(revisions.NewHLCForTime(time.Unix(2, 0)), func() int { return 0 }, 0)
+	require.ErrorAs(t, err, &datastore.WatchBufferMemoryExceededError{})
+	require.ErrorAs(t, firstWatch.awaitBufferMemory(ctx, 50*time.Millisecond), &datastore.WatchDisconnectedError{})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-first
+	}()
+	require.NoError(t, firstWatch.awaitBufferMemory(ctx, time.Second))
+	require.Equal(t, size, registry.bufferedBytes.Load())
+
+	second, err := registry.register(revisions.NewHLCForTime(time.Unix(2, 0)), func() int { return 0 }, 0)
+	require.NoError(t, err)
+
+	// The changes left in the buffer of an ended watch are released.
+	registry.unregister(firstWatch)
+	registry.unregister(second)
+	require.Zero(t, registry.bufferedBytes.Load())
+}
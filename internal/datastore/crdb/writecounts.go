@@ -0,0 +1,144 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/options"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// WriteCounts are the numbers of relationships changed by a write, by
+// operation.
+type WriteCounts struct {
+	// Created is the number of relationships created by create mutations.
+	// Under the upsert duplicate write policy, those that already existed and
+	// were updated are not created, and are counted as skipped.
+	Created uint64
+
+	// Touched is the number of relationships created or updated by touch
+	// mutations.
+	Touched uint64
+
+	// Deleted is the number of relationships deleted by delete mutations.
+	Deleted uint64
+
+	// Skipped is the number of mutations that created, touched or deleted no
+	// relationship: creates of existing relationships under the ignore or
+	// upsert duplicate write policies, touches of unchanged relationships and
+	// deletes of missing relationships.
+	Skipped uint64
+}
+
+func (wc *WriteCounts) add(other WriteCounts) {
+	wc.Created += other.Created
+	wc.Touched += other.Touched
+	wc.Deleted += other.Deleted
+	wc.Skipped += other.Skipped
+}
+
+// skippedMutations returns the number of the mutations that changed no
+// relationship, whatever the duplicate write policy, given the counts of the
+// relationships they created, touched and deleted. The rows affected need not
+// match the mutations one to one, so the difference is clamped at zero rather
+// than allowed to wrap around.
+func skippedMutations(mutations int, counts WriteCounts) uint64 {
+	changed := counts.Created + counts.Touched + counts.Deleted
+	// nolint:gosec
+	if total := uint64(mutations); total > changed {
+		return total - changed
+	}
+	return 0
+}
+
+// WriteRelationshipsWithCounts writes the mutations in a single read-write
+// transaction, as WriteRelationships does, and returns the revision of the
+// write along with the numbers of relationships it changed. A write of more
+// mutations than MaxTuplesPerWrite allows is rejected before the transaction
+// is opened.
+func (cds *crdbDatastore) WriteRelationshipsWithCounts(
+	ctx context.Context,
+	mutations []tuple.RelationshipUpdate,
+	opts ...options.RWTOptionsOption,
+) (datastore.Revision, WriteCounts, error) {
+	if err := cds.checkTuplesPerWrite(mutations); err != nil {
+		return datastore.NoRevision, WriteCounts{}, err
+	}
+	return cds.writeRelationshipsWithCounts(ctx, mutations, opts...)
+}
+
+// checkTuplesPerWrite returns a datastore.TooManyUpdatesError if the write of
+// the mutations exceeds MaxTuplesPerWrite.
+func (cds *crdbDatastore) checkTuplesPerWrite(mutations []tuple.RelationshipUpdate) error {
+	if cds.maxTuplesPerWrite > 0 && len(mutations) > cds.maxTuplesPerWrite {
+		return datastore.NewTooManyUpdatesErr(len(mutations), cds.maxTuplesPerWrite)
+	}
+	return nil
+}
+
+func (cds *crdbDatastore) writeRelationshipsWithCounts(
+	ctx context.Context,
+	mutations []tuple.RelationshipUpdate,
+	opts ...options.RWTOptionsOption,
+) (datastore.Revision, WriteCounts, error) {
+	var counts WriteCounts
+	revision, err := cds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		crdbRWT, ok := rwt.(*crdbReadWriteTXN)
+		if !ok {
+			return spiceerrors.MustBugf("unexpected read-write transaction type %T", rwt)
+		}
+
+		if err := crdbRWT.WriteRelationships(ctx, mutations); err != nil {
+			return err
+		}
+
+		// The transaction function runs again on retry, so only the counts of
+		// the attempt that commits are kept.
+		counts = crdbRWT.writeCounts
+		return nil
+	}, opts...)
+	if err != nil {
+		return datastore.NoRevision, WriteCounts{}, err
+	}
+	return revision, counts, nil
+}
+
+// WriteRelationshipsInBatches writes the mutations in consecutive read-write
+// transactions of at most the number of mutations set by
+// AllowTransactionSplitting each, or in a single transaction if splitting is
+// not allowed, returning the revision of the last transaction along with the
+// numbers of relationships changed by all of them.
+//
+// WARNING: the write is not atomic. Each batch is committed before the next is
+// written, and remains committed if a later batch fails, in which case the
+// revision and counts of the batches that were committed are returned along
+// with the error. A write of more mutations than MaxTuplesPerWrite allows is
+// rejected as a whole, before it is split.
+func (cds *crdbDatastore) WriteRelationshipsInBatches(
+	ctx context.Context,
+	mutations []tuple.RelationshipUpdate,
+	opts ...options.RWTOptionsOption,
+) (datastore.Revision, WriteCounts, error) {
+	if err := cds.checkTuplesPerWrite(mutations); err != nil {
+		return datastore.NoRevision, WriteCounts{}, err
+	}
+
+	batchSize := cds.maxRowsPerTransaction
+	if batchSize <= 0 {
+		batchSize = len(mutations)
+	}
+
+	revision := datastore.NoRevision
+	var total WriteCounts
+	for start := 0; start < len(mutations); start += batchSize {
+		batchRevision, counts, err := cds.writeRelationshipsWithCounts(ctx, mutations[start:min(start+batchSize, len(mutations))], opts...)
+		if err != nil {
+			return revision, total, fmt.Errorf("unable to write the batch starting at mutation %d, after committing the mutations before it: %w", start, err)
+		}
+		revision = batchRevision
+		total.add(counts)
+	}
+	return revision, total, nil
+}
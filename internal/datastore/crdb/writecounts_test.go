@@ -0,0 +1,29 @@
+package crdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkippedMutations(t *testing.T) {
+	tcs := []struct {
+		name      string
+		mutations int
+		counts    WriteCounts
+		expected  uint64
+	}{
+		{"no mutations", 0, WriteCounts{}, 0},
+		{"all changed", 3, WriteCounts{Created: 1, Touched: 1, Deleted: 1}, 0},
+		{"some skipped", 4, WriteCounts{Created: 1, Deleted: 1}, 2},
+		{"all skipped", 2, WriteCounts{}, 2},
+		{"deletes affecting more rows than mutations", 2, WriteCounts{Deleted: 5}, 0},
+		{"creates and deletes affecting more rows than mutations", 3, WriteCounts{Created: 2, Deleted: 4}, 0},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, skippedMutations(tc.mutations, tc.counts))
+		})
+	}
+}
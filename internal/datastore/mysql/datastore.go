@@ -269,6 +269,7 @@ func newMySQLDatastore(ctx context.Context, uri string, replicaIndex int, option
 		revisionQuantization:    config.revisionQuantization,
 		gcWindow:                config.gcWindow,
 		gcInterval:              config.gcInterval,
+		gcIntervalJitter:        config.gcIntervalJitter,
 		gcTimeout:               config.gcMaxOperationTime,
 		gcCtx:                   gcCtx,
 		cancelGc:                cancelGc,
@@ -310,6 +311,7 @@ func newMySQLDatastore(ctx context.Context, uri string, replicaIndex int, option
 					store.gcCtx,
 					store,
 					store.gcInterval,
+					store.gcIntervalJitter,
 					store.gcWindow,
 					store.gcTimeout,
 				)
@@ -485,6 +487,7 @@ type Datastore struct {
 	revisionQuantization    time.Duration
 	gcWindow                time.Duration
 	gcInterval              time.Duration
+	gcIntervalJitter        float64
 	gcTimeout               time.Duration
 	watchBufferLength       uint16
 	watchBufferWriteTimeout time.Duration
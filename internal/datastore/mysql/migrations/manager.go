@@ -1,15 +1,24 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"regexp"
 
+	sqlDriver "github.com/go-sql-driver/mysql"
+
+	log "github.com/authzed/spicedb/internal/logging"
 	"github.com/authzed/spicedb/pkg/migrate"
 )
 
 const migrationNamePattern = `^[_a-zA-Z]*$`
 
+// reservedPrefixes are the prefixes with which a migration version cannot
+// begin, since the versions must be valid MySQL column names that do not
+// need quoting. They are also enforced by migrationNamePattern.
+var reservedPrefixes = []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+
 var (
 	noNonatomicMigration migrate.MigrationFunc[Wrapper]
 	noTxMigration        migrate.TxMigrationFunc[TxWrapper] // nolint: deadcode, unused, varcheck
@@ -17,9 +26,29 @@ var (
 	migrationNameRe = regexp.MustCompile(migrationNamePattern)
 
 	// Manager is the singleton migration manager instance for MySQL
-	Manager = migrate.NewManager[*MySQLDriver, Wrapper, TxWrapper]()
+	Manager = migrate.NewManager[*MySQLDriver, Wrapper, TxWrapper](migrate.WithReservedPrefixes(reservedPrefixes...))
 )
 
+func init() {
+	if err := migrate.RegisterEngine("mysql", migrate.Engine{
+		NewRunner: func(_ context.Context, uri string, options migrate.RunnerOptions) (migrate.Runner, error) {
+			// Do this outside NewMySQLDriverFromDSN to avoid races on MySQL datastore tests
+			if err := sqlDriver.SetLogger(&log.Logger); err != nil {
+				return nil, fmt.Errorf("unable to set logging to mysql driver: %w", err)
+			}
+
+			driver, err := NewMySQLDriverFromDSN(uri, options.TablePrefix, options.CredentialsProvider)
+			if err != nil {
+				return nil, err
+			}
+			return migrate.NewRunner(Manager, driver), nil
+		},
+		HeadRevision: Manager.HeadRevision,
+	}); err != nil {
+		panic("failed to register migrations: " + err.Error())
+	}
+}
+
 // Wrapper makes it possible to forward the table schema needed for MySQL MigrationFunc to run
 type Wrapper struct {
 	db     *sql.DB
@@ -38,16 +67,39 @@ func mustRegisterMigration(version, replaces string, up migrate.MigrationFunc[Wr
 	}
 }
 
+// registerMigration registers the migration with the Manager; see
+// registerMigrationWith.
 func registerMigration(version, replaces string, up migrate.MigrationFunc[Wrapper], upTx migrate.TxMigrationFunc[TxWrapper]) error {
+	return registerMigrationWith(Manager, version, replaces, up, upTx)
+}
+
+// registerMigrationWith registers the migration to version, which replaces the
+// migration to replaces, with the manager. The migration it replaces must
+// already be registered, unless it is the first, so that the migrations are
+// registered in order and a migration cannot be made to follow one that does
+// not exist, or itself. Registering a version twice is an error.
+func registerMigrationWith(m *migrate.Manager[*MySQLDriver, Wrapper, TxWrapper], version, replaces string, up migrate.MigrationFunc[Wrapper], upTx migrate.TxMigrationFunc[TxWrapper]) error {
 	// validate migration names to ensure they are compatible with mysql column names
 	for _, v := range []string{version, replaces} {
-		if match := migrationNameRe.MatchString(version); !match {
+		if match := migrationNameRe.MatchString(v); !match {
 			return fmt.Errorf("migration from '%s' to '%s': '%s' is an invalid mysql migration version, expected to match pattern '%s'",
 				replaces, version, v, migrationNamePattern,
 			)
 		}
 	}
 
+	if version == replaces {
+		return fmt.Errorf("migration '%s' cannot replace itself", version)
+	}
+
+	if m.IsRegistered(version) {
+		return fmt.Errorf("migration '%s' is already registered", version)
+	}
+
+	if replaces != "" && !m.IsRegistered(replaces) {
+		return fmt.Errorf("migration from '%s' to '%s': the migration it replaces, '%s', is not registered", replaces, version, replaces)
+	}
+
 	// register the migration
-	return Manager.Register(version, replaces, up, upTx)
+	return m.Register(version, replaces, up, upTx)
 }
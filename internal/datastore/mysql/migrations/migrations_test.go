@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/migrate"
 )
 
 func TestMySQLMigrationsWithUnsupportedPrefix(t *testing.T) {
@@ -17,3 +19,43 @@ func TestMySQLMigrationsWithUnsupportedPrefix(t *testing.T) {
 	}, noTxMigration)
 	req.Error(err)
 }
+
+func TestMySQLReservedPrefixes(t *testing.T) {
+	req := require.New(t)
+	req.True(Manager.IsReservedPrefix("888"))
+	req.False(Manager.IsReservedPrefix("add_expiration"))
+}
+
+func TestMySQLMigrationOrdering(t *testing.T) {
+	noop := func(ctx context.Context, d Wrapper) error { return nil }
+
+	testCases := []struct {
+		name     string
+		version  string
+		replaces string
+		expected string
+	}{
+		{"first migration", "initial", "", ""},
+		{"follows a registered migration", "add_columns", "initial", ""},
+		{"missing predecessor", "add_index", "add_columnz", "the migration it replaces, 'add_columnz', is not registered"},
+		{"duplicate version", "add_columns", "initial", "migration 'add_columns' is already registered"},
+		{"cycle through a registered migration", "initial", "add_columns", "migration 'initial' is already registered"},
+		{"cycle to itself", "loop", "loop", "migration 'loop' cannot replace itself"},
+	}
+
+	m := migrate.NewManager[*MySQLDriver, Wrapper, TxWrapper](migrate.WithReservedPrefixes(reservedPrefixes...))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := registerMigrationWith(m, tc.version, tc.replaces, noop, noTxMigration)
+			if tc.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.expected)
+		})
+	}
+
+	head, err := m.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, "add_columns", head)
+}
@@ -13,6 +13,7 @@ const (
 
 	defaultGarbageCollectionWindow           = 24 * time.Hour
 	defaultGarbageCollectionInterval         = time.Minute * 3
+	defaultGarbageCollectionIntervalJitter   = 0.1
 	defaultGarbageCollectionMaxOperationTime = time.Minute
 	defaultMaxOpenConns                      = 20
 	defaultConnMaxIdleTime                   = 30 * time.Minute
@@ -34,6 +35,7 @@ type mysqlOptions struct {
 	revisionQuantization        time.Duration
 	gcWindow                    time.Duration
 	gcInterval                  time.Duration
+	gcIntervalJitter            float64
 	gcMaxOperationTime          time.Duration
 	maxRevisionStalenessPercent float64
 	watchBufferLength           uint16
@@ -62,6 +64,7 @@ func generateConfig(options []Option) (mysqlOptions, error) {
 	computed := mysqlOptions{
 		gcWindow:                    defaultGarbageCollectionWindow,
 		gcInterval:                  defaultGarbageCollectionInterval,
+		gcIntervalJitter:            defaultGarbageCollectionIntervalJitter,
 		gcMaxOperationTime:          defaultGarbageCollectionMaxOperationTime,
 		watchBufferLength:           defaultWatchBufferLength,
 		watchBufferWriteTimeout:     defaultWatchBufferWriteTimeout,
@@ -92,6 +95,10 @@ func generateConfig(options []Option) (mysqlOptions, error) {
 		)
 	}
 
+	if computed.gcIntervalJitter < 0 || computed.gcIntervalJitter >= 1 {
+		return computed, fmt.Errorf("gc interval jitter (%v) must be at least 0 and less than 1", computed.gcIntervalJitter)
+	}
+
 	if computed.filterMaximumIDCount == 0 {
 		computed.filterMaximumIDCount = 100
 		log.Warn().Msg("filterMaximumIDCount not set, defaulting to 100")
@@ -156,6 +163,17 @@ func GCInterval(interval time.Duration) Option {
 	}
 }
 
+// GCIntervalJitter is the fraction of the GC interval by which each interval
+// is randomly lengthened or shortened, so that the garbage collection passes
+// of multiple instances sharing a database do not synchronize.
+//
+// This value defaults to 0.1.
+func GCIntervalJitter(fraction float64) Option {
+	return func(mo *mysqlOptions) {
+		mo.gcIntervalJitter = fraction
+	}
+}
+
 // MaxRetries is the maximum number of times a retriable transaction will be
 // client-side retried.
 //
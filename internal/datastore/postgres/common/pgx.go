@@ -215,6 +215,11 @@ type PoolOptions struct {
 	ConnHealthCheckInterval *time.Duration
 	MinOpenConns            *int
 	MaxOpenConns            *int
+
+	// MinIdleConns is the number of idle connections the pool keeps ready to
+	// serve bursts. The pinned pgx predates pgxpool.Config.MinIdleConns, so it
+	// is not applied by ConfigurePgx, and must be maintained by the pool.
+	MinIdleConns *int
 }
 
 // ConfigurePgx applies PoolOptions to a pgx connection pool confiugration.
@@ -139,6 +139,70 @@ func (pgd *pgDatastore) DeleteBeforeTx(ctx context.Context, txID datastore.Revis
 	return removed, err
 }
 
+// ExpiredTupleCount returns the number of relationships that are eligible for
+// garbage collection but have not yet been collected: those deleted by a
+// transaction that completed before the start of the GC window, and those
+// which expired before the start of the GC window.
+//
+// The counts are computed with predicates served by the GC indexes, and are
+// bounded by the GC operation timeout, if any.
+func (pgd *pgDatastore) ExpiredTupleCount(ctx context.Context) (int64, error) {
+	if pgd.gcTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pgd.gcTimeout)
+		defer cancel()
+	}
+
+	now, err := pgd.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	watermark, err := pgd.TxIDBefore(ctx, now.Add(-1*pgd.gcWindow))
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving watermark: %w", err)
+	}
+	minTxAlive := newXid8(watermark.(postgresRevision).snapshot.xmin)
+
+	// The second predicate matches that of the partial GC index.
+	deletedCount, err := pgd.countRelationships(ctx, sq.And{
+		sq.Lt{colDeletedXid: minTxAlive},
+		sq.Lt{colDeletedXid: liveDeletedTxnID},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to count deleted relationships: %w", err)
+	}
+
+	if pgd.schema.ExpirationDisabled {
+		return deletedCount, nil
+	}
+
+	// Relationships which have expired and also been deleted are already
+	// included in the deleted count.
+	expiredCount, err := pgd.countRelationships(ctx, sq.And{
+		sq.Lt{colExpiration: now.Add(-1 * pgd.gcWindow)},
+		sq.GtOrEq{colDeletedXid: minTxAlive},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to count expired relationships: %w", err)
+	}
+
+	return deletedCount + expiredCount, nil
+}
+
+func (pgd *pgDatastore) countRelationships(ctx context.Context, filter sqlFilter) (int64, error) {
+	sql, args, err := psql.Select("COUNT(*)").From(tableTuple).Where(filter).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := pgd.readPool.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (pgd *pgDatastore) batchDelete(
 	ctx context.Context,
 	tableName string,
@@ -1,6 +1,8 @@
 package migrations
 
 import (
+	"context"
+
 	"github.com/jackc/pgx/v5"
 
 	"github.com/authzed/spicedb/pkg/migrate"
@@ -13,3 +15,22 @@ var (
 
 // DatabaseMigrations implements a migration manager for the Postgres Driver.
 var DatabaseMigrations = migrate.NewManager[*AlembicPostgresDriver, *pgx.Conn, pgx.Tx]()
+
+func init() {
+	if err := migrate.RegisterEngine("postgres", migrate.Engine{
+		NewRunner: func(ctx context.Context, uri string, options migrate.RunnerOptions) (migrate.Runner, error) {
+			driver, err := NewAlembicPostgresDriver(ctx, uri, options.CredentialsProvider, false)
+			if err != nil {
+				return nil, err
+			}
+			return migrate.NewRunner(DatabaseMigrations, driver), nil
+		},
+		HeadRevision: DatabaseMigrations.HeadRevision,
+		ValidateURI: func(uri string) error {
+			_, err := pgx.ParseConfig(uri)
+			return err
+		},
+	}); err != nil {
+		panic("failed to register migrations: " + err.Error())
+	}
+}
@@ -21,6 +21,7 @@ type postgresOptions struct {
 	revisionQuantization    time.Duration
 	gcWindow                time.Duration
 	gcInterval              time.Duration
+	gcIntervalJitter        float64
 	gcMaxOperationTime      time.Duration
 	maxRetries              uint8
 	filterMaximumIDCount    uint16
@@ -62,6 +63,7 @@ const (
 	defaultWatchBufferWriteTimeout           = 1 * time.Second
 	defaultGarbageCollectionWindow           = 24 * time.Hour
 	defaultGarbageCollectionInterval         = time.Minute * 3
+	defaultGarbageCollectionIntervalJitter   = 0.1
 	defaultGarbageCollectionMaxOperationTime = time.Minute
 	defaultQuantization                      = 5 * time.Second
 	defaultMaxRevisionStalenessPercent       = 0.1
@@ -84,6 +86,7 @@ func generateConfig(options []Option) (postgresOptions, error) {
 	computed := postgresOptions{
 		gcWindow:                       defaultGarbageCollectionWindow,
 		gcInterval:                     defaultGarbageCollectionInterval,
+		gcIntervalJitter:               defaultGarbageCollectionIntervalJitter,
 		gcMaxOperationTime:             defaultGarbageCollectionMaxOperationTime,
 		watchBufferLength:              defaultWatchBufferLength,
 		watchBufferWriteTimeout:        defaultWatchBufferWriteTimeout,
@@ -118,6 +121,10 @@ func generateConfig(options []Option) (postgresOptions, error) {
 		return computed, fmt.Errorf("unknown migration phase: %s", computed.migrationPhase)
 	}
 
+	if computed.gcIntervalJitter < 0 || computed.gcIntervalJitter >= 1 {
+		return computed, fmt.Errorf("gc interval jitter (%v) must be at least 0 and less than 1", computed.gcIntervalJitter)
+	}
+
 	if computed.filterMaximumIDCount == 0 {
 		computed.filterMaximumIDCount = 100
 		log.Warn().Msg("filterMaximumIDCount not set, defaulting to 100")
@@ -305,6 +312,15 @@ func GCInterval(interval time.Duration) Option {
 	return func(po *postgresOptions) { po.gcInterval = interval }
 }
 
+// GCIntervalJitter is the fraction of the GC interval by which each interval
+// is randomly lengthened or shortened, so that the garbage collection passes
+// of multiple instances sharing a database do not synchronize.
+//
+// This value defaults to 0.1.
+func GCIntervalJitter(fraction float64) Option {
+	return func(po *postgresOptions) { po.gcIntervalJitter = fraction }
+}
+
 // GCMaxOperationTime is the maximum operation time of a garbage collection
 // pass before it times out.
 //
@@ -348,6 +348,7 @@ func newPostgresDatastore(
 		validTransactionQuery:   validTransactionQuery,
 		gcWindow:                config.gcWindow,
 		gcInterval:              config.gcInterval,
+		gcIntervalJitter:        config.gcIntervalJitter,
 		gcTimeout:               config.gcMaxOperationTime,
 		analyzeBeforeStatistics: config.analyzeBeforeStatistics,
 		watchEnabled:            watchEnabled,
@@ -381,6 +382,7 @@ func newPostgresDatastore(
 					datastore.gcCtx,
 					datastore,
 					datastore.gcInterval,
+					datastore.gcIntervalJitter,
 					datastore.gcWindow,
 					datastore.gcTimeout,
 				)
@@ -405,6 +407,7 @@ type pgDatastore struct {
 	validTransactionQuery          string
 	gcWindow                       time.Duration
 	gcInterval                     time.Duration
+	gcIntervalJitter               float64
 	gcTimeout                      time.Duration
 	analyzeBeforeStatistics        bool
 	readTxOptions                  pgx.TxOptions
@@ -642,6 +642,14 @@ func GarbageCollectionByTimeTest(t *testing.T, ds datastore.Datastore) {
 	})
 	require.NoError(err)
 
+	// Sleep 1ms to ensure the deletion falls outside of the GC window.
+	time.Sleep(1 * time.Millisecond)
+
+	// Ensure the deleted relationship is counted as eligible for GC.
+	eligible, err := pds.ExpiredTupleCount(ctx)
+	require.NoError(err)
+	require.Equal(int64(1), eligible)
+
 	// Run GC and ensure the relationship is not removed.
 	afterDelete, err := pds.Now(ctx)
 	require.NoError(err)
@@ -655,6 +663,10 @@ func GarbageCollectionByTimeTest(t *testing.T, ds datastore.Datastore) {
 	require.Equal(int64(2), removed.Transactions) // relDeletedAt, injected
 	require.Zero(removed.Namespaces)
 
+	eligible, err = pds.ExpiredTupleCount(ctx)
+	require.NoError(err)
+	require.Zero(eligible)
+
 	// Ensure the relationship is still not present.
 	tRequire.NoRelationshipExists(ctx, rel, relDeletedAt)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"sync/atomic"
 
@@ -12,11 +13,13 @@ import (
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/datastore/options"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 // NewCheckingReplicatedDatastore creates a new datastore that writes to the provided primary and reads
 // from the provided replicas. The replicas are chosen in a round-robin fashion. If a replica does
-// not have the requested revision, the primary is used instead.
+// not have the requested revision, the primary is used instead; if it cannot be reached, the other
+// replicas are tried in turn before the primary.
 //
 // NOTE: Be *very* careful when using this function. It is not safe to use this function without
 // knowledge of the layout of the underlying datastore and its replicas.
@@ -46,7 +49,8 @@ func NewCheckingReplicatedDatastore(primary datastore.Datastore, replicas ...dat
 
 // NewStrictReplicatedDatastore creates a new datastore that writes to the provided primary and reads
 // from the provided replicas. The replicas are chosen in a round-robin fashion. If a replica does
-// not have the requested revision, the primary is used instead.
+// not have the requested revision, the primary is used instead; if it cannot be reached, the read is
+// retried against the other replicas in turn before the primary.
 //
 // Unlike NewCheckingReplicatedDatastore, this function does not check the replicas for the requested
 // revision before reading from them; instead, a revision check is inserted into the SQL for each read.
@@ -77,21 +81,29 @@ func NewStrictReplicatedDatastore(primary datastore.Datastore, replicas ...datas
 	}, nil
 }
 
-func selectReplica[T any](replicas []T, lastReplica *uint64) T {
-	if len(replicas) == 1 {
-		return replicas[0]
+// selectReplica chooses the index of the replica with which a read starts, in
+// a round-robin fashion.
+func selectReplica(replicaCount int, lastReplica *uint64) int {
+	if replicaCount == 1 {
+		return 0
 	}
 
 	var swapped bool
 	var next uint64
 	for !swapped {
 		last := *lastReplica
-		next = (*lastReplica + 1) % uint64(len(replicas))
+		next = (*lastReplica + 1) % uint64(replicaCount)
 		swapped = atomic.CompareAndSwapUint64(lastReplica, last, next)
 	}
 
 	log.Trace().Uint64("replica", next).Msg("choosing replica for read")
-	return replicas[next]
+	return int(next)
+}
+
+// replicaAt returns the replica tried attempt'th by a read that starts with the
+// replica at index first, wrapping around the replicas.
+func replicaAt(replicas []datastore.ReadOnlyDatastore, first, attempt int) datastore.ReadOnlyDatastore {
+	return replicas[(first+attempt)%len(replicas)]
 }
 
 type checkingReplicatedDatastore struct {
@@ -101,14 +113,18 @@ type checkingReplicatedDatastore struct {
 	lastReplica uint64
 }
 
+func (rd *checkingReplicatedDatastore) Unwrap() datastore.Datastore {
+	return rd.Datastore
+}
+
 // SnapshotReader creates a read-only handle that reads the datastore at the specified revision.
 // Any errors establishing the reader will be returned by subsequent calls.
 func (rd *checkingReplicatedDatastore) SnapshotReader(revision datastore.Revision) datastore.Reader {
-	replica := selectReplica(rd.replicas, &rd.lastReplica)
 	return &checkingStableReader{
-		rev:     revision,
-		replica: replica,
-		primary: rd.Datastore,
+		rev:      revision,
+		replicas: rd.replicas,
+		first:    selectReplica(len(rd.replicas), &rd.lastReplica),
+		primary:  rd.Datastore,
 	}
 }
 
@@ -119,27 +135,34 @@ type strictReplicatedDatastore struct {
 	lastReplica uint64
 }
 
+func (rd *strictReplicatedDatastore) Unwrap() datastore.Datastore {
+	return rd.Datastore
+}
+
 // SnapshotReader creates a read-only handle that reads the datastore at the specified revision.
 // Any errors establishing the reader will be returned by subsequent calls.
 func (rd *strictReplicatedDatastore) SnapshotReader(revision datastore.Revision) datastore.Reader {
-	replica := selectReplica(rd.replicas, &rd.lastReplica)
 	return &strictReadReplicatedReader{
-		rev:     revision,
-		replica: replica,
-		primary: rd.Datastore,
+		rev:      revision,
+		replicas: rd.replicas,
+		first:    selectReplica(len(rd.replicas), &rd.lastReplica),
+		primary:  rd.Datastore,
 	}
 }
 
 // checkingStableReader is a reader that will check the replica for the requested revision before
 // reading from it. If the replica does not have the requested revision, the primary will be used
-// instead. Only supported for a stable replica within each pool.
+// instead. If the replica cannot be reached, the other replicas are checked in turn, and then the
+// primary is used; a relationship query against a replica that can no longer be reached once it is
+// iterated is rerun against the primary. Only supported for a stable replica within each pool.
 type checkingStableReader struct {
-	rev     datastore.Revision
-	replica datastore.ReadOnlyDatastore
-	primary datastore.Datastore
+	rev      datastore.Revision
+	replicas []datastore.ReadOnlyDatastore
+	first    int
+	primary  datastore.Datastore
 
-	// chosePrimaryForTest is used for testing to determine if the primary was used for the read.
-	chosePrimaryForTest bool
+	// chosePrimary is whether the primary was chosen for the reads, rather than a replica.
+	chosePrimary bool
 
 	chosenReader datastore.Reader
 	choose       sync.Once
@@ -174,11 +197,9 @@ func (rr *checkingStableReader) QueryRelationships(
 	filter datastore.RelationshipsFilter,
 	options ...options.QueryOptionsOption,
 ) (datastore.RelationshipIterator, error) {
-	if err := rr.determineSource(ctx); err != nil {
-		return nil, err
-	}
-
-	return rr.chosenReader.QueryRelationships(ctx, filter, options...)
+	return rr.queryWithFallback(ctx, func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+		return reader.QueryRelationships(ctx, filter, options...)
+	})
 }
 
 func (rr *checkingStableReader) ReverseQueryRelationships(
@@ -186,11 +207,9 @@ func (rr *checkingStableReader) ReverseQueryRelationships(
 	subjectsFilter datastore.SubjectsFilter,
 	options ...options.ReverseQueryOptionsOption,
 ) (datastore.RelationshipIterator, error) {
-	if err := rr.determineSource(ctx); err != nil {
-		return nil, err
-	}
-
-	return rr.chosenReader.ReverseQueryRelationships(ctx, subjectsFilter, options...)
+	return rr.queryWithFallback(ctx, func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+		return reader.ReverseQueryRelationships(ctx, subjectsFilter, options...)
+	})
 }
 
 func (rr *checkingStableReader) ReadNamespaceByName(ctx context.Context, nsName string) (ns *core.NamespaceDefinition, lastWritten datastore.Revision, err error) {
@@ -233,76 +252,222 @@ func (rr *checkingStableReader) LookupCounters(ctx context.Context) ([]datastore
 	return rr.chosenReader.LookupCounters(ctx)
 }
 
+// queryWithFallback returns an iterator over the relationships of the query, run against the chosen
+// source. The iterators of the datastores only connect to them once iterated, so a replica that has
+// become unreachable since it was checked is usually only found when iterating: if the query against
+// the replica fails for that reason, when run or before yielding its first relationship, it is rerun
+// against the primary.
+func (rr *checkingStableReader) queryWithFallback(ctx context.Context, query func(datastore.Reader) (datastore.RelationshipIterator, error)) (datastore.RelationshipIterator, error) {
+	if err := rr.determineSource(ctx); err != nil {
+		return nil, err
+	}
+
+	source := func(attempt int) datastore.Reader {
+		if attempt > 0 {
+			return rr.primary.SnapshotReader(rr.rev)
+		}
+		return rr.chosenReader
+	}
+	next := func(attempt int, err error) (int, bool) {
+		if attempt > 0 || rr.chosePrimary || !isReplicaUnavailable(err) {
+			return 0, false
+		}
+		log.Warn().Str("revision", rr.rev.String()).Err(err).Msg("replica is unavailable, using primary")
+		return 1, true
+	}
+	return failoverRelationships(source, next, query), nil
+}
+
 // determineSource will choose the replica or primary to read from based on the revision, by checking
 // if the replica contains the revision. If the replica does not contain the revision, the primary
-// will be used instead.
+// will be used instead. If the replica cannot be reached, the next replica is checked, until each has
+// been checked once, after which the primary is used.
 func (rr *checkingStableReader) determineSource(ctx context.Context) error {
 	var finalError error
 	rr.choose.Do(func() {
-		// If the revision is not known to the replica, use the primary instead.
-		if err := rr.replica.CheckRevision(ctx, rr.rev); err != nil {
+		for attempt := range len(rr.replicas) {
+			replica := replicaAt(rr.replicas, rr.first, attempt)
+
+			// If the revision is not known to the replica, use the primary instead.
+			err := replica.CheckRevision(ctx, rr.rev)
+			if err == nil {
+				log.Trace().Str("revision", rr.rev.String()).Msg("replica contains the requested revision")
+				rr.chosenReader = replica.SnapshotReader(rr.rev)
+				rr.chosePrimary = false
+				return
+			}
+
 			var irr datastore.InvalidRevisionError
 			if errors.As(err, &irr) {
-				if irr.Reason() == datastore.CouldNotDetermineRevision {
+				if irr.Reason() == datastore.CouldNotDetermineRevision || irr.Reason() == datastore.RevisionInFuture {
 					log.Trace().Str("revision", rr.rev.String()).Err(err).Msg("replica does not contain the requested revision, using primary")
 					rr.chosenReader = rr.primary.SnapshotReader(rr.rev)
-					rr.chosePrimaryForTest = true
+					rr.chosePrimary = true
 					return
 				}
 			}
-			finalError = err
-			return
+			if !isReplicaUnavailable(err) {
+				finalError = err
+				return
+			}
+			log.Warn().Str("revision", rr.rev.String()).Err(err).Msg("replica is unavailable, trying the next source")
 		}
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica contains the requested revision")
 
-		rr.chosenReader = rr.replica.SnapshotReader(rr.rev)
-		rr.chosePrimaryForTest = false
+		log.Warn().Str("revision", rr.rev.String()).Msg("no replica is available, using primary")
+		rr.chosenReader = rr.primary.SnapshotReader(rr.rev)
+		rr.chosePrimary = true
 	})
 
 	return finalError
 }
 
+// shouldReadFromPrimary returns whether a read that failed on a replica with
+// the error should be retried on the primary: either the replica does not yet
+// have the requested revision, or it could not be reached.
+func shouldReadFromPrimary(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.As(err, &common.RevisionUnavailableError{}) || isReplicaUnavailable(err)
+}
+
+// isReplicaUnavailable returns whether the error is the failure to reach a
+// replica over the network, such as when it is down or being restarted.
+// Errors of the queries themselves, and timeouts of the caller's context,
+// are not considered as such.
+func isReplicaUnavailable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
 // strictReadReplicatedReader is a reader that will use the replica for reads without itself checking for
 // the requested revision. If the replica does not have the requested revision, the primary will be
 // used instead. This is useful when the read pool points to a load balancer that can transparently
 // handle the request. In this case, the primary will be used as a fallback if the replica does not
 // have the requested revision. The replica(s) supplied to this proxy *must*, therefore, have strict
 // read mode enabled, to ensure the query will fail with a RevisionUnavailableError if the revision is
-// not available.
+// not available. If the replica cannot be reached, the read is retried against the other replicas in
+// turn, and then against the primary.
 type strictReadReplicatedReader struct {
-	rev     datastore.Revision
-	replica datastore.ReadOnlyDatastore
-	primary datastore.Datastore
+	rev      datastore.Revision
+	replicas []datastore.ReadOnlyDatastore
+	first    int
+	primary  datastore.Datastore
 }
 
-func (rr *strictReadReplicatedReader) ReadCaveatByName(ctx context.Context, name string) (*core.CaveatDefinition, datastore.Revision, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	caveat, lastWritten, err := sr.ReadCaveatByName(ctx, name)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("caveat", name).Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).ReadCaveatByName(ctx, name)
+// source returns the reader against which the attempt'th try of a read is run: the replicas in turn,
+// starting with the reader's, and then the primary.
+func (rr *strictReadReplicatedReader) source(attempt int) datastore.Reader {
+	if attempt >= len(rr.replicas) {
+		return rr.primary.SnapshotReader(rr.rev)
 	}
+	return replicaAt(rr.replicas, rr.first, attempt).SnapshotReader(rr.rev)
+}
+
+// nextAttempt returns the attempt with which to retry a read whose attempt'th try failed with the
+// error, or false if the error should be returned. If the replica cannot be reached, the read is
+// retried against the next replica, until each has been tried once, and then against the primary;
+// if the replica does not have the requested revision, it is retried against the primary. Other
+// errors, such as those of the query itself, and those of the primary, are returned without retrying.
+func (rr *strictReadReplicatedReader) nextAttempt(attempt int, err error) (int, bool) {
+	if attempt >= len(rr.replicas) || !shouldReadFromPrimary(err) {
+		return 0, false
+	}
+
+	if isReplicaUnavailable(err) && attempt+1 < len(rr.replicas) {
+		log.Warn().Str("revision", rr.rev.String()).Err(err).Msg("replica is unavailable, trying the next source")
+		return attempt + 1, true
+	}
+	log.Trace().Str("revision", rr.rev.String()).Err(err).Msg("replicas cannot serve the read, using primary")
+	return len(rr.replicas), true
+}
+
+// readWithFailover runs the read against the reader's replica, retrying it against the other
+// replicas and the primary as decided by nextAttempt.
+func readWithFailover[T any](rr *strictReadReplicatedReader, read func(datastore.Reader) (T, error)) (T, error) {
+	attempt := 0
+	for {
+		result, err := read(rr.source(attempt))
+		next, ok := rr.nextAttempt(attempt, err)
+		if !ok {
+			return result, err
+		}
+		attempt = next
+	}
+}
+
+// queryWithFailover returns an iterator over the relationships of the query, run against the
+// reader's replica and retried against the other replicas and the primary as decided by nextAttempt.
+// The iterators of the datastores only connect to them once iterated, so a source that cannot be
+// reached is usually only found when iterating: the query is therefore retried if it fails either
+// when run or before yielding its first relationship. Errors once relationships have been yielded
+// are yielded without retrying, since the relationships cannot be taken back.
+func queryWithFailover(rr *strictReadReplicatedReader, query func(datastore.Reader) (datastore.RelationshipIterator, error)) datastore.RelationshipIterator {
+	return failoverRelationships(rr.source, rr.nextAttempt, query)
+}
+
+// failoverRelationships returns an iterator over the relationships of the query, run against the
+// source of the first attempt. If the query fails, when run or before yielding any relationship,
+// next is given the attempt and the error, and returns the attempt with which to rerun the query, or
+// false if the error is to be yielded.
+func failoverRelationships(
+	source func(attempt int) datastore.Reader,
+	next func(attempt int, err error) (int, bool),
+	query func(datastore.Reader) (datastore.RelationshipIterator, error),
+) datastore.RelationshipIterator {
+	return func(yield func(tuple.Relationship, error) bool) {
+		attempt := 0
+		for {
+			iter, err := query(source(attempt))
+			if err == nil {
+				yielded := false
+				for rel, relErr := range iter {
+					if relErr != nil && !yielded {
+						err = relErr
+						break
+					}
+
+					yielded = true
+					if !yield(rel, relErr) || relErr != nil {
+						return
+					}
+				}
+				if err == nil {
+					return
+				}
+			}
+
+			var ok bool
+			if attempt, ok = next(attempt, err); !ok {
+				yield(tuple.Relationship{}, err)
+				return
+			}
+		}
+	}
+}
+
+func (rr *strictReadReplicatedReader) ReadCaveatByName(ctx context.Context, name string) (*core.CaveatDefinition, datastore.Revision, error) {
+	var lastWritten datastore.Revision
+	caveat, err := readWithFailover(rr, func(reader datastore.Reader) (caveat *core.CaveatDefinition, err error) {
+		caveat, lastWritten, err = reader.ReadCaveatByName(ctx, name)
+		return caveat, err
+	})
 	return caveat, lastWritten, err
 }
 
 func (rr *strictReadReplicatedReader) ListAllCaveats(ctx context.Context) ([]datastore.RevisionedCaveat, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	caveats, err := sr.ListAllCaveats(ctx)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).ListAllCaveats(ctx)
-	}
-	return caveats, err
+	return readWithFailover(rr, func(reader datastore.Reader) ([]datastore.RevisionedCaveat, error) {
+		return reader.ListAllCaveats(ctx)
+	})
 }
 
 func (rr *strictReadReplicatedReader) LookupCaveatsWithNames(ctx context.Context, names []string) ([]datastore.RevisionedCaveat, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	caveats, err := sr.LookupCaveatsWithNames(ctx, names)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).LookupCaveatsWithNames(ctx, names)
-	}
-	return caveats, err
+	return readWithFailover(rr, func(reader datastore.Reader) ([]datastore.RevisionedCaveat, error) {
+		return reader.LookupCaveatsWithNames(ctx, names)
+	})
 }
 
 func (rr *strictReadReplicatedReader) QueryRelationships(
@@ -310,13 +475,9 @@ func (rr *strictReadReplicatedReader) QueryRelationships(
 	filter datastore.RelationshipsFilter,
 	options ...options.QueryOptionsOption,
 ) (datastore.RelationshipIterator, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	relationships, err := sr.QueryRelationships(ctx, filter, options...)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).QueryRelationships(ctx, filter, options...)
-	}
-	return relationships, err
+	return queryWithFailover(rr, func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+		return reader.QueryRelationships(ctx, filter, options...)
+	}), nil
 }
 
 func (rr *strictReadReplicatedReader) ReverseQueryRelationships(
@@ -324,61 +485,40 @@ func (rr *strictReadReplicatedReader) ReverseQueryRelationships(
 	subjectsFilter datastore.SubjectsFilter,
 	options ...options.ReverseQueryOptionsOption,
 ) (datastore.RelationshipIterator, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	relationships, err := sr.ReverseQueryRelationships(ctx, subjectsFilter, options...)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).ReverseQueryRelationships(ctx, subjectsFilter, options...)
-	}
-	return relationships, err
+	return queryWithFailover(rr, func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+		return reader.ReverseQueryRelationships(ctx, subjectsFilter, options...)
+	}), nil
 }
 
-func (rr *strictReadReplicatedReader) ReadNamespaceByName(ctx context.Context, nsName string) (ns *core.NamespaceDefinition, lastWritten datastore.Revision, err error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	namespace, lastWritten, err := sr.ReadNamespaceByName(ctx, nsName)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("namespace", nsName).Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).ReadNamespaceByName(ctx, nsName)
-	}
+func (rr *strictReadReplicatedReader) ReadNamespaceByName(ctx context.Context, nsName string) (*core.NamespaceDefinition, datastore.Revision, error) {
+	var lastWritten datastore.Revision
+	namespace, err := readWithFailover(rr, func(reader datastore.Reader) (namespace *core.NamespaceDefinition, err error) {
+		namespace, lastWritten, err = reader.ReadNamespaceByName(ctx, nsName)
+		return namespace, err
+	})
 	return namespace, lastWritten, err
 }
 
 func (rr *strictReadReplicatedReader) ListAllNamespaces(ctx context.Context) ([]datastore.RevisionedNamespace, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	namespaces, err := sr.ListAllNamespaces(ctx)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).ListAllNamespaces(ctx)
-	}
-	return namespaces, err
+	return readWithFailover(rr, func(reader datastore.Reader) ([]datastore.RevisionedNamespace, error) {
+		return reader.ListAllNamespaces(ctx)
+	})
 }
 
 func (rr *strictReadReplicatedReader) LookupNamespacesWithNames(ctx context.Context, nsNames []string) ([]datastore.RevisionedNamespace, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	namespaces, err := sr.LookupNamespacesWithNames(ctx, nsNames)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).LookupNamespacesWithNames(ctx, nsNames)
-	}
-	return namespaces, err
+	return readWithFailover(rr, func(reader datastore.Reader) ([]datastore.RevisionedNamespace, error) {
+		return reader.LookupNamespacesWithNames(ctx, nsNames)
+	})
 }
 
 func (rr *strictReadReplicatedReader) CountRelationships(ctx context.Context, filter string) (int, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	count, err := sr.CountRelationships(ctx, filter)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).CountRelationships(ctx, filter)
-	}
-	return count, err
+	return readWithFailover(rr, func(reader datastore.Reader) (int, error) {
+		return reader.CountRelationships(ctx, filter)
+	})
 }
 
 func (rr *strictReadReplicatedReader) LookupCounters(ctx context.Context) ([]datastore.RelationshipCounter, error) {
-	sr := rr.replica.SnapshotReader(rr.rev)
-	counters, err := sr.LookupCounters(ctx)
-	if err != nil && errors.As(err, &common.RevisionUnavailableError{}) {
-		log.Trace().Str("revision", rr.rev.String()).Msg("replica does not contain the requested revision, using primary")
-		return rr.primary.SnapshotReader(rr.rev).LookupCounters(ctx)
-	}
-	return counters, err
+	return readWithFailover(rr, func(reader datastore.Reader) ([]datastore.RelationshipCounter, error) {
+		return reader.LookupCounters(ctx)
+	})
 }
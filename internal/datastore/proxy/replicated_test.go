@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -12,6 +14,7 @@ import (
 	"github.com/authzed/spicedb/pkg/datastore/options"
 	"github.com/authzed/spicedb/pkg/datastore/revisionparsing"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 func TestReplicatedReaderWithOnlyPrimary(t *testing.T) {
@@ -36,7 +39,7 @@ func TestReplicatedReaderFallsbackToPrimaryOnCheckRevisionFailure(t *testing.T)
 	require.NoError(t, err)
 	require.Equal(t, 0, len(ns))
 
-	require.False(t, reader.(*checkingStableReader).chosePrimaryForTest)
+	require.False(t, reader.(*checkingStableReader).chosePrimary)
 
 	// Try at revision 2, which should use the primary.
 	reader = replicated.SnapshotReader(revisionparsing.MustParseRevisionForTest("2"))
@@ -44,7 +47,7 @@ func TestReplicatedReaderFallsbackToPrimaryOnCheckRevisionFailure(t *testing.T)
 	require.NoError(t, err)
 	require.Equal(t, 0, len(ns))
 
-	require.True(t, reader.(*checkingStableReader).chosePrimaryForTest)
+	require.True(t, reader.(*checkingStableReader).chosePrimary)
 }
 
 func TestReplicatedReaderFallsbackToPrimaryOnRevisionNotAvailableError(t *testing.T) {
@@ -60,6 +63,181 @@ func TestReplicatedReaderFallsbackToPrimaryOnRevisionNotAvailableError(t *testin
 	require.Equal(t, 1, len(ns))
 }
 
+func TestReplicatedReaderFallsbackToPrimaryWhenReplicaUnavailable(t *testing.T) {
+	primary := fakeDatastore{true, revisionparsing.MustParseRevisionForTest("2")}
+	replica := fakeDatastore{false, revisionparsing.MustParseRevisionForTest("1")}
+
+	strict, err := NewStrictReplicatedDatastore(primary, replica)
+	require.NoError(t, err)
+
+	ns, _, err := strict.SnapshotReader(revisionparsing.MustParseRevisionForTest("1")).ReadNamespaceByName(context.Background(), "unreachable")
+	require.NoError(t, err)
+	require.Equal(t, "unreachable", ns.Name)
+
+	checking, err := NewCheckingReplicatedDatastore(primary, unreachableDatastore{replica})
+	require.NoError(t, err)
+
+	reader := checking.SnapshotReader(revisionparsing.MustParseRevisionForTest("1"))
+	_, err = reader.ListAllNamespaces(context.Background())
+	require.NoError(t, err)
+	require.True(t, reader.(*checkingStableReader).chosePrimary)
+}
+
+func TestReplicatedReaderFailsOverToAnotherReplica(t *testing.T) {
+	primary := fakeDatastore{true, revisionparsing.MustParseRevisionForTest("2")}
+	replica := fakeDatastore{false, revisionparsing.MustParseRevisionForTest("1")}
+	failing := unreachableDatastore{replica}
+
+	// Whichever replica each read starts with, the failing one is skipped for
+	// the other rather than the primary.
+	strict, err := NewStrictReplicatedDatastore(primary, failing, replica)
+	require.NoError(t, err)
+	for range 4 {
+		ns, _, err := strict.SnapshotReader(revisionparsing.MustParseRevisionForTest("1")).ReadNamespaceByName(context.Background(), "source")
+		require.NoError(t, err)
+		require.Equal(t, "replica", ns.Name)
+	}
+
+	checking, err := NewCheckingReplicatedDatastore(primary, failing, replica)
+	require.NoError(t, err)
+	for range 4 {
+		reader := checking.SnapshotReader(revisionparsing.MustParseRevisionForTest("1"))
+		ns, _, err := reader.ReadNamespaceByName(context.Background(), "source")
+		require.NoError(t, err)
+		require.Equal(t, "replica", ns.Name)
+		require.False(t, reader.(*checkingStableReader).chosePrimary)
+	}
+
+	// Once every replica has failed, the primary serves the read.
+	strict, err = NewStrictReplicatedDatastore(primary, failing, failing)
+	require.NoError(t, err)
+	ns, _, err := strict.SnapshotReader(revisionparsing.MustParseRevisionForTest("1")).ReadNamespaceByName(context.Background(), "source")
+	require.NoError(t, err)
+	require.Equal(t, "primary", ns.Name)
+
+	// Errors of the read itself do not fail over.
+	strict, err = NewStrictReplicatedDatastore(primary, replica, replica)
+	require.NoError(t, err)
+	_, _, err = strict.SnapshotReader(revisionparsing.MustParseRevisionForTest("1")).ReadNamespaceByName(context.Background(), "expecterror")
+	require.ErrorContains(t, err, "raising an expected error")
+}
+
+func TestReplicatedReaderFailsOverRelationshipQueries(t *testing.T) {
+	primary := fakeDatastore{true, revisionparsing.MustParseRevisionForTest("2")}
+	replica := fakeDatastore{false, revisionparsing.MustParseRevisionForTest("1")}
+	failing := unreachableDatastore{replica}
+
+	queries := map[string]func(datastore.Reader) (datastore.RelationshipIterator, error){
+		"forward": func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+			return reader.QueryRelationships(context.Background(), datastore.RelationshipsFilter{OptionalResourceType: "source"})
+		},
+		"reverse": func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+			return reader.ReverseQueryRelationships(context.Background(), datastore.SubjectsFilter{SubjectType: "user"})
+		},
+	}
+
+	for name, query := range queries {
+		t.Run(name, func(t *testing.T) {
+			readSource := func(ds datastore.Datastore, revision string) string {
+				iter, err := query(ds.SnapshotReader(revisionparsing.MustParseRevisionForTest(revision)))
+				require.NoError(t, err)
+				rels, err := datastore.IteratorToSlice(iter)
+				require.NoError(t, err)
+				require.Len(t, rels, 1)
+				return rels[0].Resource.ObjectID
+			}
+
+			// Whichever replica each query starts with, the failing one is
+			// skipped for the other rather than the primary.
+			strict, err := NewStrictReplicatedDatastore(primary, failing, replica)
+			require.NoError(t, err)
+			for range 4 {
+				require.Equal(t, "replica", readSource(strict, "1"))
+			}
+
+			// Once every replica has failed, the primary serves the query.
+			strict, err = NewStrictReplicatedDatastore(primary, failing, failing)
+			require.NoError(t, err)
+			require.Equal(t, "primary", readSource(strict, "1"))
+
+			// A replica without the revision defers to the primary.
+			strict, err = NewStrictReplicatedDatastore(primary, replica)
+			require.NoError(t, err)
+			require.Equal(t, "primary", readSource(strict, "3"))
+		})
+	}
+}
+
+func TestCheckingReplicatedReaderFallsBackForRelationshipQueries(t *testing.T) {
+	primary := fakeDatastore{true, revisionparsing.MustParseRevisionForTest("2")}
+	replica := fakeDatastore{false, revisionparsing.MustParseRevisionForTest("1")}
+
+	// The replica passes the revision check, but cannot be reached once the
+	// relationships are iterated.
+	checking, err := NewCheckingReplicatedDatastore(primary, lostDatastore{replica})
+	require.NoError(t, err)
+
+	queries := map[string]func(datastore.Reader) (datastore.RelationshipIterator, error){
+		"forward": func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+			return reader.QueryRelationships(context.Background(), datastore.RelationshipsFilter{OptionalResourceType: "source"})
+		},
+		"reverse": func(reader datastore.Reader) (datastore.RelationshipIterator, error) {
+			return reader.ReverseQueryRelationships(context.Background(), datastore.SubjectsFilter{SubjectType: "user"})
+		},
+	}
+
+	for name, query := range queries {
+		t.Run(name, func(t *testing.T) {
+			reader := checking.SnapshotReader(revisionparsing.MustParseRevisionForTest("1"))
+			iter, err := query(reader)
+			require.NoError(t, err)
+			require.False(t, reader.(*checkingStableReader).chosePrimary)
+
+			rels, err := datastore.IteratorToSlice(iter)
+			require.NoError(t, err)
+			require.Len(t, rels, 1)
+			require.Equal(t, "primary", rels[0].Resource.ObjectID)
+		})
+	}
+}
+
+func TestFailoverRelationshipsAfterFirstRelationship(t *testing.T) {
+	rel := tuple.MustParse("source:replica#viewer@user:tom")
+	queried := 0
+	iter := failoverRelationships(
+		func(int) datastore.Reader { return nil },
+		func(attempt int, _ error) (int, bool) { return attempt + 1, true },
+		func(datastore.Reader) (datastore.RelationshipIterator, error) {
+			queried++
+			return func(yield func(tuple.Relationship, error) bool) {
+				if yield(rel, nil) {
+					yield(tuple.Relationship{}, fmt.Errorf("failed to read: %w", errReplicaUnreachable))
+				}
+			}, nil
+		},
+	)
+
+	// Errors once a relationship has been yielded are not retried.
+	var rels []tuple.Relationship
+	var iterErr error
+	for found, err := range iter {
+		if err != nil {
+			iterErr = err
+			break
+		}
+		rels = append(rels, found)
+	}
+	require.ErrorIs(t, iterErr, errReplicaUnreachable)
+	require.Equal(t, []tuple.Relationship{rel}, rels)
+	require.Equal(t, 1, queried)
+}
+
+func TestIsReplicaUnavailable(t *testing.T) {
+	require.True(t, isReplicaUnavailable(fmt.Errorf("failed to connect: %w", errReplicaUnreachable)))
+	require.False(t, isReplicaUnavailable(errors.New("syntax error")))
+	require.False(t, isReplicaUnavailable(fmt.Errorf("%w: %w", context.DeadlineExceeded, errReplicaUnreachable)))
+}
+
 func TestReplicatedReaderReturnsExpectedError(t *testing.T) {
 	for _, requireCheck := range []bool{true, false} {
 		t.Run(fmt.Sprintf("requireCheck=%v", requireCheck), func(t *testing.T) {
@@ -86,6 +264,53 @@ func TestReplicatedReaderReturnsExpectedError(t *testing.T) {
 	}
 }
 
+var errReplicaUnreachable = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+// unreachableDatastore is a replica that cannot be reached.
+type unreachableDatastore struct {
+	fakeDatastore
+}
+
+func (unreachableDatastore) CheckRevision(_ context.Context, _ datastore.Revision) error {
+	return errReplicaUnreachable
+}
+
+func (unreachableDatastore) SnapshotReader(revision datastore.Revision) datastore.Reader {
+	return unreachableSnapshotReader{fakeSnapshotReader{revision: revision}}
+}
+
+// lostDatastore is a replica that passes the revision check, but cannot be
+// reached by its readers.
+type lostDatastore struct {
+	fakeDatastore
+}
+
+func (lostDatastore) SnapshotReader(revision datastore.Revision) datastore.Reader {
+	return unreachableSnapshotReader{fakeSnapshotReader{revision: revision}}
+}
+
+// unreachableSnapshotReader is a reader of a replica that cannot be reached.
+type unreachableSnapshotReader struct {
+	fakeSnapshotReader
+}
+
+func (unreachableSnapshotReader) ReadNamespaceByName(_ context.Context, _ string) (*corev1.NamespaceDefinition, datastore.Revision, error) {
+	return nil, nil, fmt.Errorf("failed to connect: %w", errReplicaUnreachable)
+}
+
+func (unreachableSnapshotReader) QueryRelationships(context.Context, datastore.RelationshipsFilter, ...options.QueryOptionsOption) (datastore.RelationshipIterator, error) {
+	return unreachableRelationships, nil
+}
+
+func (unreachableSnapshotReader) ReverseQueryRelationships(context.Context, datastore.SubjectsFilter, ...options.ReverseQueryOptionsOption) (datastore.RelationshipIterator, error) {
+	return unreachableRelationships, nil
+}
+
+// unreachableRelationships fails to connect once iterated, as the iterators of the datastores do.
+func unreachableRelationships(yield func(tuple.Relationship, error) bool) {
+	yield(tuple.Relationship{}, fmt.Errorf("failed to connect: %w", errReplicaUnreachable))
+}
+
 type fakeDatastore struct {
 	isPrimary bool
 	revision  datastore.Revision
@@ -174,11 +399,25 @@ func (fsr fakeSnapshotReader) LookupNamespacesWithNames(_ context.Context, nsNam
 	return nil, fmt.Errorf("not implemented")
 }
 
-func (fakeSnapshotReader) ReadNamespaceByName(_ context.Context, nsName string) (ns *corev1.NamespaceDefinition, lastWritten datastore.Revision, err error) {
+func (fsr fakeSnapshotReader) ReadNamespaceByName(_ context.Context, nsName string) (ns *corev1.NamespaceDefinition, lastWritten datastore.Revision, err error) {
 	if nsName == "expecterror" {
 		return nil, nil, fmt.Errorf("raising an expected error")
 	}
 
+	if nsName == "source" {
+		if fsr.isPrimary {
+			return &corev1.NamespaceDefinition{Name: "primary"}, fsr.revision, nil
+		}
+		return &corev1.NamespaceDefinition{Name: "replica"}, fsr.revision, nil
+	}
+
+	if nsName == "unreachable" {
+		if fsr.isPrimary {
+			return &corev1.NamespaceDefinition{Name: nsName}, fsr.revision, nil
+		}
+		return nil, nil, fmt.Errorf("failed to connect: %w", errReplicaUnreachable)
+	}
+
 	return nil, nil, fmt.Errorf("not implemented")
 }
 
@@ -198,12 +437,29 @@ func (fakeSnapshotReader) ListAllNamespaces(context.Context) ([]datastore.Revisi
 	return nil, nil
 }
 
-func (fakeSnapshotReader) QueryRelationships(context.Context, datastore.RelationshipsFilter, ...options.QueryOptionsOption) (datastore.RelationshipIterator, error) {
-	return nil, fmt.Errorf("not implemented")
+func (fsr fakeSnapshotReader) QueryRelationships(context.Context, datastore.RelationshipsFilter, ...options.QueryOptionsOption) (datastore.RelationshipIterator, error) {
+	return fsr.sourceRelationships(), nil
 }
 
-func (fakeSnapshotReader) ReverseQueryRelationships(context.Context, datastore.SubjectsFilter, ...options.ReverseQueryOptionsOption) (datastore.RelationshipIterator, error) {
-	return nil, fmt.Errorf("not implemented")
+func (fsr fakeSnapshotReader) ReverseQueryRelationships(context.Context, datastore.SubjectsFilter, ...options.ReverseQueryOptionsOption) (datastore.RelationshipIterator, error) {
+	return fsr.sourceRelationships(), nil
+}
+
+// sourceRelationships returns an iterator over a relationship whose resource names the source that
+// served it, which, like those of the datastores, only fails once iterated.
+func (fsr fakeSnapshotReader) sourceRelationships() datastore.RelationshipIterator {
+	return func(yield func(tuple.Relationship, error) bool) {
+		if fsr.isPrimary {
+			yield(tuple.MustParse("source:primary#viewer@user:tom"), nil)
+			return
+		}
+
+		if fsr.revision.GreaterThan(revisionparsing.MustParseRevisionForTest("2")) {
+			yield(tuple.Relationship{}, common.NewRevisionUnavailableError(fmt.Errorf("revision not available")))
+			return
+		}
+		yield(tuple.MustParse("source:replica#viewer@user:tom"), nil)
+	}
 }
 
 func (fakeSnapshotReader) CountRelationships(ctx context.Context, filter string) (int, error) {
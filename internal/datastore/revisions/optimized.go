@@ -37,6 +37,12 @@ func (cor *CachedOptimizedRevisions) SetOptimizedRevisionFunc(revisionFunc Optim
 	cor.optimizedFunc = revisionFunc
 }
 
+// SetClock sets the clock used to determine whether the cached revisions are
+// still valid, in place of the system clock.
+func (cor *CachedOptimizedRevisions) SetClock(clock clock.Clock) {
+	cor.clockFn = clock
+}
+
 func (cor *CachedOptimizedRevisions) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
 	span := trace.SpanFromContext(ctx)
 	localNow := cor.clockFn.Now()
@@ -97,6 +103,14 @@ func (cor *CachedOptimizedRevisions) OptimizedRevision(ctx context.Context) (dat
 	return newQuantizedRevision.(datastore.Revision), err
 }
 
+// replaceCandidates replaces the cached revisions with the given revision,
+// valid for the given duration.
+func (cor *CachedOptimizedRevisions) replaceCandidates(revision datastore.Revision, validFor time.Duration) {
+	cor.Lock()
+	defer cor.Unlock()
+	cor.candidates = []validRevision{{revision, cor.clockFn.Now().Add(validFor)}}
+}
+
 // CachedOptimizedRevisions does caching and deduplication for requests for optimized revisions.
 type CachedOptimizedRevisions struct {
 	sync.RWMutex
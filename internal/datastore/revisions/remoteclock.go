@@ -2,8 +2,12 @@ package revisions
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
+
 	log "github.com/authzed/spicedb/internal/logging"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
@@ -12,6 +16,87 @@ import (
 // RemoteNowFunction queries the datastore to get a current revision.
 type RemoteNowFunction func(context.Context) (datastore.Revision, error)
 
+// HLCClockNowFunction returns a RemoteNowFunction that reads the current
+// revision from the given clock instead of the datastore, allowing tests to
+// control the passage of time.
+func HLCClockNowFunction(clock clock.Clock) RemoteNowFunction {
+	return func(context.Context) (datastore.Revision, error) {
+		return NewHLCForTime(clock.Now()), nil
+	}
+}
+
+// RevisionObserverFunction is invoked with each newly computed optimized revision.
+type RevisionObserverFunction func(datastore.Revision)
+
+// FutureRevisionPolicy determines how a revision that is newer than the latest
+// revision known to the datastore is handled when checked.
+type FutureRevisionPolicy int
+
+const (
+	// FutureRevisionError immediately fails the check of a future revision
+	// with an InvalidRevisionError whose reason is RevisionInFuture.
+	FutureRevisionError FutureRevisionPolicy = iota
+
+	// FutureRevisionWait waits, up to a maximum amount of time, for the
+	// datastore to catch up to a future revision before failing the check.
+	FutureRevisionWait
+)
+
+// BackwardsRevisionPolicy determines how a current revision of the datastore
+// that is older than one previously read, such as after a clock issue or a
+// failover, is handled when computing the optimized revision.
+type BackwardsRevisionPolicy int
+
+const (
+	// ClampBackwardsRevision uses the latest revision previously read in
+	// place of the older one, and logs a warning.
+	ClampBackwardsRevision BackwardsRevisionPolicy = iota
+
+	// FailBackwardsRevision fails with a RevisionWentBackwardsError.
+	FailBackwardsRevision
+)
+
+// RevisionWentBackwardsError is returned when the datastore's current revision
+// is older than one previously read, with FailBackwardsRevision.
+type RevisionWentBackwardsError struct {
+	// Observed is the current revision read from the datastore.
+	Observed datastore.Revision
+
+	// Previous is the latest revision previously read from the datastore.
+	Previous datastore.Revision
+}
+
+func (err RevisionWentBackwardsError) Error() string {
+	return fmt.Sprintf("datastore revision went backwards from %s to %s", err.Previous, err.Observed)
+}
+
+// QuantizationAlignment determines where the quantization windows of the
+// optimized revisions begin.
+type QuantizationAlignment int
+
+const (
+	// RelativeEpochAlignment begins a window at each multiple of the
+	// quantization since the Unix epoch.
+	RelativeEpochAlignment QuantizationAlignment = iota
+
+	// WallClockAlignment begins a window at each multiple of the quantization
+	// since the start of the enclosing UTC wall-clock second, minute, hour or
+	// day, whichever is the shortest that is at least as long as the
+	// quantization, so that every such boundary begins a window even when the
+	// quantization does not divide it evenly. The last window before each
+	// boundary is then shorter than the quantization. Quantizations longer
+	// than a day are aligned to the Unix epoch.
+	WallClockAlignment
+)
+
+// wallClockUnits are the wall-clock units to which WallClockAlignment aligns
+// the quantization windows, from shortest to longest.
+var wallClockUnits = []time.Duration{time.Second, time.Minute, time.Hour, 24 * time.Hour}
+
+// futureRevisionPollInterval is how often the datastore's current revision is
+// read while waiting for it to catch up to a future revision.
+var futureRevisionPollInterval = 25 * time.Millisecond
+
 // RemoteClockRevisions handles revision calculation for datastores that provide
 // their own clocks.
 type RemoteClockRevisions struct {
@@ -19,8 +104,21 @@ type RemoteClockRevisions struct {
 
 	gcWindowNanos          int64
 	nowFunc                RemoteNowFunction
+	revisionObserver       RevisionObserverFunction
 	followerReadDelayNanos int64
 	quantizationNanos      int64
+	quantizationAlignment  QuantizationAlignment
+
+	// maxCachedRevisionAgeNanos, if positive, bounds how old an optimized
+	// revision can be when handed out, whatever the quantization.
+	maxCachedRevisionAgeNanos int64
+
+	futureRevisionPolicy  FutureRevisionPolicy
+	futureRevisionMaxWait time.Duration
+
+	backwardsRevisionPolicy BackwardsRevisionPolicy
+	latestLock              sync.RWMutex
+	latest                  datastore.Revision
 }
 
 // NewRemoteClockRevisions returns a RemoteClockRevisions for the given configuration
@@ -49,7 +147,7 @@ func NewRemoteClockRevisions(gcWindow, maxRevisionStaleness, followerReadDelay,
 }
 
 func (rcr *RemoteClockRevisions) optimizedRevisionFunc(ctx context.Context) (datastore.Revision, time.Duration, error) {
-	nowRev, err := rcr.nowFunc(ctx)
+	nowRev, err := rcr.monotonicNow(ctx)
 	if err != nil {
 		return datastore.NoRevision, 0, err
 	}
@@ -64,20 +162,160 @@ func (rcr *RemoteClockRevisions) optimizedRevisionFunc(ctx context.Context) (dat
 	}
 
 	delayedNow := nowTS.TimestampNanoSec() - rcr.followerReadDelayNanos
-	quantized := delayedNow
-	validForNanos := int64(0)
-	if rcr.quantizationNanos > 0 {
-		afterLastQuantization := delayedNow % rcr.quantizationNanos
-		quantized -= afterLastQuantization
-		validForNanos = rcr.quantizationNanos - afterLastQuantization
+	quantized, windowEnd := rcr.quantizationWindow(delayedNow)
+	if maxAge := rcr.maxCachedRevisionAgeNanos; maxAge > 0 && delayedNow-quantized >= maxAge {
+		// The start of the window is too old to be handed out, so the
+		// revision is not quantized.
+		quantized = delayedNow
 	}
+	validForNanos := rcr.capValidFor(quantized, delayedNow, windowEnd-delayedNow)
 	log.Ctx(ctx).Debug().
 		Time("quantized", time.Unix(0, quantized)).
 		Int64("readSkew", rcr.followerReadDelayNanos).
 		Int64("totalSkew", nowTS.TimestampNanoSec()-quantized).
 		Msg("revision skews")
 
-	return nowTS.ConstructForTimestamp(quantized), time.Duration(validForNanos) * time.Nanosecond, nil
+	optimized := nowTS.ConstructForTimestamp(quantized)
+	if rcr.revisionObserver != nil {
+		rcr.revisionObserver(optimized)
+	}
+
+	return optimized, time.Duration(validForNanos) * time.Nanosecond, nil
+}
+
+// QuantizeRevision returns the start of the quantization window, of the
+// configured length and alignment, that contains the timestamp. See
+// QuantizeRevision.
+func (rcr *RemoteClockRevisions) QuantizeRevision(ts time.Time) time.Time {
+	if rcr.quantizationNanos <= 0 {
+		return ts
+	}
+	start, _ := rcr.quantizationWindow(ts.UnixNano())
+	return time.Unix(0, start).In(ts.Location())
+}
+
+// quantizationWindow returns the start and end of the quantization window that
+// contains the nanosecond timestamp. Without quantization, both are the
+// timestamp itself.
+func (rcr *RemoteClockRevisions) quantizationWindow(nanos int64) (int64, int64) {
+	if rcr.quantizationNanos <= 0 {
+		return nanos, nanos
+	}
+
+	if rcr.quantizationAlignment == WallClockAlignment {
+		for _, unit := range wallClockUnits {
+			unitNanos := unit.Nanoseconds()
+			if unitNanos < rcr.quantizationNanos {
+				continue
+			}
+			unitStart := quantizeNanos(nanos, unitNanos)
+			start := unitStart + quantizeNanos(nanos-unitStart, rcr.quantizationNanos)
+			return start, min(start+rcr.quantizationNanos, unitStart+unitNanos)
+		}
+	}
+
+	start := quantizeNanos(nanos, rcr.quantizationNanos)
+	return start, start + rcr.quantizationNanos
+}
+
+// QuantizeRevision returns the start of the window of the given length that
+// contains the timestamp, which is the timestamp of the revision advertised
+// for it by a RemoteClockRevisions quantizing with that window. Windows are
+// aligned to the Unix epoch. A window of zero or less leaves the timestamp
+// unchanged.
+func QuantizeRevision(ts time.Time, window time.Duration) time.Time {
+	if window <= 0 {
+		return ts
+	}
+	return time.Unix(0, quantizeNanos(ts.UnixNano(), window.Nanoseconds())).In(ts.Location())
+}
+
+// quantizeNanos floors the nanosecond timestamp to a multiple of the window,
+// if the window is positive.
+func quantizeNanos(nanos, windowNanos int64) int64 {
+	if windowNanos <= 0 {
+		return nanos
+	}
+	afterLastQuantization := nanos % windowNanos
+	if afterLastQuantization < 0 {
+		afterLastQuantization += windowNanos
+	}
+	return nanos - afterLastQuantization
+}
+
+// RefreshOptimizedRevision bypasses the cached optimized revisions, reading the
+// datastore's current revision and caching it in their place until the end of
+// the current quantization window. The returned revision is neither quantized
+// nor delayed for follower reads, so it follows every write made before the
+// call, allowing reads made after a write to observe it.
+func (rcr *RemoteClockRevisions) RefreshOptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	nowRev, err := rcr.monotonicNow(ctx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	nowTS, ok := nowRev.(WithTimestampRevision)
+	if !ok {
+		return datastore.NoRevision, spiceerrors.MustBugf("expected with-timestamp revision, got %T", nowRev)
+	}
+
+	_, windowEnd := rcr.quantizationWindow(nowTS.TimestampNanoSec())
+	validForNanos := rcr.capValidFor(nowTS.TimestampNanoSec(), nowTS.TimestampNanoSec(), windowEnd-nowTS.TimestampNanoSec())
+
+	rcr.replaceCandidates(nowRev, time.Duration(validForNanos))
+	if rcr.revisionObserver != nil {
+		rcr.revisionObserver(nowRev)
+	}
+	return nowRev, nil
+}
+
+// capValidFor caps the duration for which a revision with the given timestamp,
+// computed at nowNanos, is cached so that it is not handed out once older than
+// the maximum cached revision age.
+func (rcr *RemoteClockRevisions) capValidFor(revisionNanos, nowNanos, validForNanos int64) int64 {
+	if rcr.maxCachedRevisionAgeNanos <= 0 {
+		return validForNanos
+	}
+	return min(validForNanos, revisionNanos+rcr.maxCachedRevisionAgeNanos-nowNanos)
+}
+
+// monotonicNow reads the datastore's current revision, handling a revision
+// older than the latest one previously read per the backwards revision policy,
+// so that the optimized revisions never go backwards. The revision is read
+// without holding the lock, so that concurrent callers are not serialized
+// behind the round trip to the datastore; a revision that is older only than
+// one recorded by a concurrent read, rather than than the latest one recorded
+// before this read began, has not gone backwards, and is simply superseded.
+func (rcr *RemoteClockRevisions) monotonicNow(ctx context.Context) (datastore.Revision, error) {
+	rcr.latestLock.RLock()
+	previous := rcr.latest
+	rcr.latestLock.RUnlock()
+
+	nowRev, err := rcr.nowFunc(ctx)
+	if err != nil || nowRev == datastore.NoRevision {
+		return nowRev, err
+	}
+
+	rcr.latestLock.Lock()
+	defer rcr.latestLock.Unlock()
+
+	if previous != nil && nowRev.LessThan(previous) {
+		if rcr.backwardsRevisionPolicy == FailBackwardsRevision {
+			return datastore.NoRevision, RevisionWentBackwardsError{Observed: nowRev, Previous: previous}
+		}
+
+		log.Ctx(ctx).Warn().
+			Stringer("observed", nowRev).
+			Stringer("previous", previous).
+			Msg("the datastore revision went backwards, so the latest revision previously read is used instead")
+	}
+
+	if rcr.latest != nil && nowRev.LessThan(rcr.latest) {
+		return rcr.latest, nil
+	}
+
+	rcr.latest = nowRev
+	return nowRev, nil
 }
 
 // SetNowFunc sets the function used to determine the head revision
@@ -85,6 +323,67 @@ func (rcr *RemoteClockRevisions) SetNowFunc(nowFunc RemoteNowFunction) {
 	rcr.nowFunc = nowFunc
 }
 
+// SetRevisionObserver sets a function to be invoked each time a new optimized
+// revision is computed.
+func (rcr *RemoteClockRevisions) SetRevisionObserver(observer RevisionObserverFunction) {
+	rcr.revisionObserver = observer
+}
+
+// SetQuantizationAlignment sets where the quantization windows of the
+// optimized revisions begin. Defaults to RelativeEpochAlignment.
+func (rcr *RemoteClockRevisions) SetQuantizationAlignment(alignment QuantizationAlignment) {
+	rcr.quantizationAlignment = alignment
+}
+
+// SetMaxCachedRevisionAge bounds how old the optimized revision can be, so that
+// it is refreshed before then even within a quantization window, and the start
+// of a window older than it is not handed out. The revision can still be older
+// by up to the maximum revision staleness. Zero, the default, leaves the age
+// bounded by the quantization alone.
+func (rcr *RemoteClockRevisions) SetMaxCachedRevisionAge(maxAge time.Duration) {
+	rcr.maxCachedRevisionAgeNanos = maxAge.Nanoseconds()
+}
+
+// SetBackwardsRevisionPolicy sets how a current revision of the datastore that
+// is older than one previously read is handled when computing the optimized
+// revision. Defaults to ClampBackwardsRevision.
+func (rcr *RemoteClockRevisions) SetBackwardsRevisionPolicy(policy BackwardsRevisionPolicy) {
+	rcr.backwardsRevisionPolicy = policy
+}
+
+// SetFutureRevisionPolicy sets how a revision newer than the datastore's
+// current revision is handled by CheckRevision. With FutureRevisionWait, the
+// check waits for at most maxWait for the datastore to catch up.
+func (rcr *RemoteClockRevisions) SetFutureRevisionPolicy(policy FutureRevisionPolicy, maxWait time.Duration) {
+	rcr.futureRevisionPolicy = policy
+	rcr.futureRevisionMaxWait = maxWait
+}
+
+// MinimumValidRevision returns the oldest revision that can still be read,
+// which is the datastore's current revision minus the GC window. When
+// revisions are quantized, it is rounded up to the start of the next
+// quantization window, as aligned by the quantization alignment, so that the
+// returned revision is one the datastore could have handed out.
+// Revisions older than it are rejected by CheckRevision as stale.
+func (rcr *RemoteClockRevisions) MinimumValidRevision(ctx context.Context) (datastore.Revision, error) {
+	now, err := rcr.nowFunc(ctx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	nowTS, ok := now.(WithTimestampRevision)
+	if !ok {
+		return datastore.NoRevision, spiceerrors.MustBugf("expected with-timestamp revision, got %T", now)
+	}
+
+	minimum := nowTS.TimestampNanoSec() - rcr.gcWindowNanos
+	if start, end := rcr.quantizationWindow(minimum); start != minimum {
+		minimum = end
+	}
+
+	return nowTS.ConstructForTimestamp(minimum), nil
+}
+
 func (rcr *RemoteClockRevisions) CheckRevision(ctx context.Context, dsRevision datastore.Revision) error {
 	if dsRevision == datastore.NoRevision {
 		return datastore.NewInvalidRevisionErr(dsRevision, datastore.CouldNotDetermineRevision)
@@ -115,11 +414,92 @@ func (rcr *RemoteClockRevisions) CheckRevision(ctx context.Context, dsRevision d
 		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
 	}
 
-	isUnknown := revisionNanos > nowNanos
-	if isUnknown {
-		log.Ctx(ctx).Debug().Stringer("now", now).Stringer("revision", revision).Msg("unknown revision")
-		return datastore.NewInvalidRevisionErr(revision, datastore.CouldNotDetermineRevision)
+	isFuture := revisionNanos > nowNanos
+	if isFuture {
+		if rcr.futureRevisionPolicy == FutureRevisionWait && rcr.futureRevisionMaxWait > 0 {
+			return rcr.waitForRevision(ctx, revision, rcr.futureRevisionMaxWait)
+		}
+
+		log.Ctx(ctx).Debug().Stringer("now", now).Stringer("revision", revision).Msg("future revision")
+		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
 	}
 
 	return nil
 }
+
+// AwaitRevision blocks until the datastore's current revision has reached the
+// revision, such as one returned by a write made through another node, so that
+// it can be read, polling the current revision until it does. Unlike
+// CheckRevision with FutureRevisionWait, the wait is bounded only by the
+// context. Revisions that are stale fail immediately.
+func (rcr *RemoteClockRevisions) AwaitRevision(ctx context.Context, dsRevision datastore.Revision) error {
+	if dsRevision == datastore.NoRevision {
+		return datastore.NewInvalidRevisionErr(dsRevision, datastore.CouldNotDetermineRevision)
+	}
+
+	revision, ok := dsRevision.(WithTimestampRevision)
+	if !ok {
+		return spiceerrors.MustBugf("expected with-timestamp revision, got %T", dsRevision)
+	}
+
+	now, err := rcr.nowFunc(ctx)
+	if err != nil {
+		return err
+	}
+
+	nowTS, ok := now.(WithTimestampRevision)
+	if !ok {
+		return spiceerrors.MustBugf("expected with-timestamp revision, got %T", now)
+	}
+
+	if revision.TimestampNanoSec() < nowTS.TimestampNanoSec()-rcr.gcWindowNanos {
+		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+	}
+	if nowTS.TimestampNanoSec() >= revision.TimestampNanoSec() {
+		return nil
+	}
+
+	return rcr.waitForRevision(ctx, revision, 0)
+}
+
+// waitForRevision polls the datastore's current revision until it reaches the
+// given revision, or until maxWait has elapsed. A maxWait of zero bounds the
+// wait only by the context. The wait and the polls are timed by the clock set
+// by SetClock.
+func (rcr *RemoteClockRevisions) waitForRevision(ctx context.Context, revision WithTimestampRevision, maxWait time.Duration) error {
+	var deadlineC <-chan time.Time
+	if maxWait > 0 {
+		deadline := rcr.clockFn.Timer(maxWait)
+		defer deadline.Stop()
+		deadlineC = deadline.C
+	}
+
+	ticker := rcr.clockFn.Ticker(futureRevisionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-deadlineC:
+			log.Ctx(ctx).Debug().Stringer("revision", revision).Dur("waited", maxWait).Msg("datastore did not catch up to future revision")
+			return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
+
+		case <-ticker.C:
+			now, err := rcr.nowFunc(ctx)
+			if err != nil {
+				return err
+			}
+
+			nowTS, ok := now.(WithTimestampRevision)
+			if !ok {
+				return spiceerrors.MustBugf("expected HLC revision, got %T", now)
+			}
+
+			if nowTS.TimestampNanoSec() >= revision.TimestampNanoSec() {
+				return nil
+			}
+		}
+	}
+}
@@ -2,10 +2,15 @@ package revisions
 
 import (
 	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	log "github.com/authzed/spicedb/internal/logging"
@@ -101,6 +106,65 @@ func TestRemoteClockOptimizedRevisions(t *testing.T) {
 	}
 }
 
+func TestRemoteClockWithoutQuantization(t *testing.T) {
+	// Without quantization, nothing is cached, so even revisions a nanosecond
+	// apart are advertised exactly.
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 0)
+
+	remoteClock := clock.NewMock()
+	rcr.clockFn = remoteClock
+	rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+		return NewForTime(remoteClock.Now()), nil
+	})
+
+	first := time.Unix(1_700_000_000, 123)
+	remoteClock.Set(first)
+	firstRevision, err := rcr.OptimizedRevision(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, first.UnixNano(), firstRevision.(WithTimestampRevision).TimestampNanoSec())
+
+	remoteClock.Add(time.Nanosecond)
+	secondRevision, err := rcr.OptimizedRevision(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, first.UnixNano()+1, secondRevision.(WithTimestampRevision).TimestampNanoSec())
+	require.True(t, firstRevision.LessThan(secondRevision))
+}
+
+func TestRemoteClockRevisionObserver(t *testing.T) {
+	require := require.New(t)
+
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 5*time.Second)
+
+	remoteClock := clock.NewMock()
+	rcr.clockFn = remoteClock
+	rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+		return NewForTime(remoteClock.Now()), nil
+	})
+
+	var observed []datastore.Revision
+	rcr.SetRevisionObserver(func(rev datastore.Revision) {
+		observed = append(observed, rev)
+	})
+
+	remoteClock.Set(time.Unix(1231, 0))
+	optimized, err := rcr.OptimizedRevision(context.Background())
+	require.NoError(err)
+	require.Len(observed, 1)
+	require.True(optimized.Equal(observed[0]))
+
+	// A cached revision is not recomputed, so the observer is not invoked.
+	_, err = rcr.OptimizedRevision(context.Background())
+	require.NoError(err)
+	require.Len(observed, 1)
+
+	remoteClock.Set(time.Unix(1236, 0))
+	optimized, err = rcr.OptimizedRevision(context.Background())
+	require.NoError(err)
+	require.Len(observed, 2)
+	require.True(NewForTimestamp(1235 * 1_000_000_000).Equal(observed[1]))
+	require.True(optimized.Equal(observed[1]))
+}
+
 func TestRemoteClockCheckRevisions(t *testing.T) {
 	testCases := []struct {
 		name                string
@@ -144,6 +208,206 @@ func TestRemoteClockCheckRevisions(t *testing.T) {
 	}
 }
 
+func TestRemoteClockFutureRevisionPolicy(t *testing.T) {
+	futureRevision := NewForTimestamp(12350 * 1_000_000_000)
+
+	newRevisions := func(catchUpAfterCalls int) *RemoteClockRevisions {
+		rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 0)
+
+		calls := 0
+		rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+			calls++
+			if catchUpAfterCalls > 0 && calls > catchUpAfterCalls {
+				return futureRevision, nil
+			}
+			return NewForTimestamp(12345 * 1_000_000_000), nil
+		})
+		return rcr
+	}
+
+	requireFutureErr := func(t *testing.T, err error) {
+		var invalidErr datastore.InvalidRevisionError
+		require.ErrorAs(t, err, &invalidErr)
+		require.Equal(t, datastore.RevisionInFuture, invalidErr.Reason())
+	}
+
+	t.Run("error", func(t *testing.T) {
+		rcr := newRevisions(1)
+		requireFutureErr(t, rcr.CheckRevision(context.Background(), futureRevision))
+	})
+
+	t.Run("wait until caught up", func(t *testing.T) {
+		rcr := newRevisions(3)
+		rcr.SetFutureRevisionPolicy(FutureRevisionWait, 5*time.Second)
+		require.NoError(t, rcr.CheckRevision(context.Background(), futureRevision))
+	})
+
+	t.Run("wait exceeds maximum", func(t *testing.T) {
+		rcr := newRevisions(0)
+		rcr.SetFutureRevisionPolicy(FutureRevisionWait, 100*time.Millisecond)
+
+		start := time.Now()
+		requireFutureErr(t, rcr.CheckRevision(context.Background(), futureRevision))
+		require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("wait canceled", func(t *testing.T) {
+		rcr := newRevisions(0)
+		rcr.SetFutureRevisionPolicy(FutureRevisionWait, 5*time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, rcr.CheckRevision(ctx, futureRevision), context.DeadlineExceeded)
+	})
+
+	t.Run("wait timed by the clock", func(t *testing.T) {
+		mockClock := clock.NewMock()
+		rcr := newRevisions(0)
+		rcr.SetClock(mockClock)
+		rcr.SetFutureRevisionPolicy(FutureRevisionWait, 200*time.Millisecond)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- rcr.CheckRevision(context.Background(), futureRevision)
+		}()
+
+		// The wait ends only once the clock passes the maximum wait, however
+		// long it takes in real time.
+		require.Never(t, func() bool { return len(errCh) > 0 }, 500*time.Millisecond, 10*time.Millisecond)
+		mockClock.Add(200 * time.Millisecond)
+		select {
+		case err := <-errCh:
+			requireFutureErr(t, err)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the wait did not end when the clock passed the maximum wait")
+		}
+	})
+}
+
+func TestRemoteClockAwaitRevision(t *testing.T) {
+	written := NewForTimestamp(12350 * 1_000_000_000)
+
+	// laggingRevisions reaches the written revision after the given number of
+	// reads of its current revision, or never if it is zero.
+	laggingRevisions := func(catchUpAfterCalls int) (*RemoteClockRevisions, *int) {
+		rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 0)
+
+		calls := 0
+		rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+			calls++
+			if catchUpAfterCalls > 0 && calls >= catchUpAfterCalls {
+				return written, nil
+			}
+			return NewForTimestamp(12345 * 1_000_000_000), nil
+		})
+		return rcr, &calls
+	}
+
+	t.Run("already readable", func(t *testing.T) {
+		rcr, calls := laggingRevisions(1)
+		require.NoError(t, rcr.AwaitRevision(context.Background(), written))
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("waits until caught up", func(t *testing.T) {
+		rcr, calls := laggingRevisions(4)
+		require.NoError(t, rcr.AwaitRevision(context.Background(), written))
+		require.Equal(t, 4, *calls)
+	})
+
+	t.Run("context expires", func(t *testing.T) {
+		rcr, _ := laggingRevisions(0)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, rcr.AwaitRevision(ctx, written), context.DeadlineExceeded)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		rcr, _ := laggingRevisions(1)
+		err := rcr.AwaitRevision(context.Background(), NewForTimestamp(1))
+		var invalidErr datastore.InvalidRevisionError
+		require.ErrorAs(t, err, &invalidErr)
+		require.Equal(t, datastore.RevisionStale, invalidErr.Reason())
+	})
+
+	t.Run("no revision", func(t *testing.T) {
+		rcr, _ := laggingRevisions(1)
+		require.Error(t, rcr.AwaitRevision(context.Background(), datastore.NoRevision))
+	})
+}
+
+func TestRemoteClockMinimumValidRevision(t *testing.T) {
+	now := NewForTimestamp(12345 * 1_000_000_000)
+
+	newRevisions := func(quantization time.Duration) *RemoteClockRevisions {
+		rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, quantization)
+		rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+			return now, nil
+		})
+		return rcr
+	}
+
+	t.Run("unquantized", func(t *testing.T) {
+		rcr := newRevisions(0)
+
+		minimum, err := rcr.MinimumValidRevision(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(8745*1_000_000_000), minimum.(WithTimestampRevision).TimestampNanoSec())
+
+		// Just inside the window.
+		require.NoError(t, rcr.CheckRevision(context.Background(), minimum))
+
+		// Just outside the window.
+		outside := NewForTimestamp(minimum.(WithTimestampRevision).TimestampNanoSec() - 1)
+		var invalidErr datastore.InvalidRevisionError
+		require.ErrorAs(t, rcr.CheckRevision(context.Background(), outside), &invalidErr)
+		require.Equal(t, datastore.RevisionStale, invalidErr.Reason())
+	})
+
+	t.Run("quantized", func(t *testing.T) {
+		rcr := newRevisions(7 * time.Second)
+
+		minimum, err := rcr.MinimumValidRevision(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(8750*1_000_000_000), minimum.(WithTimestampRevision).TimestampNanoSec())
+		require.NoError(t, rcr.CheckRevision(context.Background(), minimum))
+	})
+
+	t.Run("quantized with wall-clock alignment", func(t *testing.T) {
+		rcr := newRevisions(7 * time.Second)
+		rcr.SetQuantizationAlignment(WallClockAlignment)
+
+		// The minimum of 8745s falls in the window from 8742s, 42s into the
+		// minute, which ends at 8749s.
+		minimum, err := rcr.MinimumValidRevision(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(8749*1_000_000_000), minimum.(WithTimestampRevision).TimestampNanoSec())
+		require.NoError(t, rcr.CheckRevision(context.Background(), minimum))
+
+		start, _ := rcr.quantizationWindow(minimum.(WithTimestampRevision).TimestampNanoSec())
+		require.Equal(t, minimum.(WithTimestampRevision).TimestampNanoSec(), start)
+	})
+
+	t.Run("quantized minimum on a window start", func(t *testing.T) {
+		rcr := newRevisions(5 * time.Second)
+		rcr.SetQuantizationAlignment(WallClockAlignment)
+
+		minimum, err := rcr.MinimumValidRevision(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(8745*1_000_000_000), minimum.(WithTimestampRevision).TimestampNanoSec())
+	})
+
+	t.Run("now unavailable", func(t *testing.T) {
+		rcr := newRevisions(0)
+		rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+			return datastore.NoRevision, context.DeadlineExceeded
+		})
+
+		_, err := rcr.MinimumValidRevision(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
 func TestRemoteClockStalenessBeyondGC(t *testing.T) {
 	// Set a GC window of 1 hour.
 	gcWindow := 1 * time.Hour
@@ -182,3 +446,309 @@ func TestRemoteClockStalenessBeyondGC(t *testing.T) {
 	err = rcr.CheckRevision(context.Background(), newOptimized)
 	require.NoError(t, err)
 }
+
+func TestHLCClockNowFunction(t *testing.T) {
+	gcWindow := 1 * time.Hour
+	quantization := 5 * time.Second
+
+	remoteClock := clock.NewMock()
+	remoteClock.Set(time.Unix(1000, 0))
+
+	rcr := NewRemoteClockRevisions(gcWindow, 0, 0, quantization)
+	rcr.SetClock(remoteClock)
+	rcr.SetNowFunc(HLCClockNowFunction(remoteClock))
+
+	ctx := context.Background()
+	optimized, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1000, 0).UnixNano(), optimized.(HLCRevision).TimestampNanoSec())
+
+	// The revision is reused until the quantization bucket rolls over.
+	remoteClock.Add(quantization - time.Nanosecond)
+	reused, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.True(t, optimized.Equal(reused))
+
+	remoteClock.Add(time.Nanosecond)
+	next, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1005, 0).UnixNano(), next.(HLCRevision).TimestampNanoSec())
+
+	// The revision remains valid up to and including the end of the GC window.
+	remoteClock.Set(time.Unix(1000, 0).Add(gcWindow))
+	require.NoError(t, rcr.CheckRevision(ctx, optimized))
+
+	remoteClock.Add(time.Nanosecond)
+	var invalidErr datastore.InvalidRevisionError
+	require.ErrorAs(t, rcr.CheckRevision(ctx, optimized), &invalidErr)
+	require.Equal(t, datastore.RevisionStale, invalidErr.Reason())
+}
+
+func TestRemoteClockRefreshOptimizedRevision(t *testing.T) {
+	quantization := 5 * time.Second
+
+	remoteClock := clock.NewMock()
+	remoteClock.Set(time.Unix(1000, 0))
+
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, quantization)
+	rcr.SetClock(remoteClock)
+	rcr.SetNowFunc(HLCClockNowFunction(remoteClock))
+
+	ctx := context.Background()
+	optimized, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1000, 0).UnixNano(), optimized.(HLCRevision).TimestampNanoSec())
+
+	// Within the quantization window, the cached revision is returned until
+	// refreshed.
+	remoteClock.Add(2 * time.Second)
+	cached, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.True(t, optimized.Equal(cached))
+
+	refreshed, err := rcr.RefreshOptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1002, 0).UnixNano(), refreshed.(HLCRevision).TimestampNanoSec())
+
+	afterRefresh, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.True(t, refreshed.Equal(afterRefresh))
+
+	// The refreshed revision is replaced at the end of the quantization window.
+	remoteClock.Add(3 * time.Second)
+	next, err := rcr.OptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1005, 0).UnixNano(), next.(HLCRevision).TimestampNanoSec())
+}
+
+func TestQuantizeRevision(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	for _, tc := range []struct {
+		name     string
+		ts       time.Time
+		window   time.Duration
+		expected time.Time
+	}{
+		{"no quantization", base.Add(123 * time.Millisecond), 0, base.Add(123 * time.Millisecond)},
+		{"negative window", base.Add(123 * time.Millisecond), -time.Second, base.Add(123 * time.Millisecond)},
+		{"on boundary", base, 5 * time.Second, base},
+		{"just after boundary", base.Add(time.Nanosecond), 5 * time.Second, base},
+		{"within bucket", base.Add(3 * time.Second), 5 * time.Second, base},
+		{"just before next boundary", base.Add(5*time.Second - time.Nanosecond), 5 * time.Second, base},
+		{"next boundary", base.Add(5 * time.Second), 5 * time.Second, base.Add(5 * time.Second)},
+		{"window not dividing a second", time.Unix(0, int64(100*time.Millisecond)), 7 * time.Millisecond, time.Unix(0, int64(98*time.Millisecond))},
+		{"before the epoch", time.Unix(-3, 0), 2 * time.Second, time.Unix(-4, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.True(t, tc.expected.Equal(QuantizeRevision(tc.ts, tc.window)), "got %s", QuantizeRevision(tc.ts, tc.window))
+		})
+	}
+
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 5*time.Second)
+	require.True(t, base.Equal(rcr.QuantizeRevision(base.Add(3*time.Second))))
+
+	// QuantizeRevision agrees with the optimized revisions.
+	rcr.SetNowFunc(func(context.Context) (datastore.Revision, error) {
+		return NewForTime(base.Add(3 * time.Second)), nil
+	})
+	optimized, validFor, err := rcr.optimizedRevisionFunc(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, base.UnixNano(), optimized.(WithTimestampRevision).TimestampNanoSec())
+	require.Equal(t, 2*time.Second, validFor)
+}
+
+func TestQuantizationAlignment(t *testing.T) {
+	minute := time.Date(2024, 1, 1, 12, 34, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name             string
+		ts               time.Time
+		window           time.Duration
+		expectedStart    time.Time
+		expectedValidFor time.Duration
+	}{
+		{"start of minute", minute.Add(3 * time.Second), 7 * time.Second, minute, 4 * time.Second},
+		{"within minute", minute.Add(15 * time.Second), 7 * time.Second, minute.Add(14 * time.Second), 6 * time.Second},
+		{"shortened last window", minute.Add(58 * time.Second), 7 * time.Second, minute.Add(56 * time.Second), 2 * time.Second},
+		{"sub-second window", minute.Add(1100 * time.Millisecond), 300 * time.Millisecond, minute.Add(1 * time.Second), 200 * time.Millisecond},
+		{"aligned to the day", minute, 5 * time.Hour, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), 2*time.Hour + 26*time.Minute},
+		{"longer than a day", minute, 72 * time.Hour, QuantizeRevision(minute, 72*time.Hour), QuantizeRevision(minute, 72*time.Hour).Add(72 * time.Hour).Sub(minute)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rcr := NewRemoteClockRevisions(100*24*time.Hour, 0, 0, tc.window)
+			rcr.SetQuantizationAlignment(WallClockAlignment)
+			require.True(t, tc.expectedStart.Equal(rcr.QuantizeRevision(tc.ts)), "got %s", rcr.QuantizeRevision(tc.ts))
+
+			rcr.SetNowFunc(func(context.Context) (datastore.Revision, error) {
+				return NewForTime(tc.ts), nil
+			})
+			optimized, validFor, err := rcr.optimizedRevisionFunc(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedStart.UnixNano(), optimized.(WithTimestampRevision).TimestampNanoSec())
+			require.Equal(t, tc.expectedValidFor, validFor)
+		})
+	}
+
+	// The default alignment is relative to the Unix epoch.
+	rcr := NewRemoteClockRevisions(time.Hour, 0, 0, 7*time.Second)
+	require.True(t, QuantizeRevision(minute, 7*time.Second).Equal(rcr.QuantizeRevision(minute)))
+	require.False(t, minute.Equal(rcr.QuantizeRevision(minute)))
+}
+
+func TestRemoteClockBackwardsRevision(t *testing.T) {
+	later := NewForTimestamp(12350 * 1_000_000_000)
+	earlier := NewForTimestamp(12345 * 1_000_000_000)
+
+	newRevisions := func(policy BackwardsRevisionPolicy) *RemoteClockRevisions {
+		rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 0)
+		rcr.SetBackwardsRevisionPolicy(policy)
+
+		nows := []datastore.Revision{later, earlier}
+		rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+			now := nows[0]
+			nows = nows[1:]
+			return now, nil
+		})
+		return rcr
+	}
+
+	t.Run("clamp", func(t *testing.T) {
+		rcr := newRevisions(ClampBackwardsRevision)
+		for range 2 {
+			rev, err := rcr.RefreshOptimizedRevision(context.Background())
+			require.NoError(t, err)
+			require.True(t, later.Equal(rev), "got %s", rev)
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		rcr := newRevisions(FailBackwardsRevision)
+		rev, err := rcr.RefreshOptimizedRevision(context.Background())
+		require.NoError(t, err)
+		require.True(t, later.Equal(rev))
+
+		_, _, err = rcr.optimizedRevisionFunc(context.Background())
+		var backwardsErr RevisionWentBackwardsError
+		require.ErrorAs(t, err, &backwardsErr)
+		require.True(t, earlier.Equal(backwardsErr.Observed))
+		require.True(t, later.Equal(backwardsErr.Previous))
+	})
+}
+
+func TestRemoteClockConcurrentMonotonicNow(t *testing.T) {
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 0)
+	rcr.SetBackwardsRevisionPolicy(FailBackwardsRevision)
+
+	// Each read returns a later revision, so no caller may see the revision
+	// go backwards, however the reads interleave. Yielding after the read
+	// gives other callers the chance to record a later revision before it is
+	// compared.
+	var now atomic.Int64
+	rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+		rev := NewForTimestamp(now.Add(1))
+		runtime.Gosched()
+		return rev, nil
+	})
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_, err := rcr.monotonicNow(context.Background())
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRemoteClockMonotonicNowReadsOutsideLock(t *testing.T) {
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 0)
+	rcr.SetBackwardsRevisionPolicy(FailBackwardsRevision)
+
+	earlier := NewForTimestamp(12345 * 1_000_000_000)
+	later := NewForTimestamp(12350 * 1_000_000_000)
+
+	// The first read does not return until a second, concurrent read has
+	// recorded a later revision, which it could not do were the first read
+	// made under the lock.
+	slowStarted := make(chan struct{})
+	fastDone := make(chan struct{})
+	var calls atomic.Int32
+	rcr.SetNowFunc(func(ctx context.Context) (datastore.Revision, error) {
+		if calls.Add(1) == 1 {
+			close(slowStarted)
+			select {
+			case <-fastDone:
+			case <-time.After(5 * time.Second):
+				return datastore.NoRevision, errors.New("concurrent read was blocked")
+			}
+			return earlier, nil
+		}
+		return later, nil
+	})
+
+	var slowRev datastore.Revision
+	var slowErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slowRev, slowErr = rcr.monotonicNow(context.Background())
+	}()
+
+	<-slowStarted
+	fastRev, err := rcr.monotonicNow(context.Background())
+	require.NoError(t, err)
+	require.True(t, later.Equal(fastRev))
+	close(fastDone)
+	wg.Wait()
+
+	// The slower read was only superseded by the concurrent one, so the
+	// revision has not gone backwards, and the later one is used.
+	require.NoError(t, slowErr)
+	require.True(t, later.Equal(slowRev), "got %s", slowRev)
+}
+
+func TestRemoteClockMaxCachedRevisionAge(t *testing.T) {
+	remoteClock := clock.NewMock()
+	remoteClock.Set(time.Unix(1000, 0))
+
+	rcr := NewRemoteClockRevisions(1*time.Hour, 0, 0, 1*time.Minute)
+	rcr.SetClock(remoteClock)
+	rcr.SetNowFunc(HLCClockNowFunction(remoteClock))
+	rcr.SetMaxCachedRevisionAge(10 * time.Second)
+
+	ctx := context.Background()
+	expectRevision := func(unixTime int64) {
+		t.Helper()
+		optimized, err := rcr.OptimizedRevision(ctx)
+		require.NoError(t, err)
+		require.Equal(t, time.Unix(unixTime, 0).UnixNano(), optimized.(HLCRevision).TimestampNanoSec())
+	}
+
+	// The start of the window, at 960, is too old to be handed out.
+	expectRevision(1000)
+
+	// The revision is cached for at most the maximum age, even though the
+	// window lasts until 1020.
+	remoteClock.Add(9 * time.Second)
+	expectRevision(1000)
+	remoteClock.Add(1 * time.Second)
+	expectRevision(1010)
+
+	// A window that has just begun is handed out as usual, until it is too
+	// old.
+	remoteClock.Set(time.Unix(1022, 0))
+	expectRevision(1020)
+	remoteClock.Set(time.Unix(1030, 0))
+	expectRevision(1030)
+
+	// Refreshed revisions are cached for at most the maximum age.
+	refreshed, err := rcr.RefreshOptimizedRevision(ctx)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1030, 0).UnixNano(), refreshed.(HLCRevision).TimestampNanoSec())
+	remoteClock.Add(10 * time.Second)
+	expectRevision(1040)
+}
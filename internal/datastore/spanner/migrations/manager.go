@@ -1,6 +1,8 @@
 package migrations
 
 import (
+	"context"
+
 	"cloud.google.com/go/spanner"
 
 	"github.com/authzed/spicedb/pkg/migrate"
@@ -8,3 +10,18 @@ import (
 
 // SpannerMigrations implements a migration manager for the Spanner datastore.
 var SpannerMigrations = migrate.NewManager[*SpannerMigrationDriver, Wrapper, *spanner.ReadWriteTransaction]()
+
+func init() {
+	if err := migrate.RegisterEngine("spanner", migrate.Engine{
+		NewRunner: func(ctx context.Context, uri string, options migrate.RunnerOptions) (migrate.Runner, error) {
+			driver, err := NewSpannerDriver(ctx, uri, options.CredentialsFile, options.EmulatorHost)
+			if err != nil {
+				return nil, err
+			}
+			return migrate.NewRunner(SpannerMigrations, driver), nil
+		},
+		HeadRevision: SpannerMigrations.HeadRevision,
+	}); err != nil {
+		panic("failed to register migrations: " + err.Error())
+	}
+}
@@ -184,6 +184,8 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 	))
 	defer span.End()
 
+	ctx = datastore.WithStatementLabel(ctx, datastore.StatementLabelCheck)
+
 	if err := dispatch.CheckDepth(ctx, req); err != nil {
 		if req.Debug != v1.DispatchCheckRequest_ENABLE_BASIC_DEBUGGING {
 			return &v1.DispatchCheckResponse{
@@ -278,6 +280,8 @@ func (ld *localDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchE
 	))
 	defer span.End()
 
+	ctx = datastore.WithStatementLabel(ctx, datastore.StatementLabelExpand)
+
 	if err := dispatch.CheckDepth(ctx, req); err != nil {
 		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
 	}
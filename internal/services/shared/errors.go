@@ -172,12 +172,22 @@ func rewriteError(ctx context.Context, err error, config *ConfigForErrors) error
 
 	case errors.As(err, &datastore.ReadOnlyError{}):
 		return ErrServiceReadOnly
+	case errors.As(err, &datastore.OverloadedError{}):
+		return status.Errorf(codes.Unavailable, "%s", err)
 	case errors.As(err, &datastore.InvalidRevisionError{}):
 		return status.Errorf(codes.OutOfRange, "invalid zedtoken: %s", err)
 	case errors.As(err, &datastore.CaveatNameNotFoundError{}):
 		return spiceerrors.WithCodeAndReason(err, codes.FailedPrecondition, v1.ErrorReason_ERROR_REASON_UNKNOWN_CAVEAT)
+	case errors.As(err, &datastore.CaveatContextMismatchError{}):
+		return status.Errorf(codes.InvalidArgument, "%s", err)
 	case errors.As(err, &datastore.WatchDisabledError{}):
 		return status.Errorf(codes.FailedPrecondition, "%s", err)
+	case errors.As(err, &datastore.TooManyWatchesError{}):
+		return status.Errorf(codes.ResourceExhausted, "%s", err)
+	case errors.As(err, &datastore.WatchBufferMemoryExceededError{}):
+		return status.Errorf(codes.ResourceExhausted, "%s", err)
+	case errors.As(err, &datastore.TooManyUpdatesError{}):
+		return status.Errorf(codes.InvalidArgument, "%s", err)
 	case errors.As(err, &datastore.CounterAlreadyRegisteredError{}):
 		return spiceerrors.WithCodeAndReason(err, codes.FailedPrecondition, v1.ErrorReason_ERROR_REASON_COUNTER_ALREADY_REGISTERED)
 	case errors.As(err, &datastore.CounterNotRegisteredError{}):
@@ -2,6 +2,7 @@ package shared
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 func TestRewriteCanceledError(t *testing.T) {
@@ -26,6 +29,33 @@ func TestRewriteDeadlineExceededError(t *testing.T) {
 	grpcutil.RequireStatus(t, codes.DeadlineExceeded, errorRewritten)
 }
 
+func TestRewriteOverloadedError(t *testing.T) {
+	errorRewritten := RewriteError(context.Background(), fmt.Errorf("wrapped: %w", datastore.NewOverloadedErr()), nil)
+	grpcutil.RequireStatus(t, codes.Unavailable, errorRewritten)
+}
+
+func TestRewriteTooManyWatchesError(t *testing.T) {
+	errorRewritten := RewriteError(context.Background(), fmt.Errorf("wrapped: %w", datastore.NewTooManyWatchesErr(10)), nil)
+	grpcutil.RequireStatus(t, codes.ResourceExhausted, errorRewritten)
+}
+
+func TestRewriteWatchBufferMemoryExceededError(t *testing.T) {
+	errorRewritten := RewriteError(context.Background(), fmt.Errorf("wrapped: %w", datastore.NewWatchBufferMemoryExceededErr(1024)), nil)
+	grpcutil.RequireStatus(t, codes.ResourceExhausted, errorRewritten)
+}
+
+func TestRewriteTooManyUpdatesError(t *testing.T) {
+	errorRewritten := RewriteError(context.Background(), fmt.Errorf("wrapped: %w", datastore.NewTooManyUpdatesErr(20, 10)), nil)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, errorRewritten)
+}
+
+func TestRewriteCaveatContextMismatchError(t *testing.T) {
+	rel := tuple.MustParse("document:doc1#viewer@user:tom[somecaveat:{\"unknown\":1}]")
+	errorRewritten := RewriteError(context.Background(), fmt.Errorf("wrapped: %w", datastore.NewCaveatContextMismatchErr(rel, "unknown")), nil)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, errorRewritten)
+	require.ErrorContains(t, errorRewritten, "`unknown`, which is not a parameter of caveat `somecaveat`")
+}
+
 func TestRewriteMaximumDepthExceededError(t *testing.T) {
 	errorRewritten := RewriteError(context.Background(), dispatch.NewMaxDepthExceededError(nil), &ConfigForErrors{
 		MaximumAPIDepth: 50,
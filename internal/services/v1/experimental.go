@@ -37,7 +37,6 @@ import (
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/typesystem"
-	"github.com/authzed/spicedb/pkg/zedtoken"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
@@ -528,12 +527,12 @@ func (es *experimentalServer) ExperimentalReflectSchema(ctx context.Context, req
 	return &v1.ExperimentalReflectSchemaResponse{
 		Definitions: definitions,
 		Caveats:     caveats,
-		ReadAt:      zedtoken.MustNewFromRevision(atRevision),
+		ReadAt:      consistency.ZedTokenCodecFromContext(ctx).MustNewFromRevision(atRevision),
 	}, nil
 }
 
 func (es *experimentalServer) ExperimentalDiffSchema(ctx context.Context, req *v1.ExperimentalDiffSchemaRequest) (*v1.ExperimentalDiffSchemaResponse, error) {
-	atRevision, _, err := consistency.RevisionFromContext(ctx)
+	_, readAt, err := consistency.RevisionFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -543,7 +542,7 @@ func (es *experimentalServer) ExperimentalDiffSchema(ctx context.Context, req *v
 		return nil, shared.RewriteErrorWithoutConfig(ctx, err)
 	}
 
-	resp, err := convertDiff(diff, existingSchema, comparisonSchema, atRevision)
+	resp, err := convertDiff(diff, existingSchema, comparisonSchema, readAt)
 	if err != nil {
 		return nil, shared.RewriteErrorWithoutConfig(ctx, err)
 	}
@@ -753,7 +752,7 @@ func (es *experimentalServer) ExperimentalCountRelationships(ctx context.Context
 		CounterResult: &v1.ExperimentalCountRelationshipsResponse_ReadCounterValue{
 			ReadCounterValue: &v1.ReadCounterValue{
 				RelationshipCount: uintCount,
-				ReadAt:            zedtoken.MustNewFromRevision(headRev),
+				ReadAt:            consistency.ZedTokenCodecFromContext(ctx).MustNewFromRevision(headRev),
 			},
 		},
 	}, nil
@@ -15,6 +15,7 @@ import (
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/input"
 	"github.com/authzed/spicedb/pkg/testutil"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 func TestConvertDiff(t *testing.T) {
@@ -548,7 +549,7 @@ func TestConvertDiff(t *testing.T) {
 				diff,
 				&es,
 				&cs,
-				revisionparsing.MustParseRevisionForTest("1"),
+				zedtoken.MustNewFromRevision(revisionparsing.MustParseRevisionForTest("1")),
 			)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
@@ -32,7 +32,6 @@ import (
 	"github.com/authzed/spicedb/pkg/middleware/consistency"
 	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
-	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 var writeUpdateCounter = promauto.NewHistogramVec(prometheus.HistogramOpts{
@@ -379,7 +378,7 @@ func (ps *permissionServer) WriteRelationships(ctx context.Context, req *v1.Writ
 	}
 
 	return &v1.WriteRelationshipsResponse{
-		WrittenAt: zedtoken.MustNewFromRevision(revision),
+		WrittenAt: consistency.ZedTokenCodecFromContext(ctx).MustNewFromRevision(revision),
 	}, nil
 }
 
@@ -497,7 +496,7 @@ func (ps *permissionServer) DeleteRelationships(ctx context.Context, req *v1.Del
 	}
 
 	return &v1.DeleteRelationshipsResponse{
-		DeletedAt:        zedtoken.MustNewFromRevision(revision),
+		DeletedAt:        consistency.ZedTokenCodecFromContext(ctx).MustNewFromRevision(revision),
 		DeletionProgress: deletionProgress,
 	}, nil
 }
@@ -15,11 +15,11 @@ import (
 	"github.com/authzed/spicedb/internal/services/shared"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/genutil"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
 	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/generator"
 	"github.com/authzed/spicedb/pkg/schemadsl/input"
-	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 // NewSchemaServer creates a SchemaServiceServer instance.
@@ -101,7 +101,7 @@ func (ss *schemaServer) ReadSchema(ctx context.Context, _ *v1.ReadSchemaRequest)
 
 	return &v1.ReadSchemaResponse{
 		SchemaText: schemaText,
-		ReadAt:     zedtoken.MustNewFromRevision(headRevision),
+		ReadAt:     consistency.ZedTokenCodecFromContext(ctx).MustNewFromRevision(headRevision),
 	}, nil
 }
 
@@ -153,6 +153,6 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 	}
 
 	return &v1.WriteSchemaResponse{
-		WrittenAt: zedtoken.MustNewFromRevision(revision),
+		WrittenAt: consistency.ZedTokenCodecFromContext(ctx).MustNewFromRevision(revision),
 	}, nil
 }
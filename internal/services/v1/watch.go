@@ -15,9 +15,9 @@ import (
 	"github.com/authzed/spicedb/internal/services/shared"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/genutil/mapz"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
 	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
-	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 type watchServer struct {
@@ -48,10 +48,11 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 
 	ctx := stream.Context()
 	ds := datastoremw.MustFromContext(ctx)
+	codec := consistency.ZedTokenCodecFromContext(ctx)
 
 	var afterRevision datastore.Revision
 	if req.OptionalStartCursor != nil && req.OptionalStartCursor.Token != "" {
-		decodedRevision, err := zedtoken.DecodeRevision(req.OptionalStartCursor, ds)
+		decodedRevision, err := codec.DecodeRevision(req.OptionalStartCursor, ds)
 		if err != nil {
 			return status.Errorf(codes.InvalidArgument, "failed to decode start revision: %s", err)
 		}
@@ -101,7 +102,7 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 
 					if err := stream.Send(&v1.WatchResponse{
 						Updates:                     converted,
-						ChangesThrough:              zedtoken.MustNewFromRevision(update.Revision),
+						ChangesThrough:              codec.MustNewFromRevision(update.Revision),
 						OptionalTransactionMetadata: update.Metadata,
 					}); err != nil {
 						return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
@@ -114,6 +115,8 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
 			case errors.As(err, &datastore.WatchDisconnectedError{}):
 				return status.Errorf(codes.ResourceExhausted, "watch disconnected: %s", err)
+			case errors.As(err, &datastore.WatchCatchupWindowExceededError{}):
+				return status.Errorf(codes.OutOfRange, "watch start revision too old: %s", err)
 			default:
 				return status.Errorf(codes.Internal, "watch error: %s", err)
 			}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/crdb"
 )
 
 func TestDefaults(t *testing.T) {
@@ -18,6 +20,15 @@ func TestDefaults(t *testing.T) {
 	require.Equal(t, expected, received)
 }
 
+func TestDefaultCRDBOptions(t *testing.T) {
+	// The options built from the defaults of the command line must be
+	// accepted by the CRDB datastore.
+	options, err := crdbOptions(*DefaultDatastoreConfig())
+	require.NoError(t, err)
+	_, err = crdb.ResolveConfig("postgres://root@localhost:26257/spicedb", options)
+	require.NoError(t, err)
+}
+
 func TestLoadDatastoreFromFileContents(t *testing.T) {
 	ctx := context.Background()
 	ds, err := NewDatastore(ctx,
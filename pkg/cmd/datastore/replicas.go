@@ -0,0 +1,73 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const (
+	// ReplicaVerificationWarn logs a warning for each read replica that is
+	// unreachable on startup.
+	ReplicaVerificationWarn = "warn"
+
+	// ReplicaVerificationFailIfAllDown logs a warning for each read replica
+	// that is unreachable on startup, and fails the startup if none is
+	// reachable.
+	ReplicaVerificationFailIfAllDown = "fail-if-all-down"
+)
+
+var replicaVerificationPolicies = []string{ReplicaVerificationWarn, ReplicaVerificationFailIfAllDown}
+
+// replicaVerificationTimeout bounds the time spent checking each read replica.
+const replicaVerificationTimeout = 5 * time.Second
+
+// verifyReplicas checks that each read replica of the primary is reachable,
+// by querying its ready state, and handles the unreachable ones per the
+// policy. Replicas are identified in the logs by their index, as their URIs
+// may hold credentials. If the verification fails, the primary and the
+// replicas are closed, as they will not be used.
+func verifyReplicas[T datastore.ReadOnlyDatastore](ctx context.Context, policy string, primary datastore.Datastore, replicas ...T) error {
+	if err := checkReplicas(ctx, policy, replicas...); err != nil {
+		if closeErr := primary.Close(); closeErr != nil {
+			log.Ctx(ctx).Warn().Err(closeErr).Msg("unable to close primary datastore")
+		}
+		for index, replica := range replicas {
+			if closeErr := replica.Close(); closeErr != nil {
+				log.Ctx(ctx).Warn().Err(closeErr).Int("replica", index).Msg("unable to close read replica")
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func checkReplicas[T datastore.ReadOnlyDatastore](ctx context.Context, policy string, replicas ...T) error {
+	switch policy {
+	case ReplicaVerificationWarn, ReplicaVerificationFailIfAllDown:
+	default:
+		return fmt.Errorf("unknown read replica verification policy %q", policy)
+	}
+
+	var down int
+	for index, replica := range replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, replicaVerificationTimeout)
+		_, err := replica.ReadyState(checkCtx)
+		cancel()
+
+		if err != nil {
+			down++
+			log.Ctx(ctx).Warn().Err(err).Int("replica", index).Msg("read replica is unreachable")
+			continue
+		}
+		log.Ctx(ctx).Info().Int("replica", index).Msg("read replica is reachable")
+	}
+
+	if down > 0 && down == len(replicas) && policy == ReplicaVerificationFailIfAllDown {
+		return fmt.Errorf("none of the %d read replicas is reachable", len(replicas))
+	}
+	return nil
+}
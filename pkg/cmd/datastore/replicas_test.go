@@ -0,0 +1,80 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+type fakeReplica struct {
+	datastore.ReadOnlyDatastore
+	err    error
+	closed *bool
+}
+
+func (r fakeReplica) ReadyState(context.Context) (datastore.ReadyState, error) {
+	return datastore.ReadyState{IsReady: r.err == nil}, r.err
+}
+
+func (r fakeReplica) Close() error {
+	*r.closed = true
+	return nil
+}
+
+type fakePrimary struct {
+	datastore.Datastore
+	closed bool
+}
+
+func (p *fakePrimary) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestVerifyReplicas(t *testing.T) {
+	errRefused := errors.New("connection refused")
+
+	testCases := []struct {
+		name          string
+		policy        string
+		replicaErrs   []error
+		expectedError string
+	}{
+		{"warn with some down", ReplicaVerificationWarn, []error{nil, errRefused}, ""},
+		{"warn with all down", ReplicaVerificationWarn, []error{errRefused, errRefused}, ""},
+		{"fail if all down with some down", ReplicaVerificationFailIfAllDown, []error{nil, errRefused}, ""},
+		{"fail if all down without replicas", ReplicaVerificationFailIfAllDown, nil, ""},
+		{"fail if all down with all down", ReplicaVerificationFailIfAllDown, []error{errRefused, errRefused}, "none of the 2 read replicas is reachable"},
+		{"unknown policy", "fail-always", []error{nil}, "unknown read replica verification policy"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			primary := &fakePrimary{}
+			closed := make([]bool, len(tc.replicaErrs))
+			replicas := make([]fakeReplica, 0, len(tc.replicaErrs))
+			for index, err := range tc.replicaErrs {
+				replicas = append(replicas, fakeReplica{err: err, closed: &closed[index]})
+			}
+
+			err := verifyReplicas(context.Background(), tc.policy, primary, replicas...)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				require.False(t, primary.closed)
+				require.NotContains(t, closed, true)
+				return
+			}
+
+			// The datastores are closed when the verification fails, as they
+			// will not be used.
+			require.ErrorContains(t, err, tc.expectedError)
+			require.True(t, primary.closed)
+			require.NotContains(t, closed, false)
+		})
+	}
+}
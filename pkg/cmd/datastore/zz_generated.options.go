@@ -48,6 +48,8 @@ func (c *Config) ToOption() ConfigOption {
 		to.ReadReplicaConnPool = c.ReadReplicaConnPool
 		to.ReadReplicaURIs = c.ReadReplicaURIs
 		to.ReadReplicaCredentialsProviderName = c.ReadReplicaCredentialsProviderName
+		to.VerifyReplicasOnStartup = c.VerifyReplicasOnStartup
+		to.ReplicaVerificationPolicy = c.ReplicaVerificationPolicy
 		to.BootstrapFiles = c.BootstrapFiles
 		to.BootstrapFileContents = c.BootstrapFileContents
 		to.BootstrapOverwrite = c.BootstrapOverwrite
@@ -102,6 +104,8 @@ func (c Config) DebugMap() map[string]any {
 	debugMap["ReadReplicaConnPool"] = helpers.DebugValue(c.ReadReplicaConnPool, false)
 	debugMap["ReadReplicaURIs"] = helpers.SensitiveDebugValue(c.ReadReplicaURIs)
 	debugMap["ReadReplicaCredentialsProviderName"] = helpers.DebugValue(c.ReadReplicaCredentialsProviderName, false)
+	debugMap["VerifyReplicasOnStartup"] = helpers.DebugValue(c.VerifyReplicasOnStartup, false)
+	debugMap["ReplicaVerificationPolicy"] = helpers.DebugValue(c.ReplicaVerificationPolicy, false)
 	debugMap["BootstrapFiles"] = helpers.DebugValue(c.BootstrapFiles, true)
 	debugMap["BootstrapFileContents"] = helpers.DebugValue(c.BootstrapFileContents, false)
 	debugMap["BootstrapOverwrite"] = helpers.DebugValue(c.BootstrapOverwrite, false)
@@ -279,6 +283,20 @@ func WithReadReplicaCredentialsProviderName(readReplicaCredentialsProviderName s
 	}
 }
 
+// WithVerifyReplicasOnStartup returns an option that can set VerifyReplicasOnStartup on a Config
+func WithVerifyReplicasOnStartup(verifyReplicasOnStartup bool) ConfigOption {
+	return func(c *Config) {
+		c.VerifyReplicasOnStartup = verifyReplicasOnStartup
+	}
+}
+
+// WithReplicaVerificationPolicy returns an option that can set ReplicaVerificationPolicy on a Config
+func WithReplicaVerificationPolicy(replicaVerificationPolicy string) ConfigOption {
+	return func(c *Config) {
+		c.ReplicaVerificationPolicy = replicaVerificationPolicy
+	}
+}
+
 // WithBootstrapFiles returns an option that can append BootstrapFiless to Config.BootstrapFiles
 func WithBootstrapFiles(bootstrapFiles string) ConfigOption {
 	return func(c *Config) {
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	crdbmigrations "github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+	mysqlmigrations "github.com/authzed/spicedb/internal/datastore/mysql/migrations"
+	"github.com/authzed/spicedb/internal/datastore/postgres/migrations"
+	spannermigrations "github.com/authzed/spicedb/internal/datastore/spanner/migrations"
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+func TestHeadRevision(t *testing.T) {
+	for engine, manager := range map[string]interface{ HeadRevision() (string, error) }{
+		"cockroachdb": crdbmigrations.CRDBMigrations,
+		"mysql":       mysqlmigrations.Manager,
+		"postgres":    migrations.DatabaseMigrations,
+		"spanner":     spannermigrations.SpannerMigrations,
+	} {
+		t.Run(engine, func(t *testing.T) {
+			expected, err := manager.HeadRevision()
+			require.NoError(t, err)
+
+			head, err := HeadRevision(engine)
+			require.NoError(t, err)
+			require.Equal(t, expected, head)
+		})
+	}
+
+	require.Subset(t, migrate.RegisteredEngines(), []string{"cockroachdb", "mysql", "postgres", "spanner"})
+
+	_, err := HeadRevision("memory")
+	require.ErrorContains(t, err, "cannot migrate datastore engine type: memory")
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/authzed/spicedb/pkg/cmd/termination"
 	"github.com/authzed/spicedb/pkg/cmd/util"
 	"github.com/authzed/spicedb/pkg/runtime"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 const PresharedKeyFlag = "grpc-preshared-key"
@@ -112,6 +113,9 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) error {
 	apiFlags.Uint32Var(&config.MaxDeleteRelationshipsLimit, "max-delete-relationships-limit", 1000, "maximum number of relationships that can be deleted in a single request")
 	apiFlags.Uint32Var(&config.MaxLookupResourcesLimit, "max-lookup-resources-limit", 1000, "maximum number of resources that can be looked up in a single request")
 	apiFlags.Uint32Var(&config.MaxBulkExportRelationshipsLimit, "max-bulk-export-relationships-limit", 10_000, "maximum number of relationships that can be exported in a single request")
+	apiFlags.Uint32Var(&config.ZedTokenEmitVersion, "zedtoken-emit-version", uint32(zedtoken.EncodingV1), "version of the encoding of the revisions within the ZedTokens returned by the API. Upgrade by first deploying every server with the current version, then switching to the new one")
+	apiFlags.BoolVar(&config.ZedTokenRejectLegacy, "zedtoken-reject-legacy", false, "rejects ZedTokens encoded with a version older than the one set by --zedtoken-emit-version, instead of decoding them")
+	apiFlags.BoolVar(&config.ZedTokenEmbedOrigin, "zedtoken-embed-origin", false, "embeds the instance ID of the datastore in the ZedTokens returned by the API, and rejects those returned for another datastore, such as before a restore. Requires --zedtoken-emit-version=2 and a datastore that provides an instance ID")
 
 	datastoreFlags := nfs.FlagSet(BoldBlue("Datastore"))
 	// Flags for the datastore
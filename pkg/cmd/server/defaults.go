@@ -44,6 +44,7 @@ import (
 	"github.com/authzed/spicedb/pkg/middleware/serverversion"
 	"github.com/authzed/spicedb/pkg/releases"
 	"github.com/authzed/spicedb/pkg/runtime"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 var DisableTelemetryHandler *prometheus.Registry
@@ -189,6 +190,7 @@ type MiddlewareOption struct {
 	EnableResponseLog       bool                `debugmap:"visible"`
 	DisableGRPCHistogram    bool                `debugmap:"visible"`
 	MiddlewareServiceLabel  string              `debugmap:"visible"`
+	ZedTokenCodec           *zedtoken.Codec     `debugmap:"hidden"`
 
 	unaryDatastoreMiddleware  *ReferenceableMiddleware[grpc.UnaryServerInterceptor]  `debugmap:"hidden"`
 	streamDatastoreMiddleware *ReferenceableMiddleware[grpc.StreamServerInterceptor] `debugmap:"hidden"`
@@ -221,6 +223,7 @@ func (m MiddlewareOption) WithDatastoreMiddleware(middleware Middleware) Middlew
 		EnableResponseLog:         m.EnableResponseLog,
 		DisableGRPCHistogram:      m.DisableGRPCHistogram,
 		MiddlewareServiceLabel:    m.MiddlewareServiceLabel,
+		ZedTokenCodec:             m.ZedTokenCodec,
 		unaryDatastoreMiddleware:  &unary,
 		streamDatastoreMiddleware: &stream,
 	}
@@ -248,6 +251,7 @@ func (m MiddlewareOption) WithDatastore(ds datastore.Datastore) MiddlewareOption
 		EnableResponseLog:         m.EnableResponseLog,
 		DisableGRPCHistogram:      m.DisableGRPCHistogram,
 		MiddlewareServiceLabel:    m.MiddlewareServiceLabel,
+		ZedTokenCodec:             m.ZedTokenCodec,
 		unaryDatastoreMiddleware:  &unary,
 		streamDatastoreMiddleware: &stream,
 	}
@@ -350,7 +354,7 @@ func DefaultUnaryMiddleware(opts MiddlewareOption) (*MiddlewareChain[grpc.UnaryS
 
 		NewUnaryMiddleware().
 			WithName(DefaultInternalMiddlewareConsistency).
-			WithInterceptor(consistencymw.UnaryServerInterceptor(opts.MiddlewareServiceLabel)).
+			WithInterceptor(consistencymw.UnaryServerInterceptor(opts.MiddlewareServiceLabel, consistencymw.WithZedTokenCodec(opts.ZedTokenCodec))).
 			Done(),
 
 		NewUnaryMiddleware().
@@ -428,7 +432,7 @@ func DefaultStreamingMiddleware(opts MiddlewareOption) (*MiddlewareChain[grpc.St
 
 		NewStreamMiddleware().
 			WithName(DefaultInternalMiddlewareConsistency).
-			WithInterceptor(consistencymw.StreamServerInterceptor(opts.MiddlewareServiceLabel)).
+			WithInterceptor(consistencymw.StreamServerInterceptor(opts.MiddlewareServiceLabel, consistencymw.WithZedTokenCodec(opts.ZedTokenCodec))).
 			Done(),
 
 		NewStreamMiddleware().
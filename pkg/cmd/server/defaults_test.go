@@ -32,6 +32,7 @@ func TestWithDatastore(t *testing.T) {
 		"service",
 		nil,
 		nil,
+		nil,
 	}
 
 	someDS, err := memdb.NewMemdbDatastore(0, time.Hour, time.Hour)
@@ -72,6 +73,7 @@ func TestWithDatastoreMiddleware(t *testing.T) {
 		"anotherservice",
 		nil,
 		nil,
+		nil,
 	}
 
 	someMiddleware := pertoken.NewMiddleware(nil)
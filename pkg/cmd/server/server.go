@@ -45,6 +45,7 @@ import (
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/middleware/requestid"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 // ConsistentHashringBuilder is a balancer Builder that uses xxhash as the
@@ -121,6 +122,9 @@ type Config struct {
 	MaxBulkExportRelationshipsLimit          uint32        `debugmap:"visible"`
 	EnableExperimentalLookupResources        bool          `debugmap:"visible"`
 	EnableExperimentalRelationshipExpiration bool          `debugmap:"visible"`
+	ZedTokenEmitVersion                      uint32        `debugmap:"visible"`
+	ZedTokenRejectLegacy                     bool          `debugmap:"visible"`
+	ZedTokenEmbedOrigin                      bool          `debugmap:"visible"`
 
 	// Additional Services
 	MetricsAPI util.HTTPServerConfig `debugmap:"visible"`
@@ -186,6 +190,38 @@ func (c *closeableStack) CloseIfError(err error) error {
 	return nil
 }
 
+// zedTokenCodec returns the codec with which the API encodes and decodes
+// zedtokens, emitting the version set by ZedTokenEmitVersion, or
+// zedtoken.EncodingV1 if unset, and rejecting tokens of older versions if
+// ZedTokenRejectLegacy is set. If ZedTokenEmbedOrigin is set, the zedtokens
+// embed the instance ID of the datastore, and those of another datastore are
+// rejected.
+func (c *Config) zedTokenCodec(ctx context.Context, ds datastore.Datastore) (*zedtoken.Codec, error) {
+	opts := []zedtoken.CodecOption{zedtoken.WithAcceptLegacy(!c.ZedTokenRejectLegacy)}
+	if c.ZedTokenEmitVersion != 0 {
+		opts = append(opts, zedtoken.WithEmitVersion(zedtoken.EncodingVersion(c.ZedTokenEmitVersion)))
+	}
+
+	if c.ZedTokenEmbedOrigin {
+		identified := datastore.UnwrapAs[datastore.InstanceIdentifiedDatastore](ds)
+		if identified == nil {
+			return nil, fmt.Errorf("invalid zedtoken configuration: the datastore does not provide an instance ID to embed")
+		}
+
+		instanceID, err := identified.InstanceID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the datastore instance ID: %w", err)
+		}
+		opts = append(opts, zedtoken.WithOrigin(instanceID))
+	}
+
+	codec, err := zedtoken.NewCodec(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zedtoken configuration: %w", err)
+	}
+	return codec, nil
+}
+
 // Complete validates the config and fills out defaults.
 // if there is no error, a completedServerConfig (with limited options for
 // mutation) is returned.
@@ -238,6 +274,11 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 	}
 	closeables.AddWithError(ds.Close)
 
+	zedTokenCodec, err := c.zedTokenCodec(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+
 	nscc, err := CompleteCache[cache.StringKey, schemacaching.CacheEntry](&c.NamespaceCacheConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create namespace cache: %w", err)
@@ -394,6 +435,7 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		c.EnableResponseLogs,
 		c.DisableGRPCLatencyHistogram,
 		serverName,
+		zedTokenCodec,
 		nil,
 		nil,
 	}
@@ -8,10 +8,14 @@ import (
 	"time"
 
 	"github.com/authzed/spicedb/internal/datastore/dsfortesting"
+	"github.com/authzed/spicedb/internal/datastore/revisions"
 	"github.com/authzed/spicedb/internal/logging"
 	"github.com/authzed/spicedb/pkg/cmd/datastore"
 	"github.com/authzed/spicedb/pkg/cmd/util"
+	dspkg "github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/revisionparsing"
 	"github.com/authzed/spicedb/pkg/testutil"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/stretchr/testify/require"
@@ -177,6 +181,63 @@ func TestServerGracefulTerminationOnError(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestZedTokenCodecConfig(t *testing.T) {
+	c := ConfigWithOptions(&Config{})
+	codec, err := c.zedTokenCodec(context.Background(), nil)
+	require.NoError(t, err)
+	token, err := codec.NewFromRevision(revisionparsing.MustParseRevisionForTest("1"))
+	require.NoError(t, err)
+	require.Equal(t, zedtoken.MustNewFromRevision(revisionparsing.MustParseRevisionForTest("1")).Token, token.Token)
+
+	// A server emitting V2 zedtokens still decodes the V1 zedtokens emitted
+	// before the upgrade, unless legacy zedtokens are rejected.
+	c = ConfigWithOptions(&Config{}, WithZedTokenEmitVersion(uint32(zedtoken.EncodingV2)))
+	codec, err = c.zedTokenCodec(context.Background(), nil)
+	require.NoError(t, err)
+	legacy := zedtoken.MustNewFromRevision(revisionparsing.MustParseRevisionForTest("1"))
+	_, err = codec.DecodeRevision(legacy, revisions.CommonDecoder{Kind: revisions.HybridLogicalClock})
+	require.NoError(t, err)
+
+	c = ConfigWithOptions(&Config{}, WithZedTokenEmitVersion(uint32(zedtoken.EncodingV2)), WithZedTokenRejectLegacy(true))
+	codec, err = c.zedTokenCodec(context.Background(), nil)
+	require.NoError(t, err)
+	_, err = codec.DecodeRevision(legacy, revisions.CommonDecoder{Kind: revisions.HybridLogicalClock})
+	require.Error(t, err)
+
+	c = ConfigWithOptions(&Config{}, WithZedTokenEmitVersion(42))
+	_, err = c.zedTokenCodec(context.Background(), nil)
+	require.Error(t, err)
+
+	// Embedding the origin requires a datastore with an instance ID.
+	ds, err := dsfortesting.NewMemDBDatastoreForTesting(0, 1*time.Second, 10*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	c = ConfigWithOptions(&Config{}, WithZedTokenEmitVersion(uint32(zedtoken.EncodingV2)), WithZedTokenEmbedOrigin(true))
+	_, err = c.zedTokenCodec(context.Background(), ds)
+	require.Error(t, err)
+
+	codec, err = c.zedTokenCodec(context.Background(), instanceIdentifiedDatastore{ds, "first"})
+	require.NoError(t, err)
+	token, err = codec.NewFromRevision(revisionparsing.MustParseRevisionForTest("1"))
+	require.NoError(t, err)
+	require.NoError(t, codec.ValidateRevisionOrigin(token))
+
+	other, err := c.zedTokenCodec(context.Background(), instanceIdentifiedDatastore{ds, "second"})
+	require.NoError(t, err)
+	var originErr zedtoken.RevisionOriginError
+	require.ErrorAs(t, other.ValidateRevisionOrigin(token), &originErr)
+}
+
+type instanceIdentifiedDatastore struct {
+	dspkg.Datastore
+	instanceID string
+}
+
+func (ds instanceIdentifiedDatastore) InstanceID(context.Context) (string, error) {
+	return ds.instanceID, nil
+}
+
 func TestReplaceUnaryMiddleware(t *testing.T) {
 	c := Config{UnaryMiddlewareModification: []MiddlewareModification[grpc.UnaryServerInterceptor]{
 		{
@@ -231,7 +292,7 @@ func TestModifyUnaryMiddleware(t *testing.T) {
 		},
 	}}
 
-	opt := MiddlewareOption{logging.Logger, nil, false, nil, false, false, false, "testing", nil, nil}
+	opt := MiddlewareOption{logging.Logger, nil, false, nil, false, false, false, "testing", nil, nil, nil}
 	opt = opt.WithDatastore(nil)
 
 	defaultMw, err := DefaultUnaryMiddleware(opt)
@@ -259,7 +320,7 @@ func TestModifyStreamingMiddleware(t *testing.T) {
 		},
 	}}
 
-	opt := MiddlewareOption{logging.Logger, nil, false, nil, false, false, false, "testing", nil, nil}
+	opt := MiddlewareOption{logging.Logger, nil, false, nil, false, false, false, "testing", nil, nil, nil}
 	opt = opt.WithDatastore(nil)
 
 	defaultMw, err := DefaultStreamingMiddleware(opt)
@@ -3,6 +3,7 @@ package server
 
 import (
 	dispatch "github.com/authzed/spicedb/internal/dispatch"
+	zedtoken "github.com/authzed/spicedb/pkg/zedtoken"
 	defaults "github.com/creasty/defaults"
 	helpers "github.com/ecordell/optgen/helpers"
 	auth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
@@ -41,6 +42,7 @@ func (m *MiddlewareOption) ToOption() MiddlewareOptionOption {
 		to.EnableResponseLog = m.EnableResponseLog
 		to.DisableGRPCHistogram = m.DisableGRPCHistogram
 		to.MiddlewareServiceLabel = m.MiddlewareServiceLabel
+		to.ZedTokenCodec = m.ZedTokenCodec
 		to.unaryDatastoreMiddleware = m.unaryDatastoreMiddleware
 		to.streamDatastoreMiddleware = m.streamDatastoreMiddleware
 	}
@@ -128,3 +130,10 @@ func WithMiddlewareServiceLabel(middlewareServiceLabel string) MiddlewareOptionO
 		m.MiddlewareServiceLabel = middlewareServiceLabel
 	}
 }
+
+// WithZedTokenCodec returns an option that can set ZedTokenCodec on a MiddlewareOption
+func WithZedTokenCodec(zedTokenCodec *zedtoken.Codec) MiddlewareOptionOption {
+	return func(m *MiddlewareOption) {
+		m.ZedTokenCodec = zedTokenCodec
+	}
+}
@@ -89,6 +89,9 @@ func (c *Config) ToOption() ConfigOption {
 		to.MaxBulkExportRelationshipsLimit = c.MaxBulkExportRelationshipsLimit
 		to.EnableExperimentalLookupResources = c.EnableExperimentalLookupResources
 		to.EnableExperimentalRelationshipExpiration = c.EnableExperimentalRelationshipExpiration
+		to.ZedTokenEmitVersion = c.ZedTokenEmitVersion
+		to.ZedTokenRejectLegacy = c.ZedTokenRejectLegacy
+		to.ZedTokenEmbedOrigin = c.ZedTokenEmbedOrigin
 		to.MetricsAPI = c.MetricsAPI
 		to.UnaryMiddlewareModification = c.UnaryMiddlewareModification
 		to.StreamingMiddlewareModification = c.StreamingMiddlewareModification
@@ -158,6 +161,9 @@ func (c Config) DebugMap() map[string]any {
 	debugMap["MaxBulkExportRelationshipsLimit"] = helpers.DebugValue(c.MaxBulkExportRelationshipsLimit, false)
 	debugMap["EnableExperimentalLookupResources"] = helpers.DebugValue(c.EnableExperimentalLookupResources, false)
 	debugMap["EnableExperimentalRelationshipExpiration"] = helpers.DebugValue(c.EnableExperimentalRelationshipExpiration, false)
+	debugMap["ZedTokenEmitVersion"] = helpers.DebugValue(c.ZedTokenEmitVersion, false)
+	debugMap["ZedTokenRejectLegacy"] = helpers.DebugValue(c.ZedTokenRejectLegacy, false)
+	debugMap["ZedTokenEmbedOrigin"] = helpers.DebugValue(c.ZedTokenEmbedOrigin, false)
 	debugMap["MetricsAPI"] = helpers.DebugValue(c.MetricsAPI, false)
 	debugMap["SilentlyDisableTelemetry"] = helpers.DebugValue(c.SilentlyDisableTelemetry, false)
 	debugMap["TelemetryCAOverridePath"] = helpers.DebugValue(c.TelemetryCAOverridePath, false)
@@ -570,6 +576,27 @@ func WithEnableExperimentalRelationshipExpiration(enableExperimentalRelationship
 	}
 }
 
+// WithZedTokenEmitVersion returns an option that can set ZedTokenEmitVersion on a Config
+func WithZedTokenEmitVersion(zedTokenEmitVersion uint32) ConfigOption {
+	return func(c *Config) {
+		c.ZedTokenEmitVersion = zedTokenEmitVersion
+	}
+}
+
+// WithZedTokenRejectLegacy returns an option that can set ZedTokenRejectLegacy on a Config
+func WithZedTokenRejectLegacy(zedTokenRejectLegacy bool) ConfigOption {
+	return func(c *Config) {
+		c.ZedTokenRejectLegacy = zedTokenRejectLegacy
+	}
+}
+
+// WithZedTokenEmbedOrigin returns an option that can set ZedTokenEmbedOrigin on a Config
+func WithZedTokenEmbedOrigin(zedTokenEmbedOrigin bool) ConfigOption {
+	return func(c *Config) {
+		c.ZedTokenEmbedOrigin = zedTokenEmbedOrigin
+	}
+}
+
 // WithMetricsAPI returns an option that can set MetricsAPI on a Config
 func WithMetricsAPI(metricsAPI util.HTTPServerConfig) ConfigOption {
 	return func(c *Config) {
@@ -1 +1,73 @@
 package datastore
+
+import "context"
+
+type ctxFollowerReadsDisabled struct{}
+
+// WithFollowerReadsDisabled returns a context that instructs datastores which
+// support follower reads to serve the operation at the current revision from
+// the primary (write) connection pool, even if a follower read delay has been
+// configured. This should be used for operations which must observe writes
+// that have just been made, such as a check immediately following a write.
+func WithFollowerReadsDisabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxFollowerReadsDisabled{}, true)
+}
+
+// FollowerReadsDisabled returns true if follower reads have been disabled for
+// the operation via WithFollowerReadsDisabled.
+func FollowerReadsDisabled(ctx context.Context) bool {
+	disabled, ok := ctx.Value(ctxFollowerReadsDisabled{}).(bool)
+	return ok && disabled
+}
+
+type ctxStatementLabel struct{}
+
+const (
+	// StatementLabelCheck labels the statements issued to compute a permission
+	// check.
+	StatementLabelCheck = "check"
+
+	// StatementLabelExpand labels the statements issued to expand a
+	// permission.
+	StatementLabelExpand = "expand"
+
+	// StatementLabelWatch labels the statements issued to watch for changes.
+	StatementLabelWatch = "watch"
+
+	// StatementLabelWrite labels the statements issued by read-write
+	// transactions.
+	StatementLabelWrite = "write"
+)
+
+type ctxRelationshipMetadata struct{}
+
+// WithRelationshipMetadata returns a context whose relationship writes set the
+// given values of the metadata columns of the relationships created or
+// touched, on datastores which support metadata columns.
+func WithRelationshipMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	return context.WithValue(ctx, ctxRelationshipMetadata{}, metadata)
+}
+
+// RelationshipMetadata returns the metadata set for relationship writes via
+// WithRelationshipMetadata, or nil if none has been set.
+func RelationshipMetadata(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(ctxRelationshipMetadata{}).(map[string]string)
+	return metadata
+}
+
+// WithStatementLabel returns a context that labels the statements issued by
+// the datastore for the operation with the given class of operation, such as
+// StatementLabelCheck. Datastores which support statement labels, and have them
+// enabled, attach the label to each statement so that the statements can be
+// attributed in the database's own diagnostics. Labels may only contain ASCII
+// letters, digits, `_`, `.`, `:` and `-`; other labels are not attached.
+func WithStatementLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, ctxStatementLabel{}, label)
+}
+
+// StatementLabel returns the label set for the operation via
+// WithStatementLabel, or the empty string if none has been set.
+func StatementLabel(ctx context.Context) string {
+	label, _ := ctx.Value(ctxStatementLabel{}).(string)
+	return label
+}
@@ -586,6 +586,13 @@ type WatchOptions struct {
 	// EmissionStrategy defines when are changes streamed to the client. If unspecified, changes will be buffered until
 	// they can be checkpointed, which is the default behavior.
 	EmissionStrategy EmissionStrategy
+
+	// OptionalResourceTypes, if non-empty, restricts the relationship changes emitted
+	// to those whose resource type is in the list, so that changes to other types do
+	// not consume the watch buffer.
+	// May not be supported by the datastore, in which case changes for all resource
+	// types are emitted; callers must continue to filter the changes they receive.
+	OptionalResourceTypes []string
 }
 
 // EmissionStrategy describes when changes are emitted to the client.
@@ -732,6 +739,34 @@ type RepairableDatastore interface {
 	RepairOperations() []RepairOperation
 }
 
+// PagedRelationshipsDatastore is an optional extension to the datastore interface that, when
+// implemented, provides the ability for callers to list relationships page by page, with each page
+// continuing from the cursor returned with the previous one and every page read at the same
+// revision.
+type PagedRelationshipsDatastore interface {
+	Datastore
+
+	// ListRelationshipsPage returns a page of at most limit of the relationships matching the
+	// filter, along with the cursor from which to read the next page. An empty cursor reads the
+	// first page, and the returned cursor is empty once the last page has been read.
+	ListRelationshipsPage(ctx context.Context, filter RelationshipsFilter, cursor string, limit uint64) ([]tuple.Relationship, string, error)
+
+	// ListRelationshipsWithCaveatPage returns a page of the relationships, of any resource type,
+	// whose caveat is the named caveat, paging as ListRelationshipsPage does.
+	ListRelationshipsWithCaveatPage(ctx context.Context, caveatName string, cursor string, limit uint64) ([]tuple.Relationship, string, error)
+}
+
+// InstanceIdentifiedDatastore is an optional extension to the datastore interface that, when
+// implemented, provides the unique ID of the datastore's database, which is the same for every
+// process using the database and differs between databases, such as after a restore into a new
+// cluster.
+type InstanceIdentifiedDatastore interface {
+	Datastore
+
+	// InstanceID returns the unique ID of the datastore's database.
+	InstanceID(ctx context.Context) (string, error)
+}
+
 // UnwrappableDatastore represents a datastore that can be unwrapped into the underlying
 // datastore.
 type UnwrappableDatastore interface {
@@ -3,10 +3,13 @@ package datastore
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog"
 
+	"github.com/authzed/spicedb/pkg/datastore/options"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 // ErrNotFound is a shared interface for not found errors.
@@ -53,10 +56,95 @@ type WatchCanceledError struct{ error }
 // WatchDisabledError occurs when watch is disabled by being unsupported by the datastore.
 type WatchDisabledError struct{ error }
 
+// WatchCatchupWindowExceededError occurs when a watch was requested to start from a revision older
+// than the datastore is willing to replay changes from. The caller should re-read the current state
+// and watch from its revision instead.
+type WatchCatchupWindowExceededError struct {
+	error
+	revision Revision
+	window   time.Duration
+}
+
+// StartRevision is the revision from which the watch was requested to start.
+func (err WatchCatchupWindowExceededError) StartRevision() Revision {
+	return err.revision
+}
+
+// Window is the maximum age of the revision from which a watch may start.
+func (err WatchCatchupWindowExceededError) Window() time.Duration {
+	return err.window
+}
+
+// ResultsTruncatedError is returned by a relationships iterator, after the
+// maximum number of results has been yielded, when the query has further
+// results. The remaining results can be read by querying again after Cursor.
+type ResultsTruncatedError struct {
+	error
+	limit  uint64
+	cursor options.Cursor
+}
+
+// Limit is the maximum number of results returned by a single query.
+func (err ResultsTruncatedError) Limit() uint64 {
+	return err.limit
+}
+
+// Cursor is the cursor after which the remaining results can be read.
+func (err ResultsTruncatedError) Cursor() options.Cursor {
+	return err.cursor
+}
+
+// TooManyWatchesError is returned when a watch was rejected because the datastore is already serving
+// the maximum number of concurrent watches. The caller *may* retry the watch after others have ended.
+type TooManyWatchesError struct {
+	error
+	limit int
+}
+
+// Limit is the maximum number of concurrent watches served by the datastore.
+func (err TooManyWatchesError) Limit() int {
+	return err.limit
+}
+
+// WatchBufferMemoryExceededError is returned when a watch was rejected because the changes buffered by
+// the watches the datastore is serving already exceed the maximum total size. The caller *may* retry
+// the watch once the other watches have drained their buffers.
+type WatchBufferMemoryExceededError struct {
+	error
+	limit int
+}
+
+// Limit is the maximum total size, in bytes, of the changes buffered by the watches of the datastore.
+func (err WatchBufferMemoryExceededError) Limit() int {
+	return err.limit
+}
+
+// TooManyUpdatesError is returned when a write was rejected because it contains more relationship
+// updates than the datastore accepts in a single write. The caller should split the write.
+type TooManyUpdatesError struct {
+	error
+	count int
+	limit int
+}
+
+// Count is the number of relationship updates in the rejected write.
+func (err TooManyUpdatesError) Count() int {
+	return err.count
+}
+
+// Limit is the maximum number of relationship updates accepted in a single write.
+func (err TooManyUpdatesError) Limit() int {
+	return err.limit
+}
+
 // ReadOnlyError is returned when the operation cannot be completed because the datastore is in
 // read-only mode.
 type ReadOnlyError struct{ error }
 
+// OverloadedError is returned when the operation was rejected because the datastore has too many
+// operations waiting to be performed. The caller *may* retry the operation after some backoff time.
+type OverloadedError struct{ error }
+
 // WatchRetryableError is returned when a transient/temporary error occurred in watch and indicates that
 // the caller *may* retry the watch after some backoff time.
 type WatchRetryableError struct{ error }
@@ -72,6 +160,11 @@ const (
 	// CouldNotDetermineRevision is the reason returned when a revision for a
 	// request could not be determined.
 	CouldNotDetermineRevision
+
+	// RevisionInFuture is the reason returned when a revision is newer than
+	// the latest revision known to the datastore, such as after a failover to
+	// a replica that has not yet caught up.
+	RevisionInFuture
 )
 
 // InvalidRevisionError occurs when a revision specified to a call was invalid.
@@ -98,6 +191,8 @@ func (err InvalidRevisionError) MarshalZerologObject(e *zerolog.Event) {
 		e.Err(err.error).Str("reason", "stale")
 	case CouldNotDetermineRevision:
 		e.Err(err.error).Str("reason", "indeterminate")
+	case RevisionInFuture:
+		e.Err(err.error).Str("reason", "future")
 	default:
 		e.Err(err.error).Str("reason", "unknown")
 	}
@@ -132,6 +227,54 @@ func NewWatchDisabledErr(reason string) error {
 	}
 }
 
+// NewResultsTruncatedErr constructs a new error for when a query had more
+// results than the maximum that may be returned.
+func NewResultsTruncatedErr(limit uint64, cursor options.Cursor) error {
+	return ResultsTruncatedError{
+		error:  fmt.Errorf("query results were truncated to the maximum of %d; query again after the returned cursor for the rest", limit),
+		limit:  limit,
+		cursor: cursor,
+	}
+}
+
+// NewWatchCatchupWindowExceededErr constructs a new error for when a watch was requested to start
+// from a revision older than the maximum catch-up window.
+func NewWatchCatchupWindowExceededErr(revision Revision, window time.Duration) error {
+	return WatchCatchupWindowExceededError{
+		error:    fmt.Errorf("watch start revision %s is older than the maximum catch-up window of %s; read the current state and watch from its revision instead", revision, window),
+		revision: revision,
+		window:   window,
+	}
+}
+
+// NewTooManyWatchesErr constructs a new error for when a watch was rejected because the datastore is
+// already serving the maximum number of concurrent watches.
+func NewTooManyWatchesErr(limit int) error {
+	return TooManyWatchesError{
+		error: fmt.Errorf("too many watches: the datastore is already serving the maximum of %d concurrent watches", limit),
+		limit: limit,
+	}
+}
+
+// NewWatchBufferMemoryExceededErr constructs a new error for when a watch was rejected because the
+// changes buffered by the watches of the datastore already exceed the maximum total size.
+func NewWatchBufferMemoryExceededErr(limit int) error {
+	return WatchBufferMemoryExceededError{
+		error: fmt.Errorf("too many buffered watch changes: the watches of the datastore already buffer more than the maximum of %d bytes", limit),
+		limit: limit,
+	}
+}
+
+// NewTooManyUpdatesErr constructs a new error for when a write was rejected because it contains more
+// relationship updates than the maximum accepted in a single write.
+func NewTooManyUpdatesErr(count, limit int) error {
+	return TooManyUpdatesError{
+		error: fmt.Errorf("too many updates: the write contains %d relationship updates, more than the maximum of %d", count, limit),
+		count: count,
+		limit: limit,
+	}
+}
+
 // NewWatchTemporaryErr wraps another error in watch, indicating that the error is likely
 // a temporary condition and clients may consider retrying by calling watch again (vs a fatal error).
 func NewWatchTemporaryErr(wrapped error) error {
@@ -148,6 +291,14 @@ func NewReadonlyErr() error {
 	}
 }
 
+// NewOverloadedErr constructs an error for when a request has been rejected because the
+// datastore is overloaded.
+func NewOverloadedErr() error {
+	return OverloadedError{
+		error: fmt.Errorf("datastore is overloaded, please retry later"),
+	}
+}
+
 // NewInvalidRevisionErr constructs a new invalid revision error.
 func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) error {
 	switch reason {
@@ -158,6 +309,13 @@ func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) erro
 			reason:   reason,
 		}
 
+	case RevisionInFuture:
+		return InvalidRevisionError{
+			error:    fmt.Errorf("revision is newer than the latest known revision"),
+			revision: revision,
+			reason:   reason,
+		}
+
 	default:
 		return InvalidRevisionError{
 			error:    fmt.Errorf("revision was invalid"),
@@ -199,6 +357,44 @@ func (err CaveatNameNotFoundError) DetailsMetadata() map[string]string {
 	}
 }
 
+// CaveatContextMismatchError is returned by datastores that validate the
+// caveats of the relationships they write, for a relationship whose caveat
+// context has a key that is not a parameter of the caveat.
+type CaveatContextMismatchError struct {
+	error
+	caveatName string
+	parameter  string
+}
+
+// CaveatName returns the name of the caveat whose context did not match.
+func (err CaveatContextMismatchError) CaveatName() string {
+	return err.caveatName
+}
+
+// Parameter returns the key of the context that is not a parameter of the
+// caveat.
+func (err CaveatContextMismatchError) Parameter() string {
+	return err.parameter
+}
+
+// NewCaveatContextMismatchErr constructs a new caveat context mismatch error.
+func NewCaveatContextMismatchErr(rel tuple.Relationship, parameter string) error {
+	return CaveatContextMismatchError{
+		error: fmt.Errorf("context of relationship `%s` has key `%s`, which is not a parameter of caveat `%s`",
+			tuple.StringWithoutCaveatOrExpiration(rel), parameter, rel.OptionalCaveat.CaveatName),
+		caveatName: rel.OptionalCaveat.CaveatName,
+		parameter:  parameter,
+	}
+}
+
+// DetailsMetadata returns the metadata for details for this error.
+func (err CaveatContextMismatchError) DetailsMetadata() map[string]string {
+	return map[string]string{
+		"caveat_name":    err.caveatName,
+		"parameter_name": err.parameter,
+	}
+}
+
 // CounterNotRegisteredError indicates that a counter was not registered.
 type CounterNotRegisteredError struct {
 	error
@@ -278,4 +474,8 @@ var (
 	ErrClosedIterator        = errors.New("unable to iterate: iterator closed")
 	ErrCursorsWithoutSorting = errors.New("cursors are disabled on unsorted results")
 	ErrCursorEmpty           = errors.New("cursors are only available after the first result")
+
+	// ErrDatastoreClosed is returned when a datastore is used after it has
+	// been closed.
+	ErrDatastoreClosed = errors.New("datastore is closed")
 )
@@ -40,12 +40,17 @@ var errInvalidZedToken = errors.New("invalid revision requested")
 
 type revisionHandle struct {
 	revision datastore.Revision
+	codec    *zedtoken.Codec
 }
 
 // ContextWithHandle adds a placeholder to a context that will later be
 // filled by the revision
 func ContextWithHandle(ctx context.Context) context.Context {
-	return context.WithValue(ctx, revisionKey, &revisionHandle{})
+	return contextWithHandle(ctx, zedtoken.DefaultCodec())
+}
+
+func contextWithHandle(ctx context.Context, codec *zedtoken.Codec) context.Context {
+	return context.WithValue(ctx, revisionKey, &revisionHandle{codec: codec})
 }
 
 // RevisionFromContext reads the selected revision out of a context.Context, computes a zedtoken
@@ -55,13 +60,52 @@ func RevisionFromContext(ctx context.Context) (datastore.Revision, *v1.ZedToken,
 		handle := c.(*revisionHandle)
 		rev := handle.revision
 		if rev != nil {
-			return rev, zedtoken.MustNewFromRevision(rev), nil
+			return rev, handle.codec.MustNewFromRevision(rev), nil
 		}
 	}
 
 	return nil, nil, fmt.Errorf("consistency middleware did not inject revision")
 }
 
+// ZedTokenCodecFromContext returns the codec with which the zedtokens of the
+// request are encoded and decoded, as configured on the middleware with
+// WithZedTokenCodec, or zedtoken.DefaultCodec if the middleware did not handle
+// the request.
+func ZedTokenCodecFromContext(ctx context.Context) *zedtoken.Codec {
+	if c := ctx.Value(revisionKey); c != nil {
+		return c.(*revisionHandle).codec
+	}
+	return zedtoken.DefaultCodec()
+}
+
+// Option configures the consistency middleware.
+type Option func(*interceptorOptions)
+
+type interceptorOptions struct {
+	codec *zedtoken.Codec
+}
+
+// WithZedTokenCodec sets the codec with which the zedtokens of requests are
+// decoded, and with which the services encode the zedtokens of their
+// responses, as returned by ZedTokenCodecFromContext.
+//
+// default: zedtoken.DefaultCodec()
+func WithZedTokenCodec(codec *zedtoken.Codec) Option {
+	return func(opts *interceptorOptions) {
+		if codec != nil {
+			opts.codec = codec
+		}
+	}
+}
+
+func newInterceptorOptions(opts []Option) interceptorOptions {
+	options := interceptorOptions{codec: zedtoken.DefaultCodec()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
 // AddRevisionToContext adds a revision to the given context, based on the consistency block found
 // in the given request (if applicable).
 func AddRevisionToContext(ctx context.Context, req interface{}, ds datastore.Datastore, serviceLabel string) error {
@@ -83,6 +127,7 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 
 	var revision datastore.Revision
 	consistency := req.GetConsistency()
+	codec := handle.(*revisionHandle).codec
 
 	withOptionalCursor, hasOptionalCursor := req.(hasOptionalCursor)
 
@@ -137,7 +182,7 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 	case consistency.GetAtLeastAsFresh() != nil:
 		// At least as fresh as: Pick one of the datastore's revision and that specified, which
 		// ever is later.
-		picked, pickedRequest, err := pickBestRevision(ctx, consistency.GetAtLeastAsFresh(), ds)
+		picked, pickedRequest, err := pickBestRevision(ctx, consistency.GetAtLeastAsFresh(), ds, codec)
 		if err != nil {
 			return rewriteDatastoreError(ctx, err)
 		}
@@ -159,9 +204,9 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 			ConsistencyCounter.WithLabelValues("snapshot", "request", serviceLabel).Inc()
 		}
 
-		requestedRev, err := zedtoken.DecodeRevision(consistency.GetAtExactSnapshot(), ds)
+		requestedRev, err := decodeZedToken(codec, consistency.GetAtExactSnapshot(), ds)
 		if err != nil {
-			return errInvalidZedToken
+			return err
 		}
 
 		err = ds.CheckRevision(ctx, requestedRev)
@@ -187,7 +232,8 @@ var bypassServiceWhitelist = map[string]struct{}{
 
 // UnaryServerInterceptor returns a new unary server interceptor that performs per-request exchange of
 // the specified consistency configuration for the revision at which to perform the request.
-func UnaryServerInterceptor(serviceLabel string) grpc.UnaryServerInterceptor {
+func UnaryServerInterceptor(serviceLabel string, opts ...Option) grpc.UnaryServerInterceptor {
+	options := newInterceptorOptions(opts)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		for bypass := range bypassServiceWhitelist {
 			if strings.HasPrefix(info.FullMethod, bypass) {
@@ -195,7 +241,7 @@ func UnaryServerInterceptor(serviceLabel string) grpc.UnaryServerInterceptor {
 			}
 		}
 		ds := datastoremw.MustFromContext(ctx)
-		newCtx := ContextWithHandle(ctx)
+		newCtx := contextWithHandle(ctx, options.codec)
 		if err := AddRevisionToContext(newCtx, req, ds, serviceLabel); err != nil {
 			return nil, err
 		}
@@ -206,14 +252,15 @@ func UnaryServerInterceptor(serviceLabel string) grpc.UnaryServerInterceptor {
 
 // StreamServerInterceptor returns a new stream server interceptor that performs per-request exchange of
 // the specified consistency configuration for the revision at which to perform the request.
-func StreamServerInterceptor(serviceLabel string) grpc.StreamServerInterceptor {
+func StreamServerInterceptor(serviceLabel string, opts ...Option) grpc.StreamServerInterceptor {
+	options := newInterceptorOptions(opts)
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		for bypass := range bypassServiceWhitelist {
 			if strings.HasPrefix(info.FullMethod, bypass) {
 				return handler(srv, stream)
 			}
 		}
-		wrapper := &recvWrapper{stream, ContextWithHandle(stream.Context()), serviceLabel, AddRevisionToContext}
+		wrapper := &recvWrapper{stream, contextWithHandle(stream.Context(), options.codec), serviceLabel, AddRevisionToContext}
 		return handler(srv, wrapper)
 	}
 }
@@ -237,7 +284,7 @@ func (s *recvWrapper) RecvMsg(m interface{}) error {
 
 // pickBestRevision compares the provided ZedToken with the optimized revision of the datastore, and returns the most
 // recent one. The boolean return value will be true if the provided ZedToken is the most recent, false otherwise.
-func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.Datastore) (datastore.Revision, bool, error) {
+func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.Datastore, codec *zedtoken.Codec) (datastore.Revision, bool, error) {
 	// Calculate a revision as we see fit
 	databaseRev, err := ds.OptimizedRevision(ctx)
 	if err != nil {
@@ -245,9 +292,9 @@ func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.
 	}
 
 	if requested != nil {
-		requestedRev, err := zedtoken.DecodeRevision(requested, ds)
+		requestedRev, err := decodeZedToken(codec, requested, ds)
 		if err != nil {
-			return datastore.NoRevision, false, errInvalidZedToken
+			return datastore.NoRevision, false, err
 		}
 
 		if databaseRev.GreaterThan(requestedRev) {
@@ -260,6 +307,20 @@ func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.
 	return databaseRev, false, nil
 }
 
+// decodeZedToken decodes the revision of the provided ZedToken, returning a
+// FailedPrecondition status if it was emitted for a different datastore.
+func decodeZedToken(codec *zedtoken.Codec, token *v1.ZedToken, ds datastore.Datastore) (datastore.Revision, error) {
+	rev, err := codec.DecodeRevision(token, ds)
+	if err != nil {
+		var originErr zedtoken.RevisionOriginError
+		if errors.As(err, &originErr) {
+			return datastore.NoRevision, status.Errorf(codes.FailedPrecondition, "invalid revision requested: %s", originErr)
+		}
+		return datastore.NoRevision, errInvalidZedToken
+	}
+	return rev, nil
+}
+
 func rewriteDatastoreError(ctx context.Context, err error) error {
 	// Check if the error can be directly used.
 	if _, ok := status.FromError(err); ok {
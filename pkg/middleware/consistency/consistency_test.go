@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 
 	"github.com/authzed/spicedb/internal/datastore/proxy/proxy_test"
 	"github.com/authzed/spicedb/internal/datastore/revisions"
@@ -152,6 +154,74 @@ func TestAddRevisionToContextAtInvalidExactSnapshot(t *testing.T) {
 	ds.AssertExpectations(t)
 }
 
+func TestAddRevisionToContextWithZedTokenCodec(t *testing.T) {
+	require := require.New(t)
+
+	codec, err := zedtoken.NewCodec(zedtoken.WithEmitVersion(zedtoken.EncodingV2), zedtoken.WithAcceptLegacy(false))
+	require.NoError(err)
+	require.Same(zedtoken.DefaultCodec(), ZedTokenCodecFromContext(context.Background()))
+
+	ds := &proxy_test.MockDatastore{}
+	ds.On("CheckRevision", exact).Return(nil).Times(1)
+	ds.On("RevisionFromString", exact.String()).Return(exact, nil).Once()
+
+	updated := newInterceptorContext(context.Background(), WithZedTokenCodec(codec))
+	require.Same(codec, ZedTokenCodecFromContext(updated))
+	err = AddRevisionToContext(updated, &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtExactSnapshot{
+				AtExactSnapshot: codec.MustNewFromRevision(exact),
+			},
+		},
+	}, ds, "somelabel")
+	require.NoError(err)
+
+	// The zedtoken of the response is encoded with the configured version.
+	rev, token, err := RevisionFromContext(updated)
+	require.NoError(err)
+	require.True(exact.Equal(rev))
+	require.Equal(codec.MustNewFromRevision(exact).Token, token.Token)
+	require.NotEqual(zedtoken.MustNewFromRevision(exact).Token, token.Token)
+	ds.AssertExpectations(t)
+
+	// Legacy zedtokens are rejected.
+	updated = newInterceptorContext(context.Background(), WithZedTokenCodec(codec))
+	err = AddRevisionToContext(updated, &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtExactSnapshot{
+				AtExactSnapshot: zedtoken.MustNewFromRevision(exact),
+			},
+		},
+	}, ds, "somelabel")
+	require.ErrorIs(err, errInvalidZedToken)
+}
+
+func TestAddRevisionToContextWithZedTokenOfAnotherOrigin(t *testing.T) {
+	require := require.New(t)
+
+	issuing, err := zedtoken.NewCodec(zedtoken.WithEmitVersion(zedtoken.EncodingV2), zedtoken.WithOrigin("first"))
+	require.NoError(err)
+	codec, err := zedtoken.NewCodec(zedtoken.WithEmitVersion(zedtoken.EncodingV2), zedtoken.WithOrigin("second"))
+	require.NoError(err)
+
+	ds := &proxy_test.MockDatastore{}
+	ds.On("OptimizedRevision").Return(optimized, nil).Once()
+
+	for _, consistency := range []*v1.Consistency{
+		{Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: issuing.MustNewFromRevision(exact)}},
+		{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: issuing.MustNewFromRevision(exact)}},
+	} {
+		updated := newInterceptorContext(context.Background(), WithZedTokenCodec(codec))
+		err = AddRevisionToContext(updated, &v1.ReadRelationshipsRequest{Consistency: consistency}, ds, "somelabel")
+		grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+	}
+	ds.AssertExpectations(t)
+}
+
+func newInterceptorContext(ctx context.Context, opts ...Option) context.Context {
+	return contextWithHandle(ctx, newInterceptorOptions(opts).codec)
+}
+
 func TestAddRevisionToContextNoConsistencyAPI(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"fmt"
+	"slices"
+)
+
+// stage is the role of a migration in an expand/contract schema change.
+type stage int
+
+const (
+	// stageNone is a migration that is not part of an expand/contract change.
+	stageNone stage = iota
+
+	// stageExpand is a backwards-compatible migration, such as adding a
+	// nullable column, that is safe to apply while older servers are running.
+	stageExpand
+
+	// stageContract is a migration, such as making a column required, that
+	// is only safe once every server has been upgraded past its expand.
+	stageContract
+)
+
+// AsExpand declares the migration as the expand phase of an expand/contract
+// schema change. An expand migration must be backwards compatible with the
+// servers running the previous version.
+func AsExpand() MigrationOption {
+	return func(mo *migrationOptions) { mo.stage = stageExpand }
+}
+
+// AsContract declares the migration as the contract phase of the
+// expand/contract schema change whose expand is the migration to
+// expandVersion. The manager refuses to run a contract whose expand is not an
+// earlier migration in the chain, or whose expand was not applied by a
+// previous run, so that a partial rollout cannot contract the schema while
+// servers that depend on it are still running. A datastore that has never
+// been migrated is not running any servers, and may be migrated through both
+// phases at once.
+func AsContract(expandVersion string) MigrationOption {
+	return func(mo *migrationOptions) {
+		mo.stage = stageContract
+		mo.expandVersion = expandVersion
+	}
+}
+
+func validateStage(version string, options migrationOptions) error {
+	if options.stage == stageContract && options.expandVersion == "" {
+		return fmt.Errorf("contract migration %s must name its expand migration", version)
+	}
+	if options.stage == stageContract && options.expandVersion == version {
+		return fmt.Errorf("contract migration %s cannot be its own expand migration", version)
+	}
+	return nil
+}
+
+// validateContracts checks that the expand of every contract migration to be
+// run precedes it in the chain, and was applied before the starting version.
+func validateContracts[C any, T any](starting string, toRun []migration[C, T], all map[string]migration[C, T]) error {
+	pending := make([]string, 0, len(toRun))
+	for _, migration := range toRun {
+		pending = append(pending, migration.version)
+	}
+
+	for _, migration := range toRun {
+		if migration.options.stage != stageContract {
+			continue
+		}
+
+		expandVersion := migration.options.expandVersion
+		expand, ok := all[expandVersion]
+		if !ok || expand.options.stage != stageExpand {
+			return fmt.Errorf("contract migration %s names %s, which is not a registered expand migration", migration.version, expandVersion)
+		}
+
+		if !precedes(expandVersion, migration.version, all) {
+			return fmt.Errorf("contract migration %s must come after its expand migration %s", migration.version, expandVersion)
+		}
+
+		if starting != None && slices.Contains(pending, expandVersion) {
+			return fmt.Errorf("contract migration %s requires its expand migration %s to have been applied by a previous run", migration.version, expandVersion)
+		}
+	}
+	return nil
+}
+
+// precedes returns whether the migration to ancestor is an earlier migration
+// in the chain leading to the migration to version.
+func precedes[C any, T any](ancestor, version string, all map[string]migration[C, T]) bool {
+	seen := make(map[string]struct{}, len(all))
+	for current, ok := all[version]; ok; current, ok = all[current.replaces] {
+		if current.replaces == ancestor {
+			return true
+		}
+		if _, ok := seen[current.replaces]; ok {
+			return false
+		}
+		seen[current.replaces] = struct{}{}
+	}
+	return false
+}
@@ -2,8 +2,11 @@ package migrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
 	log "github.com/authzed/spicedb/internal/logging"
 )
@@ -15,6 +18,11 @@ const (
 
 type RunType bool
 
+// ErrNoMigrationsRegistered is returned when migrating with a manager that has
+// no registered migrations, such as from a build from which the migrations
+// were stripped, unless the manager was created with WithNoMigrationsExpected.
+var ErrNoMigrationsRegistered = errors.New("no migrations are registered")
+
 var (
 	DryRun  RunType = true
 	LiveRun RunType = false
@@ -42,17 +50,134 @@ type Driver[C any, T any] interface {
 	Close(ctx context.Context) error
 }
 
+// SeedingDriver is implemented by drivers that load initial data into the datastore. Seed is called
+// by the Manager after a live run has migrated the datastore to the head revision, and must skip
+// any data that already exists.
+type SeedingDriver interface {
+	Seed(ctx context.Context) error
+}
+
 // MigrationFunc is a function that executes in the context of a specific database connection handler.
 type MigrationFunc[C any] func(ctx context.Context, conn C) error
 
 // TxMigrationFunc is a function that executes in the context of a specific database transaction.
 type TxMigrationFunc[T any] func(ctx context.Context, tx T) error
 
+// MigrationOption configures optional behavior for a single registered migration.
+type MigrationOption func(*migrationOptions)
+
+type migrationOptions struct {
+	shouldRetry   func(err error) bool
+	stage         stage
+	expandVersion string
+	sql           []string
+
+	// typed are the options whose values have the connection or transaction
+	// type of the manager, which Register applies to the migration.
+	typed []typedOption
+}
+
+// typedOption is an option carrying a value of the connection type, a
+// connOption, or of the transaction type, a txOption, that only applies to a
+// migration of that type.
+type typedOption interface {
+	// describe returns the name of the option and the type of its value, for
+	// the error reported when the option is given to a migration of another
+	// type.
+	describe() (name string, valueType string)
+}
+
+// connOption is a typedOption of the connection type C.
+type connOption[C any] struct {
+	name  string
+	value string
+	apply func(*connOptions[C])
+}
+
+func (o connOption[C]) describe() (string, string) { return o.name, o.value }
+
+// txOption is a typedOption of the transaction type T.
+type txOption[T any] struct {
+	name  string
+	value string
+	apply func(*txOptions[T])
+}
+
+func (o txOption[T]) describe() (string, string) { return o.name, o.value }
+
+// connOptions are the options of a migration that take its connection type.
+type connOptions[C any] struct {
+	postCommitHook MigrationFunc[C]
+	phases         []Phase[C]
+}
+
+// txOptions are the options of a migration that take its transaction type.
+type txOptions[T any] struct {
+	down TxMigrationFunc[T]
+}
+
+// maxMigrationRetries is the maximum number of times a migration with a retry
+// predicate will be re-attempted before the last error is returned.
+const maxMigrationRetries = 5
+
+// WithRetryPredicate configures the migration to be re-attempted whenever
+// its execution fails with an error for which shouldRetry returns true.
+// Errors that do not match the predicate are returned immediately.
+func WithRetryPredicate(shouldRetry func(err error) bool) MigrationOption {
+	return func(mo *migrationOptions) { mo.shouldRetry = shouldRetry }
+}
+
+// WithPostCommitHook configures a function to be run with the driver's
+// connection after the migration has been committed, outside of the migration
+// transaction, such as to issue `ANALYZE` for tables whose data was rewritten.
+// Failures of the hook are logged as warnings and do not fail the migration.
+//
+// The connection type of the hook must match that of the manager with which
+// the migration is registered.
+func WithPostCommitHook[C any](hook MigrationFunc[C]) MigrationOption {
+	return func(mo *migrationOptions) {
+		mo.typed = append(mo.typed, connOption[C]{
+			name:  "post-commit hook",
+			value: fmt.Sprintf("%T", hook),
+			apply: func(co *connOptions[C]) { co.postCommitHook = hook },
+		})
+	}
+}
+
+// WithSQL declares that the migration consists solely of the given SQL
+// statements, run in order, so that it can be included verbatim in the script
+// returned by SQLScript. It does not change how the migration is run: the
+// migration's functions must still execute the same statements.
+func WithSQL(statements ...string) MigrationOption {
+	return func(mo *migrationOptions) { mo.sql = statements }
+}
+
+// DeclareSQL declares, like WithSQL, that the registered migration to the
+// given version consists solely of the given SQL statements. It allows the
+// statements of migrations that have already shipped to be declared without
+// changing their registration.
+func (m *Manager[D, C, T]) DeclareSQL(version string, statements ...string) error {
+	migration, ok := m.migrations[version]
+	if !ok {
+		return fmt.Errorf("unable to declare the SQL of unknown revision: %s", version)
+	}
+	if migration.options.sql != nil {
+		return fmt.Errorf("the SQL of revision %s has already been declared", version)
+	}
+
+	migration.options.sql = statements
+	m.migrations[version] = migration
+	return nil
+}
+
 type migration[C any, T any] struct {
-	version  string
-	replaces string
-	up       MigrationFunc[C]
-	upTx     TxMigrationFunc[T]
+	version     string
+	replaces    string
+	up          MigrationFunc[C]
+	upTx        TxMigrationFunc[T]
+	options     migrationOptions
+	connOptions connOptions[C]
+	txOptions   txOptions[T]
 }
 
 // Manager is used to manage a self-contained set of migrations. Standard usage
@@ -63,12 +188,39 @@ type migration[C any, T any] struct {
 // a database connection handler. This makes it possible for MigrationFunc to run without
 // having to abstract each connection handler behind a common interface.
 type Manager[D Driver[C, T], C any, T any] struct {
-	migrations map[string]migration[C, T]
+	migrations           map[string]migration[C, T]
+	noMigrationsExpected bool
+	reservedPrefixes     []string
+}
+
+// ManagerOption configures optional behavior of a migration manager.
+type ManagerOption func(*managerOptions)
+
+type managerOptions struct {
+	noMigrationsExpected bool
+	reservedPrefixes     []string
+}
+
+// WithNoMigrationsExpected declares that the manager is for a datastore, such
+// as an in-memory one, that has no migrations. Running the manager succeeds
+// without doing anything, rather than failing with ErrNoMigrationsRegistered,
+// and registering a migration with it fails.
+func WithNoMigrationsExpected() ManagerOption {
+	return func(mo *managerOptions) { mo.noMigrationsExpected = true }
 }
 
 // NewManager creates a new empty instance of a migration manager.
-func NewManager[D Driver[C, T], C any, T any]() *Manager[D, C, T] {
-	return &Manager[D, C, T]{migrations: make(map[string]migration[C, T])}
+func NewManager[D Driver[C, T], C any, T any](opts ...ManagerOption) *Manager[D, C, T] {
+	var options managerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Manager[D, C, T]{
+		migrations:           make(map[string]migration[C, T]),
+		noMigrationsExpected: options.noMigrationsExpected,
+		reservedPrefixes:     options.reservedPrefixes,
+	}
 }
 
 // Register is used to associate a single migration with the migration engine.
@@ -77,20 +229,62 @@ func NewManager[D Driver[C, T], C any, T any]() *Manager[D, C, T] {
 // interface as its only parameters, which will be passed directly from the Run
 // method into the upgrade function. If not extra fields or data are required
 // the function can alternatively take a Driver interface param.
-func (m *Manager[D, C, T]) Register(version, replaces string, up MigrationFunc[C], upTx TxMigrationFunc[T]) error {
+func (m *Manager[D, C, T]) Register(version, replaces string, up MigrationFunc[C], upTx TxMigrationFunc[T], opts ...MigrationOption) error {
 	if strings.ToLower(version) == Head {
 		return fmt.Errorf("unable to register version called head")
 	}
 
+	if m.noMigrationsExpected {
+		return fmt.Errorf("unable to register revision %s with a manager that expects no migrations", version)
+	}
+
+	if prefix, ok := m.reservedPrefix(version); ok {
+		return fmt.Errorf("unable to register revision %s, which begins with the reserved prefix %q", version, prefix)
+	}
+
 	if _, ok := m.migrations[version]; ok {
 		return fmt.Errorf("revision already exists: %s", version)
 	}
 
+	var options migrationOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var connOpts connOptions[C]
+	var txOpts txOptions[T]
+	for _, opt := range options.typed {
+		switch opt := opt.(type) {
+		case connOption[C]:
+			opt.apply(&connOpts)
+		case txOption[T]:
+			opt.apply(&txOpts)
+		default:
+			name, valueType := opt.describe()
+			return fmt.Errorf("%s for revision %s has type %s, which takes neither the connection type %s nor the transaction type %s of the manager",
+				name, version, valueType, reflect.TypeFor[C](), reflect.TypeFor[T]())
+		}
+	}
+	options.typed = nil
+
+	if err := validateStage(version, options); err != nil {
+		return err
+	}
+
+	if connOpts.phases != nil {
+		if err := validatePhases(version, connOpts.phases); err != nil {
+			return err
+		}
+	}
+
 	m.migrations[version] = migration[C, T]{
-		version:  version,
-		replaces: replaces,
-		up:       up,
-		upTx:     upTx,
+		version:     version,
+		replaces:    replaces,
+		up:          up,
+		upTx:        upTx,
+		options:     options,
+		connOptions: connOpts,
+		txOptions:   txOpts,
 	}
 
 	return nil
@@ -99,6 +293,13 @@ func (m *Manager[D, C, T]) Register(version, replaces string, up MigrationFunc[C
 // Run will actually perform the necessary migrations to bring the backing datastore
 // from its current revision to the specified revision.
 func (m *Manager[D, C, T]) Run(ctx context.Context, driver D, throughRevision string, dryRun RunType) error {
+	if len(m.migrations) == 0 {
+		if m.noMigrationsExpected {
+			return nil
+		}
+		return ErrNoMigrationsRegistered
+	}
+
 	requestedRevision := throughRevision
 	starting, err := driver.Version(ctx)
 	if err != nil {
@@ -120,51 +321,155 @@ func (m *Manager[D, C, T]) Run(ctx context.Context, driver D, throughRevision st
 		log.Ctx(ctx).Info().Str("targetRevision", requestedRevision).Msg("server already at requested revision")
 	}
 
+	if err := validateContracts(starting, toRun, m.migrations); err != nil {
+		return fmt.Errorf("unable to run migrations: %w", err)
+	}
+
 	if !dryRun {
 		for _, migrationToRun := range toRun {
-			// Double check that the current version reported is the one we expect
-			currentVersion, err := driver.Version(ctx)
-			if err != nil {
-				return fmt.Errorf("unable to load version from driver: %w", err)
+			if err := applyMigration(ctx, driver, migrationToRun, func(ctx context.Context) error {
+				phases := migrationToRun.connOptions.phases
+				if len(phases) == 0 {
+					return nil
+				}
+				return runPhases[C, T](ctx, driver, driver.Conn(), migrationToRun.version, phases)
+			}); err != nil {
+				return err
 			}
+		}
 
-			if migrationToRun.replaces != currentVersion {
-				return fmt.Errorf("migration attempting to run out of order: %s != %s", currentVersion, migrationToRun.replaces)
+		if seeder, ok := any(driver).(SeedingDriver); ok {
+			head, err := m.HeadRevision()
+			if err != nil {
+				return fmt.Errorf("unable to compute head revision: %w", err)
 			}
 
-			log.Ctx(ctx).Info().Str("from", migrationToRun.replaces).Str("to", migrationToRun.version).Msg("migrating")
-			if migrationToRun.up != nil {
-				if err = migrationToRun.up(ctx, driver.Conn()); err != nil {
-					return fmt.Errorf("error executing migration function: %w", err)
+			if throughRevision == head {
+				if err := seeder.Seed(ctx); err != nil {
+					return fmt.Errorf("unable to seed datastore: %w", err)
 				}
 			}
+		}
+	}
 
-			migrationToRun := migrationToRun
-			if err := driver.RunTx(ctx, func(ctx context.Context, tx T) error {
-				if migrationToRun.upTx != nil {
-					if err := migrationToRun.upTx(ctx, tx); err != nil {
-						return err
-					}
-				}
-				return driver.WriteVersion(ctx, tx, migrationToRun.version, migrationToRun.replaces)
-			}); err != nil {
-				return fmt.Errorf("error executing migration `%s`: %w", migrationToRun.version, err)
-			}
+	return nil
+}
 
-			currentVersion, err = driver.Version(ctx)
-			if err != nil {
-				return fmt.Errorf("unable to load version from driver: %w", err)
+// applyMigration runs the migration, whose phases, if any, are run by
+// runPhases, and records its version.
+func applyMigration[D Driver[C, T], C any, T any](ctx context.Context, driver D, migrationToRun migration[C, T], runPhases func(ctx context.Context) error) error {
+	// Double check that the current version reported is the one we expect
+	currentVersion, err := driver.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load version from driver: %w", err)
+	}
+
+	if migrationToRun.replaces != currentVersion {
+		return fmt.Errorf("migration attempting to run out of order: %s != %s", currentVersion, migrationToRun.replaces)
+	}
+
+	log.Ctx(ctx).Info().Str("from", migrationToRun.replaces).Str("to", migrationToRun.version).Msg("migrating")
+	run := func() error {
+		return runMigration(ctx, driver, migrationToRun, runPhases)
+	}
+	if timingDriver, ok := any(driver).(TimingDriver); ok {
+		if report, enabled := timingDriver.MigrationTimingReporter(); enabled {
+			run = func() error {
+				return timeMigration(ctx, migrationToRun.version, migrationToRun.replaces, report, func() error {
+					return runMigration(ctx, driver, migrationToRun, runPhases)
+				})
 			}
-			if migrationToRun.version != currentVersion {
-				return fmt.Errorf("the migration function succeeded, but the driver did not report the expected version: %s", migrationToRun.version)
+		}
+	}
+	if err := run(); err != nil {
+		return err
+	}
+
+	if hook := migrationToRun.connOptions.postCommitHook; hook != nil {
+		if err := hook(ctx, driver.Conn()); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("version", migrationToRun.version).Msg("post-commit hook for migration failed")
+		}
+	}
+	return nil
+}
+
+// runMigration runs the phases, non-transactional part and transaction of the
+// migration, and verifies that the driver reports its version.
+func runMigration[D Driver[C, T], C any, T any](ctx context.Context, driver D, migrationToRun migration[C, T], runPhases func(ctx context.Context) error) error {
+	started := time.Now()
+	if err := runPhases(ctx); err != nil {
+		return fmt.Errorf("error executing migration `%s`: %w", migrationToRun.version, err)
+	}
+
+	if migrationToRun.up != nil {
+		if err := withMigrationRetries(ctx, migrationToRun.options, func() error {
+			return migrationToRun.up(ctx, driver.Conn())
+		}); err != nil {
+			return fmt.Errorf("error executing migration function: %w", err)
+		}
+	}
+
+	var txMigration TxMigrationFunc[T] = func(ctx context.Context, tx T) error {
+		if migrationToRun.upTx != nil {
+			if err := migrationToRun.upTx(ctx, tx); err != nil {
+				return err
 			}
 		}
+		ctx = context.WithValue(ctx, ctxMigrationDuration{}, time.Since(started))
+		return driver.WriteVersion(ctx, tx, migrationToRun.version, migrationToRun.replaces)
 	}
 
+	if err := withMigrationRetries(ctx, migrationToRun.options, func() error {
+		return driver.RunTx(ctx, txMigration)
+	}); err != nil {
+		return fmt.Errorf("error executing migration `%s`: %w", migrationToRun.version, err)
+	}
+
+	currentVersion, err := driver.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load version from driver: %w", err)
+	}
+	if migrationToRun.version != currentVersion {
+		return fmt.Errorf("the migration function succeeded, but the driver did not report the expected version: %s", migrationToRun.version)
+	}
 	return nil
 }
 
+type ctxMigrationDuration struct{}
+
+// MigrationDuration returns the time spent running the migration whose
+// version is being written, when called with the context given to a driver's
+// WriteVersion by the Manager.
+func MigrationDuration(ctx context.Context) (time.Duration, bool) {
+	duration, ok := ctx.Value(ctxMigrationDuration{}).(time.Duration)
+	return duration, ok
+}
+
+// withMigrationRetries runs fn, re-attempting it for as long as the migration's
+// retry predicate (if any) matches the returned error.
+func withMigrationRetries(ctx context.Context, opts migrationOptions, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= maxMigrationRetries; attempt++ {
+		err = fn()
+		if err == nil || opts.shouldRetry == nil || !opts.shouldRetry(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Ctx(ctx).Warn().Err(err).Int("attempt", attempt+1).Msg("retrying migration after retryable error")
+	}
+	return err
+}
+
 func (m *Manager[D, C, T]) HeadRevision() (string, error) {
+	if len(m.migrations) == 0 {
+		if m.noMigrationsExpected {
+			return None, nil
+		}
+		return "", ErrNoMigrationsRegistered
+	}
+
 	candidates := make(map[string]struct{}, len(m.migrations))
 	for candidate := range m.migrations {
 		candidates[candidate] = struct{}{}
@@ -186,6 +491,175 @@ func (m *Manager[D, C, T]) HeadRevision() (string, error) {
 	return allHeads[0], nil
 }
 
+// PendingMigrations returns, in the order in which they would be applied, the
+// versions of all migrations between the driver's current version and head.
+// A datastore that has never been migrated reports the entire chain.
+func (m *Manager[D, C, T]) PendingMigrations(ctx context.Context, driver D) ([]string, error) {
+	if m.noMigrationsExpected {
+		return nil, nil
+	}
+
+	starting, err := driver.Version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get current revision: %w", err)
+	}
+
+	head, err := m.HeadRevision()
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute head revision: %w", err)
+	}
+
+	toRun, err := collectMigrationsInRange(starting, head, m.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	pending := make([]string, 0, len(toRun))
+	for _, migration := range toRun {
+		pending = append(pending, migration.version)
+	}
+	return pending, nil
+}
+
+// MigrationStep is a version transition applied by a migration.
+type MigrationStep struct {
+	// From is the version the migration replaces, or empty for the first
+	// migration.
+	From string `json:"from"`
+
+	// To is the version of the migration.
+	To string `json:"to"`
+}
+
+// Plan returns, in the order in which Run would apply them, the version
+// transitions that migrating the driver's datastore through the given
+// revision, or Head, would make. It only reads the driver's current version:
+// no migration is run and no transaction is opened. A datastore already at
+// the revision has an empty plan. Plan fails for the same migration graphs as
+// Run does.
+func (m *Manager[D, C, T]) Plan(ctx context.Context, driver D, throughRevision string) ([]MigrationStep, error) {
+	if len(m.migrations) == 0 {
+		if m.noMigrationsExpected {
+			return []MigrationStep{}, nil
+		}
+		return nil, ErrNoMigrationsRegistered
+	}
+
+	starting, err := driver.Version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get current revision: %w", err)
+	}
+
+	if strings.ToLower(throughRevision) == Head {
+		throughRevision, err = m.HeadRevision()
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute head revision: %w", err)
+		}
+	}
+
+	toRun, err := collectMigrationsInRange(starting, throughRevision, m.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	if err := validateContracts(starting, toRun, m.migrations); err != nil {
+		return nil, fmt.Errorf("unable to run migrations: %w", err)
+	}
+
+	plan := make([]MigrationStep, 0, len(toRun))
+	for _, migration := range toRun {
+		plan = append(plan, MigrationStep{From: migration.replaces, To: migration.version})
+	}
+	return plan, nil
+}
+
+// SchemaStatusReport summarizes the migration status of a datastore.
+type SchemaStatusReport struct {
+	// CurrentVersion is the version to which the datastore has been migrated,
+	// or empty if it has never been migrated.
+	CurrentVersion string `json:"current_version"`
+
+	// HeadVersion is the version of the newest registered migration.
+	HeadVersion string `json:"head_version"`
+
+	// IsAtHead is whether the datastore has been migrated to the head version.
+	IsAtHead bool `json:"is_at_head"`
+
+	// PendingMigrations is the number of migrations that would be applied to
+	// bring the datastore to the head version.
+	PendingMigrations int `json:"pending_migrations"`
+}
+
+// SchemaStatus reports the current and head versions of the driver's
+// datastore, and the migrations pending between them. The report is computed
+// from a single read of the driver's version, so its fields are consistent
+// with one another.
+func (m *Manager[D, C, T]) SchemaStatus(ctx context.Context, driver D) (SchemaStatusReport, error) {
+	starting, err := driver.Version(ctx)
+	if err != nil {
+		return SchemaStatusReport{}, fmt.Errorf("unable to get current revision: %w", err)
+	}
+
+	head, err := m.HeadRevision()
+	if err != nil {
+		return SchemaStatusReport{}, fmt.Errorf("unable to compute head revision: %w", err)
+	}
+
+	toRun, err := collectMigrationsInRange(starting, head, m.migrations)
+	if err != nil {
+		return SchemaStatusReport{}, fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	return SchemaStatusReport{
+		CurrentVersion:    starting,
+		HeadVersion:       head,
+		IsAtHead:          starting == head,
+		PendingMigrations: len(toRun),
+	}, nil
+}
+
+// ValidateMigrationPath checks, without applying any migration, that the
+// registered migrations form an unbroken path from the driver's current
+// version to head, returning a descriptive error if the current version is not
+// a registered migration, if an intermediate migration is missing, or if a
+// contract migration on the path could not be run.
+func (m *Manager[D, C, T]) ValidateMigrationPath(ctx context.Context, driver D) error {
+	if m.noMigrationsExpected {
+		return nil
+	}
+
+	starting, err := driver.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get current revision: %w", err)
+	}
+
+	head, err := m.HeadRevision()
+	if err != nil {
+		return fmt.Errorf("unable to compute head revision: %w", err)
+	}
+
+	if _, ok := m.migrations[starting]; starting != None && !ok {
+		return fmt.Errorf("current revision %s is not a registered migration; the datastore may have been migrated by a newer version", starting)
+	}
+
+	toRun, err := collectMigrationsInRange(starting, head, m.migrations)
+	if err != nil {
+		return fmt.Errorf("no migration path from current revision %q to head revision %s: %w", starting, head, err)
+	}
+
+	if err := validateContracts(starting, toRun, m.migrations); err != nil {
+		return fmt.Errorf("invalid migration path from current revision %q to head revision %s: %w", starting, head, err)
+	}
+	return nil
+}
+
+// IsRegistered returns whether a migration to the version has been
+// registered with the manager.
+func (m *Manager[D, C, T]) IsRegistered(version string) bool {
+	_, ok := m.migrations[version]
+	return ok
+}
+
 func (m *Manager[D, C, T]) IsHeadCompatible(revision string) (bool, error) {
 	headRevision, err := m.HeadRevision()
 	if err != nil {
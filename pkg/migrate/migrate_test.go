@@ -2,6 +2,8 @@ package migrate
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -40,6 +42,18 @@ func (*fakeDriver) Close(ctx context.Context) error {
 	return ctx.Err()
 }
 
+// fakeTxDriver is a fakeDriver whose RunTx executes the provided function.
+type fakeTxDriver struct {
+	fakeDriver
+}
+
+func (fd *fakeTxDriver) RunTx(ctx context.Context, f TxMigrationFunc[fakeTx]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f(ctx, fakeTx{})
+}
+
 type fakeConnPool struct{}
 
 type fakeTx struct{}
@@ -179,6 +193,158 @@ func TestIsHeadCompatible(t *testing.T) {
 	}
 }
 
+func TestIsRegistered(t *testing.T) {
+	m := Manager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]{migrations: singleHeadedChain}
+	require.True(t, m.IsRegistered("123"))
+	require.True(t, m.IsRegistered("789"))
+	require.False(t, m.IsRegistered("10"))
+	require.False(t, m.IsRegistered(""))
+}
+
+func TestPendingMigrations(t *testing.T) {
+	testCases := []struct {
+		name           string
+		migrations     map[string]migration[fakeConnPool, fakeTx]
+		currentVersion string
+		expected       []string
+		expectError    bool
+	}{
+		{"fresh database", singleHeadedChain, "", []string{"123", "456", "789"}, false},
+		{"partially migrated", singleHeadedChain, "123", []string{"456", "789"}, false},
+		{"at head", singleHeadedChain, "789", []string{}, false},
+		{"unknown version", singleHeadedChain, "10", nil, true},
+		{"multiple heads", multiHeadedChain, "", nil, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := require.New(t)
+			m := Manager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]{migrations: tc.migrations}
+			pending, err := m.PendingMigrations(context.Background(), &fakeDriver{currentVersion: tc.currentVersion})
+			req.Equal(tc.expectError, err != nil, err)
+			req.Equal(tc.expected, pending)
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	testCases := []struct {
+		name            string
+		migrations      map[string]migration[fakeConnPool, fakeTx]
+		currentVersion  string
+		throughRevision string
+		expected        []MigrationStep
+		expectError     bool
+	}{
+		{"fresh database", singleHeadedChain, "", Head, []MigrationStep{{"", "123"}, {"123", "456"}, {"456", "789"}}, false},
+		{"partially migrated", singleHeadedChain, "123", Head, []MigrationStep{{"123", "456"}, {"456", "789"}}, false},
+		{"through revision", singleHeadedChain, "", "456", []MigrationStep{{"", "123"}, {"123", "456"}}, false},
+		{"at head", singleHeadedChain, "789", Head, []MigrationStep{}, false},
+		{"unknown version", singleHeadedChain, "10", Head, nil, true},
+		{"multiple heads", multiHeadedChain, "", Head, nil, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := require.New(t)
+			m := Manager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]{migrations: tc.migrations}
+			plan, err := m.Plan(context.Background(), &fakeDriver{currentVersion: tc.currentVersion}, tc.throughRevision)
+			req.Equal(tc.expectError, err != nil, err)
+			req.Equal(tc.expected, plan)
+		})
+	}
+}
+
+func TestSchemaStatus(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	require.NoError(t, m.Register("1", "", noNonatomicMigration, noTxMigration))
+	require.NoError(t, m.Register("2", "1", noNonatomicMigration, noTxMigration))
+	require.NoError(t, m.Register("3", "2", noNonatomicMigration, noTxMigration))
+
+	testCases := []struct {
+		currentVersion string
+		expected       SchemaStatusReport
+	}{
+		{"", SchemaStatusReport{CurrentVersion: "", HeadVersion: "3", IsAtHead: false, PendingMigrations: 3}},
+		{"2", SchemaStatusReport{CurrentVersion: "2", HeadVersion: "3", IsAtHead: false, PendingMigrations: 1}},
+		{"3", SchemaStatusReport{CurrentVersion: "3", HeadVersion: "3", IsAtHead: true, PendingMigrations: 0}},
+	}
+
+	for _, tc := range testCases {
+		report, err := m.SchemaStatus(ctx, &fakeDriver{currentVersion: tc.currentVersion})
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, report)
+	}
+
+	encoded, err := json.Marshal(testCases[1].expected)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"current_version":"2","head_version":"3","is_at_head":false,"pending_migrations":1}`, string(encoded))
+
+	_, err = m.SchemaStatus(ctx, &fakeDriver{currentVersion: "unknown"})
+	require.Error(t, err)
+}
+
+func TestValidateMigrationPath(t *testing.T) {
+	testCases := []struct {
+		name           string
+		migrations     map[string]migration[fakeConnPool, fakeTx]
+		currentVersion string
+		expectedError  string
+	}{
+		{"fresh database", singleHeadedChain, "", ""},
+		{"partially migrated", singleHeadedChain, "123", ""},
+		{"at head", singleHeadedChain, "789", ""},
+		{"unknown version", singleHeadedChain, "10", "not a registered migration"},
+		{"multiple heads", multiHeadedChain, "", "unable to compute head revision"},
+		{"missing intermediate migration", missingEarlyMigrations, "", "no migration path"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := Manager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]{migrations: tc.migrations}
+			driver := &fakeDriver{currentVersion: tc.currentVersion}
+			err := m.ValidateMigrationPath(context.Background(), driver)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.expectedError)
+			}
+
+			// Nothing is applied.
+			require.Equal(t, tc.currentVersion, driver.currentVersion)
+		})
+	}
+}
+
+func TestEmptyMigrationSet(t *testing.T) {
+	ctx := context.Background()
+
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	require.ErrorIs(t, m.Run(ctx, &fakeDriver{}, Head, LiveRun), ErrNoMigrationsRegistered)
+	require.ErrorIs(t, m.Run(ctx, &fakeDriver{}, "1", LiveRun), ErrNoMigrationsRegistered)
+
+	_, err := m.PendingMigrations(ctx, &fakeDriver{})
+	require.ErrorIs(t, err, ErrNoMigrationsRegistered)
+
+	expectingNone := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx](WithNoMigrationsExpected())
+	require.NoError(t, expectingNone.Run(ctx, &fakeDriver{}, Head, LiveRun))
+	require.NoError(t, expectingNone.ValidateMigrationPath(ctx, &fakeDriver{}))
+
+	head, err := expectingNone.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, None, head)
+
+	pending, err := expectingNone.PendingMigrations(ctx, &fakeDriver{})
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	require.Error(t, expectingNone.Register("1", "", noNonatomicMigration, noTxMigration))
+}
+
 func TestManagerEnsureVersionIsWritten(t *testing.T) {
 	req := require.New(t)
 	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
@@ -193,27 +359,549 @@ func TestManagerEnsureVersionIsWritten(t *testing.T) {
 	req.Equal("", writtenVer)
 }
 
+func TestMigrationRetryPredicate(t *testing.T) {
+	errRetryable := errors.New("retryable")
+	errFatal := errors.New("fatal")
+	isRetryable := func(err error) bool { return errors.Is(err, errRetryable) }
+
+	t.Run("retries matching errors", func(t *testing.T) {
+		req := require.New(t)
+		m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+		attempts := 0
+		err := m.Register("1", "", func(ctx context.Context, conn fakeConnPool) error {
+			attempts++
+			if attempts <= 2 {
+				return errRetryable
+			}
+			return nil
+		}, noTxMigration, WithRetryPredicate(isRetryable))
+		req.NoError(err)
+
+		drv := &fakeTxDriver{}
+		req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+		req.Equal(3, attempts)
+		req.Equal("1", drv.currentVersion)
+	})
+
+	t.Run("non-matching errors propagate immediately", func(t *testing.T) {
+		req := require.New(t)
+		m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+		attempts := 0
+		err := m.Register("1", "", func(ctx context.Context, conn fakeConnPool) error {
+			attempts++
+			return errFatal
+		}, noTxMigration, WithRetryPredicate(isRetryable))
+		req.NoError(err)
+
+		drv := &fakeTxDriver{}
+		req.ErrorIs(m.Run(context.Background(), drv, Head, LiveRun), errFatal)
+		req.Equal(1, attempts)
+		req.Equal("", drv.currentVersion)
+	})
+
+	t.Run("gives up after the maximum number of retries", func(t *testing.T) {
+		req := require.New(t)
+		m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+		attempts := 0
+		err := m.Register("1", "", noNonatomicMigration, func(ctx context.Context, tx fakeTx) error {
+			attempts++
+			return errRetryable
+		}, WithRetryPredicate(isRetryable))
+		req.NoError(err)
+
+		drv := &fakeTxDriver{}
+		req.ErrorIs(m.Run(context.Background(), drv, Head, LiveRun), errRetryable)
+		req.Equal(maxMigrationRetries+1, attempts)
+	})
+}
+
+// fakeTimingDriver is a fakeTxDriver that opts in to timing migrations.
+type fakeTimingDriver struct {
+	fakeTxDriver
+	timings []MigrationTiming
+}
+
+func (fd *fakeTimingDriver) MigrationTimingReporter() (func(MigrationTiming), bool) {
+	return func(timing MigrationTiming) { fd.timings = append(fd.timings, timing) }, true
+}
+
+func TestMigrationTimings(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	errFailed := errors.New("failed")
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	// The timing covers the non-transactional part of the migration, not just
+	// its transaction.
+	req.NoError(m.Register("2", "1", func(ctx context.Context, conn fakeConnPool) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, func(ctx context.Context, tx fakeTx) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}))
+	req.NoError(m.Register("3", "2", noNonatomicMigration, func(ctx context.Context, tx fakeTx) error {
+		return errFailed
+	}))
+
+	drv := &fakeTimingDriver{}
+	req.ErrorIs(m.Run(context.Background(), drv, Head, LiveRun), errFailed)
+
+	req.Len(drv.timings, 3)
+	req.Equal("1", drv.timings[0].Version)
+	req.Equal("", drv.timings[0].Replaces)
+	req.NoError(drv.timings[0].Err)
+	req.Equal("2", drv.timings[1].Version)
+	req.Equal("1", drv.timings[1].Replaces)
+	req.GreaterOrEqual(drv.timings[1].Duration, 20*time.Millisecond)
+	req.Equal("3", drv.timings[2].Version)
+	req.ErrorIs(drv.timings[2].Err, errFailed)
+
+	// Drivers that do not opt in are not timed.
+	untimed := &fakeTxDriver{}
+	req.NoError(m.Run(context.Background(), untimed, "2", LiveRun))
+	req.Equal("2", untimed.currentVersion)
+}
+
+// fakeRollbackDriver is a fakeTxDriver that can revert its version.
+type fakeRollbackDriver struct {
+	fakeTxDriver
+}
+
+func (fd *fakeRollbackDriver) RevertVersion(ctx context.Context, _ fakeTx, _, replaced string) error {
+	if ctx.Err() == nil {
+		fd.currentVersion = replaced
+	}
+	return ctx.Err()
+}
+
+func TestRollback(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	var reverted []string
+	down := func(version string) TxMigrationFunc[fakeTx] {
+		return func(ctx context.Context, tx fakeTx) error {
+			reverted = append(reverted, version)
+			return nil
+		}
+	}
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration, WithDown(down("2"))))
+	req.NoError(m.Register("3", "2", noNonatomicMigration, noTxMigration, WithDown(down("3"))))
+	req.ErrorContains(m.Register("4", "3", noNonatomicMigration, noTxMigration, WithDown(func(ctx context.Context, tx string) error {
+		return nil
+	})), "down migration for revision 4 has type")
+
+	drv := &fakeRollbackDriver{}
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("3", drv.currentVersion)
+
+	step, err := m.Rollback(context.Background(), drv)
+	req.NoError(err)
+	req.Equal(MigrationStep{From: "3", To: "2"}, step)
+	req.Equal("2", drv.currentVersion)
+	req.Equal([]string{"3"}, reverted)
+
+	step, err = m.Rollback(context.Background(), drv)
+	req.NoError(err)
+	req.Equal(MigrationStep{From: "2", To: "1"}, step)
+
+	// The first migration has no inverse.
+	_, err = m.Rollback(context.Background(), drv)
+	var irreversible IrreversibleMigrationError
+	req.ErrorAs(err, &irreversible)
+	req.Equal("1", irreversible.Version)
+	req.ErrorContains(err, "irreversible migration")
+	req.Equal("1", drv.currentVersion)
+
+	// Drivers must support reverting their version.
+	_, err = m.Rollback(context.Background(), &fakeTxDriver{fakeDriver{currentVersion: "3"}})
+	req.ErrorContains(err, "does not support rolling back")
+}
+
+func TestMigrationPostCommitHook(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	var hooksRun []string
+	err := m.Register("1", "", noNonatomicMigration, noTxMigration, WithPostCommitHook(func(ctx context.Context, conn fakeConnPool) error {
+		hooksRun = append(hooksRun, "1")
+		return errors.New("hook failures are not fatal")
+	}))
+	req.NoError(err)
+
+	err = m.Register("2", "1", noNonatomicMigration, noTxMigration, WithPostCommitHook(func(ctx context.Context, conn fakeConnPool) error {
+		hooksRun = append(hooksRun, "2")
+		return nil
+	}))
+	req.NoError(err)
+
+	drv := &fakeTxDriver{}
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("2", drv.currentVersion)
+	req.Equal([]string{"1", "2"}, hooksRun)
+
+	// A hook for a different connection type is rejected at registration.
+	err = m.Register("3", "2", noNonatomicMigration, noTxMigration, WithPostCommitHook(func(ctx context.Context, conn string) error {
+		return nil
+	}))
+	req.ErrorContains(err, "post-commit hook for revision 3 has type migrate.MigrationFunc[string]")
+	req.False(m.IsRegistered("3"))
+
+	// So are phases for a different connection type.
+	err = m.Register("3", "2", noNonatomicMigration, noTxMigration, WithPhases(Phase[string]{Name: "first", Run: func(ctx context.Context, conn string) error {
+		return nil
+	}}))
+	req.ErrorContains(err, "phases for revision 3 has type []migrate.Phase[string]")
+}
+
+// fakeCheckpointDriver is a fakeTxDriver that records completed phases.
+type fakeCheckpointDriver struct {
+	fakeTxDriver
+	completed map[string][]string
+}
+
+func (fd *fakeCheckpointDriver) CompletedPhases(ctx context.Context, version string) ([]string, error) {
+	return fd.completed[version], ctx.Err()
+}
+
+func (fd *fakeCheckpointDriver) MarkPhaseCompleted(ctx context.Context, version, phase string) error {
+	if fd.completed == nil {
+		fd.completed = make(map[string][]string)
+	}
+	fd.completed[version] = append(fd.completed[version], phase)
+	return ctx.Err()
+}
+
+func (fd *fakeCheckpointDriver) ClearPhases(ctx context.Context, _ fakeTx, version string) error {
+	delete(fd.completed, version)
+	return ctx.Err()
+}
+
+// fakeRollbackCheckpointDriver is a fakeCheckpointDriver that can revert its
+// version.
+type fakeRollbackCheckpointDriver struct {
+	fakeCheckpointDriver
+}
+
+func (fd *fakeRollbackCheckpointDriver) RevertVersion(ctx context.Context, _ fakeTx, _, replaced string) error {
+	if ctx.Err() == nil {
+		fd.currentVersion = replaced
+	}
+	return ctx.Err()
+}
+
+func TestRollbackPhasedMigration(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	var phasesRun []string
+	phase := func(name string) Phase[fakeConnPool] {
+		return Phase[fakeConnPool]{Name: name, Run: func(ctx context.Context, conn fakeConnPool) error {
+			phasesRun = append(phasesRun, name)
+			return nil
+		}}
+	}
+	down := WithDown(func(ctx context.Context, tx fakeTx) error { return nil })
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration, down, WithPhases(phase("first"), phase("second"))))
+
+	drv := &fakeRollbackCheckpointDriver{}
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal([]string{"first", "second"}, drv.completed["2"])
+
+	// Rolling back clears the checkpoints of the reverted migration, so that
+	// migrating again runs all of its phases.
+	_, err := m.Rollback(context.Background(), drv)
+	req.NoError(err)
+	req.Equal("1", drv.currentVersion)
+	req.Empty(drv.completed["2"])
+
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("2", drv.currentVersion)
+	req.Equal([]string{"first", "second", "first", "second"}, phasesRun)
+	req.Equal([]string{"first", "second"}, drv.completed["2"])
+
+	// Phased migrations cannot be rolled back without checkpoint support.
+	_, err = m.Rollback(context.Background(), &fakeRollbackDriver{fakeTxDriver{fakeDriver{currentVersion: "2"}}})
+	req.ErrorContains(err, "does not support checkpoints")
+}
+
+func TestMigrationPhases(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	var phasesRun []string
+	interrupted := true
+	err := m.Register("1", "", noNonatomicMigration, noTxMigration, WithPhases(
+		Phase[fakeConnPool]{Name: "first", Run: func(ctx context.Context, conn fakeConnPool) error {
+			phasesRun = append(phasesRun, "first")
+			return nil
+		}},
+		Phase[fakeConnPool]{Name: "second", Run: func(ctx context.Context, conn fakeConnPool) error {
+			phasesRun = append(phasesRun, "second")
+			if interrupted {
+				interrupted = false
+				return errors.New("interrupted")
+			}
+			return nil
+		}},
+	))
+	req.NoError(err)
+
+	drv := &fakeCheckpointDriver{}
+	req.Error(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("", drv.currentVersion)
+	req.Equal([]string{"first"}, drv.completed["1"])
+
+	// Resuming skips the phase that has already completed.
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("1", drv.currentVersion)
+	req.Equal([]string{"first", "second", "second"}, phasesRun)
+	req.Equal([]string{"first", "second"}, drv.completed["1"])
+
+	// Drivers without checkpoint support cannot run phased migrations.
+	req.Error(m.Run(context.Background(), &fakeTxDriver{}, Head, LiveRun))
+
+	// Phase names must be unique.
+	noop := func(ctx context.Context, conn fakeConnPool) error { return nil }
+	err = m.Register("2", "1", noNonatomicMigration, noTxMigration, WithPhases(
+		Phase[fakeConnPool]{Name: "dup", Run: noop},
+		Phase[fakeConnPool]{Name: "dup", Run: noop},
+	))
+	req.Error(err)
+}
+
+func TestResumeMigration(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	var phasesRun []string
+	phase := func(name string) Phase[fakeConnPool] {
+		return Phase[fakeConnPool]{Name: name, Run: func(ctx context.Context, conn fakeConnPool) error {
+			phasesRun = append(phasesRun, name)
+			return nil
+		}}
+	}
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration, WithPhases(phase("first"), phase("second"), phase("third"))))
+
+	names, err := m.Phases("2")
+	req.NoError(err)
+	req.Equal([]string{"first", "second", "third"}, names)
+	names, err = m.Phases("1")
+	req.NoError(err)
+	req.Nil(names)
+	_, err = m.Phases("unknown")
+	req.Error(err)
+
+	// A failed run left the second phase recorded as completed, but it must
+	// be run again.
+	drv := &fakeCheckpointDriver{fakeTxDriver: fakeTxDriver{fakeDriver{currentVersion: "1"}}}
+	drv.completed = map[string][]string{"2": {"first", "second"}}
+	req.NoError(m.ResumeMigration(context.Background(), drv, "2", 1))
+	req.Equal("2", drv.currentVersion)
+	req.Equal([]string{"second", "third"}, phasesRun)
+
+	// Only a phased migration replacing the current version can be resumed,
+	// from one of its phases.
+	req.ErrorContains(m.ResumeMigration(context.Background(), drv, "2", 0), "out of order")
+	req.ErrorContains(m.ResumeMigration(context.Background(), drv, "1", 0), "not phased")
+	req.ErrorContains(m.ResumeMigration(context.Background(), drv, "2", 3), "invalid step")
+	req.ErrorContains(m.ResumeMigration(context.Background(), drv, "2", -1), "invalid step")
+	req.Error(m.ResumeMigration(context.Background(), drv, "unknown", 0))
+	req.ErrorContains(m.ResumeMigration(context.Background(), &fakeTxDriver{fakeDriver{currentVersion: "1"}}, "2", 0), "does not support checkpoints")
+}
+
+func TestExpandContractMigrations(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration, AsExpand()))
+	req.NoError(m.Register("3", "2", noNonatomicMigration, noTxMigration, AsContract("2")))
+
+	// A contract must name its expand, which cannot be itself.
+	req.Error(m.Register("4", "3", noNonatomicMigration, noTxMigration, AsContract("")))
+	req.Error(m.Register("4", "3", noNonatomicMigration, noTxMigration, AsContract("4")))
+
+	// The expand and contract cannot be applied by the same run of a
+	// datastore that is already in use.
+	drv := &fakeTxDriver{fakeDriver{currentVersion: "1"}}
+	req.ErrorContains(m.Run(context.Background(), drv, Head, DryRun), "applied by a previous run")
+	req.ErrorContains(m.Run(context.Background(), drv, Head, LiveRun), "applied by a previous run")
+	req.Equal("1", drv.currentVersion)
+
+	// Once the expand has been rolled out, the contract can follow.
+	req.NoError(m.Run(context.Background(), drv, "2", LiveRun))
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("3", drv.currentVersion)
+
+	// A new datastore can be migrated through both at once.
+	fresh := &fakeTxDriver{}
+	req.NoError(m.Run(context.Background(), fresh, Head, LiveRun))
+	req.Equal("3", fresh.currentVersion)
+
+	t.Run("expand must precede its contract", func(t *testing.T) {
+		m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+		require.NoError(t, m.Register("1", "", noNonatomicMigration, noTxMigration, AsContract("2")))
+		require.NoError(t, m.Register("2", "1", noNonatomicMigration, noTxMigration, AsExpand()))
+		require.ErrorContains(t, m.Run(context.Background(), &fakeTxDriver{}, Head, LiveRun), "must come after")
+	})
+
+	t.Run("expand must be registered as one", func(t *testing.T) {
+		m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+		require.NoError(t, m.Register("1", "", noNonatomicMigration, noTxMigration))
+		require.NoError(t, m.Register("2", "1", noNonatomicMigration, noTxMigration, AsContract("1")))
+		require.ErrorContains(t, m.Run(context.Background(), &fakeTxDriver{}, Head, LiveRun), "not a registered expand migration")
+	})
+}
+
+// fakeSeedingDriver is a fakeTxDriver that counts the number of times it was seeded.
+type fakeSeedingDriver struct {
+	fakeTxDriver
+	seeded int
+}
+
+func (fd *fakeSeedingDriver) Seed(ctx context.Context) error {
+	fd.seeded++
+	return ctx.Err()
+}
+
+func TestSeedAfterHeadMigration(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration))
+
+	drv := &fakeSeedingDriver{}
+	req.NoError(m.Run(context.Background(), drv, "1", LiveRun))
+	req.Equal(0, drv.seeded)
+
+	req.NoError(m.Run(context.Background(), drv, "2", DryRun))
+	req.Equal(0, drv.seeded)
+
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.Equal("2", drv.currentVersion)
+	req.Equal(1, drv.seeded)
+}
+
+// fakeTimedDriver is a fakeTxDriver that records the migration durations given to WriteVersion.
+type fakeTimedDriver struct {
+	fakeTxDriver
+	durations map[string]time.Duration
+}
+
+func (fd *fakeTimedDriver) WriteVersion(ctx context.Context, tx fakeTx, to, replaced string) error {
+	if duration, ok := MigrationDuration(ctx); ok {
+		fd.durations[to] = duration
+	}
+	return fd.fakeTxDriver.WriteVersion(ctx, tx, to, replaced)
+}
+
+func TestMigrationDuration(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	req.NoError(m.Register("1", "", func(ctx context.Context, conn fakeConnPool) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, noTxMigration))
+
+	_, ok := MigrationDuration(context.Background())
+	req.False(ok)
+
+	drv := &fakeTimedDriver{durations: map[string]time.Duration{}}
+	req.NoError(m.Run(context.Background(), drv, Head, LiveRun))
+	req.GreaterOrEqual(drv.durations["1"], 10*time.Millisecond)
+}
+
+func TestSQLScript(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration, WithSQL("CREATE TABLE a (id INT)", "CREATE INDEX ix_a ON a (id);")))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("3", "2", noNonatomicMigration, noTxMigration, WithSQL("\n\t\tDROP TABLE a;\n\t")))
+
+	script, err := m.SQLScript()
+	req.NoError(err)
+	req.Equal(`-- migration: 1
+CREATE TABLE a (id INT);
+CREATE INDEX ix_a ON a (id);
+
+-- migration: 2
+-- this migration runs procedural logic and cannot be expressed as SQL
+
+-- migration: 3
+DROP TABLE a;
+`, script)
+
+	_, err = NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]().SQLScript()
+	req.ErrorIs(err, ErrNoMigrationsRegistered)
+}
+
+func TestDeclareSQL(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration, WithSQL("DROP TABLE a")))
+
+	req.NoError(m.DeclareSQL("1", "CREATE TABLE a (id INT)"))
+	req.ErrorContains(m.DeclareSQL("1", "CREATE TABLE b (id INT)"), "already been declared")
+	req.ErrorContains(m.DeclareSQL("2", "DROP TABLE b"), "already been declared")
+	req.ErrorContains(m.DeclareSQL("3", "DROP TABLE a"), "unknown revision")
+
+	script, err := m.SQLScript()
+	req.NoError(err)
+	req.Equal(`-- migration: 1
+CREATE TABLE a (id INT);
+
+-- migration: 2
+DROP TABLE a;
+`, script)
+}
+
 var noMigrations = map[string]migration[fakeConnPool, fakeTx]{}
 
 var simpleMigrations = map[string]migration[fakeConnPool, fakeTx]{
-	"123": {"123", "", noNonatomicMigration, noTxMigration},
+	"123": {version: "123", replaces: "", up: noNonatomicMigration, upTx: noTxMigration},
 }
 
 var singleHeadedChain = map[string]migration[fakeConnPool, fakeTx]{
-	"123": {"123", "", noNonatomicMigration, noTxMigration},
-	"456": {"456", "123", noNonatomicMigration, noTxMigration},
-	"789": {"789", "456", noNonatomicMigration, noTxMigration},
+	"123": {version: "123", replaces: "", up: noNonatomicMigration, upTx: noTxMigration},
+	"456": {version: "456", replaces: "123", up: noNonatomicMigration, upTx: noTxMigration},
+	"789": {version: "789", replaces: "456", up: noNonatomicMigration, upTx: noTxMigration},
 }
 
 var multiHeadedChain = map[string]migration[fakeConnPool, fakeTx]{
-	"123":  {"123", "", noNonatomicMigration, noTxMigration},
-	"456":  {"456", "123", noNonatomicMigration, noTxMigration},
-	"789a": {"789a", "456", noNonatomicMigration, noTxMigration},
-	"789b": {"789b", "456", noNonatomicMigration, noTxMigration},
+	"123":  {version: "123", replaces: "", up: noNonatomicMigration, upTx: noTxMigration},
+	"456":  {version: "456", replaces: "123", up: noNonatomicMigration, upTx: noTxMigration},
+	"789a": {version: "789a", replaces: "456", up: noNonatomicMigration, upTx: noTxMigration},
+	"789b": {version: "789b", replaces: "456", up: noNonatomicMigration, upTx: noTxMigration},
 }
 
 var missingEarlyMigrations = map[string]migration[fakeConnPool, fakeTx]{
-	"456": {"456", "123", noNonatomicMigration, noTxMigration},
-	"789": {"789", "456", noNonatomicMigration, noTxMigration},
-	"10":  {"10", "789", noNonatomicMigration, noTxMigration},
+	"456": {version: "456", replaces: "123", up: noNonatomicMigration, upTx: noTxMigration},
+	"789": {version: "789", replaces: "456", up: noNonatomicMigration, upTx: noTxMigration},
+	"10":  {version: "10", replaces: "789", up: noNonatomicMigration, upTx: noTxMigration},
+}
+
+func TestReservedPrefixes(t *testing.T) {
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx](WithReservedPrefixes("888", "tmp_"))
+	require.Equal(t, []string{"888", "tmp_"}, m.ReservedPrefixes())
+
+	require.True(t, m.IsReservedPrefix("888"))
+	require.True(t, m.IsReservedPrefix("tmp_migration"))
+	require.False(t, m.IsReservedPrefix("add_tmp_table"))
+
+	require.ErrorContains(t, m.Register("tmp_migration", "", noNonatomicMigration, noTxMigration), "reserved prefix")
+	require.NoError(t, m.Register("add_tmp_table", "", noNonatomicMigration, noTxMigration))
+
+	// Without reserved prefixes, every version may be registered.
+	unreserved := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	require.False(t, unreserved.IsReservedPrefix("888"))
+	require.NoError(t, unreserved.Register("888", "", noNonatomicMigration, noTxMigration))
 }
@@ -0,0 +1,172 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// Phase is a single step of a migration that has been split into phases. Each
+// phase must be idempotent: a phase that was interrupted before its completion
+// was recorded will be run again in its entirety.
+type Phase[C any] struct {
+	// Name uniquely identifies the phase within its migration.
+	Name string
+
+	// Run performs the work of the phase.
+	Run MigrationFunc[C]
+}
+
+// CheckpointDriver is implemented by drivers that can durably record which
+// phases of a migration have completed, allowing an interrupted migration to
+// resume from its last completed phase.
+type CheckpointDriver[T any] interface {
+	// CompletedPhases returns the names of the phases of the migration to the
+	// given version that have been recorded as completed.
+	CompletedPhases(ctx context.Context, version string) ([]string, error)
+
+	// MarkPhaseCompleted records the named phase of the migration to the given
+	// version as completed.
+	MarkPhaseCompleted(ctx context.Context, version, phase string) error
+
+	// ClearPhases deletes, in the transaction, the phases recorded as completed
+	// for the migration to the given version. Rollback calls it when reverting
+	// a phased migration, so that migrating again runs every phase.
+	ClearPhases(ctx context.Context, tx T, version string) error
+}
+
+// WithPhases declares a migration as a sequence of checkpointed phases that
+// are run in order, with the driver's connection, before the migration's
+// non-transactional and transactional functions. Phases recorded as completed
+// by a previous, interrupted run are skipped.
+//
+// The driver with which the migration is run must implement CheckpointDriver,
+// and the connection type of the phases must match that of the manager with
+// which the migration is registered.
+func WithPhases[C any](phases ...Phase[C]) MigrationOption {
+	return func(mo *migrationOptions) {
+		mo.typed = append(mo.typed, connOption[C]{
+			name:  "phases",
+			value: fmt.Sprintf("%T", phases),
+			apply: func(co *connOptions[C]) { co.phases = phases },
+		})
+	}
+}
+
+func validatePhases[C any](version string, phases []Phase[C]) error {
+	names := make(map[string]struct{}, len(phases))
+	for _, phase := range phases {
+		if phase.Name == "" {
+			return fmt.Errorf("phase for revision %s must have a name", version)
+		}
+		if _, ok := names[phase.Name]; ok {
+			return fmt.Errorf("duplicate phase %s for revision %s", phase.Name, version)
+		}
+		if phase.Run == nil {
+			return fmt.Errorf("phase %s for revision %s must have a function to run", phase.Name, version)
+		}
+		names[phase.Name] = struct{}{}
+	}
+	return nil
+}
+
+func runPhases[C any, T any](ctx context.Context, driver any, conn C, version string, phases []Phase[C]) error {
+	checkpoints, ok := driver.(CheckpointDriver[T])
+	if !ok {
+		return fmt.Errorf("migration %s is phased, but driver %T does not support checkpoints", version, driver)
+	}
+
+	completed, err := checkpoints.CompletedPhases(ctx, version)
+	if err != nil {
+		return fmt.Errorf("unable to load completed phases: %w", err)
+	}
+
+	for _, phase := range phases {
+		if slices.Contains(completed, phase.Name) {
+			log.Ctx(ctx).Info().Str("version", version).Str("phase", phase.Name).Msg("skipping completed migration phase")
+			continue
+		}
+
+		if err := runPhase(ctx, checkpoints, conn, version, phase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPhase[C any, T any](ctx context.Context, checkpoints CheckpointDriver[T], conn C, version string, phase Phase[C]) error {
+	log.Ctx(ctx).Info().Str("version", version).Str("phase", phase.Name).Msg("running migration phase")
+	if err := phase.Run(ctx, conn); err != nil {
+		return fmt.Errorf("error executing phase %s: %w", phase.Name, err)
+	}
+
+	if err := checkpoints.MarkPhaseCompleted(ctx, version, phase.Name); err != nil {
+		return fmt.Errorf("unable to record completion of phase %s: %w", phase.Name, err)
+	}
+	return nil
+}
+
+// Phases returns the names of the phases of the migration to the given
+// version, in the order in which they are run, or nil if the migration is not
+// phased.
+func (m *Manager[D, C, T]) Phases(version string) ([]string, error) {
+	found, ok := m.migrations[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown migration: %s", version)
+	}
+
+	phases := found.connOptions.phases
+	if len(phases) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(phases))
+	for _, phase := range phases {
+		names = append(names, phase.Name)
+	}
+	return names, nil
+}
+
+// ResumeMigration re-runs the phased migration to the given version starting
+// at the phase with index fromStep, as returned by Phases, followed by the rest
+// of the migration, which then records its version as usual. The phases from
+// fromStep onwards are run even if they were recorded as completed, while
+// those before it are assumed to have been applied, whether or not they were
+// recorded. The driver's current version must be the one the migration
+// replaces.
+//
+// DANGER: this is a recovery tool for experts, for the rare migrations that
+// failed partway and left the schema partially changed. Each re-run phase must
+// tolerate the state in which the failure left the schema, and skipping phases
+// that were not in fact applied leaves the schema inconsistent with the
+// recorded version.
+func (m *Manager[D, C, T]) ResumeMigration(ctx context.Context, driver D, version string, fromStep int) error {
+	found, ok := m.migrations[version]
+	if !ok {
+		return fmt.Errorf("unknown migration: %s", version)
+	}
+
+	phases := found.connOptions.phases
+	if len(phases) == 0 {
+		return fmt.Errorf("migration %s is not phased and cannot be resumed from a step", version)
+	}
+	if fromStep < 0 || fromStep >= len(phases) {
+		return fmt.Errorf("invalid step %d for migration %s: must be between 0 and %d", fromStep, version, len(phases)-1)
+	}
+
+	checkpoints, ok := any(driver).(CheckpointDriver[T])
+	if !ok {
+		return fmt.Errorf("migration %s is phased, but driver %T does not support checkpoints", version, driver)
+	}
+
+	return applyMigration(ctx, driver, found, func(ctx context.Context) error {
+		for _, phase := range phases[fromStep:] {
+			if err := runPhase(ctx, checkpoints, driver.Conn(), version, phase); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
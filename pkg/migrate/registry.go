@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// Runner runs the migrations of a datastore through a driver connected to it,
+// independently of the types of the driver's connection and transaction.
+type Runner interface {
+	// Run migrates the datastore through the given revision.
+	Run(ctx context.Context, throughRevision string, dryRun RunType) error
+
+	// HeadRevision returns the latest revision of the migrations.
+	HeadRevision() (string, error)
+
+	// Close frees up any resources in use by the driver.
+	Close(ctx context.Context) error
+}
+
+// NewRunner returns a Runner that runs the migrations of the manager with the
+// driver.
+func NewRunner[D Driver[C, T], C any, T any](manager *Manager[D, C, T], driver D) Runner {
+	return &managerRunner[D, C, T]{manager: manager, driver: driver}
+}
+
+type managerRunner[D Driver[C, T], C any, T any] struct {
+	manager *Manager[D, C, T]
+	driver  D
+}
+
+func (r *managerRunner[D, C, T]) Run(ctx context.Context, throughRevision string, dryRun RunType) error {
+	return r.manager.Run(ctx, r.driver, throughRevision, dryRun)
+}
+
+func (r *managerRunner[D, C, T]) HeadRevision() (string, error) {
+	return r.manager.HeadRevision()
+}
+
+func (r *managerRunner[D, C, T]) Close(ctx context.Context) error {
+	return r.driver.Close(ctx)
+}
+
+// RunnerOptions are the settings with which an Engine connects its driver to
+// a datastore. Each engine uses the settings that apply to it and ignores the
+// others.
+type RunnerOptions struct {
+	// CredentialsProvider, if set, supplies the credentials of the connection
+	// in place of those in the connection URI.
+	CredentialsProvider datastore.CredentialsProvider
+
+	// TablePrefix is the prefix of the names of all of the datastore's tables,
+	// for engines whose tables can be prefixed, such as MySQL. Engines whose
+	// tables cannot be prefixed, such as CockroachDB, reject it.
+	TablePrefix string
+
+	// CredentialsFile is the path of a file holding the credentials of the
+	// connection, for engines that authenticate with one, such as Spanner.
+	CredentialsFile string
+
+	// EmulatorHost is the address of an emulator of the datastore to connect
+	// to in place of the datastore, for engines that have one, such as
+	// Spanner.
+	EmulatorHost string
+}
+
+// Engine is the registration of the migrations of a datastore engine.
+type Engine struct {
+	// NewRunner connects a driver to the datastore at the connection URI with
+	// the options and returns a Runner for it.
+	NewRunner func(ctx context.Context, uri string, options RunnerOptions) (Runner, error)
+
+	// HeadRevision returns the latest revision of the migrations, without
+	// connecting to a datastore.
+	HeadRevision func() (string, error)
+
+	// ValidateURI, if set, checks that a connection URI is valid for the
+	// engine without connecting to a datastore.
+	ValidateURI func(uri string) error
+
+	// SQLScript, if set, returns a SQL script that produces the schema of the
+	// head revision, without connecting to a datastore.
+	SQLScript func() (string, error)
+}
+
+var (
+	enginesLock sync.RWMutex
+	engines     = map[string]Engine{}
+)
+
+// RegisterEngine registers the migrations of the named datastore engine, so
+// that they can be run by name with LookupEngine. It is typically called from
+// the init function of the package providing the engine's driver, and fails if
+// the name is already registered.
+func RegisterEngine(name string, engine Engine) error {
+	if engine.NewRunner == nil || engine.HeadRevision == nil {
+		return fmt.Errorf("migrations of datastore engine %s must provide NewRunner and HeadRevision", name)
+	}
+
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+
+	if _, ok := engines[name]; ok {
+		return fmt.Errorf("migrations of datastore engine %s are already registered", name)
+	}
+	engines[name] = engine
+	return nil
+}
+
+// LookupEngine returns the registered migrations of the named datastore
+// engine.
+func LookupEngine(name string) (Engine, bool) {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	engine, ok := engines[name]
+	return engine, ok
+}
+
+// RegisteredEngines returns the names of the datastore engines whose
+// migrations are registered, in sorted order.
+func RegisteredEngines() []string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	return slices.Sorted(maps.Keys(engines))
+}
@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterEngine(t *testing.T) {
+	m := NewManager[*fakeTxDriver, fakeConnPool, fakeTx]()
+	require.NoError(t, m.Register("1", "", noNonatomicMigration, noTxMigration))
+	require.NoError(t, m.Register("2", "1", noNonatomicMigration, noTxMigration))
+
+	drv := &fakeTxDriver{}
+	var connectedURI string
+	var connectedOptions RunnerOptions
+	engine := Engine{
+		NewRunner: func(_ context.Context, uri string, options RunnerOptions) (Runner, error) {
+			connectedURI = uri
+			connectedOptions = options
+			return NewRunner(m, drv), nil
+		},
+		HeadRevision: m.HeadRevision,
+	}
+
+	require.NoError(t, RegisterEngine("test-engine", engine))
+	t.Cleanup(func() {
+		enginesLock.Lock()
+		defer enginesLock.Unlock()
+		delete(engines, "test-engine")
+	})
+
+	require.Error(t, RegisterEngine("test-engine", engine), "engines cannot be registered twice")
+	require.Error(t, RegisterEngine("incomplete-engine", Engine{}))
+	require.Contains(t, RegisteredEngines(), "test-engine")
+
+	_, ok := LookupEngine("unknown-engine")
+	require.False(t, ok)
+
+	registered, ok := LookupEngine("test-engine")
+	require.True(t, ok)
+
+	head, err := registered.HeadRevision()
+	require.NoError(t, err)
+	require.Equal(t, "2", head)
+
+	ctx := context.Background()
+	runner, err := registered.NewRunner(ctx, "test://datastore", RunnerOptions{TablePrefix: "tenant1_"})
+	require.NoError(t, err)
+	require.Equal(t, "test://datastore", connectedURI)
+	require.Equal(t, RunnerOptions{TablePrefix: "tenant1_"}, connectedOptions)
+
+	require.NoError(t, runner.Run(ctx, Head, LiveRun))
+	require.Equal(t, "2", drv.currentVersion)
+	require.NoError(t, runner.Close(ctx))
+}
@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"slices"
+	"strings"
+)
+
+// WithReservedPrefixes reserves the version prefixes for the datastore, such
+// as those that are not valid at the start of an identifier of its database.
+// Registering a migration whose version begins with a reserved prefix fails,
+// and IsReservedPrefix allows tooling, such as generators of migration stubs,
+// to check a version before registering it. Each datastore declares its own
+// prefixes; by default, none are reserved.
+func WithReservedPrefixes(prefixes ...string) ManagerOption {
+	return func(mo *managerOptions) { mo.reservedPrefixes = append(mo.reservedPrefixes, prefixes...) }
+}
+
+// ReservedPrefixes returns the version prefixes reserved with
+// WithReservedPrefixes.
+func (m *Manager[D, C, T]) ReservedPrefixes() []string {
+	return slices.Clone(m.reservedPrefixes)
+}
+
+// IsReservedPrefix returns whether the version begins with one of the prefixes
+// reserved with WithReservedPrefixes, and so cannot be registered.
+func (m *Manager[D, C, T]) IsReservedPrefix(version string) bool {
+	_, reserved := m.reservedPrefix(version)
+	return reserved
+}
+
+// reservedPrefix returns the reserved prefix with which the version begins, if
+// any.
+func (m *Manager[D, C, T]) reservedPrefix(version string) (string, bool) {
+	for _, prefix := range m.reservedPrefixes {
+		if strings.HasPrefix(version, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
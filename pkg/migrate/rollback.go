@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// WithDown registers the inverse of the migration, which Rollback runs in a
+// transaction to revert the datastore from the migration's version to the
+// version it replaces. Migrations registered without it are irreversible.
+//
+// The transaction type of the function must match that of the manager with
+// which the migration is registered.
+func WithDown[T any](down TxMigrationFunc[T]) MigrationOption {
+	return func(mo *migrationOptions) {
+		mo.typed = append(mo.typed, txOption[T]{
+			name:  "down migration",
+			value: fmt.Sprintf("%T", down),
+			apply: func(to *txOptions[T]) { to.down = down },
+		})
+	}
+}
+
+// RollbackDriver is implemented by drivers whose datastores can be rolled
+// back with Rollback.
+type RollbackDriver[T any] interface {
+	// RevertVersion records, in the transaction, that the datastore has been
+	// reverted from version to replaced, the version it replaced.
+	RevertVersion(ctx context.Context, tx T, version, replaced string) error
+}
+
+// IrreversibleMigrationError is returned by Rollback when the migration to
+// the datastore's current version was registered without WithDown.
+type IrreversibleMigrationError struct {
+	// Version is the version of the irreversible migration.
+	Version string
+}
+
+func (err IrreversibleMigrationError) Error() string {
+	return fmt.Sprintf("irreversible migration: %s has no registered down migration", err.Version)
+}
+
+// Rollback reverts the driver's datastore by one version, running the down
+// migration of its current version and recording the version that it
+// replaced, in a single transaction. It returns the transition that was made,
+// from the current version to its predecessor. The driver must implement
+// RollbackDriver, and CheckpointDriver if the migration is phased, in which
+// case the completed phases of the migration are cleared in the transaction.
+func (m *Manager[D, C, T]) Rollback(ctx context.Context, driver D) (MigrationStep, error) {
+	reverter, ok := any(driver).(RollbackDriver[T])
+	if !ok {
+		return MigrationStep{}, fmt.Errorf("driver %T does not support rolling back migrations", driver)
+	}
+
+	currentVersion, err := driver.Version(ctx)
+	if err != nil {
+		return MigrationStep{}, fmt.Errorf("unable to load version from driver: %w", err)
+	}
+	if currentVersion == None {
+		return MigrationStep{}, fmt.Errorf("unable to roll back a datastore that has not been migrated")
+	}
+
+	migrationToRevert, ok := m.migrations[currentVersion]
+	if !ok {
+		return MigrationStep{}, fmt.Errorf("unknown current version: %s", currentVersion)
+	}
+
+	down := migrationToRevert.txOptions.down
+	if down == nil {
+		return MigrationStep{}, IrreversibleMigrationError{Version: currentVersion}
+	}
+
+	// The checkpoints of a phased migration are cleared along with its version,
+	// lest migrating again skip the phases that were reverted.
+	var checkpoints CheckpointDriver[T]
+	if len(migrationToRevert.connOptions.phases) > 0 {
+		checkpoints, ok = any(driver).(CheckpointDriver[T])
+		if !ok {
+			return MigrationStep{}, fmt.Errorf("migration %s is phased, but driver %T does not support checkpoints", currentVersion, driver)
+		}
+	}
+
+	step := MigrationStep{From: migrationToRevert.version, To: migrationToRevert.replaces}
+	log.Ctx(ctx).Info().Str("from", step.From).Str("to", step.To).Msg("rolling back")
+
+	if err := withMigrationRetries(ctx, migrationToRevert.options, func() error {
+		return driver.RunTx(ctx, func(ctx context.Context, tx T) error {
+			if err := down(ctx, tx); err != nil {
+				return err
+			}
+			if checkpoints != nil {
+				if err := checkpoints.ClearPhases(ctx, tx, step.From); err != nil {
+					return fmt.Errorf("unable to clear completed phases: %w", err)
+				}
+			}
+			return reverter.RevertVersion(ctx, tx, step.From, step.To)
+		})
+	}); err != nil {
+		return MigrationStep{}, fmt.Errorf("error rolling back migration `%s`: %w", step.From, err)
+	}
+
+	currentVersion, err = driver.Version(ctx)
+	if err != nil {
+		return MigrationStep{}, fmt.Errorf("unable to load version from driver: %w", err)
+	}
+	if currentVersion != step.To {
+		return MigrationStep{}, fmt.Errorf("the down migration succeeded, but the driver did not report the expected version: %s", step.To)
+	}
+	return step, nil
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLScript returns a SQL script that produces the schema of the head
+// revision, without connecting to a datastore, by concatenating the
+// statements of each migration, from the first through head, in the order in
+// which they are applied. Migrations whose bodies were not declared with
+// WithSQL, such as those that run procedural Go logic, are represented by a
+// comment placeholder, which must be reviewed and applied by hand.
+func (m *Manager[D, C, T]) SQLScript() (string, error) {
+	if m.noMigrationsExpected {
+		return "", nil
+	}
+
+	head, err := m.HeadRevision()
+	if err != nil {
+		return "", fmt.Errorf("unable to compute head revision: %w", err)
+	}
+
+	toRun, err := collectMigrationsInRange(None, head, m.migrations)
+	if err != nil {
+		return "", fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	var sb strings.Builder
+	for i, migration := range toRun {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "-- migration: %s\n", migration.version)
+		if migration.options.sql == nil {
+			sb.WriteString("-- this migration runs procedural logic and cannot be expressed as SQL\n")
+			continue
+		}
+		for _, statement := range migration.options.sql {
+			statement = strings.TrimSpace(statement)
+			if !strings.HasSuffix(statement, ";") {
+				statement += ";"
+			}
+			sb.WriteString(statement)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// MigrationTiming is the timing of a single migration, from the start of its
+// phases, if any, through the commit of its transaction.
+type MigrationTiming struct {
+	Version  string
+	Replaces string
+	Duration time.Duration
+
+	// Err is the error with which the migration failed, or nil if it succeeded.
+	Err error
+}
+
+// TimingDriver is implemented by drivers that opt in to timing the migrations
+// run with them. The Manager times each such migration as a whole, including
+// its phases, its non-transactional part and its transaction with any retries,
+// reporting to the function returned by MigrationTimingReporter, when it is
+// enabled.
+type TimingDriver interface {
+	MigrationTimingReporter() (report func(MigrationTiming), enabled bool)
+}
+
+// timeMigration runs fn, the migration from replaces to version, logging when
+// it completes, with the time it took, or when it fails. The timing is also
+// reported to report, if not nil.
+func timeMigration(ctx context.Context, version, replaces string, report func(MigrationTiming), fn func() error) error {
+	started := time.Now()
+
+	err := fn()
+	elapsed := time.Since(started)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("version", version).Str("replaces", replaces).Dur("duration", elapsed).Msg("migration failed")
+	} else {
+		log.Ctx(ctx).Info().Str("version", version).Str("replaces", replaces).Dur("duration", elapsed).Msg("completed migration")
+	}
+
+	if report != nil {
+		report(MigrationTiming{
+			Version:  version,
+			Replaces: replaces,
+			Duration: elapsed,
+			Err:      err,
+		})
+	}
+	return err
+}
@@ -0,0 +1,227 @@
+package zedtoken
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	zedtoken "github.com/authzed/spicedb/pkg/proto/impl/v1"
+)
+
+// EncodingVersion is the version of the format in which a revision is encoded
+// within a zedtoken.
+type EncodingVersion uint32
+
+const (
+	// encodingZookie is the version of legacy zookies, which predate
+	// zedtokens.
+	encodingZookie EncodingVersion = 0
+
+	// EncodingV1 encodes the revision as its string form.
+	EncodingV1 EncodingVersion = 1
+
+	// EncodingV2 encodes the revision as its string form prefixed by a version
+	// tag, allowing the format of the revision to change in later versions
+	// without the tokens being mistaken for one another.
+	EncodingV2 EncodingVersion = 2
+)
+
+// v2Tag prefixes revisions encoded with EncodingV2. Revisions never begin with
+// a letter, so the tag cannot be confused with a V1 revision.
+const v2Tag = "v2:"
+
+// originSeparator separates the revision encoded with EncodingV2 from the
+// origin that follows it, if any. Revisions never contain it.
+const originSeparator = "@"
+
+// RevisionOriginError is returned when a zedtoken was emitted for a different
+// datastore than the one configured with WithOrigin, such as after SpiceDB is
+// pointed at a restored or migrated datastore.
+type RevisionOriginError struct {
+	// Expected is the origin configured with WithOrigin.
+	Expected string
+
+	// Actual is the origin embedded in the zedtoken.
+	Actual string
+}
+
+func (err RevisionOriginError) Error() string {
+	return fmt.Sprintf("zedtoken was issued for datastore %q rather than %q", err.Actual, err.Expected)
+}
+
+// Codec encodes revisions into zedtokens in a configured version of the
+// encoding, and decodes zedtokens of that and, optionally, older versions.
+//
+// When upgrading to a new encoding, servers should first be deployed decoding
+// the new version while still emitting the old one, and only then switched to
+// emitting the new version while still accepting legacy tokens, so that the
+// tokens held by clients during the rollout remain valid.
+type Codec struct {
+	emit         EncodingVersion
+	acceptLegacy bool
+	origin       string
+}
+
+// CodecOption configures a Codec.
+type CodecOption func(*Codec)
+
+// WithEmitVersion sets the version of the encoding used for new zedtokens.
+//
+// Defaults to EncodingV1.
+func WithEmitVersion(version EncodingVersion) CodecOption {
+	return func(c *Codec) { c.emit = version }
+}
+
+// WithAcceptLegacy sets whether zedtokens encoded with a version older than
+// the emitted one, including legacy zookies, are decoded.
+//
+// Enabled by default.
+func WithAcceptLegacy(accept bool) CodecOption {
+	return func(c *Codec) { c.acceptLegacy = accept }
+}
+
+// WithOrigin sets the identifier of the datastore whose revisions are encoded,
+// such as the instance ID of a datastore.InstanceIdentifiedDatastore.
+// Zedtokens emitted with EncodingV2 embed the origin, and DecodeRevision
+// rejects those embedding a different origin with a RevisionOriginError,
+// rather than misinterpreting a revision of another datastore. Zedtokens
+// without an origin, including all of those of older versions, cannot be
+// checked and are accepted.
+//
+// Requires EncodingV2.
+func WithOrigin(origin string) CodecOption {
+	return func(c *Codec) { c.origin = origin }
+}
+
+// NewCodec returns a Codec configured with the given options.
+func NewCodec(opts ...CodecOption) (*Codec, error) {
+	c := &Codec{emit: EncodingV1, acceptLegacy: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	switch c.emit {
+	case EncodingV1, EncodingV2:
+	default:
+		return nil, fmt.Errorf("unknown zedtoken encoding version: %d", c.emit)
+	}
+	if c.origin != "" && c.emit < EncodingV2 {
+		return nil, fmt.Errorf("zedtoken origin requires encoding version %d or later", EncodingV2)
+	}
+	return c, nil
+}
+
+var defaultCodec = &Codec{emit: EncodingV1, acceptLegacy: true}
+
+// DefaultCodec returns the Codec used by the package-level functions, which
+// emits EncodingV1 and accepts legacy tokens.
+func DefaultCodec() *Codec {
+	return defaultCodec
+}
+
+// MustNewFromRevision generates an encoded zedtoken from the revision,
+// panicking if it cannot be encoded.
+func (c *Codec) MustNewFromRevision(revision datastore.Revision) *v1.ZedToken {
+	encoded, err := c.NewFromRevision(revision)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
+
+// NewFromRevision generates an encoded zedtoken from the revision.
+func (c *Codec) NewFromRevision(revision datastore.Revision) (*v1.ZedToken, error) {
+	encodedRevision := revision.String()
+	if c.emit == EncodingV2 {
+		encodedRevision = v2Tag + encodedRevision
+		if c.origin != "" {
+			encodedRevision += originSeparator + c.origin
+		}
+	}
+
+	toEncode := &zedtoken.DecodedZedToken{
+		VersionOneof: &zedtoken.DecodedZedToken_V1{
+			V1: &zedtoken.DecodedZedToken_V1ZedToken{
+				Revision: encodedRevision,
+			},
+		},
+	}
+	encoded, err := Encode(toEncode)
+	if err != nil {
+		return nil, fmt.Errorf(errEncodeError, err)
+	}
+
+	return encoded, nil
+}
+
+// DecodeRevision converts and extracts the revision from a zedtoken or legacy
+// zookie, rejecting tokens encoded with a version older than the emitted one
+// unless legacy tokens are accepted, and tokens issued for a datastore other
+// than the configured origin.
+func (c *Codec) DecodeRevision(encoded *v1.ZedToken, ds revisionDecoder) (datastore.Revision, error) {
+	revString, version, origin, err := decodeRevisionString(encoded)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	if err := c.validateOrigin(origin); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	if version < c.emit && !c.acceptLegacy {
+		return datastore.NoRevision, fmt.Errorf(errDecodeError, fmt.Errorf("legacy zedtoken encoding version %d is not accepted", version))
+	}
+
+	parsed, err := ds.RevisionFromString(revString)
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf(errDecodeError, err)
+	}
+	return parsed, nil
+}
+
+// ValidateRevisionOrigin checks that the zedtoken was issued for the datastore
+// configured with WithOrigin, returning a RevisionOriginError if it embeds a
+// different origin. Zedtokens without an origin are accepted.
+func (c *Codec) ValidateRevisionOrigin(encoded *v1.ZedToken) error {
+	_, _, origin, err := decodeRevisionString(encoded)
+	if err != nil {
+		return err
+	}
+	return c.validateOrigin(origin)
+}
+
+func (c *Codec) validateOrigin(origin string) error {
+	if c.origin == "" || origin == "" || origin == c.origin {
+		return nil
+	}
+	return RevisionOriginError{Expected: c.origin, Actual: origin}
+}
+
+// decodeRevisionString returns the string form of the revision within a
+// zedtoken or legacy zookie, along with the version of its encoding and the
+// origin embedded in it, if any.
+func decodeRevisionString(encoded *v1.ZedToken) (revString string, version EncodingVersion, origin string, err error) {
+	decoded, err := Decode(encoded)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	switch ver := decoded.VersionOneof.(type) {
+	case *zedtoken.DecodedZedToken_DeprecatedV1Zookie:
+		return fmt.Sprintf("%d", ver.DeprecatedV1Zookie.Revision), encodingZookie, "", nil
+
+	case *zedtoken.DecodedZedToken_V1:
+		revString = ver.V1.Revision
+		trimmed, ok := strings.CutPrefix(revString, v2Tag)
+		if !ok {
+			return revString, EncodingV1, "", nil
+		}
+		revString, origin, _ = strings.Cut(trimmed, originSeparator)
+		return revString, EncodingV2, origin, nil
+
+	default:
+		return "", 0, "", fmt.Errorf(errDecodeError, fmt.Errorf("unknown zookie version: %T", decoded.VersionOneof))
+	}
+}
@@ -24,28 +24,13 @@ var ErrNilZedToken = errors.New("zedtoken pointer was nil")
 
 // MustNewFromRevision generates an encoded zedtoken from an integral revision.
 func MustNewFromRevision(revision datastore.Revision) *v1.ZedToken {
-	encoded, err := NewFromRevision(revision)
-	if err != nil {
-		panic(err)
-	}
-	return encoded
+	return defaultCodec.MustNewFromRevision(revision)
 }
 
-// NewFromRevision generates an encoded zedtoken from an integral revision.
+// NewFromRevision generates an encoded zedtoken from an integral revision,
+// using EncodingV1.
 func NewFromRevision(revision datastore.Revision) (*v1.ZedToken, error) {
-	toEncode := &zedtoken.DecodedZedToken{
-		VersionOneof: &zedtoken.DecodedZedToken_V1{
-			V1: &zedtoken.DecodedZedToken_V1ZedToken{
-				Revision: revision.String(),
-			},
-		},
-	}
-	encoded, err := Encode(toEncode)
-	if err != nil {
-		return nil, fmt.Errorf(errEncodeError, err)
-	}
-
-	return encoded, nil
+	return defaultCodec.NewFromRevision(revision)
 }
 
 // Encode converts a decoded zedtoken to its opaque version.
@@ -76,31 +61,10 @@ func Decode(encoded *v1.ZedToken) (*zedtoken.DecodedZedToken, error) {
 	return decoded, nil
 }
 
-// DecodeRevision converts and extracts the revision from a zedtoken or legacy zookie.
+// DecodeRevision converts and extracts the revision from a zedtoken or legacy
+// zookie of any encoding version.
 func DecodeRevision(encoded *v1.ZedToken, ds revisionDecoder) (datastore.Revision, error) {
-	decoded, err := Decode(encoded)
-	if err != nil {
-		return datastore.NoRevision, err
-	}
-
-	switch ver := decoded.VersionOneof.(type) {
-	case *zedtoken.DecodedZedToken_DeprecatedV1Zookie:
-		revString := fmt.Sprintf("%d", ver.DeprecatedV1Zookie.Revision)
-		parsed, err := ds.RevisionFromString(revString)
-		if err != nil {
-			return datastore.NoRevision, fmt.Errorf(errDecodeError, err)
-		}
-		return parsed, nil
-
-	case *zedtoken.DecodedZedToken_V1:
-		parsed, err := ds.RevisionFromString(ver.V1.Revision)
-		if err != nil {
-			return datastore.NoRevision, fmt.Errorf(errDecodeError, err)
-		}
-		return parsed, nil
-	default:
-		return datastore.NoRevision, fmt.Errorf(errDecodeError, fmt.Errorf("unknown zookie version: %T", decoded.VersionOneof))
-	}
+	return defaultCodec.DecodeRevision(encoded, ds)
 }
 
 type revisionDecoder interface {
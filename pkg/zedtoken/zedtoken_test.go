@@ -223,3 +223,118 @@ func TestHLCDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestCodecEncodingVersions(t *testing.T) {
+	decoder := revisions.CommonDecoder{Kind: revisions.TransactionID}
+	rev := revisions.NewForTransactionID(42)
+
+	v1Codec, err := NewCodec()
+	require.NoError(t, err)
+	v1Token, err := v1Codec.NewFromRevision(rev)
+	require.NoError(t, err)
+
+	// A V1 token is identical to those produced without a codec.
+	defaultToken, err := NewFromRevision(rev)
+	require.NoError(t, err)
+	require.Equal(t, defaultToken.Token, v1Token.Token)
+
+	v2Codec, err := NewCodec(WithEmitVersion(EncodingV2))
+	require.NoError(t, err)
+	v2Token, err := v2Codec.NewFromRevision(rev)
+	require.NoError(t, err)
+	require.NotEqual(t, v1Token.Token, v2Token.Token)
+
+	t.Run("v2 token round trips", func(t *testing.T) {
+		decoded, err := v2Codec.DecodeRevision(v2Token, decoder)
+		require.NoError(t, err)
+		require.True(t, rev.Equal(decoded))
+	})
+
+	t.Run("v1 token decodes after upgrading to v2", func(t *testing.T) {
+		decoded, err := v2Codec.DecodeRevision(v1Token, decoder)
+		require.NoError(t, err)
+		require.True(t, rev.Equal(decoded))
+
+		zookie, err := v2Codec.DecodeRevision(&v1.ZedToken{Token: "CAESAggC"}, decoder)
+		require.NoError(t, err)
+		require.True(t, revisions.NewForTransactionID(2).Equal(zookie))
+	})
+
+	t.Run("v2 token decodes before emitting v2", func(t *testing.T) {
+		decoded, err := DecodeRevision(v2Token, decoder)
+		require.NoError(t, err)
+		require.True(t, rev.Equal(decoded))
+	})
+
+	t.Run("legacy tokens rejected", func(t *testing.T) {
+		strict, err := NewCodec(WithEmitVersion(EncodingV2), WithAcceptLegacy(false))
+		require.NoError(t, err)
+
+		_, err = strict.DecodeRevision(v1Token, decoder)
+		require.ErrorContains(t, err, "legacy zedtoken encoding version 1")
+
+		_, err = strict.DecodeRevision(v2Token, decoder)
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, err := NewCodec(WithEmitVersion(3))
+		require.Error(t, err)
+	})
+}
+
+func TestCodecOrigin(t *testing.T) {
+	decoder := revisions.CommonDecoder{Kind: revisions.HybridLogicalClock}
+	rev, err := revisions.HLCRevisionFromString("1235.0000000001")
+	require.NoError(t, err)
+
+	current, err := NewCodec(WithEmitVersion(EncodingV2), WithOrigin("current"))
+	require.NoError(t, err)
+	previous, err := NewCodec(WithEmitVersion(EncodingV2), WithOrigin("previous"))
+	require.NoError(t, err)
+
+	currentToken, err := current.NewFromRevision(rev)
+	require.NoError(t, err)
+	previousToken, err := previous.NewFromRevision(rev)
+	require.NoError(t, err)
+
+	t.Run("same origin", func(t *testing.T) {
+		require.NoError(t, current.ValidateRevisionOrigin(currentToken))
+		decoded, err := current.DecodeRevision(currentToken, decoder)
+		require.NoError(t, err)
+		require.True(t, rev.Equal(decoded))
+	})
+
+	t.Run("different origin", func(t *testing.T) {
+		var originErr RevisionOriginError
+		require.ErrorAs(t, current.ValidateRevisionOrigin(previousToken), &originErr)
+		require.Equal(t, "current", originErr.Expected)
+		require.Equal(t, "previous", originErr.Actual)
+
+		_, err := current.DecodeRevision(previousToken, decoder)
+		require.ErrorAs(t, err, &originErr)
+	})
+
+	t.Run("tokens without an origin are accepted", func(t *testing.T) {
+		v1Token, err := NewFromRevision(rev)
+		require.NoError(t, err)
+		require.NoError(t, current.ValidateRevisionOrigin(v1Token))
+
+		v2Codec, err := NewCodec(WithEmitVersion(EncodingV2))
+		require.NoError(t, err)
+		v2Token, err := v2Codec.NewFromRevision(rev)
+		require.NoError(t, err)
+		require.NoError(t, current.ValidateRevisionOrigin(v2Token))
+	})
+
+	t.Run("decoders without an origin ignore it", func(t *testing.T) {
+		decoded, err := DecodeRevision(previousToken, decoder)
+		require.NoError(t, err)
+		require.True(t, rev.Equal(decoded))
+	})
+
+	t.Run("origin requires v2", func(t *testing.T) {
+		_, err := NewCodec(WithOrigin("current"))
+		require.ErrorContains(t, err, "zedtoken origin requires encoding version 2")
+	})
+}